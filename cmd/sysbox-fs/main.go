@@ -17,13 +17,16 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"math/rand"
 	"os"
 	"os/signal"
 	"runtime"
+	"strings"
 	"syscall"
 	"time"
 
@@ -70,6 +73,8 @@ var (
 //
 func exitHandler(
 	signalChan chan os.Signal,
+	css domain.ContainerStateServiceIface,
+	nss domain.NSenterServiceIface,
 	fss domain.FuseServerServiceIface,
 	profile interface{ Stop() }) {
 
@@ -105,6 +110,12 @@ func exitHandler(
 		logrus.Warnf("\n\n%s\n", string(stacktrace[:length]))
 	}
 
+	// Unregister all containers and release their cached state.
+	css.Shutdown()
+
+	// Drain ongoing nsenter requests (new ones are rejected from now on).
+	nss.Shutdown()
+
 	// Destroy fuse-service and inner fuse-servers.
 	fss.DestroyFuseService()
 
@@ -120,6 +131,62 @@ func exitHandler(
 	os.Exit(0)
 }
 
+// Emits the audit records of the containers' write operations as structured
+// log entries, so that they can be routed (e.g. to a SIEM) along with the rest
+// of sysbox-fs' logs.
+func logAuditRecord(rec domain.HandlerAuditRecord) {
+
+	result := "success"
+	if rec.Err != nil {
+		result = rec.Err.Error()
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"audit":     "write",
+		"time":      rec.Time.Format(time.RFC3339Nano),
+		"container": rec.Container,
+		"pid":       rec.Pid,
+		"path":      rec.Path,
+		"value":     rec.Value,
+		"result":    result,
+	}).Info("sysbox-fs audit record")
+}
+
+// Parses the per-handler settings (indexed by handler path) held by the passed
+// JSON file, e.g. {"/proc/sys/kernel/watchdog": {"writeMode": "passthrough"}}.
+func loadHandlerConfig(path string) (map[string]domain.HandlerConfig, error) {
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var config map[string]domain.HandlerConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("invalid handler config %v: %v", path, err)
+	}
+
+	return config, nil
+}
+
+// Pins kernel.unprivileged_userns_clone to enabled within the given handler
+// configuration, on top of any other setting of the handler.
+func enforceUsernsClone(
+	config map[string]domain.HandlerConfig) map[string]domain.HandlerConfig {
+
+	const path = "/proc/sys/kernel/unprivileged_userns_clone"
+
+	if config == nil {
+		config = make(map[string]domain.HandlerConfig)
+	}
+
+	cfg := config[path]
+	cfg.WriteMode = domain.WriteModeEnforceEnabled
+	config[path] = cfg
+
+	return config
+}
+
 // Run cpu / memory profiling collection.
 func runProfiler(ctx *cli.Context) (interface{ Stop() }, error) {
 
@@ -185,6 +252,95 @@ func main() {
 			Name:  "allow-immutable-unmounts",
 			Usage: "sys container's initial mounts are considered immutable; this option allows them to be unmounted from within the container (default: \"true\")",
 		},
+		cli.BoolFlag{
+			Name:  "single-instance",
+			Usage: "sysbox-fs is the only instance running on the host; skips the mitigations for races among instances (default: \"false\")",
+		},
+		cli.BoolFlag{
+			Name:  "host-uptime",
+			Usage: "report the host's uptime in the containers' /proc/uptime rather than the containers' own one (default: \"false\")",
+		},
+		cli.BoolFlag{
+			Name:  "sysctl-readonly",
+			Usage: "present /proc/sys as read-only to all the containers, regardless of their registration settings; sysctl writes are rejected with EROFS (default: \"false\")",
+		},
+		cli.BoolFlag{
+			Name:  "audit-writes",
+			Usage: "log an audit record for every write operation performed by the containers over the emulated resources (default: \"false\")",
+		},
+		cli.IntFlag{
+			Name:  "handler-lookup-cache-size",
+			Value: 1024,
+			Usage: "number of path-to-handler resolutions to cache; zero disables caching",
+		},
+		cli.IntFlag{
+			Name:  "write-coalesce-window",
+			Value: 0,
+			Usage: "period (in milliseconds) within which successive writes of a sysctl by the same container are collapsed into a single host update; zero disables coalescing; coalesced writes are acknowledged before reaching the host, so host failures can't be reported to the writer",
+		},
+		cli.IntFlag{
+			Name:  "host-push-retries",
+			Value: 5,
+			Usage: "maximum number of attempts made to push a merged sysctl value to the host when racing with other sysbox instances (ignored with --single-instance)",
+		},
+		cli.IntFlag{
+			Name:  "host-push-backoff",
+			Value: 100,
+			Usage: "upper bound (in microseconds) of the random delay between attempts to push a merged sysctl value to the host",
+		},
+		cli.BoolFlag{
+			Name:  "userns-clone-enforce-enabled",
+			Usage: "pin kernel.unprivileged_userns_clone to enabled: containers may only set it to 1, which is pushed down to the host (default: \"false\")",
+		},
+		cli.IntFlag{
+			Name:  "path-max-len",
+			Value: syscall.PathMax,
+			Usage: "maximum length of the paths accepted by the emulated resources' handlers (longer ones fail with ENAMETOOLONG); zero disables the limit",
+		},
+		cli.IntFlag{
+			Name:  "path-max-depth",
+			Value: 64,
+			Usage: "maximum number of components of the paths accepted by the emulated resources' handlers (deeper ones fail with ENAMETOOLONG); zero disables the limit",
+		},
+		cli.BoolFlag{
+			Name:  "nsenter-health-check",
+			Usage: "refuse to start (rather than just logging the failure) if the nsenter machinery is found broken at startup (default: \"false\")",
+		},
+		cli.IntFlag{
+			Name:  "nsenter-readdir-max-entries",
+			Value: 4096,
+			Usage: "maximum number of directory entries per nsenter response (larger dirs are paginated); zero disables the limit",
+		},
+		cli.StringFlag{
+			Name:  "mounts",
+			Value: "",
+			Usage: "comma-separated list of <path>:<subdir> pairs defining the fs trees to emulate and their mountpoints relative to each container's base mountpoint (e.g. \"/proc:proc,/sys:sys\"); empty emulates \"/\" at the base mountpoint (default: \"\")",
+		},
+		cli.StringFlag{
+			Name:  "root-entries",
+			Value: strings.Join(fuse.DefaultRootEntries, ","),
+			Usage: "comma-separated list of the top-level directories displayed under the emulated root dir",
+		},
+		cli.StringFlag{
+			Name:  "cache-warmup",
+			Value: "",
+			Usage: "comma-separated list of /proc/sys resources to prefetch into the cache of the containers upon registration; empty disables warming (default: \"\")",
+		},
+		cli.StringFlag{
+			Name:  "persist-dir",
+			Value: "",
+			Usage: "directory where the state emulated by persistent handlers is kept across restarts; empty disables persistence (default: \"\")",
+		},
+		cli.StringFlag{
+			Name:  "handler-config",
+			Value: "",
+			Usage: "JSON file with per-handler settings (enabled, mergePolicy, writeMode, acceptMissing, allowedContainers) indexed by resource path; e.g. writeMode \"passthrough\" lets the containers modify a host-global sysctl, and acceptMissing lets them write net-ns sysctls missing in the host kernel (default: \"\")",
+		},
+		cli.StringFlag{
+			Name:  "nsenter-agent",
+			Value: "",
+			Usage: "path to the sysbox-fs binary to launch as nsenter agent instead of /proc/self/exe; it's validated at startup (default: \"\")",
+		},
 		cli.StringFlag{
 			Name:  "log",
 			Value: "",
@@ -341,7 +497,13 @@ func main() {
 		// Setup sysbox-fs services.
 		processService.Setup(ioService)
 
-		nsenterService.Setup(processService, nil)
+		if err := nsenterService.Setup(
+			processService,
+			nil,
+			ctx.GlobalString("nsenter-agent")); err != nil {
+			logrus.Fatalf("nsenter service setup failed: %v. Exiting ...", err)
+		}
+		nsenterService.SetReadDirMaxEntries(ctx.Int("nsenter-readdir-max-entries"))
 
 		handlerService.Setup(
 			handler.DefaultHandlers,
@@ -351,6 +513,32 @@ func main() {
 			processService,
 			ioService,
 		)
+		handlerService.SetSingleInstance(ctx.Bool("single-instance"))
+		handlerService.SetLookupCacheSize(ctx.Int("handler-lookup-cache-size"))
+		handlerService.SetWriteCoalesceWindow(
+			time.Duration(ctx.Int("write-coalesce-window")) * time.Millisecond)
+		handlerService.SetPushRetries(ctx.Int("host-push-retries"),
+			time.Duration(ctx.Int("host-push-backoff"))*time.Microsecond)
+		handlerService.SetPathLimits(ctx.Int("path-max-len"), ctx.Int("path-max-depth"))
+		handlerService.SetHostUptime(ctx.Bool("host-uptime"))
+		if ctx.Bool("audit-writes") {
+			handlerService.SetAuditor(logAuditRecord)
+		}
+		config := make(map[string]domain.HandlerConfig)
+		if path := ctx.GlobalString("handler-config"); path != "" {
+			var err error
+			if config, err = loadHandlerConfig(path); err != nil {
+				logrus.Fatalf("Invalid --handler-config option: %v", err)
+			}
+		}
+		if ctx.Bool("userns-clone-enforce-enabled") {
+			config = enforceUsernsClone(config)
+		}
+		if len(config) > 0 {
+			if err := handlerService.Reload(config); err != nil {
+				logrus.Fatalf("handler service configuration failed: %v. Exiting ...", err)
+			}
+		}
 
 		fuseServerService.Setup(
 			ctx.GlobalString("mountpoint"),
@@ -358,6 +546,14 @@ func main() {
 			ioService,
 			handlerService,
 		)
+		fuseServerService.SetRootEntries(strings.Split(ctx.GlobalString("root-entries"), ","))
+		if spec := ctx.GlobalString("mounts"); spec != "" {
+			mounts, err := fuse.ParseMounts(spec)
+			if err != nil {
+				logrus.Fatalf("Invalid --mounts option: %v", err)
+			}
+			fuseServerService.SetMounts(mounts)
+		}
 
 		containerStateService.Setup(
 			fuseServerService,
@@ -365,6 +561,17 @@ func main() {
 			ioService,
 			mountService,
 		)
+		if dir := ctx.GlobalString("persist-dir"); dir != "" {
+			if err := containerStateService.SetPersistDir(dir); err != nil {
+				logrus.Fatalf("container-state persistence setup failed: %v. Exiting ...", err)
+			}
+		}
+		containerStateService.SetSysctlReadOnly(ctx.Bool("sysctl-readonly"))
+		if paths := ctx.GlobalString("cache-warmup"); paths != "" {
+			handlerService.SetCacheWarmup(strings.Split(paths, ","))
+			containerStateService.SetCacheWarmer(handlerService)
+		}
+		containerStateService.SetWriteCanceller(handlerService)
 
 		mountService.Setup(
 			containerStateService,
@@ -404,11 +611,26 @@ func main() {
 			syscall.SIGTERM,
 			syscall.SIGSEGV,
 			syscall.SIGQUIT)
-		go exitHandler(exitChan, fuseServerService, profile)
+		go exitHandler(
+			exitChan,
+			containerStateService,
+			nsenterService,
+			fuseServerService,
+			profile)
 
 		// TODO: Consider adding sync.Workgroups to ensure that all goroutines
 		// are done with their in-fly tasks before exit()ing.
 
+		// Flag a broken nsenter machinery (e.g. the agent can't be re-exec'ed),
+		// as no container could be served. Readiness is only withheld if
+		// explicitly requested, as the failure may well be transient.
+		if err := handlerService.HealthCheck(); err != nil {
+			if ctx.Bool("nsenter-health-check") {
+				logrus.Fatalf("%v. Exiting ...", err)
+			}
+			logrus.Errorf("%v", err)
+		}
+
 		systemd.SdNotify(false, systemd.SdNotifyReady)
 
 		logrus.Info("Ready ...")
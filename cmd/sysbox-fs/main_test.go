@@ -18,9 +18,14 @@ package main
 
 import (
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 
 	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
 )
 
 func TestMain(m *testing.M) {
@@ -30,3 +35,130 @@ func TestMain(m *testing.M) {
 
 	m.Run()
 }
+
+func TestLoadHandlerConfig(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "sysbox-fs-handler-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	enabled := false
+
+	tests := []struct {
+		name    string
+		data    string
+		want    map[string]domain.HandlerConfig
+		wantErr bool
+	}{
+		{
+			//
+			// Test-case 1: Valid config.
+			//
+			name: "1",
+			data: `{
+				"/proc/sys/kernel/watchdog": {"writeMode": "passthrough", "allowedContainers": ["c1"]},
+				"/proc/sys/kernel/hung_task_timeout_secs": {"enabled": false, "mergePolicy": "max"}
+			}`,
+			want: map[string]domain.HandlerConfig{
+				"/proc/sys/kernel/watchdog": {
+					WriteMode:         domain.WriteModePassthrough,
+					AllowedContainers: []string{"c1"},
+				},
+				"/proc/sys/kernel/hung_task_timeout_secs": {
+					Enabled:     &enabled,
+					MergePolicy: domain.MergePolicyMax,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			//
+			// Test-case 2: Malformed config.
+			//
+			name:    "2",
+			data:    `{"/proc/sys/kernel/watchdog": "passthrough"}`,
+			want:    nil,
+			wantErr: true,
+		},
+	}
+
+	//
+	// Testcase executions.
+	//
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name+".json")
+			if err := ioutil.WriteFile(path, []byte(tt.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := loadHandlerConfig(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadHandlerConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loadHandlerConfig() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := loadHandlerConfig(filepath.Join(dir, "missing.json")); err == nil {
+		t.Errorf("loadHandlerConfig() of a missing file succeeded, want error")
+	}
+}
+
+func TestEnforceUsernsClone(t *testing.T) {
+
+	const path = "/proc/sys/kernel/unprivileged_userns_clone"
+
+	tests := []struct {
+		name   string
+		config map[string]domain.HandlerConfig
+		want   map[string]domain.HandlerConfig
+	}{
+		{
+			//
+			// Test-case 1: No prior config.
+			//
+			name:   "1",
+			config: nil,
+			want: map[string]domain.HandlerConfig{
+				path: {WriteMode: domain.WriteModeEnforceEnabled},
+			},
+		},
+		{
+			//
+			// Test-case 2: Other handlers' settings and the handler's allow-list
+			// are preserved, while its write mode is overridden.
+			//
+			name: "2",
+			config: map[string]domain.HandlerConfig{
+				"/proc/sys/kernel/watchdog": {WriteMode: domain.WriteModePassthrough},
+				path: {
+					WriteMode:         domain.WriteModeReadOnly,
+					AllowedContainers: []string{"c1"},
+				},
+			},
+			want: map[string]domain.HandlerConfig{
+				"/proc/sys/kernel/watchdog": {WriteMode: domain.WriteModePassthrough},
+				path: {
+					WriteMode:         domain.WriteModeEnforceEnabled,
+					AllowedContainers: []string{"c1"},
+				},
+			},
+		},
+	}
+
+	//
+	// Testcase executions.
+	//
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := enforceUsernsClone(tt.config); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("enforceUsernsClone() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -17,6 +17,7 @@
 package domain
 
 import (
+	"strings"
 	"time"
 )
 
@@ -33,8 +34,11 @@ type ContainerIface interface {
 	Data(path string, name string) (string, bool)
 	UID() uint32
 	GID() uint32
+	UIDSize() uint32
+	GIDSize() uint32
 	ProcRoPaths() []string
 	ProcMaskPaths() []string
+	SysctlReadOnly() bool
 	InitProc() ProcessIface
 	ExtractInode(path string) (Inode, error)
 	IsImmutableMount(info *MountInfo) bool
@@ -46,11 +50,26 @@ type ContainerIface interface {
 	IsImmutableMountpoint(mp string) bool
 	IsImmutableRoMountpoint(mp string) bool
 	IsImmutableOverlapMountpoint(mp string) bool
+	CacheStats() CacheStats
 	//
 	// Setters
 	//
 	SetData(path string, name string, data string)
 	SetInitProc(pid, uid, gid uint32) error
+	SetSysctlReadOnly(val bool)
+	//
+	// Counters of the reads served from the container's data store (hits) vs
+	// those that had to be fetched from the host FS (misses).
+	//
+	CacheHit()
+	CacheMiss()
+	//
+	// Validation of the container's init process, to be carried out before
+	// serving any request on behalf of the container. If its init pid turns
+	// out to be held by an unrelated process (e.g. after a pid wraparound),
+	// the state cached for the container is reset and false is returned.
+	//
+	Validate() bool
 	//
 	// Locks for read-modify-write operations on container data via the Data()
 	// and SetData() methods.
@@ -59,6 +78,60 @@ type ContainerIface interface {
 	Unlock()
 }
 
+// IsMaskedPath reports whether the given path matches (or is located under) any
+// of the OCI masked paths of the given container.
+func IsMaskedPath(c ContainerIface, path string) bool {
+	if c == nil {
+		return false
+	}
+
+	return pathListMatch(c.ProcMaskPaths(), path)
+}
+
+// IsRoPath reports whether the given path matches (or is located under) any of
+// the OCI read-only paths of the given container, or is a sysctl of a container
+// whose /proc/sys is presented as read-only.
+func IsRoPath(c ContainerIface, path string) bool {
+	if c == nil {
+		return false
+	}
+
+	if c.SysctlReadOnly() && pathListMatch([]string{"/proc/sys"}, path) {
+		return true
+	}
+
+	return pathListMatch(c.ProcRoPaths(), path)
+}
+
+func pathListMatch(list []string, path string) bool {
+	for _, p := range list {
+		if path == p || strings.HasPrefix(path, strings.TrimSuffix(p, "/")+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CacheStats holds the container's data-store hit / miss counters.
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+// ContainerSnapshot holds a point-in-time copy of the state of a registered
+// container, meant for debugging purposes (see ContainerDBSnapshot()).
+type ContainerSnapshot struct {
+	ID           string    `json:"id"`
+	InitPid      uint32    `json:"initPid"`
+	Ctime        time.Time `json:"ctime"`
+	UidFirst     uint32    `json:"uidFirst"`
+	UidSize      uint32    `json:"uidSize"`
+	GidFirst     uint32    `json:"gidFirst"`
+	GidSize      uint32    `json:"gidSize"`
+	CacheEntries int       `json:"cacheEntries"`
+}
+
 //
 // Auxiliary types to deal with the per-container-state associated to all the
 // emulated resources.
@@ -66,6 +139,18 @@ type ContainerIface interface {
 type StateDataMap = map[string]map[string]string
 type StateData = map[string]string
 
+// CacheWarmer prefetches the state of the resources most commonly accessed by
+// containers into their cache (see HandlerServiceIface's WarmupCache()).
+type CacheWarmer interface {
+	WarmupCache(cntr ContainerIface)
+}
+
+// WriteCanceller drops the host FS updates still pending on behalf of departing
+// containers (see HandlerServiceIface's CancelWrites()).
+type WriteCanceller interface {
+	CancelWrites(cntr ContainerIface)
+}
+
 //
 // ContainerStateService interface defines the APIs that sysbox-fs components
 // must utilize to interact with the sysbox-fs state-storage backend.
@@ -87,12 +172,14 @@ type ContainerStateServiceIface interface {
 		gidSize uint32,
 		procRoPaths []string,
 		procMaskPaths []string,
+		sysctlRo bool,
 		service ContainerStateServiceIface) ContainerIface
 
 	ContainerPreRegister(id string) error
 	ContainerRegister(c ContainerIface) error
 	ContainerUpdate(c ContainerIface) error
 	ContainerUnregister(c ContainerIface) error
+	ContainerMarkDead(c ContainerIface) error
 	ContainerLookupById(id string) ContainerIface
 	ContainerLookupByInode(usernsInode Inode) ContainerIface
 	ContainerLookupByProcess(process ProcessIface) ContainerIface
@@ -100,4 +187,14 @@ type ContainerStateServiceIface interface {
 	ProcessService() ProcessServiceIface
 	MountService() MountServiceIface
 	ContainerDBSize() int
+	ContainerDBSnapshot() []ContainerSnapshot
+	SetCacheWarmer(w CacheWarmer)
+	SetWriteCanceller(w WriteCanceller)
+	SetSysctlReadOnly(val bool)
+	Shutdown()
+
+	// Persistence of the state of the resources emulated by the handlers
+	// flagged as 'Persistent'.
+	SetPersistDir(dir string) error
+	RegisterPersistentPath(path string)
 }
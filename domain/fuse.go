@@ -34,5 +34,5 @@ type FuseServerIface interface {
 	Destroy() error
 	MountPoint() string
 	Unmount()
-	InitWait()
+	InitWait() error
 }
@@ -20,6 +20,7 @@ import (
 	"os"
 	"sync"
 	"syscall"
+	"time"
 )
 
 type HandlerType int
@@ -70,8 +71,201 @@ type HandlerBase struct {
 	Type      HandlerType
 	Enabled   bool
 	Cacheable bool
-	Lock      sync.Mutex
+	Lock      HandlerLock
 	Service   HandlerServiceIface
+
+	// Values emulated by this handler survive sysbox-fs restarts (as long as a
+	// persistence store is configured in the container-state service).
+	Persistent bool
+
+	// The emulated resource can only be written (e.g. trigger-like knobs such
+	// as route/flush): attempts to open it for reading, or to read from it,
+	// are rejected (EACCES) by the fuse layer.
+	WriteOnly bool
+
+	// The emulated resource is structurally read-only (i.e. its content is
+	// synthesized by sysbox-fs and there's nothing to write it into): attempts
+	// to open it for writing, or to write into it, are rejected with EROFS
+	// by the fuse layer, rather than with the EPERM / EACCES errors reserved
+	// for lack of permissions. Read-only handlers are never Writable().
+	ReadOnly bool
+
+	// The content of the emulated resource spans multiple lines, and must thereby
+	// be served verbatim: no whitespace trimming nor newline appending.
+	Raw bool
+
+	// Namespace (of the reading process) on which the content served by the
+	// handler depends, so that processes in different namespaces (e.g. the
+	// ones of nested containers) are given their own view. Cacheable content
+	// is keyed on it; non-cached one is expected to be generated within it on
+	// every read (see NsFileCompute()). Empty for content shared by all the
+	// processes of the container.
+	NsKey NStype
+
+	// Type of the values accepted by the emulated resource, and number of
+	// elements expected for tuple types (zero means any).
+	ValueType ValueType
+	ValueLen  int
+}
+
+// ValueType describes the type of the values expected by the kernel for an
+// emulated resource. Writes not matching it are rejected upfront.
+type ValueType string
+
+const (
+	// No validation is carried out.
+	ValueTypeAny ValueType = ""
+
+	// Signed decimal integer.
+	ValueTypeInt ValueType = "int"
+
+	// Unsigned 64-bit decimal integer.
+	ValueTypeUint64 ValueType = "uint64"
+
+	// Boolean value (0 / 1).
+	ValueTypeBool ValueType = "bool"
+
+	// Whitespace-separated list of signed decimal integers.
+	ValueTypeTuple ValueType = "tuple"
+
+	// Single-line string.
+	ValueTypeString ValueType = "string"
+
+	// Unsigned 64-bit integer, either in decimal or 0x-prefixed hex notation.
+	ValueTypeBitmask ValueType = "bitmask"
+)
+
+// MergePolicy describes how values written by sys containers into an emulated
+// resource are reconciled with the host's one.
+type MergePolicy string
+
+const (
+	// Values are kept within the container's scope; host is never updated.
+	MergePolicyNone MergePolicy = "none"
+
+	// Host holds the highest value written across all containers.
+	MergePolicyMax MergePolicy = "max"
+
+	// Host holds the lowest value written across all containers.
+	MergePolicyMin MergePolicy = "min"
+
+	// Host holds the bitwise-or of the values written across all containers.
+	MergePolicyOr MergePolicy = "or"
+
+	// Host holds the bitwise-and of the values written across all containers.
+	MergePolicyAnd MergePolicy = "and"
+
+	// Host holds the last value written by any container.
+	MergePolicyLast MergePolicy = "last"
+
+	// Elements of tuples are reconciled as per their own (element-specific)
+	// policy.
+	MergePolicyField MergePolicy = "field"
+)
+
+// Default capabilities of a handler. Handlers diverging from these ones are
+// expected to override the corresponding methods.
+func (h *HandlerBase) GetCacheable() bool {
+	return h.Cacheable
+}
+
+// IsCacheable tells whether the given request can be served from (and update)
+// the container's cache. Handlers whose cacheability depends on the request
+// (e.g. on the namespaces of the requester) are expected to override it; by
+// default it's just the static Cacheable attribute.
+func (h *HandlerBase) IsCacheable(req *HandlerRequest) bool {
+	return h.Cacheable
+}
+
+func (h *HandlerBase) GetPersistent() bool {
+	return h.Persistent
+}
+
+func (h *HandlerBase) GetWriteOnly() bool {
+	return h.WriteOnly
+}
+
+func (h *HandlerBase) GetReadOnly() bool {
+	return h.ReadOnly
+}
+
+// Writable reports whether writes into the emulated resource can take effect.
+// It's derived from the ReadOnly attribute, which structurally read-only
+// handlers are expected to set rather than overriding this method; only
+// handlers accepting writes conditionally (e.g. guarded ones) further restrict
+// it.
+func (h *HandlerBase) Writable() bool {
+	return !h.ReadOnly
+}
+
+func (h *HandlerBase) MergePolicy() MergePolicy {
+	return MergePolicyNone
+}
+
+// Rmdir is only meaningful for handlers that pass-through operations into the
+// container's namespaces; emulated directories can't be removed.
+func (h *HandlerBase) Rmdir(n IOnodeIface, req *HandlerRequest) error {
+	return syscall.EPERM
+}
+
+// Clone returns a copy of the handler's attributes. The copy shares the lock of
+// the original handler, so that host accesses carried out through both of them
+// (e.g. by requests in-flight during a reload) remain serialized.
+func (h *HandlerBase) Clone() HandlerBase {
+	return HandlerBase{
+		Name:       h.Name,
+		Path:       h.Path,
+		Type:       h.Type,
+		Enabled:    h.Enabled,
+		Cacheable:  h.Cacheable,
+		Lock:       HandlerLock{mu: h.Lock.mutex()},
+		Service:    h.Service,
+		Persistent: h.Persistent,
+		WriteOnly:  h.WriteOnly,
+		ReadOnly:   h.ReadOnly,
+		Raw:        h.Raw,
+		NsKey:      h.NsKey,
+		ValueType:  h.ValueType,
+		ValueLen:   h.ValueLen,
+	}
+}
+
+// HandlerLock is the mutex serializing the host accesses of a handler. Its zero
+// value is ready to use; handler copies obtained through Clone() share the
+// underlying mutex.
+type HandlerLock struct {
+	once sync.Once
+	mu   *sync.Mutex
+}
+
+func (l *HandlerLock) mutex() *sync.Mutex {
+	l.once.Do(func() {
+		if l.mu == nil {
+			l.mu = new(sync.Mutex)
+		}
+	})
+
+	return l.mu
+}
+
+func (l *HandlerLock) Lock() {
+	l.mutex().Lock()
+}
+
+func (l *HandlerLock) Unlock() {
+	l.mutex().Unlock()
+}
+
+// HandlerMetadata summarizes the attributes and capabilities of a handler, for
+// documentation and tooling purposes.
+type HandlerMetadata struct {
+	Name        string      `json:"name"`
+	Path        string      `json:"path"`
+	Type        HandlerType `json:"type"`
+	Enabled     bool        `json:"enabled"`
+	Cacheable   bool        `json:"cacheable"`
+	Writable    bool        `json:"writable"`
+	MergePolicy MergePolicy `json:"mergePolicy"`
 }
 
 // HandlerRequest represents a request to be processed by a handler
@@ -85,6 +279,84 @@ type HandlerRequest struct {
 	Container ContainerIface
 }
 
+// HandlerOp identifies the handler operations subject to authorization.
+type HandlerOp string
+
+const (
+	HandlerOpLookup HandlerOp = "lookup"
+	HandlerOpRead   HandlerOp = "read"
+	HandlerOpWrite  HandlerOp = "write"
+)
+
+// HandlerAuthorizer is an (optional) operator-provided policy function invoked
+// ahead of every Lookup / Read / Write handler operation. A zero errno allows
+// the operation; any other value denies it and is returned to the requester.
+type HandlerAuthorizer func(
+	cntr ContainerIface,
+	pid uint32,
+	path string,
+	op HandlerOp) syscall.Errno
+
+// HandlerAuditRecord describes a write operation performed by a container over
+// an emulated resource, along with its outcome (nil Err on success).
+type HandlerAuditRecord struct {
+	Time      time.Time
+	Container string
+	Pid       uint32
+	Path      string
+	Value     string
+	Err       error
+}
+
+// HandlerAuditor is an (optional) operator-provided function receiving the
+// audit records of write operations (e.g. to forward them to a SIEM). It's
+// executed asynchronously, away from the write path.
+type HandlerAuditor func(rec HandlerAuditRecord)
+
+// HandlerWriteMode determines whether, and how, the writes of the containers
+// over a host-global resource reach the host FS.
+type HandlerWriteMode string
+
+const (
+	// Writes are rejected (EPERM).
+	WriteModeReadOnly HandlerWriteMode = "read-only"
+
+	// Writes are validated and accepted, but never reach the host FS.
+	WriteModeNoop HandlerWriteMode = "no-op"
+
+	// Writes within the supported range are pushed down to the host FS.
+	WriteModePassthrough HandlerWriteMode = "passthrough"
+
+	// Writes only reach the host FS when raising its value.
+	WriteModeEnforceMax HandlerWriteMode = "enforce-max"
+
+	// Only writes enabling (max value) the resource are accepted.
+	WriteModeEnforceEnabled HandlerWriteMode = "enforce-enabled"
+
+	// Only writes disabling (zero value) the resource are accepted.
+	WriteModeEnforceDisabled HandlerWriteMode = "enforce-disabled"
+)
+
+// HandlerConfig holds the settings of a handler that can be changed at runtime
+// (see HandlerServiceIface.Reload()). Unset (nil / empty) attributes are left
+// untouched; an empty, non-nil AllowedContainers list lifts the restriction
+// (or denies every container, for handlers requiring an allow-list).
+type HandlerConfig struct {
+	Enabled           *bool            `json:"enabled,omitempty"`
+	MergePolicy       MergePolicy      `json:"mergePolicy,omitempty"`
+	WriteMode         HandlerWriteMode `json:"writeMode,omitempty"`
+	AcceptMissing     *bool            `json:"acceptMissing,omitempty"`
+	AllowedContainers []string         `json:"allowedContainers,omitempty"`
+}
+
+// HandlerReconfigurer is implemented by handlers whose merge policy, write mode,
+// missing-resource policy and / or allow-list can be changed at runtime. Reconfigure returns a copy of the
+// handler with the passed settings applied, leaving the receiver untouched, or
+// an error if any of them is not supported.
+type HandlerReconfigurer interface {
+	Reconfigure(cfg HandlerConfig) (HandlerIface, error)
+}
+
 // HandlerIface is the interface that each handler must implement
 type HandlerIface interface {
 	// FS operations.
@@ -95,6 +367,7 @@ type HandlerIface interface {
 	Read(node IOnodeIface, req *HandlerRequest) (int, error)
 	Write(node IOnodeIface, req *HandlerRequest) (int, error)
 	ReadDirAll(node IOnodeIface, req *HandlerRequest) ([]os.FileInfo, error)
+	Rmdir(node IOnodeIface, req *HandlerRequest) error
 
 	// getters/setters.
 	GetName() string
@@ -104,6 +377,15 @@ type HandlerIface interface {
 	SetEnabled(val bool)
 	GetService() HandlerServiceIface
 	SetService(hs HandlerServiceIface)
+
+	// capabilities (see HandlerBase for defaults).
+	GetCacheable() bool
+	IsCacheable(req *HandlerRequest) bool
+	GetPersistent() bool
+	GetWriteOnly() bool
+	GetReadOnly() bool
+	Writable() bool
+	MergePolicy() MergePolicy
 }
 
 type HandlerServiceIface interface {
@@ -122,6 +404,7 @@ type HandlerServiceIface interface {
 	EnableHandler(h HandlerIface) error
 	DisableHandler(h HandlerIface) error
 	DirHandlerEntries(s string) []string
+	HandlersMetadata() []HandlerMetadata
 
 	// getters/setter
 	HandlerDB() map[string]HandlerIface
@@ -131,6 +414,34 @@ type HandlerServiceIface interface {
 	NSenterService() NSenterServiceIface
 	IOService() IOServiceIface
 	IgnoreErrors() bool
+	SingleInstance() bool
+	SetSingleInstance(val bool)
+	WriteCoalesceWindow() time.Duration
+	SetWriteCoalesceWindow(d time.Duration)
+	WritePending(cntr ContainerIface, path string, name string) bool
+	CoalesceWrite(cntr ContainerIface, path string, name string, val string, push func(val string) error) bool
+	CancelWrites(cntr ContainerIface)
+	PushRetries() (int, time.Duration)
+	SetPushRetries(retries int, backoff time.Duration)
+	PathLimits() (int, int)
+	SetPathLimits(maxLen, maxDepth int)
+	CheckPathLimits(path string) syscall.Errno
+	HostUptime() bool
+	SetHostUptime(val bool)
+	SetLookupCacheSize(size int)
+	SetCacheWarmup(paths []string)
+	WarmupCache(cntr ContainerIface)
+	SetAuthorizer(a HandlerAuthorizer)
+	SetAuditor(a HandlerAuditor)
+	Reload(config map[string]HandlerConfig) error
+
+	// Policy enforcement.
+	Authorize(cntr ContainerIface, pid uint32, path string, op HandlerOp) syscall.Errno
+	Audit(rec HandlerAuditRecord)
+
+	// Alternative (non-FUSE) entry points.
+	SysctlWrite(pid uint32, sysctl string, value []byte) syscall.Errno
+	HealthCheck() error
 
 	// Auxiliar methods.
 	HostUserNsInode() Inode
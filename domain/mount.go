@@ -33,6 +33,7 @@ type MountServiceIface interface {
 
 	NewMountHelper() MountHelperIface
 	MountHelper() MountHelperIface
+	IsIDMappedMount(c ContainerIface, path string) bool
 }
 
 // Interface to define the mountInfoParser api.
@@ -53,6 +54,7 @@ type MountInfoParserIface interface {
 	IsSelfMount(info *MountInfo) bool
 	IsOverlapMount(info *MountInfo) bool
 	IsRoMount(info *MountInfo) bool
+	IsIDMappedMount(info *MountInfo) bool
 	IsBindMount(info *MountInfo) bool
 	IsRoBindMount(info *MountInfo) bool
 	IsCloneMount(info *MountInfo, readonly bool) bool
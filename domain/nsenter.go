@@ -16,6 +16,11 @@
 
 package domain
 
+import (
+	"fmt"
+	"time"
+)
+
 // Aliases to leverage strong-typing.
 type NStype = string
 type NSenterMsgType = string
@@ -70,10 +75,13 @@ const (
 	OpenFileResponse      NSenterMsgType = "openFileResponse"
 	ReadFileRequest       NSenterMsgType = "readFileRequest"
 	ReadFileResponse      NSenterMsgType = "readFileResponse"
+	ReadFilesRequest      NSenterMsgType = "readFilesRequest"
+	ReadFilesResponse     NSenterMsgType = "readFilesResponse"
 	WriteFileRequest      NSenterMsgType = "writeFileRequest"
 	WriteFileResponse     NSenterMsgType = "writeFileResponse"
 	ReadDirRequest        NSenterMsgType = "readDirRequest"
 	ReadDirResponse       NSenterMsgType = "readDirResponse"
+	ReadDirPartResponse   NSenterMsgType = "readDirPartResponse"
 	SetAttrRequest        NSenterMsgType = "setAttrRequest"
 	SetAttrResponse       NSenterMsgType = "setAttrResponse"
 	MountSyscallRequest   NSenterMsgType = "mountSyscallRequest"
@@ -88,6 +96,12 @@ const (
 	MountInodeResponse    NSenterMsgType = "mountInodeResponse"
 	SleepRequest          NSenterMsgType = "sleepRequest"
 	SleepResponse         NSenterMsgType = "sleepResponse"
+	AccessRequest         NSenterMsgType = "accessRequest"
+	AccessResponse        NSenterMsgType = "accessResponse"
+	RmdirRequest          NSenterMsgType = "rmdirRequest"
+	RmdirResponse         NSenterMsgType = "rmdirResponse"
+	FstatRequest          NSenterMsgType = "fstatRequest"
+	FstatResponse         NSenterMsgType = "fstatResponse"
 	ErrorResponse         NSenterMsgType = "errorResponse"
 )
 
@@ -104,11 +118,42 @@ type NSenterServiceIface interface {
 		res *NSenterMessage,
 		async bool) NSenterEventIface
 
-	Setup(prs ProcessServiceIface, mts MountServiceIface)
+	Setup(prs ProcessServiceIface, mts MountServiceIface, agentPath string) error
 	SendRequestEvent(e NSenterEventIface) error
 	ReceiveResponseEvent(e NSenterEventIface) *NSenterMessage
 	TerminateRequestEvent(e NSenterEventIface) error
 	GetEventProcessID(e NSenterEventIface) uint32
+	Stats() map[NSenterMsgType]NSenterStats
+	InflightEvents() int
+	SetReadDirMaxEntries(n int)
+	Shutdown()
+}
+
+// Upper bounds of the latency-histogram buckets utilized to account for nsenter
+// round-trips (see NSenterStats). Round-trips exceeding the last bound are
+// accounted for in an additional (overflow) bucket.
+var NSenterLatencyBuckets = []time.Duration{
+	1 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	1 * time.Second,
+}
+
+// NSenterStats holds the accounting of the nsenter events dispatched for a
+// given request type.
+type NSenterStats struct {
+	Success uint64
+	Error   uint64
+	Timeout uint64
+
+	// Aggregated duration of all the round-trips.
+	Latency time.Duration
+
+	// Number of round-trips per latency bucket (see NSenterLatencyBuckets).
+	Histogram []uint64
 }
 
 //
@@ -134,14 +179,49 @@ type NSenterEventIface interface {
 	GetProcessID() uint32
 }
 
+// Protocol version spoken by sysbox-fs 'main' and 'forked' processes. To be
+// bumped whenever a non backwards-compatible change is made to the messages
+// being exchanged. Messages lacking a version (zero value) are assumed to be
+// generated by binaries predating this field, which speak version 1.
+const NSenterMsgVersion uint32 = 1
+
+// NSenterVersionMismatch error is generated upon arrival of a message encoded
+// with a protocol version unknown to the receiving end.
+type NSenterVersionMismatch struct {
+	Version uint32
+}
+
+func (e *NSenterVersionMismatch) Error() string {
+	return fmt.Sprintf("nsenter message version mismatch: received %d, supported %d",
+		e.Version, NSenterMsgVersion)
+}
+
 // NSenterMessage struct defines the layout of the messages being exchanged
 // between sysbox-fs 'main' and 'forked' ones.
 type NSenterMessage struct {
+	// Protocol version of the message (see NSenterMsgVersion).
+	Version uint32 `json:"version,omitempty"`
+
 	// Message type being exchanged.
 	Type NSenterMsgType `json:"message"`
 
 	// Message payload.
 	Payload interface{} `json:"payload"`
+
+	// Credentials to be assumed by the agent prior to processing the request,
+	// so that the permission checks carried out by the kernel during the file
+	// operation match the ones of the original caller (nil = agent's own).
+	Creds *NSenterCreds `json:"creds,omitempty"`
+}
+
+// NSenterCreds holds the effective ids and the supplementary groups to run an
+// nsenter request with. Ids are expected to be the ones of the caller within
+// the user-namespace being entered (e.g. as obtained through ProcessIface's
+// Uid(), Gid() and SGid()).
+type NSenterCreds struct {
+	Uid    uint32   `json:"uid"`
+	Gid    uint32   `json:"gid"`
+	Groups []uint32 `json:"groups"`
 }
 
 type NSenterMsgHeader struct {
@@ -163,9 +243,36 @@ type OpenFilePayload struct {
 	Mode  string `json:"mode"`
 }
 
+// FileHandle identifies a file opened through a prior OpenFileRequest. Nsenter
+// agents are short-lived (one per event), so no descriptor can outlive the
+// open event; the handle carries the identity (device / inode) of the opened
+// file instead, which allows subsequent FstatRequests to refresh its attributes
+// while detecting files replaced in the meantime (ESTALE).
+type FileHandle struct {
+	File string `json:"file"`
+	Dev  uint64 `json:"dev"`
+	Ino  uint64 `json:"ino"`
+}
+
+type FstatPayload struct {
+	Handle FileHandle `json:"handle"`
+}
+
 type ReadFilePayload struct {
 	File    string `json:"file"`
 	Content string `json:"content"`
+
+	// Return the file content verbatim. By default, leading and trailing
+	// whitespaces are trimmed (as expected for single-line sysctls); multi-line
+	// or binary resources must disable it to preserve their content.
+	Raw bool `json:"raw,omitempty"`
+}
+
+// ReadFilesPayload allows a number of files to be read through a single nsenter
+// round-trip. Files that can't be read are left out of the response, which maps
+// each file to its content.
+type ReadFilesPayload struct {
+	Files []ReadFilePayload `json:"files"`
 }
 
 type WriteFilePayload struct {
@@ -175,6 +282,16 @@ type WriteFilePayload struct {
 
 type ReadDirPayload struct {
 	Dir string `json:"dir"`
+
+	// Pagination of large directories: entries to skip, and maximum number of
+	// entries to return (zero means no limit). Exceeding the limit turns the
+	// response into a ReadDirPartResponse.
+	Offset     int `json:"offset,omitempty"`
+	MaxEntries int `json:"maxEntries,omitempty"`
+}
+
+type RmdirPayload struct {
+	Dir string `json:"dir"`
 }
 
 type MountSyscallPayload struct {
@@ -207,3 +324,9 @@ type MountInodeRespPayload struct {
 type SleepReqPayload struct {
 	Ival string `json:"attr"`
 }
+
+type AccessReqPayload struct {
+	Header NSenterMsgHeader `json:"header"`
+	Path   string           `json:"path"`
+	Mode   AccessMode       `json:"mode"`
+}
@@ -48,6 +48,7 @@ type ProcessIface interface {
 	MountNsInode() (Inode, error)
 	UserNsInode() (Inode, error)
 	UserNsInodeParent() (Inode, error)
+	UserNsInodeAncestors() ([]Inode, error)
 	CreateNsInodes(Inode) error
 	PathAccess(path string, accessFlags AccessMode) error
 	ResolveProcSelf(string) (string, error)
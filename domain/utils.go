@@ -19,6 +19,8 @@ package domain
 import (
 	"os"
 	"syscall"
+
+	"golang.org/x/sys/unix"
 )
 
 // FileExists reports whether the named file or directory exists.
@@ -46,3 +48,22 @@ func FileInode(name string) Inode {
 
 	return st.Ino
 }
+
+// IsCreateOpenFlags reports whether the given open() flags request the creation
+// of a new file-system node (i.e. O_CREAT, O_EXCL or O_TMPFILE). Users are not
+// expected to create files within the procfs / sysfs nodes emulated by
+// sysbox-fs, so these flags must be rejected.
+func IsCreateOpenFlags(flags int) bool {
+
+	if flags&(unix.O_CREAT|unix.O_EXCL) != 0 {
+		return true
+	}
+
+	// O_TMPFILE is defined as (__O_TMPFILE | O_DIRECTORY), so we must match
+	// the full bit-set to avoid tagging regular O_DIRECTORY opens.
+	if flags&unix.O_TMPFILE == unix.O_TMPFILE {
+		return true
+	}
+
+	return false
+}
@@ -81,6 +81,20 @@ func (d *Dir) Lookup(
 
 	path := filepath.Join(d.path, req.Name)
 
+	// Masked directories are displayed as empty ones (as the OCI runtime would
+	// do by mounting an empty tmpfs over them).
+	if domain.IsMaskedPath(d.server.container, d.path) {
+		return nil, fuse.ENOENT
+	}
+
+	if err := d.server.checkPathLimits(path); err != nil {
+		return nil, err
+	}
+
+	if err := d.server.authorize(req.Pid, path, domain.HandlerOpLookup); err != nil {
+		return nil, err
+	}
+
 	//
 	// nodeDB caches the attributes associated with each file. This way, we perform the
 	// lookup of a given procfs/sysfs dir/file only once, improving performance. This works
@@ -103,11 +117,9 @@ func (d *Dir) Lookup(
 
 		// Identify node type and overwrite uid & gid values.
 		if file, ok := (*node).(*File); ok {
-			file.attr.Uid = uid
-			file.attr.Gid = gid
+			file.attr.Uid, file.attr.Gid = file.mapOwner(uid, gid)
 		} else if dir, ok := (*node).(*Dir); ok {
-			dir.attr.Uid = uid
-			dir.attr.Gid = gid
+			dir.attr.Uid, dir.attr.Gid = dir.mapOwner(uid, gid)
 		}
 
 		return *node, nil
@@ -130,40 +142,47 @@ func (d *Dir) Lookup(
 		Pid:       req.Pid,
 		Uid:       req.Uid,
 		Gid:       req.Gid,
-		Container: d.server.container,
+		Container: d.server.requestContainer(),
 	}
 
 	// Handler execution.
 	info, err := handler.Lookup(ionode, request)
 	if err != nil {
+		d.server.checkContainerAlive(err)
 		return nil, fuse.ENOENT
 	}
 
 	// Extract received file attributes and create a new element within
 	// sysbox file-system.
-	attr := statToAttr(info.Sys().(*syscall.Stat_t))
+	attr := infoToAttr(info)
 
 	// Adjust response to carry the proper dentry-cache-timeout value.
 	resp.EntryValid = time.Duration(DentryCacheTimeout)
 
 	// Override the uid & gid attributes with the root uid & gid in the
-	// requester's user-ns.
+	// requester's user-ns (see mapOwner() for the id-mapped mounts case).
 	uid, gid, err := d.getUsernsRootUid(req.Pid, req.Uid, req.Gid)
 	if err != nil {
 		return nil, err
 	}
-	attr.Uid = uid
-	attr.Gid = gid
 
-	var newNode fs.Node
+	var (
+		newNode fs.Node
+		newFile *File
+	)
 
 	if info.IsDir() {
 		attr.Mode = os.ModeDir | attr.Mode
-		newNode = NewDir(req.Name, path, &attr, d.File.server)
+		newDir := NewDir(req.Name, path, &attr, d.File.server)
+		newNode, newFile = newDir, &newDir.File
 	} else {
-		newNode = NewFile(req.Name, path, &attr, d.File.server)
+		newFile = NewFile(req.Name, path, &attr, d.File.server)
+		newNode = newFile
 	}
 
+	newFile.hostUid, newFile.hostGid = attr.Uid, attr.Gid
+	attr.Uid, attr.Gid = newFile.mapOwner(uid, gid)
+
 	// Insert new fs node into nodeDB.
 	d.server.Lock()
 	d.server.nodeDB[path] = &newNode
@@ -180,11 +199,17 @@ func (d *Dir) Open(
 	req *fuse.OpenRequest,
 	resp *fuse.OpenResponse) (fs.Handle, error) {
 
-	_, err := d.File.Open(ctx, req, resp)
+	h, err := d.File.Open(ctx, req, resp)
 	if err != nil {
 		return nil, err
 	}
 
+	// Directory handles carry no state (entries are collected afresh upon
+	// every ReadDirAll()), so whatever the handler opened is released already.
+	if fh, ok := h.(*fileHandle); ok {
+		fh.Release(ctx, &fuse.ReleaseRequest{Header: req.Header, Dir: true})
+	}
+
 	return d, nil
 }
 
@@ -200,6 +225,10 @@ func (d *Dir) Create(
 
 	path := filepath.Join(d.path, req.Name)
 
+	if err := d.server.checkPathLimits(path); err != nil {
+		return nil, nil, err
+	}
+
 	// New ionode reflecting the path of the element to be created.
 	ionode := d.server.service.ios.NewIOnode(req.Name, path, 0)
 	ionode.SetOpenFlags(int(req.Flags))
@@ -217,7 +246,7 @@ func (d *Dir) Create(
 		Pid:       req.Pid,
 		Uid:       req.Uid,
 		Gid:       req.Gid,
-		Container: d.server.container,
+		Container: d.server.requestContainer(),
 	}
 
 	// Handler execution. 'Open' handler will create new element if requesting
@@ -237,7 +266,7 @@ func (d *Dir) Create(
 	}
 
 	// Extract received file attributes.
-	attr := statToAttr(info.Sys().(*syscall.Stat_t))
+	attr := infoToAttr(info)
 
 	// Adjust response to carry the proper dentry-cache-timeout value.
 	resp.EntryValid = time.Duration(DentryCacheTimeout)
@@ -262,15 +291,31 @@ func (d *Dir) ReadDirAll(ctx context.Context, req *fuse.ReadRequest) ([]fuse.Dir
 
 	logrus.Debugf("Requested ReadDirAll() on directory %v (req ID=%#v)", d.path, uint64(req.ID))
 
+	// Masked directories are displayed as empty ones (see Lookup() above).
+	if domain.IsMaskedPath(d.server.container, d.path) {
+		return nil, nil
+	}
+
+	if err := d.server.checkPathLimits(d.path); err != nil {
+		return nil, err
+	}
+
 	// New ionode reflecting the path of the element to be created.
 	ionode := d.server.service.ios.NewIOnode(d.name, d.path, 0)
 	ionode.SetOpenFlags(int(req.Flags))
 
-	// Lookup the associated handler within handler-DB.
+	// Lookup the associated handler within handler-DB. Directories lacking a
+	// specific handler are listed by the common (passthrough) one, which
+	// reads them from the container's namespaces.
 	handler, ok := d.server.service.hds.LookupHandler(ionode)
 	if !ok {
-		logrus.Errorf("No supported handler for %v resource", d.path)
-		return nil, fmt.Errorf("No supported handler for %v resource", d.path)
+		handler, ok = d.server.service.hds.FindHandler("procSysCommonHandler")
+		if !ok {
+			logrus.Errorf("No supported handler for %v resource", d.path)
+			return nil, fmt.Errorf("No supported handler for %v resource", d.path)
+		}
+		logrus.Debugf("No specific handler for %v resource; listing it through %v handler",
+			d.path, handler.GetName())
 	}
 
 	request := &domain.HandlerRequest{
@@ -278,13 +323,14 @@ func (d *Dir) ReadDirAll(ctx context.Context, req *fuse.ReadRequest) ([]fuse.Dir
 		Pid:       req.Pid,
 		Uid:       req.Uid,
 		Gid:       req.Gid,
-		Container: d.server.container,
+		Container: d.server.requestContainer(),
 	}
 
 	// Handler execution.
 	files, err := handler.ReadDirAll(ionode, request)
 	if err != nil {
 		logrus.Errorf("ReadDirAll() error: %v", err)
+		d.server.checkContainerAlive(err)
 		return nil, fuse.ENOENT
 	}
 
@@ -293,11 +339,8 @@ func (d *Dir) ReadDirAll(ctx context.Context, req *fuse.ReadRequest) ([]fuse.Dir
 		// For ReadDirAll on the sysbox-fs root dir ("/"), we only act
 		// on the subdirs emulated by sysbox-fs (e.g., /proc, /sys).
 		//
-		if d.path == "/" {
-			if node.Name() != "sys" && node.Name() != "proc" &&
-				node.Name() != "testing" {
-				continue
-			}
+		if d.path == "/" && !d.server.service.isRootEntry(node.Name()) {
+			continue
 		}
 
 		elem := fuse.Dirent{Name: node.Name()}
@@ -327,6 +370,122 @@ func (d *Dir) Mkdir(ctx context.Context, req *fuse.MkdirRequest) (fs.Node, error
 	return newDir, nil
 }
 
+//
+// Remove FS operation.
+//
+// Only directory removals (rmdir) are supported, and only for resources that
+// are passed-through to the container's namespaces; the handler rejects
+// removals of emulated dirs (EPERM). File unlinks remain unsupported (ENOSYS).
+//
+func (d *Dir) Remove(ctx context.Context, req *fuse.RemoveRequest) error {
+
+	if !req.Dir {
+		logrus.Debugf("Requested unsupported Remove() operation for entry %v (Req ID=%#v)",
+			req.Name, uint64(req.ID))
+
+		return fuse.ENOSYS
+	}
+
+	logrus.Debugf("Requested Rmdir() operation for entry %v (Req ID=%#v)",
+		req.Name, uint64(req.ID))
+
+	path := filepath.Join(d.path, req.Name)
+
+	if err := d.server.checkPathLimits(path); err != nil {
+		return err
+	}
+
+	// New ionode reflecting the path of the element to be removed.
+	ionode := d.server.service.ios.NewIOnode(req.Name, path, 0)
+
+	// Lookup the associated handler within handler-DB.
+	handler, ok := d.server.service.hds.LookupHandler(ionode)
+	if !ok {
+		logrus.Errorf("No supported handler for %v resource", path)
+		return fmt.Errorf("No supported handler for %v resource", path)
+	}
+
+	request := &domain.HandlerRequest{
+		ID:        uint64(req.ID),
+		Pid:       req.Pid,
+		Uid:       req.Uid,
+		Gid:       req.Gid,
+		Container: d.server.requestContainer(),
+	}
+
+	// Handler execution.
+	if err := handler.Rmdir(ionode, request); err != nil {
+		logrus.Debugf("Rmdir() error: %v", err)
+		d.server.checkContainerAlive(err)
+
+		// Raw errnos (e.g. the handler's default EPERM) must be wrapped to
+		// prevent Bazil-FUSE from turning them into EIO.
+		if errno, ok := err.(syscall.Errno); ok {
+			return IOerror{Code: errno}
+		}
+		return err
+	}
+
+	// Drop the removed node from nodeDB so that a subsequent lookup reflects
+	// the new state of the container's fs.
+	d.server.Lock()
+	delete(d.server.nodeDB, path)
+	d.server.Unlock()
+
+	return nil
+}
+
+//
+// Unsupported FS operations.
+//
+// None of the following operations makes sense within the procfs / sysfs
+// resources emulated by sysbox-fs. We explicitly implement them to return
+// ENOSYS, as otherwise Bazil-FUSE lib would reply with generic errors (e.g.
+// EIO), which most programs treat as fatal rather than as "unsupported".
+//
+
+func (d *Dir) Rename(
+	ctx context.Context,
+	req *fuse.RenameRequest,
+	newDir fs.Node) error {
+
+	logrus.Debugf("Requested unsupported Rename() operation for entry %v (Req ID=%#v)",
+		req.OldName, uint64(req.ID))
+
+	return fuse.ENOSYS
+}
+
+func (d *Dir) Link(
+	ctx context.Context,
+	req *fuse.LinkRequest,
+	old fs.Node) (fs.Node, error) {
+
+	logrus.Debugf("Requested unsupported Link() operation for entry %v (Req ID=%#v)",
+		req.NewName, uint64(req.ID))
+
+	return nil, fuse.ENOSYS
+}
+
+func (d *Dir) Symlink(
+	ctx context.Context,
+	req *fuse.SymlinkRequest) (fs.Node, error) {
+
+	logrus.Debugf("Requested unsupported Symlink() operation for entry %v (Req ID=%#v)",
+		req.NewName, uint64(req.ID))
+
+	return nil, fuse.ENOSYS
+}
+
+func (d *Dir) Mknod(
+	ctx context.Context,
+	req *fuse.MknodRequest) (fs.Node, error) {
+
+	logrus.Debugf("Requested unsupported Mknod() operation for entry %v (Req ID=%#v)",
+		req.Name, uint64(req.ID))
+
+	return nil, fuse.ENOSYS
+}
+
 //
 // Forget FS operation.
 //
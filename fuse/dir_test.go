@@ -0,0 +1,350 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package fuse
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"bazil.org/fuse"
+	"bazil.org/fuse/fs"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/mocks"
+	"github.com/nestybox/sysbox-fs/sysio"
+)
+
+func TestDir_UnsupportedOps(t *testing.T) {
+
+	d := NewDir("sys", "/proc/sys", &fuse.Attr{Mode: os.ModeDir | 0555}, nil)
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		op   func() error
+		want error
+	}{
+		{
+			//
+			// Test-case 1: Rename of an emulated resource (ENOSYS).
+			//
+			name: "1",
+			op: func() error {
+				req := &fuse.RenameRequest{OldName: "kernel", NewName: "kernel2"}
+				return d.Rename(ctx, req, d)
+			},
+			want: fuse.ENOSYS,
+		},
+		{
+			//
+			// Test-case 2: Hard-link creation (ENOSYS).
+			//
+			name: "2",
+			op: func() error {
+				_, err := d.Link(ctx, &fuse.LinkRequest{NewName: "link"}, d)
+				return err
+			},
+			want: fuse.ENOSYS,
+		},
+		{
+			//
+			// Test-case 3: Extended attributes setting (ENOTSUP).
+			//
+			name: "3",
+			op: func() error {
+				req := &fuse.SetxattrRequest{Name: "user.foo", Xattr: []byte("bar")}
+				return d.Setxattr(ctx, req)
+			},
+			want: fuse.ENOTSUP,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.op(); err != tt.want {
+				t.Errorf("unsupported op error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDir_ReadDirAllRootEntries(t *testing.T) {
+
+	ios := sysio.NewIOService(domain.IOMemFileService)
+	hds := &mocks.HandlerServiceIface{}
+	hdlr := &mocks.HandlerIface{}
+
+	fss := NewFuseServerService()
+	fss.Setup("/var/lib/sysboxfs", nil, ios, hds)
+
+	// Extra top-level dir on top of the default ones, minus /testing.
+	fss.SetRootEntries([]string{"sys", "proc", "dev"})
+
+	srv := NewFuseServer("/var/lib/sysboxfs/c1", nil, nil, fss).(*fuseServer)
+	d := NewDir("", "/", &fuse.Attr{Mode: os.ModeDir | 0555}, srv)
+
+	hds.On("CheckPathLimits", mock.Anything).Return(syscall.Errno(0))
+	hds.On("LookupHandler", mock.Anything).Return(hdlr, true)
+	hdlr.On("ReadDirAll", mock.Anything, mock.Anything).Return(
+		[]os.FileInfo{
+			dirInfo{name: "dev"},
+			dirInfo{name: "etc"},
+			dirInfo{name: "proc"},
+			dirInfo{name: "sys"},
+			dirInfo{name: "testing"},
+		}, nil)
+
+	entries, err := d.ReadDirAll(context.Background(), &fuse.ReadRequest{})
+	if err != nil {
+		t.Fatalf("Dir.ReadDirAll() error = %v", err)
+	}
+
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Name)
+	}
+
+	want := []string{"dev", "proc", "sys"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Dir.ReadDirAll() = %v, want %v", got, want)
+	}
+}
+
+func TestDir_Rmdir(t *testing.T) {
+
+	ios := sysio.NewIOService(domain.IOMemFileService)
+
+	tests := []struct {
+		name      string
+		dir       string
+		rmdirErr  error
+		wantErr   error
+		wantCache bool
+	}{
+		{
+			//
+			// Test-case 1: Empty dir served by a passthrough handler. No errors
+			// expected and node evicted from nodeDB.
+			//
+			name:      "1",
+			dir:       "foo",
+			rmdirErr:  nil,
+			wantErr:   nil,
+			wantCache: false,
+		},
+		{
+			//
+			// Test-case 2: Emulated dir (EPERM). Node must be kept in nodeDB.
+			//
+			name:      "2",
+			dir:       "net",
+			rmdirErr:  syscall.EPERM,
+			wantErr:   IOerror{Code: syscall.EPERM},
+			wantCache: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hds := &mocks.HandlerServiceIface{}
+			hdlr := &mocks.HandlerIface{}
+
+			srv := &fuseServer{
+				service: &FuseServerService{ios: ios, hds: hds},
+				nodeDB:  make(map[string]*fs.Node),
+			}
+			d := NewDir("sys", "/proc/sys", &fuse.Attr{Mode: os.ModeDir | 0555}, srv)
+
+			path := "/proc/sys/" + tt.dir
+			var node fs.Node = NewDir(tt.dir, path, &fuse.Attr{Mode: os.ModeDir | 0555}, srv)
+			srv.nodeDB[path] = &node
+
+			hds.On("CheckPathLimits", mock.Anything).Return(syscall.Errno(0))
+			hds.On("LookupHandler", mock.Anything).Return(hdlr, true)
+			hdlr.On("Rmdir", mock.Anything, mock.Anything).Return(tt.rmdirErr)
+
+			err := d.Remove(context.Background(), &fuse.RemoveRequest{Name: tt.dir, Dir: true})
+			if err != tt.wantErr {
+				t.Errorf("Dir.Remove() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if _, ok := srv.nodeDB[path]; ok != tt.wantCache {
+				t.Errorf("Dir.Remove() nodeDB entry present = %v, want %v", ok, tt.wantCache)
+			}
+		})
+	}
+}
+
+// File info of backends providing no (or an unexpected) stat struct.
+type noStatInfo struct {
+	sys interface{}
+}
+
+func (i noStatInfo) Name() string       { return "foo" }
+func (i noStatInfo) Size() int64        { return 4 }
+func (i noStatInfo) Mode() os.FileMode  { return 0644 }
+func (i noStatInfo) ModTime() time.Time { return time.Unix(1000, 0) }
+func (i noStatInfo) IsDir() bool        { return false }
+func (i noStatInfo) Sys() interface{}   { return i.sys }
+
+func TestDir_CreateNoStat(t *testing.T) {
+
+	ios := sysio.NewIOService(domain.IOMemFileService)
+
+	tests := []struct {
+		name string
+		info os.FileInfo
+	}{
+		{
+			//
+			// Test-case 1: Nil Sys() attribute.
+			//
+			name: "1",
+			info: noStatInfo{sys: nil},
+		},
+		{
+			//
+			// Test-case 2: Non-Stat_t Sys() attribute.
+			//
+			name: "2",
+			info: noStatInfo{sys: "foo"},
+		},
+		{
+			//
+			// Test-case 3: Typed nil Stat_t (e.g. nsenter responses lacking
+			// it).
+			//
+			name: "3",
+			info: domain.FileInfo{
+				Fname:    "foo",
+				Fsize:    4,
+				Fmode:    0644,
+				FmodTime: time.Unix(1000, 0),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hds := &mocks.HandlerServiceIface{}
+			hdlr := &mocks.HandlerIface{}
+
+			srv := &fuseServer{
+				service: &FuseServerService{ios: ios, hds: hds},
+				nodeDB:  make(map[string]*fs.Node),
+			}
+			d := NewDir("sys", "/proc/sys", &fuse.Attr{Mode: os.ModeDir | 0555}, srv)
+
+			hds.On("CheckPathLimits", mock.Anything).Return(syscall.Errno(0))
+			hds.On("LookupHandler", mock.Anything).Return(hdlr, true)
+			hdlr.On("Open", mock.Anything, mock.Anything).Return(nil)
+			hdlr.On("Lookup", mock.Anything, mock.Anything).Return(tt.info, nil)
+
+			node, _, err := d.Create(
+				context.Background(),
+				&fuse.CreateRequest{Name: "foo"},
+				&fuse.CreateResponse{})
+			if err != nil {
+				t.Fatalf("Dir.Create() error = %v", err)
+			}
+
+			// Attributes must be the ones of the file info.
+			attr := *node.(*File).attr
+			if attr.Mode != 0644 || attr.Size != 4 || !attr.Mtime.Equal(time.Unix(1000, 0)) {
+				t.Errorf("Dir.Create() attr = %+v, want mode 0644, size 4 and mtime %v",
+					attr, time.Unix(1000, 0))
+			}
+		})
+	}
+}
+
+func TestDir_ReadDirAllFallback(t *testing.T) {
+
+	ios := sysio.NewIOService(domain.IOMemFileService)
+
+	tests := []struct {
+		name      string
+		common    bool
+		wantNames []string
+		wantErr   bool
+	}{
+		{
+			//
+			// Test-case 1: Directory lacking a specific handler. Listing
+			// expected through the common (passthrough) handler.
+			//
+			name:      "1",
+			common:    true,
+			wantNames: []string{"bar", "foo"},
+			wantErr:   false,
+		},
+		{
+			//
+			// Test-case 2: No common handler registered either. Error
+			// expected.
+			//
+			name:      "2",
+			common:    false,
+			wantNames: nil,
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hds := &mocks.HandlerServiceIface{}
+			hdlr := &mocks.HandlerIface{}
+
+			srv := &fuseServer{
+				service: &FuseServerService{ios: ios, hds: hds},
+				nodeDB:  make(map[string]*fs.Node),
+			}
+			d := NewDir("foo", "/proc/foo", &fuse.Attr{Mode: os.ModeDir | 0555}, srv)
+
+			hds.On("CheckPathLimits", mock.Anything).Return(syscall.Errno(0))
+			hds.On("LookupHandler", mock.Anything).Return(nil, false)
+			if tt.common {
+				hds.On("FindHandler", "procSysCommonHandler").Return(hdlr, true)
+			} else {
+				hds.On("FindHandler", "procSysCommonHandler").Return(nil, false)
+			}
+			hdlr.On("GetName").Return("procSysCommon")
+			hdlr.On("ReadDirAll", mock.Anything, mock.Anything).Return(
+				[]os.FileInfo{
+					dirInfo{name: "bar"},
+					dirInfo{name: "foo"},
+				}, nil)
+
+			entries, err := d.ReadDirAll(context.Background(), &fuse.ReadRequest{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Dir.ReadDirAll() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			var got []string
+			for _, e := range entries {
+				got = append(got, e.Name)
+			}
+			if !reflect.DeepEqual(got, tt.wantNames) {
+				t.Errorf("Dir.ReadDirAll() = %v, want %v", got, tt.wantNames)
+			}
+		})
+	}
+}
@@ -44,6 +44,22 @@ type File struct {
 
 	// Pointer to parent fuseService hosting this file/dir.
 	server *fuseServer
+
+	// File ownership as reported by the handler (i.e. as seen from the host).
+	hostUid uint32
+	hostGid uint32
+}
+
+//
+// Handle of an opened File. Every Open() gets a handle of its own, holding the
+// IOnode the handler opened until the handle is released, so the host file
+// stays valid for as long as the handle does, however other opens of the same
+// File overlap with it. Operations not overridden here are the File's ones.
+//
+type fileHandle struct {
+	*File
+	ionode  domain.IOnodeIface
+	handler domain.HandlerIface
 }
 
 //
@@ -86,6 +102,10 @@ func (f *File) Getattr(
 	logrus.Debugf("Requested GetAttr() operation for entry %v (Req ID=%#v)",
 		f.path, uint64(req.ID))
 
+	if err := f.server.checkPathLimits(f.path); err != nil {
+		return err
+	}
+
 	// Use the attributes obtained during Lookup()
 	resp.Attr = *f.attr
 
@@ -95,8 +115,9 @@ func (f *File) Getattr(
 	// from the sys container's one if request is originated from an L2 container.
 	// Also, this will help us to support "unshare -U -m --mount-proc" inside a
 	// sys container.
-	resp.Attr.Uid = f.server.container.UID()
-	resp.Attr.Gid = f.server.container.GID()
+	resp.Attr.Uid, resp.Attr.Gid = f.mapOwner(
+		f.server.container.UID(),
+		f.server.container.GID())
 
 	return nil
 }
@@ -112,6 +133,33 @@ func (f *File) Open(
 	logrus.Debugf("Requested Open() operation for entry %v (Req ID=%#v)",
 		f.path, uint64(req.ID))
 
+	// Ensure that the node type matches the caller's expectations: no
+	// directory can be opened for writing, and O_DIRECTORY is only meaningful
+	// for directories.
+	if f.attr.Mode.IsDir() {
+		if !req.Flags.IsReadOnly() {
+			return nil, fuse.Errno(syscall.EISDIR)
+		}
+	} else if req.Flags&fuse.OpenDirectory != 0 {
+		return nil, fuse.Errno(syscall.ENOTDIR)
+	}
+
+	// Paths declared as read-only in the container's OCI spec can't be opened
+	// for writing, regardless of the capabilities of the requesting process.
+	if !req.Flags.IsReadOnly() && domain.IsRoPath(f.server.container, f.path) {
+		return nil, fuse.Errno(syscall.EROFS)
+	}
+
+	// Masked paths are not backed by any handler (see Read()/Write() below).
+	if domain.IsMaskedPath(f.server.container, f.path) {
+		resp.Flags |= fuse.OpenDirectIO
+		return f, nil
+	}
+
+	if err := f.server.checkPathLimits(f.path); err != nil {
+		return nil, err
+	}
+
 	ionode := f.server.service.ios.NewIOnode(f.name, f.path, f.attr.Mode)
 	ionode.SetOpenFlags(int(req.Flags))
 
@@ -122,18 +170,29 @@ func (f *File) Open(
 		return nil, fmt.Errorf("No supported handler for %v resource", f.path)
 	}
 
+	// Write-only resources can't be opened for reading.
+	if handler.GetWriteOnly() && !req.Flags.IsWriteOnly() {
+		return nil, fuse.Errno(syscall.EACCES)
+	}
+
+	// Read-only resources can't be opened for writing.
+	if handler.GetReadOnly() && !req.Flags.IsReadOnly() {
+		return nil, fuse.Errno(syscall.EROFS)
+	}
+
 	request := &domain.HandlerRequest{
 		ID:        uint64(req.ID),
 		Pid:       req.Pid,
 		Uid:       req.Uid,
 		Gid:       req.Gid,
-		Container: f.server.container,
+		Container: f.server.requestContainer(),
 	}
 
 	// Handler execution.
 	err := handler.Open(ionode, request)
 	if err != nil && err != io.EOF {
 		logrus.Debugf("Open() error: %v", err)
+		f.server.checkContainerAlive(err)
 		return nil, err
 	}
 
@@ -153,7 +212,7 @@ func (f *File) Open(
 	//
 	resp.Flags |= fuse.OpenDirectIO
 
-	return f, nil
+	return &fileHandle{File: f, ionode: ionode, handler: handler}, nil
 }
 
 //
@@ -164,27 +223,27 @@ func (f *File) Release(ctx context.Context, req *fuse.ReleaseRequest) error {
 	logrus.Debugf("Requested Release() operation for entry %v (Req ID=%#v)",
 		f.path, uint64(req.ID))
 
-	//
-	// Upon arrival of incoming fuse requests, sysbox-fs open()s and close()s
-	// the associated file-system node. IOW, upon successful handling of an
-	// open() fuse request, no file-system state (i.e. opened file-descriptor)
-	// will be held in sysbox-fs for opened dentries. Subsequent fuse requests
-	// generated by the same fuse-client process, will re-open the associated
-	// file to carry out the corresponding read/write operation.
-	//
-	// Notice that this approach allows us to handle emulated and non-emulated
-	// fs resources in the same manner. Non-emulated resources are only
-	// reachable through 'nsexec' mechanisms, which relies on the utilization
-	// of different processes to perform a determined i/o operation. In this
-	// scenario, there's no point in open()ing and clos()ing files, as the
-	// process performing the interim action (let's say, an open request) will
-	// die upon completion, which will necessarily end up with the process'
-	// fd-table getting wiped out by kernel upon process' exit().
-	//
-	// That is all to say, that there is no need to do anything with these
-	// release() requests, as the associated inode is already closed by the
-	// time these requests arrive. And that covers both non-emulated ('nsexec')
-	// and emulated nodes.
+	// Only the handles of masked paths (which carry no state) are released
+	// here; handles of the handler-backed files are released through
+	// fileHandle.Release().
+
+	return nil
+}
+
+//
+// Release FS operation over a file opened through a handler: the handler gets
+// to close the IOnode it opened.
+//
+func (h *fileHandle) Release(ctx context.Context, req *fuse.ReleaseRequest) error {
+
+	logrus.Debugf("Requested Release() operation for entry %v (Req ID=%#v)",
+		h.path, uint64(req.ID))
+
+	// Not every handler keeps its IOnode open (e.g. nsenter-backed ones), so
+	// close errors are of no concern to the releasing process.
+	if err := h.handler.Close(h.ionode); err != nil {
+		logrus.Debugf("Release() error: %v", err)
+	}
 
 	return nil
 }
@@ -197,10 +256,46 @@ func (f *File) Read(
 	req *fuse.ReadRequest,
 	resp *fuse.ReadResponse) error {
 
+	return f.read(ctx, nil, req, resp)
+}
+
+//
+// Read FS operation over an opened handle, which is served through the IOnode
+// opened by the handler.
+//
+func (h *fileHandle) Read(
+	ctx context.Context,
+	req *fuse.ReadRequest,
+	resp *fuse.ReadResponse) error {
+
+	return h.File.read(ctx, h.ionode, req, resp)
+}
+
+// read carries out the Read() operation through the passed IOnode, or through
+// a fresh one if none is provided.
+func (f *File) read(
+	ctx context.Context,
+	ionode domain.IOnodeIface,
+	req *fuse.ReadRequest,
+	resp *fuse.ReadResponse) error {
+
 	logrus.Debugf("Requested Read() operation for entry %v (Req ID=%#v)",
 		f.path, uint64(req.ID))
 
-	ionode := f.server.service.ios.NewIOnode(f.name, f.path, f.attr.Mode)
+	// Masked paths are displayed as empty files, in the same way as the OCI
+	// runtime does by bind-mounting /dev/null over them.
+	if domain.IsMaskedPath(f.server.container, f.path) {
+		resp.Data = resp.Data[:0]
+		return nil
+	}
+
+	if err := f.server.checkPathLimits(f.path); err != nil {
+		return err
+	}
+
+	if ionode == nil {
+		ionode = f.server.service.ios.NewIOnode(f.name, f.path, f.attr.Mode)
+	}
 
 	// Adjust receiving buffer to the request's size.
 	resp.Data = resp.Data[:req.Size]
@@ -212,6 +307,14 @@ func (f *File) Read(
 		return fmt.Errorf("No supported handler for %v resource", f.path)
 	}
 
+	if handler.GetWriteOnly() {
+		return fuse.Errno(syscall.EACCES)
+	}
+
+	if err := f.server.authorize(req.Pid, f.path, domain.HandlerOpRead); err != nil {
+		return err
+	}
+
 	request := &domain.HandlerRequest{
 		ID:        uint64(req.ID),
 		Pid:       req.Pid,
@@ -219,13 +322,14 @@ func (f *File) Read(
 		Gid:       req.Gid,
 		Offset:    req.Offset,
 		Data:      resp.Data,
-		Container: f.server.container,
+		Container: f.server.requestContainer(),
 	}
 
 	// Handler execution.
 	n, err := handler.Read(ionode, request)
 	if err != nil && err != io.EOF {
 		logrus.Debugf("Read() error: %v", err)
+		f.server.checkContainerAlive(err)
 		return err
 	}
 
@@ -242,10 +346,54 @@ func (f *File) Write(
 	req *fuse.WriteRequest,
 	resp *fuse.WriteResponse) error {
 
+	return f.write(ctx, nil, req, resp)
+}
+
+//
+// Write FS operation over an opened handle, which is served through the IOnode
+// opened by the handler.
+//
+func (h *fileHandle) Write(
+	ctx context.Context,
+	req *fuse.WriteRequest,
+	resp *fuse.WriteResponse) error {
+
+	return h.File.write(ctx, h.ionode, req, resp)
+}
+
+// write carries out the Write() operation through the passed IOnode, or through
+// a fresh one if none is provided.
+func (f *File) write(
+	ctx context.Context,
+	ionode domain.IOnodeIface,
+	req *fuse.WriteRequest,
+	resp *fuse.WriteResponse) error {
+
 	logrus.Debugf("Requested Write() operation for entry %v (Req ID=%#v)",
 		f.path, uint64(req.ID))
 
-	ionode := f.server.service.ios.NewIOnode(f.name, f.path, f.attr.Mode)
+	// As with /dev/null, writes to masked paths are silently discarded.
+	if domain.IsMaskedPath(f.server.container, f.path) {
+		resp.Size = len(req.Data)
+		return nil
+	}
+
+	// Read-only paths are enforced here as well, as handles opened before the
+	// container's sysctls were made read-only can still be written to.
+	if domain.IsRoPath(f.server.container, f.path) {
+		err := fuse.Errno(syscall.EROFS)
+		f.server.audit(req.Pid, f.path, req.Data, err)
+		return err
+	}
+
+	if err := f.server.checkPathLimits(f.path); err != nil {
+		f.server.audit(req.Pid, f.path, req.Data, err)
+		return err
+	}
+
+	if ionode == nil {
+		ionode = f.server.service.ios.NewIOnode(f.name, f.path, f.attr.Mode)
+	}
 
 	// Lookup the associated handler within handler-DB.
 	handler, ok := f.server.service.hds.LookupHandler(ionode)
@@ -254,22 +402,37 @@ func (f *File) Write(
 		return fmt.Errorf("No supported handler for %v resource", f.path)
 	}
 
+	if handler.GetReadOnly() {
+		err := fuse.Errno(syscall.EROFS)
+		f.server.audit(req.Pid, f.path, req.Data, err)
+		return err
+	}
+
+	if err := f.server.authorize(req.Pid, f.path, domain.HandlerOpWrite); err != nil {
+		f.server.audit(req.Pid, f.path, req.Data, err)
+		return err
+	}
+
 	request := &domain.HandlerRequest{
 		ID:        uint64(req.ID),
 		Pid:       req.Pid,
 		Uid:       req.Uid,
 		Gid:       req.Gid,
 		Data:      req.Data,
-		Container: f.server.container,
+		Container: f.server.requestContainer(),
 	}
 
 	// Handler execution.
 	n, err := handler.Write(ionode, request)
 	if err != nil && err != io.EOF {
 		logrus.Debugf("Write() error: %v", err)
+		f.server.checkContainerAlive(err)
+		f.server.audit(req.Pid, f.path, req.Data, err)
 		return err
 	}
 
+	f.server.audit(req.Pid, f.path, req.Data, nil)
+
 	resp.Size = n
 
 	return nil
@@ -296,6 +459,89 @@ func (f *File) Setattr(
 	return fuse.EPERM
 }
 
+//
+// Fsync FS operation. Nothing to flush in the emulated resources. Notice that
+// by returning ENOSYS the kernel will no longer forward fsync() requests to
+// sysbox-fs, and will report success to the callers instead.
+//
+func (f *File) Fsync(ctx context.Context, req *fuse.FsyncRequest) error {
+
+	logrus.Debugf("Requested unsupported Fsync() operation for entry %v (Req ID=%#v)",
+		f.path, uint64(req.ID))
+
+	return fuse.ENOSYS
+}
+
+//
+// Setxattr / Removexattr FS operations. Extended attributes are not supported
+// by the emulated resources, so we return ENOTSUP as procfs / sysfs would do.
+//
+func (f *File) Setxattr(ctx context.Context, req *fuse.SetxattrRequest) error {
+
+	logrus.Debugf("Requested unsupported Setxattr() operation for entry %v (Req ID=%#v)",
+		f.path, uint64(req.ID))
+
+	return fuse.ENOTSUP
+}
+
+func (f *File) Removexattr(ctx context.Context, req *fuse.RemovexattrRequest) error {
+
+	logrus.Debugf("Requested unsupported Removexattr() operation for entry %v (Req ID=%#v)",
+		f.path, uint64(req.ID))
+
+	return fuse.ENOTSUP
+}
+
+//
+// Lock / LockWait / Unlock FS operations (FUSE Setlk / Setlkw requests).
+// Emulated resources don't need real locking, so advisory locks (fcntl and
+// flock ones) are granted right away and never conflict with each other.
+//
+func (f *File) Lock(ctx context.Context, req *fuse.LockRequest) error {
+
+	logrus.Debugf("Requested no-op Lock() operation for entry %v (Req ID=%#v)",
+		f.path, uint64(req.ID))
+
+	return nil
+}
+
+func (f *File) LockWait(ctx context.Context, req *fuse.LockWaitRequest) error {
+
+	logrus.Debugf("Requested no-op LockWait() operation for entry %v (Req ID=%#v)",
+		f.path, uint64(req.ID))
+
+	return nil
+}
+
+func (f *File) Unlock(ctx context.Context, req *fuse.UnlockRequest) error {
+
+	logrus.Debugf("Requested no-op Unlock() operation for entry %v (Req ID=%#v)",
+		f.path, uint64(req.ID))
+
+	return nil
+}
+
+//
+// QueryLock FS operation (FUSE Getlk request). As locks are never held, the
+// resource is always reported as unlocked.
+//
+func (f *File) QueryLock(
+	ctx context.Context,
+	req *fuse.QueryLockRequest,
+	resp *fuse.QueryLockResponse) error {
+
+	logrus.Debugf("Requested no-op QueryLock() operation for entry %v (Req ID=%#v)",
+		f.path, uint64(req.ID))
+
+	resp.Lock = fuse.FileLock{
+		Start: req.Lock.Start,
+		End:   req.Lock.End,
+		Type:  fuse.LockUnlock,
+	}
+
+	return nil
+}
+
 //
 // Forget FS operation.
 //
@@ -364,6 +610,34 @@ func (f *File) getUsernsRootUid(reqPid, reqUid, reqGid uint32) (uint32, uint32,
 	return cntr.UID(), cntr.GID(), nil
 }
 
+// mapOwner returns the uid and gid to display for the file, given the root uid
+// and gid of the requester's user-ns. Files sitting on id-mapped mounts within
+// the container keep their host ownership, shifted by the container's id-mapping
+// (as the kernel would do), instead of being displayed as owned by the container's
+// root. Requests served as the host's true root (0:0) are left untouched.
+func (f *File) mapOwner(uid, gid uint32) (uint32, uint32) {
+
+	cntr := f.server.container
+	css := f.server.service.css
+
+	if cntr == nil || css == nil || (uid == 0 && gid == 0) {
+		return uid, gid
+	}
+
+	mts := css.MountService()
+	if mts == nil || !mts.IsIDMappedMount(cntr, f.path) {
+		return uid, gid
+	}
+
+	// Ids beyond the container's id-mapping range have no representation
+	// within the container.
+	if f.hostUid >= cntr.UIDSize() || f.hostGid >= cntr.GIDSize() {
+		return uid, gid
+	}
+
+	return cntr.UID() + f.hostUid, cntr.GID() + f.hostGid
+}
+
 //
 // statToAttr helper function to translate FS node-parameters from unix/kernel
 // format to FUSE ones.
@@ -394,3 +668,27 @@ func statToAttr(s *syscall.Stat_t) fuse.Attr {
 
 	return a
 }
+
+//
+// infoToAttr translates the passed file info into FUSE attributes. File-system
+// backends not providing a stat struct (or providing an unexpected type) are
+// tolerated: the attributes are then built out of the file info itself.
+//
+func infoToAttr(info os.FileInfo) fuse.Attr {
+
+	if s, ok := info.Sys().(*syscall.Stat_t); ok && s != nil {
+		return statToAttr(s)
+	}
+
+	logrus.Warnf("No stat info available for %v (%T); using default attributes",
+		info.Name(), info.Sys())
+
+	return fuse.Attr{
+		Size:  uint64(info.Size()),
+		Atime: info.ModTime(),
+		Mtime: info.ModTime(),
+		Ctime: info.ModTime(),
+		Mode:  info.Mode().Perm(),
+		Nlink: 1,
+	}
+}
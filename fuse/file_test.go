@@ -0,0 +1,772 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package fuse
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"bazil.org/fuse"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/mocks"
+	"github.com/nestybox/sysbox-fs/state"
+	"github.com/nestybox/sysbox-fs/sysio"
+)
+
+func TestFile_MaskedAndRoPaths(t *testing.T) {
+
+	// Disable log generation during UT.
+	logrus.SetOutput(ioutil.Discard)
+
+	ios := sysio.NewIOService(domain.IOMemFileService)
+
+	// No handler expectations are set: neither masked nor read-only paths
+	// should reach the handler layer in these scenarios.
+	hds := &mocks.HandlerServiceIface{}
+	hds.On("Audit", mock.Anything).Return()
+
+	css := state.NewContainerStateService()
+	cntr := css.ContainerCreate(
+		"c1",
+		uint32(1001),
+		time.Time{},
+		231072,
+		65535,
+		231072,
+		65535,
+		[]string{"/proc/sys"},
+		[]string{"/proc/kcore", "/proc/acpi"},
+		false,
+		nil)
+
+	srv := &fuseServer{
+		container: cntr,
+		service:   &FuseServerService{ios: ios, hds: hds},
+	}
+	ctx := context.Background()
+
+	// Masked file: empty content, and opened without any handler involvement.
+	f := NewFile("kcore", "/proc/kcore", &fuse.Attr{Mode: 0400}, srv)
+	openResp := &fuse.OpenResponse{}
+	if _, err := f.Open(ctx, &fuse.OpenRequest{Flags: fuse.OpenReadOnly}, openResp); err != nil {
+		t.Fatalf("File.Open() error = %v", err)
+	}
+
+	readResp := &fuse.ReadResponse{Data: make([]byte, 0, 64)}
+	if err := f.Read(ctx, &fuse.ReadRequest{Size: 64}, readResp); err != nil {
+		t.Fatalf("File.Read() error = %v", err)
+	}
+	if len(readResp.Data) != 0 {
+		t.Errorf("File.Read() data = %q, want empty", readResp.Data)
+	}
+
+	// Masked directory: empty listing, and no children.
+	d := NewDir("acpi", "/proc/acpi", &fuse.Attr{Mode: 0555}, srv)
+	dirents, err := d.ReadDirAll(ctx, &fuse.ReadRequest{})
+	if err != nil || len(dirents) != 0 {
+		t.Errorf("Dir.ReadDirAll() = %v, %v; want empty listing", dirents, err)
+	}
+	if _, err := d.Lookup(ctx, &fuse.LookupRequest{Name: "wakeup"},
+		&fuse.LookupResponse{}); err != fuse.ENOENT {
+		t.Errorf("Dir.Lookup() error = %v, want %v", err, fuse.ENOENT)
+	}
+
+	// Read-only path: write access must be rejected (EROFS).
+	f = NewFile("ip_forward", "/proc/sys/net/ipv4/ip_forward", &fuse.Attr{Mode: 0644}, srv)
+	_, err = f.Open(ctx, &fuse.OpenRequest{Flags: fuse.OpenWriteOnly}, &fuse.OpenResponse{})
+	if err != fuse.Errno(syscall.EROFS) {
+		t.Errorf("File.Open() error = %v, want %v", err, fuse.Errno(syscall.EROFS))
+	}
+	writeReq := &fuse.WriteRequest{Data: []byte("1\n")}
+	if err := f.Write(ctx, writeReq, &fuse.WriteResponse{}); err != fuse.Errno(syscall.EROFS) {
+		t.Errorf("File.Write() error = %v, want %v", err, fuse.Errno(syscall.EROFS))
+	}
+
+	// Sysctl read-only mode: same treatment for the whole /proc/sys tree.
+	roCntr := css.ContainerCreate("c2", uint32(2001), time.Time{}, 231072, 65535,
+		231072, 65535, nil, nil, false, nil)
+	roCntr.SetSysctlReadOnly(true)
+
+	srv = &fuseServer{
+		container: roCntr,
+		service:   &FuseServerService{ios: ios, hds: hds},
+	}
+	f = NewFile("somaxconn", "/proc/sys/net/core/somaxconn", &fuse.Attr{Mode: 0644}, srv)
+	if err := f.Write(ctx, writeReq, &fuse.WriteResponse{}); err != fuse.Errno(syscall.EROFS) {
+		t.Errorf("File.Write() error = %v, want %v", err, fuse.Errno(syscall.EROFS))
+	}
+
+	hds.AssertNotCalled(t, "LookupHandler", mock.Anything)
+}
+
+func TestFile_OpenNodeTypeMismatch(t *testing.T) {
+
+	// No handler expectations are set: mismatches must be caught before
+	// reaching the handler layer.
+	srv := &fuseServer{
+		service: &FuseServerService{
+			ios: sysio.NewIOService(domain.IOMemFileService),
+			hds: &mocks.HandlerServiceIface{},
+		},
+	}
+	ctx := context.Background()
+
+	f := NewFile("uptime", "/proc/uptime", &fuse.Attr{Mode: 0444}, srv)
+	d := NewDir("sys", "/proc/sys", &fuse.Attr{Mode: os.ModeDir | 0555}, srv)
+
+	tests := []struct {
+		name string
+		op   func() error
+		want error
+	}{
+		{
+			//
+			// Test-case 1: O_DIRECTORY over a regular file (ENOTDIR).
+			//
+			name: "1",
+			op: func() error {
+				req := &fuse.OpenRequest{Flags: fuse.OpenReadOnly | fuse.OpenDirectory}
+				_, err := f.Open(ctx, req, &fuse.OpenResponse{})
+				return err
+			},
+			want: fuse.Errno(syscall.ENOTDIR),
+		},
+		{
+			//
+			// Test-case 2: Directory opened with write intent (EISDIR).
+			//
+			name: "2",
+			op: func() error {
+				req := &fuse.OpenRequest{Flags: fuse.OpenWriteOnly}
+				_, err := d.Open(ctx, req, &fuse.OpenResponse{})
+				return err
+			},
+			want: fuse.Errno(syscall.EISDIR),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.op(); err != tt.want {
+				t.Errorf("Open() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFile_WriteOnly(t *testing.T) {
+
+	hds := &mocks.HandlerServiceIface{}
+	hdlr := &mocks.HandlerIface{}
+
+	srv := &fuseServer{
+		service: &FuseServerService{
+			ios: sysio.NewIOService(domain.IOMemFileService),
+			hds: hds,
+		},
+	}
+	ctx := context.Background()
+
+	f := NewFile("flush", "/proc/sys/net/ipv4/route/flush", &fuse.Attr{Mode: 0200}, srv)
+
+	hds.On("CheckPathLimits", mock.Anything).Return(syscall.Errno(0))
+	hds.On("LookupHandler", mock.Anything).Return(hdlr, true)
+	hds.On("Authorize", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(
+		syscall.Errno(0))
+	hds.On("Audit", mock.Anything).Return()
+	hdlr.On("GetWriteOnly").Return(true)
+	hdlr.On("GetReadOnly").Return(false)
+
+	// Read access must be rejected (EACCES) without reaching the handler.
+	for _, flags := range []fuse.OpenFlags{fuse.OpenReadOnly, fuse.OpenReadWrite} {
+		_, err := f.Open(ctx, &fuse.OpenRequest{Flags: flags}, &fuse.OpenResponse{})
+		if err != fuse.Errno(syscall.EACCES) {
+			t.Errorf("File.Open(%v) error = %v, want %v", flags, err, fuse.Errno(syscall.EACCES))
+		}
+	}
+
+	readResp := &fuse.ReadResponse{Data: make([]byte, 0, 8)}
+	if err := f.Read(ctx, &fuse.ReadRequest{Size: 8}, readResp); err != fuse.Errno(syscall.EACCES) {
+		t.Errorf("File.Read() error = %v, want %v", err, fuse.Errno(syscall.EACCES))
+	}
+
+	// Write access must reach the handler.
+	hdlr.On("Open", mock.Anything, mock.Anything).Return(nil).Once()
+	hdlr.On("Write", mock.Anything, mock.Anything).Return(2, nil).Once()
+
+	if _, err := f.Open(ctx, &fuse.OpenRequest{Flags: fuse.OpenWriteOnly}, &fuse.OpenResponse{}); err != nil {
+		t.Errorf("File.Open() error = %v", err)
+	}
+
+	writeResp := &fuse.WriteResponse{}
+	if err := f.Write(ctx, &fuse.WriteRequest{Data: []byte("1\n")}, writeResp); err != nil {
+		t.Errorf("File.Write() error = %v", err)
+	}
+	if writeResp.Size != 2 {
+		t.Errorf("File.Write() size = %v, want 2", writeResp.Size)
+	}
+
+	hdlr.AssertExpectations(t)
+}
+
+func TestFile_ReadOnly(t *testing.T) {
+
+	hds := &mocks.HandlerServiceIface{}
+	hdlr := &mocks.HandlerIface{}
+
+	srv := &fuseServer{
+		service: &FuseServerService{
+			ios: sysio.NewIOService(domain.IOMemFileService),
+			hds: hds,
+		},
+	}
+	ctx := context.Background()
+
+	f := NewFile("uptime", "/proc/uptime", &fuse.Attr{Mode: 0444}, srv)
+
+	hds.On("CheckPathLimits", mock.Anything).Return(syscall.Errno(0))
+	hds.On("LookupHandler", mock.Anything).Return(hdlr, true)
+	hds.On("Authorize", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(
+		syscall.Errno(0))
+	hds.On("Audit", mock.Anything).Return()
+	hdlr.On("GetWriteOnly").Return(false)
+	hdlr.On("GetReadOnly").Return(true)
+
+	// Write access must be rejected (EROFS) without reaching the handler.
+	for _, flags := range []fuse.OpenFlags{fuse.OpenWriteOnly, fuse.OpenReadWrite} {
+		_, err := f.Open(ctx, &fuse.OpenRequest{Flags: flags}, &fuse.OpenResponse{})
+		if err != fuse.Errno(syscall.EROFS) {
+			t.Errorf("File.Open(%v) error = %v, want %v", flags, err, fuse.Errno(syscall.EROFS))
+		}
+	}
+
+	writeReq := &fuse.WriteRequest{Data: []byte("1\n")}
+	if err := f.Write(ctx, writeReq, &fuse.WriteResponse{}); err != fuse.Errno(syscall.EROFS) {
+		t.Errorf("File.Write() error = %v, want %v", err, fuse.Errno(syscall.EROFS))
+	}
+
+	// Read access must reach the handler.
+	hdlr.On("Open", mock.Anything, mock.Anything).Return(nil).Once()
+	hdlr.On("Read", mock.Anything, mock.Anything).Return(2, nil).Once()
+
+	if _, err := f.Open(ctx, &fuse.OpenRequest{Flags: fuse.OpenReadOnly}, &fuse.OpenResponse{}); err != nil {
+		t.Errorf("File.Open() error = %v", err)
+	}
+
+	readResp := &fuse.ReadResponse{Data: make([]byte, 0, 8)}
+	if err := f.Read(ctx, &fuse.ReadRequest{Size: 8}, readResp); err != nil {
+		t.Errorf("File.Read() error = %v", err)
+	}
+
+	hdlr.AssertNotCalled(t, "Write", mock.Anything, mock.Anything)
+	hdlr.AssertExpectations(t)
+}
+
+func TestFile_OpenRelease(t *testing.T) {
+
+	// Disable log generation during UT.
+	logrus.SetOutput(ioutil.Discard)
+
+	ios := sysio.NewIOService(domain.IOMemFileService)
+	hds := &mocks.HandlerServiceIface{}
+	hdlr := &mocks.HandlerIface{}
+
+	srv := &fuseServer{
+		service: &FuseServerService{ios: ios, hds: hds},
+	}
+	ctx := context.Background()
+
+	dir := ios.NewIOnode("proc", "/proc", 0755)
+	if err := dir.MkdirAll(); err != nil {
+		t.Fatalf("Could not create %v: %v", dir.Path(), err)
+	}
+	content := "processor\t: 0\n"
+	if err := ios.NewIOnode("cpuinfo", "/proc/cpuinfo", 0444).WriteFile(
+		[]byte(content)); err != nil {
+		t.Fatalf("Could not create /proc/cpuinfo: %v", err)
+	}
+
+	f := NewFile("cpuinfo", "/proc/cpuinfo", &fuse.Attr{Mode: 0444}, srv)
+
+	hds.On("CheckPathLimits", mock.Anything).Return(syscall.Errno(0))
+	hds.On("LookupHandler", mock.Anything).Return(hdlr, true)
+	hds.On("Authorize", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(
+		syscall.Errno(0))
+	hdlr.On("GetWriteOnly").Return(false)
+	hdlr.On("GetReadOnly").Return(true)
+
+	// Handler going straight to the host file through the passed IOnode.
+	var (
+		closed  []domain.IOnodeIface
+		readErr error
+	)
+	hdlr.On("Open", mock.Anything, mock.Anything).Return(
+		func(n domain.IOnodeIface, req *domain.HandlerRequest) error {
+			return n.Open()
+		})
+	hdlr.On("Read", mock.Anything, mock.Anything).Return(
+		func(n domain.IOnodeIface, req *domain.HandlerRequest) int {
+			var len int
+			len, readErr = n.Read(req.Data)
+			return len
+		},
+		func(n domain.IOnodeIface, req *domain.HandlerRequest) error {
+			return readErr
+		})
+	hdlr.On("Close", mock.Anything).Return(
+		func(n domain.IOnodeIface) error {
+			closed = append(closed, n)
+			return n.Close()
+		})
+
+	open := func() *fileHandle {
+		h, err := f.Open(ctx, &fuse.OpenRequest{Flags: fuse.OpenReadOnly}, &fuse.OpenResponse{})
+		if err != nil {
+			t.Fatalf("File.Open() error = %v", err)
+		}
+		return h.(*fileHandle)
+	}
+	read := func(h *fileHandle) (string, error) {
+		resp := &fuse.ReadResponse{Data: make([]byte, 0, 64)}
+		err := h.Read(ctx, &fuse.ReadRequest{Size: 64}, resp)
+		return string(resp.Data), err
+	}
+
+	// Overlapping opens of the same file get a handle (and IOnode) each.
+	h1, h2 := open(), open()
+	if h1.ionode == h2.ionode {
+		t.Fatalf("File.Open() handles share IOnode %v", h1.ionode)
+	}
+
+	if got, err := read(h1); err != nil || got != content {
+		t.Errorf("Handle 1 Read() = %q, %v; want %q", got, err, content)
+	}
+
+	// Releasing the first handle must only close its own IOnode, leaving the
+	// second one readable.
+	if err := h1.Release(ctx, &fuse.ReleaseRequest{}); err != nil {
+		t.Errorf("Handle 1 Release() error = %v", err)
+	}
+	if len(closed) != 1 || closed[0] != h1.ionode {
+		t.Errorf("Release() closed %v, want %v", closed, h1.ionode)
+	}
+
+	if got, err := read(h2); err != nil || got != content {
+		t.Errorf("Handle 2 Read() = %q, %v; want %q", got, err, content)
+	}
+
+	// The second handle's file is closed upon its release.
+	if err := h2.Release(ctx, &fuse.ReleaseRequest{}); err != nil {
+		t.Errorf("Handle 2 Release() error = %v", err)
+	}
+	if len(closed) != 2 || closed[1] != h2.ionode {
+		t.Errorf("Release() closed %v, want %v", closed, h2.ionode)
+	}
+	if _, err := read(h2); err == nil {
+		t.Errorf("Handle 2 Read() after Release() succeeded, want error")
+	}
+}
+
+func TestFile_Authorizer(t *testing.T) {
+
+	hds := &mocks.HandlerServiceIface{}
+	hdlr := &mocks.HandlerIface{}
+
+	srv := &fuseServer{
+		service: &FuseServerService{
+			ios: sysio.NewIOService(domain.IOMemFileService),
+			hds: hds,
+		},
+	}
+	ctx := context.Background()
+
+	f := NewFile("ip_forward", "/proc/sys/net/ipv4/ip_forward", &fuse.Attr{Mode: 0644}, srv)
+
+	// Policy denying all writes (EPERM) and allowing everything else.
+	authorizer := func(
+		cntr domain.ContainerIface,
+		pid uint32,
+		path string,
+		op domain.HandlerOp) syscall.Errno {
+
+		if op == domain.HandlerOpWrite {
+			return syscall.EPERM
+		}
+		return 0
+	}
+
+	hds.On("CheckPathLimits", mock.Anything).Return(syscall.Errno(0))
+	hds.On("LookupHandler", mock.Anything).Return(hdlr, true)
+	hds.On("Authorize", mock.Anything, uint32(1001), f.path, mock.Anything).Return(authorizer)
+	hds.On("Audit", mock.Anything).Return()
+	hdlr.On("GetWriteOnly").Return(false)
+	hdlr.On("GetReadOnly").Return(false)
+	hdlr.On("Read", mock.Anything, mock.Anything).Return(2, nil).Once()
+
+	// Reads must reach the handler.
+	readResp := &fuse.ReadResponse{Data: make([]byte, 0, 8)}
+	readReq := &fuse.ReadRequest{Header: fuse.Header{Pid: 1001}, Size: 8}
+	if err := f.Read(ctx, readReq, readResp); err != nil {
+		t.Errorf("File.Read() error = %v", err)
+	}
+	if len(readResp.Data) != 2 {
+		t.Errorf("File.Read() size = %v, want 2", len(readResp.Data))
+	}
+
+	// Writes must be rejected without reaching the handler.
+	writeReq := &fuse.WriteRequest{Header: fuse.Header{Pid: 1001}, Data: []byte("1\n")}
+	if err := f.Write(ctx, writeReq, &fuse.WriteResponse{}); err != fuse.Errno(syscall.EPERM) {
+		t.Errorf("File.Write() error = %v, want %v", err, fuse.Errno(syscall.EPERM))
+	}
+
+	hdlr.AssertNotCalled(t, "Write", mock.Anything, mock.Anything)
+	hdlr.AssertExpectations(t)
+}
+
+func TestFile_Audit(t *testing.T) {
+
+	hds := &mocks.HandlerServiceIface{}
+	hdlr := &mocks.HandlerIface{}
+
+	cntr := state.NewContainerStateService().ContainerCreate(
+		"c1",
+		uint32(1001),
+		time.Time{},
+		231072,
+		65536,
+		231072,
+		65536,
+		nil,
+		nil,
+		false,
+		nil)
+
+	srv := &fuseServer{
+		container: cntr,
+		service: &FuseServerService{
+			ios: sysio.NewIOService(domain.IOMemFileService),
+			hds: hds,
+		},
+	}
+	ctx := context.Background()
+
+	f := NewFile("ip_forward", "/proc/sys/net/ipv4/ip_forward", &fuse.Attr{Mode: 0644}, srv)
+
+	var recs []domain.HandlerAuditRecord
+
+	hds.On("CheckPathLimits", mock.Anything).Return(syscall.Errno(0))
+	hds.On("LookupHandler", mock.Anything).Return(hdlr, true)
+	hds.On("Authorize", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(
+		syscall.Errno(0))
+	hds.On("Audit", mock.Anything).Run(func(args mock.Arguments) {
+		recs = append(recs, args.Get(0).(domain.HandlerAuditRecord))
+	}).Return()
+	hdlr.On("GetReadOnly").Return(false)
+	hdlr.On("Write", mock.Anything, mock.Anything).Return(2, nil).Once()
+	hdlr.On("Write", mock.Anything, mock.Anything).Return(0,
+		IOerror{Code: syscall.EINVAL}).Once()
+
+	// Both successful and failed writes must be audited.
+	writeReq := &fuse.WriteRequest{Header: fuse.Header{Pid: 1001}, Data: []byte("1\n")}
+	if err := f.Write(ctx, writeReq, &fuse.WriteResponse{}); err != nil {
+		t.Errorf("File.Write() error = %v", err)
+	}
+	writeReq = &fuse.WriteRequest{Header: fuse.Header{Pid: 1001}, Data: []byte("foo\n")}
+	if err := f.Write(ctx, writeReq, &fuse.WriteResponse{}); err != (IOerror{Code: syscall.EINVAL}) {
+		t.Errorf("File.Write() error = %v, want %v", err, IOerror{Code: syscall.EINVAL})
+	}
+
+	want := []domain.HandlerAuditRecord{
+		{
+			Container: "c1",
+			Pid:       1001,
+			Path:      "/proc/sys/net/ipv4/ip_forward",
+			Value:     "1",
+		},
+		{
+			Container: "c1",
+			Pid:       1001,
+			Path:      "/proc/sys/net/ipv4/ip_forward",
+			Value:     "foo",
+			Err:       IOerror{Code: syscall.EINVAL},
+		},
+	}
+	if !reflect.DeepEqual(recs, want) {
+		t.Errorf("File.Write() audit records = %v, want %v", recs, want)
+	}
+
+	hdlr.AssertExpectations(t)
+}
+
+func TestFile_PathLimits(t *testing.T) {
+
+	// Disable log generation during UT.
+	logrus.SetOutput(ioutil.Discard)
+
+	// Paths exceeding the limits must be rejected before reaching the handler
+	// layer, hence no LookupHandler() / Authorize() expectations are set.
+	hds := &mocks.HandlerServiceIface{}
+	hds.On("CheckPathLimits", mock.Anything).Return(syscall.ENAMETOOLONG)
+	hds.On("Audit", mock.Anything).Return()
+
+	srv := &fuseServer{
+		service: &FuseServerService{
+			ios: sysio.NewIOService(domain.IOMemFileService),
+			hds: hds,
+		},
+	}
+	ctx := context.Background()
+
+	f := NewFile("c", "/proc/sys/a/b/c", &fuse.Attr{Mode: 0644}, srv)
+	d := NewDir("b", "/proc/sys/a/b", &fuse.Attr{Mode: os.ModeDir | 0555}, srv)
+
+	tests := []struct {
+		name string
+		op   func() error
+	}{
+		{
+			//
+			// Test-case 1: Getattr.
+			//
+			name: "1",
+			op: func() error {
+				return f.Getattr(ctx, &fuse.GetattrRequest{}, &fuse.GetattrResponse{})
+			},
+		},
+		{
+			//
+			// Test-case 2: Open.
+			//
+			name: "2",
+			op: func() error {
+				req := &fuse.OpenRequest{Flags: fuse.OpenReadOnly}
+				_, err := f.Open(ctx, req, &fuse.OpenResponse{})
+				return err
+			},
+		},
+		{
+			//
+			// Test-case 3: Read.
+			//
+			name: "3",
+			op: func() error {
+				resp := &fuse.ReadResponse{Data: make([]byte, 0, 64)}
+				return f.Read(ctx, &fuse.ReadRequest{Size: 64}, resp)
+			},
+		},
+		{
+			//
+			// Test-case 4: Write.
+			//
+			name: "4",
+			op: func() error {
+				req := &fuse.WriteRequest{Data: []byte("1\n")}
+				return f.Write(ctx, req, &fuse.WriteResponse{})
+			},
+		},
+		{
+			//
+			// Test-case 5: Lookup.
+			//
+			name: "5",
+			op: func() error {
+				req := &fuse.LookupRequest{Name: "c"}
+				_, err := d.Lookup(ctx, req, &fuse.LookupResponse{})
+				return err
+			},
+		},
+		{
+			//
+			// Test-case 6: ReadDirAll.
+			//
+			name: "6",
+			op: func() error {
+				_, err := d.ReadDirAll(ctx, &fuse.ReadRequest{})
+				return err
+			},
+		},
+		{
+			//
+			// Test-case 7: Rmdir.
+			//
+			name: "7",
+			op: func() error {
+				return d.Remove(ctx, &fuse.RemoveRequest{Name: "c", Dir: true})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.op(); err != fuse.Errno(syscall.ENAMETOOLONG) {
+				t.Errorf("error = %v, want %v", err, fuse.Errno(syscall.ENAMETOOLONG))
+			}
+		})
+	}
+
+	hds.AssertNotCalled(t, "LookupHandler", mock.Anything)
+	hds.AssertNotCalled(t, "Authorize", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestFile_GetattrIDMappedMount(t *testing.T) {
+
+	mts := &mocks.MountServiceIface{}
+	css := &mocks.ContainerStateServiceIface{}
+	css.On("MountService").Return(mts)
+
+	hds := &mocks.HandlerServiceIface{}
+	hds.On("CheckPathLimits", mock.Anything).Return(syscall.Errno(0))
+
+	cntr := state.NewContainerStateService().ContainerCreate(
+		"c1",
+		uint32(1001),
+		time.Time{},
+		231072,
+		65536,
+		231072,
+		65536,
+		nil,
+		nil,
+		false,
+		nil)
+
+	srv := &fuseServer{
+		container: cntr,
+		service:   &FuseServerService{css: css, hds: hds},
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		idMapped bool
+		hostUid  uint32
+		hostGid  uint32
+		wantUid  uint32
+		wantGid  uint32
+	}{
+		{
+			//
+			// Test-case 1: No id-mapped mount. Container's root ownership
+			// displayed.
+			//
+			name:     "1",
+			path:     "/proc/sys/kernel/panic",
+			idMapped: false,
+			hostUid:  1000,
+			hostGid:  1000,
+			wantUid:  231072,
+			wantGid:  231072,
+		},
+		{
+			//
+			// Test-case 2: Id-mapped mount. Host ownership shifted by the
+			// container's id-mapping.
+			//
+			name:     "2",
+			path:     "/proc/sys/kernel/panic_on_oops",
+			idMapped: true,
+			hostUid:  1000,
+			hostGid:  1001,
+			wantUid:  232072,
+			wantGid:  232073,
+		},
+		{
+			//
+			// Test-case 3: Id-mapped mount with host ownership beyond the
+			// container's id-mapping range. Container's root ownership
+			// displayed.
+			//
+			name:     "3",
+			path:     "/proc/sys/kernel/sysrq",
+			idMapped: true,
+			hostUid:  70000,
+			hostGid:  0,
+			wantUid:  231072,
+			wantGid:  231072,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mts.On("IsIDMappedMount", cntr, tt.path).Return(tt.idMapped)
+
+			f := NewFile("", tt.path, &fuse.Attr{Mode: 0644}, srv)
+			f.hostUid, f.hostGid = tt.hostUid, tt.hostGid
+
+			resp := &fuse.GetattrResponse{}
+			if err := f.Getattr(context.Background(), &fuse.GetattrRequest{}, resp); err != nil {
+				t.Fatalf("File.Getattr() error = %v", err)
+			}
+			if resp.Attr.Uid != tt.wantUid || resp.Attr.Gid != tt.wantGid {
+				t.Errorf("File.Getattr() ownership = %v:%v, want %v:%v",
+					resp.Attr.Uid, resp.Attr.Gid, tt.wantUid, tt.wantGid)
+			}
+		})
+	}
+}
+
+func TestFile_Locks(t *testing.T) {
+
+	// Disable log generation during UT.
+	logrus.SetOutput(ioutil.Discard)
+
+	hds := &mocks.HandlerServiceIface{}
+
+	srv := &fuseServer{
+		service: &FuseServerService{
+			ios: sysio.NewIOService(domain.IOMemFileService),
+			hds: hds,
+		},
+	}
+	ctx := context.Background()
+
+	f := NewFile("somaxconn", "/proc/sys/net/core/somaxconn", &fuse.Attr{Mode: 0644}, srv)
+
+	lock := fuse.FileLock{Start: 0, End: 0x7fffffffffffffff, Type: fuse.LockWrite, PID: 1001}
+
+	// Lock acquisition must always succeed.
+	if err := f.Lock(ctx, &fuse.LockRequest{Lock: lock}); err != nil {
+		t.Errorf("File.Lock() error = %v", err)
+	}
+	if err := f.LockWait(ctx, &fuse.LockWaitRequest{Lock: lock}); err != nil {
+		t.Errorf("File.LockWait() error = %v", err)
+	}
+
+	// Resource must be reported as unlocked, even while "holding" the lock.
+	resp := &fuse.QueryLockResponse{}
+	if err := f.QueryLock(ctx, &fuse.QueryLockRequest{Lock: lock}, resp); err != nil {
+		t.Errorf("File.QueryLock() error = %v", err)
+	}
+	if resp.Lock.Type != fuse.LockUnlock {
+		t.Errorf("File.QueryLock() lock type = %v, want %v", resp.Lock.Type, fuse.LockUnlock)
+	}
+
+	if err := f.Unlock(ctx, &fuse.UnlockRequest{Lock: lock}); err != nil {
+		t.Errorf("File.Unlock() error = %v", err)
+	}
+
+	// Handlers must not be involved.
+	hds.AssertNotCalled(t, "LookupHandler", mock.Anything)
+}
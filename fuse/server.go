@@ -18,7 +18,10 @@ package fuse
 
 import (
 	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"syscall"
 
@@ -34,85 +37,310 @@ import (
 // FuseServer class in charge of running/hosting sysbox-fs' FUSE server features.
 type fuseServer struct {
 	sync.RWMutex                       // nodeDB protection
-	path         string                // fs path to emulate -- "/" by default
-	mountPoint   string                // mountpoint -- "/var/lib/sysboxfs" by default
+	mountPoint   string                // base mountpoint -- "/var/lib/sysboxfs/<cntr-id>" by default
+	mounts       []*fuseMount          // fuse mounts served on behalf of the sys container
 	container    domain.ContainerIface // associated sys container
-	server       *fs.Server            // bazil-fuse server instance
 	nodeDB       map[string]*fs.Node   // map to store all fs nodes, e.g. "/proc/uptime" -> File
-	root         *Dir                  // root node of fuse fs -- "/" by default
-	initDone     chan bool             // sync-up channel to alert about fuse-server's init-completion
+	initDone     chan error            // sync-up channel to alert about fuse-server's init-completion
+	deadOnce     sync.Once             // ensures a departed container is only reaped once
 	service      *FuseServerService    // backpointer to parent service
 }
 
+// fuseMount represents each of the FUSE connections served by a fuse-server.
+// All the mounts of a given fuse-server share the same nodeDB, and thereby, the
+// same handlers and caches.
+type fuseMount struct {
+	path       string      // fs path to emulate -- "/" by default
+	mountPoint string      // mountpoint of this fs path
+	root       *Dir        // root node of this fuse mount
+	conn       *fuse.Conn  // bazil-fuse connection
+	server     *fs.Server  // bazil-fuse server instance
+	srv        *fuseServer // backpointer to parent fuse-server
+}
+
 func NewFuseServer(
-	path string,
 	mountpoint string,
+	mounts []MountSpec,
 	container domain.ContainerIface,
 	service *FuseServerService) domain.FuseServerIface {
 
 	srv := &fuseServer{
-		path:       path,
 		mountPoint: mountpoint,
 		container:  container,
 		service:    service,
 	}
 
+	for _, m := range mounts {
+		srv.mounts = append(srv.mounts, &fuseMount{
+			path:       m.Path,
+			mountPoint: filepath.Join(mountpoint, m.Subdir),
+			srv:        srv,
+		})
+	}
+
 	return srv
 }
 
 func (s *fuseServer) Create() error {
 
+	if len(s.mounts) == 0 {
+		logrus.Errorf("No File-System paths to serve at mountpoint %v", s.mountPoint)
+		return errors.New("No File-System paths to serve")
+	}
+
+	for _, m := range s.mounts {
+		if err := m.create(); err != nil {
+			return err
+		}
+	}
+
+	// Initialize pending members.
+	s.nodeDB = make(map[string]*fs.Node)
+	s.initDone = make(chan error, 1)
+
+	return nil
+}
+
+func (s *fuseServer) Run() error {
+
+	// Create all the FUSE mounts before alerting the caller, as the sys
+	// container's registration can't proceed till all of them are ready. Should
+	// any of them fail, the ones already created are torn down.
+	for i, m := range s.mounts {
+		if err := m.mount(); err != nil {
+			for _, pm := range s.mounts[:i] {
+				fuse.Unmount(pm.mountPoint)
+				pm.conn.Close()
+			}
+			s.initDone <- err
+			return err
+		}
+	}
+
+	// Deferred routine to enforce a clean exit should an unrecoverable error is
+	// ever returned from fuse-lib.
+	defer func() {
+		s.Unmount()
+		for _, m := range s.mounts {
+			m.conn.Close()
+		}
+	}()
+
+	// At this point we are done with fuse-server initialization, so let's
+	// caller know about it.
+	s.initDone <- nil
+
+	// Launch each fuse-mount's main-loop to handle incoming requests, and wait
+	// till all of them are done. Should any of them fail, we return right away:
+	// the deferred routine tears all the mounts down, which also brings the
+	// remaining main-loops to an end.
+	errCh := make(chan error, len(s.mounts))
+
+	for _, m := range s.mounts {
+		go func(m *fuseMount) {
+			errCh <- m.serve()
+		}(m)
+	}
+
+	for range s.mounts {
+		if err := <-errCh; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *fuseServer) Destroy() error {
+
+	// Unmount sysboxfs from all its mountpoints, even if some of them fail to
+	// be unmounted.
+	var errs []string
+	for _, m := range s.mounts {
+		err := fuse.Unmount(m.mountPoint)
+		if err != nil {
+			logrus.Errorf("FUSE file-system could not be unmounted from %v: %v",
+				m.mountPoint, err)
+			errs = append(errs, fmt.Sprintf("%v: %v", m.mountPoint, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("FUSE file-system could not be unmounted: %v",
+			strings.Join(errs, "; "))
+	}
+
+	// Unset pointers for GC purposes.
+	s.container = nil
+	for _, m := range s.mounts {
+		m.server = nil
+		m.root = nil
+	}
+	s.service = nil
+
+	return nil
+}
+
+// Ensure that fuse-server initialization is completed before moving on
+// with sys container's pre-registration sequence. Returns the error (if any)
+// preventing the fuse-server from coming up.
+func (s *fuseServer) InitWait() error {
+	return <-s.initDone
+}
+
+func (s *fuseServer) MountPoint() string {
+
+	return s.mountPoint
+}
+
+func (s *fuseServer) Unmount() {
+
+	for _, m := range s.mounts {
+		fuse.Unmount(m.mountPoint)
+	}
+}
+
+// Inspects the errors obtained while serving fuse requests to detect
+// containers that have gone away without being unregistered (ESRCH errors with
+// no init process left behind). The state of such containers is released
+// asynchronously, as their unregistration tears down this very fuse-server.
+func (s *fuseServer) checkContainerAlive(err error) {
+
+	if s.container == nil || s.service.css == nil {
+		return
+	}
+
+	var errno syscall.Errno
+
+	switch v := err.(type) {
+	case IOerror:
+		errno = v.Code
+	case *IOerror:
+		errno = v.Code
+	case syscall.Errno:
+		errno = v
+	default:
+		return
+	}
+
+	if errno != syscall.ESRCH {
+		return
+	}
+
+	initPid := s.container.InitPid()
+	if initPid == 0 || syscall.Kill(int(initPid), 0) != syscall.ESRCH {
+		return
+	}
+
+	s.deadOnce.Do(func() {
+		go s.service.css.ContainerMarkDead(s.container)
+	})
+}
+
+// Returns the sys container on whose behalf requests are served, once validated
+// (see ContainerIface.Validate()), so that no state cached for the container is
+// served after its init pid has been reused.
+func (s *fuseServer) requestContainer() domain.ContainerIface {
+
+	if s.container != nil {
+		s.container.Validate()
+	}
+
+	return s.container
+}
+
+// Consults the handler-service's authorizer (if any) on the given operation,
+// so that operator policies are enforced uniformly regardless of the handler
+// serving the resource.
+func (s *fuseServer) authorize(pid uint32, path string, op domain.HandlerOp) error {
+
+	if errno := s.service.hds.Authorize(s.container, pid, path, op); errno != 0 {
+		logrus.Debugf("Operation %v on %v denied by authorizer (pid %v): %v",
+			op, path, pid, errno)
+		return fuse.Errno(errno)
+	}
+
+	return nil
+}
+
+// Rejects paths exceeding the handler-service's length / depth limits, before
+// any handler (and hence nsenter) work is carried out on their behalf.
+func (s *fuseServer) checkPathLimits(path string) error {
+
+	if errno := s.service.hds.CheckPathLimits(path); errno != 0 {
+		logrus.Debugf("Operation on %v rejected: path exceeds limits", path)
+		return fuse.Errno(errno)
+	}
+
+	return nil
+}
+
+// Hands the outcome of a write operation over to the handler-service's auditor
+// (if any).
+func (s *fuseServer) audit(pid uint32, path string, data []byte, err error) {
+
+	var cntrID string
+	if s.container != nil {
+		cntrID = s.container.ID()
+	}
+
+	s.service.hds.Audit(domain.HandlerAuditRecord{
+		Container: cntrID,
+		Pid:       pid,
+		Path:      path,
+		Value:     strings.TrimSpace(string(data)),
+		Err:       err,
+	})
+}
+
+func (m *fuseMount) create() error {
+
 	// Verify the existence of the requested path in the host FS.
-	pathIOnode := s.service.ios.NewIOnode(s.path, s.path, os.ModeDir)
+	pathIOnode := m.srv.service.ios.NewIOnode(m.path, m.path, os.ModeDir)
 	pathInfo, err := pathIOnode.Stat()
 	if err != nil {
 		if os.IsNotExist(err) {
-			logrus.Errorf("File-System path not found: %v", s.path)
+			logrus.Errorf("File-System path not found: %v", m.path)
 			return err
 		} else {
-			logrus.Errorf("File-System path not accessible: %v", s.path)
+			logrus.Errorf("File-System path not accessible: %v", m.path)
 			return err
 		}
 	}
 
 	// Verify the existence of the requested mountpoint in the host FS.
-	mountPointIOnode := s.service.ios.NewIOnode(
-		s.mountPoint,
-		s.mountPoint,
+	mountPointIOnode := m.srv.service.ios.NewIOnode(
+		m.mountPoint,
+		m.mountPoint,
 		0600,
 	)
 	_, err = mountPointIOnode.Stat()
 	if err != nil {
 		if os.IsNotExist(err) {
-			logrus.Errorf("File-System mountpoint not found: %v", s.mountPoint)
+			logrus.Errorf("File-System mountpoint not found: %v", m.mountPoint)
 			return err
 		} else {
-			logrus.Errorf("File-System mountpoint not accessible: %v", s.mountPoint)
+			logrus.Errorf("File-System mountpoint not accessible: %v", m.mountPoint)
 			return err
 		}
 	}
 
-	// Creating a first node corresponding to the root (dir) element in
-	// sysbox-fs.
+	// Creating a first node corresponding to the root (dir) element of this
+	// mount.
 	var attr fuse.Attr
-	if s.service.ios.GetServiceType() == domain.IOMemFileService {
+	if m.srv.service.ios.GetServiceType() == domain.IOMemFileService {
 		attr = fuse.Attr{}
 	} else {
-		attr = statToAttr(pathInfo.Sys().(*syscall.Stat_t))
+		attr = infoToAttr(pathInfo)
 	}
 	attr.Mode = os.ModeDir | os.FileMode(int(0600))
 
-	// Build sysbox-fs top-most directory (root).
-	s.root = NewDir(s.path, s.path, &attr, s)
-
-	// Initialize pending members.
-	s.nodeDB = make(map[string]*fs.Node)
-	s.initDone = make(chan bool)
+	// Build the top-most directory of this mount.
+	m.root = NewDir(m.path, m.path, &attr, m.srv)
 
 	return nil
 }
 
-func (s *fuseServer) Run() error {
+func (m *fuseMount) mount() error {
 	//
 	// Creating a FUSE mount at the requested mountpoint.
 	//
@@ -124,48 +352,45 @@ func (s *fuseServer) Run() error {
 	// to sysbox-fs filesystem.
 	//
 	c, err := fuse.Mount(
-		s.mountPoint,
+		m.mountPoint,
 		fuse.FSName("sysboxfs"),
 		fuse.AllowOther(),
 		fuse.DefaultPermissions(),
+		fuse.LockingPOSIX(),
+		fuse.LockingFlock(),
 	)
 	if err != nil {
-		logrus.Fatal(err)
+		logrus.Errorf("FUSE file-system could not be mounted at %v: %v", m.mountPoint, err)
 		return err
 	}
-
-	// Deferred routine to enforce a clean exit should an unrecoverable error is
-	// ever returned from fuse-lib.
-	defer func() {
-		s.Unmount()
-		c.Close()
-	}()
+	m.conn = c
 
 	if p := c.Protocol(); !p.HasInvalidate() {
 		logrus.Panic("Kernel FUSE support is too old to have invalidations: version ", p)
-		return err
+		return errors.New("Kernel FUSE support is too old")
 	}
 
 	// Creating a FUSE server to drive kernel interactions.
-	s.server = fs.New(c, nil)
-	if s.server == nil {
+	m.server = fs.New(c, nil)
+	if m.server == nil {
 		logrus.Panic("FUSE file-system could not be created")
 		return errors.New("FUSE file-system could not be created")
 	}
 
-	// At this point we are done with fuse-server initialization, so let's
-	// caller know about it.
-	s.initDone <- true
+	return nil
+}
 
-	// Launch fuse-server's main-loop to handle incoming requests.
-	if err := s.server.Serve(s); err != nil {
+func (m *fuseMount) serve() error {
+
+	// Launch fuse-mount's main-loop to handle incoming requests.
+	if err := m.server.Serve(m); err != nil {
 		logrus.Panic(err)
 		return err
 	}
 
 	// Return if any error is reported by mount logic.
-	<-c.Ready
-	if err := c.MountError; err != nil {
+	<-m.conn.Ready
+	if err := m.conn.MountError; err != nil {
 		logrus.Panic(err)
 		return err
 	}
@@ -173,45 +398,11 @@ func (s *fuseServer) Run() error {
 	return nil
 }
 
-func (s *fuseServer) Destroy() error {
-
-	// Unmount sysboxfs from mountpoint.
-	err := fuse.Unmount(s.mountPoint)
-	if err != nil {
-		logrus.Errorf("FUSE file-system could not be unmounted: %v", err)
-		return err
-	}
-
-	// Unset pointers for GC purposes.
-	s.container = nil
-	s.server = nil
-	s.root = nil
-	s.service = nil
-
-	return nil
-}
-
 //
-// Root method. This is a Bazil-FUSE-lib requirement. Function returns
-// sysbox-fs' root-node.
+// Root method. This is a Bazil-FUSE-lib requirement. Function returns the
+// root-node of this sysbox-fs mount.
 //
-func (s *fuseServer) Root() (fs.Node, error) {
-
-	return s.root, nil
-}
-
-// Ensure that fuse-server initialization is completed before moving on
-// with sys container's pre-registration sequence.
-func (s *fuseServer) InitWait() {
-	<-s.initDone
-}
-
-func (s *fuseServer) MountPoint() string {
-
-	return s.mountPoint
-}
-
-func (s *fuseServer) Unmount() {
+func (m *fuseMount) Root() (fs.Node, error) {
 
-	fuse.Unmount(s.mountPoint)
+	return m.root, nil
 }
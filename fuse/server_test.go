@@ -0,0 +1,186 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package fuse
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"bazil.org/fuse"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/mocks"
+	"github.com/nestybox/sysbox-fs/sysio"
+)
+
+// Minimal os.FileInfo implementation to feed handler's Lookup() responses.
+type dirInfo struct {
+	name string
+}
+
+func (i dirInfo) Name() string       { return i.name }
+func (i dirInfo) Size() int64        { return 0 }
+func (i dirInfo) Mode() os.FileMode  { return os.ModeDir | 0555 }
+func (i dirInfo) ModTime() time.Time { return time.Time{} }
+func (i dirInfo) IsDir() bool        { return true }
+func (i dirInfo) Sys() interface{} {
+	return &syscall.Stat_t{Mode: syscall.S_IFDIR | 0555}
+}
+
+func TestFuseServer_MultipleMounts(t *testing.T) {
+
+	// Disable log generation during UT.
+	logrus.SetOutput(ioutil.Discard)
+
+	ios := sysio.NewIOService(domain.IOMemFileService)
+	hds := &mocks.HandlerServiceIface{}
+	hdlr := &mocks.HandlerIface{}
+
+	fss := NewFuseServerService()
+	fss.Setup("/var/lib/sysboxfs", nil, ios, hds)
+
+	// Same fs tree exposed at two different mountpoints.
+	mounts := []MountSpec{
+		{Path: "/", Subdir: "a"},
+		{Path: "/", Subdir: "b"},
+	}
+	fss.SetMounts(mounts)
+
+	cntrMountpoint := filepath.Join(fss.mountPoint, "c1")
+	for _, m := range mounts {
+		n := ios.NewIOnode("", filepath.Join(cntrMountpoint, m.Subdir), 0600)
+		if err := n.MkdirAll(); err != nil {
+			t.Fatalf("Could not create mountpoint: %v", err)
+		}
+	}
+
+	srv := NewFuseServer(cntrMountpoint, mounts, nil, fss).(*fuseServer)
+	if err := srv.Create(); err != nil {
+		t.Fatalf("fuseServer.Create() error = %v", err)
+	}
+
+	if len(srv.mounts) != len(mounts) {
+		t.Fatalf("fuseServer.Create() mounts = %v, want %v", len(srv.mounts), len(mounts))
+	}
+
+	// The handler must be resolved only once: the second lookup, arriving
+	// through a different mount, must be served from the shared nodeDB.
+	hds.On("CheckPathLimits", mock.Anything).Return(syscall.Errno(0))
+	hds.On("LookupHandler", mock.Anything).Return(hdlr, true).Once()
+	hds.On("FindUserNsInode", uint32(1001)).Return(domain.Inode(1), nil)
+	hds.On("HostUserNsInode").Return(domain.Inode(1))
+	hds.On("Authorize", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(
+		syscall.Errno(0))
+	hdlr.On("Lookup", mock.Anything, mock.Anything).Return(dirInfo{name: "proc"}, nil).Once()
+
+	var nodes []interface{}
+	for _, m := range srv.mounts {
+		root, err := m.Root()
+		if err != nil {
+			t.Fatalf("fuseMount.Root() error = %v", err)
+		}
+
+		req := &fuse.LookupRequest{
+			Header: fuse.Header{Pid: 1001},
+			Name:   "proc",
+		}
+		node, err := root.(*Dir).Lookup(context.Background(), req, &fuse.LookupResponse{})
+		if err != nil {
+			t.Fatalf("Dir.Lookup() error = %v", err)
+		}
+		nodes = append(nodes, node)
+	}
+
+	if nodes[0] != nodes[1] {
+		t.Errorf("Dir.Lookup() returned different nodes across mounts: %v, %v",
+			nodes[0], nodes[1])
+	}
+
+	hds.AssertNumberOfCalls(t, "LookupHandler", 1)
+	hds.AssertExpectations(t)
+	hdlr.AssertExpectations(t)
+}
+
+func TestParseMounts(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		spec    string
+		want    []MountSpec
+		wantErr bool
+	}{
+		{
+			//
+			// Test-case 1: Multiple mounts.
+			//
+			name: "1",
+			spec: "/proc:proc, /sys:sys/",
+			want: []MountSpec{
+				{Path: "/proc", Subdir: "proc"},
+				{Path: "/sys", Subdir: "sys"},
+			},
+			wantErr: false,
+		},
+		{
+			//
+			// Test-case 2: Missing subdir separator.
+			//
+			name:    "2",
+			spec:    "/proc",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			//
+			// Test-case 3: Subdir escaping the container's base mountpoint.
+			//
+			name:    "3",
+			spec:    "/proc:../proc",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			//
+			// Test-case 4: Relative fs path.
+			//
+			name:    "4",
+			spec:    "proc:proc",
+			want:    nil,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMounts(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseMounts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseMounts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
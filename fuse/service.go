@@ -18,8 +18,10 @@ package fuse
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 
 	_ "bazil.org/fuse/fs/fstestutil"
@@ -28,26 +30,112 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// MountSpec describes each of the FUSE mounts served on behalf of every sys
+// container: the fs path to emulate (e.g. "/", "/proc") and the location of its
+// mountpoint, relative to the container's base mountpoint.
+type MountSpec struct {
+	Path   string
+	Subdir string
+}
+
 type FuseServerService struct {
 	sync.RWMutex                                   // servers map protection
-	path         string                            // fs path to emulate -- "/" by default
+	mounts       []MountSpec                       // fs paths to emulate -- "/" by default
 	mountPoint   string                            // base mountpoint -- "/var/lib/sysboxfs" by default
 	serversMap   map[string]*fuseServer            // tracks created fuse-servers
 	css          domain.ContainerStateServiceIface // containerState service pointer
 	ios          domain.IOServiceIface             // i/o service pointer
 	hds          domain.HandlerServiceIface        // handler service pointer
+	rootEntries  map[string]bool                   // top-level dirs displayed under "/"
 }
 
+// Top-level directories emulated by sysbox-fs, and as such displayed when
+// listing the root ("/") directory.
+var DefaultRootEntries = []string{"sys", "proc", "testing"}
+
 // FuseServerService constructor.
 func NewFuseServerService() *FuseServerService {
 
 	newServerService := &FuseServerService{
+		mounts:     []MountSpec{{Path: "/", Subdir: ""}},
 		serversMap: make(map[string]*fuseServer),
 	}
+	newServerService.SetRootEntries(DefaultRootEntries)
 
 	return newServerService
 }
 
+// SetRootEntries defines the set of top-level directories displayed when
+// listing the root ("/") directory of the emulated fs trees. Entries reported
+// by the root handler and not present in this set are filtered out.
+func (fss *FuseServerService) SetRootEntries(entries []string) {
+
+	rootEntries := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		rootEntries[e] = true
+	}
+
+	fss.Lock()
+	defer fss.Unlock()
+
+	fss.rootEntries = rootEntries
+}
+
+func (fss *FuseServerService) isRootEntry(name string) bool {
+
+	fss.RLock()
+	defer fss.RUnlock()
+
+	return fss.rootEntries[name]
+}
+
+// SetMounts defines the set of FUSE mounts to create for each sys container
+// (e.g. "/proc" and "/sys" trees under separate mountpoints). All of them are
+// served by the same fuse-server, so they share handlers and caches. Must be
+// invoked prior to the creation of any fuse-server.
+func (fss *FuseServerService) SetMounts(mounts []MountSpec) {
+
+	fss.Lock()
+	defer fss.Unlock()
+
+	fss.mounts = mounts
+}
+
+// ParseMounts parses a comma-separated list of "<path>:<subdir>" pairs (e.g.
+// "/proc:proc,/sys:sys") into the corresponding set of mount specs.
+func ParseMounts(s string) ([]MountSpec, error) {
+
+	var mounts []MountSpec
+
+	for _, e := range strings.Split(s, ",") {
+		e = strings.TrimSpace(e)
+		if e == "" {
+			continue
+		}
+
+		// Subdirs must stay within the container's base mountpoint.
+		fields := strings.SplitN(e, ":", 2)
+		if len(fields) != 2 || !filepath.IsAbs(fields[0]) || filepath.IsAbs(fields[1]) {
+			return nil, fmt.Errorf("invalid mount spec %q", e)
+		}
+		subdir := filepath.Clean(fields[1])
+		if subdir == ".." || strings.HasPrefix(subdir, "../") {
+			return nil, fmt.Errorf("invalid mount spec %q", e)
+		}
+
+		mounts = append(mounts, MountSpec{
+			Path:   filepath.Clean(fields[0]),
+			Subdir: subdir,
+		})
+	}
+
+	if len(mounts) == 0 {
+		return nil, errors.New("no mount specs provided")
+	}
+
+	return mounts, nil
+}
+
 func (fss *FuseServerService) Setup(
 	mp string,
 	css domain.ContainerStateServiceIface,
@@ -81,18 +169,22 @@ func (fss *FuseServerService) CreateFuseServer(cntr domain.ContainerIface) error
 			cntrId)
 		return errors.New("FuseServer already present")
 	}
+	mounts := fss.mounts
 	fss.RUnlock()
 
-	// Create required mountpoint in host file-system.
+	// Create required mountpoints in host file-system.
 	cntrMountpoint := filepath.Join(fss.mountPoint, cntrId)
-	mountpointIOnode := fss.ios.NewIOnode("", cntrMountpoint, 0600)
-	if err := mountpointIOnode.MkdirAll(); err != nil {
-		return errors.New("FuseServer with invalid mountpoint")
+	for _, m := range mounts {
+		mp := filepath.Join(cntrMountpoint, m.Subdir)
+		mountpointIOnode := fss.ios.NewIOnode("", mp, 0600)
+		if err := mountpointIOnode.MkdirAll(); err != nil {
+			return errors.New("FuseServer with invalid mountpoint")
+		}
 	}
 
 	srv := NewFuseServer(
-		"/",
 		cntrMountpoint,
+		mounts,
 		cntr,
 		fss,
 	)
@@ -105,7 +197,9 @@ func (fss *FuseServerService) CreateFuseServer(cntr domain.ContainerIface) error
 	// Launch fuse-server in a separate goroutine and wait for 'ack' before
 	// moving on.
 	go srv.Run()
-	srv.InitWait()
+	if err := srv.InitWait(); err != nil {
+		return errors.New("FuseServer initialization error")
+	}
 
 	// Store newly created fuse-server.
 	fss.Lock()
@@ -136,8 +230,18 @@ func (fss *FuseServerService) DestroyFuseServer(cntrId string) error {
 		return nil
 	}
 
-	// Remove mountpoint dir from host file-system.
+	// Remove mountpoint dirs from host file-system.
 	cntrMountpoint := filepath.Join(fss.mountPoint, cntrId)
+	for _, m := range srv.mounts {
+		if m.mountPoint == cntrMountpoint {
+			continue
+		}
+		if err := os.Remove(m.mountPoint); err != nil {
+			logrus.Errorf("FuseServer mountpoint %s could not be eliminated for container id %s",
+				m.mountPoint, cntrId)
+			return nil
+		}
+	}
 	if err := os.Remove(cntrMountpoint); err != nil {
 		logrus.Errorf("FuseServer mountpoint could not be eliminated for container id %s",
 			cntrId)
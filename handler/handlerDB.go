@@ -18,14 +18,22 @@ package handler
 
 import (
 	"errors"
+	"fmt"
+	"math"
 	"os"
 	"path"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/sirupsen/logrus"
 
 	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
 	"github.com/nestybox/sysbox-fs/handler/implementations"
 )
 
@@ -57,6 +65,16 @@ var DefaultHandlers = []domain.HandlerIface{
 			Cacheable: true,
 		},
 	},
+	&implementations.ProcPidStatusHandler{
+		domain.HandlerBase{
+			Name:      "procPidStatus",
+			Path:      "/proc/*/status",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: false,
+			ReadOnly:  true,
+		},
+	},
 	&implementations.ProcCgroupsHandler{
 		domain.HandlerBase{
 			Name:      "procCgroups",
@@ -64,6 +82,7 @@ var DefaultHandlers = []domain.HandlerIface{
 			Type:      domain.NODE_SUBSTITUTION | domain.NODE_BINDMOUNT,
 			Enabled:   true,
 			Cacheable: false,
+			ReadOnly:  true,
 		},
 	},
 	&implementations.ProcCpuinfoHandler{
@@ -73,6 +92,7 @@ var DefaultHandlers = []domain.HandlerIface{
 			Type:      domain.NODE_SUBSTITUTION | domain.NODE_BINDMOUNT,
 			Enabled:   true,
 			Cacheable: true,
+			ReadOnly:  true,
 		},
 	},
 	&implementations.ProcDevicesHandler{
@@ -82,6 +102,7 @@ var DefaultHandlers = []domain.HandlerIface{
 			Type:      domain.NODE_SUBSTITUTION | domain.NODE_BINDMOUNT,
 			Enabled:   true,
 			Cacheable: false,
+			ReadOnly:  true,
 		},
 	},
 	&implementations.ProcDiskstatsHandler{
@@ -91,25 +112,32 @@ var DefaultHandlers = []domain.HandlerIface{
 			Type:      domain.NODE_SUBSTITUTION | domain.NODE_BINDMOUNT,
 			Enabled:   true,
 			Cacheable: false,
+			ReadOnly:  true,
 		},
 	},
-	&implementations.ProcLoadavgHandler{
-		domain.HandlerBase{
+	&implementations.ComputedHandler{
+		HandlerBase: domain.HandlerBase{
 			Name:      "procLoadavg",
 			Path:      "/proc/loadavg",
 			Type:      domain.NODE_SUBSTITUTION | domain.NODE_BINDMOUNT,
 			Enabled:   true,
 			Cacheable: false,
+			ReadOnly:  true,
+			NsKey:     domain.NStypePid,
 		},
+		Compute: implementations.NsFileCompute(domain.NStypePid),
 	},
-	&implementations.ProcMeminfoHandler{
-		domain.HandlerBase{
+	&implementations.ComputedHandler{
+		HandlerBase: domain.HandlerBase{
 			Name:      "procMeminfo",
 			Path:      "/proc/meminfo",
 			Type:      domain.NODE_SUBSTITUTION | domain.NODE_BINDMOUNT,
 			Enabled:   true,
 			Cacheable: false,
+			ReadOnly:  true,
+			NsKey:     domain.NStypePid,
 		},
+		Compute: implementations.NsFileCompute(domain.NStypePid),
 	},
 	&implementations.ProcPagetypeinfoHandler{
 		domain.HandlerBase{
@@ -118,6 +146,7 @@ var DefaultHandlers = []domain.HandlerIface{
 			Type:      domain.NODE_SUBSTITUTION | domain.NODE_BINDMOUNT,
 			Enabled:   true,
 			Cacheable: false,
+			ReadOnly:  true,
 		},
 	},
 	&implementations.ProcPartitionsHandler{
@@ -127,6 +156,7 @@ var DefaultHandlers = []domain.HandlerIface{
 			Type:      domain.NODE_SUBSTITUTION | domain.NODE_BINDMOUNT,
 			Enabled:   true,
 			Cacheable: false,
+			ReadOnly:  true,
 		},
 	},
 	&implementations.ProcStatHandler{
@@ -136,6 +166,7 @@ var DefaultHandlers = []domain.HandlerIface{
 			Type:      domain.NODE_SUBSTITUTION | domain.NODE_BINDMOUNT,
 			Enabled:   true,
 			Cacheable: false,
+			ReadOnly:  true,
 		},
 	},
 	&implementations.ProcSwapsHandler{
@@ -145,16 +176,19 @@ var DefaultHandlers = []domain.HandlerIface{
 			Type:      domain.NODE_SUBSTITUTION | domain.NODE_BINDMOUNT | domain.NODE_PROPAGATE,
 			Enabled:   true,
 			Cacheable: false,
+			ReadOnly:  true,
 		},
 	},
-	&implementations.ProcUptimeHandler{
-		domain.HandlerBase{
+	&implementations.ComputedHandler{
+		HandlerBase: domain.HandlerBase{
 			Name:      "procUptime",
 			Path:      "/proc/uptime",
 			Type:      domain.NODE_SUBSTITUTION | domain.NODE_BINDMOUNT | domain.NODE_PROPAGATE,
 			Enabled:   true,
 			Cacheable: false,
+			ReadOnly:  true,
 		},
+		Compute: implementations.ProcUptime,
 	},
 	&implementations.ProcSysHandler{
 		domain.HandlerBase{
@@ -177,6 +211,41 @@ var DefaultHandlers = []domain.HandlerIface{
 		},
 	},
 	//
+	// /proc/sys/abi handlers
+	//
+	// Compatibility knobs are host-global (and arch-specific, hence the x86-only
+	// vsyscall32 one being the only one emulated so far), so containers can't
+	// modify them by default. Writes can be let through by switching the
+	// handler to the 'passthrough' write mode (see --handler-config).
+	//
+	&implementations.GuardedIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "abiVsyscall32",
+			Path:      "/proc/sys/abi/vsyscall32",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: false,
+		},
+		Min: 0,
+		Max: 1,
+	},
+	//
+	// /proc/sys/dev handlers
+	//
+	// Multi-line resources are passed through (as any other non-emulated one)
+	// but served verbatim.
+	//
+	&implementations.ProcSysCommonHandler{
+		domain.HandlerBase{
+			Name:      "devCdromInfo",
+			Path:      "/proc/sys/dev/cdrom/info",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+			Raw:       true,
+		},
+	},
+	//
 	// /proc/sys/fs handlers
 	//
 	// TODO: use a common dir handler here ...
@@ -196,6 +265,7 @@ var DefaultHandlers = []domain.HandlerIface{
 			Type:      domain.NODE_SUBSTITUTION,
 			Enabled:   true,
 			Cacheable: false,
+			ReadOnly:  true,
 		},
 	},
 	&implementations.FsBinfmtRegisterHandler{
@@ -205,6 +275,16 @@ var DefaultHandlers = []domain.HandlerIface{
 			Type:      domain.NODE_SUBSTITUTION,
 			Enabled:   true,
 			Cacheable: false,
+			ReadOnly:  true,
+		},
+	},
+	&implementations.FsEpollMaxUserWatchesHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "fsEpollMaxUserWatches",
+			Path:      "/proc/sys/fs/epoll/max_user_watches",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
 		},
 	},
 	&implementations.FsProtectHardLinksHandler{
@@ -226,7 +306,17 @@ var DefaultHandlers = []domain.HandlerIface{
 		},
 	},
 	&implementations.MaxIntBaseHandler{
-		domain.HandlerBase{
+		HandlerBase: domain.HandlerBase{
+			Name:      "fsAioMaxNr",
+			Path:      "/proc/sys/fs/aio-max-nr",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+		},
+		MinVal: 1,
+	},
+	&implementations.MaxIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
 			Name:      "fsFileMax",
 			Path:      "/proc/sys/fs/file-max",
 			Type:      domain.NODE_SUBSTITUTION,
@@ -235,7 +325,7 @@ var DefaultHandlers = []domain.HandlerIface{
 		},
 	},
 	&implementations.MaxIntBaseHandler{
-		domain.HandlerBase{
+		HandlerBase: domain.HandlerBase{
 			Name:      "fsNrOpen",
 			Path:      "/proc/sys/fs/nr_open",
 			Type:      domain.NODE_SUBSTITUTION,
@@ -244,6 +334,44 @@ var DefaultHandlers = []domain.HandlerIface{
 		},
 	},
 	//
+	// POSIX message-queue limits. The kernel keeps them per ipc-ns, but the
+	// ipc-ns of the containers are initialized with the kernel defaults and,
+	// prior to v5.19, can't be tuned from within them (the sysctls are owned by
+	// the host's root). Hence, they're emulated as the rest of the host-wide
+	// limits: the host holds the highest value written, and each container
+	// sees its own.
+	//
+	&implementations.MaxIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "fsMqueueMsgMax",
+			Path:      "/proc/sys/fs/mqueue/msg_max",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+		},
+		MinVal: 1,
+	},
+	&implementations.MaxIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "fsMqueueMsgsizeMax",
+			Path:      "/proc/sys/fs/mqueue/msgsize_max",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+		},
+		MinVal: 1,
+	},
+	&implementations.MaxIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "fsMqueueQueuesMax",
+			Path:      "/proc/sys/fs/mqueue/queues_max",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+		},
+		MinVal: 1,
+	},
+	//
 	// /proc/sys/kernel handlers
 	//
 	&implementations.KernelKptrRestrictHandler{
@@ -262,6 +390,7 @@ var DefaultHandlers = []domain.HandlerIface{
 			Type:      domain.NODE_SUBSTITUTION,
 			Enabled:   true,
 			Cacheable: true,
+			ReadOnly:  true,
 		},
 	},
 	&implementations.KernelLastCapHandler{
@@ -271,6 +400,7 @@ var DefaultHandlers = []domain.HandlerIface{
 			Type:      domain.NODE_SUBSTITUTION,
 			Enabled:   true,
 			Cacheable: true,
+			ReadOnly:  true,
 		},
 	},
 	&implementations.KernelPanicHandler{
@@ -291,22 +421,61 @@ var DefaultHandlers = []domain.HandlerIface{
 			Cacheable: true,
 		},
 	},
-	&implementations.KernelPrintkHandler{
-		domain.HandlerBase{
+	//
+	// The four loglevels (console, default message, minimum console and default
+	// console) are system-wide and mutually-exclusive, so changes are only made
+	// at sys-container level. Partial writes (e.g. "echo 8 > printk") only
+	// update the leading loglevels, as the kernel does.
+	//
+	&implementations.TupleIntHandler{
+		HandlerBase: domain.HandlerBase{
 			Name:      "kernelPrintk",
 			Path:      "/proc/sys/kernel/printk",
 			Type:      domain.NODE_SUBSTITUTION,
 			Enabled:   true,
 			Cacheable: true,
 		},
+		Fields:  4,
+		Partial: true,
+		Validators: []implementations.TupleFieldValidator{
+			implementations.TupleFieldRange(math.MinInt32, math.MaxInt32),
+			implementations.TupleFieldRange(math.MinInt32, math.MaxInt32),
+			implementations.TupleFieldRange(math.MinInt32, math.MaxInt32),
+			implementations.TupleFieldRange(math.MinInt32, math.MaxInt32),
+		},
+	},
+	&implementations.KernelRandomEntropyAvailHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "kernelRandomEntropyAvail",
+			Path:      "/proc/sys/kernel/random/entropy_avail",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+			ReadOnly:  true,
+		},
+	},
+	&implementations.KernelRandomPoolsizeHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "kernelRandomPoolsize",
+			Path:      "/proc/sys/kernel/random/poolsize",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+			ReadOnly:  true,
+		},
 	},
+	//
+	// Sysrq can't be modified from within containers by default. It can be
+	// pinned to disabled through the 'enforce-disabled' write mode (see
+	// --handler-config).
+	//
 	&implementations.KernelSysrqHandler{
-		domain.HandlerBase{
+		HandlerBase: domain.HandlerBase{
 			Name:      "kernelSysrq",
 			Path:      "/proc/sys/kernel/sysrq",
 			Type:      domain.NODE_SUBSTITUTION,
 			Enabled:   true,
-			Cacheable: true,
+			Cacheable: false,
 		},
 	},
 	&implementations.KernelYamaPtraceScopeHandler{
@@ -319,7 +488,7 @@ var DefaultHandlers = []domain.HandlerIface{
 		},
 	},
 	&implementations.MaxIntBaseHandler{
-		domain.HandlerBase{
+		HandlerBase: domain.HandlerBase{
 			Name:      "kernelPidMax",
 			Path:      "/proc/sys/kernel/pid_max",
 			Type:      domain.NODE_SUBSTITUTION,
@@ -327,9 +496,113 @@ var DefaultHandlers = []domain.HandlerIface{
 			Cacheable: true,
 		},
 	},
+	&implementations.GuardedIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "kernelWatchdog",
+			Path:      "/proc/sys/kernel/watchdog",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: false,
+		},
+		Min: 0,
+		Max: 1,
+	},
+	&implementations.GuardedIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "kernelNmiWatchdog",
+			Path:      "/proc/sys/kernel/nmi_watchdog",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: false,
+		},
+		Min: 0,
+		Max: 1,
+	},
+	//
+	// Userns creation can't be disabled (nor enabled) from within containers by
+	// default. It can be pinned to enabled through the 'enforce-enabled' write
+	// mode (see --userns-clone-enforce-enabled and --handler-config).
+	//
+	&implementations.GuardedIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "kernelUnprivUsernsClone",
+			Path:      "/proc/sys/kernel/unprivileged_userns_clone",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: false,
+		},
+		Min: 0,
+		Max: 1,
+	},
+	//
+	// Perf access gate: read-only by default. In the 'enforce-max' write mode
+	// (see --handler-config) the host ends at the most restrictive value
+	// requested by any container.
+	//
+	&implementations.GuardedIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "kernelPerfEventParanoid",
+			Path:      "/proc/sys/kernel/perf_event_paranoid",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: false,
+		},
+		Min: -1,
+		Max: 3,
+	},
+	//
+	// Host-wide debugging knob: the last value written by any container
+	// prevails. It can be made read-only through the ReadOnly attribute.
+	//
+	&implementations.MergeBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "kernelHungTaskTimeoutSecs",
+			Path:      "/proc/sys/kernel/hung_task_timeout_secs",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+			ValueType: domain.ValueTypeInt,
+		},
+		Policy: domain.MergePolicyLast,
+		MinVal: 1,
+	},
+	//
+	// One-way latch: once set, modules can't be (un)loaded on the host until
+	// the next reboot. Writes are rejected by default; trusted containers may
+	// be given the ability to set it (0 -> 1 only) through the 'enforce-max'
+	// write mode along with an allow-list (see --handler-config). No container
+	// is trusted unless explicitly listed.
+	//
+	&implementations.GuardedIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "kernelModulesDisabled",
+			Path:      "/proc/sys/kernel/modules_disabled",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: false,
+		},
+		AllowListRequired: true,
+		Min:               0,
+		Max:               1,
+	},
 	//
 	// /proc/sys/net/core handlers
 	//
+	// The JIT setting is host-wide: read-only by default, it can be switched
+	// to the 'enforce-max' write mode (see --handler-config) so that no
+	// container can lower the setting requested by the others.
+	//
+	&implementations.GuardedIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "coreBpfJitEnable",
+			Path:      "/proc/sys/net/core/bpf_jit_enable",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: false,
+		},
+		Min: 0,
+		Max: 2,
+	},
 	&implementations.CoreDefaultQdiscHandler{
 		domain.HandlerBase{
 			Name:      "coreDefaultQdisc",
@@ -339,11 +612,41 @@ var DefaultHandlers = []domain.HandlerIface{
 			Cacheable: true,
 		},
 	},
+	&implementations.MaxIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "coreNetdevMaxBacklog",
+			Path:      "/proc/sys/net/core/netdev_max_backlog",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+		},
+		MinVal: 1,
+	},
+	&implementations.MaxIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "coreRmemDefault",
+			Path:      "/proc/sys/net/core/rmem_default",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+		},
+		MinVal: 1,
+	},
+	&implementations.MaxIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "coreWmemDefault",
+			Path:      "/proc/sys/net/core/wmem_default",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+		},
+		MinVal: 1,
+	},
 	//
 	// /proc/sys/net/netfilter handlers
 	//
 	&implementations.MaxIntBaseHandler{
-		domain.HandlerBase{
+		HandlerBase: domain.HandlerBase{
 			Name:      "nfConntrackMax",
 			Path:      "/proc/sys/net/netfilter/nf_conntrack_max",
 			Type:      domain.NODE_SUBSTITUTION,
@@ -352,7 +655,7 @@ var DefaultHandlers = []domain.HandlerIface{
 		},
 	},
 	&implementations.MaxIntBaseHandler{
-		domain.HandlerBase{
+		HandlerBase: domain.HandlerBase{
 			Name:      "nfConntrackTcpTimeoutEst",
 			Path:      "/proc/sys/net/netfilter/nf_conntrack_tcp_timeout_established",
 			Type:      domain.NODE_SUBSTITUTION,
@@ -361,7 +664,7 @@ var DefaultHandlers = []domain.HandlerIface{
 		},
 	},
 	&implementations.MaxIntBaseHandler{
-		domain.HandlerBase{
+		HandlerBase: domain.HandlerBase{
 			Name:      "nfConntrackTcpTimeoutCWait",
 			Path:      "/proc/sys/net/netfilter/nf_conntrack_tcp_timeout_close_wait",
 			Type:      domain.NODE_SUBSTITUTION,
@@ -370,6 +673,157 @@ var DefaultHandlers = []domain.HandlerIface{
 		},
 	},
 	//
+	// /proc/sys/net/ipv4 handlers
+	//
+	&implementations.NetNsIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "ipv4IcmpEchoIgnoreAll",
+			Path:      "/proc/sys/net/ipv4/icmp_echo_ignore_all",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+		},
+		Min: 0,
+		Max: 1,
+	},
+	&implementations.NetNsIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "ipv4IcmpEchoIgnoreBroadcasts",
+			Path:      "/proc/sys/net/ipv4/icmp_echo_ignore_broadcasts",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+		},
+		Min: 0,
+		Max: 1,
+	},
+	&implementations.Ipv4TcpAvailCongestionControlHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "ipv4TcpAvailCongestionControl",
+			Path:      "/proc/sys/net/ipv4/tcp_available_congestion_control",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+			ReadOnly:  true,
+		},
+	},
+	&implementations.Ipv4TcpCongestionControlHandler{
+		domain.HandlerBase{
+			Name:      "ipv4TcpCongestionControl",
+			Path:      "/proc/sys/net/ipv4/tcp_congestion_control",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+		},
+	},
+	&implementations.NetNsIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "ipv4TcpFastopen",
+			Path:      "/proc/sys/net/ipv4/tcp_fastopen",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+		},
+		Min: 0,
+		Max: 0x3ff,
+	},
+	&implementations.NetNsIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "ipv4TcpFinTimeout",
+			Path:      "/proc/sys/net/ipv4/tcp_fin_timeout",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+		},
+		Min: 1,
+		Max: math.MaxInt32,
+	},
+	&implementations.MaxIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "ipv4TcpMaxSynBacklog",
+			Path:      "/proc/sys/net/ipv4/tcp_max_syn_backlog",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+		},
+		MinVal:      1,
+		MinReadback: true,
+	},
+	//
+	// MTU probing can be disabled (0), enabled upon ICMP black hole detection (1)
+	// or always enabled (2).
+	//
+	&implementations.NetNsIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "ipv4TcpMtuProbing",
+			Path:      "/proc/sys/net/ipv4/tcp_mtu_probing",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+		},
+		Min: 0,
+		Max: 2,
+	},
+	&implementations.NetNsIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "ipv4TcpNoMetricsSave",
+			Path:      "/proc/sys/net/ipv4/tcp_no_metrics_save",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+		},
+		Min: 0,
+		Max: 1,
+	},
+	&implementations.NetNsIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "ipv4TcpSack",
+			Path:      "/proc/sys/net/ipv4/tcp_sack",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+		},
+		Min: 0,
+		Max: 1,
+	},
+	//
+	// Besides enabling (1) timestamps with random per-connection offsets, the
+	// kernel also accepts 2 to enable them without such offsets.
+	//
+	&implementations.NetNsIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "ipv4TcpTimestamps",
+			Path:      "/proc/sys/net/ipv4/tcp_timestamps",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+		},
+		Min: 0,
+		Max: 2,
+	},
+	&implementations.NetNsIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "ipv4TcpTwReuse",
+			Path:      "/proc/sys/net/ipv4/tcp_tw_reuse",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+		},
+		Min: 0,
+		Max: 2,
+	},
+	&implementations.NetNsIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "ipv4TcpWindowScaling",
+			Path:      "/proc/sys/net/ipv4/tcp_window_scaling",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+		},
+		Min: 0,
+		Max: 1,
+	},
+	//
 	// /proc/sys/net/ipv4/vs handlers
 	//
 	&implementations.VsConntrackHandler{
@@ -397,6 +851,7 @@ var DefaultHandlers = []domain.HandlerIface{
 			Type:      domain.NODE_SUBSTITUTION,
 			Enabled:   true,
 			Cacheable: true,
+			ValueType: domain.ValueTypeInt,
 		},
 	},
 	&implementations.VsExpireQuiescentTemplateHandler{
@@ -406,7 +861,52 @@ var DefaultHandlers = []domain.HandlerIface{
 			Type:      domain.NODE_SUBSTITUTION,
 			Enabled:   true,
 			Cacheable: true,
+			ValueType: domain.ValueTypeInt,
+		},
+	},
+	//
+	// /proc/sys/net/ipv4/conf handlers
+	//
+	// Per-interface knobs are registered through their wildcarded path
+	// ("conf/*/<knob>"), which matches the 'all' and 'default' entries as well
+	// as any named interface.
+	//
+	&implementations.NetNsIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "ipv4ConfArpAnnounce",
+			Path:      "/proc/sys/net/ipv4/conf/*/arp_announce",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+		},
+		Min: 0,
+		Max: 2,
+	},
+	&implementations.NetNsIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "ipv4ConfArpIgnore",
+			Path:      "/proc/sys/net/ipv4/conf/*/arp_ignore",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+		},
+		Min: 0,
+		Max: 8,
+	},
+	//
+	// Allows routing of loopback addresses (e.g. relied on by kube-proxy for
+	// NodePort services over localhost).
+	//
+	&implementations.NetNsIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "ipv4ConfRouteLocalnet",
+			Path:      "/proc/sys/net/ipv4/conf/*/route_localnet",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
 		},
+		Min: 0,
+		Max: 1,
 	},
 	//
 	// /proc/sys/net/ipv4/neigh/default handlers
@@ -422,7 +922,7 @@ var DefaultHandlers = []domain.HandlerIface{
 		},
 	},
 	&implementations.MaxIntBaseHandler{
-		domain.HandlerBase{
+		HandlerBase: domain.HandlerBase{
 			Name:      "neighDefaultGcThresh1",
 			Path:      "/proc/sys/net/ipv4/neigh/default/gc_thresh1",
 			Type:      domain.NODE_SUBSTITUTION,
@@ -431,7 +931,7 @@ var DefaultHandlers = []domain.HandlerIface{
 		},
 	},
 	&implementations.MaxIntBaseHandler{
-		domain.HandlerBase{
+		HandlerBase: domain.HandlerBase{
 			Name:      "neighDefaultGcThresh2",
 			Path:      "/proc/sys/net/ipv4/neigh/default/gc_thresh2",
 			Type:      domain.NODE_SUBSTITUTION,
@@ -440,7 +940,7 @@ var DefaultHandlers = []domain.HandlerIface{
 		},
 	},
 	&implementations.MaxIntBaseHandler{
-		domain.HandlerBase{
+		HandlerBase: domain.HandlerBase{
 			Name:      "neighDefaultGcThresh3",
 			Path:      "/proc/sys/net/ipv4/neigh/default/gc_thresh3",
 			Type:      domain.NODE_SUBSTITUTION,
@@ -448,31 +948,196 @@ var DefaultHandlers = []domain.HandlerIface{
 			Cacheable: true,
 		},
 	},
-	//
-	// /proc/sys/net/unix handlers
-	//
-	&implementations.MaxIntBaseHandler{
-		domain.HandlerBase{
-			Name:      "maxDgramQlen",
-			Path:      "/proc/sys/net/unix/max_dgram_qlen",
+	&implementations.NetNsIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "neighDefaultGcStaleTime",
+			Path:      "/proc/sys/net/ipv4/neigh/default/gc_stale_time",
 			Type:      domain.NODE_SUBSTITUTION,
 			Enabled:   true,
 			Cacheable: true,
 		},
+		Min: 1,
+		Max: math.MaxInt32,
 	},
 	//
-	// /proc/sys/vm handlers
+	// /proc/sys/net/ipv4/route handlers
 	//
-	&implementations.VmOvercommitMemHandler{
-		domain.HandlerBase{
-			Name:      "vmOvercommitMem",
-			Path:      "/proc/sys/vm/overcommit_memory",
+	&implementations.NetNsIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "routeFlush",
+			Path:      "/proc/sys/net/ipv4/route/flush",
 			Type:      domain.NODE_SUBSTITUTION,
 			Enabled:   true,
-			Cacheable: true,
+			Cacheable: false,
+			WriteOnly: true,
 		},
+		Min: math.MinInt32,
+		Max: math.MaxInt32,
 	},
-	&implementations.VmMmapMinAddrHandler{
+	&implementations.NetNsIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "routeGcThresh",
+			Path:      "/proc/sys/net/ipv4/route/gc_thresh",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+		},
+		Min: -1,
+		Max: math.MaxInt32,
+	},
+	//
+	// Only exposed by the kernel within the initial net-ns, so its value is a
+	// host-wide one: containers must not shrink each other's routing capacity.
+	//
+	&implementations.MaxIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "routeMaxSize",
+			Path:      "/proc/sys/net/ipv4/route/max_size",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+		},
+		MinVal: 1,
+	},
+	//
+	// /proc/sys/net/unix handlers
+	//
+	&implementations.MaxIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "maxDgramQlen",
+			Path:      "/proc/sys/net/unix/max_dgram_qlen",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+		},
+	},
+	//
+	// /proc/sys/user handlers
+	//
+	// The namespace-count limits are charged against every userns in the
+	// hierarchy, so the effective budget of a container is bounded by the one
+	// of the host's (init) userns. Hence the max semantics: containers can only
+	// raise the host value, and see the one they last wrote.
+	//
+	&implementations.MaxIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "userMaxCgroupNamespaces",
+			Path:      "/proc/sys/user/max_cgroup_namespaces",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+		},
+		MinVal: 1,
+	},
+	&implementations.MaxIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "userMaxIpcNamespaces",
+			Path:      "/proc/sys/user/max_ipc_namespaces",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+		},
+		MinVal: 1,
+	},
+	&implementations.MaxIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "userMaxMntNamespaces",
+			Path:      "/proc/sys/user/max_mnt_namespaces",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+		},
+		MinVal: 1,
+	},
+	&implementations.MaxIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "userMaxNetNamespaces",
+			Path:      "/proc/sys/user/max_net_namespaces",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+		},
+		MinVal: 1,
+	},
+	&implementations.MaxIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "userMaxPidNamespaces",
+			Path:      "/proc/sys/user/max_pid_namespaces",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+		},
+		MinVal: 1,
+	},
+	&implementations.MaxIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "userMaxTimeNamespaces",
+			Path:      "/proc/sys/user/max_time_namespaces",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+		},
+		MinVal: 1,
+	},
+	&implementations.MaxIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "userMaxUserNamespaces",
+			Path:      "/proc/sys/user/max_user_namespaces",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+		},
+		MinVal: 1,
+	},
+	&implementations.MaxIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "userMaxUtsNamespaces",
+			Path:      "/proc/sys/user/max_uts_namespaces",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+		},
+		MinVal: 1,
+	},
+	//
+	// /proc/sys/vm handlers
+	//
+	// Cache drops are served as a no-op by default. The real action can be
+	// granted to (trusted) containers through the 'passthrough' write mode
+	// and an allow-list (see --handler-config).
+	//
+	&implementations.VmDropCachesHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "vmDropCaches",
+			Path:      "/proc/sys/vm/drop_caches",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+		},
+	},
+	// Zero is a legit value here (hugepages release), so only negative values
+	// are rejected. The kernel may allocate fewer pages than requested (e.g.
+	// memory fragmentation), hence the min-readback.
+	&implementations.MaxIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "vmNrHugepages",
+			Path:      "/proc/sys/vm/nr_hugepages",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+		},
+		MinReadback: true,
+	},
+	&implementations.VmOvercommitMemHandler{
+		domain.HandlerBase{
+			Name:      "vmOvercommitMem",
+			Path:      "/proc/sys/vm/overcommit_memory",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+		},
+	},
+	&implementations.VmMmapMinAddrHandler{
 		domain.HandlerBase{
 			Name:      "vmMmapMinAddr",
 			Path:      "/proc/sys/vm/mmap_min_addr",
@@ -493,7 +1158,7 @@ var DefaultHandlers = []domain.HandlerIface{
 		},
 	},
 	&implementations.MaxIntBaseHandler{
-		domain.HandlerBase{
+		HandlerBase: domain.HandlerBase{
 			Name:      "nfConntrackHashSize",
 			Path:      "/sys/module/nf_conntrack/parameters/hashsize",
 			Type:      domain.NODE_SUBSTITUTION | domain.NODE_BINDMOUNT | domain.NODE_PROPAGATE,
@@ -501,6 +1166,48 @@ var DefaultHandlers = []domain.HandlerIface{
 			Cacheable: true,
 		},
 	},
+	&implementations.SysDevicesSystemCpuOnlineHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "sysDevicesSystemCpuOnline",
+			Path:      "/sys/devices/system/cpu/online",
+			Type:      domain.NODE_SUBSTITUTION | domain.NODE_BINDMOUNT | domain.NODE_PROPAGATE,
+			Enabled:   true,
+			Cacheable: false,
+			ReadOnly:  true,
+		},
+	},
+	&implementations.SysFsCgroupBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "sysFsCgroupMemoryLimit",
+			Path:      "/sys/fs/cgroup/memory/memory.limit_in_bytes",
+			Type:      domain.NODE_SUBSTITUTION | domain.NODE_BINDMOUNT | domain.NODE_PROPAGATE,
+			Enabled:   true,
+			Cacheable: false,
+			ValueType: domain.ValueTypeString, // suffixed values (e.g. "1G") allowed
+		},
+		Controller: "memory",
+	},
+	&implementations.SysFsCgroupBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "sysFsCgroupCpuCfsQuota",
+			Path:      "/sys/fs/cgroup/cpu/cpu.cfs_quota_us",
+			Type:      domain.NODE_SUBSTITUTION | domain.NODE_BINDMOUNT | domain.NODE_PROPAGATE,
+			Enabled:   true,
+			Cacheable: false,
+			ValueType: domain.ValueTypeInt,
+		},
+		Controller: "cpu",
+	},
+	&implementations.SysKernelMmThpEnabledHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "sysKernelMmThpEnabled",
+			Path:      "/sys/kernel/mm/transparent_hugepage/enabled",
+			Type:      domain.NODE_SUBSTITUTION | domain.NODE_BINDMOUNT | domain.NODE_PROPAGATE,
+			Enabled:   true,
+			Cacheable: false,
+			ValueType: domain.ValueTypeString,
+		},
+	},
 	//
 	// Testing handler
 	//
@@ -514,6 +1221,41 @@ var DefaultHandlers = []domain.HandlerIface{
 	},
 }
 
+// Default number of LookupHandler() resolutions kept in the lookup cache.
+const defaultLookupCacheSize = 1024
+
+// Bounds of the cache warm-up (see SetCacheWarmup()): maximum number of
+// resources to prefetch per container, and of warm-ups running at any given
+// time.
+const (
+	cacheWarmupMaxPaths = 64
+	cacheWarmupMaxJobs  = 4
+)
+
+// Default bounds of the read-after-write verification of host FS pushes (see
+// SetPushRetries()).
+const (
+	defaultPushRetries = 5
+	defaultPushBackoff = 100 * time.Microsecond
+)
+
+// Default bounds of the paths accepted at the handlers' entry points (see
+// SetPathLimits()).
+const (
+	defaultPathMaxLen   = syscall.PathMax
+	defaultPathMaxDepth = 64
+)
+
+// Number of audit records that can be pending delivery to the auditor before
+// new ones are dropped (see SetAuditor()).
+const auditBufferSize = 1024
+
+// Outcome of a LookupHandler() resolution.
+type lookupResult struct {
+	h  domain.HandlerIface
+	ok bool
+}
+
 type handlerService struct {
 	sync.RWMutex
 
@@ -521,10 +1263,15 @@ type handlerService struct {
 	// object (value).
 	handlerDB map[string]domain.HandlerIface
 
+	// Handlers declared as disabled (either at setup time or through Reload()),
+	// indexed by path. They serve no requests, but can be enabled at runtime.
+	disabledDB map[string]domain.HandlerIface
+
 	// Map to keep track of the resources being emulated and the directory where
 	// these are being placed. Map is indexed by directory path (string), and
 	// the value corresponds to a slice of strings that holds the full path of
-	// the emulated resources seating in each directory.
+	// the emulated resources seating in each directory. Map is kept in sync
+	// with handlerDB as handlers get registered / unregistered.
 	dirHandlerMap map[string][]string
 
 	// Pointer to the service providing container-state storage functionality.
@@ -545,14 +1292,72 @@ type handlerService struct {
 	// Handler i/o errors should be obviated if this flag is enabled (testing
 	// purposes).
 	ignoreErrors bool
+
+	// Set when sysbox-fs is known to be the only instance running on the host,
+	// which allows handlers to skip cross-instance race mitigations.
+	singleInstance bool
+
+	// Quiet period after which the last of a burst of writes of a resource by
+	// the same container is pushed to the host FS. Zero disables coalescing.
+	writeCoalesceWindow time.Duration
+	coalescer           *writeCoalescer
+
+	// Maximum number of attempts to push a merged value to the host FS, and
+	// upper bound of the random delay between attempts.
+	pushRetries int
+	pushBackoff time.Duration
+
+	// Maximum length and number of components of the paths accepted at the
+	// handlers' entry points. Zero disables the respective limit.
+	pathMaxLen   int
+	pathMaxDepth int
+
+	// Set to have /proc/uptime report the host's uptime instead of the
+	// container's one.
+	hostUptime bool
+
+	// Cache of the LookupHandler() resolutions (unsuccessful ones included) of
+	// the most recently accessed paths. The cache is flushed whenever the
+	// handlerDB changes (lookupGen tracks these changes), or once it's full.
+	// A zero size disables caching.
+	lookupLock      sync.Mutex
+	lookupCache     map[string]lookupResult
+	lookupCacheSize int
+	lookupGen       uint64
+
+	// Optional operator-provided policy function consulted ahead of handler
+	// operations (nil allows them all).
+	authorizer domain.HandlerAuthorizer
+
+	// Optional operator-provided function receiving the audit records of write
+	// operations, buffer decoupling its execution from the write path, and
+	// number of records dropped due to this one being full.
+	auditor    domain.HandlerAuditor
+	auditCh    chan domain.HandlerAuditRecord
+	auditDrops uint64
+
+	// Resources to prefetch into the cache of newly registered containers
+	// (empty disables warming), and semaphore bounding the number of warm-ups
+	// in progress.
+	warmupPaths []string
+	warmupSem   chan struct{}
 }
 
 // HandlerService constructor.
 func NewHandlerService() domain.HandlerServiceIface {
 
 	newhs := &handlerService{
-		handlerDB:     make(map[string]domain.HandlerIface),
-		dirHandlerMap: make(map[string][]string),
+		handlerDB:       make(map[string]domain.HandlerIface),
+		disabledDB:      make(map[string]domain.HandlerIface),
+		dirHandlerMap:   make(map[string][]string),
+		lookupCache:     make(map[string]lookupResult),
+		lookupCacheSize: defaultLookupCacheSize,
+		warmupSem:       make(chan struct{}, cacheWarmupMaxJobs),
+		pushRetries:     defaultPushRetries,
+		pushBackoff:     defaultPushBackoff,
+		pathMaxLen:      defaultPathMaxLen,
+		pathMaxDepth:    defaultPathMaxDepth,
+		coalescer:       newWriteCoalescer(),
 	}
 
 	return newhs
@@ -572,17 +1377,18 @@ func (hs *handlerService) Setup(
 	hs.ios = ios
 	hs.ignoreErrors = ignoreErrors
 
-	// Register all handlers declared as 'enabled'.
+	// Register all handlers declared as 'enabled'; keep track of the remaining
+	// ones so that they can be enabled later on (see Reload()).
 	for _, h := range hdlrs {
 		if h.GetEnabled() {
 			hs.RegisterHandler(h)
+			continue
 		}
+		hs.Lock()
+		hs.disabledDB[h.GetPath()] = h
+		hs.Unlock()
 	}
 
-	// Create a directory-handler map to keep track of the association between
-	// emulated resource paths, and the parent directory hosting them.
-	hs.createDirHandlerMap()
-
 	// Obtain user-ns inode corresponding to the host fs (root user-ns).
 	hostUserNsInode, err := hs.FindUserNsInode(uint32(os.Getpid()))
 	if err != nil {
@@ -591,46 +1397,48 @@ func (hs *handlerService) Setup(
 	hs.hostUserNsInode = hostUserNsInode
 }
 
-func (hs *handlerService) createDirHandlerMap() {
-	hs.Lock()
-	defer hs.Unlock()
+// Adds the given handler path to the list of emulated resources hosted by its
+// parent directory. Caller must hold the handler-service lock.
+func (hs *handlerService) dirHandlerMapAdd(hpath string) {
 
-	var dirHandlerMap = hs.dirHandlerMap
-
-	// Iterate through all the registered handlers to populate the dirHandlerMap
-	// structure. Even though this is an O(n^2) logic, notice that 'n' here is
-	// very small (number of handlers), and that this is only executed during
-	// process initialization.
-	for h1, _ := range hs.handlerDB {
-		dir_h1 := path.Dir(h1)
-
-		for h2, _ := range hs.handlerDB {
-			dir_h2 := path.Dir(h2)
-
-			// A potential handler candidate must fully match the dir-path of
-			// the original one.
-			if dir_h1 == dir_h2 {
-				var dup = false
-				dirHandlerMapSlice := dirHandlerMap[dir_h1]
-
-				// Avoid pushing duplicated elements into any given slice.
-				for _, elem := range dirHandlerMapSlice {
-					if elem == h1 {
-						dup = true
-						break
-					}
-				}
-
-				// Proceed to push a new entry if no dups have been encountered.
-				if !dup {
-					dirHandlerMapSlice = append(dirHandlerMapSlice, h1)
-					dirHandlerMap[dir_h1] = dirHandlerMapSlice
-				}
-			}
+	// Per-process resources (wildcarded paths) are not hosted by any actual
+	// directory.
+	if strings.Contains(hpath, "*") {
+		return
+	}
+
+	dir := path.Dir(hpath)
+
+	// Avoid pushing duplicated elements into any given slice.
+	for _, elem := range hs.dirHandlerMap[dir] {
+		if elem == hpath {
+			return
 		}
 	}
 
-	hs.dirHandlerMap = dirHandlerMap
+	hs.dirHandlerMap[dir] = append(hs.dirHandlerMap[dir], hpath)
+}
+
+// Removes the given handler path from the list of emulated resources hosted by
+// its parent directory. Caller must hold the handler-service lock.
+func (hs *handlerService) dirHandlerMapDel(hpath string) {
+
+	dir := path.Dir(hpath)
+	entries := hs.dirHandlerMap[dir]
+
+	for i, elem := range entries {
+		if elem == hpath {
+			entries = append(entries[:i:i], entries[i+1:]...)
+			break
+		}
+	}
+
+	if len(entries) == 0 {
+		delete(hs.dirHandlerMap, dir)
+		return
+	}
+
+	hs.dirHandlerMap[dir] = entries
 }
 
 func (hs *handlerService) RegisterHandler(h domain.HandlerIface) error {
@@ -647,8 +1455,19 @@ func (hs *handlerService) RegisterHandler(h domain.HandlerIface) error {
 
 	h.SetService(hs)
 	hs.handlerDB[path] = h
+	hs.lookupCacheFlush()
+
+	// Keep track of the association between the emulated resource and the
+	// parent directory hosting it, so that it's displayed in dir listings.
+	hs.dirHandlerMapAdd(path)
 	hs.Unlock()
 
+	// Have the container-state service preserve the state emulated by this
+	// handler across sysbox-fs restarts.
+	if h.GetPersistent() && hs.css != nil {
+		hs.css.RegisterPersistentPath(path)
+	}
+
 	return nil
 }
 
@@ -664,7 +1483,9 @@ func (hs *handlerService) UnregisterHandler(h domain.HandlerIface) error {
 		return errors.New("Handler not previously registered")
 	}
 
-	delete(hs.handlerDB, name)
+	delete(hs.handlerDB, path)
+	hs.dirHandlerMapDel(path)
+	hs.lookupCacheFlush()
 	hs.Unlock()
 
 	return nil
@@ -673,6 +1494,35 @@ func (hs *handlerService) UnregisterHandler(h domain.HandlerIface) error {
 func (hs *handlerService) LookupHandler(
 	i domain.IOnodeIface) (domain.HandlerIface, bool) {
 
+	path := i.Path()
+
+	hs.lookupLock.Lock()
+	res, ok := hs.lookupCache[path]
+	gen := hs.lookupGen
+	hs.lookupLock.Unlock()
+
+	if ok {
+		return res.h, res.ok
+	}
+
+	h, ok := hs.lookupHandler(path)
+
+	// Skip caching if the handlerDB has changed in the meantime, as this
+	// resolution may be stale already.
+	hs.lookupLock.Lock()
+	if hs.lookupCacheSize > 0 && gen == hs.lookupGen {
+		if len(hs.lookupCache) >= hs.lookupCacheSize {
+			hs.lookupCache = make(map[string]lookupResult)
+		}
+		hs.lookupCache[path] = lookupResult{h, ok}
+	}
+	hs.lookupLock.Unlock()
+
+	return h, ok
+}
+
+func (hs *handlerService) lookupHandler(p string) (domain.HandlerIface, bool) {
+
 	hs.RLock()
 	defer hs.RUnlock()
 
@@ -680,19 +1530,29 @@ func (hs *handlerService) LookupHandler(
 	// we will find it in the handlerDB. Otherwise, it's handled by one
 	// of the generic handlers.
 
-	h, ok := hs.handlerDB[i.Path()]
+	h, ok := hs.handlerDB[p]
 	if !ok {
-		if strings.HasPrefix(i.Path(), "/proc/sys") {
+		// Per-process resources are registered through their wildcarded path.
+		if h, ok = hs.handlerDB[procPidPattern(p)]; ok {
+			return h, true
+		}
+
+		// Likewise for per-interface network resources.
+		if h, ok = hs.handlerDB[netConfPattern(p)]; ok {
+			return h, true
+		}
+
+		if strings.HasPrefix(p, "/proc/sys") {
 			h, ok = hs.handlerDB["procSysCommonHandler"]
 			if !ok {
 				return nil, false
 			}
-		} else if strings.HasPrefix(i.Path(), "/proc") {
+		} else if strings.HasPrefix(p, "/proc") {
 			h, ok = hs.handlerDB["procHandler"]
 			if !ok {
 				return nil, false
 			}
-		} else if strings.HasPrefix(i.Path(), "/sys") {
+		} else if strings.HasPrefix(p, "/sys") {
 			h, ok = hs.handlerDB["sysHandler"]
 			if !ok {
 				return nil, false
@@ -707,6 +1567,46 @@ func (hs *handlerService) LookupHandler(
 	return h, true
 }
 
+// Flushes the lookup cache. Caller must hold the handler-service lock.
+func (hs *handlerService) lookupCacheFlush() {
+	hs.lookupLock.Lock()
+	defer hs.lookupLock.Unlock()
+
+	hs.lookupCache = make(map[string]lookupResult)
+	hs.lookupGen++
+}
+
+// procPidPattern returns the wildcarded path ("/proc/*/<file>") of the passed
+// per-process resource ("/proc/<pid>/<file>"), or an empty string if the path
+// doesn't refer to a per-process resource.
+func procPidPattern(p string) string {
+
+	elems := strings.SplitN(strings.TrimPrefix(p, "/proc/"), "/", 2)
+	if !strings.HasPrefix(p, "/proc/") || len(elems) != 2 {
+		return ""
+	}
+
+	if _, err := strconv.ParseUint(elems[0], 10, 32); err != nil {
+		return ""
+	}
+
+	return "/proc/*/" + elems[1]
+}
+
+// netConfPattern returns the wildcarded path ("/proc/sys/net/<proto>/conf/*/<knob>")
+// of the passed per-interface resource ("/proc/sys/net/<proto>/conf/<iface>/<knob>"),
+// or an empty string if the path doesn't refer to a per-interface resource.
+func netConfPattern(p string) string {
+
+	elems := strings.Split(strings.TrimPrefix(p, "/proc/sys/net/"), "/")
+	if !strings.HasPrefix(p, "/proc/sys/net/") || len(elems) != 4 || elems[1] != "conf" {
+		return ""
+	}
+	elems[2] = "*"
+
+	return "/proc/sys/net/" + strings.Join(elems, "/")
+}
+
 func (hs *handlerService) FindHandler(s string) (domain.HandlerIface, bool) {
 
 	hs.RLock()
@@ -733,6 +1633,7 @@ func (hs *handlerService) EnableHandler(h domain.HandlerIface) error {
 	}
 
 	h.SetEnabled(true)
+	hs.lookupCacheFlush()
 	hs.Unlock()
 
 	return nil
@@ -751,6 +1652,7 @@ func (hs *handlerService) DisableHandler(h domain.HandlerIface) error {
 	}
 
 	h.SetEnabled(false)
+	hs.lookupCacheFlush()
 	hs.Unlock()
 
 	return nil
@@ -760,7 +1662,41 @@ func (hs *handlerService) DirHandlerEntries(s string) []string {
 	hs.RLock()
 	defer hs.RUnlock()
 
-	return hs.dirHandlerMap[s]
+	// Return a copy to prevent callers from racing with subsequent handler
+	// (un)registrations.
+	entries := hs.dirHandlerMap[s]
+	if len(entries) == 0 {
+		return nil
+	}
+
+	return append([]string(nil), entries...)
+}
+
+// HandlersMetadata returns the attributes and capabilities of all the
+// registered handlers, sorted by path.
+func (hs *handlerService) HandlersMetadata() []domain.HandlerMetadata {
+	hs.RLock()
+	defer hs.RUnlock()
+
+	var metadata = make([]domain.HandlerMetadata, 0, len(hs.handlerDB))
+
+	for _, h := range hs.handlerDB {
+		metadata = append(metadata, domain.HandlerMetadata{
+			Name:        h.GetName(),
+			Path:        h.GetPath(),
+			Type:        h.GetType(),
+			Enabled:     h.GetEnabled(),
+			Cacheable:   h.GetCacheable(),
+			Writable:    h.Writable(),
+			MergePolicy: h.MergePolicy(),
+		})
+	}
+
+	sort.Slice(metadata, func(i, j int) bool {
+		return metadata[i].Path < metadata[j].Path
+	})
+
+	return metadata
 }
 
 func (hs *handlerService) HandlerDB() map[string]domain.HandlerIface {
@@ -791,6 +1727,557 @@ func (hs *handlerService) IgnoreErrors() bool {
 	return hs.ignoreErrors
 }
 
+func (hs *handlerService) SingleInstance() bool {
+	return hs.singleInstance
+}
+
+func (hs *handlerService) SetSingleInstance(val bool) {
+	hs.singleInstance = val
+}
+
+func (hs *handlerService) WriteCoalesceWindow() time.Duration {
+	return hs.writeCoalesceWindow
+}
+
+// SetWriteCoalesceWindow sets the period within which successive writes of a
+// resource by the same container are collapsed into a single host FS update.
+// A zero (or negative) period disables coalescing.
+func (hs *handlerService) SetWriteCoalesceWindow(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	hs.writeCoalesceWindow = d
+}
+
+// WritePending returns true if a coalesced write of the given resource by the
+// passed container is yet to be pushed to the host FS.
+func (hs *handlerService) WritePending(
+	cntr domain.ContainerIface,
+	path string,
+	name string) bool {
+
+	return hs.coalescer.isPending(coalesceKey{cntr.ID(), path, name})
+}
+
+// CoalesceWrite defers the push of the given value to the host FS until the
+// resource has been left alone by the container for the whole coalescing
+// window. Returns false if coalescing is disabled, in which case the caller is
+// expected to push the value by itself.
+//
+// Notice that the outcome of a deferred push can't be reported back to the
+// writer; push failures are merely logged.
+func (hs *handlerService) CoalesceWrite(
+	cntr domain.ContainerIface,
+	path string,
+	name string,
+	val string,
+	push func(val string) error) bool {
+
+	if hs.writeCoalesceWindow <= 0 {
+		return false
+	}
+
+	hs.coalescer.schedule(coalesceKey{cntr.ID(), path, name}, val,
+		hs.writeCoalesceWindow, push)
+
+	return true
+}
+
+// CancelWrites drops the coalesced writes still pending on behalf of the given
+// (departing) container.
+func (hs *handlerService) CancelWrites(cntr domain.ContainerIface) {
+
+	if count := hs.coalescer.cancel(cntr.ID()); count > 0 {
+		logrus.Debugf("Dropped %d pending write(s) of container %v", count, cntr.ID())
+	}
+}
+
+func (hs *handlerService) PushRetries() (int, time.Duration) {
+	return hs.pushRetries, hs.pushBackoff
+}
+
+// SetPushRetries sets the maximum number of attempts made to push a merged
+// value to the host FS when other sysbox instances race with this one, as well
+// as the upper bound of the random delay waited between attempts. At least one
+// attempt is always made; a zero backoff retries right away.
+func (hs *handlerService) SetPushRetries(retries int, backoff time.Duration) {
+	if retries < 1 {
+		retries = 1
+	}
+	if backoff < 0 {
+		backoff = 0
+	}
+	hs.pushRetries = retries
+	hs.pushBackoff = backoff
+}
+
+func (hs *handlerService) PathLimits() (int, int) {
+	return hs.pathMaxLen, hs.pathMaxDepth
+}
+
+// SetPathLimits sets the maximum length and number of components of the paths
+// accepted at the handlers' entry points; longer or deeper ones are rejected
+// with ENAMETOOLONG before reaching any handler (and nsenter). A zero (or
+// negative) value disables the respective limit.
+func (hs *handlerService) SetPathLimits(maxLen, maxDepth int) {
+	if maxLen < 0 {
+		maxLen = 0
+	}
+	if maxDepth < 0 {
+		maxDepth = 0
+	}
+	hs.pathMaxLen = maxLen
+	hs.pathMaxDepth = maxDepth
+}
+
+// CheckPathLimits verifies that the given path is within the configured length /
+// depth limits, returning ENAMETOOLONG otherwise. It's expected to be invoked
+// at every entry point ahead of any handler processing.
+func (hs *handlerService) CheckPathLimits(path string) syscall.Errno {
+
+	if hs.pathMaxLen > 0 && len(path)+1 > hs.pathMaxLen {
+		return syscall.ENAMETOOLONG
+	}
+
+	if hs.pathMaxDepth > 0 {
+		var depth int
+		for _, c := range strings.Split(path, "/") {
+			if c != "" {
+				depth++
+			}
+		}
+		if depth > hs.pathMaxDepth {
+			return syscall.ENAMETOOLONG
+		}
+	}
+
+	return 0
+}
+
+func (hs *handlerService) HostUptime() bool {
+	return hs.hostUptime
+}
+
+func (hs *handlerService) SetHostUptime(val bool) {
+	hs.hostUptime = val
+}
+
+// SetLookupCacheSize sets the maximum number of LookupHandler() resolutions to
+// cache. A zero size disables caching.
+func (hs *handlerService) SetLookupCacheSize(size int) {
+	hs.lookupLock.Lock()
+	defer hs.lookupLock.Unlock()
+
+	if size < 0 {
+		size = 0
+	}
+	hs.lookupCacheSize = size
+	hs.lookupCache = make(map[string]lookupResult)
+	hs.lookupGen++
+}
+
+// SetCacheWarmup sets the list of (non-emulated) /proc/sys resources to prefetch
+// into the cache of the containers right after their registration. The list is
+// capped to cacheWarmupMaxPaths entries; an empty one disables warming.
+func (hs *handlerService) SetCacheWarmup(paths []string) {
+	hs.Lock()
+	defer hs.Unlock()
+
+	if len(paths) > cacheWarmupMaxPaths {
+		logrus.Warnf("Cache warm-up list truncated to %d entries", cacheWarmupMaxPaths)
+		paths = paths[:cacheWarmupMaxPaths]
+	}
+	hs.warmupPaths = paths
+}
+
+// WarmupCache prefetches the resources set through SetCacheWarmup() into the
+// container's cache. Only the ones served by the (cacheable) procSysCommon
+// handler are considered, as those are the ones whose first access would
+// otherwise require an nsenter round-trip of its own. As this is just an
+// optimization, warm-ups exceeding the cacheWarmupMaxJobs running ones are
+// skipped rather than queued.
+func (hs *handlerService) WarmupCache(cntr domain.ContainerIface) {
+
+	hs.RLock()
+	warmupPaths := hs.warmupPaths
+	hs.RUnlock()
+
+	if len(warmupPaths) == 0 {
+		return
+	}
+
+	select {
+	case hs.warmupSem <- struct{}{}:
+		defer func() { <-hs.warmupSem }()
+	default:
+		logrus.Debugf("Cache warm-up skipped for container %s: too many in progress",
+			cntr.ID())
+		return
+	}
+
+	// Resources are grouped by handler, as each one dictates how its resources
+	// are to be read (e.g. verbatim).
+	var (
+		handlers []*implementations.ProcSysCommonHandler
+		paths    = make(map[*implementations.ProcSysCommonHandler][]string)
+	)
+
+	for _, p := range warmupPaths {
+		h, ok := hs.LookupHandler(hs.ios.NewIOnode(path.Base(p), p, 0))
+		if !ok || !h.GetCacheable() {
+			continue
+		}
+		if psc, ok := h.(*implementations.ProcSysCommonHandler); ok {
+			if _, ok := paths[psc]; !ok {
+				handlers = append(handlers, psc)
+			}
+			paths[psc] = append(paths[psc], p)
+		}
+	}
+
+	for _, psc := range handlers {
+		if err := psc.WarmCache(cntr, paths[psc]); err != nil {
+			logrus.Warnf("Cache warm-up failed for container %s: %v", cntr.ID(), err)
+		}
+	}
+}
+
+// Reload applies the passed per-handler settings (indexed by handler path). The
+// whole configuration is validated upfront and rejected if any of its entries
+// refers to an unknown handler or to a setting the handler doesn't support, in
+// which case no handler is altered. Handlers being disabled are unregistered
+// (so that their resources are served by the generic handlers), and handlers
+// being enabled (including the ones disabled at setup time) are registered.
+// Reconfigurable handlers are replaced by reconfigured copies (sharing the
+// original handler's lock), so that requests in progress complete with the
+// settings they were dispatched with.
+func (hs *handlerService) Reload(config map[string]domain.HandlerConfig) error {
+
+	type reload struct {
+		h          domain.HandlerIface
+		registered bool
+		enabled    bool
+	}
+
+	hs.Lock()
+
+	reloads := make(map[string]reload, len(config))
+
+	for path, cfg := range config {
+		h, registered := hs.handlerDB[path]
+		if !registered {
+			var ok bool
+			if h, ok = hs.disabledDB[path]; !ok {
+				hs.Unlock()
+				return fmt.Errorf("handler %v not found", path)
+			}
+		}
+
+		enabled := registered
+		if cfg.Enabled != nil {
+			enabled = *cfg.Enabled
+		}
+
+		r, ok := h.(domain.HandlerReconfigurer)
+		if !ok && (cfg.MergePolicy != "" || cfg.WriteMode != "" ||
+			cfg.AcceptMissing != nil || cfg.AllowedContainers != nil) {
+			hs.Unlock()
+			return fmt.Errorf("handler %v can't be reconfigured", path)
+		}
+		if ok {
+			nh, err := r.Reconfigure(cfg)
+			if err != nil {
+				hs.Unlock()
+				return fmt.Errorf("handler %v: %v", path, err)
+			}
+			h = nh
+		}
+
+		reloads[path] = reload{h: h, registered: registered, enabled: enabled}
+	}
+
+	var persistent []string
+
+	for path, r := range reloads {
+		// Non-reconfigurable handlers are not copied, but their 'enabled'
+		// attribute is not consulted while serving requests.
+		r.h.SetEnabled(r.enabled)
+		r.h.SetService(hs)
+
+		if !r.enabled {
+			delete(hs.handlerDB, path)
+			if r.registered {
+				hs.dirHandlerMapDel(path)
+			}
+			hs.disabledDB[path] = r.h
+			continue
+		}
+
+		delete(hs.disabledDB, path)
+		hs.handlerDB[path] = r.h
+
+		// Keep the dir listings and the persistent-state registrations in
+		// sync with the (possibly replaced) handler, as RegisterHandler() does.
+		hs.dirHandlerMapAdd(path)
+		if r.h.GetPersistent() {
+			persistent = append(persistent, path)
+		}
+	}
+	hs.lookupCacheFlush()
+	hs.Unlock()
+
+	if hs.css != nil {
+		for _, path := range persistent {
+			hs.css.RegisterPersistentPath(path)
+		}
+	}
+
+	logrus.Infof("Reloaded the configuration of %d handler(s)", len(reloads))
+
+	return nil
+}
+
+// SetAuthorizer sets the policy function to consult ahead of every Lookup /
+// Read / Write handler operation. A nil function allows them all.
+func (hs *handlerService) SetAuthorizer(a domain.HandlerAuthorizer) {
+	hs.Lock()
+	defer hs.Unlock()
+
+	hs.authorizer = a
+}
+
+// Authorize returns the verdict of the configured authorizer (if any) for the
+// given operation.
+func (hs *handlerService) Authorize(
+	cntr domain.ContainerIface,
+	pid uint32,
+	path string,
+	op domain.HandlerOp) syscall.Errno {
+
+	hs.RLock()
+	authorizer := hs.authorizer
+	hs.RUnlock()
+
+	if authorizer == nil {
+		return 0
+	}
+
+	return authorizer(cntr, pid, path, op)
+}
+
+// SetAuditor sets the function to hand the audit records of write operations
+// over to. Records are delivered in order by a dedicated goroutine; a nil
+// function disables auditing.
+func (hs *handlerService) SetAuditor(a domain.HandlerAuditor) {
+	hs.Lock()
+	defer hs.Unlock()
+
+	hs.auditor = a
+
+	if a != nil && hs.auditCh == nil {
+		hs.auditCh = make(chan domain.HandlerAuditRecord, auditBufferSize)
+		go hs.auditDispatch(hs.auditCh)
+	}
+}
+
+// Audit queues the given record for delivery to the configured auditor (if
+// any). It never blocks: records are dropped (and accounted for) if the
+// auditor can't keep up with the rate of writes.
+func (hs *handlerService) Audit(rec domain.HandlerAuditRecord) {
+
+	hs.RLock()
+	auditor := hs.auditor
+	auditCh := hs.auditCh
+	hs.RUnlock()
+
+	if auditor == nil || auditCh == nil {
+		return
+	}
+
+	if rec.Time.IsZero() {
+		rec.Time = time.Now()
+	}
+
+	select {
+	case auditCh <- rec:
+	default:
+		drops := atomic.AddUint64(&hs.auditDrops, 1)
+		if drops == 1 || drops%auditBufferSize == 0 {
+			logrus.Warnf("Audit buffer full: %d audit record(s) dropped so far", drops)
+		}
+	}
+}
+
+func (hs *handlerService) auditDispatch(auditCh chan domain.HandlerAuditRecord) {
+
+	for rec := range auditCh {
+		hs.RLock()
+		auditor := hs.auditor
+		hs.RUnlock()
+
+		if auditor != nil {
+			auditor(rec)
+		}
+	}
+}
+
+// SysctlWrite is the entry point for sysctl writes that reach sysbox-fs through
+// a path other than FUSE (e.g. a sysctl(2) syscall trapped via seccomp-notify).
+// The request is dispatched to the very same handler that would process the
+// equivalent FUSE write, so validation, caching and errno semantics are shared
+// by both access paths. The sysctl can be expressed either as a path (e.g.
+// "/proc/sys/net/ipv4/ip_forward") or as a dotted name ("net.ipv4.ip_forward").
+func (hs *handlerService) SysctlWrite(
+	pid uint32,
+	sysctl string,
+	value []byte) syscall.Errno {
+
+	sysctlPath := sysctl
+	if !strings.HasPrefix(sysctlPath, "/proc/sys/") {
+		sysctlPath = path.Join("/proc/sys", strings.Replace(sysctl, ".", "/", -1))
+	}
+	sysctlPath = path.Clean(sysctlPath)
+
+	// Ensure the request doesn't escape /proc/sys (e.g. through "..").
+	if !strings.HasPrefix(sysctlPath, "/proc/sys/") {
+		return syscall.EINVAL
+	}
+
+	if errno := hs.CheckPathLimits(sysctlPath); errno != 0 {
+		return errno
+	}
+
+	process := hs.prs.ProcessCreate(pid, 0, 0)
+
+	cntr := hs.css.ContainerLookupByProcess(process)
+	if cntr == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			pid)
+		return syscall.ESRCH
+	}
+
+	ionode := hs.ios.NewIOnode(path.Base(sysctlPath), sysctlPath, 0)
+	ionode.SetOpenFlags(syscall.O_WRONLY)
+
+	handler, ok := hs.LookupHandler(ionode)
+	if !ok {
+		return syscall.ENOENT
+	}
+
+	errno := hs.sysctlWrite(cntr, process, handler, ionode, value)
+
+	rec := domain.HandlerAuditRecord{
+		Container: cntr.ID(),
+		Pid:       pid,
+		Path:      sysctlPath,
+		Value:     strings.TrimSpace(string(value)),
+	}
+	if errno != 0 {
+		rec.Err = errno
+	}
+	hs.Audit(rec)
+
+	return errno
+}
+
+// Carries out the write requested through SysctlWrite() on the given handler.
+func (hs *handlerService) sysctlWrite(
+	cntr domain.ContainerIface,
+	process domain.ProcessIface,
+	handler domain.HandlerIface,
+	ionode domain.IOnodeIface,
+	value []byte) syscall.Errno {
+
+	pid := process.Pid()
+
+	// Read-only paths are enforced here too, regardless of the handler.
+	if domain.IsRoPath(cntr, ionode.Path()) {
+		return syscall.EROFS
+	}
+
+	if errno := hs.Authorize(cntr, pid, ionode.Path(), domain.HandlerOpWrite); errno != 0 {
+		return errno
+	}
+
+	req := &domain.HandlerRequest{
+		Pid:       pid,
+		Uid:       process.Uid(),
+		Gid:       process.Gid(),
+		Data:      value,
+		Container: cntr,
+	}
+
+	if err := handler.Open(ionode, req); err != nil {
+		return errnoFromError(err)
+	}
+	defer handler.Close(ionode)
+
+	if _, err := handler.Write(ionode, req); err != nil {
+		return errnoFromError(err)
+	}
+
+	return 0
+}
+
+// errnoFromError mimics the error translation carried out by the FUSE lib for
+// the errors returned by handlers.
+func errnoFromError(err error) syscall.Errno {
+
+	switch v := err.(type) {
+	case fuse.IOerror:
+		return v.Code
+	case syscall.Errno:
+		return v
+	}
+
+	return syscall.EIO
+}
+
+// Path looked up by the nsenter health-check; it's present in every host.
+const healthCheckPath = "/proc/sys"
+
+// HealthCheck verifies that the nsenter machinery is operational (e.g. for
+// readiness probes) by means of a minimal round-trip: a lookup of a well-known
+// path within sysbox-fs' own namespaces. The user-ns is left out, as processes
+// can't re-enter the one they live in.
+func (hs *handlerService) HealthCheck() error {
+
+	if hs.nss == nil {
+		return errors.New("nsenter service not available")
+	}
+
+	event := hs.nss.NewEvent(
+		uint32(os.Getpid()),
+		&domain.AllNSsButUser,
+		&domain.NSenterMessage{
+			Type: domain.LookupRequest,
+			Payload: &domain.LookupPayload{
+				Entry: healthCheckPath,
+			},
+		},
+		nil,
+		false,
+	)
+
+	if err := hs.nss.SendRequestEvent(event); err != nil {
+		return fmt.Errorf("nsenter health-check failed: %v", err)
+	}
+
+	responseMsg := hs.nss.ReceiveResponseEvent(event)
+	if responseMsg == nil {
+		return errors.New("nsenter health-check failed: no response")
+	}
+	if responseMsg.Type == domain.ErrorResponse {
+		return fmt.Errorf("nsenter health-check failed: %v", responseMsg.Payload)
+	}
+	if responseMsg.Type != domain.LookupResponse {
+		return fmt.Errorf("nsenter health-check failed: unexpected %v response",
+			responseMsg.Type)
+	}
+
+	return nil
+}
+
 //
 // Auxiliary methods
 //
@@ -0,0 +1,1292 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package handler_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+	"github.com/nestybox/sysbox-fs/handler"
+	"github.com/nestybox/sysbox-fs/handler/implementations"
+	"github.com/nestybox/sysbox-fs/mocks"
+	"github.com/nestybox/sysbox-fs/nsenter"
+	"github.com/nestybox/sysbox-fs/process"
+	"github.com/nestybox/sysbox-fs/state"
+	"github.com/nestybox/sysbox-fs/sysio"
+)
+
+// Credentials expected to be assumed by the nsenter agent on behalf of the
+// requests issued by these tests, whose (host) ids fall outside of the
+// containers' id range.
+var unmappedCreds = &domain.NSenterCreds{Uid: 65534, Gid: 65534}
+
+func TestHandlerService_SysctlWrite(t *testing.T) {
+
+	// Disable log generation during UT.
+	logrus.SetOutput(ioutil.Discard)
+
+	ios := sysio.NewIOService(domain.IOMemFileService)
+	prs := process.NewProcessService()
+	prs.Setup(ios)
+
+	// Container-state is mocked to skip the container registration sequence.
+	realCss := state.NewContainerStateService()
+	realCss.Setup(nil, prs, ios, nil)
+	css := &mocks.ContainerStateServiceIface{}
+	nss := &mocks.NSenterServiceIface{}
+
+	// Host's user-ns inode must be available during handler-service setup.
+	prs.ProcessCreate(uint32(os.Getpid()), 0, 0).CreateNsInodes(1)
+
+	hds := handler.NewHandlerService()
+	hds.Setup(handler.DefaultHandlers, false, css, nss, prs, ios)
+
+	c1 := realCss.ContainerCreate(
+		"c1",
+		uint32(1001),
+		time.Time{},
+		231072,
+		65535,
+		231072,
+		65535,
+		nil,
+		nil,
+		false,
+		nil)
+	_ = c1.SetInitProc(c1.InitPid(), c1.UID(), c1.GID())
+	c1.InitProc().CreateNsInodes(123456)
+
+	css.On("ContainerLookupByProcess", mock.Anything).Return(c1)
+
+	const sysctlPath = "/proc/sys/net/ipv4/ip_forward"
+
+	// Expected nsenter requests: the same ones dispatched by a FUSE open() +
+	// write() sequence over the procSysCommon handler.
+	openReq := &nsenter.NSenterEvent{
+		Pid:       c1.InitPid(),
+		Namespace: &domain.AllNSsButMount,
+		ReqMsg: &domain.NSenterMessage{
+			Type: domain.OpenFileRequest,
+			Payload: &domain.OpenFilePayload{
+				File:  sysctlPath,
+				Flags: strconv.Itoa(syscall.O_WRONLY),
+				Mode:  strconv.Itoa(0),
+			},
+			Creds: unmappedCreds,
+		},
+	}
+	writeReq := &nsenter.NSenterEvent{
+		Pid:       c1.InitPid(),
+		Namespace: &domain.AllNSsButMount,
+		ReqMsg: &domain.NSenterMessage{
+			Type: domain.WriteFileRequest,
+			Payload: &domain.WriteFilePayload{
+				File:    sysctlPath,
+				Content: "1",
+			},
+			Creds: unmappedCreds,
+		},
+	}
+
+	tests := []struct {
+		name    string
+		sysctl  string
+		value   string
+		want    syscall.Errno
+		prepare func()
+	}{
+		{
+			//
+			// Test-case 1: Dotted sysctl name. No errors expected.
+			//
+			name:   "1",
+			sysctl: "net.ipv4.ip_forward",
+			value:  "1\n",
+			want:   0,
+			prepare: func() {
+				nss.On("NewEvent", c1.InitPid(), &domain.AllNSsButMount,
+					openReq.ReqMsg, (*domain.NSenterMessage)(nil), false).Return(openReq)
+				nss.On("SendRequestEvent", openReq).Return(nil)
+				nss.On("ReceiveResponseEvent", openReq).Return(
+					&domain.NSenterMessage{Type: domain.OpenFileResponse})
+
+				nss.On("NewEvent", c1.InitPid(), &domain.AllNSsButMount,
+					writeReq.ReqMsg, (*domain.NSenterMessage)(nil), false).Return(writeReq)
+				nss.On("SendRequestEvent", writeReq).Return(nil)
+				nss.On("ReceiveResponseEvent", writeReq).Return(
+					&domain.NSenterMessage{Type: domain.WriteFileResponse})
+			},
+		},
+		{
+			//
+			// Test-case 2: Verify that errors obtained from the nsenter agent are
+			// returned as errnos (EACCES).
+			//
+			name:   "2",
+			sysctl: sysctlPath,
+			value:  "1\n",
+			want:   syscall.EACCES,
+			prepare: func() {
+				nss.On("NewEvent", c1.InitPid(), &domain.AllNSsButMount,
+					openReq.ReqMsg, (*domain.NSenterMessage)(nil), false).Return(openReq)
+				nss.On("SendRequestEvent", openReq).Return(nil)
+				nss.On("ReceiveResponseEvent", openReq).Return(
+					&domain.NSenterMessage{
+						Type:    domain.ErrorResponse,
+						Payload: fuse.IOerror{Code: syscall.EACCES},
+					})
+			},
+		},
+		{
+			//
+			// Test-case 3: Sysctl escaping /proc/sys (EINVAL). No nsenter
+			// requests expected.
+			//
+			name:    "3",
+			sysctl:  "/proc/sys/../self/environ",
+			value:   "1\n",
+			want:    syscall.EINVAL,
+			prepare: func() {},
+		},
+		{
+			//
+			// Test-case 4: Container in sysctl read-only mode (EROFS). No
+			// nsenter requests expected.
+			//
+			name:   "4",
+			sysctl: "net.ipv4.ip_forward",
+			value:  "1\n",
+			want:   syscall.EROFS,
+			prepare: func() {
+				c1.SetSysctlReadOnly(true)
+			},
+		},
+	}
+
+	//
+	// Testcase executions.
+	//
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			// Prepare the mocks.
+			if tt.prepare != nil {
+				tt.prepare()
+			}
+
+			got := hds.SysctlWrite(c1.InitPid(), tt.sysctl, []byte(tt.value))
+			if got != tt.want {
+				t.Errorf("handlerService.SysctlWrite() = %v, want %v", got, tt.want)
+			}
+
+			// Ensure that mocks were properly invoked and reset expectedCalls
+			// object.
+			nss.AssertExpectations(t)
+			nss.ExpectedCalls = nil
+		})
+	}
+}
+
+func TestHandlerService_DirHandlerEntries(t *testing.T) {
+
+	// Disable log generation during UT.
+	logrus.SetOutput(ioutil.Discard)
+
+	ios := sysio.NewIOService(domain.IOMemFileService)
+	prs := process.NewProcessService()
+	prs.Setup(ios)
+
+	css := state.NewContainerStateService()
+	css.Setup(nil, prs, ios, nil)
+	nss := &mocks.NSenterServiceIface{}
+
+	// Host's user-ns inode must be available during handler-service setup.
+	prs.ProcessCreate(uint32(os.Getpid()), 0, 0).CreateNsInodes(1)
+
+	hds := handler.NewHandlerService()
+	hds.Setup(handler.DefaultHandlers, false, css, nss, prs, ios)
+
+	c1 := css.ContainerCreate(
+		"c1",
+		uint32(1001),
+		time.Time{},
+		231072,
+		65535,
+		231072,
+		65535,
+		nil,
+		nil,
+		false,
+		nil)
+
+	const (
+		dirPath = "/proc/sys/foo"
+		hdlPath = "/proc/sys/foo/bar"
+	)
+
+	// Emulated resource to be registered after the handler-service setup.
+	h := &implementations.MaxIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:    "fooBar",
+			Path:    hdlPath,
+			Type:    domain.NODE_SUBSTITUTION,
+			Enabled: true,
+		},
+	}
+	if err := ios.NewIOnode("bar", hdlPath, 0).WriteFile([]byte("0")); err != nil {
+		t.Fatalf("Could not initialize host file: %v", err)
+	}
+
+	// The container's view of /proc/sys/foo is empty.
+	readDirReq := &nsenter.NSenterEvent{
+		Pid:       c1.InitPid(),
+		Namespace: &domain.AllNSsButMount,
+		ReqMsg: &domain.NSenterMessage{
+			Type:    domain.ReadDirRequest,
+			Payload: &domain.ReadDirPayload{Dir: dirPath},
+			Creds:   unmappedCreds,
+		},
+	}
+	nss.On("NewEvent", c1.InitPid(), &domain.AllNSsButMount,
+		readDirReq.ReqMsg, (*domain.NSenterMessage)(nil), false).Return(readDirReq)
+	nss.On("SendRequestEvent", readDirReq).Return(nil)
+	nss.On("ReceiveResponseEvent", readDirReq).Return(
+		&domain.NSenterMessage{
+			Type:    domain.ReadDirResponse,
+			Payload: []domain.FileInfo{},
+		})
+
+	readDirNames := func() []string {
+		common, ok := hds.FindHandler("procSysCommonHandler")
+		if !ok {
+			t.Fatalf("procSysCommonHandler not found")
+		}
+
+		infos, err := common.ReadDirAll(
+			ios.NewIOnode("foo", dirPath, 0),
+			&domain.HandlerRequest{Pid: c1.InitPid(), Container: c1},
+		)
+		if err != nil {
+			t.Fatalf("ReadDirAll() error = %v", err)
+		}
+
+		var names []string
+		for _, info := range infos {
+			names = append(names, info.Name())
+		}
+
+		return names
+	}
+
+	// Newly registered handlers must show up in their parent dir's listings.
+	if err := hds.RegisterHandler(h); err != nil {
+		t.Fatalf("RegisterHandler() error = %v", err)
+	}
+	if got := hds.DirHandlerEntries(dirPath); !reflect.DeepEqual(got, []string{hdlPath}) {
+		t.Errorf("DirHandlerEntries() = %v, want %v", got, []string{hdlPath})
+	}
+	if got := readDirNames(); !reflect.DeepEqual(got, []string{"bar"}) {
+		t.Errorf("ReadDirAll() = %v, want %v", got, []string{"bar"})
+	}
+
+	// And they must go away once unregistered.
+	if err := hds.UnregisterHandler(h); err != nil {
+		t.Fatalf("UnregisterHandler() error = %v", err)
+	}
+	if got := hds.DirHandlerEntries(dirPath); got != nil {
+		t.Errorf("DirHandlerEntries() = %v, want none", got)
+	}
+	if got := readDirNames(); len(got) != 0 {
+		t.Errorf("ReadDirAll() = %v, want empty listing", got)
+	}
+}
+
+func TestHandlerService_LookupHandlerPid(t *testing.T) {
+
+	// Disable log generation during UT.
+	logrus.SetOutput(ioutil.Discard)
+
+	ios := sysio.NewIOService(domain.IOMemFileService)
+	prs := process.NewProcessService()
+	prs.Setup(ios)
+
+	css := state.NewContainerStateService()
+	css.Setup(nil, prs, ios, nil)
+	nss := &mocks.NSenterServiceIface{}
+
+	// Host's user-ns inode must be available during handler-service setup.
+	prs.ProcessCreate(uint32(os.Getpid()), 0, 0).CreateNsInodes(1)
+
+	hds := handler.NewHandlerService()
+	hds.Setup(handler.DefaultHandlers, false, css, nss, prs, ios)
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/proc/1/status", "procPidStatus"},
+		{"/proc/4194304/status", "procPidStatus"},
+		{"/proc/self/status", "proc"},
+		{"/proc/1/stat", "proc"},
+		{"/proc/uptime", "procUptime"},
+		{"/proc/sys/net/ipv4/conf/all/arp_ignore", "ipv4ConfArpIgnore"},
+		{"/proc/sys/net/ipv4/conf/default/arp_announce", "ipv4ConfArpAnnounce"},
+		{"/proc/sys/net/ipv4/conf/eth0/arp_ignore", "ipv4ConfArpIgnore"},
+		{"/proc/sys/net/ipv4/conf/all/route_localnet", "ipv4ConfRouteLocalnet"},
+		{"/proc/sys/net/ipv4/conf/eth0/route_localnet", "ipv4ConfRouteLocalnet"},
+		{"/proc/sys/net/ipv4/conf/eth0/arp_filter", "procSysCommon"},
+		{"/proc/sys/net/ipv4/conf/eth0", "procSysCommon"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			h, ok := hds.LookupHandler(ios.NewIOnode("", tt.path, 0))
+			if !ok {
+				t.Fatalf("LookupHandler(%v) found no handler", tt.path)
+			}
+			if h.GetName() != tt.want {
+				t.Errorf("LookupHandler(%v) = %v, want %v", tt.path, h.GetName(), tt.want)
+			}
+		})
+	}
+
+	// Wildcarded paths are not displayed in any dir listing.
+	if got := hds.DirHandlerEntries("/proc/*"); got != nil {
+		t.Errorf("DirHandlerEntries() = %v, want none", got)
+	}
+	if got := hds.DirHandlerEntries("/proc/sys/net/ipv4/conf/*"); got != nil {
+		t.Errorf("DirHandlerEntries() = %v, want none", got)
+	}
+}
+
+func TestHandlerService_LookupHandlerCache(t *testing.T) {
+
+	// Disable log generation during UT.
+	logrus.SetOutput(ioutil.Discard)
+
+	ios := sysio.NewIOService(domain.IOMemFileService)
+	prs := process.NewProcessService()
+	prs.Setup(ios)
+
+	css := state.NewContainerStateService()
+	css.Setup(nil, prs, ios, nil)
+	nss := &mocks.NSenterServiceIface{}
+
+	// Host's user-ns inode must be available during handler-service setup.
+	prs.ProcessCreate(uint32(os.Getpid()), 0, 0).CreateNsInodes(1)
+
+	hds := handler.NewHandlerService()
+	hds.Setup(handler.DefaultHandlers, false, css, nss, prs, ios)
+
+	const (
+		hdlPath  = "/proc/sys/foo/bar"
+		nonePath = "/foo/bar"
+	)
+
+	lookup := func(p string) (domain.HandlerIface, bool) {
+		return hds.LookupHandler(ios.NewIOnode("", p, 0))
+	}
+
+	common, _ := hds.FindHandler("procSysCommonHandler")
+
+	h := &implementations.MaxIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:    "fooBar",
+			Path:    hdlPath,
+			Type:    domain.NODE_SUBSTITUTION,
+			Enabled: true,
+		},
+	}
+
+	for _, size := range []int{16, 0} {
+		t.Run(strconv.Itoa(size), func(t *testing.T) {
+			hds.SetLookupCacheSize(size)
+
+			// Repeated resolutions (cached or not) must be consistent ...
+			for i := 0; i < 2; i++ {
+				if got, ok := lookup(hdlPath); !ok || got != common {
+					t.Errorf("LookupHandler(%v) = %v, want %v", hdlPath, got, common)
+				}
+				if got, ok := lookup(nonePath); ok {
+					t.Errorf("LookupHandler(%v) = %v, want none", nonePath, got)
+				}
+			}
+
+			// ... and reflect the registration changes right away.
+			if err := hds.RegisterHandler(h); err != nil {
+				t.Fatalf("RegisterHandler() error = %v", err)
+			}
+			if got, ok := lookup(hdlPath); !ok || got != h {
+				t.Errorf("LookupHandler(%v) = %v, want %v", hdlPath, got, h)
+			}
+
+			if err := hds.UnregisterHandler(h); err != nil {
+				t.Fatalf("UnregisterHandler() error = %v", err)
+			}
+			if got, ok := lookup(hdlPath); !ok || got != common {
+				t.Errorf("LookupHandler(%v) = %v, want %v", hdlPath, got, common)
+			}
+		})
+	}
+}
+
+func TestHandlerService_HandlersMetadata(t *testing.T) {
+
+	// Disable log generation during UT.
+	logrus.SetOutput(ioutil.Discard)
+
+	ios := sysio.NewIOService(domain.IOMemFileService)
+	prs := process.NewProcessService()
+	prs.Setup(ios)
+
+	// Host's user-ns inode must be available during handler-service setup.
+	prs.ProcessCreate(uint32(os.Getpid()), 0, 0).CreateNsInodes(1)
+
+	hds := handler.NewHandlerService()
+	hds.Setup(handler.DefaultHandlers, false, nil, nil, prs, ios)
+
+	want := map[string]domain.HandlerMetadata{
+		"/proc/sys/net/core/netdev_max_backlog": {
+			Name:        "coreNetdevMaxBacklog",
+			Path:        "/proc/sys/net/core/netdev_max_backlog",
+			Type:        domain.NODE_SUBSTITUTION,
+			Enabled:     true,
+			Cacheable:   true,
+			Writable:    true,
+			MergePolicy: domain.MergePolicyMax,
+		},
+		"/proc/sys/kernel/watchdog": {
+			Name:        "kernelWatchdog",
+			Path:        "/proc/sys/kernel/watchdog",
+			Type:        domain.NODE_SUBSTITUTION,
+			Enabled:     true,
+			Cacheable:   false,
+			Writable:    false,
+			MergePolicy: domain.MergePolicyNone,
+		},
+		"/proc/sys/kernel/cap_last_cap": {
+			Name:        "kernelLastCap",
+			Path:        "/proc/sys/kernel/cap_last_cap",
+			Type:        domain.NODE_SUBSTITUTION,
+			Enabled:     true,
+			Cacheable:   true,
+			Writable:    false,
+			MergePolicy: domain.MergePolicyNone,
+		},
+		"/proc/cpuinfo": {
+			Name:        "procCpuinfo",
+			Path:        "/proc/cpuinfo",
+			Type:        domain.NODE_SUBSTITUTION | domain.NODE_BINDMOUNT,
+			Enabled:     true,
+			Cacheable:   true,
+			Writable:    false,
+			MergePolicy: domain.MergePolicyNone,
+		},
+		"/proc/swaps": {
+			Name:        "procSwaps",
+			Path:        "/proc/swaps",
+			Type:        domain.NODE_SUBSTITUTION | domain.NODE_BINDMOUNT | domain.NODE_PROPAGATE,
+			Enabled:     true,
+			Cacheable:   false,
+			Writable:    false,
+			MergePolicy: domain.MergePolicyNone,
+		},
+	}
+
+	metadata := hds.HandlersMetadata()
+	if len(metadata) != len(hds.HandlerDB()) {
+		t.Errorf("HandlersMetadata() returned %d entries, want %d",
+			len(metadata), len(hds.HandlerDB()))
+	}
+
+	for i, m := range metadata {
+		if i > 0 && metadata[i-1].Path >= m.Path {
+			t.Errorf("HandlersMetadata() not sorted: %v found after %v",
+				m.Path, metadata[i-1].Path)
+		}
+
+		if w, ok := want[m.Path]; ok {
+			if m != w {
+				t.Errorf("HandlersMetadata() = %+v, want %+v", m, w)
+			}
+			delete(want, m.Path)
+		}
+	}
+
+	for path := range want {
+		t.Errorf("HandlersMetadata() missing entry for %v", path)
+	}
+}
+
+func TestHandlerService_ReadOnlyHandlers(t *testing.T) {
+
+	// Handlers serving content synthesized by sysbox-fs (or fetched from the
+	// host FS) with nothing to write it into.
+	names := map[string]bool{
+		"procLoadavg":                   true,
+		"procMeminfo":                   true,
+		"procPidStatus":                 true,
+		"procStat":                      true,
+		"procUptime":                    true,
+		"kernelRandomEntropyAvail":      true,
+		"kernelRandomPoolsize":          true,
+		"ipv4TcpAvailCongestionControl": true,
+		"sysDevicesSystemCpuOnline":     true,
+	}
+
+	for _, h := range handler.DefaultHandlers {
+		if !names[h.GetName()] {
+			continue
+		}
+		delete(names, h.GetName())
+
+		if !h.GetReadOnly() || h.Writable() {
+			t.Errorf("%v handler: GetReadOnly() = %v, Writable() = %v; want true, false",
+				h.GetName(), h.GetReadOnly(), h.Writable())
+		}
+	}
+
+	for name := range names {
+		t.Errorf("%v handler not found", name)
+	}
+}
+
+func TestHandlerService_UsernsSysctls(t *testing.T) {
+
+	// Disable log generation during UT.
+	logrus.SetOutput(ioutil.Discard)
+
+	ios := sysio.NewIOService(domain.IOMemFileService)
+	prs := process.NewProcessService()
+	prs.Setup(ios)
+
+	// Container-state is mocked to skip the container registration sequence.
+	realCss := state.NewContainerStateService()
+	realCss.Setup(nil, prs, ios, nil)
+	css := &mocks.ContainerStateServiceIface{}
+
+	// Host's user-ns inode must be available during handler-service setup.
+	prs.ProcessCreate(uint32(os.Getpid()), 0, 0).CreateNsInodes(1)
+
+	hds := handler.NewHandlerService()
+	hds.Setup(handler.DefaultHandlers, false, css, nil, prs, ios)
+
+	c1 := realCss.ContainerCreate("c1", uint32(1001), time.Time{}, 231072, 65535,
+		231072, 65535, nil, nil, false, nil)
+	c2 := realCss.ContainerCreate("c2", uint32(2001), time.Time{}, 296608, 65535,
+		296608, 65535, nil, nil, false, nil)
+
+	for _, c := range []domain.ContainerIface{c1, c2} {
+		cntr := c
+		css.On("ContainerLookupByProcess", mock.MatchedBy(
+			func(p domain.ProcessIface) bool {
+				return p.Pid() == cntr.InitPid()
+			})).Return(cntr)
+	}
+
+	const (
+		usernsClonePath = "/proc/sys/kernel/unprivileged_userns_clone"
+		maxUsernsPath   = "/proc/sys/user/max_user_namespaces"
+		maxNetnsPath    = "/proc/sys/user/max_net_namespaces"
+		maxPidnsPath    = "/proc/sys/user/max_pid_namespaces"
+	)
+
+	// Host FS initial state.
+	hostVals := map[string]string{
+		usernsClonePath: "1",
+		maxUsernsPath:   "1000",
+		maxNetnsPath:    "1000",
+		maxPidnsPath:    "1000",
+	}
+	for path, val := range hostVals {
+		n := ios.NewIOnode("", path, 0)
+		if err := n.WriteFile([]byte(val)); err != nil {
+			t.Fatalf("Could not initialize host file %v: %v", path, err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		cntr        domain.ContainerIface
+		sysctl      string
+		value       string
+		want        syscall.Errno
+		wantHostVal string
+	}{
+		{
+			//
+			// Test-case 1: Userns creation can't be disabled from within a
+			// container (EPERM).
+			//
+			name:        "1",
+			cntr:        c1,
+			sysctl:      usernsClonePath,
+			value:       "0\n",
+			want:        syscall.EPERM,
+			wantHostVal: "1",
+		},
+		{
+			//
+			// Test-case 2: First container raises the userns budget. Host FS must
+			// be updated.
+			//
+			name:        "2",
+			cntr:        c1,
+			sysctl:      maxUsernsPath,
+			value:       "4000\n",
+			want:        0,
+			wantHostVal: "4000",
+		},
+		{
+			//
+			// Test-case 3: Second container attempts to shrink the budget. Host
+			// FS must keep the max across containers.
+			//
+			name:        "3",
+			cntr:        c2,
+			sysctl:      maxUsernsPath,
+			value:       "10\n",
+			want:        0,
+			wantHostVal: "4000",
+		},
+		{
+			//
+			// Test-case 4: Zero budget (EINVAL), as with the other namespace
+			// types.
+			//
+			name:        "4",
+			cntr:        c2,
+			sysctl:      maxUsernsPath,
+			value:       "0\n",
+			want:        syscall.EINVAL,
+			wantHostVal: "4000",
+		},
+		{
+			//
+			// Test-case 5: First container raises the netns budget. Host FS must
+			// be updated.
+			//
+			name:        "5",
+			cntr:        c1,
+			sysctl:      maxNetnsPath,
+			value:       "2000\n",
+			want:        0,
+			wantHostVal: "2000",
+		},
+		{
+			//
+			// Test-case 6: Second container sets a lower netns budget. Host FS
+			// must keep the max across containers.
+			//
+			name:        "6",
+			cntr:        c2,
+			sysctl:      maxNetnsPath,
+			value:       "1500\n",
+			want:        0,
+			wantHostVal: "2000",
+		},
+		{
+			//
+			// Test-case 7: Zero netns budget (EINVAL).
+			//
+			name:        "7",
+			cntr:        c2,
+			sysctl:      maxNetnsPath,
+			value:       "0\n",
+			want:        syscall.EINVAL,
+			wantHostVal: "2000",
+		},
+		{
+			//
+			// Test-case 8: Second container raises the pidns budget. Host FS
+			// must be updated.
+			//
+			name:        "8",
+			cntr:        c2,
+			sysctl:      maxPidnsPath,
+			value:       "3000\n",
+			want:        0,
+			wantHostVal: "3000",
+		},
+		{
+			//
+			// Test-case 9: Non-numeric pidns budget (EINVAL).
+			//
+			name:        "9",
+			cntr:        c1,
+			sysctl:      maxPidnsPath,
+			value:       "foo\n",
+			want:        syscall.EINVAL,
+			wantHostVal: "3000",
+		},
+	}
+
+	//
+	// Testcase executions.
+	//
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := hds.SysctlWrite(tt.cntr.InitPid(), tt.sysctl, []byte(tt.value))
+			if got != tt.want {
+				t.Errorf("handlerService.SysctlWrite() = %v, want %v", got, tt.want)
+			}
+
+			gotHostVal, err := ios.NewIOnode("", tt.sysctl, 0).ReadLine()
+			if err != nil {
+				t.Fatalf("Could not read host file: %v", err)
+			}
+			if gotHostVal != tt.wantHostVal {
+				t.Errorf("handlerService.SysctlWrite() host value = %v, want %v",
+					gotHostVal, tt.wantHostVal)
+			}
+		})
+	}
+}
+
+func TestHandlerService_Auditor(t *testing.T) {
+
+	hds := handler.NewHandlerService()
+
+	// Auditing disabled by default: records must be silently discarded.
+	hds.Audit(domain.HandlerAuditRecord{Container: "c0"})
+
+	recs := make(chan domain.HandlerAuditRecord)
+	hds.SetAuditor(func(rec domain.HandlerAuditRecord) {
+		recs <- rec
+	})
+
+	paths := []string{
+		"/proc/sys/net/ipv4/ip_forward",
+		"/proc/sys/kernel/pid_max",
+		"/proc/sys/vm/nr_hugepages",
+	}
+
+	// Records must be queued without waiting for the (blocked) auditor.
+	done := make(chan struct{})
+	go func() {
+		for _, p := range paths {
+			hds.Audit(domain.HandlerAuditRecord{Container: "c1", Pid: 1001, Path: p})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("handlerService.Audit() blocked on the auditor")
+	}
+
+	// Records must be delivered in order, and timestamped.
+	for _, p := range paths {
+		select {
+		case rec := <-recs:
+			if rec.Container != "c1" || rec.Path != p {
+				t.Errorf("Audit record = %+v, want container c1 and path %v", rec, p)
+			}
+			if rec.Time.IsZero() {
+				t.Errorf("Audit record for %v not timestamped", p)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Audit record for %v not delivered", p)
+		}
+	}
+
+	// Records exceeding the buffer must be dropped rather than block writes.
+	done = make(chan struct{})
+	go func() {
+		for i := 0; i < 4096; i++ {
+			hds.Audit(domain.HandlerAuditRecord{Container: "c1", Pid: 1001})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("handlerService.Audit() blocked with a full buffer")
+	}
+
+	hds.SetAuditor(nil)
+}
+
+func TestHandlerService_PushRetries(t *testing.T) {
+
+	hds := handler.NewHandlerService()
+
+	// Defaults.
+	if retries, backoff := hds.PushRetries(); retries != 5 || backoff != 100*time.Microsecond {
+		t.Errorf("handlerService.PushRetries() = (%v, %v), want (5, 100µs)", retries, backoff)
+	}
+
+	hds.SetPushRetries(10, time.Millisecond)
+	if retries, backoff := hds.PushRetries(); retries != 10 || backoff != time.Millisecond {
+		t.Errorf("handlerService.PushRetries() = (%v, %v), want (10, 1ms)", retries, backoff)
+	}
+
+	// At least one attempt is always made, and negative delays are ignored.
+	hds.SetPushRetries(0, -time.Second)
+	if retries, backoff := hds.PushRetries(); retries != 1 || backoff != 0 {
+		t.Errorf("handlerService.PushRetries() = (%v, %v), want (1, 0s)", retries, backoff)
+	}
+}
+
+func TestHandlerService_WriteCoalescing(t *testing.T) {
+
+	hds := handler.NewHandlerService()
+
+	c1 := &mocks.ContainerIface{}
+	c1.On("ID").Return("c1")
+	c2 := &mocks.ContainerIface{}
+	c2.On("ID").Return("c2")
+
+	pushed := make(chan string, 8)
+	push := func(val string) error {
+		pushed <- val
+		return nil
+	}
+
+	// Coalescing disabled: caller is expected to push the value by itself.
+	if hds.CoalesceWrite(c1, "/proc/sys/a", "a", "1", push) {
+		t.Fatalf("handlerService.CoalesceWrite() = true with coalescing disabled")
+	}
+
+	hds.SetWriteCoalesceWindow(50 * time.Millisecond)
+
+	// Bursts of both containers, the one of c2 being dropped on its departure.
+	for _, val := range []string{"1", "2", "3"} {
+		if !hds.CoalesceWrite(c1, "/proc/sys/a", "a", "c1-"+val, push) ||
+			!hds.CoalesceWrite(c2, "/proc/sys/a", "a", "c2-"+val, push) {
+			t.Fatalf("handlerService.CoalesceWrite() = false with coalescing enabled")
+		}
+	}
+	if !hds.WritePending(c1, "/proc/sys/a", "a") || !hds.WritePending(c2, "/proc/sys/a", "a") {
+		t.Fatalf("handlerService.WritePending() = false, want true")
+	}
+
+	hds.CancelWrites(c2)
+	if hds.WritePending(c2, "/proc/sys/a", "a") {
+		t.Errorf("handlerService.WritePending() = true after CancelWrites()")
+	}
+
+	select {
+	case val := <-pushed:
+		if val != "c1-3" {
+			t.Errorf("handlerService.CoalesceWrite() pushed %v, want c1-3", val)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("handlerService.CoalesceWrite() value never pushed")
+	}
+
+	// Nothing else (i.e. no value of c2) is expected to reach the host.
+	select {
+	case val := <-pushed:
+		t.Errorf("handlerService.CoalesceWrite() unexpectedly pushed %v", val)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestHandlerService_PathLimits(t *testing.T) {
+
+	// Disable log generation during UT.
+	logrus.SetOutput(ioutil.Discard)
+
+	hds := handler.NewHandlerService()
+
+	// Defaults.
+	if maxLen, maxDepth := hds.PathLimits(); maxLen != syscall.PathMax || maxDepth != 64 {
+		t.Errorf("handlerService.PathLimits() = (%v, %v), want (%v, 64)",
+			maxLen, maxDepth, syscall.PathMax)
+	}
+
+	hds.SetPathLimits(64, 4)
+
+	tests := []struct {
+		name string
+		path string
+		want syscall.Errno
+	}{
+		{
+			//
+			// Test-case 1: Path within limits.
+			//
+			name: "1",
+			path: "/proc/sys/net/core",
+			want: 0,
+		},
+		{
+			//
+			// Test-case 2: Over-long path (ENAMETOOLONG).
+			//
+			name: "2",
+			path: "/proc/sys/" + strings.Repeat("a", 64),
+			want: syscall.ENAMETOOLONG,
+		},
+		{
+			//
+			// Test-case 3: Over-deep path (ENAMETOOLONG).
+			//
+			name: "3",
+			path: "/proc/sys/a/b/c",
+			want: syscall.ENAMETOOLONG,
+		},
+		{
+			//
+			// Test-case 4: Repeated separators not accounted as components.
+			//
+			name: "4",
+			path: "/proc//sys/a/b/",
+			want: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := hds.CheckPathLimits(tt.path)
+			if got != tt.want {
+				t.Errorf("handlerService.CheckPathLimits() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Limits also apply to the non-FUSE entry points, ahead of any container
+	// lookup.
+	if got := hds.SysctlWrite(1001, "net.a.b.c", []byte("1")); got != syscall.ENAMETOOLONG {
+		t.Errorf("handlerService.SysctlWrite() = %v, want %v", got, syscall.ENAMETOOLONG)
+	}
+
+	// Zero values disable the limits.
+	hds.SetPathLimits(0, 0)
+	if got := hds.CheckPathLimits("/proc/sys/a/b/c"); got != 0 {
+		t.Errorf("handlerService.CheckPathLimits() = %v, want 0", got)
+	}
+}
+
+func TestHandlerService_Reload(t *testing.T) {
+
+	// Disable log generation during UT.
+	logrus.SetOutput(ioutil.Discard)
+
+	ios := sysio.NewIOService(domain.IOMemFileService)
+	prs := process.NewProcessService()
+	prs.Setup(ios)
+
+	// Host's user-ns inode must be available during handler-service setup.
+	prs.ProcessCreate(uint32(os.Getpid()), 0, 0).CreateNsInodes(1)
+
+	guarded := &implementations.GuardedIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:    "kernelWatchdog",
+			Path:    "/proc/sys/kernel/watchdog",
+			Type:    domain.NODE_SUBSTITUTION,
+			Enabled: true,
+		},
+		Passthrough: true,
+		Min:         0,
+		Max:         1,
+	}
+	merged := &implementations.MergeBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "kernelHungTaskTimeoutSecs",
+			Path:      "/proc/sys/kernel/hung_task_timeout_secs",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			ValueType: domain.ValueTypeInt,
+		},
+		Policy: domain.MergePolicyLast,
+	}
+	maxed := &implementations.MaxIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:       "fsAioMaxNr",
+			Path:       "/proc/sys/fs/aio-max-nr",
+			Type:       domain.NODE_SUBSTITUTION,
+			Enabled:    true,
+			Persistent: true,
+		},
+	}
+	nmi := &implementations.GuardedIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:    "kernelNmiWatchdog",
+			Path:    "/proc/sys/kernel/nmi_watchdog",
+			Type:    domain.NODE_SUBSTITUTION,
+			Enabled: false,
+		},
+		Min: 0,
+		Max: 1,
+	}
+	common := &implementations.ProcSysCommonHandler{
+		domain.HandlerBase{
+			Name:    "procSysCommon",
+			Path:    "procSysCommonHandler",
+			Enabled: true,
+		},
+	}
+
+	css := &mocks.ContainerStateServiceIface{}
+	css.On("RegisterPersistentPath", maxed.Path).Return()
+
+	hds := handler.NewHandlerService()
+	hds.Setup([]domain.HandlerIface{guarded, merged, maxed, nmi, common}, false, css, nil, prs, ios)
+
+	lookup := func(path string) domain.HandlerIface {
+		h, _ := hds.LookupHandler(ios.NewIOnode("", path, 0))
+		return h
+	}
+
+	disabled := false
+	enabled := true
+
+	// Handlers disabled at setup time are not served.
+	if h := lookup(nmi.Path); h != common {
+		t.Errorf("handler %v served while disabled", nmi.Path)
+	}
+
+	// Disable the guarded handler and restrict its writes to a single
+	// container; switch the merge handler to the 'max' policy.
+	err := hds.Reload(map[string]domain.HandlerConfig{
+		guarded.Path: {Enabled: &disabled, AllowedContainers: []string{"c1"}},
+		merged.Path:  {MergePolicy: domain.MergePolicyMax},
+	})
+	if err != nil {
+		t.Fatalf("handlerService.Reload() error = %v", err)
+	}
+
+	// Disabled handlers stop being served: their resources fall back to the
+	// common handler, and are no longer listed.
+	if h := lookup(guarded.Path); h != common {
+		t.Errorf("handler %v still served after being disabled", guarded.Path)
+	}
+	if e := hds.DirHandlerEntries("/proc/sys/kernel"); !reflect.DeepEqual(e, []string{merged.Path}) {
+		t.Errorf("dir /proc/sys/kernel entries = %v, want [%v]", e, merged.Path)
+	}
+	m, ok := lookup(merged.Path).(*implementations.MergeBaseHandler)
+	if !ok || m == merged || m.MergePolicy() != domain.MergePolicyMax {
+		t.Errorf("handler %v not replaced by a 'max' policy copy", merged.Path)
+	}
+
+	// Handlers in use at reload time (i.e. in-flight requests) are left as is.
+	if !guarded.GetEnabled() || guarded.AllowedContainers != nil {
+		t.Errorf("original handler %v altered by reload", guarded.Path)
+	}
+	if merged.MergePolicy() != domain.MergePolicyLast {
+		t.Errorf("original handler %v altered by reload", merged.Path)
+	}
+
+	// Reconfigured copies share the lock of the original handler, so that host
+	// accesses through both of them are serialized.
+	m.Lock.Lock()
+	locked := make(chan struct{})
+	go func() {
+		merged.Lock.Lock()
+		close(locked)
+		merged.Lock.Unlock()
+	}()
+	select {
+	case <-locked:
+		t.Errorf("handler %v lock not shared with its reconfigured copy", merged.Path)
+	case <-time.After(100 * time.Millisecond):
+	}
+	m.Lock.Unlock()
+	<-locked
+
+	// Handlers disabled at setup time can be enabled.
+	err = hds.Reload(map[string]domain.HandlerConfig{
+		nmi.Path: {Enabled: &enabled},
+	})
+	if err != nil {
+		t.Fatalf("handlerService.Reload() error = %v", err)
+	}
+	n, ok := lookup(nmi.Path).(*implementations.GuardedIntBaseHandler)
+	if !ok || !n.GetEnabled() || n.Path != nmi.Path {
+		t.Errorf("handler %v not served after being enabled", nmi.Path)
+	}
+	if nmi.GetEnabled() {
+		t.Errorf("original handler %v altered by reload", nmi.Path)
+	}
+	want := []string{merged.Path, nmi.Path}
+	if e := hds.DirHandlerEntries("/proc/sys/kernel"); !reflect.DeepEqual(e, want) {
+		t.Errorf("dir /proc/sys/kernel entries = %v, want %v", e, want)
+	}
+
+	// Max-int handlers are reconfigured into equivalent merge handlers; the
+	// replacement keeps the dir-listing and persistence registrations.
+	err = hds.Reload(map[string]domain.HandlerConfig{
+		maxed.Path: {MergePolicy: domain.MergePolicyLast},
+	})
+	if err != nil {
+		t.Fatalf("handlerService.Reload() error = %v", err)
+	}
+	if h := lookup(maxed.Path); h.MergePolicy() != domain.MergePolicyLast {
+		t.Errorf("handler %v policy = %v, want last", maxed.Path, h.MergePolicy())
+	}
+	if e := hds.DirHandlerEntries("/proc/sys/fs"); !reflect.DeepEqual(e, []string{maxed.Path}) {
+		t.Errorf("dir /proc/sys/fs entries = %v, want [%v]", e, maxed.Path)
+	}
+	css.AssertNumberOfCalls(t, "RegisterPersistentPath", 2)
+
+	// Invalid configurations are rejected wholesale.
+	invalid := []map[string]domain.HandlerConfig{
+		{
+			guarded.Path:        {Enabled: &enabled},
+			"/proc/sys/foo/bar": {Enabled: &enabled},
+		},
+		{
+			guarded.Path: {Enabled: &enabled},
+			common.Path:  {MergePolicy: domain.MergePolicyMax},
+		},
+		{
+			guarded.Path: {Enabled: &enabled},
+			merged.Path:  {AllowedContainers: []string{"c2"}},
+		},
+		{
+			guarded.Path: {Enabled: &enabled},
+			merged.Path:  {MergePolicy: "foo"},
+		},
+		{
+			guarded.Path: {Enabled: &enabled},
+			merged.Path:  {WriteMode: domain.WriteModePassthrough},
+		},
+		{
+			guarded.Path: {Enabled: &enabled},
+			common.Path:  {WriteMode: domain.WriteModePassthrough},
+		},
+	}
+	for i, cfg := range invalid {
+		if err := hds.Reload(cfg); err == nil {
+			t.Errorf("handlerService.Reload() config %d accepted, want error", i+1)
+		}
+	}
+	if h := lookup(guarded.Path); h != common {
+		t.Errorf("handler %v enabled by a rejected reload", guarded.Path)
+	}
+
+	// Re-enable the guarded handler, lift its write restriction and switch it
+	// to the 'enforce-max' write mode.
+	err = hds.Reload(map[string]domain.HandlerConfig{
+		guarded.Path: {
+			Enabled:           &enabled,
+			WriteMode:         domain.WriteModeEnforceMax,
+			AllowedContainers: []string{},
+		},
+	})
+	if err != nil {
+		t.Fatalf("handlerService.Reload() error = %v", err)
+	}
+
+	g, ok := lookup(guarded.Path).(*implementations.GuardedIntBaseHandler)
+	if !ok || !g.GetEnabled() || len(g.AllowedContainers) != 0 {
+		t.Errorf("handler %v not served with an empty allow-list after being enabled",
+			guarded.Path)
+	}
+	if !ok || g.Passthrough || !g.EnforceMax || g.MergePolicy() != domain.MergePolicyMax {
+		t.Errorf("handler %v not served in 'enforce-max' write mode", guarded.Path)
+	}
+}
+
+func TestHandlerService_HealthCheck(t *testing.T) {
+
+	// Disable log generation during UT.
+	logrus.SetOutput(ioutil.Discard)
+
+	ios := sysio.NewIOService(domain.IOMemFileService)
+	prs := process.NewProcessService()
+	prs.Setup(ios)
+
+	// Host's user-ns inode must be available during handler-service setup.
+	prs.ProcessCreate(uint32(os.Getpid()), 0, 0).CreateNsInodes(1)
+
+	tests := []struct {
+		name    string
+		sendErr error
+		resMsg  *domain.NSenterMessage
+		wantErr bool
+	}{
+		{
+			//
+			// Test-case 1: Successful round-trip.
+			//
+			name:    "1",
+			sendErr: nil,
+			resMsg: &domain.NSenterMessage{
+				Type:    domain.LookupResponse,
+				Payload: domain.FileInfo{Fname: "sys"},
+			},
+			wantErr: false,
+		},
+		{
+			//
+			// Test-case 2: Agent can't be launched.
+			//
+			name:    "2",
+			sendErr: errors.New("Error launching nsenter agent"),
+			resMsg:  nil,
+			wantErr: true,
+		},
+		{
+			//
+			// Test-case 3: Agent reporting an error.
+			//
+			name:    "3",
+			sendErr: nil,
+			resMsg: &domain.NSenterMessage{
+				Type:    domain.ErrorResponse,
+				Payload: fuse.IOerror{Code: syscall.EIO},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nss := &mocks.NSenterServiceIface{}
+
+			hds := handler.NewHandlerService()
+			hds.Setup(nil, false, nil, nss, prs, ios)
+
+			req := &nsenter.NSenterEvent{
+				Pid:       uint32(os.Getpid()),
+				Namespace: &domain.AllNSsButUser,
+				ReqMsg: &domain.NSenterMessage{
+					Type:    domain.LookupRequest,
+					Payload: &domain.LookupPayload{Entry: "/proc/sys"},
+				},
+			}
+			nss.On(
+				"NewEvent",
+				req.Pid,
+				req.Namespace,
+				req.ReqMsg,
+				(*domain.NSenterMessage)(nil),
+				false).Return(req)
+			nss.On("SendRequestEvent", req).Return(tt.sendErr)
+			nss.On("ReceiveResponseEvent", req).Return(tt.resMsg)
+
+			if err := hds.HealthCheck(); (err != nil) != tt.wantErr {
+				t.Errorf("handlerService.HealthCheck() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"errors"
+	"io"
+	"os"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+)
+
+//
+// Base handler for read-only emulated files whose content is synthesized by
+// sysbox-fs (e.g. /proc/uptime) rather than fetched from the host FS.
+//
+// Handlers relying on this type are expected to be flagged as ReadOnly, and only
+// need to provide the Compute function; the base takes care of the offset
+// slicing of the generated content, as well as of its caching when the
+// Cacheable attribute is set. In that case content is computed once per
+// container (or once per namespace of the reading process, if NsKey is set) and
+// served from the container's data store in subsequent reads.
+//
+// As tools may rely on st_size to figure out how much to read, the size of the
+// generated content (at the time of the lookup) is reported in Lookup() and
+// Getattr(), rather than the one of the host FS file.
+//
+type ComputedHandler struct {
+	domain.HandlerBase
+	Compute func(
+		hs domain.HandlerServiceIface,
+		n domain.IOnodeIface,
+		req *domain.HandlerRequest) (string, error)
+}
+
+func (h *ComputedHandler) Lookup(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (os.FileInfo, error) {
+
+	logrus.Debugf("Executing Lookup() method on %v handler", h.Name)
+
+	info, err := n.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	// Content can only be generated on behalf of a registered sys container.
+	if req.Container == nil {
+		return info, nil
+	}
+
+	data, err := h.content(n, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return sizedFileInfo(info, int64(len(data))), nil
+}
+
+func (h *ComputedHandler) Getattr(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (*syscall.Stat_t, error) {
+
+	logrus.Debugf("Executing Getattr() method on %v handler", h.Name)
+
+	info, err := h.Lookup(n, req)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, _ := info.Sys().(*syscall.Stat_t)
+
+	return stat, nil
+}
+
+func (h *ComputedHandler) Open(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) error {
+
+	logrus.Debugf("Executing %v Open() method", h.Name)
+
+	flags := n.OpenFlags()
+	if flags != syscall.O_RDONLY {
+		return fuse.IOerror{Code: syscall.EACCES}
+	}
+
+	if err := n.Open(); err != nil {
+		logrus.Debugf("Error opening file %v", h.Path)
+		return fuse.IOerror{Code: syscall.EIO}
+	}
+
+	return nil
+}
+
+func (h *ComputedHandler) Close(n domain.IOnodeIface) error {
+
+	logrus.Debugf("Executing Close() method on %v handler", h.Name)
+
+	if err := n.Close(); err != nil {
+		logrus.Debugf("Error closing file %v", h.Path)
+		return fuse.IOerror{Code: syscall.EIO}
+	}
+
+	return nil
+}
+
+func (h *ComputedHandler) Read(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Read() method", h.Name)
+
+	cntr := req.Container
+
+	// Ensure operation is generated from within a registered sys container.
+	if cntr == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return 0, errors.New("Container not found")
+	}
+
+	data, err := h.content(n, req)
+	if err != nil {
+		return 0, err
+	}
+
+	if req.Offset >= int64(len(data)) {
+		return 0, io.EOF
+	}
+
+	return copyResultBuffer(req.Data, []byte(data[req.Offset:]))
+}
+
+func (h *ComputedHandler) Write(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	return 0, nil
+}
+
+func (h *ComputedHandler) ReadDirAll(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) ([]os.FileInfo, error) {
+
+	return nil, nil
+}
+
+func (h *ComputedHandler) GetName() string {
+	return h.Name
+}
+
+func (h *ComputedHandler) GetPath() string {
+	return h.Path
+}
+
+func (h *ComputedHandler) GetEnabled() bool {
+	return h.Enabled
+}
+
+func (h *ComputedHandler) GetType() domain.HandlerType {
+	return h.Type
+}
+
+func (h *ComputedHandler) GetService() domain.HandlerServiceIface {
+	return h.Service
+}
+
+func (h *ComputedHandler) SetEnabled(val bool) {
+	h.Enabled = val
+}
+
+func (h *ComputedHandler) SetService(hs domain.HandlerServiceIface) {
+	h.Service = hs
+}
+
+// content returns the data to serve for the passed request, either by making
+// use of the Compute function or by picking it from the container's data store.
+func (h *ComputedHandler) content(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (string, error) {
+
+	if h.Compute == nil {
+		logrus.Errorf("No compute function defined for %v handler", h.Name)
+		return "", fuse.IOerror{Code: syscall.EIO}
+	}
+
+	if !h.IsCacheable(req) {
+		return h.Compute(h.Service, n, req)
+	}
+
+	cntr := req.Container
+	path := n.Path()
+
+	name, err := nsDataKey(h.Service, h.NsKey, n, req)
+	if err != nil {
+		logrus.Errorf("Could not obtain the %v namespace of pid %d: %v",
+			h.NsKey, req.Pid, err)
+		return "", fuse.IOerror{Code: syscall.EIO}
+	}
+
+	cntr.Lock()
+	defer cntr.Unlock()
+
+	if data, ok := cntr.Data(path, name); ok {
+		return data, nil
+	}
+
+	data, err := h.Compute(h.Service, n, req)
+	if err != nil {
+		return "", err
+	}
+	cntr.SetData(path, name, data)
+
+	return data, nil
+}
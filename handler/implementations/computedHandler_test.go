@@ -0,0 +1,471 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations_test
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/handler/implementations"
+	"github.com/nestybox/sysbox-fs/mocks"
+	"github.com/nestybox/sysbox-fs/nsenter"
+)
+
+func TestComputedHandler_Read(t *testing.T) {
+
+	// Compute function generating different content on every invocation.
+	var calls int
+	compute := func(
+		hs domain.HandlerServiceIface,
+		n domain.IOnodeIface,
+		req *domain.HandlerRequest) (string, error) {
+		calls++
+		return "call " + strconv.Itoa(calls) + "\n", nil
+	}
+
+	tests := []struct {
+		name      string
+		cacheable bool
+		offset    int64
+		want      []string
+		wantErr   error
+	}{
+		{
+			//
+			// Test-case 1: Non-cacheable content. Every read must produce a
+			// freshly computed value.
+			//
+			name:      "1",
+			cacheable: false,
+			want:      []string{"call 1\n", "call 2\n"},
+		},
+		{
+			//
+			// Test-case 2: Cacheable content. Only the first read must invoke the
+			// compute function.
+			//
+			name:      "2",
+			cacheable: true,
+			want:      []string{"call 1\n", "call 1\n"},
+		},
+		{
+			//
+			// Test-case 3: Non-zero offset. Content must be sliced accordingly.
+			//
+			name:   "3",
+			offset: 5,
+			want:   []string{"1\n", "2\n"},
+		},
+		{
+			//
+			// Test-case 4: Offset beyond the content length (EOF).
+			//
+			name:    "4",
+			offset:  7,
+			want:    []string{""},
+			wantErr: io.EOF,
+		},
+	}
+
+	//
+	// Testcase executions.
+	//
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = 0
+
+			h := &implementations.ComputedHandler{
+				HandlerBase: domain.HandlerBase{
+					Name:      "testComputed",
+					Path:      "/proc/testComputed",
+					Type:      domain.NODE_SUBSTITUTION,
+					Enabled:   true,
+					Cacheable: tt.cacheable,
+					Service:   hds,
+				},
+				Compute: compute,
+			}
+
+			n := ios.NewIOnode("testComputed", "/proc/testComputed", 0)
+			cntr := css.ContainerCreate("c1", uint32(1001), time.Time{}, 231072,
+				65535, 231072, 65535, nil, nil, false, nil)
+
+			for _, want := range tt.want {
+				buf := make([]byte, 32)
+				rn, err := h.Read(n, &domain.HandlerRequest{
+					Pid:       cntr.InitPid(),
+					Offset:    tt.offset,
+					Data:      buf,
+					Container: cntr,
+				})
+				if err != tt.wantErr {
+					t.Fatalf("ComputedHandler.Read() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if got := string(buf[:rn]); got != want {
+					t.Errorf("ComputedHandler.Read() = %q, want %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestComputedHandler_LookupSize(t *testing.T) {
+
+	h := &implementations.ComputedHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "testComputed",
+			Path:      "/proc/testComputed",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+			Service:   hds,
+		},
+		Compute: func(
+			hs domain.HandlerServiceIface,
+			n domain.IOnodeIface,
+			req *domain.HandlerRequest) (string, error) {
+			return "line 1\nline 2\nline 3\n", nil
+		},
+	}
+
+	// Host FS file is empty (as procfs ones are).
+	n := ios.NewIOnode("testComputed", "/proc/testComputed", 0)
+	if err := n.WriteFile(nil); err != nil {
+		t.Fatalf("Could not initialize host file: %v", err)
+	}
+	cntr := css.ContainerCreate("c1", uint32(1001), time.Time{}, 231072,
+		65535, 231072, 65535, nil, nil, false, nil)
+	req := &domain.HandlerRequest{Pid: cntr.InitPid(), Container: cntr}
+
+	info, err := h.Lookup(n, req)
+	if err != nil {
+		t.Fatalf("ComputedHandler.Lookup() error = %v", err)
+	}
+	stat, err := h.Getattr(n, req)
+	if err != nil {
+		t.Fatalf("ComputedHandler.Getattr() error = %v", err)
+	}
+
+	buf := make([]byte, 64)
+	rn, err := h.Read(n, &domain.HandlerRequest{
+		Pid:       cntr.InitPid(),
+		Data:      buf,
+		Container: cntr,
+	})
+	if err != nil {
+		t.Fatalf("ComputedHandler.Read() error = %v", err)
+	}
+
+	// Reported sizes must match the content being served.
+	if info.Size() != int64(rn) {
+		t.Errorf("ComputedHandler.Lookup() size = %v, want %v", info.Size(), rn)
+	}
+	if sys, ok := info.Sys().(*syscall.Stat_t); !ok || sys.Size != int64(rn) {
+		t.Errorf("ComputedHandler.Lookup() stat = %+v, want size %v", info.Sys(), rn)
+	}
+	if stat == nil || stat.Size != int64(rn) {
+		t.Errorf("ComputedHandler.Getattr() = %+v, want size %v", stat, rn)
+	}
+}
+
+func TestComputedHandler_NsKey(t *testing.T) {
+
+	// Compute function generating different content on every invocation.
+	var calls int
+	h := &implementations.ComputedHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "testComputed",
+			Path:      "/proc/testComputed",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+			NsKey:     domain.NStypePid,
+			Service:   hds,
+		},
+		Compute: func(
+			hs domain.HandlerServiceIface,
+			n domain.IOnodeIface,
+			req *domain.HandlerRequest) (string, error) {
+			calls++
+			return "call " + strconv.Itoa(calls) + "\n", nil
+		},
+	}
+
+	n := ios.NewIOnode("testComputed", "/proc/testComputed", 0)
+	cntr := css.ContainerCreate("c1", uint32(6001), time.Time{}, 231072,
+		65535, 231072, 65535, nil, nil, false, nil)
+
+	// Container's init process (6001) and two processes (6002, 6003) of a
+	// nested container sharing a pid-ns of their own.
+	prs.ProcessCreate(6001, 0, 0).CreateNsInodes(61001)
+	prs.ProcessCreate(6002, 0, 0).CreateNsInodes(61002)
+	prs.ProcessCreate(6003, 0, 0).CreateNsInodes(61002)
+
+	tests := []struct {
+		name string
+		pid  uint32
+		want string
+	}{
+		{
+			//
+			// Test-case 1: First reader within the container's pid-ns.
+			//
+			name: "1",
+			pid:  6001,
+			want: "call 1\n",
+		},
+		{
+			//
+			// Test-case 2: First reader within the nested pid-ns. Content must
+			// be computed afresh.
+			//
+			name: "2",
+			pid:  6002,
+			want: "call 2\n",
+		},
+		{
+			//
+			// Test-case 3: Second reader within the nested pid-ns. Content
+			// cached for that namespace must be served.
+			//
+			name: "3",
+			pid:  6003,
+			want: "call 2\n",
+		},
+		{
+			//
+			// Test-case 4: Container's pid-ns content must be preserved.
+			//
+			name: "4",
+			pid:  6001,
+			want: "call 1\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := make([]byte, 32)
+			rn, err := h.Read(n, &domain.HandlerRequest{
+				Pid:       tt.pid,
+				Data:      buf,
+				Container: cntr,
+			})
+			if err != nil {
+				t.Fatalf("ComputedHandler.Read() error = %v", err)
+			}
+			if got := string(buf[:rn]); got != tt.want {
+				t.Errorf("ComputedHandler.Read() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNsFileCompute(t *testing.T) {
+
+	compute := implementations.NsFileCompute(domain.NStypePid)
+	n := ios.NewIOnode("loadavg", "/proc/loadavg", 0)
+	cntr := css.ContainerCreate("c1", uint32(6101), time.Time{}, 231072,
+		65535, 231072, 65535, nil, nil, false, nil)
+
+	// /proc/loadavg as seen within the pid-ns of each reader.
+	expectRead := func(pid uint32, content string) {
+		nsenterEventReq := &nsenter.NSenterEvent{
+			Pid:       pid,
+			Namespace: &[]domain.NStype{domain.NStypePid},
+			ReqMsg: &domain.NSenterMessage{
+				Type: domain.ReadFileRequest,
+				Payload: &domain.ReadFilePayload{
+					File: "/proc/loadavg",
+					Raw:  true,
+				},
+			},
+		}
+
+		nss.On(
+			"NewEvent",
+			pid,
+			&[]domain.NStype{domain.NStypePid},
+			nsenterEventReq.ReqMsg,
+			(*domain.NSenterMessage)(nil),
+			false).Return(nsenterEventReq)
+
+		nss.On("SendRequestEvent", nsenterEventReq).Return(nil)
+		nss.On("ReceiveResponseEvent", nsenterEventReq).Return(
+			&domain.NSenterMessage{
+				Type:    domain.ReadFileResponse,
+				Payload: content,
+			})
+	}
+	expectRead(6101, "0.10 0.20 0.30 2/120 4242\n")
+	expectRead(6102, "0.10 0.20 0.30 1/3 17\n")
+
+	tests := []struct {
+		name string
+		pid  uint32
+		want string
+	}{
+		{
+			//
+			// Test-case 1: Reader within the container's pid-ns.
+			//
+			name: "1",
+			pid:  6101,
+			want: "0.10 0.20 0.30 2/120 4242\n",
+		},
+		{
+			//
+			// Test-case 2: Reader within a nested pid-ns. Its own view of the
+			// tasks / last-pid fields is expected.
+			//
+			name: "2",
+			pid:  6102,
+			want: "0.10 0.20 0.30 1/3 17\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := compute(hds, n, &domain.HandlerRequest{Pid: tt.pid, Container: cntr})
+			if err != nil {
+				t.Fatalf("NsFileCompute() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("NsFileCompute() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcUptime(t *testing.T) {
+
+	// Host FS initial state: 4 cpus idling 4000s out of 1000s of uptime.
+	initFile := func(path, content string) {
+		if err := ios.NewIOnode("", path, 0).WriteFile([]byte(content)); err != nil {
+			t.Fatalf("Could not initialize host file %v: %v", path, err)
+		}
+	}
+	initFile("/proc/uptime", "1000.00 4000.00\n")
+	initFile("/sys/devices/system/cpu/online", "0-3")
+
+	tests := []struct {
+		name       string
+		pid        uint32
+		hostUptime bool
+		prepare    func()
+		wantUptime float64
+		wantIdle   float64
+	}{
+		{
+			//
+			// Test-case 1: Container's uptime. Idle time scaled to the
+			// container's lifetime.
+			//
+			name:       "1",
+			pid:        5001,
+			hostUptime: false,
+			wantUptime: 100,
+			wantIdle:   400,
+		},
+		{
+			//
+			// Test-case 2: Host's uptime. Host's idle time reported as is.
+			//
+			name:       "2",
+			pid:        5002,
+			hostUptime: true,
+			wantUptime: 1000,
+			wantIdle:   4000,
+		},
+		{
+			//
+			// Test-case 3: Container restricted to half of the host cpus.
+			//
+			name:       "3",
+			pid:        5003,
+			hostUptime: true,
+			prepare: func() {
+				initFile("/proc/5003/cgroup", "0::/docker/u3\n")
+				initFile("/sys/fs/cgroup/docker/u3/cpuset.cpus.effective", "0-1")
+			},
+			wantUptime: 1000,
+			wantIdle:   2000,
+		},
+		{
+			//
+			// Test-case 4: Container's uptime on half of the host cpus.
+			//
+			name:       "4",
+			pid:        5004,
+			hostUptime: false,
+			prepare: func() {
+				initFile("/proc/5004/cgroup", "0::/docker/u4\n")
+				initFile("/sys/fs/cgroup/docker/u4/cpuset.cpus.effective", "2-3")
+			},
+			wantUptime: 100,
+			wantIdle:   200,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepare != nil {
+				tt.prepare()
+			}
+
+			hs := &mocks.HandlerServiceIface{}
+			hs.On("IOService").Return(ios)
+			hs.On("HostUptime").Return(tt.hostUptime)
+
+			ctime := time.Now().Add(-100 * time.Second)
+			cntr := css.ContainerCreate("c1", tt.pid, ctime, 231072, 65535,
+				231072, 65535, nil, nil, false, nil)
+			n := ios.NewIOnode("uptime", "/proc/uptime", 0)
+
+			data, err := implementations.ProcUptime(hs, n,
+				&domain.HandlerRequest{Pid: tt.pid, Container: cntr})
+			if err != nil {
+				t.Fatalf("ProcUptime() error = %v", err)
+			}
+
+			fields := strings.Fields(data)
+			if len(fields) != 2 || !strings.HasSuffix(data, "\n") {
+				t.Fatalf("ProcUptime() = %q, want two columns", data)
+			}
+
+			uptime, err := strconv.ParseFloat(fields[0], 64)
+			if err != nil {
+				t.Fatalf("ProcUptime() invalid uptime %q: %v", fields[0], err)
+			}
+			idle, err := strconv.ParseFloat(fields[1], 64)
+			if err != nil {
+				t.Fatalf("ProcUptime() invalid idle %q: %v", fields[1], err)
+			}
+
+			if uptime < tt.wantUptime || uptime > tt.wantUptime*1.1 {
+				t.Errorf("ProcUptime() uptime = %v, want ~%v", uptime, tt.wantUptime)
+			}
+			if idle < tt.wantIdle || idle > tt.wantIdle*1.1 {
+				t.Errorf("ProcUptime() idle = %v, want ~%v", idle, tt.wantIdle)
+			}
+		})
+	}
+}
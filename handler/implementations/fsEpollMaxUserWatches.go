@@ -0,0 +1,223 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+)
+
+//
+// /proc/sys/fs/epoll/max_user_watches handler
+//
+// The kernel enforces this limit on a per-user basis (number of epoll watches
+// registered by each host user), yet the knob itself is a global one. Thereby,
+// values written by sys containers are accounted for on behalf of the host user
+// owning the container (i.e. the host uid its root user is mapped to), and the
+// max across all of them is pushed down to the host kernel, so that no container
+// can lower the limit enforced on the others. Containers sharing the same host
+// uid range display the same value.
+//
+type FsEpollMaxUserWatchesHandler struct {
+	domain.HandlerBase
+
+	mu       sync.Mutex
+	userVals map[uint32]string // value written on behalf of each host uid
+}
+
+func (h *FsEpollMaxUserWatchesHandler) Lookup(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (os.FileInfo, error) {
+
+	logrus.Debugf("Executing Lookup() method on %v handler", h.Name)
+
+	return n.Stat()
+}
+
+func (h *FsEpollMaxUserWatchesHandler) Getattr(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (*syscall.Stat_t, error) {
+
+	logrus.Debugf("Executing Getattr() method on %v handler", h.Name)
+
+	return nil, nil
+}
+
+func (h *FsEpollMaxUserWatchesHandler) Open(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) error {
+
+	logrus.Debugf("Executing %v Open() method\n", h.Name)
+
+	return h.merger().open(n)
+}
+
+func (h *FsEpollMaxUserWatchesHandler) Close(n domain.IOnodeIface) error {
+
+	logrus.Debugf("Executing Close() method on %v handler", h.Name)
+
+	return h.merger().close(n)
+}
+
+func (h *FsEpollMaxUserWatchesHandler) Read(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Read() method", h.Name)
+
+	if req.Offset > 0 {
+		return 0, io.EOF
+	}
+
+	cntr := req.Container
+
+	// Ensure operation is generated from within a registered sys container.
+	if cntr == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return 0, errors.New("Container not found")
+	}
+
+	data, ok := h.userVal(hostUid(cntr))
+	if !ok {
+		var err error
+
+		cntr.CacheMiss()
+		data, err = h.merger().fetch(n)
+		if err != nil {
+			return 0, err
+		}
+	} else {
+		cntr.CacheHit()
+	}
+
+	data += "\n"
+
+	return copyResultBuffer(req.Data, []byte(data))
+}
+
+func (h *FsEpollMaxUserWatchesHandler) Write(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Write() method", h.Name)
+
+	m := h.merger()
+	cntr := req.Container
+
+	newVal, err := m.parse(string(req.Data))
+	if err != nil {
+		logrus.Errorf("Unsupported value %q for %v: %v", req.Data, h.Path, err)
+		return 0, fuse.IOerror{Code: syscall.EINVAL}
+	}
+
+	// Ensure operation is generated from within a registered sys container.
+	if cntr == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return 0, errors.New("Container not found")
+	}
+
+	if err := m.push(n, newVal); err != nil {
+		return 0, err
+	}
+
+	h.setUserVal(hostUid(cntr), newVal)
+
+	return len(req.Data), nil
+}
+
+func (h *FsEpollMaxUserWatchesHandler) ReadDirAll(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) ([]os.FileInfo, error) {
+
+	return nil, nil
+}
+
+func (h *FsEpollMaxUserWatchesHandler) MergePolicy() domain.MergePolicy {
+	return domain.MergePolicyMax
+}
+
+func (h *FsEpollMaxUserWatchesHandler) merger() *hostMerger {
+	return &hostMerger{
+		hb:     &h.HandlerBase,
+		policy: domain.MergePolicyMax,
+		vtype:  domain.ValueTypeInt,
+		minVal: 1,
+	}
+}
+
+func (h *FsEpollMaxUserWatchesHandler) userVal(uid uint32) (string, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	val, ok := h.userVals[uid]
+
+	return val, ok
+}
+
+func (h *FsEpollMaxUserWatchesHandler) setUserVal(uid uint32, val string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.userVals == nil {
+		h.userVals = make(map[uint32]string)
+	}
+	h.userVals[uid] = val
+}
+
+// hostUid returns the host uid to which the root user of the passed container is
+// mapped.
+func hostUid(cntr domain.ContainerIface) uint32 {
+	return cntr.UID()
+}
+
+func (h *FsEpollMaxUserWatchesHandler) GetName() string {
+	return h.Name
+}
+
+func (h *FsEpollMaxUserWatchesHandler) GetPath() string {
+	return h.Path
+}
+
+func (h *FsEpollMaxUserWatchesHandler) GetEnabled() bool {
+	return h.Enabled
+}
+
+func (h *FsEpollMaxUserWatchesHandler) GetType() domain.HandlerType {
+	return h.Type
+}
+
+func (h *FsEpollMaxUserWatchesHandler) GetService() domain.HandlerServiceIface {
+	return h.Service
+}
+
+func (h *FsEpollMaxUserWatchesHandler) SetEnabled(val bool) {
+	h.Enabled = val
+}
+
+func (h *FsEpollMaxUserWatchesHandler) SetService(hs domain.HandlerServiceIface) {
+	h.Service = hs
+}
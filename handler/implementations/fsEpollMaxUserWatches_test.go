@@ -0,0 +1,158 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations_test
+
+import (
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+	"github.com/nestybox/sysbox-fs/handler/implementations"
+)
+
+func newEpollWatchesHandler(t *testing.T, hostVal string) (
+	*implementations.FsEpollMaxUserWatchesHandler,
+	domain.IOnodeIface) {
+
+	h := &implementations.FsEpollMaxUserWatchesHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "fsEpollMaxUserWatches",
+			Path:      "/proc/sys/fs/epoll/max_user_watches",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+			Service:   hds,
+		},
+	}
+
+	n := ios.NewIOnode("max_user_watches", "/proc/sys/fs/epoll/max_user_watches", 0)
+	if err := n.WriteFile([]byte(hostVal)); err != nil {
+		t.Fatalf("Could not initialize host file: %v", err)
+	}
+
+	return h, n
+}
+
+func epollWatchesRead(
+	t *testing.T,
+	h *implementations.FsEpollMaxUserWatchesHandler,
+	n domain.IOnodeIface,
+	cntr domain.ContainerIface) string {
+
+	buf := make([]byte, 32)
+	rn, err := h.Read(n, &domain.HandlerRequest{
+		Pid:       cntr.InitPid(),
+		Data:      buf,
+		Container: cntr,
+	})
+	if err != nil {
+		t.Fatalf("FsEpollMaxUserWatchesHandler.Read() error = %v", err)
+	}
+
+	return strings.TrimSpace(string(buf[:rn]))
+}
+
+func epollWatchesWrite(
+	h *implementations.FsEpollMaxUserWatchesHandler,
+	n domain.IOnodeIface,
+	cntr domain.ContainerIface,
+	val string) error {
+
+	_, err := h.Write(n, &domain.HandlerRequest{
+		Pid:       cntr.InitPid(),
+		Data:      []byte(val + "\n"),
+		Container: cntr,
+	})
+
+	return err
+}
+
+func TestFsEpollMaxUserWatchesHandler_Max(t *testing.T) {
+
+	h, n := newEpollWatchesHandler(t, "100000")
+
+	c1 := css.ContainerCreate("c1", uint32(1001), time.Time{}, 231072, 65535, 231072, 65535, nil, nil, false, nil)
+	c2 := css.ContainerCreate("c2", uint32(2001), time.Time{}, 296608, 65535, 296608, 65535, nil, nil, false, nil)
+
+	// Non-positive values must be rejected (EINVAL).
+	for _, val := range []string{"0", "-1", "foo"} {
+		if err := epollWatchesWrite(h, n, c1, val); err != (fuse.IOerror{Code: syscall.EINVAL}) {
+			t.Errorf("FsEpollMaxUserWatchesHandler.Write(%v) error = %v, want %v",
+				val, err, fuse.IOerror{Code: syscall.EINVAL})
+		}
+	}
+
+	// Higher values are pushed down to the host ...
+	if err := epollWatchesWrite(h, n, c1, "200000"); err != nil {
+		t.Fatalf("FsEpollMaxUserWatchesHandler.Write() error = %v", err)
+	}
+
+	// ... but lower ones don't override them.
+	if err := epollWatchesWrite(h, n, c2, "150000"); err != nil {
+		t.Fatalf("FsEpollMaxUserWatchesHandler.Write() error = %v", err)
+	}
+
+	if got, _ := n.ReadLine(); got != "200000" {
+		t.Errorf("FsEpollMaxUserWatchesHandler.Write() host value = %v, want %v",
+			got, "200000")
+	}
+
+	// Each container displays the value it wrote.
+	if got := epollWatchesRead(t, h, n, c1); got != "200000" {
+		t.Errorf("FsEpollMaxUserWatchesHandler.Read() = %v, want %v", got, "200000")
+	}
+	if got := epollWatchesRead(t, h, n, c2); got != "150000" {
+		t.Errorf("FsEpollMaxUserWatchesHandler.Read() = %v, want %v", got, "150000")
+	}
+}
+
+func TestFsEpollMaxUserWatchesHandler_UidTranslation(t *testing.T) {
+
+	h, n := newEpollWatchesHandler(t, "100000")
+
+	// c1 and c2 share the same host uid range, hence the same host user. c3 is
+	// mapped to a different one.
+	c1 := css.ContainerCreate("c1", uint32(1001), time.Time{}, 231072, 65535, 231072, 65535, nil, nil, false, nil)
+	c2 := css.ContainerCreate("c2", uint32(2001), time.Time{}, 231072, 65535, 231072, 65535, nil, nil, false, nil)
+	c3 := css.ContainerCreate("c3", uint32(3001), time.Time{}, 296608, 65535, 296608, 65535, nil, nil, false, nil)
+
+	if err := epollWatchesWrite(h, n, c1, "300000"); err != nil {
+		t.Fatalf("FsEpollMaxUserWatchesHandler.Write() error = %v", err)
+	}
+
+	// The value written by c1 is the one of its host user, and thereby, the one
+	// displayed by c2 too.
+	if got := epollWatchesRead(t, h, n, c2); got != "300000" {
+		t.Errorf("FsEpollMaxUserWatchesHandler.Read() = %v, want %v", got, "300000")
+	}
+
+	if err := epollWatchesWrite(h, n, c3, "120000"); err != nil {
+		t.Fatalf("FsEpollMaxUserWatchesHandler.Write() error = %v", err)
+	}
+
+	// c3's host user keeps its own value, whereas the one of c1/c2's user is
+	// left untouched.
+	if got := epollWatchesRead(t, h, n, c3); got != "120000" {
+		t.Errorf("FsEpollMaxUserWatchesHandler.Read() = %v, want %v", got, "120000")
+	}
+	if got := epollWatchesRead(t, h, n, c1); got != "300000" {
+		t.Errorf("FsEpollMaxUserWatchesHandler.Read() = %v, want %v", got, "300000")
+	}
+}
@@ -0,0 +1,376 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+)
+
+// This is a base handler for host-global kernel sysctls that consist of a single
+// integer value, and which sys containers must not be able to modify by default
+// (e.g. a container disabling the host's watchdogs). Reads always display the
+// host FS value, and writes are rejected (EPERM) unless the 'Passthrough'
+// attribute is enabled, in which case values within the [Min, Max] range are
+// pushed down to the host FS. Alternatively, the 'EnforceMax' attribute allows
+// writes that can only ever raise the host FS value (lower ones are accepted but
+// have no effect), so that no container can lower the setting the others rely
+// on. Likewise, the 'EnforceEnabled' attribute pins the resource to its enabled
+// (Max) value: writes setting it are pushed down to the host FS, and any other
+// value is rejected (EPERM). In either case, writes can be restricted to a set
+// of trusted containers through the 'AllowedContainers' attribute, which can be
+// made mandatory through the 'AllowListRequired' attribute. The mode
+// can be set at runtime through the handler's write-mode setting (see
+// Reconfigure()).
+
+type GuardedIntBaseHandler struct {
+	domain.HandlerBase
+
+	// Allow writes to reach the host FS.
+	Passthrough bool
+
+	// Allow writes to reach the host FS only when raising its value.
+	EnforceMax bool
+
+	// Allow writes to reach the host FS only when enabling (Max) the resource.
+	EnforceEnabled bool
+
+	// IDs of the only containers allowed to write (EPERM for the rest). Empty
+	// means that all containers are allowed, unless AllowListRequired is set.
+	AllowedContainers []string
+
+	// Deny the writes of every container not explicitly listed in
+	// AllowedContainers (i.e. an empty list allows none).
+	AllowListRequired bool
+
+	// Range of supported values.
+	Min int
+	Max int
+}
+
+func (h *GuardedIntBaseHandler) Lookup(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (os.FileInfo, error) {
+
+	logrus.Debugf("Executing Lookup() method on %v handler", h.Name)
+
+	return n.Stat()
+}
+
+func (h *GuardedIntBaseHandler) Getattr(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (*syscall.Stat_t, error) {
+
+	logrus.Debugf("Executing Getattr() method on %v handler", h.Name)
+
+	return nil, nil
+}
+
+func (h *GuardedIntBaseHandler) Open(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) error {
+
+	logrus.Debugf("Executing %v Open() method\n", h.Name)
+
+	flags := n.OpenFlags()
+	if flags != syscall.O_RDONLY && flags != syscall.O_WRONLY {
+		return fuse.IOerror{Code: syscall.EACCES}
+	}
+
+	if flags == syscall.O_WRONLY && !h.Writable() {
+		return fuse.IOerror{Code: syscall.EPERM}
+	}
+
+	if err := n.Open(); err != nil {
+		logrus.Debugf("Error opening file %v", h.Path)
+		return fuse.IOerror{Code: syscall.EIO}
+	}
+
+	return nil
+}
+
+func (h *GuardedIntBaseHandler) Close(n domain.IOnodeIface) error {
+
+	logrus.Debugf("Executing Close() method on %v handler", h.Name)
+
+	if err := n.Close(); err != nil {
+		logrus.Debugf("Error closing file %v", h.Path)
+		return fuse.IOerror{Code: syscall.EIO}
+	}
+
+	return nil
+}
+
+func (h *GuardedIntBaseHandler) Read(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Read() method", h.Name)
+
+	// We are dealing with a single integer element being read, so we can save
+	// some cycles by returning right away if offset is any higher than zero.
+	if req.Offset > 0 {
+		return 0, io.EOF
+	}
+
+	// Ensure operation is generated from within a registered sys container.
+	if req.Container == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return 0, errors.New("Container not found")
+	}
+
+	data, err := h.fetchFile(n)
+	if err != nil {
+		return 0, fuse.IOerror{Code: syscall.EIO}
+	}
+
+	data += "\n"
+
+	return copyResultBuffer(req.Data, []byte(data))
+}
+
+func (h *GuardedIntBaseHandler) Write(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Write() method", h.Name)
+
+	// Ensure operation is generated from within a registered sys container.
+	if req.Container == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return 0, errors.New("Container not found")
+	}
+
+	if !h.Writable() || !h.allowed(req.Container) {
+		return 0, fuse.IOerror{Code: syscall.EPERM}
+	}
+
+	newVal := strings.TrimSpace(string(req.Data))
+	newValInt, err := strconv.Atoi(newVal)
+	if err != nil {
+		return 0, fuse.IOerror{Code: syscall.EINVAL}
+	}
+
+	// Ensure that only proper values are allowed as per this resource's
+	// supported values.
+	if newValInt < h.Min || newValInt > h.Max {
+		return 0, fuse.IOerror{Code: syscall.EINVAL}
+	}
+
+	if h.EnforceEnabled && !h.Passthrough && newValInt != h.Max {
+		return 0, fuse.IOerror{Code: syscall.EPERM}
+	}
+
+	if h.EnforceMax && !h.Passthrough {
+		if err := h.pushMaxFile(n, newValInt); err != nil {
+			return 0, fuse.IOerror{Code: syscall.EIO}
+		}
+		return len(req.Data), nil
+	}
+
+	if err := h.pushFile(n, newValInt); err != nil {
+		return 0, fuse.IOerror{Code: syscall.EIO}
+	}
+
+	return len(req.Data), nil
+}
+
+func (h *GuardedIntBaseHandler) ReadDirAll(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) ([]os.FileInfo, error) {
+
+	return nil, nil
+}
+
+func (h *GuardedIntBaseHandler) fetchFile(n domain.IOnodeIface) (string, error) {
+
+	h.Lock.Lock()
+	defer h.Lock.Unlock()
+
+	// Read from host FS to extract the existing value.
+	curHostVal, err := n.ReadLine()
+	if err != nil && err != io.EOF {
+		logrus.Errorf("Could not read from file %v", h.Path)
+		return "", err
+	}
+
+	// High-level verification to ensure that format is the expected one.
+	_, err = strconv.Atoi(curHostVal)
+	if err != nil {
+		logrus.Errorf("Unexpected content read from file %v, error %v", h.Path, err)
+		return "", err
+	}
+
+	return curHostVal, nil
+}
+
+func (h *GuardedIntBaseHandler) pushFile(n domain.IOnodeIface, val int) error {
+
+	h.Lock.Lock()
+	defer h.Lock.Unlock()
+
+	msg := []byte(strconv.Itoa(val))
+	err := n.WriteFile(msg)
+	if err != nil && !h.Service.IgnoreErrors() {
+		logrus.Errorf("Could not write %d to file: %s", val, err)
+		return err
+	}
+
+	return nil
+}
+
+// pushMaxFile writes the passed value into the host FS only if it's higher than
+// the current one.
+func (h *GuardedIntBaseHandler) pushMaxFile(n domain.IOnodeIface, val int) error {
+
+	h.Lock.Lock()
+	defer h.Lock.Unlock()
+
+	curHostVal, err := n.ReadLine()
+	if err != nil && err != io.EOF {
+		logrus.Errorf("Could not read from file %v", h.Path)
+		return err
+	}
+	if cur, err := strconv.Atoi(curHostVal); err == nil && val <= cur {
+		return nil
+	}
+
+	msg := []byte(strconv.Itoa(val))
+	err = n.WriteFile(msg)
+	if err != nil && !h.Service.IgnoreErrors() {
+		logrus.Errorf("Could not write %d to file: %s", val, err)
+		return err
+	}
+
+	return nil
+}
+
+// allowed reports whether the passed container is entitled to write into the
+// host FS.
+func (h *GuardedIntBaseHandler) allowed(cntr domain.ContainerIface) bool {
+
+	if len(h.AllowedContainers) == 0 {
+		return !h.AllowListRequired
+	}
+
+	for _, id := range h.AllowedContainers {
+		if id == cntr.ID() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reconfigure returns a copy of the handler operating in the passed write mode
+// and restricting writes to the passed allow-list. The merge policy is implied
+// by the handler's mode, so it can't be changed on its own.
+func (h *GuardedIntBaseHandler) Reconfigure(
+	cfg domain.HandlerConfig) (domain.HandlerIface, error) {
+
+	if cfg.AcceptMissing != nil {
+		return nil, errors.New("missing-resource policy not supported")
+	}
+
+	nh := &GuardedIntBaseHandler{
+		HandlerBase:       h.HandlerBase.Clone(),
+		Passthrough:       h.Passthrough,
+		EnforceMax:        h.EnforceMax,
+		EnforceEnabled:    h.EnforceEnabled,
+		AllowedContainers: h.AllowedContainers,
+		AllowListRequired: h.AllowListRequired,
+		Min:               h.Min,
+		Max:               h.Max,
+	}
+
+	switch cfg.WriteMode {
+	case "":
+	case domain.WriteModeReadOnly:
+		nh.Passthrough, nh.EnforceMax, nh.EnforceEnabled = false, false, false
+	case domain.WriteModePassthrough:
+		nh.Passthrough, nh.EnforceMax, nh.EnforceEnabled = true, false, false
+	case domain.WriteModeEnforceMax:
+		nh.Passthrough, nh.EnforceMax, nh.EnforceEnabled = false, true, false
+	case domain.WriteModeEnforceEnabled:
+		nh.Passthrough, nh.EnforceMax, nh.EnforceEnabled = false, false, true
+	default:
+		return nil, errors.New("write mode not supported")
+	}
+
+	if cfg.MergePolicy != "" && cfg.MergePolicy != nh.MergePolicy() {
+		return nil, errors.New("merge policy not supported")
+	}
+
+	if cfg.AllowedContainers != nil {
+		nh.AllowedContainers = append([]string(nil), cfg.AllowedContainers...)
+	}
+
+	return nh, nil
+}
+
+func (h *GuardedIntBaseHandler) Writable() bool {
+	return h.Passthrough || h.EnforceMax || h.EnforceEnabled
+}
+
+func (h *GuardedIntBaseHandler) MergePolicy() domain.MergePolicy {
+	if h.Passthrough {
+		return domain.MergePolicyLast
+	}
+	if h.EnforceMax || h.EnforceEnabled {
+		return domain.MergePolicyMax
+	}
+	return domain.MergePolicyNone
+}
+
+func (h *GuardedIntBaseHandler) GetName() string {
+	return h.Name
+}
+
+func (h *GuardedIntBaseHandler) GetPath() string {
+	return h.Path
+}
+
+func (h *GuardedIntBaseHandler) GetEnabled() bool {
+	return h.Enabled
+}
+
+func (h *GuardedIntBaseHandler) GetType() domain.HandlerType {
+	return h.Type
+}
+
+func (h *GuardedIntBaseHandler) GetService() domain.HandlerServiceIface {
+	return h.Service
+}
+
+func (h *GuardedIntBaseHandler) SetEnabled(val bool) {
+	h.Enabled = val
+}
+
+func (h *GuardedIntBaseHandler) SetService(hs domain.HandlerServiceIface) {
+	h.Service = hs
+}
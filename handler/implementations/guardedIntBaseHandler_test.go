@@ -0,0 +1,887 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations_test
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+	"github.com/nestybox/sysbox-fs/handler/implementations"
+)
+
+func TestGuardedIntBaseHandler_Open(t *testing.T) {
+	type fields struct {
+		Passthrough bool
+	}
+
+	tests := []struct {
+		name       string
+		fields     fields
+		flags      int
+		wantErr    bool
+		wantErrVal error
+	}{
+		{
+			//
+			// Test-case 1: Read-only access in default mode. No errors expected.
+			//
+			name:    "1",
+			fields:  fields{Passthrough: false},
+			flags:   syscall.O_RDONLY,
+			wantErr: false,
+		},
+		{
+			//
+			// Test-case 2: Write access in default (read-only) mode (EPERM).
+			//
+			name:       "2",
+			fields:     fields{Passthrough: false},
+			flags:      syscall.O_WRONLY,
+			wantErr:    true,
+			wantErrVal: fuse.IOerror{Code: syscall.EPERM},
+		},
+		{
+			//
+			// Test-case 3: Write access in passthrough mode. No errors expected.
+			//
+			name:    "3",
+			fields:  fields{Passthrough: true},
+			flags:   syscall.O_WRONLY,
+			wantErr: false,
+		},
+	}
+
+	//
+	// Testcase executions.
+	//
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &implementations.GuardedIntBaseHandler{
+				HandlerBase: domain.HandlerBase{
+					Name:    "kernelWatchdog",
+					Path:    "/proc/sys/kernel/watchdog",
+					Type:    domain.NODE_SUBSTITUTION,
+					Enabled: true,
+					Service: hds,
+				},
+				Passthrough: tt.fields.Passthrough,
+				Min:         0,
+				Max:         1,
+			}
+
+			n := ios.NewIOnode("watchdog", "/proc/sys/kernel/watchdog", 0)
+			if err := n.WriteFile([]byte("1")); err != nil {
+				t.Fatalf("Could not initialize host file: %v", err)
+			}
+			n.SetOpenFlags(tt.flags)
+
+			err := h.Open(n, &domain.HandlerRequest{Pid: 1001})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GuardedIntBaseHandler.Open() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && tt.wantErrVal != nil && err != tt.wantErrVal {
+				t.Errorf("GuardedIntBaseHandler.Open() error = %v, wantErr %v, wantErrVal %v",
+					err, tt.wantErr, tt.wantErrVal)
+			}
+			if err == nil {
+				h.Close(n)
+			}
+		})
+	}
+}
+
+func TestGuardedIntBaseHandler_Write(t *testing.T) {
+	type fields struct {
+		Name        string
+		Path        string
+		Passthrough bool
+	}
+
+	// Host FS initial state.
+	const hostVal = "1"
+
+	cntr := css.ContainerCreate(
+		"c1",
+		uint32(1001),
+		time.Time{},
+		231072,
+		65535,
+		231072,
+		65535,
+		nil,
+		nil,
+		false,
+		nil)
+
+	tests := []struct {
+		name        string
+		fields      fields
+		data        string
+		wantErr     bool
+		wantErrVal  error
+		wantHostVal string
+	}{
+		{
+			//
+			// Test-case 1: Default (read-only) mode. Write must be rejected
+			// (EPERM) and host FS left untouched.
+			//
+			name:        "1",
+			fields:      fields{"kernelWatchdog", "/proc/sys/kernel/watchdog", false},
+			data:        "0\n",
+			wantErr:     true,
+			wantErrVal:  fuse.IOerror{Code: syscall.EPERM},
+			wantHostVal: hostVal,
+		},
+		{
+			//
+			// Test-case 2: Passthrough mode. Host FS must reflect the new value.
+			//
+			name:        "2",
+			fields:      fields{"kernelNmiWatchdog", "/proc/sys/kernel/nmi_watchdog", true},
+			data:        "0\n",
+			wantErr:     false,
+			wantHostVal: "0",
+		},
+		{
+			//
+			// Test-case 3: Passthrough mode with out-of-range value (EINVAL).
+			//
+			name:        "3",
+			fields:      fields{"kernelNmiWatchdog", "/proc/sys/kernel/nmi_watchdog", true},
+			data:        "2\n",
+			wantErr:     true,
+			wantErrVal:  fuse.IOerror{Code: syscall.EINVAL},
+			wantHostVal: hostVal,
+		},
+		{
+			//
+			// Test-case 4: ABI knob in default (read-only) mode. Write must be
+			// rejected (EPERM) and host FS left untouched.
+			//
+			name:        "4",
+			fields:      fields{"abiVsyscall32", "/proc/sys/abi/vsyscall32", false},
+			data:        "0\n",
+			wantErr:     true,
+			wantErrVal:  fuse.IOerror{Code: syscall.EPERM},
+			wantHostVal: hostVal,
+		},
+		{
+			//
+			// Test-case 5: ABI knob in passthrough mode. Host FS must reflect the
+			// new value.
+			//
+			name:        "5",
+			fields:      fields{"abiVsyscall32", "/proc/sys/abi/vsyscall32", true},
+			data:        "0\n",
+			wantErr:     false,
+			wantHostVal: "0",
+		},
+		{
+			//
+			// Test-case 6: ABI knob in passthrough mode with non-boolean value
+			// (EINVAL).
+			//
+			name:        "6",
+			fields:      fields{"abiVsyscall32", "/proc/sys/abi/vsyscall32", true},
+			data:        "2\n",
+			wantErr:     true,
+			wantErrVal:  fuse.IOerror{Code: syscall.EINVAL},
+			wantHostVal: hostVal,
+		},
+	}
+
+	//
+	// Testcase executions.
+	//
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &implementations.GuardedIntBaseHandler{
+				HandlerBase: domain.HandlerBase{
+					Name:    tt.fields.Name,
+					Path:    tt.fields.Path,
+					Type:    domain.NODE_SUBSTITUTION,
+					Enabled: true,
+					Service: hds,
+				},
+				Passthrough: tt.fields.Passthrough,
+				Min:         0,
+				Max:         1,
+			}
+
+			n := ios.NewIOnode("", tt.fields.Path, 0)
+			if err := n.WriteFile([]byte(hostVal)); err != nil {
+				t.Fatalf("Could not initialize host file: %v", err)
+			}
+
+			req := &domain.HandlerRequest{
+				Pid:       1001,
+				Data:      []byte(tt.data),
+				Container: cntr,
+			}
+
+			_, err := h.Write(n, req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GuardedIntBaseHandler.Write() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && tt.wantErrVal != nil && err != tt.wantErrVal {
+				t.Errorf("GuardedIntBaseHandler.Write() error = %v, wantErr %v, wantErrVal %v",
+					err, tt.wantErr, tt.wantErrVal)
+			}
+
+			gotHostVal, err := n.ReadLine()
+			if err != nil {
+				t.Fatalf("Could not read host file: %v", err)
+			}
+			if gotHostVal != tt.wantHostVal {
+				t.Errorf("GuardedIntBaseHandler.Write() host value = %v, want %v",
+					gotHostVal, tt.wantHostVal)
+			}
+		})
+	}
+}
+
+func TestGuardedIntBaseHandler_EnforceMax(t *testing.T) {
+
+	cntr := css.ContainerCreate(
+		"c1",
+		uint32(1001),
+		time.Time{},
+		231072,
+		65535,
+		231072,
+		65535,
+		nil,
+		nil,
+		false,
+		nil)
+
+	n := ios.NewIOnode("bpf_jit_enable", "/proc/sys/net/core/bpf_jit_enable", 0)
+	if err := n.WriteFile([]byte("1")); err != nil {
+		t.Fatalf("Could not initialize host file: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		enforceMax  bool
+		data        string
+		wantErr     bool
+		wantErrVal  error
+		wantHostVal string
+	}{
+		{
+			//
+			// Test-case 1: Default (read-only) mode. Write must be rejected
+			// (EPERM) and host FS left untouched.
+			//
+			name:        "1",
+			enforceMax:  false,
+			data:        "2\n",
+			wantErr:     true,
+			wantErrVal:  fuse.IOerror{Code: syscall.EPERM},
+			wantHostVal: "1",
+		},
+		{
+			//
+			// Test-case 2: Enforce-max mode with a higher value. Host FS must
+			// reflect the new value.
+			//
+			name:        "2",
+			enforceMax:  true,
+			data:        "2\n",
+			wantErr:     false,
+			wantHostVal: "2",
+		},
+		{
+			//
+			// Test-case 3: Enforce-max mode with a lower value. No errors
+			// expected, but host FS must keep the higher value.
+			//
+			name:        "3",
+			enforceMax:  true,
+			data:        "0\n",
+			wantErr:     false,
+			wantHostVal: "2",
+		},
+		{
+			//
+			// Test-case 4: Enforce-max mode with out-of-range value (EINVAL).
+			//
+			name:        "4",
+			enforceMax:  true,
+			data:        "3\n",
+			wantErr:     true,
+			wantErrVal:  fuse.IOerror{Code: syscall.EINVAL},
+			wantHostVal: "2",
+		},
+	}
+
+	//
+	// Testcase executions.
+	//
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &implementations.GuardedIntBaseHandler{
+				HandlerBase: domain.HandlerBase{
+					Name:    "coreBpfJitEnable",
+					Path:    "/proc/sys/net/core/bpf_jit_enable",
+					Type:    domain.NODE_SUBSTITUTION,
+					Enabled: true,
+					Service: hds,
+				},
+				EnforceMax: tt.enforceMax,
+				Min:        0,
+				Max:        2,
+			}
+
+			req := &domain.HandlerRequest{
+				Pid:       1001,
+				Data:      []byte(tt.data),
+				Container: cntr,
+			}
+
+			_, err := h.Write(n, req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GuardedIntBaseHandler.Write() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && tt.wantErrVal != nil && err != tt.wantErrVal {
+				t.Errorf("GuardedIntBaseHandler.Write() error = %v, wantErr %v, wantErrVal %v",
+					err, tt.wantErr, tt.wantErrVal)
+			}
+
+			gotHostVal, err := n.ReadLine()
+			if err != nil {
+				t.Fatalf("Could not read host file: %v", err)
+			}
+			if gotHostVal != tt.wantHostVal {
+				t.Errorf("GuardedIntBaseHandler.Write() host value = %v, want %v",
+					gotHostVal, tt.wantHostVal)
+			}
+		})
+	}
+}
+
+func TestGuardedIntBaseHandler_EnforceEnabled(t *testing.T) {
+
+	cntr := css.ContainerCreate(
+		"c1",
+		uint32(1001),
+		time.Time{},
+		231072,
+		65535,
+		231072,
+		65535,
+		nil,
+		nil,
+		false,
+		nil)
+
+	n := ios.NewIOnode("unprivileged_userns_clone",
+		"/proc/sys/kernel/unprivileged_userns_clone", 0)
+	if err := n.WriteFile([]byte("0")); err != nil {
+		t.Fatalf("Could not initialize host file: %v", err)
+	}
+
+	h := &implementations.GuardedIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:    "kernelUnprivUsernsClone",
+			Path:    "/proc/sys/kernel/unprivileged_userns_clone",
+			Type:    domain.NODE_SUBSTITUTION,
+			Enabled: true,
+			Service: hds,
+		},
+		EnforceEnabled: true,
+		Min:            0,
+		Max:            1,
+	}
+
+	tests := []struct {
+		name        string
+		data        string
+		wantErrVal  error
+		wantHostVal string
+	}{
+		{
+			//
+			// Test-case 1: Enabling the resource. Host FS must reflect it.
+			//
+			name:        "1",
+			data:        "1\n",
+			wantErrVal:  nil,
+			wantHostVal: "1",
+		},
+		{
+			//
+			// Test-case 2: Disabling the resource (EPERM). Host FS must stay
+			// enabled.
+			//
+			name:        "2",
+			data:        "0\n",
+			wantErrVal:  fuse.IOerror{Code: syscall.EPERM},
+			wantHostVal: "1",
+		},
+		{
+			//
+			// Test-case 3: Out-of-range value (EINVAL).
+			//
+			name:        "3",
+			data:        "2\n",
+			wantErrVal:  fuse.IOerror{Code: syscall.EINVAL},
+			wantHostVal: "1",
+		},
+	}
+
+	//
+	// Testcase executions.
+	//
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &domain.HandlerRequest{
+				Pid:       1001,
+				Data:      []byte(tt.data),
+				Container: cntr,
+			}
+
+			_, err := h.Write(n, req)
+			if err != tt.wantErrVal {
+				t.Errorf("GuardedIntBaseHandler.Write() error = %v, want %v",
+					err, tt.wantErrVal)
+			}
+
+			gotHostVal, err := n.ReadLine()
+			if err != nil {
+				t.Fatalf("Could not read host file: %v", err)
+			}
+			if gotHostVal != tt.wantHostVal {
+				t.Errorf("GuardedIntBaseHandler.Write() host value = %v, want %v",
+					gotHostVal, tt.wantHostVal)
+			}
+		})
+	}
+
+	if !h.Writable() || h.MergePolicy() != domain.MergePolicyMax {
+		t.Errorf("GuardedIntBaseHandler Writable() = %v, MergePolicy() = %v; want true, %v",
+			h.Writable(), h.MergePolicy(), domain.MergePolicyMax)
+	}
+}
+
+func TestGuardedIntBaseHandler_PerfEventParanoid(t *testing.T) {
+
+	cntr := css.ContainerCreate(
+		"c1",
+		uint32(1001),
+		time.Time{},
+		231072,
+		65535,
+		231072,
+		65535,
+		nil,
+		nil,
+		false,
+		nil)
+
+	n := ios.NewIOnode("perf_event_paranoid", "/proc/sys/kernel/perf_event_paranoid", 0)
+	if err := n.WriteFile([]byte("2")); err != nil {
+		t.Fatalf("Could not initialize host file: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		enforceMax  bool
+		data        string
+		wantErr     bool
+		wantErrVal  error
+		wantHostVal string
+	}{
+		{
+			//
+			// Test-case 1: Default (read-only) mode. Relaxing the restriction
+			// must be rejected (EPERM).
+			//
+			name:        "1",
+			enforceMax:  false,
+			data:        "-1\n",
+			wantErr:     true,
+			wantErrVal:  fuse.IOerror{Code: syscall.EPERM},
+			wantHostVal: "2",
+		},
+		{
+			//
+			// Test-case 2: Default (read-only) mode. Tightening the restriction
+			// must be rejected too (EPERM).
+			//
+			name:        "2",
+			enforceMax:  false,
+			data:        "3\n",
+			wantErr:     true,
+			wantErrVal:  fuse.IOerror{Code: syscall.EPERM},
+			wantHostVal: "2",
+		},
+		{
+			//
+			// Test-case 3: Enforce-max mode with a less restrictive value. Host
+			// FS must keep the most restrictive one.
+			//
+			name:        "3",
+			enforceMax:  true,
+			data:        "-1\n",
+			wantErr:     false,
+			wantHostVal: "2",
+		},
+		{
+			//
+			// Test-case 4: Enforce-max mode with a more restrictive value. Host
+			// FS must reflect the new value.
+			//
+			name:        "4",
+			enforceMax:  true,
+			data:        "3\n",
+			wantErr:     false,
+			wantHostVal: "3",
+		},
+		{
+			//
+			// Test-case 5: Enforce-max mode with out-of-range values (EINVAL).
+			//
+			name:        "5",
+			enforceMax:  true,
+			data:        "-2\n",
+			wantErr:     true,
+			wantErrVal:  fuse.IOerror{Code: syscall.EINVAL},
+			wantHostVal: "3",
+		},
+		{
+			name:        "6",
+			enforceMax:  true,
+			data:        "4\n",
+			wantErr:     true,
+			wantErrVal:  fuse.IOerror{Code: syscall.EINVAL},
+			wantHostVal: "3",
+		},
+	}
+
+	//
+	// Testcase executions.
+	//
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &implementations.GuardedIntBaseHandler{
+				HandlerBase: domain.HandlerBase{
+					Name:    "kernelPerfEventParanoid",
+					Path:    "/proc/sys/kernel/perf_event_paranoid",
+					Type:    domain.NODE_SUBSTITUTION,
+					Enabled: true,
+					Service: hds,
+				},
+				EnforceMax: tt.enforceMax,
+				Min:        -1,
+				Max:        3,
+			}
+
+			req := &domain.HandlerRequest{
+				Pid:       1001,
+				Data:      []byte(tt.data),
+				Container: cntr,
+			}
+
+			_, err := h.Write(n, req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GuardedIntBaseHandler.Write() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && tt.wantErrVal != nil && err != tt.wantErrVal {
+				t.Errorf("GuardedIntBaseHandler.Write() error = %v, wantErr %v, wantErrVal %v",
+					err, tt.wantErr, tt.wantErrVal)
+			}
+
+			gotHostVal, err := n.ReadLine()
+			if err != nil {
+				t.Fatalf("Could not read host file: %v", err)
+			}
+			if gotHostVal != tt.wantHostVal {
+				t.Errorf("GuardedIntBaseHandler.Write() host value = %v, want %v",
+					gotHostVal, tt.wantHostVal)
+			}
+		})
+	}
+}
+
+func TestGuardedIntBaseHandler_ModulesDisabled(t *testing.T) {
+
+	trusted := css.ContainerCreate("c1", uint32(1001), time.Time{}, 231072,
+		65535, 231072, 65535, nil, nil, false, nil)
+	untrusted := css.ContainerCreate("c2", uint32(2001), time.Time{}, 231072,
+		65535, 231072, 65535, nil, nil, false, nil)
+
+	n := ios.NewIOnode("modules_disabled", "/proc/sys/kernel/modules_disabled", 0)
+	if err := n.WriteFile([]byte("0")); err != nil {
+		t.Fatalf("Could not initialize host file: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		enforceMax  bool
+		allowed     []string
+		cntr        domain.ContainerIface
+		data        string
+		wantErr     bool
+		wantErrVal  error
+		wantHostVal string
+	}{
+		{
+			//
+			// Test-case 1: Default (read-only) mode. Setting the latch must be
+			// rejected (EPERM).
+			//
+			name:        "1",
+			enforceMax:  false,
+			allowed:     []string{"c1"},
+			cntr:        trusted,
+			data:        "1\n",
+			wantErr:     true,
+			wantErrVal:  fuse.IOerror{Code: syscall.EPERM},
+			wantHostVal: "0",
+		},
+		{
+			//
+			// Test-case 2: Enforce mode. Non-whitelisted container (EPERM).
+			//
+			name:        "2",
+			enforceMax:  true,
+			allowed:     []string{"c1"},
+			cntr:        untrusted,
+			data:        "1\n",
+			wantErr:     true,
+			wantErrVal:  fuse.IOerror{Code: syscall.EPERM},
+			wantHostVal: "0",
+		},
+		{
+			//
+			// Test-case 3: Enforce mode with an empty whitelist. Every container
+			// must be rejected (EPERM).
+			//
+			name:        "3",
+			enforceMax:  true,
+			allowed:     nil,
+			cntr:        trusted,
+			data:        "1\n",
+			wantErr:     true,
+			wantErrVal:  fuse.IOerror{Code: syscall.EPERM},
+			wantHostVal: "0",
+		},
+		{
+			//
+			// Test-case 4: Enforce mode. Invalid value (EINVAL).
+			//
+			name:        "4",
+			enforceMax:  true,
+			allowed:     []string{"c1"},
+			cntr:        trusted,
+			data:        "2\n",
+			wantErr:     true,
+			wantErrVal:  fuse.IOerror{Code: syscall.EINVAL},
+			wantHostVal: "0",
+		},
+		{
+			//
+			// Test-case 5: Enforce mode. Whitelisted container setting the
+			// latch (0 -> 1). Host FS must reflect the new value.
+			//
+			name:        "5",
+			enforceMax:  true,
+			allowed:     []string{"c1"},
+			cntr:        trusted,
+			data:        "1\n",
+			wantErr:     false,
+			wantHostVal: "1",
+		},
+		{
+			//
+			// Test-case 6: Enforce mode. Whitelisted container attempting to
+			// unset the latch (1 -> 0). Host FS must keep the latch set.
+			//
+			name:        "6",
+			enforceMax:  true,
+			allowed:     []string{"c1"},
+			cntr:        trusted,
+			data:        "0\n",
+			wantErr:     false,
+			wantHostVal: "1",
+		},
+	}
+
+	//
+	// Testcase executions.
+	//
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &implementations.GuardedIntBaseHandler{
+				HandlerBase: domain.HandlerBase{
+					Name:    "kernelModulesDisabled",
+					Path:    "/proc/sys/kernel/modules_disabled",
+					Type:    domain.NODE_SUBSTITUTION,
+					Enabled: true,
+					Service: hds,
+				},
+				EnforceMax:        tt.enforceMax,
+				AllowListRequired: true,
+				AllowedContainers: tt.allowed,
+				Min:               0,
+				Max:               1,
+			}
+
+			req := &domain.HandlerRequest{
+				Pid:       tt.cntr.InitPid(),
+				Data:      []byte(tt.data),
+				Container: tt.cntr,
+			}
+
+			_, err := h.Write(n, req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GuardedIntBaseHandler.Write() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && tt.wantErrVal != nil && err != tt.wantErrVal {
+				t.Errorf("GuardedIntBaseHandler.Write() error = %v, wantErr %v, wantErrVal %v",
+					err, tt.wantErr, tt.wantErrVal)
+			}
+
+			gotHostVal, err := n.ReadLine()
+			if err != nil {
+				t.Fatalf("Could not read host file: %v", err)
+			}
+			if gotHostVal != tt.wantHostVal {
+				t.Errorf("GuardedIntBaseHandler.Write() host value = %v, want %v",
+					gotHostVal, tt.wantHostVal)
+			}
+		})
+	}
+}
+
+func TestGuardedIntBaseHandler_Reconfigure(t *testing.T) {
+
+	h := &implementations.GuardedIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:    "kernelWatchdog",
+			Path:    "/proc/sys/kernel/watchdog",
+			Type:    domain.NODE_SUBSTITUTION,
+			Enabled: true,
+			Service: hds,
+		},
+		Min: 0,
+		Max: 1,
+	}
+
+	tests := []struct {
+		name         string
+		cfg          domain.HandlerConfig
+		wantErr      bool
+		wantWritable bool
+		wantPolicy   domain.MergePolicy
+	}{
+		{
+			//
+			// Test-case 1: No write mode. Handler stays read-only.
+			//
+			name:         "1",
+			cfg:          domain.HandlerConfig{},
+			wantErr:      false,
+			wantWritable: false,
+			wantPolicy:   domain.MergePolicyNone,
+		},
+		{
+			//
+			// Test-case 2: Passthrough mode.
+			//
+			name:         "2",
+			cfg:          domain.HandlerConfig{WriteMode: domain.WriteModePassthrough},
+			wantErr:      false,
+			wantWritable: true,
+			wantPolicy:   domain.MergePolicyLast,
+		},
+		{
+			//
+			// Test-case 3: Enforce-max mode.
+			//
+			name:         "3",
+			cfg:          domain.HandlerConfig{WriteMode: domain.WriteModeEnforceMax},
+			wantErr:      false,
+			wantWritable: true,
+			wantPolicy:   domain.MergePolicyMax,
+		},
+		{
+			//
+			// Test-case 4: Enforce-enabled mode, along with its implied merge
+			// policy.
+			//
+			name: "4",
+			cfg: domain.HandlerConfig{
+				WriteMode:   domain.WriteModeEnforceEnabled,
+				MergePolicy: domain.MergePolicyMax,
+			},
+			wantErr:      false,
+			wantWritable: true,
+			wantPolicy:   domain.MergePolicyMax,
+		},
+		{
+			//
+			// Test-case 5: Merge policy not matching the write mode.
+			//
+			name: "5",
+			cfg: domain.HandlerConfig{
+				WriteMode:   domain.WriteModePassthrough,
+				MergePolicy: domain.MergePolicyMax,
+			},
+			wantErr: true,
+		},
+		{
+			//
+			// Test-case 6: Unknown write mode.
+			//
+			name:    "6",
+			cfg:     domain.HandlerConfig{WriteMode: "foo"},
+			wantErr: true,
+		},
+	}
+
+	//
+	// Testcase executions.
+	//
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nh, err := h.Reconfigure(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GuardedIntBaseHandler.Reconfigure() error = %v, wantErr %v",
+					err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if nh.Writable() != tt.wantWritable || nh.MergePolicy() != tt.wantPolicy {
+				t.Errorf("GuardedIntBaseHandler.Reconfigure() Writable() = %v, MergePolicy() = %v; want %v, %v",
+					nh.Writable(), nh.MergePolicy(), tt.wantWritable, tt.wantPolicy)
+			}
+		})
+	}
+
+	// The original handler is left untouched.
+	if h.Writable() || h.MergePolicy() != domain.MergePolicyNone {
+		t.Errorf("GuardedIntBaseHandler.Reconfigure() altered the original handler")
+	}
+}
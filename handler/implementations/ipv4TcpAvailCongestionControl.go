@@ -0,0 +1,177 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+)
+
+//
+// /proc/sys/net/ipv4/tcp_available_congestion_control handler
+//
+// Read-only list of the congestion-control algorithms registered in the host
+// kernel. The list is global (it's populated as tcp_* modules get loaded in
+// the host), so the host content is displayed verbatim to all containers, and
+// writes are rejected with EACCES. As the list rarely changes, it's fetched
+// once and cached across all containers for the lifetime of sysbox-fs.
+//
+type Ipv4TcpAvailCongestionControlHandler struct {
+	domain.HandlerBase
+
+	mu        sync.Mutex
+	available []byte // cached host content
+}
+
+func (h *Ipv4TcpAvailCongestionControlHandler) Lookup(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (os.FileInfo, error) {
+
+	logrus.Debugf("Executing Lookup() method on %v handler", h.Name)
+
+	return n.Stat()
+}
+
+func (h *Ipv4TcpAvailCongestionControlHandler) Getattr(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (*syscall.Stat_t, error) {
+
+	logrus.Debugf("Executing Getattr() method on %v handler", h.Name)
+
+	return nil, nil
+}
+
+func (h *Ipv4TcpAvailCongestionControlHandler) Open(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) error {
+
+	logrus.Debugf("Executing %v Open() method\n", h.Name)
+
+	flags := n.OpenFlags()
+	if flags != syscall.O_RDONLY {
+		return fuse.IOerror{Code: syscall.EACCES}
+	}
+
+	return nil
+}
+
+func (h *Ipv4TcpAvailCongestionControlHandler) Close(n domain.IOnodeIface) error {
+
+	logrus.Debugf("Executing Close() method on %v handler", h.Name)
+
+	return nil
+}
+
+func (h *Ipv4TcpAvailCongestionControlHandler) Read(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Read() method", h.Name)
+
+	// Ensure operation is generated from within a registered sys container.
+	if req.Container == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return 0, errors.New("Container not found")
+	}
+
+	data, err := h.fetchAvailable(n)
+	if err != nil {
+		return 0, err
+	}
+
+	if req.Offset >= int64(len(data)) {
+		return 0, io.EOF
+	}
+
+	return copyResultBuffer(req.Data, data[req.Offset:])
+}
+
+func (h *Ipv4TcpAvailCongestionControlHandler) Write(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Write() method", h.Name)
+
+	return 0, fuse.IOerror{Code: syscall.EACCES}
+}
+
+func (h *Ipv4TcpAvailCongestionControlHandler) ReadDirAll(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) ([]os.FileInfo, error) {
+
+	return nil, nil
+}
+
+// Returns the host's list of available congestion-control algorithms, reading
+// it from the host FS the first time around.
+func (h *Ipv4TcpAvailCongestionControlHandler) fetchAvailable(
+	n domain.IOnodeIface) ([]byte, error) {
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.available != nil {
+		return h.available, nil
+	}
+
+	data, err := n.ReadFile()
+	if err != nil {
+		logrus.Errorf("Could not read from file %v: %v", h.Path, err)
+		return nil, fuse.IOerror{Code: syscall.EIO}
+	}
+
+	h.available = data
+
+	return h.available, nil
+}
+
+func (h *Ipv4TcpAvailCongestionControlHandler) GetName() string {
+	return h.Name
+}
+
+func (h *Ipv4TcpAvailCongestionControlHandler) GetPath() string {
+	return h.Path
+}
+
+func (h *Ipv4TcpAvailCongestionControlHandler) GetEnabled() bool {
+	return h.Enabled
+}
+
+func (h *Ipv4TcpAvailCongestionControlHandler) GetType() domain.HandlerType {
+	return h.Type
+}
+
+func (h *Ipv4TcpAvailCongestionControlHandler) GetService() domain.HandlerServiceIface {
+	return h.Service
+}
+
+func (h *Ipv4TcpAvailCongestionControlHandler) SetEnabled(val bool) {
+	h.Enabled = val
+}
+
+func (h *Ipv4TcpAvailCongestionControlHandler) SetService(hs domain.HandlerServiceIface) {
+	h.Service = hs
+}
@@ -0,0 +1,97 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations_test
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+	"github.com/nestybox/sysbox-fs/handler/implementations"
+)
+
+func TestIpv4TcpAvailCongestionControlHandler(t *testing.T) {
+
+	// Host FS initial state.
+	const hostVal = "reno cubic bbr\n"
+
+	h := &implementations.Ipv4TcpAvailCongestionControlHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "ipv4TcpAvailCongestionControl",
+			Path:      "/proc/sys/net/ipv4/tcp_available_congestion_control",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+			Service:   hds,
+		},
+	}
+
+	n := ios.NewIOnode("tcp_available_congestion_control",
+		"/proc/sys/net/ipv4/tcp_available_congestion_control", 0)
+	if err := n.WriteFile([]byte(hostVal)); err != nil {
+		t.Fatalf("Could not initialize host file: %v", err)
+	}
+
+	c1 := css.ContainerCreate("c1", uint32(1001), time.Time{}, 231072, 65535, 231072, 65535, nil, nil, false, nil)
+	c2 := css.ContainerCreate("c2", uint32(2001), time.Time{}, 296608, 65535, 296608, 65535, nil, nil, false, nil)
+
+	read := func(cntr domain.ContainerIface) string {
+		buf := make([]byte, 64)
+		rn, err := h.Read(n, &domain.HandlerRequest{
+			Pid:       cntr.InitPid(),
+			Data:      buf,
+			Container: cntr,
+		})
+		if err != nil {
+			t.Fatalf("Ipv4TcpAvailCongestionControlHandler.Read() error = %v", err)
+		}
+		return string(buf[:rn])
+	}
+
+	// Host list must be passed through verbatim.
+	if got := read(c1); got != hostVal {
+		t.Errorf("Ipv4TcpAvailCongestionControlHandler.Read() = %q, want %q", got, hostVal)
+	}
+
+	// Subsequent reads (from any container) must be served from the cache.
+	if err := n.WriteFile([]byte("reno\n")); err != nil {
+		t.Fatalf("Could not update host file: %v", err)
+	}
+	if got := read(c2); got != hostVal {
+		t.Errorf("Ipv4TcpAvailCongestionControlHandler.Read() = %q, want %q", got, hostVal)
+	}
+
+	// Write access must be rejected, both at open and write time.
+	n.SetOpenFlags(syscall.O_WRONLY)
+	err := h.Open(n, &domain.HandlerRequest{Pid: 1001, Container: c1})
+	if err != (fuse.IOerror{Code: syscall.EACCES}) {
+		t.Errorf("Ipv4TcpAvailCongestionControlHandler.Open() error = %v, want %v",
+			err, syscall.EACCES)
+	}
+
+	_, err = h.Write(n, &domain.HandlerRequest{
+		Pid:       1001,
+		Data:      []byte("reno\n"),
+		Container: c1,
+	})
+	if err != (fuse.IOerror{Code: syscall.EACCES}) {
+		t.Errorf("Ipv4TcpAvailCongestionControlHandler.Write() error = %v, want %v",
+			err, syscall.EACCES)
+	}
+}
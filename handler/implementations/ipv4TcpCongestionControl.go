@@ -0,0 +1,238 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+)
+
+//
+// /proc/sys/net/ipv4/tcp_congestion_control handler
+//
+// This resource is namespaced by the kernel's net-ns, so all I/O is passed
+// through to the container by the procSysCommon handler. Written values are
+// validated against the algorithms available within the container's net-ns
+// (as per "tcp_available_congestion_control"), and rejected with EINVAL if not
+// present there.
+//
+type Ipv4TcpCongestionControlHandler struct {
+	domain.HandlerBase
+}
+
+const tcpAvailCongestionControlPath = "/proc/sys/net/ipv4/tcp_available_congestion_control"
+
+func (h *Ipv4TcpCongestionControlHandler) Lookup(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (os.FileInfo, error) {
+
+	logrus.Debugf("Executing Lookup() method on %v handler", h.Name)
+
+	commonHandler, err := h.commonHandler()
+	if err != nil {
+		return nil, err
+	}
+
+	return commonHandler.Lookup(n, req)
+}
+
+func (h *Ipv4TcpCongestionControlHandler) Getattr(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (*syscall.Stat_t, error) {
+
+	logrus.Debugf("Executing Getattr() method on %v handler", h.Name)
+
+	commonHandler, err := h.commonHandler()
+	if err != nil {
+		return nil, err
+	}
+
+	return commonHandler.Getattr(n, req)
+}
+
+func (h *Ipv4TcpCongestionControlHandler) Open(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) error {
+
+	logrus.Debugf("Executing %v Open() method\n", h.Name)
+
+	commonHandler, err := h.commonHandler()
+	if err != nil {
+		return err
+	}
+
+	return commonHandler.Open(n, req)
+}
+
+func (h *Ipv4TcpCongestionControlHandler) Close(n domain.IOnodeIface) error {
+
+	logrus.Debugf("Executing Close() method on %v handler", h.Name)
+
+	commonHandler, err := h.commonHandler()
+	if err != nil {
+		return err
+	}
+
+	return commonHandler.Close(n)
+}
+
+func (h *Ipv4TcpCongestionControlHandler) Read(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Read() method", h.Name)
+
+	commonHandler, err := h.commonHandler()
+	if err != nil {
+		return 0, err
+	}
+
+	return commonHandler.Read(n, req)
+}
+
+func (h *Ipv4TcpCongestionControlHandler) Write(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Write() method", h.Name)
+
+	// Ensure operation is generated from within a registered sys container.
+	if req.Container == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return 0, errors.New("Container not found")
+	}
+
+	newVal := strings.TrimSpace(string(req.Data))
+	if newVal == "" {
+		return 0, fuse.IOerror{Code: syscall.EINVAL}
+	}
+
+	available, err := h.fetchAvailable(req.Pid)
+	if err != nil {
+		return 0, err
+	}
+
+	var found bool
+	for _, algo := range strings.Fields(available) {
+		if algo == newVal {
+			found = true
+			break
+		}
+	}
+	if !found {
+		logrus.Errorf("Unavailable congestion-control algorithm %v (available: %v)",
+			newVal, available)
+		return 0, fuse.IOerror{Code: syscall.EINVAL}
+	}
+
+	commonHandler, err := h.commonHandler()
+	if err != nil {
+		return 0, err
+	}
+
+	return commonHandler.Write(n, req)
+}
+
+func (h *Ipv4TcpCongestionControlHandler) ReadDirAll(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) ([]os.FileInfo, error) {
+
+	return nil, nil
+}
+
+// Obtains the list of congestion-control algorithms available within the
+// requesting process' net-ns. Notice that this list is not cached, as it can
+// vary as modules get loaded / unloaded in the host.
+func (h *Ipv4TcpCongestionControlHandler) fetchAvailable(pid uint32) (string, error) {
+
+	// Create nsenterEvent to initiate interaction with container namespaces.
+	nss := h.Service.NSenterService()
+	event := nss.NewEvent(
+		pid,
+		&domain.AllNSsButMount,
+		&domain.NSenterMessage{
+			Type: domain.ReadFileRequest,
+			Payload: &domain.ReadFilePayload{
+				File: tcpAvailCongestionControlPath,
+			},
+		},
+		nil,
+		false,
+	)
+
+	// Launch nsenter-event to obtain file state within container
+	// namespaces.
+	err := nss.SendRequestEvent(event)
+	if err != nil {
+		return "", err
+	}
+
+	// Obtain nsenter-event response.
+	responseMsg := nss.ReceiveResponseEvent(event)
+	if responseMsg.Type == domain.ErrorResponse {
+		return "", responseMsg.Payload.(error)
+	}
+
+	return responseMsg.Payload.(string), nil
+}
+
+func (h *Ipv4TcpCongestionControlHandler) commonHandler() (domain.HandlerIface, error) {
+
+	commonHandler, ok := h.Service.FindHandler("procSysCommonHandler")
+	if !ok {
+		return nil, fmt.Errorf("No procSysCommonHandler found")
+	}
+
+	return commonHandler, nil
+}
+
+func (h *Ipv4TcpCongestionControlHandler) GetName() string {
+	return h.Name
+}
+
+func (h *Ipv4TcpCongestionControlHandler) GetPath() string {
+	return h.Path
+}
+
+func (h *Ipv4TcpCongestionControlHandler) GetEnabled() bool {
+	return h.Enabled
+}
+
+func (h *Ipv4TcpCongestionControlHandler) GetType() domain.HandlerType {
+	return h.Type
+}
+
+func (h *Ipv4TcpCongestionControlHandler) GetService() domain.HandlerServiceIface {
+	return h.Service
+}
+
+func (h *Ipv4TcpCongestionControlHandler) SetEnabled(val bool) {
+	h.Enabled = val
+}
+
+func (h *Ipv4TcpCongestionControlHandler) SetService(hs domain.HandlerServiceIface) {
+	h.Service = hs
+}
@@ -0,0 +1,191 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations_test
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+	"github.com/nestybox/sysbox-fs/handler/implementations"
+	"github.com/nestybox/sysbox-fs/nsenter"
+)
+
+func TestIpv4TcpCongestionControlHandler_Write(t *testing.T) {
+
+	commonHandler := &implementations.ProcSysCommonHandler{
+		domain.HandlerBase{
+			Name:      "procSysCommon",
+			Path:      "procSysCommonHandler",
+			Enabled:   true,
+			Cacheable: true,
+			Service:   hds,
+		},
+	}
+	hds.On("FindHandler", "procSysCommonHandler").Return(commonHandler, true)
+
+	h := &implementations.Ipv4TcpCongestionControlHandler{
+		domain.HandlerBase{
+			Name:      "ipv4TcpCongestionControl",
+			Path:      "/proc/sys/net/ipv4/tcp_congestion_control",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+			Service:   hds,
+		},
+	}
+
+	n := ios.NewIOnode("tcp_congestion_control", "/proc/sys/net/ipv4/tcp_congestion_control", 0)
+
+	cntr := css.ContainerCreate(
+		"c1",
+		uint32(1001),
+		time.Time{},
+		231072,
+		65535,
+		231072,
+		65535,
+		nil,
+		nil,
+		false,
+		css)
+	_ = cntr.SetInitProc(cntr.InitPid(), cntr.UID(), cntr.GID())
+	cntr.InitProc().CreateNsInodes(123456)
+
+	// Algorithms available within the container's net-ns.
+	expectAvailRead := func() {
+		nsenterEventReq := &nsenter.NSenterEvent{
+			Pid:       cntr.InitPid(),
+			Namespace: &domain.AllNSsButMount,
+			ReqMsg: &domain.NSenterMessage{
+				Type: domain.ReadFileRequest,
+				Payload: &domain.ReadFilePayload{
+					File: "/proc/sys/net/ipv4/tcp_available_congestion_control",
+				},
+			},
+		}
+
+		nss.On(
+			"NewEvent",
+			cntr.InitPid(),
+			&domain.AllNSsButMount,
+			nsenterEventReq.ReqMsg,
+			(*domain.NSenterMessage)(nil),
+			false).Return(nsenterEventReq)
+
+		nss.On("SendRequestEvent", nsenterEventReq).Return(nil)
+		nss.On("ReceiveResponseEvent", nsenterEventReq).Return(
+			&domain.NSenterMessage{
+				Type:    domain.ReadFileResponse,
+				Payload: "reno cubic bbr",
+			})
+	}
+
+	expectWrite := func(val string) {
+		nsenterEventReq := &nsenter.NSenterEvent{
+			Pid:       cntr.InitPid(),
+			Namespace: &domain.AllNSsButMount,
+			ReqMsg: &domain.NSenterMessage{
+				Type: domain.WriteFileRequest,
+				Payload: &domain.WriteFilePayload{
+					File:    n.Path(),
+					Content: val,
+				},
+				Creds: unmappedCreds,
+			},
+		}
+
+		nss.On(
+			"NewEvent",
+			cntr.InitPid(),
+			&domain.AllNSsButMount,
+			nsenterEventReq.ReqMsg,
+			(*domain.NSenterMessage)(nil),
+			false).Return(nsenterEventReq)
+
+		nss.On("SendRequestEvent", nsenterEventReq).Return(nil)
+		nss.On("ReceiveResponseEvent", nsenterEventReq).Return(
+			&domain.NSenterMessage{Type: domain.WriteFileResponse})
+	}
+
+	tests := []struct {
+		name       string
+		data       string
+		wantErr    bool
+		wantErrVal error
+		prepare    func()
+	}{
+		{
+			//
+			// Test-case 1: Available algorithm. No errors expected.
+			//
+			name: "1",
+			data: "bbr\n",
+			prepare: func() {
+				expectAvailRead()
+				expectWrite("bbr")
+			},
+		},
+		{
+			//
+			// Test-case 2: Unavailable algorithm (EINVAL). Value must not be
+			// pushed to the container's net-ns.
+			//
+			name:       "2",
+			data:       "vegas\n",
+			wantErr:    true,
+			wantErrVal: fuse.IOerror{Code: syscall.EINVAL},
+			prepare:    expectAvailRead,
+		},
+	}
+
+	//
+	// Testcase executions.
+	//
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			// Prepare the mocks.
+			if tt.prepare != nil {
+				tt.prepare()
+			}
+
+			req := &domain.HandlerRequest{
+				Pid:       cntr.InitPid(),
+				Data:      []byte(tt.data),
+				Container: cntr,
+			}
+
+			_, err := h.Write(n, req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Ipv4TcpCongestionControlHandler.Write() error = %v, wantErr %v",
+					err, tt.wantErr)
+			}
+			if err != nil && tt.wantErrVal != nil && err != tt.wantErrVal {
+				t.Errorf("Ipv4TcpCongestionControlHandler.Write() error = %v, wantErr %v, wantErrVal %v",
+					err, tt.wantErr, tt.wantErrVal)
+			}
+
+			// Ensure that mocks were properly invoked and reset expectedCalls
+			// object.
+			nss.AssertExpectations(t)
+			nss.ExpectedCalls = nil
+		})
+	}
+}
@@ -0,0 +1,188 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+)
+
+//
+// /proc/sys/kernel/random/entropy_avail handler
+//
+// Documentation: The value in this file reports the entropy count of the
+// kernel's input pool (in bits).
+//
+// Some applications poll this resource and block until enough entropy is
+// reported. As the host's value is of no concern to sys containers (and could
+// leak information about the host's activity), a plausible high value is
+// displayed instead ('Value', or 'entropyAvailDefault' if unset). The host's
+// value can still be displayed by enabling the 'HostValue' attribute. Writes
+// are rejected (EACCES) as this is a read-only resource.
+//
+type KernelRandomEntropyAvailHandler struct {
+	domain.HandlerBase
+
+	// Value to display, if different from the default one.
+	Value int
+
+	// Display the host FS value instead of the emulated one.
+	HostValue bool
+}
+
+// Entropy count (in bits) displayed by default, matching the size of the input
+// pool in recent kernels.
+const entropyAvailDefault = 4096
+
+func (h *KernelRandomEntropyAvailHandler) Lookup(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (os.FileInfo, error) {
+
+	logrus.Debugf("Executing Lookup() method on %v handler", h.Name)
+
+	return n.Stat()
+}
+
+func (h *KernelRandomEntropyAvailHandler) Getattr(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (*syscall.Stat_t, error) {
+
+	logrus.Debugf("Executing Getattr() method on %v handler", h.Name)
+
+	return nil, nil
+}
+
+func (h *KernelRandomEntropyAvailHandler) Open(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) error {
+
+	logrus.Debugf("Executing %v Open() method\n", h.Name)
+
+	flags := n.OpenFlags()
+	if flags != syscall.O_RDONLY {
+		return fuse.IOerror{Code: syscall.EACCES}
+	}
+
+	return nil
+}
+
+func (h *KernelRandomEntropyAvailHandler) Close(n domain.IOnodeIface) error {
+
+	logrus.Debugf("Executing Close() method on %v handler", h.Name)
+
+	return nil
+}
+
+func (h *KernelRandomEntropyAvailHandler) Read(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Read() method", h.Name)
+
+	// We are dealing with a single integer element being read, so we can save
+	// some cycles by returning right away if offset is any higher than zero.
+	if req.Offset > 0 {
+		return 0, io.EOF
+	}
+
+	// Ensure operation is generated from within a registered sys container.
+	if req.Container == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return 0, errors.New("Container not found")
+	}
+
+	var data string
+
+	if h.HostValue {
+		curHostVal, err := n.ReadLine()
+		if err != nil && err != io.EOF {
+			logrus.Errorf("Could not read from file %v", h.Path)
+			return 0, fuse.IOerror{Code: syscall.EIO}
+		}
+
+		// High-level verification to ensure that format is the expected one.
+		if _, err := strconv.Atoi(curHostVal); err != nil {
+			logrus.Errorf("Unsupported content read from file %v, error %v", h.Path, err)
+			return 0, fuse.IOerror{Code: syscall.EINVAL}
+		}
+
+		data = curHostVal
+	} else {
+		val := h.Value
+		if val == 0 {
+			val = entropyAvailDefault
+		}
+		data = strconv.Itoa(val)
+	}
+
+	data += "\n"
+
+	return copyResultBuffer(req.Data, []byte(data))
+}
+
+func (h *KernelRandomEntropyAvailHandler) Write(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Write() method", h.Name)
+
+	return 0, fuse.IOerror{Code: syscall.EACCES}
+}
+
+func (h *KernelRandomEntropyAvailHandler) ReadDirAll(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) ([]os.FileInfo, error) {
+
+	return nil, nil
+}
+
+func (h *KernelRandomEntropyAvailHandler) GetName() string {
+	return h.Name
+}
+
+func (h *KernelRandomEntropyAvailHandler) GetPath() string {
+	return h.Path
+}
+
+func (h *KernelRandomEntropyAvailHandler) GetEnabled() bool {
+	return h.Enabled
+}
+
+func (h *KernelRandomEntropyAvailHandler) GetType() domain.HandlerType {
+	return h.Type
+}
+
+func (h *KernelRandomEntropyAvailHandler) GetService() domain.HandlerServiceIface {
+	return h.Service
+}
+
+func (h *KernelRandomEntropyAvailHandler) SetEnabled(val bool) {
+	h.Enabled = val
+}
+
+func (h *KernelRandomEntropyAvailHandler) SetService(hs domain.HandlerServiceIface) {
+	h.Service = hs
+}
@@ -0,0 +1,120 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations_test
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+	"github.com/nestybox/sysbox-fs/handler/implementations"
+)
+
+func TestKernelRandomEntropyAvailHandler(t *testing.T) {
+
+	cntr := css.ContainerCreate(
+		"c1",
+		uint32(1001),
+		time.Time{},
+		231072,
+		65535,
+		231072,
+		65535,
+		nil,
+		nil,
+		false,
+		nil)
+
+	const path = "/proc/sys/kernel/random/entropy_avail"
+
+	n := ios.NewIOnode("entropy_avail", path, 0)
+	if err := n.WriteFile([]byte("256")); err != nil {
+		t.Fatalf("Could not initialize host file: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		value     int
+		hostValue bool
+		want      string
+	}{
+		{
+			//
+			// Test-case 1: Default emulated value.
+			//
+			name: "1",
+			want: "4096\n",
+		},
+		{
+			//
+			// Test-case 2: Configured emulated value.
+			//
+			name:  "2",
+			value: 3072,
+			want:  "3072\n",
+		},
+		{
+			//
+			// Test-case 3: Host value.
+			//
+			name:      "3",
+			hostValue: true,
+			want:      "256\n",
+		},
+	}
+
+	//
+	// Testcase executions.
+	//
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &implementations.KernelRandomEntropyAvailHandler{
+				HandlerBase: domain.HandlerBase{
+					Name:    "kernelRandomEntropyAvail",
+					Path:    path,
+					Type:    domain.NODE_SUBSTITUTION,
+					Enabled: true,
+					Service: hds,
+				},
+				Value:     tt.value,
+				HostValue: tt.hostValue,
+			}
+
+			buf := make([]byte, 16)
+			req := &domain.HandlerRequest{Pid: 1001, Data: buf, Container: cntr}
+
+			sz, err := h.Read(n, req)
+			if err != nil {
+				t.Fatalf("KernelRandomEntropyAvailHandler.Read() error = %v", err)
+			}
+			if got := string(buf[:sz]); got != tt.want {
+				t.Errorf("KernelRandomEntropyAvailHandler.Read() = %q, want %q", got, tt.want)
+			}
+
+			// Writes must be rejected, leaving the host FS untouched.
+			req = &domain.HandlerRequest{Pid: 1001, Data: []byte("0\n"), Container: cntr}
+			if _, err := h.Write(n, req); err != (fuse.IOerror{Code: syscall.EACCES}) {
+				t.Errorf("KernelRandomEntropyAvailHandler.Write() error = %v, want EACCES", err)
+			}
+			if val, _ := n.ReadLine(); val != "256" {
+				t.Errorf("KernelRandomEntropyAvailHandler.Write() host value = %v, want 256", val)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+)
+
+//
+// /proc/sys/kernel/random/poolsize handler
+//
+// Read-only size (in bits) of the kernel's entropy pool. The value is fixed at
+// kernel build time, so the host content is fetched once and displayed to all
+// containers for the lifetime of sysbox-fs, with no need to reach the host FS
+// (nor the container's namespaces) in subsequent reads. A fixed default is
+// displayed if the host value can't be obtained. Writes are rejected with
+// EACCES, as the kernel would do.
+//
+type KernelRandomPoolsizeHandler struct {
+	domain.HandlerBase
+
+	mu       sync.Mutex
+	poolsize string // cached host value
+}
+
+// Pool size displayed when the host one can't be read (value exposed by
+// kernels prior to v5.18).
+const defaultPoolsize = "4096"
+
+func (h *KernelRandomPoolsizeHandler) Lookup(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (os.FileInfo, error) {
+
+	logrus.Debugf("Executing Lookup() method on %v handler", h.Name)
+
+	return n.Stat()
+}
+
+func (h *KernelRandomPoolsizeHandler) Getattr(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (*syscall.Stat_t, error) {
+
+	logrus.Debugf("Executing Getattr() method on %v handler", h.Name)
+
+	return nil, nil
+}
+
+func (h *KernelRandomPoolsizeHandler) Open(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) error {
+
+	logrus.Debugf("Executing %v Open() method\n", h.Name)
+
+	flags := n.OpenFlags()
+	if flags != syscall.O_RDONLY {
+		return fuse.IOerror{Code: syscall.EACCES}
+	}
+
+	return nil
+}
+
+func (h *KernelRandomPoolsizeHandler) Close(n domain.IOnodeIface) error {
+
+	logrus.Debugf("Executing Close() method on %v handler", h.Name)
+
+	return nil
+}
+
+func (h *KernelRandomPoolsizeHandler) Read(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Read() method", h.Name)
+
+	// We are dealing with a single integer element being read, so we can save
+	// some cycles by returning right away if offset is any higher than zero.
+	if req.Offset > 0 {
+		return 0, io.EOF
+	}
+
+	// Ensure operation is generated from within a registered sys container.
+	if req.Container == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return 0, errors.New("Container not found")
+	}
+
+	data := h.fetchPoolsize(n) + "\n"
+
+	return copyResultBuffer(req.Data, []byte(data))
+}
+
+func (h *KernelRandomPoolsizeHandler) Write(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Write() method", h.Name)
+
+	return 0, fuse.IOerror{Code: syscall.EACCES}
+}
+
+func (h *KernelRandomPoolsizeHandler) ReadDirAll(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) ([]os.FileInfo, error) {
+
+	return nil, nil
+}
+
+// Returns the host's pool size, reading it from the host FS the first time
+// around.
+func (h *KernelRandomPoolsizeHandler) fetchPoolsize(n domain.IOnodeIface) string {
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.poolsize != "" {
+		return h.poolsize
+	}
+
+	val, err := n.ReadLine()
+	if err == nil || err == io.EOF {
+		_, err = strconv.ParseUint(val, 10, 32)
+	}
+	if err != nil {
+		logrus.Warnf("Could not obtain the host value of %v (%v); defaulting to %v",
+			h.Path, err, defaultPoolsize)
+		val = defaultPoolsize
+	}
+
+	h.poolsize = val
+
+	return h.poolsize
+}
+
+func (h *KernelRandomPoolsizeHandler) GetName() string {
+	return h.Name
+}
+
+func (h *KernelRandomPoolsizeHandler) GetPath() string {
+	return h.Path
+}
+
+func (h *KernelRandomPoolsizeHandler) GetEnabled() bool {
+	return h.Enabled
+}
+
+func (h *KernelRandomPoolsizeHandler) GetType() domain.HandlerType {
+	return h.Type
+}
+
+func (h *KernelRandomPoolsizeHandler) GetService() domain.HandlerServiceIface {
+	return h.Service
+}
+
+func (h *KernelRandomPoolsizeHandler) SetEnabled(val bool) {
+	h.Enabled = val
+}
+
+func (h *KernelRandomPoolsizeHandler) SetService(hs domain.HandlerServiceIface) {
+	h.Service = hs
+}
@@ -0,0 +1,114 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations_test
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+	"github.com/nestybox/sysbox-fs/handler/implementations"
+	"github.com/nestybox/sysbox-fs/mocks"
+)
+
+func TestKernelRandomPoolsizeHandler(t *testing.T) {
+
+	// Dedicated nsenter mock to verify that no requests are dispatched.
+	nss := &mocks.NSenterServiceIface{}
+	hs := &mocks.HandlerServiceIface{}
+	hs.On("NSenterService").Return(nss)
+	hs.On("ProcessService").Return(prs)
+
+	h := &implementations.KernelRandomPoolsizeHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "kernelRandomPoolsize",
+			Path:      "/proc/sys/kernel/random/poolsize",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+			Service:   hs,
+		},
+	}
+
+	n := ios.NewIOnode("poolsize", "/proc/sys/kernel/random/poolsize", 0)
+	if err := n.WriteFile([]byte("256")); err != nil {
+		t.Fatalf("Could not initialize host file: %v", err)
+	}
+
+	c1 := css.ContainerCreate("c1", uint32(1001), time.Time{}, 231072, 65535, 231072, 65535, nil, nil, false, nil)
+	c2 := css.ContainerCreate("c2", uint32(2001), time.Time{}, 296608, 65535, 296608, 65535, nil, nil, false, nil)
+
+	read := func(cntr domain.ContainerIface) string {
+		buf := make([]byte, 16)
+		rn, err := h.Read(n, &domain.HandlerRequest{
+			Pid:       cntr.InitPid(),
+			Data:      buf,
+			Container: cntr,
+		})
+		if err != nil {
+			t.Fatalf("KernelRandomPoolsizeHandler.Read() error = %v", err)
+		}
+		return string(buf[:rn])
+	}
+
+	// Host value must be displayed.
+	if got := read(c1); got != "256\n" {
+		t.Errorf("KernelRandomPoolsizeHandler.Read() = %q, want %q", got, "256\n")
+	}
+
+	// Subsequent reads (from any container) must be served from the cache.
+	if err := n.WriteFile([]byte("4096")); err != nil {
+		t.Fatalf("Could not update host file: %v", err)
+	}
+	if got := read(c2); got != "256\n" {
+		t.Errorf("KernelRandomPoolsizeHandler.Read() = %q, want %q", got, "256\n")
+	}
+
+	// Write access must be rejected, both at open and write time.
+	n.SetOpenFlags(syscall.O_WRONLY)
+	err := h.Open(n, &domain.HandlerRequest{Pid: 1001, Container: c1})
+	if err != (fuse.IOerror{Code: syscall.EACCES}) {
+		t.Errorf("KernelRandomPoolsizeHandler.Open() error = %v, want %v",
+			err, syscall.EACCES)
+	}
+
+	_, err = h.Write(n, &domain.HandlerRequest{
+		Pid:       1001,
+		Data:      []byte("8192\n"),
+		Container: c1,
+	})
+	if err != (fuse.IOerror{Code: syscall.EACCES}) {
+		t.Errorf("KernelRandomPoolsizeHandler.Write() error = %v, want %v",
+			err, syscall.EACCES)
+	}
+
+	nss.AssertNotCalled(t, "NewEvent")
+
+	// The fixed default must be displayed if the host value is unavailable.
+	h2 := &implementations.KernelRandomPoolsizeHandler{HandlerBase: h.HandlerBase.Clone()}
+	n2 := ios.NewIOnode("poolsize", "/proc/sys/kernel/random/missing", 0)
+	buf := make([]byte, 16)
+	rn, err := h2.Read(n2, &domain.HandlerRequest{Pid: 1001, Data: buf, Container: c1})
+	if err != nil {
+		t.Fatalf("KernelRandomPoolsizeHandler.Read() error = %v", err)
+	}
+	if got := string(buf[:rn]); got != "4096\n" {
+		t.Errorf("KernelRandomPoolsizeHandler.Read() = %q, want %q", got, "4096\n")
+	}
+}
@@ -55,9 +55,14 @@ import (
 //  128 = 0x80 - allow reboot/poweroff
 //  256 = 0x100 - allow nicing of all RT tasks
 //
-// Note: As this is a system-wide attribute, changes will be only made
-// superficially (at sys-container level). IOW, the host FS value will be left
-// untouched.
+// Note: As this is a system-wide attribute, and as enabling it would expose
+// dangerous host operations (e.g. reboot), sys containers are not allowed to
+// modify it (EPERM). Reads always display the host FS value.
+//
+// If the 'EnforceDisabled' attribute is set (i.e. the 'enforce-disabled' write
+// mode, see Reconfigure()), containers requesting sysrq to be disabled (i.e.
+// writing 0) will have the host FS value forced to 0. Attempts to enable any
+// sysrq function are still rejected in this mode.
 //
 
 const (
@@ -67,6 +72,9 @@ const (
 
 type KernelSysrqHandler struct {
 	domain.HandlerBase
+
+	// Allow containers to disable sysrq at host level.
+	EnforceDisabled bool
 }
 
 func (h *KernelSysrqHandler) Lookup(
@@ -98,6 +106,10 @@ func (h *KernelSysrqHandler) Open(
 		return fuse.IOerror{Code: syscall.EACCES}
 	}
 
+	if flags == syscall.O_WRONLY && !h.EnforceDisabled {
+		return fuse.IOerror{Code: syscall.EPERM}
+	}
+
 	if err := n.Open(); err != nil {
 		logrus.Debugf("Error opening file %v", h.Path)
 		return fuse.IOerror{Code: syscall.EIO}
@@ -130,43 +142,29 @@ func (h *KernelSysrqHandler) Read(
 		return 0, io.EOF
 	}
 
-	name := n.Name()
-	path := n.Path()
-	cntr := req.Container
-
 	// Ensure operation is generated from within a registered sys container.
-	if cntr == nil {
+	if req.Container == nil {
 		logrus.Errorf("Could not find the container originating this request (pid %v)",
 			req.Pid)
 		return 0, errors.New("Container not found")
 	}
 
-	// Check if this resource has been initialized for this container. Otherwise,
-	// fetch the information from the host FS and store it accordingly within
-	// the container struct.
-	cntr.Lock()
-	data, ok := cntr.Data(path, name)
-	if !ok {
-		// Read from host FS to extract the existing value.
-		curHostVal, err := n.ReadLine()
-		if err != nil && err != io.EOF {
-			cntr.Unlock()
-			logrus.Errorf("Could not read from file %v", h.Path)
-			return 0, fuse.IOerror{Code: syscall.EIO}
-		}
-
-		// High-level verification to ensure that format is the expected one.
-		_, err = strconv.Atoi(curHostVal)
-		if err != nil {
-			cntr.Unlock()
-			logrus.Errorf("Unsupported content read from file %v, error %v", h.Path, err)
-			return 0, fuse.IOerror{Code: syscall.EINVAL}
-		}
-
-		data = curHostVal
-		cntr.SetData(path, name, data)
-	}
-	cntr.Unlock()
+	// Read from host FS to extract the existing value (no caching here, as the
+	// host value could be altered by other containers in 'EnforceDisabled' mode).
+	h.Lock.Lock()
+	data, err := n.ReadLine()
+	h.Lock.Unlock()
+	if err != nil && err != io.EOF {
+		logrus.Errorf("Could not read from file %v", h.Path)
+		return 0, fuse.IOerror{Code: syscall.EIO}
+	}
+
+	// High-level verification to ensure that format is the expected one.
+	_, err = strconv.Atoi(data)
+	if err != nil {
+		logrus.Errorf("Unsupported content read from file %v, error %v", h.Path, err)
+		return 0, fuse.IOerror{Code: syscall.EINVAL}
+	}
 
 	data += "\n"
 
@@ -179,12 +177,8 @@ func (h *KernelSysrqHandler) Write(
 
 	logrus.Debugf("Executing %v Write() method", h.Name)
 
-	name := n.Name()
-	path := n.Path()
-	cntr := req.Container
-
 	// Ensure operation is generated from within a registered sys container.
-	if cntr == nil {
+	if req.Container == nil {
 		logrus.Errorf("Could not find the container originating this request (pid %v)",
 			req.Pid)
 		return 0, errors.New("Container not found")
@@ -202,15 +196,71 @@ func (h *KernelSysrqHandler) Write(
 		return 0, fuse.IOerror{Code: syscall.EINVAL}
 	}
 
-	// Store the new value within the container struct.
-	cntr.Lock()
-	defer cntr.Unlock()
+	// Only requests to disable sysrq can reach the host FS.
+	if !h.EnforceDisabled || newValInt != 0 {
+		return 0, fuse.IOerror{Code: syscall.EPERM}
+	}
+
+	h.Lock.Lock()
+	defer h.Lock.Unlock()
 
-	cntr.SetData(path, name, strconv.FormatInt(newValInt, 10))
+	if err := n.WriteFile([]byte("0")); err != nil && !h.Service.IgnoreErrors() {
+		logrus.Errorf("Could not write to file %v: %v", h.Path, err)
+		return 0, fuse.IOerror{Code: syscall.EIO}
+	}
 
 	return len(req.Data), nil
 }
 
+func (h *KernelSysrqHandler) Writable() bool {
+	return h.EnforceDisabled
+}
+
+func (h *KernelSysrqHandler) MergePolicy() domain.MergePolicy {
+
+	// Disabling requests prevail over any other value.
+	if h.EnforceDisabled {
+		return domain.MergePolicyMin
+	}
+
+	return domain.MergePolicyNone
+}
+
+// Reconfigure returns a copy of the handler operating in the passed write mode
+// ('read-only' or 'enforce-disabled'). The merge policy is implied by the write
+// mode, and allow-lists are not supported.
+func (h *KernelSysrqHandler) Reconfigure(
+	cfg domain.HandlerConfig) (domain.HandlerIface, error) {
+
+	if cfg.AllowedContainers != nil {
+		return nil, errors.New("allow-list not supported")
+	}
+	if cfg.AcceptMissing != nil {
+		return nil, errors.New("missing-resource policy not supported")
+	}
+
+	nh := &KernelSysrqHandler{
+		HandlerBase:     h.HandlerBase.Clone(),
+		EnforceDisabled: h.EnforceDisabled,
+	}
+
+	switch cfg.WriteMode {
+	case "":
+	case domain.WriteModeReadOnly:
+		nh.EnforceDisabled = false
+	case domain.WriteModeEnforceDisabled:
+		nh.EnforceDisabled = true
+	default:
+		return nil, errors.New("write mode not supported")
+	}
+
+	if cfg.MergePolicy != "" && cfg.MergePolicy != nh.MergePolicy() {
+		return nil, errors.New("merge policy not supported")
+	}
+
+	return nh, nil
+}
+
 func (h *KernelSysrqHandler) ReadDirAll(
 	n domain.IOnodeIface,
 	req *domain.HandlerRequest) ([]os.FileInfo, error) {
@@ -0,0 +1,238 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations_test
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+	"github.com/nestybox/sysbox-fs/handler/implementations"
+)
+
+func TestKernelSysrqHandler_Write(t *testing.T) {
+
+	// Host FS initial state.
+	const hostVal = "176"
+
+	cntr := css.ContainerCreate("c1", uint32(1001), time.Time{}, 231072, 65535,
+		231072, 65535, nil, nil, false, nil)
+
+	tests := []struct {
+		name            string
+		enforceDisabled bool
+		flags           int
+		data            string
+		wantErrVal      error
+		wantHostVal     string
+	}{
+		{
+			//
+			// Test-case 1: Default (read-only) mode. Opening for write must be
+			// rejected (EPERM).
+			//
+			name:        "1",
+			flags:       syscall.O_WRONLY,
+			wantErrVal:  fuse.IOerror{Code: syscall.EPERM},
+			wantHostVal: hostVal,
+		},
+		{
+			//
+			// Test-case 2: Default (read-only) mode. Even requests to disable
+			// sysrq must be rejected (EPERM) and host FS left untouched.
+			//
+			name:        "2",
+			data:        "0\n",
+			wantErrVal:  fuse.IOerror{Code: syscall.EPERM},
+			wantHostVal: hostVal,
+		},
+		{
+			//
+			// Test-case 3: Enforce-disabled mode. Host FS must be forced to 0.
+			//
+			name:            "3",
+			enforceDisabled: true,
+			flags:           syscall.O_WRONLY,
+			data:            "0\n",
+			wantHostVal:     "0",
+		},
+		{
+			//
+			// Test-case 4: Enforce-disabled mode. Enabling sysrq functions is
+			// not allowed (EPERM).
+			//
+			name:            "4",
+			enforceDisabled: true,
+			data:            "0x10\n",
+			wantErrVal:      fuse.IOerror{Code: syscall.EPERM},
+			wantHostVal:     hostVal,
+		},
+		{
+			//
+			// Test-case 5: Value beyond the supported bitmask (EINVAL).
+			//
+			name:            "5",
+			enforceDisabled: true,
+			data:            "512\n",
+			wantErrVal:      fuse.IOerror{Code: syscall.EINVAL},
+			wantHostVal:     hostVal,
+		},
+	}
+
+	//
+	// Testcase executions.
+	//
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &implementations.KernelSysrqHandler{
+				HandlerBase: domain.HandlerBase{
+					Name:    "kernelSysrq",
+					Path:    "/proc/sys/kernel/sysrq",
+					Type:    domain.NODE_SUBSTITUTION,
+					Enabled: true,
+					Service: hds,
+				},
+				EnforceDisabled: tt.enforceDisabled,
+			}
+
+			n := ios.NewIOnode("sysrq", "/proc/sys/kernel/sysrq", 0)
+			if err := n.WriteFile([]byte(hostVal)); err != nil {
+				t.Fatalf("Could not initialize host file: %v", err)
+			}
+
+			req := &domain.HandlerRequest{
+				Pid:       cntr.InitPid(),
+				Data:      []byte(tt.data),
+				Container: cntr,
+			}
+
+			var err error
+			if tt.flags != 0 {
+				n.SetOpenFlags(tt.flags)
+				err = h.Open(n, req)
+				if err == nil {
+					h.Close(n)
+				}
+			}
+			if err == nil && tt.data != "" {
+				_, err = h.Write(n, req)
+			}
+			if err != tt.wantErrVal {
+				t.Errorf("KernelSysrqHandler error = %v, want %v", err, tt.wantErrVal)
+			}
+
+			// Reads must always display the host FS value.
+			buf := make([]byte, 32)
+			rn, err := h.Read(n, &domain.HandlerRequest{
+				Pid:       cntr.InitPid(),
+				Data:      buf,
+				Container: cntr,
+			})
+			if err != nil {
+				t.Fatalf("KernelSysrqHandler.Read() error = %v", err)
+			}
+			if got := string(buf[:rn]); got != tt.wantHostVal+"\n" {
+				t.Errorf("KernelSysrqHandler.Read() = %q, want %q", got, tt.wantHostVal+"\n")
+			}
+		})
+	}
+}
+
+func TestKernelSysrqHandler_Reconfigure(t *testing.T) {
+
+	h := &implementations.KernelSysrqHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:    "kernelSysrq",
+			Path:    "/proc/sys/kernel/sysrq",
+			Type:    domain.NODE_SUBSTITUTION,
+			Enabled: true,
+			Service: hds,
+		},
+	}
+
+	tests := []struct {
+		name         string
+		cfg          domain.HandlerConfig
+		wantErr      bool
+		wantWritable bool
+		wantPolicy   domain.MergePolicy
+	}{
+		{
+			//
+			// Test-case 1: Enforce-disabled mode.
+			//
+			name:         "1",
+			cfg:          domain.HandlerConfig{WriteMode: domain.WriteModeEnforceDisabled},
+			wantErr:      false,
+			wantWritable: true,
+			wantPolicy:   domain.MergePolicyMin,
+		},
+		{
+			//
+			// Test-case 2: Read-only mode.
+			//
+			name:         "2",
+			cfg:          domain.HandlerConfig{WriteMode: domain.WriteModeReadOnly},
+			wantErr:      false,
+			wantWritable: false,
+			wantPolicy:   domain.MergePolicyNone,
+		},
+		{
+			//
+			// Test-case 3: Unsupported write mode.
+			//
+			name:    "3",
+			cfg:     domain.HandlerConfig{WriteMode: domain.WriteModePassthrough},
+			wantErr: true,
+		},
+		{
+			//
+			// Test-case 4: Allow-lists are not supported.
+			//
+			name:    "4",
+			cfg:     domain.HandlerConfig{AllowedContainers: []string{"c1"}},
+			wantErr: true,
+		},
+	}
+
+	//
+	// Testcase executions.
+	//
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nh, err := h.Reconfigure(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("KernelSysrqHandler.Reconfigure() error = %v, wantErr %v",
+					err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if nh.Writable() != tt.wantWritable || nh.MergePolicy() != tt.wantPolicy {
+				t.Errorf("KernelSysrqHandler.Reconfigure() Writable() = %v, MergePolicy() = %v; want %v, %v",
+					nh.Writable(), nh.MergePolicy(), tt.wantWritable, tt.wantPolicy)
+			}
+		})
+	}
+
+	if h.EnforceDisabled {
+		t.Errorf("KernelSysrqHandler.Reconfigure() altered the original handler")
+	}
+}
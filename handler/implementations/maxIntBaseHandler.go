@@ -17,27 +17,37 @@
 package implementations
 
 import (
-	"errors"
-	"io"
-	"math/rand"
 	"os"
-	"strconv"
-	"strings"
 	"syscall"
-	"time"
 
 	"github.com/sirupsen/logrus"
 
 	"github.com/nestybox/sysbox-fs/domain"
-	"github.com/nestybox/sysbox-fs/fuse"
 )
 
 // This is a base handler for kernel sysctls exposed inside a sys container that
 // consist of a single integer value and where the value written to the host
-// kernel is the max value across sys containers.
+// kernel is the max value across sys containers. Values are handled as 64-bit
+// integers, as some of these resources (e.g. fs.aio-max-nr) are 'unsigned long'
+// kernel variables. The merge logic itself is the one of MergeBaseHandler (see
+// hostMerger), with the policy fixed to 'max'.
 
 type MaxIntBaseHandler struct {
 	domain.HandlerBase
+
+	// Lowest value accepted by this resource (zero by default, as negative
+	// values make no sense for any of the resources handled here).
+	MinVal int64
+
+	// Display the lowest of the container's value and the host's one during
+	// reads. Useful for resources where the host value is the effective one, so
+	// that containers don't see values the kernel won't honor.
+	MinReadback bool
+
+	// Value the containers' view of the resource is initialized with, instead
+	// of the host's current one, so that containers start off a known value
+	// regardless of the host tuning. Empty means the host value.
+	Template string
 }
 
 func (h *MaxIntBaseHandler) Lookup(
@@ -64,82 +74,23 @@ func (h *MaxIntBaseHandler) Open(
 
 	logrus.Debugf("Executing %v Open() method\n", h.Name)
 
-	flags := n.OpenFlags()
-	if flags != syscall.O_RDONLY && flags != syscall.O_WRONLY {
-		return fuse.IOerror{Code: syscall.EACCES}
-	}
-
-	// During 'writeOnly' accesses, we must grant read-write rights temporarily
-	// to allow push() to carry out the expected 'write' operation, as well as a
-	// 'read' one too.
-	if flags == syscall.O_WRONLY {
-		n.SetOpenFlags(syscall.O_RDWR)
-	}
-
-	if err := n.Open(); err != nil {
-		logrus.Debugf("Error opening file %v", h.Path)
-		return fuse.IOerror{Code: syscall.EIO}
-	}
-
-	return nil
+	return h.merger().open(n)
 }
 
 func (h *MaxIntBaseHandler) Close(n domain.IOnodeIface) error {
 
 	logrus.Debugf("Executing Close() method on %v handler", h.Name)
 
-	if err := n.Close(); err != nil {
-		logrus.Debugf("Error closing file %v", h.Path)
-		return fuse.IOerror{Code: syscall.EIO}
-	}
-
-	return nil
+	return h.merger().close(n)
 }
 
 func (h *MaxIntBaseHandler) Read(
 	n domain.IOnodeIface,
 	req *domain.HandlerRequest) (int, error) {
 
-	var err error
-
 	logrus.Debugf("Executing %v Read() method", h.Name)
 
-	// We are dealing with a single integer element being read, so we can save
-	// some cycles by returning right away if offset is any higher than zero.
-	if req.Offset > 0 {
-		return 0, io.EOF
-	}
-
-	name := n.Name()
-	path := n.Path()
-	cntr := req.Container
-
-	// Ensure operation is generated from within a registered sys container.
-	if cntr == nil {
-		logrus.Errorf("Could not find the container originating this request (pid %v)",
-			req.Pid)
-		return 0, errors.New("Container not found")
-	}
-
-	// Check if this resource has been initialized for this container. Otherwise,
-	// fetch the information from the host FS and store it accordingly within
-	// the container struct.
-	cntr.Lock()
-	data, ok := cntr.Data(path, name)
-	if !ok {
-		data, err = h.fetchFile(n, cntr)
-		if err != nil && err != io.EOF {
-			cntr.Unlock()
-			return 0, err
-		}
-
-		cntr.SetData(path, name, data)
-	}
-	cntr.Unlock()
-
-	data += "\n"
-
-	return copyResultBuffer(req.Data, []byte(data))
+	return h.merger().read(n, req)
 }
 
 func (h *MaxIntBaseHandler) Write(
@@ -148,63 +99,7 @@ func (h *MaxIntBaseHandler) Write(
 
 	logrus.Debugf("Executing %v Write() method", h.Name)
 
-	name := n.Name()
-	path := n.Path()
-	cntr := req.Container
-
-	newMax := strings.TrimSpace(string(req.Data))
-	newMaxInt, err := strconv.Atoi(newMax)
-	if err != nil {
-		logrus.Errorf("Unexpected error: %v", err)
-		return 0, err
-	}
-
-	// Ensure operation is generated from within a registered sys container.
-	if cntr == nil {
-		logrus.Errorf("Could not find the container originating this request (pid %v)",
-			req.Pid)
-		return 0, errors.New("Container not found")
-	}
-
-	cntr.Lock()
-	defer cntr.Unlock()
-
-	// Check if this resource has been initialized for this container. If not,
-	// push it to the host FS and store it within the container struct.
-	curMax, ok := cntr.Data(path, name)
-	if !ok {
-		if err := h.pushFile(n, cntr, newMaxInt); err != nil {
-			return 0, err
-		}
-
-		cntr.SetData(path, name, newMax)
-
-		return len(req.Data), nil
-	}
-
-	curMaxInt, err := strconv.Atoi(curMax)
-	if err != nil {
-		logrus.Errorf("Unexpected error: %v", err)
-		return 0, err
-	}
-
-	// If new value is lower/equal than the existing one, then let's update this
-	// new value into the container struct but not push it down to the kernel.
-	if newMaxInt <= curMaxInt {
-		cntr.SetData(path, name, newMax)
-
-		return len(req.Data), nil
-	}
-
-	// Push new value to the kernel.
-	if err := h.pushFile(n, cntr, newMaxInt); err != nil {
-		return 0, io.EOF
-	}
-
-	// Writing the new value into container-state struct.
-	cntr.SetData(path, name, newMax)
-
-	return len(req.Data), nil
+	return h.merger().write(n, req)
 }
 
 func (h *MaxIntBaseHandler) ReadDirAll(
@@ -214,106 +109,35 @@ func (h *MaxIntBaseHandler) ReadDirAll(
 	return nil, nil
 }
 
-func (h *MaxIntBaseHandler) fetchFile(
-	n domain.IOnodeIface,
-	c domain.ContainerIface) (string, error) {
-
-	// We need the per-resource lock since we are about to access the resource on
-	// the host FS. See pushFile() for a full explanation.
-	h.Lock.Lock()
-
-	// Read from host FS to extract the existing value.
-	curHostMax, err := n.ReadLine()
-	if err != nil && err != io.EOF {
-		h.Lock.Unlock()
-		logrus.Errorf("Could not read from file %v", h.Path)
-		return "", err
-	}
+func (h *MaxIntBaseHandler) MergePolicy() domain.MergePolicy {
+	return domain.MergePolicyMax
+}
 
-	h.Lock.Unlock()
+// Reconfigure returns a MergeBaseHandler equivalent to this handler, operating
+// with the passed merge policy. Write modes and allow-lists are not supported.
+func (h *MaxIntBaseHandler) Reconfigure(
+	cfg domain.HandlerConfig) (domain.HandlerIface, error) {
 
-	// High-level verification to ensure that format is the expected one.
-	_, err = strconv.Atoi(curHostMax)
-	if err != nil {
-		logrus.Errorf("Unexpected content read from file %v, error %v", h.Path, err)
-		return "", err
+	m := &MergeBaseHandler{
+		HandlerBase: h.HandlerBase.Clone(),
+		Policy:      domain.MergePolicyMax,
+		MinVal:      h.MinVal,
+		MinReadback: h.MinReadback,
+		Template:    h.Template,
 	}
 
-	return curHostMax, nil
+	return m.Reconfigure(cfg)
 }
 
-func (h *MaxIntBaseHandler) pushFile(
-	n domain.IOnodeIface,
-	c domain.ContainerIface,
-	newMaxInt int) error {
-
-	// We need the per-resource lock since we are about to access the resource on
-	// the host FS and multiple sys containers could be accessing that same
-	// resource concurrently.
-	//
-	// But that's not sufficient. Some users may deploy sysbox inside a
-	// privileged container, and thus can have multiple sysbox instances running
-	// concurrently on the same host. If those sysbox instances write conflicting
-	// values to a kernel resource that uses this handler (e.g., a sysctl under
-	// /proc/sys), a race condition arises that could cause the value to be
-	// written to not be the max across all instances.
-	//
-	// To reduce the chance of this ocurring, in addition to the per-resource
-	// lock, we use a heuristic in which we read-after-write to verify the value
-	// of the resource is larger or equal to the one we wrote. If it isn't, it
-	// means some other agent on the host wrote a smaller value to the resource
-	// after we wrote to it, so we must retry the write.
-	//
-	// When retrying, we wait a small but random amount of time to reduce the
-	// chance of hitting the race condition again. And we retry a limited amount
-	// of times.
-	//
-	// Note that this solution works well for resolving race conditions among
-	// sysbox instances, but may not address race conditions with other host
-	// agents that write to the same sysctl. That's because there is no guarantee
-	// that the other host agent will read-after-write and retry as sysbox does.
-
-	h.Lock.Lock()
-	defer h.Lock.Unlock()
-
-	retries := 5
-	retryDelay := 100 // microsecs
-
-	for i := 0; i < retries; i++ {
-
-		curHostMax, err := n.ReadLine()
-		if err != nil && err != io.EOF {
-			return err
-		}
-		curHostMaxInt, err := strconv.Atoi(curHostMax)
-		if err != nil {
-			logrus.Errorf("Unexpected error: %v", err)
-			return err
-		}
-
-		// If the existing host value is larger than the new one to configure,
-		// then let's just return here as we want to keep the largest value
-		// in the host kernel.
-		if newMaxInt <= curHostMaxInt {
-			return nil
-		}
-
-		// When retrying, wait a random delay to reduce chances of a new collision
-		if i > 0 {
-			d := rand.Intn(retryDelay)
-			time.Sleep(time.Duration(d) * time.Microsecond)
-		}
-
-		// Push down to host kernel the new (larger) value.
-		msg := []byte(strconv.Itoa(newMaxInt))
-		err = n.WriteFile(msg)
-		if err != nil && !h.Service.IgnoreErrors() {
-			logrus.Errorf("Could not write %d to file: %s", newMaxInt, err)
-			return err
-		}
+func (h *MaxIntBaseHandler) merger() *hostMerger {
+	return &hostMerger{
+		hb:          &h.HandlerBase,
+		policy:      domain.MergePolicyMax,
+		vtype:       mergeValueType(&h.HandlerBase),
+		minVal:      h.MinVal,
+		minReadback: h.MinReadback,
+		template:    h.Template,
 	}
-
-	return nil
 }
 
 func (h *MaxIntBaseHandler) GetName() string {
@@ -0,0 +1,1113 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations_test
+
+import (
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+	"github.com/nestybox/sysbox-fs/handler/implementations"
+	"github.com/nestybox/sysbox-fs/mocks"
+)
+
+func TestMaxIntBaseHandler_Write(t *testing.T) {
+
+	h := &implementations.MaxIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "coreNetdevMaxBacklog",
+			Path:      "/proc/sys/net/core/netdev_max_backlog",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+			Service:   hds,
+		},
+		MinVal: 1,
+	}
+
+	n := ios.NewIOnode("netdev_max_backlog", "/proc/sys/net/core/netdev_max_backlog", 0)
+	if err := n.WriteFile([]byte("1000")); err != nil {
+		t.Fatalf("Could not initialize host file: %v", err)
+	}
+
+	c1 := css.ContainerCreate("c1", uint32(1001), time.Time{}, 231072, 65535, 231072, 65535, nil, nil, false, nil)
+	c2 := css.ContainerCreate("c2", uint32(2001), time.Time{}, 296608, 65535, 296608, 65535, nil, nil, false, nil)
+
+	tests := []struct {
+		name        string
+		cntr        domain.ContainerIface
+		data        string
+		wantErr     bool
+		wantErrVal  error
+		wantHostVal string
+		wantCntrVal string
+	}{
+		{
+			//
+			// Test-case 1: First container raises the value. Host FS must be
+			// updated.
+			//
+			name:        "1",
+			cntr:        c1,
+			data:        "4000\n",
+			wantHostVal: "4000",
+			wantCntrVal: "4000",
+		},
+		{
+			//
+			// Test-case 2: Second container sets a lower value. Host FS must keep
+			// the max across containers.
+			//
+			name:        "2",
+			cntr:        c2,
+			data:        "2000\n",
+			wantHostVal: "4000",
+			wantCntrVal: "2000",
+		},
+		{
+			//
+			// Test-case 3: Second container raises the value beyond the current
+			// max. Host FS must be updated.
+			//
+			name:        "3",
+			cntr:        c2,
+			data:        "8000\n",
+			wantHostVal: "8000",
+			wantCntrVal: "8000",
+		},
+		{
+			//
+			// Test-case 4: First container lowers its value. Host FS is left
+			// untouched.
+			//
+			name:        "4",
+			cntr:        c1,
+			data:        "3000\n",
+			wantHostVal: "8000",
+			wantCntrVal: "3000",
+		},
+		{
+			//
+			// Test-case 5: Value below the supported minimum (EINVAL).
+			//
+			name:        "5",
+			cntr:        c1,
+			data:        "0\n",
+			wantErr:     true,
+			wantErrVal:  fuse.IOerror{Code: syscall.EINVAL},
+			wantHostVal: "8000",
+			wantCntrVal: "3000",
+		},
+	}
+
+	//
+	// Testcase executions.
+	//
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &domain.HandlerRequest{
+				Pid:       tt.cntr.InitPid(),
+				Data:      []byte(tt.data),
+				Container: tt.cntr,
+			}
+
+			_, err := h.Write(n, req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("MaxIntBaseHandler.Write() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && tt.wantErrVal != nil && err != tt.wantErrVal {
+				t.Errorf("MaxIntBaseHandler.Write() error = %v, wantErr %v, wantErrVal %v",
+					err, tt.wantErr, tt.wantErrVal)
+			}
+
+			gotHostVal, err := n.ReadLine()
+			if err != nil {
+				t.Fatalf("Could not read host file: %v", err)
+			}
+			if gotHostVal != tt.wantHostVal {
+				t.Errorf("MaxIntBaseHandler.Write() host value = %v, want %v",
+					gotHostVal, tt.wantHostVal)
+			}
+
+			// Container's view must reflect the value it last wrote.
+			buf := make([]byte, 32)
+			rn, err := h.Read(n, &domain.HandlerRequest{
+				Pid:       tt.cntr.InitPid(),
+				Data:      buf,
+				Container: tt.cntr,
+			})
+			if err != nil {
+				t.Fatalf("MaxIntBaseHandler.Read() error = %v", err)
+			}
+			if got := strings.TrimSpace(string(buf[:rn])); got != tt.wantCntrVal {
+				t.Errorf("MaxIntBaseHandler.Read() = %v, want %v", got, tt.wantCntrVal)
+			}
+		})
+	}
+}
+
+func TestMaxIntBaseHandler_Write64(t *testing.T) {
+
+	h := &implementations.MaxIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "fsAioMaxNr",
+			Path:      "/proc/sys/fs/aio-max-nr",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+			Service:   hds,
+		},
+		MinVal: 1,
+	}
+
+	n := ios.NewIOnode("aio-max-nr", "/proc/sys/fs/aio-max-nr", 0)
+	if err := n.WriteFile([]byte("65536")); err != nil {
+		t.Fatalf("Could not initialize host file: %v", err)
+	}
+
+	c1 := css.ContainerCreate("c1", uint32(1001), time.Time{}, 231072, 65535, 231072, 65535, nil, nil, false, nil)
+	c2 := css.ContainerCreate("c2", uint32(2001), time.Time{}, 296608, 65535, 296608, 65535, nil, nil, false, nil)
+
+	// A value beyond the 32-bit range must be pushed down to the host, and must
+	// survive a subsequent (lower) write from a different container.
+	writes := []struct {
+		cntr        domain.ContainerIface
+		data        string
+		wantHostVal string
+	}{
+		{c1, "8589934592\n", "8589934592"},
+		{c2, "1048576\n", "8589934592"},
+	}
+
+	for _, w := range writes {
+		req := &domain.HandlerRequest{
+			Pid:       w.cntr.InitPid(),
+			Data:      []byte(w.data),
+			Container: w.cntr,
+		}
+
+		if _, err := h.Write(n, req); err != nil {
+			t.Fatalf("MaxIntBaseHandler.Write() error = %v", err)
+		}
+
+		gotHostVal, err := n.ReadLine()
+		if err != nil {
+			t.Fatalf("Could not read host file: %v", err)
+		}
+		if gotHostVal != w.wantHostVal {
+			t.Errorf("MaxIntBaseHandler.Write() host value = %v, want %v",
+				gotHostVal, w.wantHostVal)
+		}
+	}
+}
+
+func TestMaxIntBaseHandler_ValueType(t *testing.T) {
+
+	h := &implementations.MaxIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "maxIntUint64",
+			Path:      "/proc/sys/maxint/uint64",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+			Service:   hds,
+			ValueType: domain.ValueTypeUint64,
+		},
+	}
+
+	n := ios.NewIOnode("uint64", "/proc/sys/maxint/uint64", 0)
+	if err := n.WriteFile([]byte("65536")); err != nil {
+		t.Fatalf("Could not initialize host file: %v", err)
+	}
+
+	cntr := css.ContainerCreate("c1", uint32(1001), time.Time{}, 231072, 65535, 231072, 65535, nil, nil, false, nil)
+
+	write := func(val string) error {
+		_, err := h.Write(n, &domain.HandlerRequest{
+			Pid:       cntr.InitPid(),
+			Data:      []byte(val + "\n"),
+			Container: cntr,
+		})
+		return err
+	}
+
+	// Values beyond the int64 range are accepted for unsigned resources.
+	if err := write("18446744073709551615"); err != nil {
+		t.Fatalf("MaxIntBaseHandler.Write() error = %v", err)
+	}
+	if got, _ := n.ReadLine(); got != "18446744073709551615" {
+		t.Errorf("MaxIntBaseHandler.Write() host value = %v, want 18446744073709551615", got)
+	}
+
+	// Values not matching the declared type are rejected (EINVAL).
+	for _, val := range []string{"-1", "1 2", "foo"} {
+		if err := write(val); err != (fuse.IOerror{Code: syscall.EINVAL}) {
+			t.Errorf("MaxIntBaseHandler.Write(%q) error = %v, want %v",
+				val, err, fuse.IOerror{Code: syscall.EINVAL})
+		}
+	}
+}
+
+func TestMaxIntBaseHandler_CacheStats(t *testing.T) {
+
+	h := &implementations.MaxIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "coreSomaxconn",
+			Path:      "/proc/sys/net/core/somaxconn",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+			Service:   hds,
+		},
+	}
+
+	n := ios.NewIOnode("somaxconn", "/proc/sys/net/core/somaxconn", 0)
+	if err := n.WriteFile([]byte("128")); err != nil {
+		t.Fatalf("Could not initialize host file: %v", err)
+	}
+
+	cntr := css.ContainerCreate("c1", uint32(1001), time.Time{}, 231072, 65535,
+		231072, 65535, nil, nil, false, nil)
+
+	// First read must be fetched from the host FS (miss), and all subsequent
+	// ones served from the container's data store (hits).
+	want := []domain.CacheStats{
+		{Hits: 0, Misses: 1},
+		{Hits: 1, Misses: 1},
+		{Hits: 2, Misses: 1},
+	}
+
+	for i, w := range want {
+		buf := make([]byte, 32)
+		if _, err := h.Read(n, &domain.HandlerRequest{
+			Pid:       cntr.InitPid(),
+			Data:      buf,
+			Container: cntr,
+		}); err != nil {
+			t.Fatalf("MaxIntBaseHandler.Read() error = %v", err)
+		}
+
+		if got := cntr.CacheStats(); got != w {
+			t.Errorf("read %d: container.CacheStats() = %+v, want %+v", i+1, got, w)
+		}
+	}
+}
+
+func TestMaxIntBaseHandler_SingleInstance(t *testing.T) {
+
+	tests := []struct {
+		name           string
+		singleInstance bool
+		wantReads      int
+		wantWrites     int
+	}{
+		{
+			//
+			// Test-case 1: Default mode. The value pushed to the host FS must be
+			// read back to detect writes from other sysbox instances.
+			//
+			name:           "1",
+			singleInstance: false,
+			wantReads:      2,
+			wantWrites:     1,
+		},
+		{
+			//
+			// Test-case 2: Single-instance mode. A single write is expected, with
+			// no read-after-write verification.
+			//
+			name:           "2",
+			singleInstance: true,
+			wantReads:      1,
+			wantWrites:     1,
+		},
+	}
+
+	//
+	// Testcase executions.
+	//
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hs := &mocks.HandlerServiceIface{}
+			hs.On("SingleInstance").Return(tt.singleInstance)
+			hs.On("PushRetries").Return(5, 100*time.Microsecond)
+			hs.On("WritePending", mock.Anything, mock.Anything, mock.Anything).Return(false)
+			hs.On("CoalesceWrite", mock.Anything, mock.Anything, mock.Anything,
+				mock.Anything, mock.Anything).Return(false)
+
+			h := &implementations.MaxIntBaseHandler{
+				HandlerBase: domain.HandlerBase{
+					Name:      "coreSomaxconn",
+					Path:      "/proc/sys/net/core/somaxconn",
+					Type:      domain.NODE_SUBSTITUTION,
+					Enabled:   true,
+					Cacheable: true,
+					Service:   hs,
+				},
+			}
+
+			// Host FS reflects the new value right after it's written.
+			n := &mocks.IOnodeIface{}
+			n.On("Name").Return("somaxconn")
+			n.On("Path").Return("/proc/sys/net/core/somaxconn")
+			n.On("ReadLine").Return("128", nil).Once()
+			n.On("ReadLine").Return("4096", nil)
+			n.On("WriteFile", []byte("4096")).Return(nil)
+
+			cntr := css.ContainerCreate("c1", uint32(1001), time.Time{}, 231072,
+				65535, 231072, 65535, nil, nil, false, nil)
+
+			if _, err := h.Write(n, &domain.HandlerRequest{
+				Pid:       cntr.InitPid(),
+				Data:      []byte("4096\n"),
+				Container: cntr,
+			}); err != nil {
+				t.Fatalf("MaxIntBaseHandler.Write() error = %v", err)
+			}
+
+			n.AssertNumberOfCalls(t, "ReadLine", tt.wantReads)
+			n.AssertNumberOfCalls(t, "WriteFile", tt.wantWrites)
+		})
+	}
+}
+
+func TestMaxIntBaseHandler_PushRetries(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		retries    int
+		wantWrites int
+	}{
+		{
+			//
+			// Test-case 1: Default settings. Five attempts expected before
+			// giving up.
+			//
+			name:       "1",
+			retries:    5,
+			wantWrites: 5,
+		},
+		{
+			//
+			// Test-case 2: Custom retry count.
+			//
+			name:       "2",
+			retries:    2,
+			wantWrites: 2,
+		},
+		{
+			//
+			// Test-case 3: Retries disabled. A single attempt expected.
+			//
+			name:       "3",
+			retries:    1,
+			wantWrites: 1,
+		},
+	}
+
+	//
+	// Testcase executions.
+	//
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hs := &mocks.HandlerServiceIface{}
+			hs.On("SingleInstance").Return(false)
+			hs.On("PushRetries").Return(tt.retries, time.Duration(0))
+			hs.On("WritePending", mock.Anything, mock.Anything, mock.Anything).Return(false)
+			hs.On("CoalesceWrite", mock.Anything, mock.Anything, mock.Anything,
+				mock.Anything, mock.Anything).Return(false)
+
+			h := &implementations.MaxIntBaseHandler{
+				HandlerBase: domain.HandlerBase{
+					Name:      "coreSomaxconn",
+					Path:      "/proc/sys/net/core/somaxconn",
+					Type:      domain.NODE_SUBSTITUTION,
+					Enabled:   true,
+					Cacheable: true,
+					Service:   hs,
+				},
+			}
+
+			// Host FS never reflects the written value, as if another sysbox
+			// instance kept overriding it.
+			n := &mocks.IOnodeIface{}
+			n.On("Name").Return("somaxconn")
+			n.On("Path").Return("/proc/sys/net/core/somaxconn")
+			n.On("ReadLine").Return("128", nil)
+			n.On("WriteFile", []byte("4096")).Return(nil)
+
+			cntr := css.ContainerCreate("c1", uint32(1001), time.Time{}, 231072,
+				65535, 231072, 65535, nil, nil, false, nil)
+
+			_, err := h.Write(n, &domain.HandlerRequest{
+				Pid:       cntr.InitPid(),
+				Data:      []byte("4096\n"),
+				Container: cntr,
+			})
+			if err != (fuse.IOerror{Code: syscall.EBUSY}) {
+				t.Errorf("MaxIntBaseHandler.Write() error = %v, want EBUSY", err)
+			}
+
+			n.AssertNumberOfCalls(t, "WriteFile", tt.wantWrites)
+		})
+	}
+}
+
+func TestMaxIntBaseHandler_WriteCoalescing(t *testing.T) {
+
+	// Writes are handed over to the coalescer, which is expected to be fed with
+	// every value of the burst (the last one superseding the previous ones),
+	// even those not prevailing as per the 'max' policy.
+	var (
+		vals []string
+		push func(val string) error
+	)
+	hs := &mocks.HandlerServiceIface{}
+	hs.On("SingleInstance").Return(true)
+	hs.On("WritePending", mock.Anything, mock.Anything, mock.Anything).Return(true)
+	hs.On("CoalesceWrite", mock.Anything, "/proc/sys/net/core/somaxconn", "somaxconn",
+		mock.Anything, mock.Anything).Return(true).Run(func(args mock.Arguments) {
+		vals = append(vals, args.String(3))
+		push = args.Get(4).(func(val string) error)
+	})
+
+	h := &implementations.MaxIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "coreSomaxconn",
+			Path:      "/proc/sys/net/core/somaxconn",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+			Service:   hs,
+		},
+	}
+
+	n := &mocks.IOnodeIface{}
+	n.On("Name").Return("somaxconn")
+	n.On("Path").Return("/proc/sys/net/core/somaxconn")
+	n.On("ReadLine").Return("128", nil)
+	n.On("WriteFile", []byte("2000")).Return(nil)
+
+	cntr := css.ContainerCreate("c1", uint32(1001), time.Time{}, 231072,
+		65535, 231072, 65535, nil, nil, false, nil)
+
+	// Burst of writes, the last of which doesn't prevail over the previous
+	// ones as per the 'max' policy.
+	for _, val := range []string{"1000", "4000", "8000", "2000"} {
+		if _, err := h.Write(n, &domain.HandlerRequest{
+			Pid:       cntr.InitPid(),
+			Data:      []byte(val + "\n"),
+			Container: cntr,
+		}); err != nil {
+			t.Fatalf("MaxIntBaseHandler.Write() error = %v", err)
+		}
+	}
+
+	// Container's view must reflect the last value right away.
+	buf := make([]byte, 32)
+	rn, err := h.Read(n, &domain.HandlerRequest{
+		Pid:       cntr.InitPid(),
+		Data:      buf,
+		Container: cntr,
+	})
+	if err != nil {
+		t.Fatalf("MaxIntBaseHandler.Read() error = %v", err)
+	}
+	if got := strings.TrimSpace(string(buf[:rn])); got != "2000" {
+		t.Errorf("MaxIntBaseHandler.Read() = %v, want %v", got, "2000")
+	}
+
+	if !reflect.DeepEqual(vals, []string{"1000", "4000", "8000", "2000"}) {
+		t.Errorf("MaxIntBaseHandler.Write() coalesced values = %v", vals)
+	}
+
+	// Host FS is only updated once the coalescer fires.
+	n.AssertNumberOfCalls(t, "WriteFile", 0)
+	if err := push("2000"); err != nil {
+		t.Errorf("MaxIntBaseHandler.Write() coalesced push error = %v", err)
+	}
+	n.AssertNumberOfCalls(t, "WriteFile", 1)
+}
+
+func TestMaxIntBaseHandler_MinReadback(t *testing.T) {
+
+	h := &implementations.MaxIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "ipv4TcpMaxSynBacklog",
+			Path:      "/proc/sys/net/ipv4/tcp_max_syn_backlog",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+			Service:   hds,
+		},
+		MinVal:      1,
+		MinReadback: true,
+	}
+
+	n := ios.NewIOnode("tcp_max_syn_backlog", "/proc/sys/net/ipv4/tcp_max_syn_backlog", 0)
+	if err := n.WriteFile([]byte("512")); err != nil {
+		t.Fatalf("Could not initialize host file: %v", err)
+	}
+
+	c1 := css.ContainerCreate("c1", uint32(1001), time.Time{}, 231072, 65535, 231072, 65535, nil, nil, false, nil)
+	c2 := css.ContainerCreate("c2", uint32(2001), time.Time{}, 296608, 65535, 296608, 65535, nil, nil, false, nil)
+
+	read := func(cntr domain.ContainerIface) string {
+		buf := make([]byte, 32)
+		rn, err := h.Read(n, &domain.HandlerRequest{
+			Pid:       cntr.InitPid(),
+			Data:      buf,
+			Container: cntr,
+		})
+		if err != nil {
+			t.Fatalf("MaxIntBaseHandler.Read() error = %v", err)
+		}
+		return strings.TrimSpace(string(buf[:rn]))
+	}
+
+	write := func(cntr domain.ContainerIface, val string) error {
+		_, err := h.Write(n, &domain.HandlerRequest{
+			Pid:       cntr.InitPid(),
+			Data:      []byte(val + "\n"),
+			Container: cntr,
+		})
+		return err
+	}
+
+	// Host FS must keep the max across containers, while each container
+	// displays its own value as long as it's honored by the host.
+	if err := write(c1, "4096"); err != nil {
+		t.Fatalf("MaxIntBaseHandler.Write() error = %v", err)
+	}
+	if err := write(c2, "2048"); err != nil {
+		t.Fatalf("MaxIntBaseHandler.Write() error = %v", err)
+	}
+	if got, _ := n.ReadLine(); got != "4096" {
+		t.Errorf("MaxIntBaseHandler.Write() host value = %v, want %v", got, "4096")
+	}
+	if got := read(c1); got != "4096" {
+		t.Errorf("MaxIntBaseHandler.Read() = %v, want %v", got, "4096")
+	}
+	if got := read(c2); got != "2048" {
+		t.Errorf("MaxIntBaseHandler.Read() = %v, want %v", got, "2048")
+	}
+
+	// Non-positive values must be rejected (EINVAL).
+	if err := write(c2, "0"); err != (fuse.IOerror{Code: syscall.EINVAL}) {
+		t.Errorf("MaxIntBaseHandler.Write() error = %v, want %v",
+			err, fuse.IOerror{Code: syscall.EINVAL})
+	}
+
+	// Host value lowered behind sysbox-fs' back. Containers must not display
+	// values beyond the one honored by the kernel.
+	if err := n.WriteFile([]byte("1024")); err != nil {
+		t.Fatalf("Could not update host file: %v", err)
+	}
+	if got := read(c1); got != "1024" {
+		t.Errorf("MaxIntBaseHandler.Read() = %v, want %v", got, "1024")
+	}
+	if got := read(c2); got != "1024" {
+		t.Errorf("MaxIntBaseHandler.Read() = %v, want %v", got, "1024")
+	}
+}
+
+func TestMaxIntBaseHandler_NrHugepages(t *testing.T) {
+
+	h := &implementations.MaxIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "vmNrHugepages",
+			Path:      "/proc/sys/vm/nr_hugepages",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+			Service:   hds,
+		},
+		MinReadback: true,
+	}
+
+	n := ios.NewIOnode("nr_hugepages", "/proc/sys/vm/nr_hugepages", 0)
+	if err := n.WriteFile([]byte("0")); err != nil {
+		t.Fatalf("Could not initialize host file: %v", err)
+	}
+
+	c1 := css.ContainerCreate("c1", uint32(1001), time.Time{}, 231072, 65535, 231072, 65535, nil, nil, false, nil)
+	c2 := css.ContainerCreate("c2", uint32(2001), time.Time{}, 296608, 65535, 296608, 65535, nil, nil, false, nil)
+
+	read := func(cntr domain.ContainerIface) string {
+		buf := make([]byte, 32)
+		rn, err := h.Read(n, &domain.HandlerRequest{
+			Pid:       cntr.InitPid(),
+			Data:      buf,
+			Container: cntr,
+		})
+		if err != nil {
+			t.Fatalf("MaxIntBaseHandler.Read() error = %v", err)
+		}
+		return strings.TrimSpace(string(buf[:rn]))
+	}
+
+	write := func(cntr domain.ContainerIface, val string) error {
+		_, err := h.Write(n, &domain.HandlerRequest{
+			Pid:       cntr.InitPid(),
+			Data:      []byte(val + "\n"),
+			Container: cntr,
+		})
+		return err
+	}
+
+	// Host FS must keep the largest reservation across containers; a smaller
+	// request from another container must not reduce it.
+	if err := write(c1, "128"); err != nil {
+		t.Fatalf("MaxIntBaseHandler.Write() error = %v", err)
+	}
+	if err := write(c2, "64"); err != nil {
+		t.Fatalf("MaxIntBaseHandler.Write() error = %v", err)
+	}
+	if got, _ := n.ReadLine(); got != "128" {
+		t.Errorf("MaxIntBaseHandler.Write() host value = %v, want %v", got, "128")
+	}
+	if got := read(c1); got != "128" {
+		t.Errorf("MaxIntBaseHandler.Read() = %v, want %v", got, "128")
+	}
+	if got := read(c2); got != "64" {
+		t.Errorf("MaxIntBaseHandler.Read() = %v, want %v", got, "64")
+	}
+
+	// Releasing hugepages in one container must not affect the host value.
+	if err := write(c2, "0"); err != nil {
+		t.Fatalf("MaxIntBaseHandler.Write() error = %v", err)
+	}
+	if got, _ := n.ReadLine(); got != "128" {
+		t.Errorf("MaxIntBaseHandler.Write() host value = %v, want %v", got, "128")
+	}
+
+	// Negative values must be rejected (EINVAL).
+	if err := write(c1, "-1"); err != (fuse.IOerror{Code: syscall.EINVAL}) {
+		t.Errorf("MaxIntBaseHandler.Write() error = %v, want %v",
+			err, fuse.IOerror{Code: syscall.EINVAL})
+	}
+
+	// Kernel could only allocate part of the requested pages. Containers must
+	// see the actually-available count.
+	if err := n.WriteFile([]byte("100")); err != nil {
+		t.Fatalf("Could not update host file: %v", err)
+	}
+	if got := read(c1); got != "100" {
+		t.Errorf("MaxIntBaseHandler.Read() = %v, want %v", got, "100")
+	}
+	if got := read(c2); got != "0" {
+		t.Errorf("MaxIntBaseHandler.Read() = %v, want %v", got, "0")
+	}
+}
+
+func TestMaxIntBaseHandler_RouteMaxSize(t *testing.T) {
+
+	h := &implementations.MaxIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "routeMaxSize",
+			Path:      "/proc/sys/net/ipv4/route/max_size",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+			Service:   hds,
+		},
+		MinVal: 1,
+	}
+
+	n := ios.NewIOnode("max_size", "/proc/sys/net/ipv4/route/max_size", 0)
+	if err := n.WriteFile([]byte("16384")); err != nil {
+		t.Fatalf("Could not initialize host file: %v", err)
+	}
+
+	c1 := css.ContainerCreate("c1", uint32(1001), time.Time{}, 231072, 65535, 231072, 65535, nil, nil, false, nil)
+	c2 := css.ContainerCreate("c2", uint32(2001), time.Time{}, 296608, 65535, 296608, 65535, nil, nil, false, nil)
+
+	write := func(cntr domain.ContainerIface, val string) error {
+		_, err := h.Write(n, &domain.HandlerRequest{
+			Pid:       cntr.InitPid(),
+			Data:      []byte(val + "\n"),
+			Container: cntr,
+		})
+		return err
+	}
+
+	tests := []struct {
+		name        string
+		cntr        domain.ContainerIface
+		val         string
+		wantErr     error
+		wantHostVal string
+	}{
+		{
+			//
+			// Test-case 1: Value above the host one. Host FS must be updated.
+			//
+			name:        "1",
+			cntr:        c1,
+			val:         "65536",
+			wantErr:     nil,
+			wantHostVal: "65536",
+		},
+		{
+			//
+			// Test-case 2: Lower value from another container. Host FS must keep
+			// the max.
+			//
+			name:        "2",
+			cntr:        c2,
+			val:         "32768",
+			wantErr:     nil,
+			wantHostVal: "65536",
+		},
+		{
+			//
+			// Test-case 3: Higher value from the second container. Host FS must
+			// be updated.
+			//
+			name:        "3",
+			cntr:        c2,
+			val:         "131072",
+			wantErr:     nil,
+			wantHostVal: "131072",
+		},
+		{
+			//
+			// Test-case 4: Non-positive value (EINVAL). Host FS left untouched.
+			//
+			name:        "4",
+			cntr:        c1,
+			val:         "0",
+			wantErr:     fuse.IOerror{Code: syscall.EINVAL},
+			wantHostVal: "131072",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := write(tt.cntr, tt.val); err != tt.wantErr {
+				t.Errorf("MaxIntBaseHandler.Write() error = %v, want %v", err, tt.wantErr)
+			}
+			if got, _ := n.ReadLine(); got != tt.wantHostVal {
+				t.Errorf("MaxIntBaseHandler.Write() host value = %v, want %v",
+					got, tt.wantHostVal)
+			}
+		})
+	}
+}
+
+func TestMaxIntBaseHandler_Mqueue(t *testing.T) {
+
+	c1 := css.ContainerCreate("c1", uint32(1001), time.Time{}, 231072, 65535, 231072, 65535, nil, nil, false, nil)
+	c2 := css.ContainerCreate("c2", uint32(2001), time.Time{}, 296608, 65535, 296608, 65535, nil, nil, false, nil)
+
+	tests := []struct {
+		name        string
+		cntr        domain.ContainerIface
+		val         string
+		wantErr     error
+		wantHostVal string
+		wantCntrVal string
+	}{
+		{
+			//
+			// Test-case 1: Value above the host one. Host FS must be updated.
+			//
+			name:        "1",
+			cntr:        c1,
+			val:         "64",
+			wantErr:     nil,
+			wantHostVal: "64",
+			wantCntrVal: "64",
+		},
+		{
+			//
+			// Test-case 2: Lower value from another container. Host FS must keep
+			// the max, while the container sees its own value.
+			//
+			name:        "2",
+			cntr:        c2,
+			val:         "16",
+			wantErr:     nil,
+			wantHostVal: "64",
+			wantCntrVal: "16",
+		},
+		{
+			//
+			// Test-case 3: Zero value (EINVAL).
+			//
+			name:        "3",
+			cntr:        c2,
+			val:         "0",
+			wantErr:     fuse.IOerror{Code: syscall.EINVAL},
+			wantHostVal: "64",
+			wantCntrVal: "16",
+		},
+		{
+			//
+			// Test-case 4: Negative value (EINVAL).
+			//
+			name:        "4",
+			cntr:        c1,
+			val:         "-8",
+			wantErr:     fuse.IOerror{Code: syscall.EINVAL},
+			wantHostVal: "64",
+			wantCntrVal: "64",
+		},
+		{
+			//
+			// Test-case 5: Non-numeric value (EINVAL).
+			//
+			name:        "5",
+			cntr:        c1,
+			val:         "foo",
+			wantErr:     fuse.IOerror{Code: syscall.EINVAL},
+			wantHostVal: "64",
+			wantCntrVal: "64",
+		},
+	}
+
+	for _, knob := range []string{"msg_max", "msgsize_max", "queues_max"} {
+		path := "/proc/sys/fs/mqueue/" + knob
+
+		h := &implementations.MaxIntBaseHandler{
+			HandlerBase: domain.HandlerBase{
+				Name:      knob,
+				Path:      path,
+				Type:      domain.NODE_SUBSTITUTION,
+				Enabled:   true,
+				Cacheable: true,
+				Service:   hds,
+			},
+			MinVal: 1,
+		}
+
+		n := ios.NewIOnode(knob, path, 0)
+		if err := n.WriteFile([]byte("10")); err != nil {
+			t.Fatalf("Could not initialize host file: %v", err)
+		}
+
+		for _, tt := range tests {
+			t.Run(knob+"/"+tt.name, func(t *testing.T) {
+				_, err := h.Write(n, &domain.HandlerRequest{
+					Pid:       tt.cntr.InitPid(),
+					Data:      []byte(tt.val + "\n"),
+					Container: tt.cntr,
+				})
+				if err != tt.wantErr {
+					t.Errorf("MaxIntBaseHandler.Write() error = %v, want %v", err, tt.wantErr)
+				}
+				if got, _ := n.ReadLine(); got != tt.wantHostVal {
+					t.Errorf("MaxIntBaseHandler.Write() host value = %v, want %v",
+						got, tt.wantHostVal)
+				}
+
+				buf := make([]byte, 16)
+				rn, err := h.Read(n, &domain.HandlerRequest{
+					Pid:       tt.cntr.InitPid(),
+					Data:      buf,
+					Container: tt.cntr,
+				})
+				if err != nil {
+					t.Fatalf("MaxIntBaseHandler.Read() error = %v", err)
+				}
+				if got := string(buf[:rn]); got != tt.wantCntrVal+"\n" {
+					t.Errorf("MaxIntBaseHandler.Read() = %q, want %q", got, tt.wantCntrVal+"\n")
+				}
+			})
+		}
+	}
+}
+
+func TestMaxIntBaseHandler_Template(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{
+			//
+			// Test-case 1: No template. Containers start off the host value.
+			//
+			name:     "1",
+			template: "",
+			want:     "128\n",
+		},
+		{
+			//
+			// Test-case 2: Template defined. Containers start off the template
+			// value, regardless of the host one.
+			//
+			name:     "2",
+			template: "4096",
+			want:     "4096\n",
+		},
+		{
+			//
+			// Test-case 3: Invalid template (below the supported minimum).
+			// Host value expected.
+			//
+			name:     "3",
+			template: "0",
+			want:     "128\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &implementations.MaxIntBaseHandler{
+				HandlerBase: domain.HandlerBase{
+					Name:      "coreSomaxconn",
+					Path:      "/proc/sys/net/core/somaxconn",
+					Type:      domain.NODE_SUBSTITUTION,
+					Enabled:   true,
+					Cacheable: true,
+					Service:   hds,
+				},
+				MinVal:   1,
+				Template: tt.template,
+			}
+
+			n := ios.NewIOnode("somaxconn", "/proc/sys/net/core/somaxconn", 0)
+			if err := n.WriteFile([]byte("128")); err != nil {
+				t.Fatalf("Could not initialize host file: %v", err)
+			}
+
+			cntr := css.ContainerCreate("c1", uint32(1001), time.Time{}, 231072,
+				65535, 231072, 65535, nil, nil, false, nil)
+
+			buf := make([]byte, 16)
+			rn, err := h.Read(n, &domain.HandlerRequest{
+				Pid:       cntr.InitPid(),
+				Data:      buf,
+				Container: cntr,
+			})
+			if err != nil {
+				t.Fatalf("MaxIntBaseHandler.Read() error = %v", err)
+			}
+			if got := string(buf[:rn]); got != tt.want {
+				t.Errorf("MaxIntBaseHandler.Read() = %q, want %q", got, tt.want)
+			}
+
+			// Host FS is never altered by reads.
+			if got, _ := n.ReadLine(); got != "128" {
+				t.Errorf("MaxIntBaseHandler.Read() host value = %v, want 128", got)
+			}
+		})
+	}
+}
+
+func TestMaxIntBaseHandler_SocketBufferDefaults(t *testing.T) {
+
+	c1 := css.ContainerCreate("c1", uint32(1001), time.Time{}, 231072, 65535, 231072, 65535, nil, nil, false, nil)
+	c2 := css.ContainerCreate("c2", uint32(2001), time.Time{}, 296608, 65535, 296608, 65535, nil, nil, false, nil)
+
+	tests := []struct {
+		name        string
+		cntr        domain.ContainerIface
+		val         string
+		wantErr     error
+		wantHostVal string
+	}{
+		{
+			//
+			// Test-case 1: Value above the host one. Host FS must be updated.
+			//
+			name:        "1",
+			cntr:        c1,
+			val:         "4194304",
+			wantErr:     nil,
+			wantHostVal: "4194304",
+		},
+		{
+			//
+			// Test-case 2: Lower value from another container. Host FS must keep
+			// the max.
+			//
+			name:        "2",
+			cntr:        c2,
+			val:         "1048576",
+			wantErr:     nil,
+			wantHostVal: "4194304",
+		},
+		{
+			//
+			// Test-case 3: Value beyond the 32-bit range from the second
+			// container. Host FS must be updated.
+			//
+			name:        "3",
+			cntr:        c2,
+			val:         "8589934592",
+			wantErr:     nil,
+			wantHostVal: "8589934592",
+		},
+		{
+			//
+			// Test-case 4: Non-positive value (EINVAL). Host FS left untouched.
+			//
+			name:        "4",
+			cntr:        c1,
+			val:         "0",
+			wantErr:     fuse.IOerror{Code: syscall.EINVAL},
+			wantHostVal: "8589934592",
+		},
+	}
+
+	for _, knob := range []string{"rmem_default", "wmem_default"} {
+		path := "/proc/sys/net/core/" + knob
+
+		h := &implementations.MaxIntBaseHandler{
+			HandlerBase: domain.HandlerBase{
+				Name:      knob,
+				Path:      path,
+				Type:      domain.NODE_SUBSTITUTION,
+				Enabled:   true,
+				Cacheable: true,
+				Service:   hds,
+			},
+			MinVal: 1,
+		}
+
+		n := ios.NewIOnode(knob, path, 0)
+		if err := n.WriteFile([]byte("212992")); err != nil {
+			t.Fatalf("Could not initialize host file: %v", err)
+		}
+
+		for _, tt := range tests {
+			t.Run(knob+"/"+tt.name, func(t *testing.T) {
+				_, err := h.Write(n, &domain.HandlerRequest{
+					Pid:       tt.cntr.InitPid(),
+					Data:      []byte(tt.val + "\n"),
+					Container: tt.cntr,
+				})
+				if err != tt.wantErr {
+					t.Errorf("MaxIntBaseHandler.Write() error = %v, want %v", err, tt.wantErr)
+				}
+				if got, _ := n.ReadLine(); got != tt.wantHostVal {
+					t.Errorf("MaxIntBaseHandler.Write() host value = %v, want %v",
+						got, tt.wantHostVal)
+				}
+			})
+		}
+	}
+}
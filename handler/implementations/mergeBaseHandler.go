@@ -0,0 +1,709 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+)
+
+// This is a base handler for kernel sysctls exposed inside a sys container whose
+// host value is reconciled with the ones written by all sys containers as per
+// the configured merge 'Policy':
+//
+// none - values are kept within the container's scope; the host FS is left
+//        untouched.
+// max  - host holds the highest value (logical-or for booleans).
+// min  - host holds the lowest value (logical-and for booleans).
+// or   - host holds the bitwise-or of all the values.
+// and  - host holds the bitwise-and of all the values.
+// last - host holds the last value written.
+//
+// Values are handled as per the value type of the handler (int, uint64, tuple or
+// bool; int if not declared). Tuples (e.g. "4096 87380 6291456") are merged
+// element-wise. Every container displays the value it last wrote (or the host
+// one if it never did so).
+
+type MergeBaseHandler struct {
+	domain.HandlerBase
+
+	Policy domain.MergePolicy
+
+	// Lowest value accepted by this resource (applies to each tuple element).
+	MinVal int64
+
+	// Display the lowest of the container's value and the host's one during
+	// reads (see MaxIntBaseHandler).
+	MinReadback bool
+
+	// Initial value of the resource in the containers (see MaxIntBaseHandler).
+	Template string
+}
+
+func (h *MergeBaseHandler) Lookup(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (os.FileInfo, error) {
+
+	logrus.Debugf("Executing Lookup() method on %v handler", h.Name)
+
+	return n.Stat()
+}
+
+func (h *MergeBaseHandler) Getattr(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (*syscall.Stat_t, error) {
+
+	logrus.Debugf("Executing Getattr() method on %v handler", h.Name)
+
+	return nil, nil
+}
+
+func (h *MergeBaseHandler) Open(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) error {
+
+	logrus.Debugf("Executing %v Open() method\n", h.Name)
+
+	return h.merger().open(n)
+}
+
+func (h *MergeBaseHandler) Close(n domain.IOnodeIface) error {
+
+	logrus.Debugf("Executing Close() method on %v handler", h.Name)
+
+	return h.merger().close(n)
+}
+
+func (h *MergeBaseHandler) Read(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Read() method", h.Name)
+
+	return h.merger().read(n, req)
+}
+
+func (h *MergeBaseHandler) Write(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Write() method", h.Name)
+
+	return h.merger().write(n, req)
+}
+
+func (h *MergeBaseHandler) ReadDirAll(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) ([]os.FileInfo, error) {
+
+	return nil, nil
+}
+
+func (h *MergeBaseHandler) MergePolicy() domain.MergePolicy {
+	return h.Policy
+}
+
+// Reconfigure returns a copy of the handler operating with the passed merge
+// policy. Write modes and allow-lists are not supported.
+func (h *MergeBaseHandler) Reconfigure(
+	cfg domain.HandlerConfig) (domain.HandlerIface, error) {
+
+	if cfg.WriteMode != "" {
+		return nil, errors.New("write mode not supported")
+	}
+	if cfg.AcceptMissing != nil {
+		return nil, errors.New("missing-resource policy not supported")
+	}
+	if cfg.AllowedContainers != nil {
+		return nil, errors.New("allow-list not supported")
+	}
+
+	policy := h.Policy
+	if cfg.MergePolicy != "" {
+		switch cfg.MergePolicy {
+		case domain.MergePolicyNone,
+			domain.MergePolicyMax,
+			domain.MergePolicyMin,
+			domain.MergePolicyOr,
+			domain.MergePolicyAnd,
+			domain.MergePolicyLast:
+		default:
+			return nil, errors.New("unsupported merge policy")
+		}
+		policy = cfg.MergePolicy
+	}
+
+	return &MergeBaseHandler{
+		HandlerBase: h.HandlerBase.Clone(),
+		Policy:      policy,
+		MinVal:      h.MinVal,
+		MinReadback: h.MinReadback,
+		Template:    h.Template,
+	}, nil
+}
+
+func (h *MergeBaseHandler) merger() *hostMerger {
+	return &hostMerger{
+		hb:          &h.HandlerBase,
+		policy:      h.Policy,
+		vtype:       mergeValueType(&h.HandlerBase),
+		minVal:      h.MinVal,
+		minReadback: h.MinReadback,
+		template:    h.Template,
+	}
+}
+
+func (h *MergeBaseHandler) GetName() string {
+	return h.Name
+}
+
+func (h *MergeBaseHandler) GetPath() string {
+	return h.Path
+}
+
+func (h *MergeBaseHandler) GetEnabled() bool {
+	return h.Enabled
+}
+
+func (h *MergeBaseHandler) GetType() domain.HandlerType {
+	return h.Type
+}
+
+func (h *MergeBaseHandler) GetService() domain.HandlerServiceIface {
+	return h.Service
+}
+
+func (h *MergeBaseHandler) SetEnabled(val bool) {
+	h.Enabled = val
+}
+
+func (h *MergeBaseHandler) SetService(hs domain.HandlerServiceIface) {
+	h.Service = hs
+}
+
+// mergeValueType returns the value type the merge logic operates with for the
+// given handler: integers unless declared otherwise.
+func mergeValueType(hb *domain.HandlerBase) domain.ValueType {
+
+	if hb.ValueType == domain.ValueTypeAny {
+		return domain.ValueTypeInt
+	}
+
+	return hb.ValueType
+}
+
+//
+// hostMerger implements the merge logic shared by all the handlers relying on a
+// merge policy. It operates over the HandlerBase of the owning handler, whose
+// lock serializes all the accesses to the host FS.
+//
+type hostMerger struct {
+	hb          *domain.HandlerBase
+	policy      domain.MergePolicy
+	vtype       domain.ValueType
+	minVal      int64
+	minReadback bool
+	template    string
+
+	// Element-specific policies of tuples (see TupleIntHandler); 'policy'
+	// applies to all the elements if not set.
+	fieldPolicies []domain.MergePolicy
+}
+
+func (m *hostMerger) open(n domain.IOnodeIface) error {
+
+	flags := n.OpenFlags()
+	if flags != syscall.O_RDONLY && flags != syscall.O_WRONLY {
+		return fuse.IOerror{Code: syscall.EACCES}
+	}
+
+	if flags == syscall.O_WRONLY && m.hb.ReadOnly {
+		return fuse.IOerror{Code: syscall.EROFS}
+	}
+
+	// During 'writeOnly' accesses, we must grant read-write rights temporarily
+	// to allow push() to carry out the expected 'write' operation, as well as a
+	// 'read' one too.
+	if flags == syscall.O_WRONLY {
+		n.SetOpenFlags(syscall.O_RDWR)
+	}
+
+	if err := n.Open(); err != nil {
+		logrus.Debugf("Error opening file %v", m.hb.Path)
+		return fuse.IOerror{Code: syscall.EIO}
+	}
+
+	return nil
+}
+
+func (m *hostMerger) close(n domain.IOnodeIface) error {
+
+	if err := n.Close(); err != nil {
+		logrus.Debugf("Error closing file %v", m.hb.Path)
+		return fuse.IOerror{Code: syscall.EIO}
+	}
+
+	return nil
+}
+
+func (m *hostMerger) read(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	// We are dealing with a single-line element being read, so we can save
+	// some cycles by returning right away if offset is any higher than zero.
+	if req.Offset > 0 {
+		return 0, io.EOF
+	}
+
+	name := n.Name()
+	path := n.Path()
+	cntr := req.Container
+
+	// Ensure operation is generated from within a registered sys container.
+	if cntr == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return 0, errors.New("Container not found")
+	}
+
+	// Check if this resource has been initialized for this container. Otherwise,
+	// initialize it from the handler's template (if any) or from the host FS,
+	// and store it accordingly within the container struct.
+	cntr.Lock()
+	data, ok := cntr.Data(path, name)
+	if !ok {
+		var err error
+
+		cntr.CacheMiss()
+		data, err = m.initial(n)
+		if err != nil && err != io.EOF {
+			cntr.Unlock()
+			return 0, err
+		}
+
+		cntr.SetData(path, name, data)
+	} else {
+		cntr.CacheHit()
+	}
+	cntr.Unlock()
+
+	if m.minReadback {
+		data = m.lowest(n, data)
+	}
+
+	data += "\n"
+
+	return copyResultBuffer(req.Data, []byte(data))
+}
+
+func (m *hostMerger) write(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	name := n.Name()
+	path := n.Path()
+	cntr := req.Container
+
+	// Resources can be made read-only through the handler's ReadOnly attribute;
+	// besides the FUSE layer, this also covers the non-FUSE entry points (e.g.
+	// SysctlWrite()).
+	if m.hb.ReadOnly {
+		return 0, fuse.IOerror{Code: syscall.EROFS}
+	}
+
+	val, err := ValidateValue(m.hb, req.Data)
+	if err != nil {
+		return 0, err
+	}
+
+	newVal, err := m.parse(val)
+	if err != nil {
+		logrus.Errorf("Unsupported value %q for %v: %v", req.Data, m.hb.Path, err)
+		return 0, fuse.IOerror{Code: syscall.EINVAL}
+	}
+
+	// Ensure operation is generated from within a registered sys container.
+	if cntr == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return 0, errors.New("Container not found")
+	}
+
+	cntr.Lock()
+	defer cntr.Unlock()
+
+	// If the value previously written by this container already prevails over
+	// the new one (e.g. a lower value under the 'max' policy), then the host FS
+	// has no need to be updated; only the container struct is refreshed.
+	push := m.policy != domain.MergePolicyNone
+	if curVal, ok := cntr.Data(path, name); ok && push && m.policy != domain.MergePolicyLast {
+		if merged, err := m.merge(curVal, newVal); err == nil && merged == curVal {
+			push = false
+		}
+	}
+
+	// Bursts of writes are collapsed into a single (deferred) host FS update
+	// when coalescing is enabled. Notice that a pending update must be
+	// superseded even if the new value doesn't prevail, as the last value
+	// written is the one to be pushed down.
+	hs := m.hb.Service
+	if push || hs.WritePending(cntr, path, name) {
+		deferred := hs.CoalesceWrite(cntr, path, name, newVal, func(val string) error {
+			return m.push(n, val)
+		})
+		if !deferred {
+			if err := m.push(n, newVal); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	// Writing the new value into container-state struct.
+	cntr.SetData(path, name, newVal)
+
+	return len(req.Data), nil
+}
+
+// initial returns the value a container's view of the resource starts off
+// with: the handler's template if defined (and valid), or the host FS one
+// otherwise.
+func (m *hostMerger) initial(n domain.IOnodeIface) (string, error) {
+
+	if m.template != "" {
+		val, err := m.parse(m.template)
+		if err == nil {
+			return val, nil
+		}
+		logrus.Warnf("Ignoring invalid template %q of %v handler: %v",
+			m.template, m.hb.Name, err)
+	}
+
+	return m.fetch(n)
+}
+
+// fetch reads the current host FS value.
+func (m *hostMerger) fetch(n domain.IOnodeIface) (string, error) {
+
+	// We need the per-resource lock since we are about to access the resource on
+	// the host FS. See push() for a full explanation.
+	m.hb.Lock.Lock()
+	curHostVal, err := n.ReadLine()
+	m.hb.Lock.Unlock()
+
+	if err != nil && err != io.EOF {
+		logrus.Errorf("Could not read from file %v", m.hb.Path)
+		return "", err
+	}
+
+	// High-level verification to ensure that format is the expected one.
+	curHostVal, err = m.parse(curHostVal)
+	if err != nil {
+		logrus.Errorf("Unexpected content read from file %v, error %v", m.hb.Path, err)
+		return "", err
+	}
+
+	return curHostVal, nil
+}
+
+// push merges the passed value with the host FS one and writes the result (if
+// different) back to the host FS.
+func (m *hostMerger) push(n domain.IOnodeIface, newVal string) error {
+
+	// We need the per-resource lock since we are about to access the resource on
+	// the host FS and multiple sys containers could be accessing that same
+	// resource concurrently.
+	//
+	// But that's not sufficient. Some users may deploy sysbox inside a
+	// privileged container, and thus can have multiple sysbox instances running
+	// concurrently on the same host. If those sysbox instances write conflicting
+	// values to a kernel resource that uses this handler (e.g., a sysctl under
+	// /proc/sys), a race condition arises that could cause the value to be
+	// written to not be the merged one across all instances.
+	//
+	// To reduce the chance of this ocurring, in addition to the per-resource
+	// lock, we use a heuristic in which we read-after-write to verify the value
+	// of the resource matches the merged one we wrote. If it doesn't, it means
+	// some other agent on the host wrote to the resource after we wrote to it,
+	// so we must merge and retry the write.
+	//
+	// When retrying, we wait a small but random amount of time to reduce the
+	// chance of hitting the race condition again. And we retry a limited amount
+	// of times, after which the write is failed with EBUSY, as the container's
+	// value can't be guaranteed to have made it to the host.
+	//
+	// Note that this solution works well for resolving race conditions among
+	// sysbox instances, but may not address race conditions with other host
+	// agents that write to the same sysctl. That's because there is no guarantee
+	// that the other host agent will read-after-write and retry as sysbox does.
+	//
+	// Deployments where sysbox-fs is known to be the only instance on the host
+	// don't need the heuristic, so a single write is done in that case. The
+	// number of attempts and the delay between them are otherwise tunable
+	// (see SetPushRetries()).
+
+	m.hb.Lock.Lock()
+	defer m.hb.Lock.Unlock()
+
+	retries := 1
+	var backoff time.Duration
+
+	single := m.hb.Service.SingleInstance()
+	if !single {
+		retries, backoff = m.hb.Service.PushRetries()
+	}
+
+	for i := 0; ; i++ {
+
+		curHostVal, err := n.ReadLine()
+		if err != nil && err != io.EOF {
+			return err
+		}
+		curHostVal, err = m.parse(curHostVal)
+		if err != nil {
+			logrus.Errorf("Unexpected error: %v", err)
+			return err
+		}
+
+		mergedVal, err := m.merge(curHostVal, newVal)
+		if err != nil {
+			return fuse.IOerror{Code: syscall.EINVAL}
+		}
+
+		// Nothing to do if the host already holds the merged value.
+		if mergedVal == curHostVal {
+			return nil
+		}
+
+		// Some other agent keeps overriding the value we push down.
+		if i == retries {
+			logrus.Warnf("Could not push %v to %v after %d attempts: resource contended",
+				mergedVal, n.Path(), retries)
+			return fuse.IOerror{Code: syscall.EBUSY}
+		}
+
+		// When retrying, wait a random delay to reduce chances of a new collision
+		if i > 0 && backoff > 0 {
+			time.Sleep(time.Duration(rand.Int63n(int64(backoff))))
+		}
+
+		// Push down to host kernel the merged value.
+		err = n.WriteFile([]byte(mergedVal))
+		if err != nil && !m.hb.Service.IgnoreErrors() {
+			logrus.Errorf("Could not write %v to file: %s", mergedVal, err)
+			return err
+		}
+
+		// No read-after-write verification needed.
+		if single {
+			return nil
+		}
+	}
+}
+
+// lowest returns the lowest of the passed (container) value and the one
+// currently present in the host FS. The container value is returned if the
+// host one can't be obtained.
+func (m *hostMerger) lowest(n domain.IOnodeIface, cntrVal string) string {
+
+	hostVal, err := m.fetch(n)
+	if err != nil {
+		return cntrVal
+	}
+
+	min, err := mergeValues(domain.MergePolicyMin, m.vtype, cntrVal, hostVal)
+	if err != nil {
+		return cntrVal
+	}
+
+	return min
+}
+
+// parse validates the passed value as per the handler's value type, and returns
+// it in its canonical form.
+func (m *hostMerger) parse(val string) (string, error) {
+
+	fields, err := parseValue(m.vtype, 0, val)
+	if err != nil {
+		return "", err
+	}
+
+	for i, f := range fields {
+		switch m.vtype {
+		case domain.ValueTypeUint64:
+			v, _ := strconv.ParseUint(f, 10, 64)
+			if m.minVal > 0 && v < uint64(m.minVal) {
+				return "", errors.New("value below supported minimum")
+			}
+			fields[i] = strconv.FormatUint(v, 10)
+
+		case domain.ValueTypeBool:
+
+		case domain.ValueTypeInt, domain.ValueTypeTuple:
+			v, _ := strconv.ParseInt(f, 10, 64)
+			if v < m.minVal {
+				return "", errors.New("value below supported minimum")
+			}
+			fields[i] = strconv.FormatInt(v, 10)
+
+		default:
+			return "", errors.New("value type not supported by merge logic")
+		}
+	}
+
+	return strings.Join(fields, "\t"), nil
+}
+
+func (m *hostMerger) merge(cur, new string) (string, error) {
+
+	if m.fieldPolicies == nil {
+		return mergeValues(m.policy, m.vtype, cur, new)
+	}
+
+	curFields := strings.Fields(cur)
+	newFields := strings.Fields(new)
+	if len(curFields) != len(newFields) || len(curFields) != len(m.fieldPolicies) {
+		return "", errors.New("mismatching number of elements")
+	}
+
+	merged := make([]string, len(curFields))
+
+	for i, policy := range m.fieldPolicies {
+		v, err := mergeValues(policy, m.vtype, curFields[i], newFields[i])
+		if err != nil {
+			return "", err
+		}
+		merged[i] = v
+	}
+
+	return strings.Join(merged, "\t"), nil
+}
+
+// mergeValues merges two (canonical) values as per the given policy.
+func mergeValues(
+	policy domain.MergePolicy,
+	vtype domain.ValueType,
+	cur string,
+	new string) (string, error) {
+
+	switch policy {
+	case domain.MergePolicyNone:
+		return cur, nil
+	case domain.MergePolicyLast:
+		return new, nil
+	}
+
+	curFields := strings.Fields(cur)
+	newFields := strings.Fields(new)
+	if len(curFields) != len(newFields) {
+		return "", errors.New("mismatching number of elements")
+	}
+
+	merged := make([]string, len(curFields))
+
+	for i := range curFields {
+		if vtype == domain.ValueTypeUint64 {
+			c, err := strconv.ParseUint(curFields[i], 10, 64)
+			if err != nil {
+				return "", err
+			}
+			n, err := strconv.ParseUint(newFields[i], 10, 64)
+			if err != nil {
+				return "", err
+			}
+			m, err := mergeUint64(policy, c, n)
+			if err != nil {
+				return "", err
+			}
+			merged[i] = strconv.FormatUint(m, 10)
+			continue
+		}
+
+		c, err := strconv.ParseInt(curFields[i], 10, 64)
+		if err != nil {
+			return "", err
+		}
+		n, err := strconv.ParseInt(newFields[i], 10, 64)
+		if err != nil {
+			return "", err
+		}
+		m, err := mergeInt64(policy, c, n)
+		if err != nil {
+			return "", err
+		}
+		merged[i] = strconv.FormatInt(m, 10)
+	}
+
+	return strings.Join(merged, "\t"), nil
+}
+
+// Notice that for booleans (0 / 1) max and min are equivalent to logical-or and
+// logical-and respectively, so no specific logic is required for them.
+func mergeInt64(policy domain.MergePolicy, cur, new int64) (int64, error) {
+
+	switch policy {
+	case domain.MergePolicyMax:
+		if new > cur {
+			return new, nil
+		}
+		return cur, nil
+	case domain.MergePolicyMin:
+		if new < cur {
+			return new, nil
+		}
+		return cur, nil
+	case domain.MergePolicyOr:
+		return cur | new, nil
+	case domain.MergePolicyAnd:
+		return cur & new, nil
+	}
+
+	return 0, errors.New("unsupported merge policy")
+}
+
+func mergeUint64(policy domain.MergePolicy, cur, new uint64) (uint64, error) {
+
+	switch policy {
+	case domain.MergePolicyMax:
+		if new > cur {
+			return new, nil
+		}
+		return cur, nil
+	case domain.MergePolicyMin:
+		if new < cur {
+			return new, nil
+		}
+		return cur, nil
+	case domain.MergePolicyOr:
+		return cur | new, nil
+	case domain.MergePolicyAnd:
+		return cur & new, nil
+	}
+
+	return 0, errors.New("unsupported merge policy")
+}
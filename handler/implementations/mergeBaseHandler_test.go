@@ -0,0 +1,398 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations_test
+
+import (
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+	"github.com/nestybox/sysbox-fs/handler/implementations"
+)
+
+func TestMergeBaseHandler_Policies(t *testing.T) {
+
+	tests := []struct {
+		name        string
+		policy      domain.MergePolicy
+		vtype       domain.ValueType
+		hostVal     string
+		writes      [2]string // values written by c1 and c2 respectively
+		wantHostVal [2]string // host value after each write
+	}{
+		{
+			//
+			// Test-case 1: 'none' policy. Host FS must be left untouched.
+			//
+			name:        "1",
+			policy:      domain.MergePolicyNone,
+			vtype:       domain.ValueTypeInt,
+			hostVal:     "100",
+			writes:      [2]string{"200", "50"},
+			wantHostVal: [2]string{"100", "100"},
+		},
+		{
+			//
+			// Test-case 2: 'max' policy. Host holds the highest value.
+			//
+			name:        "2",
+			policy:      domain.MergePolicyMax,
+			vtype:       domain.ValueTypeInt,
+			hostVal:     "100",
+			writes:      [2]string{"200", "150"},
+			wantHostVal: [2]string{"200", "200"},
+		},
+		{
+			//
+			// Test-case 3: 'min' policy. Host holds the lowest value.
+			//
+			name:        "3",
+			policy:      domain.MergePolicyMin,
+			vtype:       domain.ValueTypeInt,
+			hostVal:     "100",
+			writes:      [2]string{"60", "80"},
+			wantHostVal: [2]string{"60", "60"},
+		},
+		{
+			//
+			// Test-case 4: 'or' policy. Host holds the bitwise-or of all values.
+			//
+			name:        "4",
+			policy:      domain.MergePolicyOr,
+			vtype:       domain.ValueTypeInt,
+			hostVal:     "1",
+			writes:      [2]string{"2", "4"},
+			wantHostVal: [2]string{"3", "7"},
+		},
+		{
+			//
+			// Test-case 5: 'and' policy. Host holds the bitwise-and of all values.
+			//
+			name:        "5",
+			policy:      domain.MergePolicyAnd,
+			vtype:       domain.ValueTypeInt,
+			hostVal:     "7",
+			writes:      [2]string{"6", "3"},
+			wantHostVal: [2]string{"6", "2"},
+		},
+		{
+			//
+			// Test-case 6: 'last' policy. Host holds the last value written.
+			//
+			name:        "6",
+			policy:      domain.MergePolicyLast,
+			vtype:       domain.ValueTypeInt,
+			hostVal:     "100",
+			writes:      [2]string{"200", "50"},
+			wantHostVal: [2]string{"200", "50"},
+		},
+		{
+			//
+			// Test-case 7: 'max' policy over uint64 values exceeding MaxInt64.
+			//
+			name:        "7",
+			policy:      domain.MergePolicyMax,
+			vtype:       domain.ValueTypeUint64,
+			hostVal:     "9223372036854775807",
+			writes:      [2]string{"18446744073709551615", "1"},
+			wantHostVal: [2]string{"18446744073709551615", "18446744073709551615"},
+		},
+		{
+			//
+			// Test-case 8: 'max' policy over tuples (element-wise).
+			//
+			name:        "8",
+			policy:      domain.MergePolicyMax,
+			vtype:       domain.ValueTypeTuple,
+			hostVal:     "4096\t87380\t6291456",
+			writes:      [2]string{"8192 65536 4194304", "4096 131072 4194304"},
+			wantHostVal: [2]string{"8192\t87380\t6291456", "8192\t131072\t6291456"},
+		},
+		{
+			//
+			// Test-case 9: 'min' policy over booleans (logical-and).
+			//
+			name:        "9",
+			policy:      domain.MergePolicyMin,
+			vtype:       domain.ValueTypeBool,
+			hostVal:     "1",
+			writes:      [2]string{"1", "0"},
+			wantHostVal: [2]string{"1", "0"},
+		},
+	}
+
+	//
+	// Testcase executions.
+	//
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &implementations.MergeBaseHandler{
+				HandlerBase: domain.HandlerBase{
+					Name:      "mergeTest",
+					Path:      "/proc/sys/merge/test" + tt.name,
+					Type:      domain.NODE_SUBSTITUTION,
+					Enabled:   true,
+					Cacheable: true,
+					Service:   hds,
+					ValueType: tt.vtype,
+				},
+				Policy: tt.policy,
+			}
+
+			n := ios.NewIOnode("test"+tt.name, h.Path, 0)
+			if err := n.WriteFile([]byte(tt.hostVal)); err != nil {
+				t.Fatalf("Could not initialize host file: %v", err)
+			}
+
+			cntrs := [2]domain.ContainerIface{
+				css.ContainerCreate("c1", uint32(1001), time.Time{}, 231072,
+					65535, 231072, 65535, nil, nil, false, nil),
+				css.ContainerCreate("c2", uint32(2001), time.Time{}, 296608,
+					65535, 296608, 65535, nil, nil, false, nil),
+			}
+
+			for i, cntr := range cntrs {
+				if _, err := h.Write(n, &domain.HandlerRequest{
+					Pid:       cntr.InitPid(),
+					Data:      []byte(tt.writes[i] + "\n"),
+					Container: cntr,
+				}); err != nil {
+					t.Fatalf("MergeBaseHandler.Write() error = %v", err)
+				}
+
+				gotHostVal, err := n.ReadLine()
+				if err != nil {
+					t.Fatalf("Could not read host file: %v", err)
+				}
+				if gotHostVal != tt.wantHostVal[i] {
+					t.Errorf("write %d: MergeBaseHandler.Write() host value = %v, want %v",
+						i+1, gotHostVal, tt.wantHostVal[i])
+				}
+			}
+
+			// Every container must display the value it wrote.
+			for i, cntr := range cntrs {
+				buf := make([]byte, 64)
+				rn, err := h.Read(n, &domain.HandlerRequest{
+					Pid:       cntr.InitPid(),
+					Data:      buf,
+					Container: cntr,
+				})
+				if err != nil {
+					t.Fatalf("MergeBaseHandler.Read() error = %v", err)
+				}
+				got := strings.Fields(string(buf[:rn]))
+				want := strings.Fields(tt.writes[i])
+				if strings.Join(got, " ") != strings.Join(want, " ") {
+					t.Errorf("MergeBaseHandler.Read() = %v, want %v", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMergeBaseHandler_InvalidValues(t *testing.T) {
+
+	cntr := css.ContainerCreate("c1", uint32(1001), time.Time{}, 231072, 65535,
+		231072, 65535, nil, nil, false, nil)
+
+	tests := []struct {
+		name   string
+		vtype  domain.ValueType
+		minVal int64
+		data   string
+	}{
+		{
+			//
+			// Test-case 1: Non-numeric value (EINVAL).
+			//
+			name:  "1",
+			vtype: domain.ValueTypeInt,
+			data:  "foo\n",
+		},
+		{
+			//
+			// Test-case 2: Value below the supported minimum (EINVAL).
+			//
+			name:   "2",
+			vtype:  domain.ValueTypeInt,
+			minVal: 1,
+			data:   "0\n",
+		},
+		{
+			//
+			// Test-case 3: Negative value for an unsigned resource (EINVAL).
+			//
+			name:  "3",
+			vtype: domain.ValueTypeUint64,
+			data:  "-1\n",
+		},
+		{
+			//
+			// Test-case 4: Tuple with a number of elements other than the host
+			// one (EINVAL).
+			//
+			name:  "4",
+			vtype: domain.ValueTypeTuple,
+			data:  "1 2\n",
+		},
+		{
+			//
+			// Test-case 5: Non-boolean value (EINVAL).
+			//
+			name:  "5",
+			vtype: domain.ValueTypeBool,
+			data:  "2\n",
+		},
+	}
+
+	//
+	// Testcase executions.
+	//
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &implementations.MergeBaseHandler{
+				HandlerBase: domain.HandlerBase{
+					Name:      "mergeInvalid",
+					Path:      "/proc/sys/merge/invalid" + tt.name,
+					Type:      domain.NODE_SUBSTITUTION,
+					Enabled:   true,
+					Service:   hds,
+					ValueType: tt.vtype,
+				},
+				Policy: domain.MergePolicyMax,
+				MinVal: tt.minVal,
+			}
+
+			const hostVal = "1\t1\t1"
+			n := ios.NewIOnode("invalid"+tt.name, h.Path, 0)
+			if err := n.WriteFile([]byte(hostVal)); err != nil {
+				t.Fatalf("Could not initialize host file: %v", err)
+			}
+			if tt.vtype != domain.ValueTypeTuple {
+				if err := n.WriteFile([]byte("1")); err != nil {
+					t.Fatalf("Could not initialize host file: %v", err)
+				}
+			}
+
+			_, err := h.Write(n, &domain.HandlerRequest{
+				Pid:       cntr.InitPid(),
+				Data:      []byte(tt.data),
+				Container: cntr,
+			})
+			if err != (fuse.IOerror{Code: syscall.EINVAL}) {
+				t.Errorf("MergeBaseHandler.Write() error = %v, want %v",
+					err, fuse.IOerror{Code: syscall.EINVAL})
+			}
+		})
+	}
+}
+
+func TestMergeBaseHandler_HungTaskTimeoutSecs(t *testing.T) {
+
+	newHandler := func(readOnly bool) *implementations.MergeBaseHandler {
+		return &implementations.MergeBaseHandler{
+			HandlerBase: domain.HandlerBase{
+				Name:      "kernelHungTaskTimeoutSecs",
+				Path:      "/proc/sys/kernel/hung_task_timeout_secs",
+				Type:      domain.NODE_SUBSTITUTION,
+				Enabled:   true,
+				Cacheable: true,
+				ReadOnly:  readOnly,
+				Service:   hds,
+				ValueType: domain.ValueTypeInt,
+			},
+			Policy: domain.MergePolicyLast,
+			MinVal: 1,
+		}
+	}
+
+	n := ios.NewIOnode("hung_task_timeout_secs", "/proc/sys/kernel/hung_task_timeout_secs", 0)
+	if err := n.WriteFile([]byte("120")); err != nil {
+		t.Fatalf("Could not initialize host file: %v", err)
+	}
+
+	c1 := css.ContainerCreate("c1", uint32(1001), time.Time{}, 231072, 65535,
+		231072, 65535, nil, nil, false, nil)
+	c2 := css.ContainerCreate("c2", uint32(2001), time.Time{}, 296608, 65535,
+		296608, 65535, nil, nil, false, nil)
+
+	write := func(h *implementations.MergeBaseHandler, cntr domain.ContainerIface, val string) error {
+		_, err := h.Write(n, &domain.HandlerRequest{
+			Pid:       cntr.InitPid(),
+			Data:      []byte(val + "\n"),
+			Container: cntr,
+		})
+		return err
+	}
+
+	h := newHandler(false)
+
+	// Only positive integers are accepted (EINVAL).
+	for _, val := range []string{"0", "-1", "foo", "1.5"} {
+		if err := write(h, c1, val); err != (fuse.IOerror{Code: syscall.EINVAL}) {
+			t.Errorf("MergeBaseHandler.Write(%v) error = %v, want %v",
+				val, err, fuse.IOerror{Code: syscall.EINVAL})
+		}
+	}
+
+	// Last writer wins.
+	if err := write(h, c1, "240"); err != nil {
+		t.Fatalf("MergeBaseHandler.Write() error = %v", err)
+	}
+	if err := write(h, c2, "60"); err != nil {
+		t.Fatalf("MergeBaseHandler.Write() error = %v", err)
+	}
+	if got, _ := n.ReadLine(); got != "60" {
+		t.Errorf("MergeBaseHandler.Write() host value = %v, want %v", got, "60")
+	}
+
+	// Read-only mode: writes are rejected (EROFS) and the host value is left
+	// untouched, while reads are still served.
+	h = newHandler(true)
+
+	n.SetOpenFlags(syscall.O_WRONLY)
+	if err := h.Open(n, &domain.HandlerRequest{Container: c1}); err != (fuse.IOerror{Code: syscall.EROFS}) {
+		t.Errorf("MergeBaseHandler.Open() error = %v, want %v",
+			err, fuse.IOerror{Code: syscall.EROFS})
+	}
+	n.SetOpenFlags(syscall.O_RDONLY)
+
+	if err := write(h, c1, "30"); err != (fuse.IOerror{Code: syscall.EROFS}) {
+		t.Errorf("MergeBaseHandler.Write() error = %v, want %v",
+			err, fuse.IOerror{Code: syscall.EROFS})
+	}
+	if got, _ := n.ReadLine(); got != "60" {
+		t.Errorf("MergeBaseHandler.Write() host value = %v, want %v", got, "60")
+	}
+
+	buf := make([]byte, 16)
+	rn, err := h.Read(n, &domain.HandlerRequest{
+		Pid:       c2.InitPid(),
+		Data:      buf,
+		Container: c2,
+	})
+	if err != nil {
+		t.Fatalf("MergeBaseHandler.Read() error = %v", err)
+	}
+	if got := strings.TrimSpace(string(buf[:rn])); got != "60" {
+		t.Errorf("MergeBaseHandler.Read() = %v, want %v", got, "60")
+	}
+}
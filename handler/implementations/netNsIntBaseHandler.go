@@ -0,0 +1,281 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+)
+
+//
+// Base handler for integer knobs namespaced by the kernel's net-ns.
+//
+// As these resources are already isolated per container, there's no need to
+// emulate them; all I/O is passed through to the container's net-ns by the
+// procSysCommon handler (which also takes care of caching). The only purpose of
+// this handler is to validate the values being written, which must fall within
+// the [Min, Max] range (EINVAL otherwise).
+//
+// Trigger-like knobs (e.g. route/flush) are flagged as 'WriteOnly' (see
+// domain.HandlerBase), in which case any attempt to read them is rejected
+// (EACCES), as the kernel would do.
+//
+// Knobs that may be missing in the host kernel (e.g. optional or recently
+// added ones) can be flagged as 'AcceptMissing', in which case writes are
+// accepted into the container's cache alone instead of failing (ENOENT), so
+// that apps blindly writing them keep working while container-local tools
+// observe a consistent value (i.e. reads of such knobs are served from the
+// cache). The policy can be set at runtime (see Reconfigure()).
+//
+type NetNsIntBaseHandler struct {
+	domain.HandlerBase
+	Min           int
+	Max           int
+	AcceptMissing bool
+}
+
+func (h *NetNsIntBaseHandler) Lookup(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (os.FileInfo, error) {
+
+	logrus.Debugf("Executing Lookup() method on %v handler", h.Name)
+
+	commonHandler, err := h.commonHandler()
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := commonHandler.Lookup(n, req)
+	if err != nil && h.AcceptMissing && isNotExistErr(err) {
+		return missingFileInfo(n), nil
+	}
+
+	return info, err
+}
+
+func (h *NetNsIntBaseHandler) Getattr(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (*syscall.Stat_t, error) {
+
+	logrus.Debugf("Executing Getattr() method on %v handler", h.Name)
+
+	commonHandler, err := h.commonHandler()
+	if err != nil {
+		return nil, err
+	}
+
+	return commonHandler.Getattr(n, req)
+}
+
+func (h *NetNsIntBaseHandler) Open(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) error {
+
+	logrus.Debugf("Executing %v Open() method\n", h.Name)
+
+	commonHandler, err := h.commonHandler()
+	if err != nil {
+		return err
+	}
+
+	err = commonHandler.Open(n, req)
+	if err != nil && h.AcceptMissing && isNotExistErr(err) {
+		return nil
+	}
+
+	return err
+}
+
+func (h *NetNsIntBaseHandler) Close(n domain.IOnodeIface) error {
+
+	logrus.Debugf("Executing Close() method on %v handler", h.Name)
+
+	commonHandler, err := h.commonHandler()
+	if err != nil {
+		return err
+	}
+
+	return commonHandler.Close(n)
+}
+
+func (h *NetNsIntBaseHandler) Read(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Read() method", h.Name)
+
+	commonHandler, err := h.commonHandler()
+	if err != nil {
+		return 0, err
+	}
+
+	read, err := commonHandler.Read(n, req)
+	if err != nil && h.AcceptMissing && isNotExistErr(err) && req.Container != nil {
+		cntr := req.Container
+		cntr.Lock()
+		data, ok := cntr.Data(n.Path(), n.Name())
+		cntr.Unlock()
+
+		// Knobs never written are reported as missing, as the kernel does.
+		if !ok {
+			return 0, err
+		}
+
+		return copyResultBuffer(req.Data, []byte(data+"\n"))
+	}
+
+	return read, err
+}
+
+func (h *NetNsIntBaseHandler) Write(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Write() method", h.Name)
+
+	// Ensure operation is generated from within a registered sys container.
+	if req.Container == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return 0, errors.New("Container not found")
+	}
+
+	newVal := strings.TrimSpace(string(req.Data))
+	newValInt, err := strconv.Atoi(newVal)
+	if err != nil {
+		logrus.Errorf("Unsupported value %v for %v", newVal, h.Path)
+		return 0, fuse.IOerror{Code: syscall.EINVAL}
+	}
+
+	if newValInt < h.Min || newValInt > h.Max {
+		logrus.Errorf("Out of range value %v for %v (expected [%v, %v])",
+			newValInt, h.Path, h.Min, h.Max)
+		return 0, fuse.IOerror{Code: syscall.EINVAL}
+	}
+
+	commonHandler, err := h.commonHandler()
+	if err != nil {
+		return 0, err
+	}
+
+	written, err := commonHandler.Write(n, req)
+	if err != nil && h.AcceptMissing && isNotExistErr(err) {
+		logrus.Debugf("Caching value %v for %v (missing in host kernel)", newVal, n.Path())
+
+		cntr := req.Container
+		cntr.Lock()
+		cntr.SetData(n.Path(), n.Name(), newVal)
+		cntr.Unlock()
+
+		return len(req.Data), nil
+	}
+
+	return written, err
+}
+
+func (h *NetNsIntBaseHandler) ReadDirAll(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) ([]os.FileInfo, error) {
+
+	return nil, nil
+}
+
+func (h *NetNsIntBaseHandler) MergePolicy() domain.MergePolicy {
+
+	// Every write over a trigger-like knob must reach the kernel.
+	if h.WriteOnly {
+		return domain.MergePolicyLast
+	}
+
+	return domain.MergePolicyNone
+}
+
+// Reconfigure returns a copy of the handler operating with the passed
+// missing-resource policy. Write modes and allow-lists are not supported, and
+// the merge policy is implied by the knob's nature.
+func (h *NetNsIntBaseHandler) Reconfigure(
+	cfg domain.HandlerConfig) (domain.HandlerIface, error) {
+
+	if cfg.WriteMode != "" {
+		return nil, errors.New("write mode not supported")
+	}
+	if cfg.AllowedContainers != nil {
+		return nil, errors.New("allow-list not supported")
+	}
+	if cfg.MergePolicy != "" && cfg.MergePolicy != h.MergePolicy() {
+		return nil, errors.New("merge policy not supported")
+	}
+
+	acceptMissing := h.AcceptMissing
+	if cfg.AcceptMissing != nil {
+		acceptMissing = *cfg.AcceptMissing
+	}
+
+	return &NetNsIntBaseHandler{
+		HandlerBase:   h.HandlerBase.Clone(),
+		Min:           h.Min,
+		Max:           h.Max,
+		AcceptMissing: acceptMissing,
+	}, nil
+}
+
+func (h *NetNsIntBaseHandler) commonHandler() (domain.HandlerIface, error) {
+
+	commonHandler, ok := h.Service.FindHandler("procSysCommonHandler")
+	if !ok {
+		return nil, fmt.Errorf("No procSysCommonHandler found")
+	}
+
+	return commonHandler, nil
+}
+
+func (h *NetNsIntBaseHandler) GetName() string {
+	return h.Name
+}
+
+func (h *NetNsIntBaseHandler) GetPath() string {
+	return h.Path
+}
+
+func (h *NetNsIntBaseHandler) GetEnabled() bool {
+	return h.Enabled
+}
+
+func (h *NetNsIntBaseHandler) GetType() domain.HandlerType {
+	return h.Type
+}
+
+func (h *NetNsIntBaseHandler) GetService() domain.HandlerServiceIface {
+	return h.Service
+}
+
+func (h *NetNsIntBaseHandler) SetEnabled(val bool) {
+	h.Enabled = val
+}
+
+func (h *NetNsIntBaseHandler) SetService(hs domain.HandlerServiceIface) {
+	h.Service = hs
+}
@@ -0,0 +1,1506 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations_test
+
+import (
+	"math"
+	"path"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+	"github.com/nestybox/sysbox-fs/handler/implementations"
+	"github.com/nestybox/sysbox-fs/nsenter"
+)
+
+func TestNetNsIntBaseHandler_Write(t *testing.T) {
+
+	// Net-ns knobs are passed through to the container by the common handler.
+	commonHandler := &implementations.ProcSysCommonHandler{
+		domain.HandlerBase{
+			Name:      "procSysCommon",
+			Path:      "procSysCommonHandler",
+			Enabled:   true,
+			Cacheable: true,
+			Service:   hds,
+		},
+	}
+	hds.On("FindHandler", "procSysCommonHandler").Return(commonHandler, true)
+
+	h := &implementations.NetNsIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "ipv4TcpFastopen",
+			Path:      "/proc/sys/net/ipv4/tcp_fastopen",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+			Service:   hds,
+		},
+		Min: 0,
+		Max: 0x3ff,
+	}
+
+	n := ios.NewIOnode("tcp_fastopen", "/proc/sys/net/ipv4/tcp_fastopen", 0)
+
+	cntr := css.ContainerCreate(
+		"c1",
+		uint32(1001),
+		time.Time{},
+		231072,
+		65535,
+		231072,
+		65535,
+		nil,
+		nil,
+		false,
+		css)
+	_ = cntr.SetInitProc(cntr.InitPid(), cntr.UID(), cntr.GID())
+	cntr.InitProc().CreateNsInodes(123456)
+
+	// Expected nsenter interactions for values being passed through.
+	expectWrite := func(val string) {
+		nsenterEventReq := &nsenter.NSenterEvent{
+			Pid:       cntr.InitPid(),
+			Namespace: &domain.AllNSsButMount,
+			ReqMsg: &domain.NSenterMessage{
+				Type: domain.WriteFileRequest,
+				Payload: &domain.WriteFilePayload{
+					File:    n.Path(),
+					Content: val,
+				},
+				Creds: unmappedCreds,
+			},
+		}
+
+		nss.On(
+			"NewEvent",
+			cntr.InitPid(),
+			&domain.AllNSsButMount,
+			nsenterEventReq.ReqMsg,
+			(*domain.NSenterMessage)(nil),
+			false).Return(nsenterEventReq)
+
+		nss.On("SendRequestEvent", nsenterEventReq).Return(nil)
+		nss.On("ReceiveResponseEvent", nsenterEventReq).Return(
+			&domain.NSenterMessage{Type: domain.WriteFileResponse})
+	}
+
+	tests := []struct {
+		name       string
+		data       string
+		wantErr    bool
+		wantErrVal error
+	}{
+		{
+			//
+			// Test-case 1: Client-side fastopen. No errors expected.
+			//
+			name: "1",
+			data: "1\n",
+		},
+		{
+			//
+			// Test-case 2: Client and server-side fastopen. No errors expected.
+			//
+			name: "2",
+			data: "3\n",
+		},
+		{
+			//
+			// Test-case 3: All bitmask flags set. No errors expected.
+			//
+			name: "3",
+			data: "1023\n",
+		},
+		{
+			//
+			// Test-case 4: Value beyond the bitmask range (EINVAL).
+			//
+			name:       "4",
+			data:       "1024\n",
+			wantErr:    true,
+			wantErrVal: fuse.IOerror{Code: syscall.EINVAL},
+		},
+		{
+			//
+			// Test-case 5: Negative value (EINVAL).
+			//
+			name:       "5",
+			data:       "-1\n",
+			wantErr:    true,
+			wantErrVal: fuse.IOerror{Code: syscall.EINVAL},
+		},
+		{
+			//
+			// Test-case 6: Non-numeric value (EINVAL).
+			//
+			name:       "6",
+			data:       "fast\n",
+			wantErr:    true,
+			wantErrVal: fuse.IOerror{Code: syscall.EINVAL},
+		},
+	}
+
+	//
+	// Testcase executions.
+	//
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			// Only valid values are expected to reach the container's net-ns.
+			if !tt.wantErr {
+				expectWrite(tt.data[:len(tt.data)-1])
+			}
+
+			req := &domain.HandlerRequest{
+				Pid:       cntr.InitPid(),
+				Data:      []byte(tt.data),
+				Container: cntr,
+			}
+
+			got, err := h.Write(n, req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NetNsIntBaseHandler.Write() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && tt.wantErrVal != nil && err != tt.wantErrVal {
+				t.Errorf("NetNsIntBaseHandler.Write() error = %v, wantErr %v, wantErrVal %v",
+					err, tt.wantErr, tt.wantErrVal)
+			}
+			if err == nil && got != len(tt.data) {
+				t.Errorf("NetNsIntBaseHandler.Write() = %v, want %v", got, len(tt.data))
+			}
+
+			// Ensure that mocks were properly invoked and reset expectedCalls
+			// object.
+			nss.AssertExpectations(t)
+			nss.ExpectedCalls = nil
+		})
+	}
+}
+
+func TestNetNsIntBaseHandler_ReadCached(t *testing.T) {
+
+	commonHandler := &implementations.ProcSysCommonHandler{
+		domain.HandlerBase{
+			Name:      "procSysCommon",
+			Path:      "procSysCommonHandler",
+			Enabled:   true,
+			Cacheable: true,
+			Service:   hds,
+		},
+	}
+	hds.On("FindHandler", "procSysCommonHandler").Return(commonHandler, true)
+
+	cntr := css.ContainerCreate(
+		"c1",
+		uint32(1001),
+		time.Time{},
+		231072,
+		65535,
+		231072,
+		65535,
+		nil,
+		nil,
+		false,
+		css)
+	_ = cntr.SetInitProc(cntr.InitPid(), cntr.UID(), cntr.GID())
+	cntr.InitProc().CreateNsInodes(123456)
+
+	for _, knob := range []string{"icmp_echo_ignore_all", "icmp_echo_ignore_broadcasts"} {
+		t.Run(knob, func(t *testing.T) {
+			h := &implementations.NetNsIntBaseHandler{
+				HandlerBase: domain.HandlerBase{
+					Name:      knob,
+					Path:      "/proc/sys/net/ipv4/" + knob,
+					Type:      domain.NODE_SUBSTITUTION,
+					Enabled:   true,
+					Cacheable: true,
+					Service:   hds,
+				},
+				Min: 0,
+				Max: 1,
+			}
+
+			n := ios.NewIOnode(knob, "/proc/sys/net/ipv4/"+knob, 0)
+
+			// Non-boolean values must be rejected (EINVAL).
+			if _, err := h.Write(n, &domain.HandlerRequest{
+				Pid:       cntr.InitPid(),
+				Data:      []byte("2\n"),
+				Container: cntr,
+			}); err != (fuse.IOerror{Code: syscall.EINVAL}) {
+				t.Errorf("NetNsIntBaseHandler.Write() error = %v, want %v",
+					err, fuse.IOerror{Code: syscall.EINVAL})
+			}
+
+			// Valid values are pushed to the container's net-ns ...
+			nsenterEventReq := &nsenter.NSenterEvent{
+				Pid:       cntr.InitPid(),
+				Namespace: &domain.AllNSsButMount,
+				ReqMsg: &domain.NSenterMessage{
+					Type: domain.WriteFileRequest,
+					Payload: &domain.WriteFilePayload{
+						File:    n.Path(),
+						Content: "1",
+					},
+					Creds: unmappedCreds,
+				},
+			}
+			nss.On(
+				"NewEvent",
+				cntr.InitPid(),
+				&domain.AllNSsButMount,
+				nsenterEventReq.ReqMsg,
+				(*domain.NSenterMessage)(nil),
+				false).Return(nsenterEventReq)
+			nss.On("SendRequestEvent", nsenterEventReq).Return(nil)
+			nss.On("ReceiveResponseEvent", nsenterEventReq).Return(
+				&domain.NSenterMessage{Type: domain.WriteFileResponse})
+
+			if _, err := h.Write(n, &domain.HandlerRequest{
+				Pid:       cntr.InitPid(),
+				Data:      []byte("1\n"),
+				Container: cntr,
+			}); err != nil {
+				t.Fatalf("NetNsIntBaseHandler.Write() error = %v", err)
+			}
+			nss.AssertExpectations(t)
+			nss.ExpectedCalls = nil
+
+			// ... and subsequent reads are served from the container's cache
+			// (no nsenter expectations set).
+			buf := make([]byte, 8)
+			rn, err := h.Read(n, &domain.HandlerRequest{
+				Pid:       cntr.InitPid(),
+				Data:      buf,
+				Container: cntr,
+			})
+			if err != nil {
+				t.Fatalf("NetNsIntBaseHandler.Read() error = %v", err)
+			}
+			if got := string(buf[:rn]); got != "1\n" {
+				t.Errorf("NetNsIntBaseHandler.Read() = %q, want %q", got, "1\n")
+			}
+			nss.AssertExpectations(t)
+		})
+	}
+}
+
+func TestNetNsIntBaseHandler_Route(t *testing.T) {
+
+	commonHandler := &implementations.ProcSysCommonHandler{
+		domain.HandlerBase{
+			Name:      "procSysCommon",
+			Path:      "procSysCommonHandler",
+			Enabled:   true,
+			Cacheable: true,
+			Service:   hds,
+		},
+	}
+	hds.On("FindHandler", "procSysCommonHandler").Return(commonHandler, true)
+
+	cntr := css.ContainerCreate(
+		"c1",
+		uint32(1001),
+		time.Time{},
+		231072,
+		65535,
+		231072,
+		65535,
+		nil,
+		nil,
+		false,
+		css)
+	_ = cntr.SetInitProc(cntr.InitPid(), cntr.UID(), cntr.GID())
+	cntr.InitProc().CreateNsInodes(123456)
+
+	expectWrite := func(path, val string) {
+		nsenterEventReq := &nsenter.NSenterEvent{
+			Pid:       cntr.InitPid(),
+			Namespace: &domain.AllNSsButMount,
+			ReqMsg: &domain.NSenterMessage{
+				Type: domain.WriteFileRequest,
+				Payload: &domain.WriteFilePayload{
+					File:    path,
+					Content: val,
+				},
+				Creds: unmappedCreds,
+			},
+		}
+		nss.On(
+			"NewEvent",
+			cntr.InitPid(),
+			&domain.AllNSsButMount,
+			nsenterEventReq.ReqMsg,
+			(*domain.NSenterMessage)(nil),
+			false).Return(nsenterEventReq)
+		nss.On("SendRequestEvent", nsenterEventReq).Return(nil)
+		nss.On("ReceiveResponseEvent", nsenterEventReq).Return(
+			&domain.NSenterMessage{Type: domain.WriteFileResponse})
+	}
+
+	t.Run("flush", func(t *testing.T) {
+		h := &implementations.NetNsIntBaseHandler{
+			HandlerBase: domain.HandlerBase{
+				Name:      "routeFlush",
+				Path:      "/proc/sys/net/ipv4/route/flush",
+				Type:      domain.NODE_SUBSTITUTION,
+				Enabled:   true,
+				Service:   hds,
+				WriteOnly: true,
+			},
+			Min: math.MinInt32,
+			Max: math.MaxInt32,
+		}
+
+		n := ios.NewIOnode("flush", "/proc/sys/net/ipv4/route/flush", 0)
+
+		// Read access is rejected (EACCES) by the fuse layer, without reaching
+		// the container.
+		if !h.GetWriteOnly() {
+			t.Errorf("NetNsIntBaseHandler.GetWriteOnly() = false, want true")
+		}
+
+		// Flush requests must be pushed down to the container's net-ns.
+		expectWrite(n.Path(), "1")
+		if _, err := h.Write(n, &domain.HandlerRequest{
+			Pid:       cntr.InitPid(),
+			Data:      []byte("1\n"),
+			Container: cntr,
+		}); err != nil {
+			t.Errorf("NetNsIntBaseHandler.Write() error = %v", err)
+		}
+		nss.AssertExpectations(t)
+		nss.ExpectedCalls = nil
+	})
+
+	t.Run("gc_thresh", func(t *testing.T) {
+		h := &implementations.NetNsIntBaseHandler{
+			HandlerBase: domain.HandlerBase{
+				Name:      "routeGcThresh",
+				Path:      "/proc/sys/net/ipv4/route/gc_thresh",
+				Type:      domain.NODE_SUBSTITUTION,
+				Enabled:   true,
+				Cacheable: true,
+				Service:   hds,
+			},
+			Min: -1,
+			Max: math.MaxInt32,
+		}
+
+		n := ios.NewIOnode("gc_thresh", "/proc/sys/net/ipv4/route/gc_thresh", 0)
+
+		// Values below the supported range must be rejected (EINVAL).
+		if _, err := h.Write(n, &domain.HandlerRequest{
+			Pid:       cntr.InitPid(),
+			Data:      []byte("-2\n"),
+			Container: cntr,
+		}); err != (fuse.IOerror{Code: syscall.EINVAL}) {
+			t.Errorf("NetNsIntBaseHandler.Write() error = %v, want %v",
+				err, fuse.IOerror{Code: syscall.EINVAL})
+		}
+
+		// The (default) -1 value must reach the container's net-ns.
+		expectWrite(n.Path(), "-1")
+		if _, err := h.Write(n, &domain.HandlerRequest{
+			Pid:       cntr.InitPid(),
+			Data:      []byte("-1\n"),
+			Container: cntr,
+		}); err != nil {
+			t.Errorf("NetNsIntBaseHandler.Write() error = %v", err)
+		}
+		nss.AssertExpectations(t)
+		nss.ExpectedCalls = nil
+	})
+}
+
+func TestNetNsIntBaseHandler_TcpTimeWait(t *testing.T) {
+
+	commonHandler := &implementations.ProcSysCommonHandler{
+		domain.HandlerBase{
+			Name:      "procSysCommon",
+			Path:      "procSysCommonHandler",
+			Enabled:   true,
+			Cacheable: true,
+			Service:   hds,
+		},
+	}
+	hds.On("FindHandler", "procSysCommonHandler").Return(commonHandler, true)
+
+	cntr := css.ContainerCreate(
+		"c1",
+		uint32(1001),
+		time.Time{},
+		231072,
+		65535,
+		231072,
+		65535,
+		nil,
+		nil,
+		false,
+		css)
+	_ = cntr.SetInitProc(cntr.InitPid(), cntr.UID(), cntr.GID())
+	cntr.InitProc().CreateNsInodes(123456)
+
+	tests := []struct {
+		name     string
+		min      int
+		max      int
+		invalid  []string
+		validVal string
+	}{
+		{
+			//
+			// Test-case 1: tcp_tw_reuse only accepts 0 (off), 1 (on) and 2
+			// (loopback-only).
+			//
+			name:     "tcp_tw_reuse",
+			min:      0,
+			max:      2,
+			invalid:  []string{"-1", "3"},
+			validVal: "2",
+		},
+		{
+			//
+			// Test-case 2: tcp_fin_timeout only accepts positive values.
+			//
+			name:     "tcp_fin_timeout",
+			min:      1,
+			max:      math.MaxInt32,
+			invalid:  []string{"-1", "0"},
+			validVal: "30",
+		},
+	}
+
+	//
+	// Testcase executions.
+	//
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &implementations.NetNsIntBaseHandler{
+				HandlerBase: domain.HandlerBase{
+					Name:      tt.name,
+					Path:      "/proc/sys/net/ipv4/" + tt.name,
+					Type:      domain.NODE_SUBSTITUTION,
+					Enabled:   true,
+					Cacheable: true,
+					Service:   hds,
+				},
+				Min: tt.min,
+				Max: tt.max,
+			}
+
+			n := ios.NewIOnode(tt.name, "/proc/sys/net/ipv4/"+tt.name, 0)
+
+			// Out-of-range values must be rejected (EINVAL) without reaching
+			// the container.
+			for _, val := range tt.invalid {
+				if _, err := h.Write(n, &domain.HandlerRequest{
+					Pid:       cntr.InitPid(),
+					Data:      []byte(val + "\n"),
+					Container: cntr,
+				}); err != (fuse.IOerror{Code: syscall.EINVAL}) {
+					t.Errorf("NetNsIntBaseHandler.Write(%v) error = %v, want %v",
+						val, err, fuse.IOerror{Code: syscall.EINVAL})
+				}
+			}
+
+			// Valid values are pushed to the container's net-ns ...
+			nsenterEventReq := &nsenter.NSenterEvent{
+				Pid:       cntr.InitPid(),
+				Namespace: &domain.AllNSsButMount,
+				ReqMsg: &domain.NSenterMessage{
+					Type: domain.WriteFileRequest,
+					Payload: &domain.WriteFilePayload{
+						File:    n.Path(),
+						Content: tt.validVal,
+					},
+					Creds: unmappedCreds,
+				},
+			}
+			nss.On(
+				"NewEvent",
+				cntr.InitPid(),
+				&domain.AllNSsButMount,
+				nsenterEventReq.ReqMsg,
+				(*domain.NSenterMessage)(nil),
+				false).Return(nsenterEventReq)
+			nss.On("SendRequestEvent", nsenterEventReq).Return(nil)
+			nss.On("ReceiveResponseEvent", nsenterEventReq).Return(
+				&domain.NSenterMessage{Type: domain.WriteFileResponse})
+
+			if _, err := h.Write(n, &domain.HandlerRequest{
+				Pid:       cntr.InitPid(),
+				Data:      []byte(tt.validVal + "\n"),
+				Container: cntr,
+			}); err != nil {
+				t.Fatalf("NetNsIntBaseHandler.Write() error = %v", err)
+			}
+			nss.AssertExpectations(t)
+			nss.ExpectedCalls = nil
+
+			// ... and subsequent reads are served from the container's cache
+			// (no nsenter expectations set).
+			buf := make([]byte, 16)
+			rn, err := h.Read(n, &domain.HandlerRequest{
+				Pid:       cntr.InitPid(),
+				Data:      buf,
+				Container: cntr,
+			})
+			if err != nil {
+				t.Fatalf("NetNsIntBaseHandler.Read() error = %v", err)
+			}
+			if got := string(buf[:rn]); got != tt.validVal+"\n" {
+				t.Errorf("NetNsIntBaseHandler.Read() = %q, want %q", got, tt.validVal+"\n")
+			}
+			nss.AssertExpectations(t)
+		})
+	}
+}
+
+func TestNetNsIntBaseHandler_TcpFeatureToggles(t *testing.T) {
+
+	commonHandler := &implementations.ProcSysCommonHandler{
+		domain.HandlerBase{
+			Name:      "procSysCommon",
+			Path:      "procSysCommonHandler",
+			Enabled:   true,
+			Cacheable: true,
+			Service:   hds,
+		},
+	}
+	hds.On("FindHandler", "procSysCommonHandler").Return(commonHandler, true)
+
+	cntr := css.ContainerCreate(
+		"c1",
+		uint32(1001),
+		time.Time{},
+		231072,
+		65535,
+		231072,
+		65535,
+		nil,
+		nil,
+		false,
+		css)
+	_ = cntr.SetInitProc(cntr.InitPid(), cntr.UID(), cntr.GID())
+	cntr.InitProc().CreateNsInodes(123456)
+
+	tests := []struct {
+		name     string
+		min      int
+		max      int
+		invalid  []string
+		validVal string
+	}{
+		{
+			//
+			// Test-case 1: tcp_sack only accepts 0 (off) and 1 (on).
+			//
+			name:     "tcp_sack",
+			min:      0,
+			max:      1,
+			invalid:  []string{"-1", "2", "on"},
+			validVal: "0",
+		},
+		{
+			//
+			// Test-case 2: tcp_window_scaling only accepts 0 (off) and 1 (on).
+			//
+			name:     "tcp_window_scaling",
+			min:      0,
+			max:      1,
+			invalid:  []string{"-1", "2", "on"},
+			validVal: "0",
+		},
+		{
+			//
+			// Test-case 3: tcp_timestamps also accepts 2 (on, without random
+			// offsets).
+			//
+			name:     "tcp_timestamps",
+			min:      0,
+			max:      2,
+			invalid:  []string{"-1", "3", "on"},
+			validVal: "2",
+		},
+		{
+			//
+			// Test-case 4: tcp_mtu_probing accepts 0 (off), 1 (on black hole
+			// detection) and 2 (always on).
+			//
+			name:     "tcp_mtu_probing",
+			min:      0,
+			max:      2,
+			invalid:  []string{"-1", "3", "1.5"},
+			validVal: "1",
+		},
+		{
+			//
+			// Test-case 5: tcp_no_metrics_save only accepts 0 (cache metrics of
+			// closed connections) and 1 (don't cache them).
+			//
+			name:     "tcp_no_metrics_save",
+			min:      0,
+			max:      1,
+			invalid:  []string{"-1", "2", "yes"},
+			validVal: "1",
+		},
+	}
+
+	//
+	// Testcase executions.
+	//
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &implementations.NetNsIntBaseHandler{
+				HandlerBase: domain.HandlerBase{
+					Name:      tt.name,
+					Path:      "/proc/sys/net/ipv4/" + tt.name,
+					Type:      domain.NODE_SUBSTITUTION,
+					Enabled:   true,
+					Cacheable: true,
+					Service:   hds,
+				},
+				Min: tt.min,
+				Max: tt.max,
+			}
+
+			n := ios.NewIOnode(tt.name, "/proc/sys/net/ipv4/"+tt.name, 0)
+
+			// Out-of-range values must be rejected (EINVAL) without reaching
+			// the container.
+			for _, val := range tt.invalid {
+				if _, err := h.Write(n, &domain.HandlerRequest{
+					Pid:       cntr.InitPid(),
+					Data:      []byte(val + "\n"),
+					Container: cntr,
+				}); err != (fuse.IOerror{Code: syscall.EINVAL}) {
+					t.Errorf("NetNsIntBaseHandler.Write(%v) error = %v, want %v",
+						val, err, fuse.IOerror{Code: syscall.EINVAL})
+				}
+			}
+
+			// Valid values are pushed to the container's net-ns ...
+			nsenterEventReq := &nsenter.NSenterEvent{
+				Pid:       cntr.InitPid(),
+				Namespace: &domain.AllNSsButMount,
+				ReqMsg: &domain.NSenterMessage{
+					Type: domain.WriteFileRequest,
+					Payload: &domain.WriteFilePayload{
+						File:    n.Path(),
+						Content: tt.validVal,
+					},
+					Creds: unmappedCreds,
+				},
+			}
+			nss.On(
+				"NewEvent",
+				cntr.InitPid(),
+				&domain.AllNSsButMount,
+				nsenterEventReq.ReqMsg,
+				(*domain.NSenterMessage)(nil),
+				false).Return(nsenterEventReq)
+			nss.On("SendRequestEvent", nsenterEventReq).Return(nil)
+			nss.On("ReceiveResponseEvent", nsenterEventReq).Return(
+				&domain.NSenterMessage{Type: domain.WriteFileResponse})
+
+			if _, err := h.Write(n, &domain.HandlerRequest{
+				Pid:       cntr.InitPid(),
+				Data:      []byte(tt.validVal + "\n"),
+				Container: cntr,
+			}); err != nil {
+				t.Fatalf("NetNsIntBaseHandler.Write() error = %v", err)
+			}
+			nss.AssertExpectations(t)
+			nss.ExpectedCalls = nil
+
+			// ... and subsequent reads are served from the container's cache
+			// (no nsenter expectations set).
+			buf := make([]byte, 16)
+			rn, err := h.Read(n, &domain.HandlerRequest{
+				Pid:       cntr.InitPid(),
+				Data:      buf,
+				Container: cntr,
+			})
+			if err != nil {
+				t.Fatalf("NetNsIntBaseHandler.Read() error = %v", err)
+			}
+			if got := string(buf[:rn]); got != tt.validVal+"\n" {
+				t.Errorf("NetNsIntBaseHandler.Read() = %q, want %q", got, tt.validVal+"\n")
+			}
+			nss.AssertExpectations(t)
+		})
+	}
+}
+
+func TestNetNsIntBaseHandler_NeighGcStaleTime(t *testing.T) {
+
+	commonHandler := &implementations.ProcSysCommonHandler{
+		domain.HandlerBase{
+			Name:      "procSysCommon",
+			Path:      "procSysCommonHandler",
+			Enabled:   true,
+			Cacheable: true,
+			Service:   hds,
+		},
+	}
+	hds.On("FindHandler", "procSysCommonHandler").Return(commonHandler, true)
+
+	cntr := css.ContainerCreate(
+		"c1",
+		uint32(1001),
+		time.Time{},
+		231072,
+		65535,
+		231072,
+		65535,
+		nil,
+		nil,
+		false,
+		css)
+	_ = cntr.SetInitProc(cntr.InitPid(), cntr.UID(), cntr.GID())
+	cntr.InitProc().CreateNsInodes(123456)
+
+	h := &implementations.NetNsIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "neighDefaultGcStaleTime",
+			Path:      "/proc/sys/net/ipv4/neigh/default/gc_stale_time",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+			Service:   hds,
+		},
+		Min: 1,
+		Max: math.MaxInt32,
+	}
+
+	n := ios.NewIOnode("gc_stale_time", h.Path, 0)
+
+	read := func() string {
+		buf := make([]byte, 16)
+		rn, err := h.Read(n, &domain.HandlerRequest{
+			Pid:       cntr.InitPid(),
+			Data:      buf,
+			Container: cntr,
+		})
+		if err != nil {
+			t.Fatalf("NetNsIntBaseHandler.Read() error = %v", err)
+		}
+		return string(buf[:rn])
+	}
+
+	// First read is served from the container's net-ns ...
+	nsenterEventReq := &nsenter.NSenterEvent{
+		Pid:       cntr.InitPid(),
+		Namespace: &domain.AllNSsButMount,
+		ReqMsg: &domain.NSenterMessage{
+			Type: domain.ReadFileRequest,
+			Payload: &domain.ReadFilePayload{
+				File: n.Path(),
+			},
+			Creds: unmappedCreds,
+		},
+	}
+	nss.On(
+		"NewEvent",
+		cntr.InitPid(),
+		&domain.AllNSsButMount,
+		nsenterEventReq.ReqMsg,
+		(*domain.NSenterMessage)(nil),
+		false).Return(nsenterEventReq).Once()
+	nss.On("SendRequestEvent", nsenterEventReq).Return(nil).Once()
+	nss.On("ReceiveResponseEvent", nsenterEventReq).Return(
+		&domain.NSenterMessage{Type: domain.ReadFileResponse, Payload: "60"}).Once()
+
+	if got := read(); got != "60\n" {
+		t.Errorf("NetNsIntBaseHandler.Read() = %q, want %q", got, "60\n")
+	}
+	nss.AssertExpectations(t)
+	nss.ExpectedCalls = nil
+
+	// ... and subsequent ones from the container's cache (no nsenter
+	// expectations set).
+	if got := read(); got != "60\n" {
+		t.Errorf("NetNsIntBaseHandler.Read() = %q, want %q", got, "60\n")
+	}
+
+	// Non-positive and non-integer values must be rejected (EINVAL) without
+	// reaching the container.
+	for _, val := range []string{"0", "-5", "abc"} {
+		if _, err := h.Write(n, &domain.HandlerRequest{
+			Pid:       cntr.InitPid(),
+			Data:      []byte(val + "\n"),
+			Container: cntr,
+		}); err != (fuse.IOerror{Code: syscall.EINVAL}) {
+			t.Errorf("NetNsIntBaseHandler.Write(%v) error = %v, want %v",
+				val, err, fuse.IOerror{Code: syscall.EINVAL})
+		}
+	}
+
+	// Valid values are pushed to the container's net-ns and refresh the cache.
+	nsenterEventReq = &nsenter.NSenterEvent{
+		Pid:       cntr.InitPid(),
+		Namespace: &domain.AllNSsButMount,
+		ReqMsg: &domain.NSenterMessage{
+			Type: domain.WriteFileRequest,
+			Payload: &domain.WriteFilePayload{
+				File:    n.Path(),
+				Content: "120",
+			},
+			Creds: unmappedCreds,
+		},
+	}
+	nss.On(
+		"NewEvent",
+		cntr.InitPid(),
+		&domain.AllNSsButMount,
+		nsenterEventReq.ReqMsg,
+		(*domain.NSenterMessage)(nil),
+		false).Return(nsenterEventReq)
+	nss.On("SendRequestEvent", nsenterEventReq).Return(nil)
+	nss.On("ReceiveResponseEvent", nsenterEventReq).Return(
+		&domain.NSenterMessage{Type: domain.WriteFileResponse})
+
+	if _, err := h.Write(n, &domain.HandlerRequest{
+		Pid:       cntr.InitPid(),
+		Data:      []byte("120\n"),
+		Container: cntr,
+	}); err != nil {
+		t.Fatalf("NetNsIntBaseHandler.Write() error = %v", err)
+	}
+	nss.AssertExpectations(t)
+	nss.ExpectedCalls = nil
+
+	if got := read(); got != "120\n" {
+		t.Errorf("NetNsIntBaseHandler.Read() = %q, want %q", got, "120\n")
+	}
+}
+
+func TestNetNsIntBaseHandler_ConfArp(t *testing.T) {
+
+	commonHandler := &implementations.ProcSysCommonHandler{
+		domain.HandlerBase{
+			Name:      "procSysCommon",
+			Path:      "procSysCommonHandler",
+			Enabled:   true,
+			Cacheable: true,
+			Service:   hds,
+		},
+	}
+	hds.On("FindHandler", "procSysCommonHandler").Return(commonHandler, true)
+
+	cntr := css.ContainerCreate(
+		"c1",
+		uint32(1001),
+		time.Time{},
+		231072,
+		65535,
+		231072,
+		65535,
+		nil,
+		nil,
+		false,
+		css)
+	_ = cntr.SetInitProc(cntr.InitPid(), cntr.UID(), cntr.GID())
+	cntr.InitProc().CreateNsInodes(123456)
+
+	arpIgnore := &implementations.NetNsIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "ipv4ConfArpIgnore",
+			Path:      "/proc/sys/net/ipv4/conf/*/arp_ignore",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+			Service:   hds,
+		},
+		Min: 0,
+		Max: 8,
+	}
+
+	arpAnnounce := &implementations.NetNsIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "ipv4ConfArpAnnounce",
+			Path:      "/proc/sys/net/ipv4/conf/*/arp_announce",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+			Service:   hds,
+		},
+		Min: 0,
+		Max: 2,
+	}
+
+	tests := []struct {
+		name       string
+		h          domain.HandlerIface
+		path       string
+		data       string
+		wantErr    bool
+		wantErrVal error
+	}{
+		{
+			//
+			// Test-case 1: arp_ignore over 'all' entry. No errors expected.
+			//
+			name: "1",
+			h:    arpIgnore,
+			path: "/proc/sys/net/ipv4/conf/all/arp_ignore",
+			data: "8\n",
+		},
+		{
+			//
+			// Test-case 2: arp_announce over 'default' entry. No errors expected.
+			//
+			name: "2",
+			h:    arpAnnounce,
+			path: "/proc/sys/net/ipv4/conf/default/arp_announce",
+			data: "2\n",
+		},
+		{
+			//
+			// Test-case 3: arp_ignore over a named interface. No errors expected.
+			//
+			name: "3",
+			h:    arpIgnore,
+			path: "/proc/sys/net/ipv4/conf/eth0/arp_ignore",
+			data: "1\n",
+		},
+		{
+			//
+			// Test-case 4: Out-of-range arp_ignore over a named interface (EINVAL).
+			//
+			name:       "4",
+			h:          arpIgnore,
+			path:       "/proc/sys/net/ipv4/conf/eth0/arp_ignore",
+			data:       "9\n",
+			wantErr:    true,
+			wantErrVal: fuse.IOerror{Code: syscall.EINVAL},
+		},
+		{
+			//
+			// Test-case 5: Out-of-range arp_announce over a named interface
+			// (EINVAL).
+			//
+			name:       "5",
+			h:          arpAnnounce,
+			path:       "/proc/sys/net/ipv4/conf/eth0/arp_announce",
+			data:       "3\n",
+			wantErr:    true,
+			wantErrVal: fuse.IOerror{Code: syscall.EINVAL},
+		},
+		{
+			//
+			// Test-case 6: Negative arp_announce over 'all' entry (EINVAL).
+			//
+			name:       "6",
+			h:          arpAnnounce,
+			path:       "/proc/sys/net/ipv4/conf/all/arp_announce",
+			data:       "-1\n",
+			wantErr:    true,
+			wantErrVal: fuse.IOerror{Code: syscall.EINVAL},
+		},
+	}
+
+	//
+	// Testcase executions.
+	//
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			n := ios.NewIOnode(path.Base(tt.path), tt.path, 0)
+
+			// Only valid values are expected to reach the container's net-ns,
+			// over the path of the interface being accessed.
+			if !tt.wantErr {
+				nsenterEventReq := &nsenter.NSenterEvent{
+					Pid:       cntr.InitPid(),
+					Namespace: &domain.AllNSsButMount,
+					ReqMsg: &domain.NSenterMessage{
+						Type: domain.WriteFileRequest,
+						Payload: &domain.WriteFilePayload{
+							File:    tt.path,
+							Content: tt.data[:len(tt.data)-1],
+						},
+						Creds: unmappedCreds,
+					},
+				}
+				nss.On(
+					"NewEvent",
+					cntr.InitPid(),
+					&domain.AllNSsButMount,
+					nsenterEventReq.ReqMsg,
+					(*domain.NSenterMessage)(nil),
+					false).Return(nsenterEventReq)
+				nss.On("SendRequestEvent", nsenterEventReq).Return(nil)
+				nss.On("ReceiveResponseEvent", nsenterEventReq).Return(
+					&domain.NSenterMessage{Type: domain.WriteFileResponse})
+			}
+
+			req := &domain.HandlerRequest{
+				Pid:       cntr.InitPid(),
+				Data:      []byte(tt.data),
+				Container: cntr,
+			}
+
+			_, err := tt.h.Write(n, req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NetNsIntBaseHandler.Write() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && tt.wantErrVal != nil && err != tt.wantErrVal {
+				t.Errorf("NetNsIntBaseHandler.Write() error = %v, wantErr %v, wantErrVal %v",
+					err, tt.wantErr, tt.wantErrVal)
+			}
+
+			// Valid values are cached per interface.
+			if !tt.wantErr {
+				buf := make([]byte, 16)
+				rn, err := tt.h.Read(n, &domain.HandlerRequest{
+					Pid:       cntr.InitPid(),
+					Data:      buf,
+					Container: cntr,
+				})
+				if err != nil {
+					t.Fatalf("NetNsIntBaseHandler.Read() error = %v", err)
+				}
+				if got := string(buf[:rn]); got != tt.data {
+					t.Errorf("NetNsIntBaseHandler.Read() = %q, want %q", got, tt.data)
+				}
+			}
+
+			// Ensure that mocks were properly invoked and reset expectedCalls
+			// object.
+			nss.AssertExpectations(t)
+			nss.ExpectedCalls = nil
+		})
+	}
+}
+
+func TestNetNsIntBaseHandler_ConfRouteLocalnet(t *testing.T) {
+
+	commonHandler := &implementations.ProcSysCommonHandler{
+		domain.HandlerBase{
+			Name:      "procSysCommon",
+			Path:      "procSysCommonHandler",
+			Enabled:   true,
+			Cacheable: true,
+			Service:   hds,
+		},
+	}
+	hds.On("FindHandler", "procSysCommonHandler").Return(commonHandler, true)
+
+	cntr := css.ContainerCreate(
+		"c1",
+		uint32(1001),
+		time.Time{},
+		231072,
+		65535,
+		231072,
+		65535,
+		nil,
+		nil,
+		false,
+		css)
+	_ = cntr.SetInitProc(cntr.InitPid(), cntr.UID(), cntr.GID())
+	cntr.InitProc().CreateNsInodes(123456)
+
+	routeLocalnet := &implementations.NetNsIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "ipv4ConfRouteLocalnet",
+			Path:      "/proc/sys/net/ipv4/conf/*/route_localnet",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+			Service:   hds,
+		},
+		Min: 0,
+		Max: 1,
+	}
+
+	tests := []struct {
+		name       string
+		h          domain.HandlerIface
+		path       string
+		data       string
+		wantErr    bool
+		wantErrVal error
+	}{
+		{
+			//
+			// Test-case 1: Enabling over 'all' entry. No errors expected.
+			//
+			name: "1",
+			h:    routeLocalnet,
+			path: "/proc/sys/net/ipv4/conf/all/route_localnet",
+			data: "1\n",
+		},
+		{
+			//
+			// Test-case 2: Disabling over 'default' entry. No errors expected.
+			//
+			name: "2",
+			h:    routeLocalnet,
+			path: "/proc/sys/net/ipv4/conf/default/route_localnet",
+			data: "0\n",
+		},
+		{
+			//
+			// Test-case 3: Enabling over a named interface. No errors expected.
+			//
+			name: "3",
+			h:    routeLocalnet,
+			path: "/proc/sys/net/ipv4/conf/eth0/route_localnet",
+			data: "1\n",
+		},
+		{
+			//
+			// Test-case 4: Non-boolean value over a named interface (EINVAL).
+			//
+			name:       "4",
+			h:          routeLocalnet,
+			path:       "/proc/sys/net/ipv4/conf/eth0/route_localnet",
+			data:       "2\n",
+			wantErr:    true,
+			wantErrVal: fuse.IOerror{Code: syscall.EINVAL},
+		},
+		{
+			//
+			// Test-case 5: Negative value over 'all' entry (EINVAL).
+			//
+			name:       "5",
+			h:          routeLocalnet,
+			path:       "/proc/sys/net/ipv4/conf/all/route_localnet",
+			data:       "-1\n",
+			wantErr:    true,
+			wantErrVal: fuse.IOerror{Code: syscall.EINVAL},
+		},
+	}
+
+	//
+	// Testcase executions.
+	//
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			n := ios.NewIOnode(path.Base(tt.path), tt.path, 0)
+
+			// Only valid values are expected to reach the container's net-ns,
+			// over the path of the interface being accessed.
+			if !tt.wantErr {
+				nsenterEventReq := &nsenter.NSenterEvent{
+					Pid:       cntr.InitPid(),
+					Namespace: &domain.AllNSsButMount,
+					ReqMsg: &domain.NSenterMessage{
+						Type: domain.WriteFileRequest,
+						Payload: &domain.WriteFilePayload{
+							File:    tt.path,
+							Content: tt.data[:len(tt.data)-1],
+						},
+						Creds: unmappedCreds,
+					},
+				}
+				nss.On(
+					"NewEvent",
+					cntr.InitPid(),
+					&domain.AllNSsButMount,
+					nsenterEventReq.ReqMsg,
+					(*domain.NSenterMessage)(nil),
+					false).Return(nsenterEventReq)
+				nss.On("SendRequestEvent", nsenterEventReq).Return(nil)
+				nss.On("ReceiveResponseEvent", nsenterEventReq).Return(
+					&domain.NSenterMessage{Type: domain.WriteFileResponse})
+			}
+
+			req := &domain.HandlerRequest{
+				Pid:       cntr.InitPid(),
+				Data:      []byte(tt.data),
+				Container: cntr,
+			}
+
+			_, err := tt.h.Write(n, req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NetNsIntBaseHandler.Write() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && tt.wantErrVal != nil && err != tt.wantErrVal {
+				t.Errorf("NetNsIntBaseHandler.Write() error = %v, wantErr %v, wantErrVal %v",
+					err, tt.wantErr, tt.wantErrVal)
+			}
+
+			// Valid values are cached per interface.
+			if !tt.wantErr {
+				buf := make([]byte, 16)
+				rn, err := tt.h.Read(n, &domain.HandlerRequest{
+					Pid:       cntr.InitPid(),
+					Data:      buf,
+					Container: cntr,
+				})
+				if err != nil {
+					t.Fatalf("NetNsIntBaseHandler.Read() error = %v", err)
+				}
+				if got := string(buf[:rn]); got != tt.data {
+					t.Errorf("NetNsIntBaseHandler.Read() = %q, want %q", got, tt.data)
+				}
+			}
+
+			// Ensure that mocks were properly invoked and reset expectedCalls
+			// object.
+			nss.AssertExpectations(t)
+			nss.ExpectedCalls = nil
+		})
+	}
+}
+
+func TestNetNsIntBaseHandler_AcceptMissing(t *testing.T) {
+
+	commonHandler := &implementations.ProcSysCommonHandler{
+		domain.HandlerBase{
+			Name:      "procSysCommon",
+			Path:      "procSysCommonHandler",
+			Enabled:   true,
+			Cacheable: true,
+			Service:   hds,
+		},
+	}
+	hds.On("FindHandler", "procSysCommonHandler").Return(commonHandler, true)
+
+	cntr := css.ContainerCreate(
+		"c1",
+		uint32(1001),
+		time.Time{},
+		231072,
+		65535,
+		231072,
+		65535,
+		nil,
+		nil,
+		false,
+		css)
+	_ = cntr.SetInitProc(cntr.InitPid(), cntr.UID(), cntr.GID())
+	cntr.InitProc().CreateNsInodes(123456)
+
+	// Process living in an inner net-ns, whose requests are never served from
+	// the container's cache by the common handler.
+	const innerPid = uint32(7001)
+	prs.ProcessCreate(innerPid, 0, 0).CreateNsInodes(123456)
+	innerNetns := ios.NewIOnode("net", "/proc/7001/ns/net", 0)
+	if err := innerNetns.WriteFile([]byte("654321")); err != nil {
+		t.Fatalf("Could not create %v: %v", innerNetns.Path(), err)
+	}
+
+	// Error generated by the agent for resources missing in the host kernel.
+	enoent := fuse.IOerror{Code: syscall.ENOENT}
+
+	tests := []struct {
+		name          string
+		acceptMissing bool
+		wantErrVal    error
+	}{
+		{
+			//
+			// Test-case 1: Default policy. Lookup and write of the missing
+			// resource must fail (ENOENT).
+			//
+			name:          "1",
+			acceptMissing: false,
+			wantErrVal:    enoent,
+		},
+		{
+			//
+			// Test-case 2: Accept-missing policy. Lookup and write must succeed,
+			// and the written value must be served from the container's cache,
+			// even to readers the common handler doesn't serve from it.
+			//
+			name:          "2",
+			acceptMissing: true,
+			wantErrVal:    nil,
+		},
+	}
+
+	//
+	// Testcase executions.
+	//
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			h := &implementations.NetNsIntBaseHandler{
+				HandlerBase: domain.HandlerBase{
+					Name:      "ipv4TcpFooBar",
+					Path:      "/proc/sys/net/ipv4/tcp_foo_bar_" + tt.name,
+					Type:      domain.NODE_SUBSTITUTION,
+					Enabled:   true,
+					Cacheable: true,
+					Service:   hds,
+				},
+				Min:           0,
+				Max:           1,
+				AcceptMissing: tt.acceptMissing,
+			}
+
+			n := ios.NewIOnode(path.Base(h.Path), h.Path, 0)
+
+			// Every agent interaction reports the resource as missing.
+			innerReadMsg := &domain.NSenterMessage{
+				Type:    domain.ReadFileRequest,
+				Payload: &domain.ReadFilePayload{File: n.Path()},
+				Creds:   unmappedCreds,
+			}
+			innerEventReq := &nsenter.NSenterEvent{
+				Pid:       innerPid,
+				Namespace: &domain.AllNSsButMount,
+				ReqMsg:    innerReadMsg,
+			}
+			nss.On(
+				"NewEvent",
+				innerPid,
+				&domain.AllNSsButMount,
+				innerReadMsg,
+				(*domain.NSenterMessage)(nil),
+				false).Return(innerEventReq)
+			nss.On("SendRequestEvent", innerEventReq).Return(nil)
+			nss.On("ReceiveResponseEvent", innerEventReq).Return(
+				&domain.NSenterMessage{Type: domain.ErrorResponse, Payload: enoent})
+
+			for _, reqMsg := range []*domain.NSenterMessage{
+				{
+					Type:    domain.LookupRequest,
+					Payload: &domain.LookupPayload{Entry: n.Path()},
+					Creds:   unmappedCreds,
+				},
+				{
+					Type: domain.WriteFileRequest,
+					Payload: &domain.WriteFilePayload{
+						File:    n.Path(),
+						Content: "1",
+					},
+					Creds: unmappedCreds,
+				},
+			} {
+				nsenterEventReq := &nsenter.NSenterEvent{
+					Pid:       cntr.InitPid(),
+					Namespace: &domain.AllNSsButMount,
+					ReqMsg:    reqMsg,
+				}
+				nss.On(
+					"NewEvent",
+					cntr.InitPid(),
+					&domain.AllNSsButMount,
+					nsenterEventReq.ReqMsg,
+					(*domain.NSenterMessage)(nil),
+					false).Return(nsenterEventReq)
+				nss.On("SendRequestEvent", nsenterEventReq).Return(nil)
+				nss.On("ReceiveResponseEvent", nsenterEventReq).Return(
+					&domain.NSenterMessage{Type: domain.ErrorResponse, Payload: enoent})
+			}
+
+			req := &domain.HandlerRequest{
+				Pid:       cntr.InitPid(),
+				Container: cntr,
+			}
+
+			if _, err := h.Lookup(n, req); err != tt.wantErrVal {
+				t.Errorf("NetNsIntBaseHandler.Lookup() error = %v, want %v", err, tt.wantErrVal)
+			}
+
+			req.Data = []byte("1\n")
+			got, err := h.Write(n, req)
+			if err != tt.wantErrVal {
+				t.Errorf("NetNsIntBaseHandler.Write() error = %v, want %v", err, tt.wantErrVal)
+			}
+			if err == nil && got != len(req.Data) {
+				t.Errorf("NetNsIntBaseHandler.Write() = %v, want %v", got, len(req.Data))
+			}
+
+			// Values accepted into the cache must be served from it (no nsenter
+			// expectations set for reads).
+			if tt.wantErrVal == nil {
+				buf := make([]byte, 16)
+				rn, err := h.Read(n, &domain.HandlerRequest{
+					Pid:       cntr.InitPid(),
+					Data:      buf,
+					Container: cntr,
+				})
+				if err != nil {
+					t.Fatalf("NetNsIntBaseHandler.Read() error = %v", err)
+				}
+				if got := string(buf[:rn]); got != "1\n" {
+					t.Errorf("NetNsIntBaseHandler.Read() = %q, want %q", got, "1\n")
+				}
+			}
+
+			// Readers reaching the agent get the cached value as well.
+			buf := make([]byte, 16)
+			rn, err := h.Read(n, &domain.HandlerRequest{
+				Pid:       innerPid,
+				Data:      buf,
+				Container: cntr,
+			})
+			if err != tt.wantErrVal {
+				t.Errorf("NetNsIntBaseHandler.Read() error = %v, want %v", err, tt.wantErrVal)
+			}
+			if err == nil && string(buf[:rn]) != "1\n" {
+				t.Errorf("NetNsIntBaseHandler.Read() = %q, want %q", string(buf[:rn]), "1\n")
+			}
+
+			// Ensure that mocks were properly invoked and reset expectedCalls
+			// object.
+			nss.AssertExpectations(t)
+			nss.ExpectedCalls = nil
+		})
+	}
+}
+
+func TestNetNsIntBaseHandler_Reconfigure(t *testing.T) {
+
+	h := &implementations.NetNsIntBaseHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "ipv4TcpFastopen",
+			Path:      "/proc/sys/net/ipv4/tcp_fastopen",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+			Service:   hds,
+		},
+		Min: 0,
+		Max: 0x607,
+	}
+
+	accept := true
+
+	nh, err := h.Reconfigure(domain.HandlerConfig{AcceptMissing: &accept})
+	if err != nil {
+		t.Fatalf("NetNsIntBaseHandler.Reconfigure() error = %v", err)
+	}
+	if nn, ok := nh.(*implementations.NetNsIntBaseHandler); !ok || !nn.AcceptMissing ||
+		nn.Min != h.Min || nn.Max != h.Max {
+		t.Errorf("NetNsIntBaseHandler.Reconfigure() = %+v, want accept-missing copy", nh)
+	}
+	if h.AcceptMissing {
+		t.Errorf("NetNsIntBaseHandler.Reconfigure() altered the original handler")
+	}
+
+	for i, cfg := range []domain.HandlerConfig{
+		{WriteMode: domain.WriteModePassthrough},
+		{AllowedContainers: []string{"c1"}},
+		{MergePolicy: domain.MergePolicyMax},
+	} {
+		if _, err := h.Reconfigure(cfg); err == nil {
+			t.Errorf("NetNsIntBaseHandler.Reconfigure() config %d accepted, want error", i+1)
+		}
+	}
+}
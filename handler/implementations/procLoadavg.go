@@ -1,145 +0,0 @@
-//
-// Copyright 2019-2020 Nestybox, Inc.
-//
-// Licensed under the Apache License, Version 2.0 (the "License");
-// you may not use this file except in compliance with the License.
-// You may obtain a copy of the License at
-//
-//    https://www.apache.org/licenses/LICENSE-2.0
-//
-// Unless required by applicable law or agreed to in writing, software
-// distributed under the License is distributed on an "AS IS" BASIS,
-// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
-// See the License for the specific language governing permissions and
-// limitations under the License.
-//
-
-package implementations
-
-import (
-	"io"
-	"os"
-	"syscall"
-
-	"github.com/sirupsen/logrus"
-
-	"github.com/nestybox/sysbox-fs/domain"
-	"github.com/nestybox/sysbox-fs/fuse"
-)
-
-//
-// /proc/loadavg Handler
-//
-type ProcLoadavgHandler struct {
-	domain.HandlerBase
-}
-
-func (h *ProcLoadavgHandler) Lookup(
-	n domain.IOnodeIface,
-	req *domain.HandlerRequest) (os.FileInfo, error) {
-
-	logrus.Debugf("Executing Lookup() method on %v handler", h.Name)
-
-	return n.Stat()
-}
-
-func (h *ProcLoadavgHandler) Getattr(
-	n domain.IOnodeIface,
-	req *domain.HandlerRequest) (*syscall.Stat_t, error) {
-
-	logrus.Debugf("Executing Getattr() method on %v handler", h.Name)
-
-	return nil, nil
-}
-
-func (h *ProcLoadavgHandler) Open(
-	n domain.IOnodeIface,
-	req *domain.HandlerRequest) error {
-
-	logrus.Debugf("Executing %v Open() method", h.Name)
-
-	flags := n.OpenFlags()
-	if flags != syscall.O_RDONLY {
-		return fuse.IOerror{Code: syscall.EACCES}
-	}
-
-	if err := n.Open(); err != nil {
-		logrus.Debugf("Error opening file %v", h.Path)
-		return fuse.IOerror{Code: syscall.EIO}
-	}
-
-	return nil
-}
-
-func (h *ProcLoadavgHandler) Close(n domain.IOnodeIface) error {
-
-	logrus.Debugf("Executing Close() method on %v handler", h.Name)
-
-	if err := n.Close(); err != nil {
-		logrus.Debugf("Error closing file %v", h.Path)
-		return fuse.IOerror{Code: syscall.EIO}
-	}
-
-	return nil
-}
-
-func (h *ProcLoadavgHandler) Read(
-	n domain.IOnodeIface,
-	req *domain.HandlerRequest) (int, error) {
-
-	logrus.Debugf("Executing %v Read() method", h.Name)
-
-	// Bypass emulation logic for now by going straight to host fs.
-	len, err := n.Read(req.Data)
-	if err != nil && err != io.EOF {
-		return 0, err
-	}
-
-	req.Data = req.Data[:len]
-
-	return len, nil
-}
-
-func (h *ProcLoadavgHandler) Write(
-	n domain.IOnodeIface,
-	req *domain.HandlerRequest) (int, error) {
-
-	logrus.Debugf("Executing %v Write() method", h.Name)
-
-	return 0, nil
-}
-
-func (h *ProcLoadavgHandler) ReadDirAll(
-	n domain.IOnodeIface,
-	req *domain.HandlerRequest) ([]os.FileInfo, error) {
-
-	return nil, nil
-}
-
-func (h *ProcLoadavgHandler) GetName() string {
-	return h.Name
-}
-
-func (h *ProcLoadavgHandler) GetPath() string {
-	return h.Path
-}
-
-func (h *ProcLoadavgHandler) GetEnabled() bool {
-	return h.Enabled
-}
-
-func (h *ProcLoadavgHandler) GetType() domain.HandlerType {
-	return h.Type
-}
-
-func (h *ProcLoadavgHandler) GetService() domain.HandlerServiceIface {
-	return h.Service
-}
-
-func (h *ProcLoadavgHandler) SetEnabled(val bool) {
-	h.Enabled = val
-}
-
-func (h *ProcLoadavgHandler) SetService(hs domain.HandlerServiceIface) {
-	h.Service = hs
-}
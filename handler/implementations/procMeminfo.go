@@ -1,143 +0,0 @@
-//
-// Copyright 2019-2020 Nestybox, Inc.
-//
-// Licensed under the Apache License, Version 2.0 (the "License");
-// you may not use this file except in compliance with the License.
-// You may obtain a copy of the License at
-//
-//    https://www.apache.org/licenses/LICENSE-2.0
-//
-// Unless required by applicable law or agreed to in writing, software
-// distributed under the License is distributed on an "AS IS" BASIS,
-// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
-// See the License for the specific language governing permissions and
-// limitations under the License.
-//
-
-package implementations
-
-import (
-	"io"
-	"os"
-	"syscall"
-
-	"github.com/sirupsen/logrus"
-
-	"github.com/nestybox/sysbox-fs/domain"
-	"github.com/nestybox/sysbox-fs/fuse"
-)
-
-//
-// /proc/meminfo Handler
-//
-type ProcMeminfoHandler struct {
-	domain.HandlerBase
-}
-
-func (h *ProcMeminfoHandler) Lookup(
-	n domain.IOnodeIface,
-	req *domain.HandlerRequest) (os.FileInfo, error) {
-
-	logrus.Debugf("Executing Lookup() method on %v handler", h.Name)
-
-	return n.Stat()
-}
-
-func (h *ProcMeminfoHandler) Getattr(
-	n domain.IOnodeIface,
-	req *domain.HandlerRequest) (*syscall.Stat_t, error) {
-
-	logrus.Debugf("Executing Getattr() method on %v handler", h.Name)
-
-	return nil, nil
-}
-
-func (h *ProcMeminfoHandler) Open(
-	n domain.IOnodeIface,
-	req *domain.HandlerRequest) error {
-
-	logrus.Debugf("Executing %v Open() method", h.Name)
-
-	flags := n.OpenFlags()
-	if flags != syscall.O_RDONLY {
-		return fuse.IOerror{Code: syscall.EACCES}
-	}
-
-	if err := n.Open(); err != nil {
-		logrus.Debugf("Error opening file %v", h.Path)
-		return fuse.IOerror{Code: syscall.EIO}
-	}
-
-	return nil
-}
-
-func (h *ProcMeminfoHandler) Close(n domain.IOnodeIface) error {
-
-	logrus.Debugf("Executing Close() method on %v handler", h.Name)
-
-	if err := n.Close(); err != nil {
-		logrus.Debugf("Error closing file %v", h.Path)
-		return fuse.IOerror{Code: syscall.EIO}
-	}
-
-	return nil
-}
-
-func (h *ProcMeminfoHandler) Read(
-	n domain.IOnodeIface,
-	req *domain.HandlerRequest) (int, error) {
-
-	logrus.Debugf("Executing %v Read() method", h.Name)
-
-	// Bypass emulation logic for now by going straight to host fs.
-	len, err := n.Read(req.Data)
-	if err != nil && err != io.EOF {
-		return 0, err
-	}
-
-	req.Data = req.Data[:len]
-
-	return len, nil
-}
-
-func (h *ProcMeminfoHandler) Write(
-	n domain.IOnodeIface,
-	req *domain.HandlerRequest) (int, error) {
-
-	return 0, nil
-}
-
-func (h *ProcMeminfoHandler) ReadDirAll(
-	n domain.IOnodeIface,
-	req *domain.HandlerRequest) ([]os.FileInfo, error) {
-
-	return nil, nil
-}
-
-func (h *ProcMeminfoHandler) GetName() string {
-	return h.Name
-}
-
-func (h *ProcMeminfoHandler) GetPath() string {
-	return h.Path
-}
-
-func (h *ProcMeminfoHandler) GetEnabled() bool {
-	return h.Enabled
-}
-
-func (h *ProcMeminfoHandler) GetType() domain.HandlerType {
-	return h.Type
-}
-
-func (h *ProcMeminfoHandler) GetService() domain.HandlerServiceIface {
-	return h.Service
-}
-
-func (h *ProcMeminfoHandler) SetEnabled(val bool) {
-	h.Enabled = val
-}
-
-func (h *ProcMeminfoHandler) SetService(hs domain.HandlerServiceIface) {
-	h.Service = hs
-}
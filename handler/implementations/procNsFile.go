@@ -0,0 +1,73 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"github.com/nestybox/sysbox-fs/domain"
+)
+
+//
+// Compute function generator (to be utilized through a ComputedHandler whose
+// NsKey matches the given namespace) for files whose content depends on the
+// namespace of the given type of the reading process, e.g. the last-pid field
+// of /proc/loadavg, which the kernel takes from the reader's active pid-ns
+// rather than from the one of the procfs mount. The host file is read by an
+// agent joining the reading process' namespace, so processes of nested
+// containers get their own view rather than the one of the container's init
+// process.
+//
+// Notice that the agent stays within sysbox-fs' mount-ns on purpose: within the
+// container's one the emulated path is backed by sysbox-fs itself, so reading it
+// there would recurse into the very handler being served.
+//
+func NsFileCompute(ns domain.NStype) func(
+	hs domain.HandlerServiceIface,
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (string, error) {
+
+	return func(
+		hs domain.HandlerServiceIface,
+		n domain.IOnodeIface,
+		req *domain.HandlerRequest) (string, error) {
+
+		nss := hs.NSenterService()
+		event := nss.NewEvent(
+			req.Pid,
+			&[]domain.NStype{ns},
+			&domain.NSenterMessage{
+				Type: domain.ReadFileRequest,
+				Payload: &domain.ReadFilePayload{
+					File: n.Path(),
+					Raw:  true,
+				},
+			},
+			nil,
+			false,
+		)
+
+		if err := nss.SendRequestEvent(event); err != nil {
+			return "", err
+		}
+
+		responseMsg := nss.ReceiveResponseEvent(event)
+		if responseMsg.Type == domain.ErrorResponse {
+			return "", responseMsg.Payload.(error)
+		}
+
+		return responseMsg.Payload.(string), nil
+	}
+}
@@ -0,0 +1,292 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+)
+
+// Id displayed for host uids/gids not mapped into the container's user-ns (see
+// kernel's overflowuid / overflowgid).
+const overflowId = 65534
+
+// Namespaces the /proc/<pid>/status file is read within: the ones the requester's
+// <pid> (as well as the procfs instance hosting it) are relative to.
+var procPidStatusNSs = []domain.NStype{
+	string(domain.NStypePid),
+	string(domain.NStypeMount),
+}
+
+//
+// /proc/<pid>/status handler
+//
+// The file is read within the pid and mount namespaces of the requester (through
+// nsenter), so that <pid> refers to the process the requester has in mind.
+// The pids displayed in the NSpid / NStgid fields are thereby relative to the
+// requester's procfs instance (i.e. to the container's pid-ns or a nested one);
+// the uids/gids are host ones though, as the user-ns is not entered. These are
+// translated through the container's id-mappings; those not mapped are
+// displayed as the overflow id. All other fields are passed through.
+//
+type ProcPidStatusHandler struct {
+	domain.HandlerBase
+}
+
+func (h *ProcPidStatusHandler) Lookup(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (os.FileInfo, error) {
+
+	logrus.Debugf("Executing Lookup() method on %v handler", h.Name)
+
+	// Ensure operation is generated from within a registered sys container.
+	if req.Container == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return nil, errors.New("Container not found")
+	}
+
+	nss := h.Service.NSenterService()
+	event := nss.NewEvent(
+		req.Pid,
+		&procPidStatusNSs,
+		&domain.NSenterMessage{
+			Type: domain.LookupRequest,
+			Payload: &domain.LookupPayload{
+				Entry: n.Path(),
+			},
+		},
+		nil,
+		false,
+	)
+
+	if err := nss.SendRequestEvent(event); err != nil {
+		return nil, err
+	}
+
+	responseMsg := nss.ReceiveResponseEvent(event)
+	if responseMsg.Type == domain.ErrorResponse {
+		return nil, responseMsg.Payload.(error)
+	}
+
+	return responseMsg.Payload.(domain.FileInfo), nil
+}
+
+func (h *ProcPidStatusHandler) Getattr(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (*syscall.Stat_t, error) {
+
+	logrus.Debugf("Executing Getattr() method on %v handler", h.Name)
+
+	return nil, nil
+}
+
+func (h *ProcPidStatusHandler) Open(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) error {
+
+	logrus.Debugf("Executing %v Open() method", h.Name)
+
+	// The host's file is not opened, as <pid> is relative to the requester's
+	// pid-ns (see Read()).
+	flags := n.OpenFlags()
+	if flags != syscall.O_RDONLY {
+		return fuse.IOerror{Code: syscall.EACCES}
+	}
+
+	return nil
+}
+
+func (h *ProcPidStatusHandler) Close(n domain.IOnodeIface) error {
+
+	logrus.Debugf("Executing Close() method on %v handler", h.Name)
+
+	return nil
+}
+
+func (h *ProcPidStatusHandler) Read(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Read() method", h.Name)
+
+	cntr := req.Container
+
+	// Ensure operation is generated from within a registered sys container.
+	if cntr == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return 0, errors.New("Container not found")
+	}
+
+	content, err := h.fetchFile(n, req.Pid)
+	if err != nil {
+		logrus.Errorf("Could not read from file %v: %v", n.Path(), err)
+		return 0, fuse.IOerror{Code: syscall.EIO}
+	}
+
+	data := translateProcStatus(content, cntr)
+
+	if req.Offset >= int64(len(data)) {
+		return 0, io.EOF
+	}
+
+	return copyResultBuffer(req.Data, []byte(data[req.Offset:]))
+}
+
+func (h *ProcPidStatusHandler) Write(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	return 0, fuse.IOerror{Code: syscall.EACCES}
+}
+
+func (h *ProcPidStatusHandler) ReadDirAll(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) ([]os.FileInfo, error) {
+
+	return nil, nil
+}
+
+// fetchFile reads the given /proc/<pid>/status file within the namespaces of
+// the requesting process.
+func (h *ProcPidStatusHandler) fetchFile(
+	n domain.IOnodeIface,
+	pid uint32) (string, error) {
+
+	nss := h.Service.NSenterService()
+	event := nss.NewEvent(
+		pid,
+		&procPidStatusNSs,
+		&domain.NSenterMessage{
+			Type: domain.ReadFileRequest,
+			Payload: &domain.ReadFilePayload{
+				File: n.Path(),
+				Raw:  true,
+			},
+		},
+		nil,
+		false,
+	)
+
+	if err := nss.SendRequestEvent(event); err != nil {
+		return "", err
+	}
+
+	responseMsg := nss.ReceiveResponseEvent(event)
+	if responseMsg.Type == domain.ErrorResponse {
+		return "", responseMsg.Payload.(error)
+	}
+
+	return responseMsg.Payload.(string), nil
+}
+
+// translateProcStatus rewrites the uid/gid fields of the passed /proc/<pid>/status
+// content into the container's user-ns.
+func translateProcStatus(content string, cntr domain.ContainerIface) string {
+
+	lines := strings.Split(content, "\n")
+
+	for i, line := range lines {
+		sep := strings.Index(line, ":")
+		if sep < 0 {
+			continue
+		}
+		key := line[:sep]
+		fields := strings.Fields(line[sep+1:])
+
+		switch key {
+		case "Uid":
+			fields = translateIds(fields, cntr.UID(), cntr.UIDSize())
+		case "Gid":
+			fields = translateIds(fields, cntr.GID(), cntr.GIDSize())
+		default:
+			continue
+		}
+
+		lines[i] = key + ":\t" + strings.Join(fields, "\t")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// translateIds maps the passed host ids into the container's id range
+// [first, first+size).
+func translateIds(ids []string, first, size uint32) []string {
+
+	res := make([]string, len(ids))
+
+	for i, id := range ids {
+		hostId, err := strconv.ParseUint(id, 10, 32)
+		if err != nil {
+			res[i] = id
+			continue
+		}
+
+		res[i] = strconv.FormatUint(uint64(cntrId(uint32(hostId), first, size)), 10)
+	}
+
+	return res
+}
+
+// cntrId maps the passed host id into the container's id range [first,
+// first+size), unmapped ids being shown as the overflow id.
+func cntrId(hostId, first, size uint32) uint32 {
+
+	if hostId >= first && uint64(hostId) < uint64(first)+uint64(size) {
+		return hostId - first
+	}
+
+	return overflowId
+}
+
+func (h *ProcPidStatusHandler) GetName() string {
+	return h.Name
+}
+
+func (h *ProcPidStatusHandler) GetPath() string {
+	return h.Path
+}
+
+func (h *ProcPidStatusHandler) GetEnabled() bool {
+	return h.Enabled
+}
+
+func (h *ProcPidStatusHandler) GetType() domain.HandlerType {
+	return h.Type
+}
+
+func (h *ProcPidStatusHandler) GetService() domain.HandlerServiceIface {
+	return h.Service
+}
+
+func (h *ProcPidStatusHandler) SetEnabled(val bool) {
+	h.Enabled = val
+}
+
+func (h *ProcPidStatusHandler) SetService(hs domain.HandlerServiceIface) {
+	h.Service = hs
+}
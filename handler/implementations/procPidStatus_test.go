@@ -0,0 +1,167 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations_test
+
+import (
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+	"github.com/nestybox/sysbox-fs/handler/implementations"
+	"github.com/nestybox/sysbox-fs/nsenter"
+)
+
+func TestProcPidStatusHandler_Read(t *testing.T) {
+
+	h := &implementations.ProcPidStatusHandler{
+		domain.HandlerBase{
+			Name:    "procPidStatus",
+			Path:    "/proc/*/status",
+			Type:    domain.NODE_SUBSTITUTION,
+			Enabled: true,
+			Service: hds,
+		},
+	}
+
+	// Requester's view of a process within the container (pids relative to
+	// the container's procfs, host uids/gids).
+	status := strings.Join([]string{
+		"Name:\tbash",
+		"Umask:\t0022",
+		"State:\tS (sleeping)",
+		"Tgid:\t340",
+		"NStgid:\t340\t10",
+		"Pid:\t340",
+		"NSpid:\t340\t10",
+		"PPid:\t1",
+		"Uid:\t231072\t231072\t232072\t231072",
+		"Gid:\t231072\t231072\t231072\t300000",
+		"Groups:\t",
+		"",
+	}, "\n")
+
+	nsList := []domain.NStype{
+		string(domain.NStypePid),
+		string(domain.NStypeMount),
+	}
+
+	tests := []struct {
+		name    string
+		reqPid  uint32
+		path    string
+		resMsg  *domain.NSenterMessage
+		wantErr bool
+		want    []string
+	}{
+		{
+			//
+			// Test-case 1: File read within the requester's namespaces. Host
+			// ids are translated and unmapped ones (300000) displayed as the
+			// overflow id; pids (already relative to the requester's procfs)
+			// and remaining fields are passed through.
+			//
+			name:   "1",
+			reqPid: 1001,
+			path:   "/proc/340/status",
+			resMsg: &domain.NSenterMessage{
+				Type:    domain.ReadFileResponse,
+				Payload: status,
+			},
+			want: []string{
+				"Uid:\t0\t0\t1000\t0",
+				"Gid:\t0\t0\t0\t65534",
+				"NStgid:\t340\t10",
+				"NSpid:\t340\t10",
+				"Name:\tbash",
+				"Tgid:\t340",
+				"Pid:\t340",
+				"PPid:\t1",
+				"Groups:\t",
+			},
+		},
+		{
+			//
+			// Test-case 2: Process not present within the requester's pid-ns.
+			//
+			name:   "2",
+			reqPid: 1002,
+			path:   "/proc/341/status",
+			resMsg: &domain.NSenterMessage{
+				Type:    domain.ErrorResponse,
+				Payload: fuse.IOerror{Code: syscall.ENOENT},
+			},
+			wantErr: true,
+		},
+	}
+
+	cntr := css.ContainerCreate("c1", 1001, time.Time{},
+		231072, 65535, 231072, 65535, nil, nil, false, nil)
+
+	//
+	// Testcase executions.
+	//
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := ios.NewIOnode("status", tt.path, 0)
+
+			nsenterEventReq := &nsenter.NSenterEvent{
+				Pid:       tt.reqPid,
+				Namespace: &nsList,
+				ReqMsg: &domain.NSenterMessage{
+					Type: domain.ReadFileRequest,
+					Payload: &domain.ReadFilePayload{
+						File: tt.path,
+						Raw:  true,
+					},
+				},
+			}
+			nss.On(
+				"NewEvent",
+				tt.reqPid,
+				&nsList,
+				nsenterEventReq.ReqMsg,
+				(*domain.NSenterMessage)(nil),
+				false).Return(nsenterEventReq)
+			nss.On("SendRequestEvent", nsenterEventReq).Return(nil)
+			nss.On("ReceiveResponseEvent", nsenterEventReq).Return(tt.resMsg)
+
+			buf := make([]byte, 4096)
+			rn, err := h.Read(n, &domain.HandlerRequest{
+				Pid:       tt.reqPid,
+				Data:      buf,
+				Container: cntr,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ProcPidStatusHandler.Read() error = %v, wantErr %v",
+					err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got := string(buf[:rn])
+
+			for _, line := range tt.want {
+				if !strings.Contains(got, line+"\n") {
+					t.Errorf("ProcPidStatusHandler.Read() = %q, missing line %q", got, line)
+				}
+			}
+		})
+	}
+}
@@ -17,8 +17,12 @@
 package implementations
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"os"
+	"strconv"
+	"strings"
 	"syscall"
 
 	"github.com/sirupsen/logrus"
@@ -30,10 +34,36 @@ import (
 //
 // /proc/stat Handler
 //
+// Synthesizes a /proc/stat that matches the container's view of the system:
+// only the cpus within the container's cpuset are displayed (cpuN lines), and
+// the busy time of the aggregate cpu line is scaled down to the cpu time
+// consumed by the container's cgroup (leftovers are accounted as idle time).
+// The remaining lines are taken from the host, except for 'btime', which
+// reflects the container's creation time to be consistent with /proc/uptime.
+// Host figures are displayed whenever the container's cpuset or cpu usage
+// can't be obtained.
+//
 type ProcStatHandler struct {
 	domain.HandlerBase
 }
 
+// Clock ticks per second (USER_HZ) in which cpu times are expressed.
+const procStatUserHZ = 100
+
+// Indexes of the cpu time columns within the cpu lines of /proc/stat.
+const (
+	procStatUser = iota
+	procStatNice
+	procStatSystem
+	procStatIdle
+	procStatIowait
+	procStatIrq
+	procStatSoftirq
+	procStatSteal
+	procStatGuest
+	procStatGuestNice
+)
+
 func (h *ProcStatHandler) Lookup(
 	n domain.IOnodeIface,
 	req *domain.HandlerRequest) (os.FileInfo, error) {
@@ -89,15 +119,28 @@ func (h *ProcStatHandler) Read(
 
 	logrus.Debugf("Executing %v Read() method", h.Name)
 
-	// Bypass emulation logic for now by going straight to host fs.
-	len, err := n.Read(req.Data)
+	cntr := req.Container
+
+	// Ensure operation is generated from within a registered sys container.
+	if cntr == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return 0, errors.New("Container not found")
+	}
+
+	content, err := n.ReadFile()
 	if err != nil && err != io.EOF {
-		return 0, err
+		logrus.Errorf("Could not read from file %v", h.Path)
+		return 0, fuse.IOerror{Code: syscall.EIO}
 	}
 
-	req.Data = req.Data[:len]
+	data := h.synthesize(string(content), cntr)
 
-	return len, nil
+	if req.Offset >= int64(len(data)) {
+		return 0, io.EOF
+	}
+
+	return copyResultBuffer(req.Data, []byte(data[req.Offset:]))
 }
 
 func (h *ProcStatHandler) Write(
@@ -114,6 +157,194 @@ func (h *ProcStatHandler) ReadDirAll(
 	return nil, nil
 }
 
+// synthesize returns the container's view of the passed (host) /proc/stat
+// content.
+func (h *ProcStatHandler) synthesize(content string, cntr domain.ContainerIface) string {
+
+	// Cpus the container is allowed to run on; nil stands for all of them.
+	var cpus map[int]bool
+
+	if list, err := cntrCpuList(h.Service.IOService(), cntr); err == nil {
+		if cpuList, err := cpuListParse(list); err == nil {
+			cpus = make(map[int]bool)
+			for _, cpu := range cpuList {
+				cpus[cpu] = true
+			}
+		}
+	}
+
+	var (
+		total    []uint64
+		cpuLines []string
+		others   []string
+	)
+
+	for _, line := range strings.Split(strings.TrimRight(content, "\n"), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch {
+		case fields[0] == "cpu":
+			// Aggregate line is recomputed out of the displayed cpus.
+			continue
+
+		case strings.HasPrefix(fields[0], "cpu"):
+			cpu, err := strconv.Atoi(fields[0][len("cpu"):])
+			if err != nil {
+				others = append(others, line)
+				continue
+			}
+			if cpus != nil && !cpus[cpu] {
+				continue
+			}
+
+			for i, f := range fields[1:] {
+				val, _ := strconv.ParseUint(f, 10, 64)
+				if i < len(total) {
+					total[i] += val
+				} else {
+					total = append(total, val)
+				}
+			}
+			cpuLines = append(cpuLines, line)
+
+		case fields[0] == "btime" && !cntr.Ctime().IsZero():
+			others = append(others, fmt.Sprintf("btime %d", cntr.Ctime().Unix()))
+
+		default:
+			others = append(others, line)
+		}
+	}
+
+	if usage, err := h.cntrCpuUsage(cntr); err == nil {
+		procStatScale(total, usage)
+	} else {
+		logrus.Debugf("Could not obtain cpu usage of container %v (%v): displaying host cpu times",
+			cntr.ID(), err)
+	}
+
+	vals := make([]string, len(total))
+	for i, val := range total {
+		vals[i] = strconv.FormatUint(val, 10)
+	}
+
+	var sb strings.Builder
+
+	sb.WriteString("cpu  " + strings.Join(vals, " ") + "\n")
+	for _, line := range cpuLines {
+		sb.WriteString(line + "\n")
+	}
+	for _, line := range others {
+		sb.WriteString(line + "\n")
+	}
+
+	return sb.String()
+}
+
+// procStatScale scales down the busy times of the passed aggregate cpu line so
+// that they add up to the given usage (in clock ticks). The difference is
+// accounted as idle time to keep the overall time unaltered.
+func procStatScale(total []uint64, usage uint64) {
+
+	if len(total) <= procStatSteal {
+		return
+	}
+
+	busyCols := []int{procStatUser, procStatNice, procStatSystem,
+		procStatIrq, procStatSoftirq, procStatSteal}
+
+	var busy uint64
+	for _, i := range busyCols {
+		busy += total[i]
+	}
+	if busy == 0 || usage >= busy {
+		return
+	}
+
+	factor := float64(usage) / float64(busy)
+
+	var scaled uint64
+	for _, i := range busyCols {
+		total[i] = uint64(float64(total[i]) * factor)
+		scaled += total[i]
+	}
+
+	// Guest times are included within the user ones, so scale them alike.
+	for i := procStatGuest; i < len(total) && i <= procStatGuestNice; i++ {
+		total[i] = uint64(float64(total[i]) * factor)
+	}
+
+	total[procStatIdle] += busy - scaled
+}
+
+// cntrCpuUsage returns the cpu time (in clock ticks) consumed by the container's
+// cgroup, either from the v1 cpuacct hierarchy or the unified (v2) one. Files are
+// read within the container's namespaces, where the cgroup-ns root matches the
+// container's cgroup.
+func (h *ProcStatHandler) cntrCpuUsage(cntr domain.ContainerIface) (uint64, error) {
+
+	content, err := h.fetchFile(cgroupMountPoint+"/cpuacct/cpuacct.usage", cntr)
+	if err == nil {
+		usage, err := strconv.ParseUint(strings.TrimSpace(content), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		return usage * procStatUserHZ / 1e9, nil
+	}
+
+	content, err = h.fetchFile(cgroupMountPoint+"/cpu.stat", cntr)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "usage_usec" {
+			usage, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return 0, err
+			}
+			return usage * procStatUserHZ / 1e6, nil
+		}
+	}
+
+	return 0, errors.New("usage_usec entry not found")
+}
+
+// Auxiliary method to fetch the content of any given file within the container's
+// namespaces.
+func (h *ProcStatHandler) fetchFile(
+	path string,
+	cntr domain.ContainerIface) (string, error) {
+
+	nss := h.Service.NSenterService()
+	event := nss.NewEvent(
+		cntr.InitPid(),
+		&domain.AllNSs,
+		&domain.NSenterMessage{
+			Type: domain.ReadFileRequest,
+			Payload: &domain.ReadFilePayload{
+				File: path,
+			},
+		},
+		nil,
+		false,
+	)
+
+	if err := nss.SendRequestEvent(event); err != nil {
+		return "", err
+	}
+
+	responseMsg := nss.ReceiveResponseEvent(event)
+	if responseMsg.Type == domain.ErrorResponse {
+		return "", responseMsg.Payload.(error)
+	}
+
+	return responseMsg.Payload.(string), nil
+}
+
 func (h *ProcStatHandler) GetName() string {
 	return h.Name
 }
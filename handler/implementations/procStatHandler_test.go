@@ -0,0 +1,216 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations_test
+
+import (
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+	"github.com/nestybox/sysbox-fs/handler/implementations"
+	"github.com/nestybox/sysbox-fs/nsenter"
+)
+
+func TestProcStatHandler_Read(t *testing.T) {
+
+	h := &implementations.ProcStatHandler{
+		domain.HandlerBase{
+			Name:    "procStat",
+			Path:    "/proc/stat",
+			Type:    domain.NODE_SUBSTITUTION | domain.NODE_BINDMOUNT,
+			Enabled: true,
+			Service: hds,
+		},
+	}
+
+	// Host FS initial state.
+	initFile := func(path, content string) {
+		if err := ios.NewIOnode("", path, 0).WriteFile([]byte(content)); err != nil {
+			t.Fatalf("Could not initialize host file %v: %v", path, err)
+		}
+	}
+	initFile(h.Path,
+		"cpu  400 0 200 1000 0 0 0 0 0 0\n"+
+			"cpu0 100 0 50 250 0 0 0 0 0 0\n"+
+			"cpu1 100 0 50 250 0 0 0 0 0 0\n"+
+			"cpu2 100 0 50 250 0 0 0 0 0 0\n"+
+			"cpu3 100 0 50 250 0 0 0 0 0 0\n"+
+			"intr 12345 0 9\n"+
+			"ctxt 999\n"+
+			"btime 1600000000\n"+
+			"processes 100\n"+
+			"procs_running 1\n"+
+			"procs_blocked 0\n")
+
+	// Cgroup files as seen within the container's namespaces. An empty content
+	// stands for a missing file.
+	expectRead := func(pid uint32, file string, content string) {
+		nsenterEventReq := &nsenter.NSenterEvent{
+			Pid:       pid,
+			Namespace: &domain.AllNSs,
+			ReqMsg: &domain.NSenterMessage{
+				Type: domain.ReadFileRequest,
+				Payload: &domain.ReadFilePayload{
+					File: file,
+				},
+			},
+		}
+
+		nss.On(
+			"NewEvent",
+			pid,
+			&domain.AllNSs,
+			nsenterEventReq.ReqMsg,
+			(*domain.NSenterMessage)(nil),
+			false).Return(nsenterEventReq)
+
+		resp := &domain.NSenterMessage{
+			Type:    domain.ReadFileResponse,
+			Payload: content,
+		}
+		if content == "" {
+			resp = &domain.NSenterMessage{
+				Type:    domain.ErrorResponse,
+				Payload: fuse.IOerror{Code: syscall.ENOENT},
+			}
+		}
+
+		nss.On("SendRequestEvent", nsenterEventReq).Return(nil)
+		nss.On("ReceiveResponseEvent", nsenterEventReq).Return(resp)
+	}
+
+	tests := []struct {
+		name     string
+		pid      uint32
+		ctime    time.Time
+		prepare  func()
+		want     string
+		wantCpus int
+	}{
+		{
+			//
+			// Test-case 1: Container restricted to two cpus and having consumed
+			// 1 sec (100 ticks) of cpu time.
+			//
+			name:  "1",
+			pid:   4001,
+			ctime: time.Unix(1700000000, 0),
+			prepare: func() {
+				initFile("/proc/4001/cgroup", "5:cpu,cpuacct:/docker/s1\n4:cpuset:/docker/s1\n")
+				initFile("/sys/fs/cgroup/cpuset/docker/s1/cpuset.cpus", "1,3")
+				expectRead(4001, "/sys/fs/cgroup/cpuacct/cpuacct.usage", "1000000000\n")
+			},
+			want: "cpu  66 0 33 701 0 0 0 0 0 0\n" +
+				"cpu1 100 0 50 250 0 0 0 0 0 0\n" +
+				"cpu3 100 0 50 250 0 0 0 0 0 0\n" +
+				"intr 12345 0 9\n" +
+				"ctxt 999\n" +
+				"btime 1700000000\n" +
+				"processes 100\n" +
+				"procs_running 1\n" +
+				"procs_blocked 0\n",
+			wantCpus: 2,
+		},
+		{
+			//
+			// Test-case 2: Container within the unified (v2) hierarchy.
+			//
+			name: "2",
+			pid:  4002,
+			prepare: func() {
+				initFile("/proc/4002/cgroup", "0::/docker/s2\n")
+				initFile("/sys/fs/cgroup/docker/s2/cpuset.cpus.effective", "0-2")
+				expectRead(4002, "/sys/fs/cgroup/cpuacct/cpuacct.usage", "")
+				expectRead(4002, "/sys/fs/cgroup/cpu.stat",
+					"usage_usec 1500000\nuser_usec 1000000\nsystem_usec 500000\n")
+			},
+			wantCpus: 3,
+		},
+		{
+			//
+			// Test-case 3: Neither cpuset nor cpu usage available. Host cpus
+			// and times displayed.
+			//
+			name: "3",
+			pid:  4003,
+			prepare: func() {
+				expectRead(4003, "/sys/fs/cgroup/cpuacct/cpuacct.usage", "")
+				expectRead(4003, "/sys/fs/cgroup/cpu.stat", "")
+			},
+			want: "cpu  400 0 200 1000 0 0 0 0 0 0\n" +
+				"cpu0 100 0 50 250 0 0 0 0 0 0\n" +
+				"cpu1 100 0 50 250 0 0 0 0 0 0\n" +
+				"cpu2 100 0 50 250 0 0 0 0 0 0\n" +
+				"cpu3 100 0 50 250 0 0 0 0 0 0\n" +
+				"intr 12345 0 9\n" +
+				"ctxt 999\n" +
+				"btime 1600000000\n" +
+				"processes 100\n" +
+				"procs_running 1\n" +
+				"procs_blocked 0\n",
+			wantCpus: 4,
+		},
+	}
+
+	//
+	// Testcase executions.
+	//
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cntr := css.ContainerCreate(
+				"c1",
+				tt.pid,
+				tt.ctime,
+				231072,
+				65535,
+				231072,
+				65535,
+				nil,
+				nil,
+				false,
+				nil)
+
+			tt.prepare()
+
+			buf := make([]byte, 1024)
+			req := &domain.HandlerRequest{Pid: tt.pid, Data: buf, Container: cntr}
+
+			sz, err := h.Read(ios.NewIOnode("", h.Path, 0), req)
+			if err != nil {
+				t.Fatalf("ProcStatHandler.Read() error = %v", err)
+			}
+			got := string(buf[:sz])
+
+			if tt.want != "" && got != tt.want {
+				t.Errorf("ProcStatHandler.Read() = %q, want %q", got, tt.want)
+			}
+
+			var cpus int
+			for _, line := range strings.Split(got, "\n") {
+				if strings.HasPrefix(line, "cpu") && !strings.HasPrefix(line, "cpu ") {
+					cpus++
+				}
+			}
+			if cpus != tt.wantCpus {
+				t.Errorf("ProcStatHandler.Read() cpu lines = %v, want %v", cpus, tt.wantCpus)
+			}
+		})
+	}
+}
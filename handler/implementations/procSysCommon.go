@@ -18,13 +18,17 @@ package implementations
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"syscall"
+	"unicode"
 
 	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
 
 	"github.com/sirupsen/logrus"
 )
@@ -56,6 +60,11 @@ func (h *ProcSysCommonHandler) Lookup(
 		return nil, errors.New("Container not found")
 	}
 
+	creds, err := h.nsenterCreds(req)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create nsenterEvent to initiate interaction with container namespaces.
 	nss := h.Service.NSenterService()
 	event := nss.NewEvent(
@@ -66,13 +75,14 @@ func (h *ProcSysCommonHandler) Lookup(
 			Payload: &domain.LookupPayload{
 				Entry: n.Path(),
 			},
+			Creds: creds,
 		},
 		nil,
 		false,
 	)
 
 	// Launch nsenter-event.
-	err := nss.SendRequestEvent(event)
+	err = nss.SendRequestEvent(event)
 	if err != nil {
 		return nil, err
 	}
@@ -122,6 +132,26 @@ func (h *ProcSysCommonHandler) Open(
 		return errors.New("Container not found")
 	}
 
+	// Resources within /proc/sys can't be created by the user, so there's no
+	// point in dispatching an nsenter request for such an open() attempt.
+	if domain.IsCreateOpenFlags(n.OpenFlags()) {
+		logrus.Debugf("Rejecting open() with creation flags %#o on %s",
+			n.OpenFlags(), n.Path())
+		return fuse.IOerror{Code: syscall.EPERM}
+	}
+
+	// The open() dispatched below merely validates the access to the resource,
+	// so the truncation requested by O_TRUNC is left to the subsequent Write(),
+	// which replaces the content through a single agent operation. Otherwise
+	// other readers could observe the (transient) truncated content between
+	// both operations.
+	flags := n.OpenFlags() &^ syscall.O_TRUNC
+
+	creds, err := h.nsenterCreds(req)
+	if err != nil {
+		return err
+	}
+
 	// Create nsenterEvent to initiate interaction with container namespaces.
 	nss := h.Service.NSenterService()
 	event := nss.NewEvent(
@@ -131,16 +161,17 @@ func (h *ProcSysCommonHandler) Open(
 			Type: domain.OpenFileRequest,
 			Payload: &domain.OpenFilePayload{
 				File:  n.Path(),
-				Flags: strconv.Itoa(n.OpenFlags()),
+				Flags: strconv.Itoa(flags),
 				Mode:  strconv.Itoa(int(n.OpenMode())),
 			},
+			Creds: creds,
 		},
 		nil,
 		false,
 	)
 
 	// Launch nsenter-event.
-	err := nss.SendRequestEvent(event)
+	err = nss.SendRequestEvent(event)
 	if err != nil {
 		return err
 	}
@@ -199,34 +230,55 @@ func (h *ProcSysCommonHandler) Read(
 	// know when the namespace ceases to exist in order to destroy the cache associated
 	// with it.
 	//
-	if h.Cacheable && domain.ProcessNsMatch(process, cntr.InitProc()) {
+	if h.IsCacheable(req) {
 
 		// If this resource is cached, return it's data; otherwise fetch its data from the
 		// host FS and store it in the cache.
 		cntr.Lock()
 		data, ok = cntr.Data(path, name)
 		if !ok {
-			data, err = h.fetchFile(n, process)
+			cntr.CacheMiss()
+			data, err = h.fetchFile(n, process, req)
 			if err != nil {
 				cntr.Unlock()
 				return 0, err
 			}
 
 			cntr.SetData(path, name, data)
+		} else {
+			cntr.CacheHit()
 		}
 		cntr.Unlock()
 	} else {
-		data, err = h.fetchFile(n, process)
+		cntr.CacheMiss()
+		data, err = h.fetchFile(n, process, req)
 		if err != nil {
 			return 0, err
 		}
 	}
 
-	data += "\n"
+	if !h.Raw {
+		data += "\n"
+	}
 
 	return copyResultBuffer(req.Data, []byte(data))
 }
 
+// IsCacheable narrows the handler's cacheability down to the requests
+// originated within the namespaces of the container's init process (see the
+// rationale in Read()).
+func (h *ProcSysCommonHandler) IsCacheable(req *domain.HandlerRequest) bool {
+
+	if !h.Cacheable || req.Container == nil {
+		return false
+	}
+
+	prs := h.Service.ProcessService()
+	process := prs.ProcessCreate(req.Pid, req.Uid, req.Gid)
+
+	return domain.ProcessNsMatch(process, req.Container.InitProc())
+}
+
 func (h *ProcSysCommonHandler) Write(
 	n domain.IOnodeIface,
 	req *domain.HandlerRequest) (int, error) {
@@ -245,28 +297,47 @@ func (h *ProcSysCommonHandler) Write(
 
 	newContent := strings.TrimSpace(string(req.Data))
 
+	// Leading whitespaces are trimmed, yet they count as consumed.
+	lead := len(req.Data) - len(strings.TrimLeftFunc(string(req.Data), unicode.IsSpace))
+
 	prs := h.Service.ProcessService()
 	process := prs.ProcessCreate(req.Pid, req.Uid, req.Gid)
 	cntr := req.Container
 
+	creds, err := h.nsenterCreds(req)
+	if err != nil {
+		return 0, err
+	}
+
+	var written int
+
 	// If caching is enabled, store the data in the cache and do a write-through to the
-	// host FS. Otherwise just do the write-through.
-	if h.Cacheable && domain.ProcessNsMatch(process, cntr.InitProc()) {
+	// host FS. Otherwise just do the write-through. Only the portion effectively
+	// written is cached.
+	if h.IsCacheable(req) {
 
 		cntr.Lock()
-		if err := h.pushFile(n, process, newContent); err != nil {
+		written, err = h.pushFile(n, process, creds, newContent)
+		if err != nil {
 			cntr.Unlock()
 			return 0, err
 		}
-		cntr.SetData(path, name, newContent)
+		cntr.SetData(path, name, newContent[:written])
 		cntr.Unlock()
 
 	} else {
-		if err := h.pushFile(n, process, newContent); err != nil {
+		written, err = h.pushFile(n, process, creds, newContent)
+		if err != nil {
 			return 0, err
 		}
 	}
 
+	// Report short writes as such, so that the FUSE layer reflects the actual
+	// write progress.
+	if written < len(newContent) {
+		return lead + written, nil
+	}
+
 	return len(req.Data), nil
 }
 
@@ -285,30 +356,50 @@ func (h *ProcSysCommonHandler) ReadDirAll(
 	}
 
 	// Create nsenterEvent to initiate interaction with container namespaces.
+	// Large directories are served in pages (partial responses), which are
+	// requested till the directory is exhausted.
 	nss := h.Service.NSenterService()
-	event := nss.NewEvent(
-		req.Pid,
-		&domain.AllNSsButMount,
-		&domain.NSenterMessage{
-			Type: domain.ReadDirRequest,
-			Payload: &domain.ReadDirPayload{
-				Dir: n.Path(),
-			},
-		},
-		nil,
-		false,
-	)
-
-	// Launch nsenter-event.
-	err := nss.SendRequestEvent(event)
+	creds, err := h.nsenterCreds(req)
 	if err != nil {
 		return nil, err
 	}
 
-	// Obtain nsenter-event response.
-	responseMsg := nss.ReceiveResponseEvent(event)
-	if responseMsg.Type == domain.ErrorResponse {
-		return nil, responseMsg.Payload.(error)
+	var dirEntries []domain.FileInfo
+
+	for {
+		event := nss.NewEvent(
+			req.Pid,
+			&domain.AllNSsButMount,
+			&domain.NSenterMessage{
+				Type: domain.ReadDirRequest,
+				Payload: &domain.ReadDirPayload{
+					Dir:    n.Path(),
+					Offset: len(dirEntries),
+				},
+				Creds: creds,
+			},
+			nil,
+			false,
+		)
+
+		// Launch nsenter-event.
+		err := nss.SendRequestEvent(event)
+		if err != nil {
+			return nil, err
+		}
+
+		// Obtain nsenter-event response.
+		responseMsg := nss.ReceiveResponseEvent(event)
+		if responseMsg.Type == domain.ErrorResponse {
+			return nil, responseMsg.Payload.(error)
+		}
+
+		entries := responseMsg.Payload.([]domain.FileInfo)
+		dirEntries = append(dirEntries, entries...)
+
+		if responseMsg.Type != domain.ReadDirPartResponse || len(entries) == 0 {
+			break
+		}
 	}
 
 	// Obtain FileEntries corresponding to emulated resources that could
@@ -328,7 +419,6 @@ func (h *ProcSysCommonHandler) ReadDirAll(
 	// Transform event-response payload into a FileInfo slice. Notice that to
 	// convert []T1 struct to a []T2 one, we must iterate through each element
 	// and do the conversion one element at a time.
-	dirEntries := responseMsg.Payload.([]domain.FileInfo)
 	for _, v := range dirEntries {
 		// Append nodes that don't overlap with emulated resources
 		if _, ok := osEmulatedFileEntries[v.Name()]; !ok {
@@ -339,6 +429,56 @@ func (h *ProcSysCommonHandler) ReadDirAll(
 	return osFileEntries, nil
 }
 
+func (h *ProcSysCommonHandler) Rmdir(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) error {
+
+	logrus.Debugf("Executing Rmdir() method for Req ID=%#x on %v handler",
+		req.ID, h.Name)
+
+	// Ensure operation is generated from within a registered sys container.
+	if req.Container == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return errors.New("Container not found")
+	}
+
+	creds, err := h.nsenterCreds(req)
+	if err != nil {
+		return err
+	}
+
+	// Create nsenterEvent to initiate interaction with container namespaces.
+	nss := h.Service.NSenterService()
+	event := nss.NewEvent(
+		req.Pid,
+		&domain.AllNSsButMount,
+		&domain.NSenterMessage{
+			Type: domain.RmdirRequest,
+			Payload: &domain.RmdirPayload{
+				Dir: n.Path(),
+			},
+			Creds: creds,
+		},
+		nil,
+		false,
+	)
+
+	// Launch nsenter-event.
+	err = nss.SendRequestEvent(event)
+	if err != nil {
+		return err
+	}
+
+	// Obtain nsenter-event response.
+	responseMsg := nss.ReceiveResponseEvent(event)
+	if responseMsg.Type == domain.ErrorResponse {
+		return responseMsg.Payload.(error)
+	}
+
+	return nil
+}
+
 func (h *ProcSysCommonHandler) Setattr(
 	n domain.IOnodeIface,
 	req *domain.HandlerRequest) error {
@@ -352,6 +492,11 @@ func (h *ProcSysCommonHandler) Setattr(
 		return errors.New("Container not found")
 	}
 
+	creds, err := h.nsenterCreds(req)
+	if err != nil {
+		return err
+	}
+
 	// Create nsenterEvent to initiate interaction with container namespaces.
 	nss := h.Service.NSenterService()
 	event := nss.NewEvent(
@@ -364,13 +509,14 @@ func (h *ProcSysCommonHandler) Setattr(
 				Flags: strconv.Itoa(n.OpenFlags()),
 				Mode:  strconv.Itoa(int(n.OpenMode())),
 			},
+			Creds: creds,
 		},
 		nil,
 		false,
 	)
 
 	// Launch nsenter-event.
-	err := nss.SendRequestEvent(event)
+	err = nss.SendRequestEvent(event)
 	if err != nil {
 		return err
 	}
@@ -384,10 +530,129 @@ func (h *ProcSysCommonHandler) Setattr(
 	return nil
 }
 
+// Auxiliary method to obtain the credentials the nsenter agent is to assume on
+// behalf of the process originating the request, so that the permission checks
+// carried out by the kernel within the container match the ones of the caller.
+// Host ids are mapped into the user-ns of the caller (the one joined by the
+// agent), unmapped ones being shown as the overflow id (as the kernel does).
+// For callers within the container's user-ns the container's id-mappings are
+// applied; callers within nested user-namespaces are mapped as per their own
+// uid_map / gid_map, and denied access if these can't be obtained.
+func (h *ProcSysCommonHandler) nsenterCreds(
+	req *domain.HandlerRequest) (*domain.NSenterCreds, error) {
+
+	cntr := req.Container
+
+	prs := h.Service.ProcessService()
+	process := prs.ProcessCreate(req.Pid, req.Uid, req.Gid)
+
+	uidMap := []idMapEntry{{host: cntr.UID(), size: cntr.UIDSize()}}
+	gidMap := []idMapEntry{{host: cntr.GID(), size: cntr.GIDSize()}}
+
+	if initProc := cntr.InitProc(); initProc != nil {
+		userns, err1 := process.UserNsInode()
+		cntrUserns, err2 := initProc.UserNsInode()
+		if err1 == nil && err2 == nil && userns != cntrUserns {
+			var err error
+
+			ios := h.Service.IOService()
+			if uidMap, err = readIdMap(ios, req.Pid, "uid_map"); err == nil {
+				gidMap, err = readIdMap(ios, req.Pid, "gid_map")
+			}
+			if err != nil {
+				logrus.Errorf("Could not obtain the id-mappings of pid %d: %v",
+					req.Pid, err)
+				return nil, fuse.IOerror{Code: syscall.EACCES}
+			}
+		}
+	}
+
+	var groups []uint32
+	for _, g := range process.SGid() {
+		groups = append(groups, nsId(gidMap, g))
+	}
+
+	return &domain.NSenterCreds{
+		Uid:    nsId(uidMap, req.Uid),
+		Gid:    nsId(gidMap, req.Gid),
+		Groups: groups,
+	}, nil
+}
+
+// Id-mapping of a user namespace, as listed in /proc/<pid>/uid_map and gid_map:
+// 'size' ids starting at 'host' (as seen by sysbox-fs) are mapped to the ones
+// starting at 'ns'.
+type idMapEntry struct {
+	ns   uint32
+	host uint32
+	size uint32
+}
+
+// readIdMap parses the passed id-map file (uid_map or gid_map) of the given
+// process.
+func readIdMap(
+	ios domain.IOServiceIface,
+	pid uint32,
+	file string) ([]idMapEntry, error) {
+
+	path := filepath.Join("/proc", strconv.FormatUint(uint64(pid), 10), file)
+
+	content, err := ios.NewIOnode(file, path, 0).ReadFile()
+	if err != nil {
+		return nil, err
+	}
+
+	var idMap []idMapEntry
+
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("unexpected %v entry %q", path, line)
+		}
+
+		var vals [3]uint64
+		for i, f := range fields {
+			if vals[i], err = strconv.ParseUint(f, 10, 32); err != nil {
+				return nil, fmt.Errorf("unexpected %v entry %q", path, line)
+			}
+		}
+
+		idMap = append(idMap, idMapEntry{
+			ns:   uint32(vals[0]),
+			host: uint32(vals[1]),
+			size: uint32(vals[2]),
+		})
+	}
+
+	return idMap, nil
+}
+
+// nsId maps the passed host id as per the given id-mapping, unmapped ids being
+// shown as the overflow id.
+func nsId(idMap []idMapEntry, hostId uint32) uint32 {
+
+	for _, m := range idMap {
+		if hostId >= m.host && uint64(hostId) < uint64(m.host)+uint64(m.size) {
+			return m.ns + hostId - m.host
+		}
+	}
+
+	return overflowId
+}
+
 // Auxiliary method to fetch the content of any given file within a container.
 func (h *ProcSysCommonHandler) fetchFile(
 	n domain.IOnodeIface,
-	process domain.ProcessIface) (string, error) {
+	process domain.ProcessIface,
+	req *domain.HandlerRequest) (string, error) {
+
+	creds, err := h.nsenterCreds(req)
+	if err != nil {
+		return "", err
+	}
 
 	// Create nsenterEvent to initiate interaction with container namespaces.
 	nss := h.Service.NSenterService()
@@ -398,7 +663,9 @@ func (h *ProcSysCommonHandler) fetchFile(
 			Type: domain.ReadFileRequest,
 			Payload: &domain.ReadFilePayload{
 				File: n.Path(),
+				Raw:  h.Raw,
 			},
+			Creds: creds,
 		},
 		nil,
 		false,
@@ -406,7 +673,7 @@ func (h *ProcSysCommonHandler) fetchFile(
 
 	// Launch nsenter-event to obtain file state within container
 	// namespaces.
-	err := nss.SendRequestEvent(event)
+	err = nss.SendRequestEvent(event)
 	if err != nil {
 		return "", err
 	}
@@ -422,11 +689,67 @@ func (h *ProcSysCommonHandler) fetchFile(
 	return info, nil
 }
 
+// WarmCache prefetches the given resources into the container's cache through a
+// single nsenter request, so that their first access doesn't pay for an nsenter
+// round-trip of its own. Resources missing within the container's namespaces,
+// as well as the ones cached in the meantime, are left untouched. The resources
+// are expected to be served by this handler.
+func (h *ProcSysCommonHandler) WarmCache(
+	cntr domain.ContainerIface,
+	paths []string) error {
+
+	files := make([]domain.ReadFilePayload, 0, len(paths))
+	for _, path := range paths {
+		files = append(files, domain.ReadFilePayload{
+			File: path,
+			Raw:  h.Raw,
+		})
+	}
+
+	nss := h.Service.NSenterService()
+	event := nss.NewEvent(
+		cntr.InitPid(),
+		&domain.AllNSsButMount,
+		&domain.NSenterMessage{
+			Type: domain.ReadFilesRequest,
+			Payload: &domain.ReadFilesPayload{
+				Files: files,
+			},
+		},
+		nil,
+		false,
+	)
+
+	if err := nss.SendRequestEvent(event); err != nil {
+		return err
+	}
+
+	responseMsg := nss.ReceiveResponseEvent(event)
+	if responseMsg.Type == domain.ErrorResponse {
+		return responseMsg.Payload.(error)
+	}
+
+	contents := responseMsg.Payload.(map[string]string)
+
+	cntr.Lock()
+	defer cntr.Unlock()
+
+	for path, data := range contents {
+		name := filepath.Base(path)
+		if _, ok := cntr.Data(path, name); !ok {
+			cntr.SetData(path, name, data)
+		}
+	}
+
+	return nil
+}
+
 // Auxiliary method to inject content into any given file within a container.
 func (h *ProcSysCommonHandler) pushFile(
 	n domain.IOnodeIface,
 	process domain.ProcessIface,
-	s string) error {
+	creds *domain.NSenterCreds,
+	s string) (int, error) {
 
 	// Create nsenterEvent to initiate interaction with container namespaces.
 	nss := h.Service.NSenterService()
@@ -439,6 +762,7 @@ func (h *ProcSysCommonHandler) pushFile(
 				File:    n.Path(),
 				Content: s,
 			},
+			Creds: creds,
 		},
 		nil,
 		false,
@@ -448,16 +772,21 @@ func (h *ProcSysCommonHandler) pushFile(
 	// namespaces.
 	err := nss.SendRequestEvent(event)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	// Obtain nsenter-event response.
 	responseMsg := nss.ReceiveResponseEvent(event)
 	if responseMsg.Type == domain.ErrorResponse {
-		return responseMsg.Payload.(error)
+		return 0, responseMsg.Payload.(error)
 	}
 
-	return nil
+	// Number of bytes written by the agent, if reported.
+	if written, ok := responseMsg.Payload.(int); ok && written >= 0 && written <= len(s) {
+		return written, nil
+	}
+
+	return len(s), nil
 }
 
 func (h *ProcSysCommonHandler) GetName() string {
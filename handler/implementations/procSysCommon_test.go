@@ -18,6 +18,7 @@ package implementations_test
 
 import (
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"reflect"
@@ -27,6 +28,7 @@ import (
 	"time"
 
 	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
 	"github.com/nestybox/sysbox-fs/handler/implementations"
 	"github.com/nestybox/sysbox-fs/mocks"
 	"github.com/nestybox/sysbox-fs/mount"
@@ -35,6 +37,7 @@ import (
 	"github.com/nestybox/sysbox-fs/state"
 	"github.com/nestybox/sysbox-fs/sysio"
 	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/mock"
 )
 
 // Sysbox-fs global services for all handler's testing consumption.
@@ -68,12 +71,23 @@ func TestMain(m *testing.M) {
 	// HandlerService's common mocking instructions.
 	hds.On("NSenterService").Return(nss)
 	hds.On("ProcessService").Return(prs)
+	hds.On("IOService").Return(ios)
+	hds.On("SingleInstance").Return(false)
+	hds.On("PushRetries").Return(5, 100*time.Microsecond)
+	hds.On("WritePending", mock.Anything, mock.Anything, mock.Anything).Return(false)
+	hds.On("CoalesceWrite", mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything).Return(false)
 	hds.On("DirHandlerEntries", "/proc/sys/net").Return(nil)
 
 	// Run test-suite.
 	m.Run()
 }
 
+// Credentials expected to be assumed by the nsenter agent on behalf of the
+// requests issued by these tests, whose (host) ids fall outside of the
+// containers' id range.
+var unmappedCreds = &domain.NSenterCreds{Uid: 65534, Gid: 65534}
+
 func TestProcSysCommonHandler_Lookup(t *testing.T) {
 	type fields struct {
 		Name      string
@@ -112,6 +126,7 @@ func TestProcSysCommonHandler_Lookup(t *testing.T) {
 				65535,
 				nil,
 				nil,
+				false,
 				nil),
 		},
 	}
@@ -152,6 +167,7 @@ func TestProcSysCommonHandler_Lookup(t *testing.T) {
 					ReqMsg: &domain.NSenterMessage{
 						Type:    domain.LookupRequest,
 						Payload: &domain.LookupPayload{a1.n.Path()},
+						Creds:   unmappedCreds,
 					},
 				}
 
@@ -209,6 +225,7 @@ func TestProcSysCommonHandler_Lookup(t *testing.T) {
 					ReqMsg: &domain.NSenterMessage{
 						Type:    domain.LookupRequest,
 						Payload: &domain.LookupPayload{a1.n.Path()},
+						Creds:   unmappedCreds,
 					},
 				}
 
@@ -317,6 +334,7 @@ func TestProcSysCommonHandler_Getattr(t *testing.T) {
 				65535,
 				nil,
 				nil,
+				false,
 				nil),
 		},
 	}
@@ -445,6 +463,7 @@ func TestProcSysCommonHandler_Open(t *testing.T) {
 				65535,
 				nil,
 				nil,
+				false,
 				nil),
 		},
 	}
@@ -457,6 +476,13 @@ func TestProcSysCommonHandler_Open(t *testing.T) {
 		},
 	}
 
+	// Invalid method arguments -- file-creation open flags.
+	var a3 = args{
+		n:   ios.NewIOnode("net", "/proc/sys/net", 0),
+		req: a1.req,
+	}
+	a3.n.SetOpenFlags(syscall.O_CREAT | syscall.O_WRONLY)
+
 	tests := []struct {
 		name       string
 		fields     fields
@@ -486,6 +512,7 @@ func TestProcSysCommonHandler_Open(t *testing.T) {
 							File:  a1.n.Path(),
 							Flags: strconv.Itoa(a1.n.OpenFlags()),
 							Mode:  strconv.Itoa(int(a1.n.OpenMode()))},
+						Creds: unmappedCreds,
 					},
 				}
 
@@ -543,6 +570,7 @@ func TestProcSysCommonHandler_Open(t *testing.T) {
 							File:  a1.n.Path(),
 							Flags: strconv.Itoa(a1.n.OpenFlags()),
 							Mode:  strconv.Itoa(int(a1.n.OpenMode()))},
+						Creds: unmappedCreds,
 					},
 				}
 
@@ -566,6 +594,18 @@ func TestProcSysCommonHandler_Open(t *testing.T) {
 				nss.On("ReceiveResponseEvent", nsenterEventReq).Return(nsenterEventResp.ResMsg)
 			},
 		},
+		{
+			//
+			// Test-case 4: Verify that open() requests carrying file-creation
+			// flags (O_CREAT) are rejected before reaching nsenter (EPERM).
+			//
+			name:       "4",
+			fields:     f1,
+			args:       a3,
+			wantErr:    true,
+			wantErrVal: fuse.IOerror{Code: syscall.EPERM},
+			prepare:    func() {},
+		},
 	}
 
 	//
@@ -655,6 +695,7 @@ func TestProcSysCommonHandler_Read(t *testing.T) {
 				65535,
 				nil,
 				nil,
+				false,
 				css),
 		},
 	}
@@ -702,6 +743,7 @@ func TestProcSysCommonHandler_Read(t *testing.T) {
 						Payload: &domain.ReadFilePayload{
 							File: a1.n.Path(),
 						},
+						Creds: unmappedCreds,
 					},
 				}
 
@@ -765,6 +807,7 @@ func TestProcSysCommonHandler_Read(t *testing.T) {
 						Payload: &domain.ReadFilePayload{
 							File: a1.n.Path(),
 						},
+						Creds: unmappedCreds,
 					},
 				}
 
@@ -867,6 +910,7 @@ func TestProcSysCommonHandler_Write(t *testing.T) {
 				65535,
 				nil,
 				nil,
+				false,
 				css),
 		},
 	}
@@ -915,6 +959,7 @@ func TestProcSysCommonHandler_Write(t *testing.T) {
 							File:    a1.n.Path(),
 							Content: "file content 0123456789",
 						},
+						Creds: unmappedCreds,
 					},
 				}
 
@@ -979,6 +1024,7 @@ func TestProcSysCommonHandler_Write(t *testing.T) {
 							File:    a1.n.Path(),
 							Content: "file content 0123456789",
 						},
+						Creds: unmappedCreds,
 					},
 				}
 
@@ -990,6 +1036,58 @@ func TestProcSysCommonHandler_Write(t *testing.T) {
 					},
 				}
 
+				nss.On(
+					"NewEvent",
+					a1.req.Pid,
+					&domain.AllNSsButMount,
+					nsenterEventReq.ReqMsg,
+					(*domain.NSenterMessage)(nil),
+					false).Return(nsenterEventReq)
+
+				nss.On("SendRequestEvent", nsenterEventReq).Return(nil)
+				nss.On("ReceiveResponseEvent", nsenterEventReq).Return(nsenterEventResp.ResMsg)
+			},
+		},
+		{
+			//
+			// Test-case 4: Short write reported by the nsenter agent. Short
+			// count expected.
+			//
+			name:       "4",
+			fields:     f1,
+			args:       a1,
+			want:       len(string("file content")),
+			wantErr:    false,
+			wantErrVal: nil,
+			prepare: func() {
+
+				// Setup dynamic state associated to tested container.
+				c1 := a1.req.Container
+				_ = c1.SetInitProc(c1.InitPid(), c1.UID(), c1.GID())
+				c1.InitProc().CreateNsInodes(123456)
+
+				// Expected nsenter request.
+				nsenterEventReq := &nsenter.NSenterEvent{
+					Pid:       a1.req.Pid,
+					Namespace: &domain.AllNSsButMount,
+					ReqMsg: &domain.NSenterMessage{
+						Type: domain.WriteFileRequest,
+						Payload: &domain.WriteFilePayload{
+							File:    a1.n.Path(),
+							Content: "file content 0123456789",
+						},
+						Creds: unmappedCreds,
+					},
+				}
+
+				// Expected nsenter response.
+				nsenterEventResp := &nsenter.NSenterEvent{
+					ResMsg: &domain.NSenterMessage{
+						Type:    domain.WriteFileResponse,
+						Payload: len(string("file content")),
+					},
+				}
+
 				nss.On(
 					"NewEvent",
 					a1.req.Pid,
@@ -1042,6 +1140,487 @@ func TestProcSysCommonHandler_Write(t *testing.T) {
 	}
 }
 
+func TestProcSysCommonHandler_OpenTruncWrite(t *testing.T) {
+
+	h := &implementations.ProcSysCommonHandler{
+		domain.HandlerBase{
+			Name:      "procSysCommon",
+			Path:      "procSysCommonHandler",
+			Enabled:   true,
+			Cacheable: false,
+			Service:   hds,
+		},
+	}
+
+	n := ios.NewIOnode("node_trunc", "/proc/sys/net/node_trunc", 0)
+	n.SetOpenFlags(syscall.O_WRONLY | syscall.O_TRUNC)
+
+	cntr := css.ContainerCreate("c1", uint32(1001), time.Time{}, 231072,
+		65535, 231072, 65535, nil, nil, false, nil)
+
+	// The open() dispatched to the agent must not truncate the resource.
+	openEvent := &nsenter.NSenterEvent{
+		Pid:       1001,
+		Namespace: &domain.AllNSsButMount,
+		ReqMsg: &domain.NSenterMessage{
+			Type: domain.OpenFileRequest,
+			Payload: &domain.OpenFilePayload{
+				File:  n.Path(),
+				Flags: strconv.Itoa(syscall.O_WRONLY),
+				Mode:  strconv.Itoa(int(n.OpenMode())),
+			},
+			Creds: unmappedCreds,
+		},
+	}
+	nss.On(
+		"NewEvent",
+		uint32(1001),
+		&domain.AllNSsButMount,
+		openEvent.ReqMsg,
+		(*domain.NSenterMessage)(nil),
+		false).Return(openEvent)
+	nss.On("SendRequestEvent", openEvent).Return(nil)
+	nss.On("ReceiveResponseEvent", openEvent).Return(
+		&domain.NSenterMessage{Type: domain.OpenFileResponse})
+
+	// Content must be replaced through a single write request.
+	writeEvent := &nsenter.NSenterEvent{
+		Pid:       1001,
+		Namespace: &domain.AllNSsButMount,
+		ReqMsg: &domain.NSenterMessage{
+			Type: domain.WriteFileRequest,
+			Payload: &domain.WriteFilePayload{
+				File:    n.Path(),
+				Content: "1",
+			},
+			Creds: unmappedCreds,
+		},
+	}
+	nss.On(
+		"NewEvent",
+		uint32(1001),
+		&domain.AllNSsButMount,
+		writeEvent.ReqMsg,
+		(*domain.NSenterMessage)(nil),
+		false).Return(writeEvent)
+	nss.On("SendRequestEvent", writeEvent).Return(nil)
+	nss.On("ReceiveResponseEvent", writeEvent).Return(
+		&domain.NSenterMessage{Type: domain.WriteFileResponse, Payload: 1})
+
+	req := &domain.HandlerRequest{Pid: 1001, Container: cntr}
+	if err := h.Open(n, req); err != nil {
+		t.Fatalf("ProcSysCommonHandler.Open() error = %v", err)
+	}
+
+	req.Data = []byte("1\n")
+	got, err := h.Write(n, req)
+	if err != nil {
+		t.Fatalf("ProcSysCommonHandler.Write() error = %v", err)
+	}
+	if got != len(req.Data) {
+		t.Errorf("ProcSysCommonHandler.Write() = %v, want %v", got, len(req.Data))
+	}
+
+	nss.AssertCalled(t, "SendRequestEvent", openEvent)
+	nss.AssertCalled(t, "SendRequestEvent", writeEvent)
+}
+
+func TestProcSysCommonHandler_WarmCache(t *testing.T) {
+
+	// Dedicated nsenter mock to account for the round-trips being issued.
+	nss := &mocks.NSenterServiceIface{}
+	hs := &mocks.HandlerServiceIface{}
+	hs.On("NSenterService").Return(nss)
+	hs.On("ProcessService").Return(prs)
+
+	h := &implementations.ProcSysCommonHandler{
+		domain.HandlerBase{
+			Name:      "procSysCommon",
+			Path:      "procSysCommonHandler",
+			Enabled:   true,
+			Cacheable: true,
+			Service:   hs,
+		},
+	}
+
+	cntr := css.ContainerCreate("c1", uint32(1001), time.Time{}, 231072,
+		65535, 231072, 65535, nil, nil, false, css)
+	_ = cntr.SetInitProc(cntr.InitPid(), cntr.UID(), cntr.GID())
+	cntr.InitProc().CreateNsInodes(123456)
+
+	// Single (batched) nsenter request. Missing resources are left out of the
+	// response.
+	nsenterEventReq := &nsenter.NSenterEvent{
+		Pid:       cntr.InitPid(),
+		Namespace: &domain.AllNSsButMount,
+		ReqMsg: &domain.NSenterMessage{
+			Type: domain.ReadFilesRequest,
+			Payload: &domain.ReadFilesPayload{
+				Files: []domain.ReadFilePayload{
+					{File: "/proc/sys/kernel/ostype"},
+					{File: "/proc/sys/kernel/missing"},
+				},
+			},
+		},
+	}
+	nss.On(
+		"NewEvent",
+		cntr.InitPid(),
+		&domain.AllNSsButMount,
+		nsenterEventReq.ReqMsg,
+		(*domain.NSenterMessage)(nil),
+		false).Return(nsenterEventReq)
+	nss.On("SendRequestEvent", nsenterEventReq).Return(nil)
+	nss.On("ReceiveResponseEvent", nsenterEventReq).Return(
+		&domain.NSenterMessage{
+			Type:    domain.ReadFilesResponse,
+			Payload: map[string]string{"/proc/sys/kernel/ostype": "Linux"},
+		})
+
+	err := h.WarmCache(cntr, []string{"/proc/sys/kernel/ostype", "/proc/sys/kernel/missing"})
+	if err != nil {
+		t.Fatalf("ProcSysCommonHandler.WarmCache() error = %v", err)
+	}
+
+	if _, ok := cntr.Data("/proc/sys/kernel/missing", "missing"); ok {
+		t.Errorf("ProcSysCommonHandler.WarmCache() cached a missing resource")
+	}
+
+	// Warmed resource must be served from the cache.
+	buf := make([]byte, 16)
+	rn, err := h.Read(
+		ios.NewIOnode("ostype", "/proc/sys/kernel/ostype", 0),
+		&domain.HandlerRequest{
+			Pid:       cntr.InitPid(),
+			Data:      buf,
+			Container: cntr,
+		})
+	if err != nil {
+		t.Fatalf("ProcSysCommonHandler.Read() error = %v", err)
+	}
+	if got := string(buf[:rn]); got != "Linux\n" {
+		t.Errorf("ProcSysCommonHandler.Read() = %q, want %q", got, "Linux\n")
+	}
+
+	nss.AssertNumberOfCalls(t, "NewEvent", 1)
+}
+
+func TestProcSysCommonHandler_IsCacheable(t *testing.T) {
+
+	cntr := css.ContainerCreate("c1", uint32(1001), time.Time{}, 231072,
+		65535, 231072, 65535, nil, nil, false, css)
+	_ = cntr.SetInitProc(cntr.InitPid(), cntr.UID(), cntr.GID())
+	cntr.InitProc().CreateNsInodes(123456)
+
+	// Process living in an inner (unshared) set of namespaces.
+	prs.ProcessCreate(6201, 0, 0).CreateNsInodes(654321)
+
+	tests := []struct {
+		name      string
+		cacheable bool
+		req       *domain.HandlerRequest
+		want      bool
+	}{
+		{
+			//
+			// Test-case 1: Cacheable handler, reader in the container's init
+			// namespaces. Caching expected.
+			//
+			name:      "1",
+			cacheable: true,
+			req:       &domain.HandlerRequest{Pid: 1001, Container: cntr},
+			want:      true,
+		},
+		{
+			//
+			// Test-case 2: Cacheable handler, reader in inner namespaces. No
+			// caching expected.
+			//
+			name:      "2",
+			cacheable: true,
+			req:       &domain.HandlerRequest{Pid: 6201, Container: cntr},
+			want:      false,
+		},
+		{
+			//
+			// Test-case 3: Non-cacheable handler. No caching expected.
+			//
+			name:      "3",
+			cacheable: false,
+			req:       &domain.HandlerRequest{Pid: 1001, Container: cntr},
+			want:      false,
+		},
+		{
+			//
+			// Test-case 4: Missing sys-container attribute. No caching expected.
+			//
+			name:      "4",
+			cacheable: true,
+			req:       &domain.HandlerRequest{Pid: 1001},
+			want:      false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &implementations.ProcSysCommonHandler{
+				domain.HandlerBase{
+					Name:      "procSysCommon",
+					Path:      "procSysCommonHandler",
+					Enabled:   true,
+					Cacheable: tt.cacheable,
+					Service:   hds,
+				},
+			}
+
+			if got := h.IsCacheable(tt.req); got != tt.want {
+				t.Errorf("ProcSysCommonHandler.IsCacheable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcSysCommonHandler_ReadInnerNs(t *testing.T) {
+
+	// Dedicated nsenter mock to account for the round-trips being issued.
+	nss := &mocks.NSenterServiceIface{}
+	hs := &mocks.HandlerServiceIface{}
+	hs.On("NSenterService").Return(nss)
+	hs.On("ProcessService").Return(prs)
+	hs.On("IOService").Return(ios)
+
+	h := &implementations.ProcSysCommonHandler{
+		domain.HandlerBase{
+			Name:      "procSysCommon",
+			Path:      "procSysCommonHandler",
+			Enabled:   true,
+			Cacheable: true,
+			Service:   hs,
+		},
+	}
+
+	cntr := css.ContainerCreate("c1", uint32(1001), time.Time{}, 231072,
+		65535, 231072, 65535, nil, nil, false, css)
+	_ = cntr.SetInitProc(cntr.InitPid(), cntr.UID(), cntr.GID())
+	cntr.InitProc().CreateNsInodes(123456)
+
+	// Processes living in an inner (unshared) set of namespaces, the first
+	// one's user-ns mapping container uid / gid 1000 (host 232072) to root.
+	prs.ProcessCreate(6202, 0, 0).CreateNsInodes(654321)
+	prs.ProcessCreate(6203, 0, 0).CreateNsInodes(765432)
+	for _, file := range []string{"uid_map", "gid_map"} {
+		idMap := ios.NewIOnode(file, "/proc/6202/"+file, 0)
+		if err := idMap.WriteFile([]byte("         0     232072          1\n")); err != nil {
+			t.Fatalf("Could not create %v: %v", idMap.Path(), err)
+		}
+	}
+
+	n := ios.NewIOnode("hostname", "/proc/sys/kernel/hostname", 0)
+
+	// Requests of the inner user-ns are carried out with the caller's ids as
+	// mapped into that user-ns.
+	innerCreds := &domain.NSenterCreds{Uid: 0, Gid: 0}
+	for pid, creds := range map[uint32]*domain.NSenterCreds{1001: unmappedCreds, 6202: innerCreds} {
+		nsenterEventReq := &nsenter.NSenterEvent{
+			Pid:       pid,
+			Namespace: &domain.AllNSsButMount,
+			ReqMsg: &domain.NSenterMessage{
+				Type:    domain.ReadFileRequest,
+				Payload: &domain.ReadFilePayload{File: n.Path()},
+				Creds:   creds,
+			},
+		}
+		nss.On(
+			"NewEvent",
+			pid,
+			&domain.AllNSsButMount,
+			nsenterEventReq.ReqMsg,
+			(*domain.NSenterMessage)(nil),
+			false).Return(nsenterEventReq)
+		nss.On("SendRequestEvent", nsenterEventReq).Return(nil)
+		nss.On("ReceiveResponseEvent", nsenterEventReq).Return(
+			&domain.NSenterMessage{
+				Type:    domain.ReadFileResponse,
+				Payload: fmt.Sprintf("host-%d", pid),
+			})
+	}
+
+	read := func(pid uint32) string {
+		buf := make([]byte, 16)
+		rn, err := h.Read(n, &domain.HandlerRequest{
+			Pid:       pid,
+			Uid:       map[uint32]uint32{1001: 0, 6202: 232072}[pid],
+			Gid:       map[uint32]uint32{1001: 0, 6202: 232072}[pid],
+			Data:      buf,
+			Container: cntr,
+		})
+		if err != nil {
+			t.Fatalf("ProcSysCommonHandler.Read() error = %v", err)
+		}
+		return string(buf[:rn])
+	}
+
+	// Readers in the container's init namespaces are served from the cache
+	// after the first read; inner-namespace readers always reach the nsenter
+	// agent, and never see (nor pollute) the cached content.
+	for i := 0; i < 2; i++ {
+		if got := read(1001); got != "host-1001\n" {
+			t.Errorf("ProcSysCommonHandler.Read() = %q, want %q", got, "host-1001\n")
+		}
+		if got := read(6202); got != "host-6202\n" {
+			t.Errorf("ProcSysCommonHandler.Read() = %q, want %q", got, "host-6202\n")
+		}
+	}
+
+	nss.AssertNumberOfCalls(t, "NewEvent", 3)
+
+	// Inner user-ns whose id-mappings can't be obtained: access is denied
+	// without reaching the nsenter agent.
+	_, err := h.Read(n, &domain.HandlerRequest{
+		Pid:       6203,
+		Data:      make([]byte, 16),
+		Container: cntr,
+	})
+	if err != (fuse.IOerror{Code: syscall.EACCES}) {
+		t.Errorf("ProcSysCommonHandler.Read() error = %v, want EACCES", err)
+	}
+
+	nss.AssertNumberOfCalls(t, "NewEvent", 3)
+}
+
+func TestProcSysCommonHandler_ReadRaw(t *testing.T) {
+
+	nss := &mocks.NSenterServiceIface{}
+	hs := &mocks.HandlerServiceIface{}
+	hs.On("NSenterService").Return(nss)
+	hs.On("ProcessService").Return(prs)
+	hs.On("IOService").Return(ios)
+
+	h := &implementations.ProcSysCommonHandler{
+		domain.HandlerBase{
+			Name:      "devCdromInfo",
+			Path:      "/proc/sys/dev/cdrom/info",
+			Enabled:   true,
+			Cacheable: true,
+			Raw:       true,
+			Service:   hs,
+		},
+	}
+
+	cntr := css.ContainerCreate("c1", uint32(1001), time.Time{}, 231072,
+		65535, 231072, 65535, nil, nil, false, css)
+	_ = cntr.SetInitProc(cntr.InitPid(), cntr.UID(), cntr.GID())
+	cntr.InitProc().CreateNsInodes(123456)
+
+	n := ios.NewIOnode("info", "/proc/sys/dev/cdrom/info", 0)
+
+	// Multi-line content must be requested and served verbatim.
+	const content = "CD-ROM information, Id: cdrom.c 3.20 2003/12/17\n\ndrive name:\n"
+
+	nsenterEventReq := &nsenter.NSenterEvent{
+		Pid:       1001,
+		Namespace: &domain.AllNSsButMount,
+		ReqMsg: &domain.NSenterMessage{
+			Type:    domain.ReadFileRequest,
+			Payload: &domain.ReadFilePayload{File: n.Path(), Raw: true},
+			Creds:   unmappedCreds,
+		},
+	}
+	nss.On(
+		"NewEvent",
+		uint32(1001),
+		&domain.AllNSsButMount,
+		nsenterEventReq.ReqMsg,
+		(*domain.NSenterMessage)(nil),
+		false).Return(nsenterEventReq)
+	nss.On("SendRequestEvent", nsenterEventReq).Return(nil)
+	nss.On("ReceiveResponseEvent", nsenterEventReq).Return(
+		&domain.NSenterMessage{
+			Type:    domain.ReadFileResponse,
+			Payload: content,
+		})
+
+	buf := make([]byte, 128)
+	rn, err := h.Read(n, &domain.HandlerRequest{
+		Pid:       1001,
+		Data:      buf,
+		Container: cntr,
+	})
+	if err != nil {
+		t.Fatalf("ProcSysCommonHandler.Read() error = %v", err)
+	}
+	if got := string(buf[:rn]); got != content {
+		t.Errorf("ProcSysCommonHandler.Read() = %q, want %q", got, content)
+	}
+}
+
+func TestProcSysCommonHandler_NsenterCreds(t *testing.T) {
+
+	// Dedicated nsenter mock to verify the credentials of the requests.
+	nss := &mocks.NSenterServiceIface{}
+	hs := &mocks.HandlerServiceIface{}
+	hs.On("NSenterService").Return(nss)
+	hs.On("ProcessService").Return(prs)
+
+	h := &implementations.ProcSysCommonHandler{
+		domain.HandlerBase{
+			Name:    "procSysCommon",
+			Path:    "procSysCommonHandler",
+			Enabled: true,
+			Service: hs,
+		},
+	}
+
+	cntr := css.ContainerCreate("c1", uint32(1001), time.Time{}, 231072,
+		65535, 231072, 65535, nil, nil, false, css)
+	_ = cntr.SetInitProc(cntr.InitPid(), cntr.UID(), cntr.GID())
+	cntr.InitProc().CreateNsInodes(123456)
+
+	n := ios.NewIOnode("somaxconn", "/proc/sys/net/core/somaxconn", 0)
+
+	// Host ids of the caller are expected to be mapped into the container's
+	// user-ns.
+	nsenterEventReq := &nsenter.NSenterEvent{
+		Pid:       1001,
+		Namespace: &domain.AllNSsButMount,
+		ReqMsg: &domain.NSenterMessage{
+			Type:    domain.ReadFileRequest,
+			Payload: &domain.ReadFilePayload{File: n.Path()},
+			Creds:   &domain.NSenterCreds{Uid: 1000, Gid: 5},
+		},
+	}
+	nss.On(
+		"NewEvent",
+		uint32(1001),
+		&domain.AllNSsButMount,
+		nsenterEventReq.ReqMsg,
+		(*domain.NSenterMessage)(nil),
+		false).Return(nsenterEventReq)
+	nss.On("SendRequestEvent", nsenterEventReq).Return(nil)
+	nss.On("ReceiveResponseEvent", nsenterEventReq).Return(
+		&domain.NSenterMessage{
+			Type:    domain.ReadFileResponse,
+			Payload: "4096",
+		})
+
+	buf := make([]byte, 16)
+	rn, err := h.Read(n, &domain.HandlerRequest{
+		Pid:       1001,
+		Uid:       232072,
+		Gid:       231077,
+		Data:      buf,
+		Container: cntr,
+	})
+	if err != nil {
+		t.Fatalf("ProcSysCommonHandler.Read() error = %v", err)
+	}
+	if got := string(buf[:rn]); got != "4096\n" {
+		t.Errorf("ProcSysCommonHandler.Read() = %q, want %q", got, "4096\n")
+	}
+
+	nss.AssertNumberOfCalls(t, "NewEvent", 1)
+}
+
 func TestProcSysCommonHandler_ReadDirAll(t *testing.T) {
 	type fields struct {
 		Name      string
@@ -1080,6 +1659,7 @@ func TestProcSysCommonHandler_ReadDirAll(t *testing.T) {
 				65535,
 				nil,
 				nil,
+				false,
 				css),
 		},
 	}
@@ -1137,6 +1717,7 @@ func TestProcSysCommonHandler_ReadDirAll(t *testing.T) {
 						Payload: &domain.ReadDirPayload{
 							Dir: a1.n.Path(),
 						},
+						Creds: unmappedCreds,
 					},
 				}
 
@@ -1207,6 +1788,7 @@ func TestProcSysCommonHandler_ReadDirAll(t *testing.T) {
 						Payload: &domain.ReadDirPayload{
 							Dir: a1.n.Path(),
 						},
+						Creds: unmappedCreds,
 					},
 				}
 
@@ -1559,3 +2141,65 @@ func TestProcSysCommonHandler_SetService(t *testing.T) {
 		})
 	}
 }
+
+func TestProcSysCommonHandler_SysctlReadOnly(t *testing.T) {
+
+	// Dedicated nsenter mock to verify that no writes reach the agent.
+	nss := &mocks.NSenterServiceIface{}
+	hs := &mocks.HandlerServiceIface{}
+	hs.On("NSenterService").Return(nss)
+	hs.On("ProcessService").Return(prs)
+
+	base := domain.HandlerBase{
+		Name:    "procSysCommon",
+		Path:    "procSysCommonHandler",
+		Enabled: true,
+		Service: hs,
+	}
+
+	cntr := css.ContainerCreate("c1", uint32(1001), time.Time{}, 231072,
+		65535, 231072, 65535, nil, nil, false, css)
+	_ = cntr.SetInitProc(cntr.InitPid(), cntr.UID(), cntr.GID())
+	cntr.InitProc().CreateNsInodes(123456)
+	cntr.SetSysctlReadOnly(true)
+
+	n := ios.NewIOnode("somaxconn", "/proc/sys/net/core/somaxconn", 0)
+
+	nsenterEventReq := &nsenter.NSenterEvent{
+		Pid:       1001,
+		Namespace: &domain.AllNSsButMount,
+		ReqMsg: &domain.NSenterMessage{
+			Type:    domain.ReadFileRequest,
+			Payload: &domain.ReadFilePayload{File: n.Path()},
+			Creds:   unmappedCreds,
+		},
+	}
+	nss.On(
+		"NewEvent",
+		uint32(1001),
+		&domain.AllNSsButMount,
+		nsenterEventReq.ReqMsg,
+		(*domain.NSenterMessage)(nil),
+		false).Return(nsenterEventReq)
+	nss.On("SendRequestEvent", nsenterEventReq).Return(nil)
+	nss.On("ReceiveResponseEvent", nsenterEventReq).Return(
+		&domain.NSenterMessage{
+			Type:    domain.ReadFileResponse,
+			Payload: "4096",
+		})
+
+	// Reads are still served.
+	h := &implementations.ProcSysCommonHandler{base}
+	buf := make([]byte, 16)
+	rn, err := h.Read(n, &domain.HandlerRequest{Pid: 1001, Data: buf, Container: cntr})
+	if err != nil {
+		t.Fatalf("ProcSysCommonHandler.Read() error = %v", err)
+	}
+	if got := string(buf[:rn]); got != "4096\n" {
+		t.Errorf("ProcSysCommonHandler.Read() = %q, want %q", got, "4096\n")
+	}
+
+	// Writes are rejected ahead of the handlers (fuse layer and SysctlWrite()),
+	// so no other nsenter round-trip is expected.
+	nss.AssertNumberOfCalls(t, "NewEvent", 1)
+}
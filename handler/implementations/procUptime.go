@@ -20,91 +20,31 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"os"
-	"syscall"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/sirupsen/logrus"
-
 	"github.com/nestybox/sysbox-fs/domain"
-	"github.com/nestybox/sysbox-fs/fuse"
 )
 
 //
-// /proc/uptime Handler
+// /proc/uptime compute function (to be utilized through a ComputedHandler).
 //
-type ProcUptimeHandler struct {
-	domain.HandlerBase
-}
-
-func (h *ProcUptimeHandler) Lookup(
-	n domain.IOnodeIface,
-	req *domain.HandlerRequest) (os.FileInfo, error) {
-
-	logrus.Debugf("Executing Lookup() method on %v handler", h.Name)
-
-	return n.Stat()
-}
-
-func (h *ProcUptimeHandler) Getattr(
-	n domain.IOnodeIface,
-	req *domain.HandlerRequest) (*syscall.Stat_t, error) {
-
-	logrus.Debugf("Executing Getattr() method on %v handler", h.Name)
-
-	return nil, nil
-}
-
-func (h *ProcUptimeHandler) Open(
-	n domain.IOnodeIface,
-	req *domain.HandlerRequest) error {
-
-	logrus.Debugf("Executing %v Open() method", h.Name)
-
-	flags := n.OpenFlags()
-	if flags != syscall.O_RDONLY {
-		return fuse.IOerror{Code: syscall.EACCES}
-	}
-
-	if err := n.Open(); err != nil {
-		logrus.Debugf("Error opening file %v", h.Path)
-		return fuse.IOerror{Code: syscall.EIO}
-	}
-
-	return nil
-}
-
-func (h *ProcUptimeHandler) Close(n domain.IOnodeIface) error {
-
-	logrus.Debugf("Executing Close() method on %v handler", h.Name)
-
-	if err := n.Close(); err != nil {
-		logrus.Debugf("Error closing file %v", h.Path)
-		return fuse.IOerror{Code: syscall.EIO}
-	}
-
-	return nil
-}
-
-func (h *ProcUptimeHandler) Read(
+// The first column holds the container's uptime (time elapsed since its
+// creation) or, if the host-uptime option is enabled, the host's one. The
+// second column (idle time) is derived from the host's idle time, which is
+// accumulated across all the host cpus: it's scaled down to the cpus the
+// container is allowed to run on, as well as to the container's lifetime when
+// reporting the container's uptime.
+//
+func ProcUptime(
+	hs domain.HandlerServiceIface,
 	n domain.IOnodeIface,
-	req *domain.HandlerRequest) (int, error) {
-
-	logrus.Debugf("Executing %v Read() method", h.Name)
-
-	// We are dealing with a single integer element being read, so we can save
-	// some cycles by returning right away if offset is any higher than zero.
-	if req.Offset > 0 {
-		return 0, io.EOF
-	}
+	req *domain.HandlerRequest) (string, error) {
 
 	cntr := req.Container
-
-	// Ensure operation is generated from within a registered sys container.
 	if cntr == nil {
-		logrus.Errorf("Could not find the container originating this request (pid %v)",
-			req.Pid)
-		return 0, errors.New("Container not found")
+		return "", errors.New("Container not found")
 	}
 
 	//
@@ -112,68 +52,79 @@ func (h *ProcUptimeHandler) Read(
 	// /proc/uptime, the embedding container has been fully initialized,
 	// so cs.ctime is already holding a valid value.
 	//
-	data := cntr.Ctime()
-
-	// Calculate container's uptime, convert it to float to obtain required
-	// precission (as per host FS), and finally format it into string for
-	// storage purposes.
-	//
-	// TODO: Notice that we are dumping the same values into the two columns
-	// expected in /proc/uptime. The value utilized for the first column is
-	// an accurate one (uptime seconds), however, the second one is just
-	// an approximation.
-	//
-	uptimeDur := time.Now().Sub(data) / time.Nanosecond
-	var uptime float64 = uptimeDur.Seconds()
-	uptimeStr := fmt.Sprintf("%.2f", uptime)
+	uptime := time.Since(cntr.Ctime()).Seconds()
 
-	result := []byte(uptimeStr + " " + uptimeStr + "\n")
-
-	return copyResultBuffer(req.Data, result)
-}
-
-func (h *ProcUptimeHandler) Write(
-	n domain.IOnodeIface,
-	req *domain.HandlerRequest) (int, error) {
+	hostUptime, hostIdle, err := hostUptimeParse(n)
+	if err != nil {
+		// Without the host figures, the best we can do is to report the
+		// container's uptime in both columns.
+		return fmt.Sprintf("%.2f %.2f\n", uptime, uptime), nil
+	}
 
-	return 0, nil
-}
+	// The container can't have been running for longer than the host.
+	if uptime > hostUptime {
+		uptime = hostUptime
+	}
 
-func (h *ProcUptimeHandler) ReadDirAll(
-	n domain.IOnodeIface,
-	req *domain.HandlerRequest) ([]os.FileInfo, error) {
+	idle := hostIdle * uptimeCpuRatio(hs.IOService(), cntr)
 
-	return nil, nil
-}
-
-func (h *ProcUptimeHandler) GetName() string {
+	if hs.HostUptime() {
+		uptime = hostUptime
+	} else if hostUptime > 0 {
+		idle *= uptime / hostUptime
+	}
 
-	return h.Name
+	return fmt.Sprintf("%.2f %.2f\n", uptime, idle), nil
 }
 
-func (h *ProcUptimeHandler) GetPath() string {
+// hostUptimeParse returns the uptime and idle time (in seconds) of the host as
+// reported by its /proc/uptime file.
+func hostUptimeParse(n domain.IOnodeIface) (float64, float64, error) {
 
-	return h.Path
-}
+	line, err := n.ReadLine()
+	if err != nil && err != io.EOF {
+		return 0, 0, err
+	}
 
-func (h *ProcUptimeHandler) GetEnabled() bool {
+	fields := strings.Fields(line)
+	if len(fields) != 2 {
+		return 0, 0, fmt.Errorf("unexpected uptime format %q", line)
+	}
 
-	return h.Enabled
-}
+	uptime, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	idle, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return 0, 0, err
+	}
 
-func (h *ProcUptimeHandler) GetType() domain.HandlerType {
-	return h.Type
+	return uptime, idle, nil
 }
 
-func (h *ProcUptimeHandler) GetService() domain.HandlerServiceIface {
-	return h.Service
-}
+// uptimeCpuRatio returns the fraction of the host cpus the container is allowed
+// to run on. If either set of cpus can't be determined the host's idle time is
+// reported unscaled.
+func uptimeCpuRatio(ios domain.IOServiceIface, cntr domain.ContainerIface) float64 {
 
-func (h *ProcUptimeHandler) SetEnabled(val bool) {
-	h.Enabled = val
-}
+	hostList, err := ios.NewIOnode("", "/sys/devices/system/cpu/online", 0).ReadLine()
+	if err != nil && err != io.EOF {
+		return 1
+	}
+	hostCpus, err := cpuListParse(hostList)
+	if err != nil {
+		return 1
+	}
 
-func (h *ProcUptimeHandler) SetService(hs domain.HandlerServiceIface) {
+	cntrList, err := cntrCpuList(ios, cntr)
+	if err != nil {
+		return 1
+	}
+	cntrCpus, err := cpuListParse(cntrList)
+	if err != nil || len(cntrCpus) >= len(hostCpus) {
+		return 1
+	}
 
-	h.Service = hs
+	return float64(len(cntrCpus)) / float64(len(hostCpus))
 }
@@ -0,0 +1,282 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+)
+
+//
+// /sys/devices/system/cpu/online handler
+//
+// Displays the cpus the sys container is allowed to run on (as per its cpuset
+// cgroup) instead of all the host ones, so that applications sizing their
+// thread pools out of this file behave as expected. The host FS content is
+// displayed whenever the container's cpuset can't be obtained. Writes are
+// rejected (EPERM) as the kernel does.
+//
+type SysDevicesSystemCpuOnlineHandler struct {
+	domain.HandlerBase
+}
+
+// Mount point of the host's cgroup hierarchies.
+const cgroupMountPoint = "/sys/fs/cgroup"
+
+func (h *SysDevicesSystemCpuOnlineHandler) Lookup(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (os.FileInfo, error) {
+
+	logrus.Debugf("Executing Lookup() method on %v handler", h.Name)
+
+	return n.Stat()
+}
+
+func (h *SysDevicesSystemCpuOnlineHandler) Getattr(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (*syscall.Stat_t, error) {
+
+	logrus.Debugf("Executing Getattr() method on %v handler", h.Name)
+
+	return nil, nil
+}
+
+func (h *SysDevicesSystemCpuOnlineHandler) Open(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) error {
+
+	logrus.Debugf("Executing %v Open() method\n", h.Name)
+
+	flags := n.OpenFlags()
+	if flags != syscall.O_RDONLY {
+		return fuse.IOerror{Code: syscall.EPERM}
+	}
+
+	if err := n.Open(); err != nil {
+		logrus.Debugf("Error opening file %v", h.Path)
+		return fuse.IOerror{Code: syscall.EIO}
+	}
+
+	return nil
+}
+
+func (h *SysDevicesSystemCpuOnlineHandler) Close(n domain.IOnodeIface) error {
+
+	logrus.Debugf("Executing Close() method on %v handler", h.Name)
+
+	if err := n.Close(); err != nil {
+		logrus.Debugf("Error closing file %v", h.Path)
+		return fuse.IOerror{Code: syscall.EIO}
+	}
+
+	return nil
+}
+
+func (h *SysDevicesSystemCpuOnlineHandler) Read(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Read() method", h.Name)
+
+	// We are dealing with a single-line element being read, so we can save
+	// some cycles by returning right away if offset is any higher than zero.
+	if req.Offset > 0 {
+		return 0, io.EOF
+	}
+
+	cntr := req.Container
+
+	// Ensure operation is generated from within a registered sys container.
+	if cntr == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return 0, errors.New("Container not found")
+	}
+
+	data, err := h.cntrCpus(cntr)
+	if err != nil {
+		logrus.Debugf("Could not obtain cpuset of container %v (%v): displaying host cpus",
+			cntr.ID(), err)
+
+		data, err = n.ReadLine()
+		if err != nil && err != io.EOF {
+			logrus.Errorf("Could not read from file %v", h.Path)
+			return 0, fuse.IOerror{Code: syscall.EIO}
+		}
+	}
+
+	data += "\n"
+
+	return copyResultBuffer(req.Data, []byte(data))
+}
+
+func (h *SysDevicesSystemCpuOnlineHandler) Write(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	return 0, fuse.IOerror{Code: syscall.EPERM}
+}
+
+func (h *SysDevicesSystemCpuOnlineHandler) ReadDirAll(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) ([]os.FileInfo, error) {
+
+	return nil, nil
+}
+
+// cntrCpus returns the list of cpus of the container's cpuset cgroup in the
+// kernel's canonical form.
+func (h *SysDevicesSystemCpuOnlineHandler) cntrCpus(cntr domain.ContainerIface) (string, error) {
+
+	cpus, err := cntrCpuList(h.Service.IOService(), cntr)
+	if err != nil {
+		return "", err
+	}
+
+	return cpuListFormat(cpus)
+}
+
+// cntrCpuList returns the (raw) list of cpus of the container's cpuset cgroup,
+// either from the v1 cpuset hierarchy or the unified (v2) one.
+func cntrCpuList(ios domain.IOServiceIface, cntr domain.ContainerIface) (string, error) {
+
+	var file string
+
+	if path, err := cntrCgroupPath(ios, cntr, "cpuset"); err == nil {
+		file = filepath.Join(cgroupMountPoint, "cpuset", path, "cpuset.cpus")
+	} else if path, err := cntrCgroupPath(ios, cntr, ""); err == nil {
+		file = filepath.Join(cgroupMountPoint, path, "cpuset.cpus.effective")
+	} else {
+		return "", err
+	}
+
+	cpus, err := ios.NewIOnode("", file, 0).ReadLine()
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+
+	return cpus, nil
+}
+
+// cpuListParse returns the sorted set of cpus within the passed cpu list (e.g.
+// "3,0-1,2").
+func cpuListParse(list string) ([]int, error) {
+
+	list = strings.TrimSpace(list)
+	if list == "" {
+		return nil, errors.New("empty cpu list")
+	}
+
+	set := make(map[int]bool)
+
+	for _, elem := range strings.Split(list, ",") {
+		bounds := strings.SplitN(elem, "-", 2)
+
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, err
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, err = strconv.Atoi(bounds[1]); err != nil {
+				return nil, err
+			}
+		}
+		if first < 0 || last < first {
+			return nil, fmt.Errorf("invalid cpu range %q", elem)
+		}
+
+		for cpu := first; cpu <= last; cpu++ {
+			set[cpu] = true
+		}
+	}
+
+	cpus := make([]int, 0, len(set))
+	for cpu := range set {
+		cpus = append(cpus, cpu)
+	}
+	sort.Ints(cpus)
+
+	return cpus, nil
+}
+
+// cpuListFormat returns the passed cpu list (e.g. "3,0-1,2") in the kernel's
+// canonical form (e.g. "0-3").
+func cpuListFormat(list string) (string, error) {
+
+	cpus, err := cpuListParse(list)
+	if err != nil {
+		return "", err
+	}
+
+	var ranges []string
+
+	for i := 0; i < len(cpus); {
+		j := i
+		for j+1 < len(cpus) && cpus[j+1] == cpus[j]+1 {
+			j++
+		}
+		if i == j {
+			ranges = append(ranges, strconv.Itoa(cpus[i]))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", cpus[i], cpus[j]))
+		}
+		i = j + 1
+	}
+
+	return strings.Join(ranges, ","), nil
+}
+
+func (h *SysDevicesSystemCpuOnlineHandler) GetName() string {
+	return h.Name
+}
+
+func (h *SysDevicesSystemCpuOnlineHandler) GetPath() string {
+	return h.Path
+}
+
+func (h *SysDevicesSystemCpuOnlineHandler) GetEnabled() bool {
+	return h.Enabled
+}
+
+func (h *SysDevicesSystemCpuOnlineHandler) GetType() domain.HandlerType {
+	return h.Type
+}
+
+func (h *SysDevicesSystemCpuOnlineHandler) GetService() domain.HandlerServiceIface {
+	return h.Service
+}
+
+func (h *SysDevicesSystemCpuOnlineHandler) SetEnabled(val bool) {
+	h.Enabled = val
+}
+
+func (h *SysDevicesSystemCpuOnlineHandler) SetService(hs domain.HandlerServiceIface) {
+	h.Service = hs
+}
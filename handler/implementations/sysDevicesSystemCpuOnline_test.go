@@ -0,0 +1,121 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/handler/implementations"
+)
+
+func TestSysDevicesSystemCpuOnlineHandler_Read(t *testing.T) {
+
+	h := &implementations.SysDevicesSystemCpuOnlineHandler{
+		domain.HandlerBase{
+			Name:    "sysDevicesSystemCpuOnline",
+			Path:    "/sys/devices/system/cpu/online",
+			Type:    domain.NODE_SUBSTITUTION,
+			Enabled: true,
+			Service: hds,
+		},
+	}
+
+	// Host FS initial state.
+	initFile := func(path, content string) {
+		if err := ios.NewIOnode("", path, 0).WriteFile([]byte(content)); err != nil {
+			t.Fatalf("Could not initialize host file %v: %v", path, err)
+		}
+	}
+	initFile(h.Path, "0-7")
+
+	tests := []struct {
+		name    string
+		pid     uint32
+		prepare func()
+		want    string
+	}{
+		{
+			//
+			// Test-case 1: Container restricted to a subset of the host cpus.
+			//
+			name: "1",
+			pid:  3003,
+			prepare: func() {
+				initFile("/proc/3003/cgroup", "6:cpuset:/docker/c1\n0::/docker/c1\n")
+				initFile("/sys/fs/cgroup/cpuset/docker/c1/cpuset.cpus", "3,0-1,2,6")
+			},
+			want: "0-3,6\n",
+		},
+		{
+			//
+			// Test-case 2: Container within the unified (v2) hierarchy.
+			//
+			name: "2",
+			pid:  3004,
+			prepare: func() {
+				initFile("/proc/3004/cgroup", "0::/docker/c2\n")
+				initFile("/sys/fs/cgroup/docker/c2/cpuset.cpus.effective", "4-5")
+			},
+			want: "4-5\n",
+		},
+		{
+			//
+			// Test-case 3: No cpuset found for the container. Host cpus
+			// displayed.
+			//
+			name:    "3",
+			pid:     3005,
+			prepare: func() {},
+			want:    "0-7\n",
+		},
+	}
+
+	//
+	// Testcase executions.
+	//
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cntr := css.ContainerCreate(
+				"c1",
+				tt.pid,
+				time.Time{},
+				231072,
+				65535,
+				231072,
+				65535,
+				nil,
+				nil,
+				false,
+				nil)
+
+			tt.prepare()
+
+			buf := make([]byte, 32)
+			req := &domain.HandlerRequest{Pid: tt.pid, Data: buf, Container: cntr}
+
+			sz, err := h.Read(ios.NewIOnode("", h.Path, 0), req)
+			if err != nil {
+				t.Fatalf("SysDevicesSystemCpuOnlineHandler.Read() error = %v", err)
+			}
+			if got := string(buf[:sz]); got != tt.want {
+				t.Errorf("SysDevicesSystemCpuOnlineHandler.Read() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
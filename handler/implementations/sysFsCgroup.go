@@ -0,0 +1,305 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+)
+
+//
+// Base handler for files under /sys/fs/cgroup.
+//
+// Sys containers are expected to see the cgroup subtree they have been placed
+// into. Thus, all I/O is redirected to the matching file within the container's
+// cgroup directory, as resolved on the host side from the cgroup membership of
+// the container's init process. Besides, as the contents of some of these files
+// may refer to absolute cgroup paths (i.e. as seen from the host), these are
+// rewritten to be relative to the container's cgroup root within the given
+// 'Controller'.
+//
+type SysFsCgroupBaseHandler struct {
+	domain.HandlerBase
+
+	// Cgroup (v1) controller hosting the emulated file (e.g. "memory").
+	Controller string
+}
+
+func (h *SysFsCgroupBaseHandler) Lookup(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (os.FileInfo, error) {
+
+	logrus.Debugf("Executing Lookup() method on %v handler", h.Name)
+
+	return n.Stat()
+}
+
+func (h *SysFsCgroupBaseHandler) Getattr(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (*syscall.Stat_t, error) {
+
+	logrus.Debugf("Executing Getattr() method on %v handler", h.Name)
+
+	return nil, nil
+}
+
+func (h *SysFsCgroupBaseHandler) Open(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) error {
+
+	logrus.Debugf("Executing %v Open() method\n", h.Name)
+
+	// The backing file is only known once the container's cgroup is resolved,
+	// so there's no host FS node to open here.
+	flags := n.OpenFlags()
+	if flags != syscall.O_RDONLY && flags != syscall.O_WRONLY {
+		return fuse.IOerror{Code: syscall.EACCES}
+	}
+
+	return nil
+}
+
+func (h *SysFsCgroupBaseHandler) Close(n domain.IOnodeIface) error {
+
+	logrus.Debugf("Executing Close() method on %v handler", h.Name)
+
+	return nil
+}
+
+func (h *SysFsCgroupBaseHandler) Read(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Read() method", h.Name)
+
+	if req.Offset > 0 {
+		return 0, io.EOF
+	}
+
+	cntr := req.Container
+
+	// Ensure operation is generated from within a registered sys container.
+	if cntr == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return 0, errors.New("Container not found")
+	}
+
+	root, err := h.cntrCgroup(cntr)
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := h.fetchFile(n, root)
+	if err != nil {
+		return 0, err
+	}
+
+	data = cgroupRelPaths(data, root) + "\n"
+
+	return copyResultBuffer(req.Data, []byte(data))
+}
+
+func (h *SysFsCgroupBaseHandler) Write(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Write() method", h.Name)
+
+	cntr := req.Container
+
+	// Ensure operation is generated from within a registered sys container.
+	if cntr == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return 0, errors.New("Container not found")
+	}
+
+	newVal, err := ValidateValue(&h.HandlerBase, req.Data)
+	if err != nil {
+		return 0, err
+	}
+
+	root, err := h.cntrCgroup(cntr)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := h.pushFile(n, root, newVal); err != nil {
+		return 0, err
+	}
+
+	return len(req.Data), nil
+}
+
+func (h *SysFsCgroupBaseHandler) ReadDirAll(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) ([]os.FileInfo, error) {
+
+	return nil, nil
+}
+
+// Auxiliary method to obtain the container's cgroup within the handler's
+// controller.
+func (h *SysFsCgroupBaseHandler) cntrCgroup(cntr domain.ContainerIface) (string, error) {
+
+	root, err := cntrCgroupPath(h.Service.IOService(), cntr, h.Controller)
+	if err != nil {
+		logrus.Errorf("Could not find %v cgroup of container %v: %v",
+			h.Controller, cntr.ID(), err)
+		return "", fuse.IOerror{Code: syscall.EIO}
+	}
+
+	return root, nil
+}
+
+// Auxiliary method to obtain the host path of the file backing the emulated
+// one within the passed cgroup.
+func (h *SysFsCgroupBaseHandler) hostFile(n domain.IOnodeIface, root string) string {
+
+	return filepath.Join(cgroupMountPoint, h.Controller, root, filepath.Base(n.Path()))
+}
+
+// Auxiliary method to fetch the content of the file within the container's
+// cgroup.
+func (h *SysFsCgroupBaseHandler) fetchFile(
+	n domain.IOnodeIface,
+	root string) (string, error) {
+
+	file := h.hostFile(n, root)
+
+	content, err := h.Service.IOService().NewIOnode("", file, 0).ReadFile()
+	if err != nil {
+		logrus.Errorf("Could not read from file %v: %v", file, err)
+		return "", fuse.IOerror{Code: syscall.EIO}
+	}
+
+	return strings.TrimSpace(string(content)), nil
+}
+
+// Auxiliary method to inject content into the file within the container's
+// cgroup.
+func (h *SysFsCgroupBaseHandler) pushFile(
+	n domain.IOnodeIface,
+	root string,
+	s string) error {
+
+	file := h.hostFile(n, root)
+
+	if err := h.Service.IOService().NewIOnode("", file, 0).WriteFile([]byte(s)); err != nil {
+		logrus.Errorf("Could not write to file %v: %v", file, err)
+		return fuse.IOerror{Code: syscall.EIO}
+	}
+
+	return nil
+}
+
+// cntrCgroupPath returns the (host) path of the cgroup the container's init
+// process belongs to within the given (v1) controller. An empty controller
+// stands for the unified (v2) hierarchy.
+func cntrCgroupPath(
+	ios domain.IOServiceIface,
+	cntr domain.ContainerIface,
+	controller string) (string, error) {
+
+	n := ios.NewIOnode("", fmt.Sprintf("/proc/%d/cgroup", cntr.InitPid()), 0)
+
+	content, err := n.ReadFile()
+	if err != nil {
+		return "", err
+	}
+
+	// Entries format: "hierarchy-id:controller-list:cgroup-path".
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		if controller == "" && fields[0] == "0" && fields[1] == "" {
+			return fields[2], nil
+		}
+		for _, c := range strings.Split(fields[1], ",") {
+			if controller != "" && c == controller {
+				return fields[2], nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("%v controller not found", controller)
+}
+
+// cgroupRelPaths rewrites the absolute cgroup paths within the passed content to
+// be relative to the given cgroup root.
+func cgroupRelPaths(content string, root string) string {
+
+	if root == "" || root == "/" {
+		return content
+	}
+
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		tokens := strings.Split(line, " ")
+		for j, t := range tokens {
+			if t == root {
+				tokens[j] = "/"
+			} else if strings.HasPrefix(t, root+"/") {
+				tokens[j] = t[len(root):]
+			}
+		}
+		lines[i] = strings.Join(tokens, " ")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (h *SysFsCgroupBaseHandler) GetName() string {
+	return h.Name
+}
+
+func (h *SysFsCgroupBaseHandler) GetPath() string {
+	return h.Path
+}
+
+func (h *SysFsCgroupBaseHandler) GetEnabled() bool {
+	return h.Enabled
+}
+
+func (h *SysFsCgroupBaseHandler) GetType() domain.HandlerType {
+	return h.Type
+}
+
+func (h *SysFsCgroupBaseHandler) GetService() domain.HandlerServiceIface {
+	return h.Service
+}
+
+func (h *SysFsCgroupBaseHandler) SetEnabled(val bool) {
+	h.Enabled = val
+}
+
+func (h *SysFsCgroupBaseHandler) SetService(hs domain.HandlerServiceIface) {
+	h.Service = hs
+}
@@ -0,0 +1,135 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/handler/implementations"
+)
+
+func TestSysFsCgroupBaseHandler_Read(t *testing.T) {
+
+	cntr := css.ContainerCreate(
+		"c1",
+		uint32(2002),
+		time.Time{},
+		231072,
+		65535,
+		231072,
+		65535,
+		nil,
+		nil,
+		false,
+		nil)
+
+	// Cgroups of the container's init process, as seen from the host.
+	n := ios.NewIOnode("", "/proc/2002/cgroup", 0)
+	err := n.WriteFile([]byte(
+		"5:cpu,cpuacct:/docker/abc\n" +
+			"4:memory:/docker/abc\n" +
+			"0::/docker/abc\n"))
+	if err != nil {
+		t.Fatalf("Could not initialize host file: %v", err)
+	}
+
+	// File content within the container's cgroup, as seen from the host.
+	expectRead := func(file string, content string) {
+		n := ios.NewIOnode("", file, 0)
+		if err := n.WriteFile([]byte(content)); err != nil {
+			t.Fatalf("Could not initialize host file: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		controller string
+		hostPath   string
+		content    string
+		want       string
+	}{
+		{
+			//
+			// Test-case 1: Container's memory limit.
+			//
+			name:       "1",
+			path:       "/sys/fs/cgroup/memory/memory.limit_in_bytes",
+			controller: "memory",
+			hostPath:   "/sys/fs/cgroup/memory/docker/abc/memory.limit_in_bytes",
+			content:    "1073741824",
+			want:       "1073741824\n",
+		},
+		{
+			//
+			// Test-case 2: Container's cpu quota (controller sharing its
+			// hierarchy with others).
+			//
+			name:       "2",
+			path:       "/sys/fs/cgroup/cpu/cpu.cfs_quota_us",
+			controller: "cpu",
+			hostPath:   "/sys/fs/cgroup/cpu/docker/abc/cpu.cfs_quota_us",
+			content:    "-1",
+			want:       "-1\n",
+		},
+		{
+			//
+			// Test-case 3: Absolute cgroup paths rewritten to be relative to the
+			// container's cgroup.
+			//
+			name:       "3",
+			path:       "/sys/fs/cgroup/memory/memory.paths",
+			controller: "memory",
+			hostPath:   "/sys/fs/cgroup/memory/docker/abc/memory.paths",
+			content:    "/docker/abc\n/docker/abc/sub 1\n/docker/abcd",
+			want:       "/\n/sub 1\n/docker/abcd\n",
+		},
+	}
+
+	//
+	// Testcase executions.
+	//
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &implementations.SysFsCgroupBaseHandler{
+				HandlerBase: domain.HandlerBase{
+					Name:    "sysFsCgroup",
+					Path:    tt.path,
+					Type:    domain.NODE_SUBSTITUTION,
+					Enabled: true,
+					Service: hds,
+				},
+				Controller: tt.controller,
+			}
+
+			expectRead(tt.hostPath, tt.content)
+
+			buf := make([]byte, 128)
+			req := &domain.HandlerRequest{Pid: 2002, Data: buf, Container: cntr}
+
+			sz, err := h.Read(ios.NewIOnode("", tt.path, 0), req)
+			if err != nil {
+				t.Fatalf("SysFsCgroupBaseHandler.Read() error = %v", err)
+			}
+			if got := string(buf[:sz]); got != tt.want {
+				t.Errorf("SysFsCgroupBaseHandler.Read() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
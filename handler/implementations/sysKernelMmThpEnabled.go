@@ -0,0 +1,268 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+)
+
+//
+// /sys/kernel/mm/transparent_hugepage/enabled handler
+//
+// Host-global THP setting, displayed by the kernel as the list of supported
+// modes with the active one enclosed in brackets (e.g. "always [madvise] never").
+// Reads display the host FS content, and writes are rejected (EPERM) unless the
+// 'Enforce' attribute is enabled, in which case the written mode (which must
+// be one of the supported ones) is pushed down to the host FS.
+//
+type SysKernelMmThpEnabledHandler struct {
+	domain.HandlerBase
+
+	// Allow writes to reach the host FS.
+	Enforce bool
+}
+
+// Modes supported by the kernel's THP 'enabled' setting.
+var thpEnabledModes = []string{"always", "madvise", "never"}
+
+func (h *SysKernelMmThpEnabledHandler) Lookup(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (os.FileInfo, error) {
+
+	logrus.Debugf("Executing Lookup() method on %v handler", h.Name)
+
+	return n.Stat()
+}
+
+func (h *SysKernelMmThpEnabledHandler) Getattr(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (*syscall.Stat_t, error) {
+
+	logrus.Debugf("Executing Getattr() method on %v handler", h.Name)
+
+	return nil, nil
+}
+
+func (h *SysKernelMmThpEnabledHandler) Open(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) error {
+
+	logrus.Debugf("Executing %v Open() method\n", h.Name)
+
+	flags := n.OpenFlags()
+	if flags != syscall.O_RDONLY && flags != syscall.O_WRONLY {
+		return fuse.IOerror{Code: syscall.EACCES}
+	}
+
+	if flags == syscall.O_WRONLY && !h.Enforce {
+		return fuse.IOerror{Code: syscall.EPERM}
+	}
+
+	if err := n.Open(); err != nil {
+		logrus.Debugf("Error opening file %v", h.Path)
+		return fuse.IOerror{Code: syscall.EIO}
+	}
+
+	return nil
+}
+
+func (h *SysKernelMmThpEnabledHandler) Close(n domain.IOnodeIface) error {
+
+	logrus.Debugf("Executing Close() method on %v handler", h.Name)
+
+	if err := n.Close(); err != nil {
+		logrus.Debugf("Error closing file %v", h.Path)
+		return fuse.IOerror{Code: syscall.EIO}
+	}
+
+	return nil
+}
+
+func (h *SysKernelMmThpEnabledHandler) Read(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Read() method", h.Name)
+
+	// We are dealing with a single-line element being read, so we can save
+	// some cycles by returning right away if offset is any higher than zero.
+	if req.Offset > 0 {
+		return 0, io.EOF
+	}
+
+	// Ensure operation is generated from within a registered sys container.
+	if req.Container == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return 0, errors.New("Container not found")
+	}
+
+	data, err := h.fetchFile(n)
+	if err != nil {
+		return 0, fuse.IOerror{Code: syscall.EIO}
+	}
+
+	data += "\n"
+
+	return copyResultBuffer(req.Data, []byte(data))
+}
+
+func (h *SysKernelMmThpEnabledHandler) Write(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Write() method", h.Name)
+
+	// Ensure operation is generated from within a registered sys container.
+	if req.Container == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return 0, errors.New("Container not found")
+	}
+
+	if !h.Enforce {
+		return 0, fuse.IOerror{Code: syscall.EPERM}
+	}
+
+	newVal, err := ValidateValue(&h.HandlerBase, req.Data)
+	if err != nil {
+		return 0, err
+	}
+	if !thpValidMode(newVal) {
+		return 0, fuse.IOerror{Code: syscall.EINVAL}
+	}
+
+	if err := h.pushFile(n, newVal); err != nil {
+		return 0, fuse.IOerror{Code: syscall.EIO}
+	}
+
+	return len(req.Data), nil
+}
+
+func (h *SysKernelMmThpEnabledHandler) ReadDirAll(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) ([]os.FileInfo, error) {
+
+	return nil, nil
+}
+
+func (h *SysKernelMmThpEnabledHandler) fetchFile(n domain.IOnodeIface) (string, error) {
+
+	h.Lock.Lock()
+	defer h.Lock.Unlock()
+
+	// Read from host FS to extract the existing setting.
+	curHostVal, err := n.ReadLine()
+	if err != nil && err != io.EOF {
+		logrus.Errorf("Could not read from file %v", h.Path)
+		return "", err
+	}
+
+	// High-level verification to ensure that format is the expected one.
+	if _, err := thpSelectedMode(curHostVal); err != nil {
+		logrus.Errorf("Unexpected content read from file %v, error %v", h.Path, err)
+		return "", err
+	}
+
+	return curHostVal, nil
+}
+
+func (h *SysKernelMmThpEnabledHandler) pushFile(n domain.IOnodeIface, val string) error {
+
+	h.Lock.Lock()
+	defer h.Lock.Unlock()
+
+	err := n.WriteFile([]byte(val))
+	if err != nil && !h.Service.IgnoreErrors() {
+		logrus.Errorf("Could not write %v to file: %s", val, err)
+		return err
+	}
+
+	return nil
+}
+
+// thpSelectedMode returns the active mode (the bracketed one) within the passed
+// THP setting.
+func thpSelectedMode(val string) (string, error) {
+
+	for _, f := range strings.Fields(val) {
+		if len(f) > 2 && strings.HasPrefix(f, "[") && strings.HasSuffix(f, "]") {
+			return f[1 : len(f)-1], nil
+		}
+	}
+
+	return "", errors.New("no selected mode found")
+}
+
+func thpValidMode(mode string) bool {
+
+	for _, m := range thpEnabledModes {
+		if mode == m {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (h *SysKernelMmThpEnabledHandler) Writable() bool {
+	return h.Enforce
+}
+
+func (h *SysKernelMmThpEnabledHandler) MergePolicy() domain.MergePolicy {
+	if h.Enforce {
+		return domain.MergePolicyLast
+	}
+	return domain.MergePolicyNone
+}
+
+func (h *SysKernelMmThpEnabledHandler) GetName() string {
+	return h.Name
+}
+
+func (h *SysKernelMmThpEnabledHandler) GetPath() string {
+	return h.Path
+}
+
+func (h *SysKernelMmThpEnabledHandler) GetEnabled() bool {
+	return h.Enabled
+}
+
+func (h *SysKernelMmThpEnabledHandler) GetType() domain.HandlerType {
+	return h.Type
+}
+
+func (h *SysKernelMmThpEnabledHandler) GetService() domain.HandlerServiceIface {
+	return h.Service
+}
+
+func (h *SysKernelMmThpEnabledHandler) SetEnabled(val bool) {
+	h.Enabled = val
+}
+
+func (h *SysKernelMmThpEnabledHandler) SetService(hs domain.HandlerServiceIface) {
+	h.Service = hs
+}
@@ -0,0 +1,184 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations_test
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+	"github.com/nestybox/sysbox-fs/handler/implementations"
+)
+
+func TestSysKernelMmThpEnabledHandler_Write(t *testing.T) {
+
+	// Host FS initial state.
+	const hostVal = "always [madvise] never"
+
+	cntr := css.ContainerCreate(
+		"c1",
+		uint32(1001),
+		time.Time{},
+		231072,
+		65535,
+		231072,
+		65535,
+		nil,
+		nil,
+		false,
+		nil)
+
+	tests := []struct {
+		name        string
+		enforce     bool
+		data        string
+		wantErrVal  error
+		wantHostVal string
+	}{
+		{
+			//
+			// Test-case 1: Default (read-only) mode. Write must be rejected
+			// (EPERM) and host FS left untouched.
+			//
+			name:        "1",
+			enforce:     false,
+			data:        "never\n",
+			wantErrVal:  fuse.IOerror{Code: syscall.EPERM},
+			wantHostVal: hostVal,
+		},
+		{
+			//
+			// Test-case 2: Enforce mode with a valid mode. Host FS must reflect
+			// the new value.
+			//
+			name:        "2",
+			enforce:     true,
+			data:        "never\n",
+			wantErrVal:  nil,
+			wantHostVal: "never",
+		},
+		{
+			//
+			// Test-case 3: Enforce mode with an unsupported mode (EINVAL).
+			//
+			name:        "3",
+			enforce:     true,
+			data:        "sometimes\n",
+			wantErrVal:  fuse.IOerror{Code: syscall.EINVAL},
+			wantHostVal: hostVal,
+		},
+	}
+
+	//
+	// Testcase executions.
+	//
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &implementations.SysKernelMmThpEnabledHandler{
+				HandlerBase: domain.HandlerBase{
+					Name:      "sysKernelMmThpEnabled",
+					Path:      "/sys/kernel/mm/transparent_hugepage/enabled",
+					Type:      domain.NODE_SUBSTITUTION,
+					Enabled:   true,
+					Service:   hds,
+					ValueType: domain.ValueTypeString,
+				},
+				Enforce: tt.enforce,
+			}
+
+			n := ios.NewIOnode("", h.Path, 0)
+			if err := n.WriteFile([]byte(hostVal)); err != nil {
+				t.Fatalf("Could not initialize host file: %v", err)
+			}
+
+			req := &domain.HandlerRequest{
+				Pid:       1001,
+				Data:      []byte(tt.data),
+				Container: cntr,
+			}
+
+			_, err := h.Write(n, req)
+			if err != tt.wantErrVal {
+				t.Errorf("SysKernelMmThpEnabledHandler.Write() error = %v, want %v",
+					err, tt.wantErrVal)
+			}
+
+			gotHostVal, err := n.ReadLine()
+			if err != nil {
+				t.Fatalf("Could not read host file: %v", err)
+			}
+			if gotHostVal != tt.wantHostVal {
+				t.Errorf("SysKernelMmThpEnabledHandler.Write() host value = %v, want %v",
+					gotHostVal, tt.wantHostVal)
+			}
+		})
+	}
+}
+
+func TestSysKernelMmThpEnabledHandler_Read(t *testing.T) {
+
+	const hostVal = "always [madvise] never"
+
+	cntr := css.ContainerCreate(
+		"c1",
+		uint32(1001),
+		time.Time{},
+		231072,
+		65535,
+		231072,
+		65535,
+		nil,
+		nil,
+		false,
+		nil)
+
+	h := &implementations.SysKernelMmThpEnabledHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:    "sysKernelMmThpEnabled",
+			Path:    "/sys/kernel/mm/transparent_hugepage/enabled",
+			Type:    domain.NODE_SUBSTITUTION,
+			Enabled: true,
+			Service: hds,
+		},
+	}
+
+	n := ios.NewIOnode("", h.Path, 0)
+	if err := n.WriteFile([]byte(hostVal)); err != nil {
+		t.Fatalf("Could not initialize host file: %v", err)
+	}
+
+	buf := make([]byte, 64)
+	req := &domain.HandlerRequest{Pid: 1001, Data: buf, Container: cntr}
+
+	sz, err := h.Read(n, req)
+	if err != nil {
+		t.Fatalf("SysKernelMmThpEnabledHandler.Read() error = %v", err)
+	}
+	if got := string(buf[:sz]); got != hostVal+"\n" {
+		t.Errorf("SysKernelMmThpEnabledHandler.Read() = %q, want %q", got, hostVal+"\n")
+	}
+
+	// Contents lacking a selected mode are rejected.
+	if err := n.WriteFile([]byte("always madvise never")); err != nil {
+		t.Fatalf("Could not initialize host file: %v", err)
+	}
+	if _, err := h.Read(n, req); err != (fuse.IOerror{Code: syscall.EIO}) {
+		t.Errorf("SysKernelMmThpEnabledHandler.Read() error = %v, want EIO", err)
+	}
+}
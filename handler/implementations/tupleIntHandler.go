@@ -0,0 +1,286 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"errors"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+)
+
+//
+// Base handler for sysctls holding a fixed-length tuple of integers (e.g.
+// "4096 87380 6291456").
+//
+// Writes are validated as a whole before being accepted (EINVAL otherwise):
+//
+// - the number of elements must match 'Fields'; shorter tuples are only
+//   accepted if 'Partial' is set, in which case the trailing elements are
+//   preserved (as the kernel does).
+// - every element must satisfy its validator (if any) in 'Validators'.
+// - the tuple must satisfy the 'Ordered' predicate (if any).
+//
+// Each element is reconciled with the host's one as per its policy in
+// 'Policies' (see MergeBaseHandler). Elements lacking a policy are kept within
+// the container's scope, so the host FS is left untouched if no policies are
+// provided.
+//
+type TupleIntHandler struct {
+	domain.HandlerBase
+
+	Fields     int
+	Partial    bool
+	Validators []TupleFieldValidator
+	Ordered    func(fields []int64) bool
+	Policies   []domain.MergePolicy
+}
+
+// TupleFieldValidator reports whether a tuple element holds a valid value.
+type TupleFieldValidator func(val int64) bool
+
+// TupleFieldRange returns a validator accepting values within [min, max].
+func TupleFieldRange(min, max int64) TupleFieldValidator {
+	return func(val int64) bool {
+		return val >= min && val <= max
+	}
+}
+
+// TupleAscending reports whether the tuple elements are in non-decreasing order
+// (e.g. "min default max" triplets, or "low high" ranges).
+func TupleAscending(fields []int64) bool {
+	for i := 1; i < len(fields); i++ {
+		if fields[i] < fields[i-1] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (h *TupleIntHandler) Lookup(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (os.FileInfo, error) {
+
+	logrus.Debugf("Executing Lookup() method on %v handler", h.Name)
+
+	return n.Stat()
+}
+
+func (h *TupleIntHandler) Getattr(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (*syscall.Stat_t, error) {
+
+	logrus.Debugf("Executing Getattr() method on %v handler", h.Name)
+
+	return nil, nil
+}
+
+func (h *TupleIntHandler) Open(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) error {
+
+	logrus.Debugf("Executing %v Open() method\n", h.Name)
+
+	return h.merger().open(n)
+}
+
+func (h *TupleIntHandler) Close(n domain.IOnodeIface) error {
+
+	logrus.Debugf("Executing Close() method on %v handler", h.Name)
+
+	return h.merger().close(n)
+}
+
+func (h *TupleIntHandler) Read(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Read() method", h.Name)
+
+	return h.merger().read(n, req)
+}
+
+func (h *TupleIntHandler) Write(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Write() method", h.Name)
+
+	// Ensure operation is generated from within a registered sys container.
+	if req.Container == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return 0, errors.New("Container not found")
+	}
+
+	m := h.merger()
+
+	fields, err := parseTuple(string(req.Data))
+	if err != nil || len(fields) > h.Fields ||
+		(len(fields) < h.Fields && !h.Partial) {
+		logrus.Errorf("Unsupported value %q for %v (expected %v elements)",
+			req.Data, h.Path, h.Fields)
+		return 0, fuse.IOerror{Code: syscall.EINVAL}
+	}
+
+	// Trailing elements of partial tuples are taken from the value currently
+	// displayed to the container.
+	if len(fields) < h.Fields {
+		cur, ok := req.Container.Data(n.Path(), n.Name())
+		if !ok {
+			if cur, err = m.fetch(n); err != nil {
+				return 0, err
+			}
+		}
+
+		curFields, err := parseTuple(cur)
+		if err != nil || len(curFields) != h.Fields {
+			logrus.Errorf("Unexpected content %q for %v", cur, h.Path)
+			return 0, fuse.IOerror{Code: syscall.EIO}
+		}
+		fields = append(fields, curFields[len(fields):]...)
+	}
+
+	for i, val := range fields {
+		if i < len(h.Validators) && h.Validators[i] != nil && !h.Validators[i](val) {
+			logrus.Errorf("Unsupported value %v for element %v of %v", val, i, h.Path)
+			return 0, fuse.IOerror{Code: syscall.EINVAL}
+		}
+	}
+
+	if h.Ordered != nil && !h.Ordered(fields) {
+		logrus.Errorf("Unsupported ordering of elements %v for %v", fields, h.Path)
+		return 0, fuse.IOerror{Code: syscall.EINVAL}
+	}
+
+	vals := make([]string, len(fields))
+	for i, val := range fields {
+		vals[i] = strconv.FormatInt(val, 10)
+	}
+
+	// Hand over the complete (and validated) tuple to the merger.
+	wreq := *req
+	wreq.Data = []byte(strings.Join(vals, "\t"))
+
+	if _, err := m.write(n, &wreq); err != nil {
+		return 0, err
+	}
+
+	return len(req.Data), nil
+}
+
+func (h *TupleIntHandler) ReadDirAll(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) ([]os.FileInfo, error) {
+
+	return nil, nil
+}
+
+func (h *TupleIntHandler) MergePolicy() domain.MergePolicy {
+
+	policy := domain.MergePolicyNone
+
+	for i := 0; i < h.Fields; i++ {
+		p := h.fieldPolicy(i)
+		if i > 0 && p != policy {
+			return domain.MergePolicyField
+		}
+		policy = p
+	}
+
+	return policy
+}
+
+// Returns the merge policy of the given tuple element.
+func (h *TupleIntHandler) fieldPolicy(i int) domain.MergePolicy {
+
+	if i < len(h.Policies) && h.Policies[i] != "" {
+		return h.Policies[i]
+	}
+
+	return domain.MergePolicyNone
+}
+
+func (h *TupleIntHandler) merger() *hostMerger {
+
+	policies := make([]domain.MergePolicy, h.Fields)
+	for i := range policies {
+		policies[i] = h.fieldPolicy(i)
+	}
+
+	// Values are validated by this handler, so the merger must accept any
+	// integer.
+	return &hostMerger{
+		hb:            &h.HandlerBase,
+		policy:        h.MergePolicy(),
+		vtype:         domain.ValueTypeTuple,
+		minVal:        math.MinInt64,
+		fieldPolicies: policies,
+	}
+}
+
+// Parses a whitespace-separated list of integers.
+func parseTuple(s string) ([]int64, error) {
+
+	strFields, err := parseValue(domain.ValueTypeTuple, 0, s)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]int64, len(strFields))
+	for i, f := range strFields {
+		fields[i], _ = strconv.ParseInt(f, 10, 64)
+	}
+
+	return fields, nil
+}
+
+func (h *TupleIntHandler) GetName() string {
+	return h.Name
+}
+
+func (h *TupleIntHandler) GetPath() string {
+	return h.Path
+}
+
+func (h *TupleIntHandler) GetEnabled() bool {
+	return h.Enabled
+}
+
+func (h *TupleIntHandler) GetType() domain.HandlerType {
+	return h.Type
+}
+
+func (h *TupleIntHandler) GetService() domain.HandlerServiceIface {
+	return h.Service
+}
+
+func (h *TupleIntHandler) SetEnabled(val bool) {
+	h.Enabled = val
+}
+
+func (h *TupleIntHandler) SetService(hs domain.HandlerServiceIface) {
+	h.Service = hs
+}
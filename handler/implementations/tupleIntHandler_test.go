@@ -0,0 +1,294 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations_test
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+	"github.com/nestybox/sysbox-fs/handler/implementations"
+)
+
+func TestTupleIntHandler_Write(t *testing.T) {
+
+	// "min default max" triplet, each element merged with the host as per its
+	// own policy.
+	h := &implementations.TupleIntHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "ipv4TcpRmem",
+			Path:      "/proc/sys/net/ipv4/tcp_rmem",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+			Service:   hds,
+		},
+		Fields: 3,
+		Validators: []implementations.TupleFieldValidator{
+			implementations.TupleFieldRange(1, 1<<20),
+			nil,
+			implementations.TupleFieldRange(1, 1<<30),
+		},
+		Ordered: implementations.TupleAscending,
+		Policies: []domain.MergePolicy{
+			domain.MergePolicyMin,
+			domain.MergePolicyNone,
+			domain.MergePolicyMax,
+		},
+	}
+
+	if got := h.MergePolicy(); got != domain.MergePolicyField {
+		t.Errorf("TupleIntHandler.MergePolicy() = %v, want %v", got, domain.MergePolicyField)
+	}
+
+	n := ios.NewIOnode("tcp_rmem", h.Path, 0)
+	if err := n.WriteFile([]byte("4096\t131072\t6291456")); err != nil {
+		t.Fatalf("Could not initialize host file: %v", err)
+	}
+
+	cntr := css.ContainerCreate(
+		"c1",
+		uint32(1001),
+		time.Time{},
+		231072,
+		65535,
+		231072,
+		65535,
+		nil,
+		nil,
+		false,
+		nil)
+
+	tests := []struct {
+		name        string
+		data        string
+		wantErr     bool
+		wantErrVal  error
+		wantHostVal string
+	}{
+		{
+			//
+			// Test-case 1: Fewer elements than expected (EINVAL).
+			//
+			name:        "1",
+			data:        "4096 87380\n",
+			wantErr:     true,
+			wantErrVal:  fuse.IOerror{Code: syscall.EINVAL},
+			wantHostVal: "4096\t131072\t6291456",
+		},
+		{
+			//
+			// Test-case 2: More elements than expected (EINVAL).
+			//
+			name:        "2",
+			data:        "4096 87380 6291456 1\n",
+			wantErr:     true,
+			wantErrVal:  fuse.IOerror{Code: syscall.EINVAL},
+			wantHostVal: "4096\t131072\t6291456",
+		},
+		{
+			//
+			// Test-case 3: Non-integer element (EINVAL).
+			//
+			name:        "3",
+			data:        "4096 foo 6291456\n",
+			wantErr:     true,
+			wantErrVal:  fuse.IOerror{Code: syscall.EINVAL},
+			wantHostVal: "4096\t131072\t6291456",
+		},
+		{
+			//
+			// Test-case 4: Element failing its validator (EINVAL).
+			//
+			name:        "4",
+			data:        "0 87380 6291456\n",
+			wantErr:     true,
+			wantErrVal:  fuse.IOerror{Code: syscall.EINVAL},
+			wantHostVal: "4096\t131072\t6291456",
+		},
+		{
+			//
+			// Test-case 5: Elements in descending order (EINVAL).
+			//
+			name:        "5",
+			data:        "8192 4096 6291456\n",
+			wantErr:     true,
+			wantErrVal:  fuse.IOerror{Code: syscall.EINVAL},
+			wantHostVal: "4096\t131072\t6291456",
+		},
+		{
+			//
+			// Test-case 6: Valid tuple. Host must hold the lowest 'min', its own
+			// 'default' and the highest 'max'.
+			//
+			name:        "6",
+			data:        "8192 87380 8388608\n",
+			wantErr:     false,
+			wantHostVal: "4096\t131072\t8388608",
+		},
+		{
+			//
+			// Test-case 7: Valid tuple with a lower 'min'.
+			//
+			name:        "7",
+			data:        "1024 87380 4194304\n",
+			wantErr:     false,
+			wantHostVal: "1024\t131072\t8388608",
+		},
+	}
+
+	//
+	// Testcase executions.
+	//
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			req := &domain.HandlerRequest{
+				Pid:       cntr.InitPid(),
+				Data:      []byte(tt.data),
+				Container: cntr,
+			}
+
+			got, err := h.Write(n, req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("TupleIntHandler.Write() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && tt.wantErrVal != nil && err != tt.wantErrVal {
+				t.Errorf("TupleIntHandler.Write() error = %v, wantErr %v, wantErrVal %v",
+					err, tt.wantErr, tt.wantErrVal)
+			}
+			if err == nil && got != len(tt.data) {
+				t.Errorf("TupleIntHandler.Write() = %v, want %v", got, len(tt.data))
+			}
+
+			gotHostVal, err := n.ReadLine()
+			if err != nil {
+				t.Fatalf("Could not read host file: %v", err)
+			}
+			if gotHostVal != tt.wantHostVal {
+				t.Errorf("TupleIntHandler.Write() host value = %q, want %q",
+					gotHostVal, tt.wantHostVal)
+			}
+		})
+	}
+
+	// The container must display the last tuple it wrote.
+	buf := make([]byte, 64)
+	rn, err := h.Read(n, &domain.HandlerRequest{
+		Pid:       cntr.InitPid(),
+		Data:      buf,
+		Container: cntr,
+	})
+	if err != nil {
+		t.Fatalf("TupleIntHandler.Read() error = %v", err)
+	}
+	if got, want := string(buf[:rn]), "1024\t87380\t4194304\n"; got != want {
+		t.Errorf("TupleIntHandler.Read() = %q, want %q", got, want)
+	}
+}
+
+func TestTupleIntHandler_Partial(t *testing.T) {
+
+	h := &implementations.TupleIntHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "kernelPrintk",
+			Path:      "/proc/sys/kernel/printk",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+			Service:   hds,
+		},
+		Fields:  4,
+		Partial: true,
+	}
+
+	if got := h.MergePolicy(); got != domain.MergePolicyNone {
+		t.Errorf("TupleIntHandler.MergePolicy() = %v, want %v", got, domain.MergePolicyNone)
+	}
+
+	n := ios.NewIOnode("printk", h.Path, 0)
+	if err := n.WriteFile([]byte("4\t4\t1\t7")); err != nil {
+		t.Fatalf("Could not initialize host file: %v", err)
+	}
+
+	cntr := css.ContainerCreate(
+		"c1",
+		uint32(1001),
+		time.Time{},
+		231072,
+		65535,
+		231072,
+		65535,
+		nil,
+		nil,
+		false,
+		nil)
+
+	read := func() string {
+		buf := make([]byte, 64)
+		rn, err := h.Read(n, &domain.HandlerRequest{
+			Pid:       cntr.InitPid(),
+			Data:      buf,
+			Container: cntr,
+		})
+		if err != nil {
+			t.Fatalf("TupleIntHandler.Read() error = %v", err)
+		}
+		return string(buf[:rn])
+	}
+
+	write := func(data string) error {
+		_, err := h.Write(n, &domain.HandlerRequest{
+			Pid:       cntr.InitPid(),
+			Data:      []byte(data),
+			Container: cntr,
+		})
+		return err
+	}
+
+	// Partial tuple over the host value: trailing elements are preserved.
+	if err := write("8\n"); err != nil {
+		t.Fatalf("TupleIntHandler.Write() error = %v", err)
+	}
+	if got, want := read(), "8\t4\t1\t7\n"; got != want {
+		t.Errorf("TupleIntHandler.Read() = %q, want %q", got, want)
+	}
+
+	// Partial tuple over the container's value.
+	if err := write("3 5\n"); err != nil {
+		t.Fatalf("TupleIntHandler.Write() error = %v", err)
+	}
+	if got, want := read(), "3\t5\t1\t7\n"; got != want {
+		t.Errorf("TupleIntHandler.Read() = %q, want %q", got, want)
+	}
+
+	// Empty and oversized tuples are still rejected (EINVAL).
+	for _, data := range []string{"\n", "1 2 3 4 5\n"} {
+		if err := write(data); err != (fuse.IOerror{Code: syscall.EINVAL}) {
+			t.Errorf("TupleIntHandler.Write(%q) error = %v, want %v",
+				data, err, fuse.IOerror{Code: syscall.EINVAL})
+		}
+	}
+
+	// Host FS must be left untouched.
+	if got, err := n.ReadLine(); err != nil || got != "4\t4\t1\t7" {
+		t.Errorf("TupleIntHandler.Write() host value = %q (%v), want %q",
+			got, err, "4\t4\t1\t7")
+	}
+}
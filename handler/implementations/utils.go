@@ -19,8 +19,11 @@ package implementations
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"syscall"
 
 	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
 )
 
 // copytResultBuffer function copies the obtained 'result' buffer into the 'I/O'
@@ -45,6 +48,77 @@ func copyResultBuffer(ioBuf []byte, result []byte) (int, error) {
 	return length, nil
 }
 
+// sizedFileInfo returns a copy of the passed file info reporting the given size,
+// both in the info itself and in its underlying stat struct (which is the one
+// the fuse layer relies on).
+func sizedFileInfo(info os.FileInfo, size int64) os.FileInfo {
+
+	var stat syscall.Stat_t
+	if sys, ok := info.Sys().(*syscall.Stat_t); ok && sys != nil {
+		stat = *sys
+	}
+	stat.Size = size
+
+	return domain.FileInfo{
+		Fname:    info.Name(),
+		Fsize:    size,
+		Fmode:    info.Mode(),
+		FmodTime: info.ModTime(),
+		FisDir:   info.IsDir(),
+		Fsys:     &stat,
+	}
+}
+
+// isNotExistErr reports whether the passed error, as generated by the nsenter
+// agent, stands for a resource missing in the container (ENOENT).
+func isNotExistErr(err error) bool {
+	ioErr, ok := err.(fuse.IOerror)
+
+	return ok && ioErr.Code == syscall.ENOENT
+}
+
+// missingFileInfo returns the attributes of a resource missing from the host
+// kernel, which are those of a regular (and writable) sysctl file.
+func missingFileInfo(n domain.IOnodeIface) os.FileInfo {
+
+	return domain.FileInfo{
+		Fname: n.Name(),
+		Fmode: 0644,
+		Fsys: &syscall.Stat_t{
+			Mode:  syscall.S_IFREG | 0644,
+			Nlink: 1,
+		},
+	}
+}
+
+// nsDataKey returns the name under which the content of the passed node is to
+// be kept in the container's data store. Handlers keying their content on a
+// namespace of the reading process (see HandlerBase.NsKey) get an entry per
+// namespace (e.g. "loadavg@4026531836").
+func nsDataKey(
+	hs domain.HandlerServiceIface,
+	ns domain.NStype,
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (string, error) {
+
+	if ns == "" {
+		return n.Name(), nil
+	}
+
+	process := hs.ProcessService().ProcessCreate(req.Pid, 0, 0)
+	inodes, err := process.NsInodes()
+	if err != nil {
+		return "", err
+	}
+
+	inode, ok := inodes[string(ns)]
+	if !ok {
+		return "", fmt.Errorf("No %v namespace found for pid %d", ns, req.Pid)
+	}
+
+	return n.Name() + "@" + strconv.FormatUint(inode, 10), nil
+}
+
 // EmulatedFilesInfo is a handler aid that finds files within the given
 // directory node that are emulated by sysbox-fs. It returns a map that lists
 // each file's name and it's info.
@@ -0,0 +1,116 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+)
+
+//
+// Validation of the values written into emulated resources as per the value
+// type declared by their handlers (see domain.ValueType).
+//
+
+// ValidateValue ensures that the data being written into the resource emulated
+// by the given handler matches the type expected by the kernel, and returns it
+// with the surrounding whitespaces trimmed. Type mismatches are reported as
+// EINVAL. No validation is done for handlers lacking a value type.
+func ValidateValue(h *domain.HandlerBase, data []byte) (string, error) {
+
+	val := strings.TrimSpace(string(data))
+
+	if _, err := parseValue(h.ValueType, h.ValueLen, val); err != nil {
+		logrus.Errorf("Invalid %v value %q written into %v: %v",
+			h.ValueType, val, h.Path, err)
+		return "", fuse.IOerror{Code: syscall.EINVAL}
+	}
+
+	return val, nil
+}
+
+// parseValue splits the passed value into its elements, ensuring that these
+// match the given value type. 'n' is the number of elements expected for tuple
+// types (zero means any).
+func parseValue(vtype domain.ValueType, n int, val string) ([]string, error) {
+
+	if vtype == domain.ValueTypeAny {
+		return []string{val}, nil
+	}
+
+	if vtype == domain.ValueTypeString {
+		if val == "" {
+			return nil, errors.New("empty value")
+		}
+		if strings.ContainsAny(val, "\n\x00") {
+			return nil, errors.New("multi-line value")
+		}
+		return []string{val}, nil
+	}
+
+	fields := strings.Fields(val)
+
+	if len(fields) == 0 {
+		return nil, errors.New("empty value")
+	}
+	if vtype != domain.ValueTypeTuple && len(fields) != 1 {
+		return nil, errors.New("invalid number of elements")
+	}
+	if vtype == domain.ValueTypeTuple && n > 0 && len(fields) != n {
+		return nil, errors.New("invalid number of elements")
+	}
+
+	for _, f := range fields {
+		var err error
+
+		switch vtype {
+		case domain.ValueTypeInt, domain.ValueTypeTuple:
+			_, err = strconv.ParseInt(f, 10, 64)
+
+		case domain.ValueTypeUint64:
+			_, err = strconv.ParseUint(f, 10, 64)
+
+		case domain.ValueTypeBool:
+			if f != "0" && f != "1" {
+				err = errors.New("non-boolean value")
+			}
+
+		case domain.ValueTypeBitmask:
+			if strings.HasPrefix(f, "0x") || strings.HasPrefix(f, "0X") {
+				_, err = strconv.ParseUint(f[2:], 16, 64)
+			} else {
+				_, err = strconv.ParseUint(f, 10, 64)
+			}
+
+		default:
+			err = errors.New("unknown value type")
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return fields, nil
+}
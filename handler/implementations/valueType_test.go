@@ -0,0 +1,99 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations_test
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+	"github.com/nestybox/sysbox-fs/handler/implementations"
+)
+
+func TestValidateValue(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		vtype   domain.ValueType
+		vlen    int
+		data    string
+		want    string
+		wantErr bool
+	}{
+		// Untyped handler: no validation.
+		{name: "any", vtype: domain.ValueTypeAny, data: "foo bar\n", want: "foo bar"},
+
+		// Signed integers.
+		{name: "int", vtype: domain.ValueTypeInt, data: "-15\n", want: "-15"},
+		{name: "int-float", vtype: domain.ValueTypeInt, data: "1.5", wantErr: true},
+		{name: "int-multi", vtype: domain.ValueTypeInt, data: "1 2", wantErr: true},
+		{name: "int-empty", vtype: domain.ValueTypeInt, data: "\n", wantErr: true},
+
+		// Unsigned 64-bit integers.
+		{name: "uint64", vtype: domain.ValueTypeUint64, data: "18446744073709551615", want: "18446744073709551615"},
+		{name: "uint64-neg", vtype: domain.ValueTypeUint64, data: "-1", wantErr: true},
+		{name: "uint64-overflow", vtype: domain.ValueTypeUint64, data: "18446744073709551616", wantErr: true},
+
+		// Booleans.
+		{name: "bool", vtype: domain.ValueTypeBool, data: "1\n", want: "1"},
+		{name: "bool-int", vtype: domain.ValueTypeBool, data: "2", wantErr: true},
+		{name: "bool-str", vtype: domain.ValueTypeBool, data: "true", wantErr: true},
+
+		// Tuples.
+		{name: "tuple", vtype: domain.ValueTypeTuple, vlen: 3, data: "4096\t87380 6291456\n", want: "4096\t87380 6291456"},
+		{name: "tuple-any-len", vtype: domain.ValueTypeTuple, data: "1 2", want: "1 2"},
+		{name: "tuple-short", vtype: domain.ValueTypeTuple, vlen: 3, data: "4096 87380", wantErr: true},
+		{name: "tuple-nonint", vtype: domain.ValueTypeTuple, vlen: 3, data: "4096 foo 6291456", wantErr: true},
+
+		// Strings.
+		{name: "string", vtype: domain.ValueTypeString, data: "bbr\n", want: "bbr"},
+		{name: "string-empty", vtype: domain.ValueTypeString, data: " \n", wantErr: true},
+		{name: "string-multiline", vtype: domain.ValueTypeString, data: "bbr\ncubic", wantErr: true},
+
+		// Bitmasks.
+		{name: "bitmask-dec", vtype: domain.ValueTypeBitmask, data: "176", want: "176"},
+		{name: "bitmask-hex", vtype: domain.ValueTypeBitmask, data: "0xff\n", want: "0xff"},
+		{name: "bitmask-badhex", vtype: domain.ValueTypeBitmask, data: "0xfg", wantErr: true},
+		{name: "bitmask-neg", vtype: domain.ValueTypeBitmask, data: "-1", wantErr: true},
+	}
+
+	//
+	// Testcase executions.
+	//
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &domain.HandlerBase{
+				Name:      "test",
+				Path:      "/proc/sys/test",
+				ValueType: tt.vtype,
+				ValueLen:  tt.vlen,
+			}
+
+			got, err := implementations.ValidateValue(h, []byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && err != (fuse.IOerror{Code: syscall.EINVAL}) {
+				t.Errorf("ValidateValue() error = %v, want EINVAL", err)
+			}
+			if got != tt.want {
+				t.Errorf("ValidateValue() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,318 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+)
+
+//
+// /proc/sys/vm/drop_caches handler
+//
+// Documentation: Writing to this will cause the kernel to drop clean caches, as
+// well as reclaimable slab objects like dentries and inodes. Once dropped, their
+// memory becomes free.
+//
+// Supported values:
+//
+// 1 - free pagecache
+//
+// 2 - free reclaimable slab objects (includes dentries and inodes)
+//
+// 3 - free slab objects and pagecache
+//
+// Note: As this is a system-wide attribute, a sys container dropping caches
+// would impact the page-cache of the entire host. Hence, by default, writes are
+// only validated and stored superficially (at sys-container level), turning
+// this operation into a no-op. The real action can be allowed by enabling the
+// 'Passthrough' attribute of this handler (i.e. the 'passthrough' write mode,
+// see Reconfigure()), in which case valid values are pushed down to the host
+// FS. The real action can be further restricted to a set of trusted containers
+// through the 'AllowedContainers' attribute; writes of the remaining ones are
+// still served as a no-op.
+//
+
+const (
+	minDropCachesVal = 1
+	maxDropCachesVal = 3
+)
+
+type VmDropCachesHandler struct {
+	domain.HandlerBase
+
+	// Forward valid writes to the host kernel.
+	Passthrough bool
+
+	// IDs of the only containers whose writes are forwarded in passthrough
+	// mode. Empty means that all containers are.
+	AllowedContainers []string
+}
+
+func (h *VmDropCachesHandler) Lookup(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (os.FileInfo, error) {
+
+	logrus.Debugf("Executing Lookup() method on %v handler", h.Name)
+
+	return n.Stat()
+}
+
+func (h *VmDropCachesHandler) Getattr(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (*syscall.Stat_t, error) {
+
+	logrus.Debugf("Executing Getattr() method on %v handler", h.Name)
+
+	return nil, nil
+}
+
+func (h *VmDropCachesHandler) Open(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) error {
+
+	logrus.Debugf("Executing %v Open() method\n", h.Name)
+
+	flags := n.OpenFlags()
+	if flags != syscall.O_RDONLY && flags != syscall.O_WRONLY {
+		return fuse.IOerror{Code: syscall.EACCES}
+	}
+
+	if err := n.Open(); err != nil {
+		logrus.Debugf("Error opening file %v", h.Path)
+		return fuse.IOerror{Code: syscall.EIO}
+	}
+
+	return nil
+}
+
+func (h *VmDropCachesHandler) Close(n domain.IOnodeIface) error {
+
+	logrus.Debugf("Executing Close() method on %v handler", h.Name)
+
+	if err := n.Close(); err != nil {
+		logrus.Debugf("Error closing file %v", h.Path)
+		return fuse.IOerror{Code: syscall.EIO}
+	}
+
+	return nil
+}
+
+func (h *VmDropCachesHandler) Read(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Read() method", h.Name)
+
+	// We are dealing with a single integer element being read, so we can save
+	// some cycles by returning right away if offset is any higher than zero.
+	if req.Offset > 0 {
+		return 0, io.EOF
+	}
+
+	name := n.Name()
+	path := n.Path()
+	cntr := req.Container
+
+	// Ensure operation is generated from within a registered sys container.
+	if cntr == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return 0, errors.New("Container not found")
+	}
+
+	// Return the last value written by this container, if any. Otherwise,
+	// display the kernel's default one ("0"). Notice that the host FS value is
+	// not relevant here as it reflects the actions of other containers / host
+	// agents.
+	cntr.Lock()
+	data, ok := cntr.Data(path, name)
+	if !ok {
+		data = "0"
+	}
+	cntr.Unlock()
+
+	data += "\n"
+
+	return copyResultBuffer(req.Data, []byte(data))
+}
+
+func (h *VmDropCachesHandler) Write(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Write() method", h.Name)
+
+	name := n.Name()
+	path := n.Path()
+	cntr := req.Container
+
+	// Ensure operation is generated from within a registered sys container.
+	if cntr == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return 0, errors.New("Container not found")
+	}
+
+	newVal := strings.TrimSpace(string(req.Data))
+	newValInt, err := strconv.Atoi(newVal)
+	if err != nil {
+		return 0, fuse.IOerror{Code: syscall.EINVAL}
+	}
+
+	// Ensure that only proper values are allowed as per this resource's
+	// supported values.
+	if newValInt < minDropCachesVal || newValInt > maxDropCachesVal {
+		return 0, fuse.IOerror{Code: syscall.EINVAL}
+	}
+
+	// Push the value down to the host kernel only if explicitly allowed.
+	if h.Passthrough && h.allowed(cntr) {
+		if err := h.pushFile(n, newValInt); err != nil {
+			return 0, fuse.IOerror{Code: syscall.EIO}
+		}
+	}
+
+	// Store the new value within the container struct.
+	cntr.Lock()
+	defer cntr.Unlock()
+
+	cntr.SetData(path, name, strconv.Itoa(newValInt))
+
+	return len(req.Data), nil
+}
+
+func (h *VmDropCachesHandler) ReadDirAll(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) ([]os.FileInfo, error) {
+
+	return nil, nil
+}
+
+func (h *VmDropCachesHandler) pushFile(n domain.IOnodeIface, val int) error {
+
+	// Serialize cache-drop requests originated by different sys containers.
+	h.Lock.Lock()
+	defer h.Lock.Unlock()
+
+	msg := []byte(strconv.Itoa(val))
+	err := n.WriteFile(msg)
+	if err != nil && !h.Service.IgnoreErrors() {
+		logrus.Errorf("Could not write %d to file: %s", val, err)
+		return err
+	}
+
+	return nil
+}
+
+// allowed reports whether the passed container is entitled to drop the host
+// caches.
+func (h *VmDropCachesHandler) allowed(cntr domain.ContainerIface) bool {
+
+	if len(h.AllowedContainers) == 0 {
+		return true
+	}
+
+	for _, id := range h.AllowedContainers {
+		if id == cntr.ID() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reconfigure returns a copy of the handler operating in the passed write mode
+// ('no-op' or 'passthrough') and restricting the real action to the passed
+// allow-list. The merge policy is implied by the write mode.
+func (h *VmDropCachesHandler) Reconfigure(
+	cfg domain.HandlerConfig) (domain.HandlerIface, error) {
+
+	if cfg.AcceptMissing != nil {
+		return nil, errors.New("missing-resource policy not supported")
+	}
+
+	nh := &VmDropCachesHandler{
+		HandlerBase:       h.HandlerBase.Clone(),
+		Passthrough:       h.Passthrough,
+		AllowedContainers: h.AllowedContainers,
+	}
+
+	switch cfg.WriteMode {
+	case "":
+	case domain.WriteModeNoop:
+		nh.Passthrough = false
+	case domain.WriteModePassthrough:
+		nh.Passthrough = true
+	default:
+		return nil, errors.New("write mode not supported")
+	}
+
+	if cfg.MergePolicy != "" && cfg.MergePolicy != nh.MergePolicy() {
+		return nil, errors.New("merge policy not supported")
+	}
+
+	if cfg.AllowedContainers != nil {
+		nh.AllowedContainers = append([]string(nil), cfg.AllowedContainers...)
+	}
+
+	return nh, nil
+}
+
+func (h *VmDropCachesHandler) MergePolicy() domain.MergePolicy {
+	if h.Passthrough {
+		return domain.MergePolicyLast
+	}
+	return domain.MergePolicyNone
+}
+
+func (h *VmDropCachesHandler) GetName() string {
+	return h.Name
+}
+
+func (h *VmDropCachesHandler) GetPath() string {
+	return h.Path
+}
+
+func (h *VmDropCachesHandler) GetEnabled() bool {
+	return h.Enabled
+}
+
+func (h *VmDropCachesHandler) GetType() domain.HandlerType {
+	return h.Type
+}
+
+func (h *VmDropCachesHandler) GetService() domain.HandlerServiceIface {
+	return h.Service
+}
+
+func (h *VmDropCachesHandler) SetEnabled(val bool) {
+	h.Enabled = val
+}
+
+func (h *VmDropCachesHandler) SetService(hs domain.HandlerServiceIface) {
+	h.Service = hs
+}
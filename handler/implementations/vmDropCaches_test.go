@@ -0,0 +1,229 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations_test
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+	"github.com/nestybox/sysbox-fs/handler/implementations"
+)
+
+func TestVmDropCachesHandler_Write(t *testing.T) {
+	type fields struct {
+		Passthrough       bool
+		AllowedContainers []string
+	}
+
+	type args struct {
+		n   domain.IOnodeIface
+		req *domain.HandlerRequest
+	}
+
+	// Host FS initial state.
+	const hostVal = "0"
+
+	cntr := css.ContainerCreate(
+		"c1",
+		uint32(1001),
+		time.Time{},
+		231072,
+		65535,
+		231072,
+		65535,
+		nil,
+		nil,
+		false,
+		nil)
+
+	tests := []struct {
+		name        string
+		fields      fields
+		args        args
+		wantErr     bool
+		wantErrVal  error
+		wantHostVal string
+		wantCntrVal string
+	}{
+		{
+			//
+			// Test-case 1: Default (no-op) mode. Host FS must be left untouched.
+			//
+			name:   "1",
+			fields: fields{Passthrough: false},
+			args: args{
+				n:   ios.NewIOnode("drop_caches", "/proc/sys/vm/drop_caches", 0),
+				req: &domain.HandlerRequest{Pid: 1001, Data: []byte("3\n"), Container: cntr},
+			},
+			wantErr:     false,
+			wantHostVal: hostVal,
+			wantCntrVal: "3",
+		},
+		{
+			//
+			// Test-case 2: Passthrough mode. Host FS must reflect the new value.
+			//
+			name:   "2",
+			fields: fields{Passthrough: true},
+			args: args{
+				n:   ios.NewIOnode("drop_caches", "/proc/sys/vm/drop_caches", 0),
+				req: &domain.HandlerRequest{Pid: 1001, Data: []byte("1\n"), Container: cntr},
+			},
+			wantErr:     false,
+			wantHostVal: "1",
+			wantCntrVal: "1",
+		},
+		{
+			//
+			// Test-case 3: Out-of-range value (EINVAL). Host FS must be left
+			// untouched even in passthrough mode.
+			//
+			name:   "3",
+			fields: fields{Passthrough: true},
+			args: args{
+				n:   ios.NewIOnode("drop_caches", "/proc/sys/vm/drop_caches", 0),
+				req: &domain.HandlerRequest{Pid: 1001, Data: []byte("4\n"), Container: cntr},
+			},
+			wantErr:     true,
+			wantErrVal:  fuse.IOerror{Code: syscall.EINVAL},
+			wantHostVal: hostVal,
+		},
+		{
+			//
+			// Test-case 4: Passthrough mode restricted to a whitelisted
+			// container. Host FS must reflect the new value.
+			//
+			name:   "4",
+			fields: fields{Passthrough: true, AllowedContainers: []string{"c1"}},
+			args: args{
+				n:   ios.NewIOnode("drop_caches", "/proc/sys/vm/drop_caches", 0),
+				req: &domain.HandlerRequest{Pid: 1001, Data: []byte("2\n"), Container: cntr},
+			},
+			wantErr:     false,
+			wantHostVal: "2",
+			wantCntrVal: "2",
+		},
+		{
+			//
+			// Test-case 5: Passthrough mode restricted to other containers.
+			// Write must be served as a no-op.
+			//
+			name:   "5",
+			fields: fields{Passthrough: true, AllowedContainers: []string{"c2"}},
+			args: args{
+				n:   ios.NewIOnode("drop_caches", "/proc/sys/vm/drop_caches", 0),
+				req: &domain.HandlerRequest{Pid: 1001, Data: []byte("2\n"), Container: cntr},
+			},
+			wantErr:     false,
+			wantHostVal: hostVal,
+			wantCntrVal: "2",
+		},
+	}
+
+	//
+	// Testcase executions.
+	//
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &implementations.VmDropCachesHandler{
+				HandlerBase: domain.HandlerBase{
+					Name:      "vmDropCaches",
+					Path:      "/proc/sys/vm/drop_caches",
+					Type:      domain.NODE_SUBSTITUTION,
+					Enabled:   true,
+					Cacheable: true,
+					Service:   hds,
+				},
+				Passthrough:       tt.fields.Passthrough,
+				AllowedContainers: tt.fields.AllowedContainers,
+			}
+
+			// Reset host and container state.
+			if err := tt.args.n.WriteFile([]byte(hostVal)); err != nil {
+				t.Fatalf("Could not initialize host file: %v", err)
+			}
+			cntr.SetData(tt.args.n.Path(), tt.args.n.Name(), "0")
+
+			_, err := h.Write(tt.args.n, tt.args.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VmDropCachesHandler.Write() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && tt.wantErrVal != nil && err != tt.wantErrVal {
+				t.Errorf("VmDropCachesHandler.Write() error = %v, wantErr %v, wantErrVal %v",
+					err, tt.wantErr, tt.wantErrVal)
+			}
+
+			gotHostVal, err := tt.args.n.ReadLine()
+			if err != nil {
+				t.Fatalf("Could not read host file: %v", err)
+			}
+			if gotHostVal != tt.wantHostVal {
+				t.Errorf("VmDropCachesHandler.Write() host value = %v, want %v",
+					gotHostVal, tt.wantHostVal)
+			}
+
+			if tt.wantErr {
+				return
+			}
+			gotCntrVal, _ := cntr.Data(tt.args.n.Path(), tt.args.n.Name())
+			if gotCntrVal != tt.wantCntrVal {
+				t.Errorf("VmDropCachesHandler.Write() container value = %v, want %v",
+					gotCntrVal, tt.wantCntrVal)
+			}
+		})
+	}
+}
+
+func TestVmDropCachesHandler_Reconfigure(t *testing.T) {
+
+	h := &implementations.VmDropCachesHandler{
+		HandlerBase: domain.HandlerBase{
+			Name:      "vmDropCaches",
+			Path:      "/proc/sys/vm/drop_caches",
+			Type:      domain.NODE_SUBSTITUTION,
+			Enabled:   true,
+			Cacheable: true,
+			Service:   hds,
+		},
+	}
+
+	nh, err := h.Reconfigure(domain.HandlerConfig{
+		WriteMode:         domain.WriteModePassthrough,
+		AllowedContainers: []string{"c1"},
+	})
+	if err != nil {
+		t.Fatalf("VmDropCachesHandler.Reconfigure() error = %v", err)
+	}
+	d, ok := nh.(*implementations.VmDropCachesHandler)
+	if !ok || !d.Passthrough || len(d.AllowedContainers) != 1 ||
+		d.MergePolicy() != domain.MergePolicyLast {
+		t.Errorf("VmDropCachesHandler.Reconfigure() = %+v, want passthrough restricted to c1", nh)
+	}
+	if h.Passthrough || h.AllowedContainers != nil {
+		t.Errorf("VmDropCachesHandler.Reconfigure() altered the original handler")
+	}
+
+	if _, err := d.Reconfigure(domain.HandlerConfig{WriteMode: domain.WriteModeNoop}); err != nil {
+		t.Errorf("VmDropCachesHandler.Reconfigure() error = %v", err)
+	}
+	if _, err := h.Reconfigure(domain.HandlerConfig{WriteMode: domain.WriteModeEnforceMax}); err == nil {
+		t.Errorf("VmDropCachesHandler.Reconfigure() accepted the enforce-max write mode")
+	}
+}
@@ -21,7 +21,6 @@ import (
 	"io"
 	"os"
 	"strconv"
-	"strings"
 	"syscall"
 
 	"github.com/sirupsen/logrus"
@@ -161,11 +160,14 @@ func (h *VsExpireQuiescentTemplateHandler) Write(
 		return 0, errors.New("Container not found")
 	}
 
-	newVal := strings.TrimSpace(string(req.Data))
+	newVal, err := ValidateValue(&h.HandlerBase, req.Data)
+	if err != nil {
+		return 0, err
+	}
 	newValInt, err := strconv.Atoi(newVal)
 	if err != nil {
 		logrus.Errorf("Unexpected error: %v", err)
-		return 0, err
+		return 0, fuse.IOerror{Code: syscall.EINVAL}
 	}
 
 	cntr.Lock()
@@ -0,0 +1,127 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package handler
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+//
+// writeCoalescer collapses bursts of writes of the same resource by the same
+// container into a single host FS update. Every write (re)arms the timer of its
+// (container, path, name) key, and once the key has been quiet for the whole
+// window, the last value written is the one pushed to the host. Intermediate
+// values only ever make it to the container's data store.
+//
+// Notice that coalesced writes are acknowledged before reaching the host FS, so
+// any failure to push the value down can only be logged, and never reported
+// back to the writer.
+//
+type writeCoalescer struct {
+	mu      sync.Mutex
+	pending map[coalesceKey]*pendingWrite
+}
+
+type coalesceKey struct {
+	cntr string
+	path string
+	name string
+}
+
+type pendingWrite struct {
+	val   string
+	push  func(val string) error
+	timer *time.Timer
+}
+
+func newWriteCoalescer() *writeCoalescer {
+	return &writeCoalescer{pending: make(map[coalesceKey]*pendingWrite)}
+}
+
+// schedule registers the value to push to the host once the passed window
+// expires, superseding any value still pending for the same key.
+func (c *writeCoalescer) schedule(
+	key coalesceKey,
+	val string,
+	window time.Duration,
+	push func(val string) error) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if p, ok := c.pending[key]; ok {
+		p.val = val
+		p.push = push
+		p.timer.Reset(window)
+		return
+	}
+
+	p := &pendingWrite{val: val, push: push}
+	p.timer = time.AfterFunc(window, func() { c.fire(key, p) })
+	c.pending[key] = p
+}
+
+// isPending returns true if a value is awaiting to be pushed for the given key.
+func (c *writeCoalescer) isPending(key coalesceKey) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.pending[key]
+
+	return ok
+}
+
+// cancel drops all the values still pending on behalf of the given container.
+func (c *writeCoalescer) cancel(cntr string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var count int
+
+	for key, p := range c.pending {
+		if key.cntr != cntr {
+			continue
+		}
+		p.timer.Stop()
+		delete(c.pending, key)
+		count++
+	}
+
+	return count
+}
+
+func (c *writeCoalescer) fire(key coalesceKey, p *pendingWrite) {
+
+	// Skip stale timers (i.e. re-armed after the value was already pushed, or
+	// cancelled).
+	c.mu.Lock()
+	if c.pending[key] != p {
+		c.mu.Unlock()
+		return
+	}
+	delete(c.pending, key)
+	val, push := p.val, p.push
+	c.mu.Unlock()
+
+	if err := push(val); err != nil {
+		logrus.Errorf("Could not push coalesced value %v (container %v, %v:%v): %v",
+			val, key.cntr, key.path, key.name, err)
+	}
+}
@@ -91,6 +91,7 @@ func ContainerRegister(ctx interface{}, data *grpc.ContainerData) error {
 		uint32(data.GidSize),
 		data.ProcRoPaths,
 		data.ProcMaskPaths,
+		data.SysctlReadOnly,
 		ipcService.css,
 	)
 
@@ -140,6 +141,7 @@ func ContainerUpdate(ctx interface{}, data *grpc.ContainerData) error {
 		uint32(data.GidSize),
 		nil,
 		nil,
+		false,
 		ipcService.css,
 	)
 
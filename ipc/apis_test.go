@@ -248,6 +248,7 @@ func TestContainerRegister(t *testing.T) {
 					uint32(a1.data.GidSize),
 					a1.data.ProcRoPaths,
 					a1.data.ProcMaskPaths,
+					a1.data.SysctlReadOnly,
 					css).Return(c1)
 
 				css.On("ContainerRegister", c1).Return(nil)
@@ -273,6 +274,7 @@ func TestContainerRegister(t *testing.T) {
 					uint32(a1.data.GidSize),
 					a1.data.ProcRoPaths,
 					a1.data.ProcMaskPaths,
+					a1.data.SysctlReadOnly,
 					css).Return(c1)
 
 				css.On("ContainerRegister", c1).Return(
@@ -321,6 +323,7 @@ func TestContainerUnregister(t *testing.T) {
 		65535,
 		nil,
 		nil,
+		false,
 		nil,
 	)
 
@@ -434,6 +437,7 @@ func TestContainerUpdate(t *testing.T) {
 					uint32(a1.data.GidSize),
 					a1.data.ProcRoPaths,
 					a1.data.ProcMaskPaths,
+					false,
 					css).Return(c1)
 
 				css.On("ContainerUpdate", c1).Return(nil)
@@ -459,6 +463,7 @@ func TestContainerUpdate(t *testing.T) {
 					uint32(a1.data.GidSize),
 					a1.data.ProcRoPaths,
 					a1.data.ProcMaskPaths,
+					false,
 					css).Return(c1)
 
 				css.On("ContainerUpdate", c1).Return(
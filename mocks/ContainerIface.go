@@ -14,6 +14,30 @@ type ContainerIface struct {
 	mock.Mock
 }
 
+// CacheHit provides a mock function with given fields:
+func (_m *ContainerIface) CacheHit() {
+	_m.Called()
+}
+
+// CacheMiss provides a mock function with given fields:
+func (_m *ContainerIface) CacheMiss() {
+	_m.Called()
+}
+
+// CacheStats provides a mock function with given fields:
+func (_m *ContainerIface) CacheStats() domain.CacheStats {
+	ret := _m.Called()
+
+	var r0 domain.CacheStats
+	if rf, ok := ret.Get(0).(func() domain.CacheStats); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(domain.CacheStats)
+	}
+
+	return r0
+}
+
 // Ctime provides a mock function with given fields:
 func (_m *ContainerIface) Ctime() time.Time {
 	ret := _m.Called()
@@ -84,6 +108,20 @@ func (_m *ContainerIface) GID() uint32 {
 	return r0
 }
 
+// GIDSize provides a mock function with given fields:
+func (_m *ContainerIface) GIDSize() uint32 {
+	ret := _m.Called()
+
+	var r0 uint32
+	if rf, ok := ret.Get(0).(func() uint32); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(uint32)
+	}
+
+	return r0
+}
+
 // ID provides a mock function with given fields:
 func (_m *ContainerIface) ID() string {
 	ret := _m.Called()
@@ -296,6 +334,11 @@ func (_m *ContainerIface) SetInitProc(pid uint32, uid uint32, gid uint32) error
 	return r0
 }
 
+// SetSysctlReadOnly provides a mock function with given fields: val
+func (_m *ContainerIface) SetSysctlReadOnly(val bool) {
+	_m.Called(val)
+}
+
 // String provides a mock function with given fields:
 func (_m *ContainerIface) String() string {
 	ret := _m.Called()
@@ -310,6 +353,20 @@ func (_m *ContainerIface) String() string {
 	return r0
 }
 
+// SysctlReadOnly provides a mock function with given fields:
+func (_m *ContainerIface) SysctlReadOnly() bool {
+	ret := _m.Called()
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func() bool); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
 // UID provides a mock function with given fields:
 func (_m *ContainerIface) UID() uint32 {
 	ret := _m.Called()
@@ -324,6 +381,34 @@ func (_m *ContainerIface) UID() uint32 {
 	return r0
 }
 
+// UIDSize provides a mock function with given fields:
+func (_m *ContainerIface) UIDSize() uint32 {
+	ret := _m.Called()
+
+	var r0 uint32
+	if rf, ok := ret.Get(0).(func() uint32); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(uint32)
+	}
+
+	return r0
+}
+
+// Validate provides a mock function with given fields:
+func (_m *ContainerIface) Validate() bool {
+	ret := _m.Called()
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func() bool); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
 // Unlock provides a mock function with given fields:
 func (_m *ContainerIface) Unlock() {
 	_m.Called()
@@ -14,13 +14,13 @@ type ContainerStateServiceIface struct {
 	mock.Mock
 }
 
-// ContainerCreate provides a mock function with given fields: id, pid, ctime, uidFirst, uidSize, gidFirst, gidSize, procRoPaths, procMaskPaths, service
-func (_m *ContainerStateServiceIface) ContainerCreate(id string, pid uint32, ctime time.Time, uidFirst uint32, uidSize uint32, gidFirst uint32, gidSize uint32, procRoPaths []string, procMaskPaths []string, service domain.ContainerStateServiceIface) domain.ContainerIface {
-	ret := _m.Called(id, pid, ctime, uidFirst, uidSize, gidFirst, gidSize, procRoPaths, procMaskPaths, service)
+// ContainerCreate provides a mock function with given fields: id, pid, ctime, uidFirst, uidSize, gidFirst, gidSize, procRoPaths, procMaskPaths, sysctlRo, service
+func (_m *ContainerStateServiceIface) ContainerCreate(id string, pid uint32, ctime time.Time, uidFirst uint32, uidSize uint32, gidFirst uint32, gidSize uint32, procRoPaths []string, procMaskPaths []string, sysctlRo bool, service domain.ContainerStateServiceIface) domain.ContainerIface {
+	ret := _m.Called(id, pid, ctime, uidFirst, uidSize, gidFirst, gidSize, procRoPaths, procMaskPaths, sysctlRo, service)
 
 	var r0 domain.ContainerIface
-	if rf, ok := ret.Get(0).(func(string, uint32, time.Time, uint32, uint32, uint32, uint32, []string, []string, domain.ContainerStateServiceIface) domain.ContainerIface); ok {
-		r0 = rf(id, pid, ctime, uidFirst, uidSize, gidFirst, gidSize, procRoPaths, procMaskPaths, service)
+	if rf, ok := ret.Get(0).(func(string, uint32, time.Time, uint32, uint32, uint32, uint32, []string, []string, bool, domain.ContainerStateServiceIface) domain.ContainerIface); ok {
+		r0 = rf(id, pid, ctime, uidFirst, uidSize, gidFirst, gidSize, procRoPaths, procMaskPaths, sysctlRo, service)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(domain.ContainerIface)
@@ -44,6 +44,22 @@ func (_m *ContainerStateServiceIface) ContainerDBSize() int {
 	return r0
 }
 
+// ContainerDBSnapshot provides a mock function with given fields:
+func (_m *ContainerStateServiceIface) ContainerDBSnapshot() []domain.ContainerSnapshot {
+	ret := _m.Called()
+
+	var r0 []domain.ContainerSnapshot
+	if rf, ok := ret.Get(0).(func() []domain.ContainerSnapshot); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.ContainerSnapshot)
+		}
+	}
+
+	return r0
+}
+
 // ContainerLookupById provides a mock function with given fields: id
 func (_m *ContainerStateServiceIface) ContainerLookupById(id string) domain.ContainerIface {
 	ret := _m.Called(id)
@@ -120,6 +136,20 @@ func (_m *ContainerStateServiceIface) ContainerRegister(c domain.ContainerIface)
 	return r0
 }
 
+// ContainerMarkDead provides a mock function with given fields: c
+func (_m *ContainerStateServiceIface) ContainerMarkDead(c domain.ContainerIface) error {
+	ret := _m.Called(c)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(domain.ContainerIface) error); ok {
+		r0 = rf(c)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // ContainerUnregister provides a mock function with given fields: c
 func (_m *ContainerStateServiceIface) ContainerUnregister(c domain.ContainerIface) error {
 	ret := _m.Called(c)
@@ -196,7 +226,46 @@ func (_m *ContainerStateServiceIface) ProcessService() domain.ProcessServiceIfac
 	return r0
 }
 
+// RegisterPersistentPath provides a mock function with given fields: path
+func (_m *ContainerStateServiceIface) RegisterPersistentPath(path string) {
+	_m.Called(path)
+}
+
+// SetCacheWarmer provides a mock function with given fields: w
+func (_m *ContainerStateServiceIface) SetCacheWarmer(w domain.CacheWarmer) {
+	_m.Called(w)
+}
+
+// SetWriteCanceller provides a mock function with given fields: w
+func (_m *ContainerStateServiceIface) SetWriteCanceller(w domain.WriteCanceller) {
+	_m.Called(w)
+}
+
+// SetPersistDir provides a mock function with given fields: dir
+func (_m *ContainerStateServiceIface) SetPersistDir(dir string) error {
+	ret := _m.Called(dir)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(dir)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetSysctlReadOnly provides a mock function with given fields: val
+func (_m *ContainerStateServiceIface) SetSysctlReadOnly(val bool) {
+	_m.Called(val)
+}
+
 // Setup provides a mock function with given fields: fss, prs, ios, mts
 func (_m *ContainerStateServiceIface) Setup(fss domain.FuseServerServiceIface, prs domain.ProcessServiceIface, ios domain.IOServiceIface, mts domain.MountServiceIface) {
 	_m.Called(fss, prs, ios, mts)
 }
+
+// Shutdown provides a mock function with given fields:
+func (_m *ContainerStateServiceIface) Shutdown() {
+	_m.Called()
+}
@@ -38,8 +38,17 @@ func (_m *FuseServerIface) Destroy() error {
 }
 
 // InitWait provides a mock function with given fields:
-func (_m *FuseServerIface) InitWait() {
-	_m.Called()
+func (_m *FuseServerIface) InitWait() error {
+	ret := _m.Called()
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func() error); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
 }
 
 // MountPoint provides a mock function with given fields:
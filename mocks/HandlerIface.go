@@ -30,6 +30,20 @@ func (_m *HandlerIface) Close(node domain.IOnodeIface) error {
 	return r0
 }
 
+// GetCacheable provides a mock function with given fields:
+func (_m *HandlerIface) GetCacheable() bool {
+	ret := _m.Called()
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func() bool); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
 // GetEnabled provides a mock function with given fields:
 func (_m *HandlerIface) GetEnabled() bool {
 	ret := _m.Called()
@@ -72,6 +86,34 @@ func (_m *HandlerIface) GetPath() string {
 	return r0
 }
 
+// GetPersistent provides a mock function with given fields:
+func (_m *HandlerIface) GetPersistent() bool {
+	ret := _m.Called()
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func() bool); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
+// GetReadOnly provides a mock function with given fields:
+func (_m *HandlerIface) GetReadOnly() bool {
+	ret := _m.Called()
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func() bool); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
 // GetService provides a mock function with given fields:
 func (_m *HandlerIface) GetService() domain.HandlerServiceIface {
 	ret := _m.Called()
@@ -102,6 +144,20 @@ func (_m *HandlerIface) GetType() domain.HandlerType {
 	return r0
 }
 
+// GetWriteOnly provides a mock function with given fields:
+func (_m *HandlerIface) GetWriteOnly() bool {
+	ret := _m.Called()
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func() bool); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
 // Getattr provides a mock function with given fields: n, req
 func (_m *HandlerIface) Getattr(n domain.IOnodeIface, req *domain.HandlerRequest) (*syscall.Stat_t, error) {
 	ret := _m.Called(n, req)
@@ -125,6 +181,20 @@ func (_m *HandlerIface) Getattr(n domain.IOnodeIface, req *domain.HandlerRequest
 	return r0, r1
 }
 
+// IsCacheable provides a mock function with given fields: req
+func (_m *HandlerIface) IsCacheable(req *domain.HandlerRequest) bool {
+	ret := _m.Called(req)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(*domain.HandlerRequest) bool); ok {
+		r0 = rf(req)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
 // Lookup provides a mock function with given fields: n, req
 func (_m *HandlerIface) Lookup(n domain.IOnodeIface, req *domain.HandlerRequest) (os.FileInfo, error) {
 	ret := _m.Called(n, req)
@@ -148,6 +218,20 @@ func (_m *HandlerIface) Lookup(n domain.IOnodeIface, req *domain.HandlerRequest)
 	return r0, r1
 }
 
+// MergePolicy provides a mock function with given fields:
+func (_m *HandlerIface) MergePolicy() domain.MergePolicy {
+	ret := _m.Called()
+
+	var r0 domain.MergePolicy
+	if rf, ok := ret.Get(0).(func() domain.MergePolicy); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(domain.MergePolicy)
+	}
+
+	return r0
+}
+
 // Open provides a mock function with given fields: node, req
 func (_m *HandlerIface) Open(node domain.IOnodeIface, req *domain.HandlerRequest) error {
 	ret := _m.Called(node, req)
@@ -206,6 +290,20 @@ func (_m *HandlerIface) ReadDirAll(node domain.IOnodeIface, req *domain.HandlerR
 	return r0, r1
 }
 
+// Rmdir provides a mock function with given fields: node, req
+func (_m *HandlerIface) Rmdir(node domain.IOnodeIface, req *domain.HandlerRequest) error {
+	ret := _m.Called(node, req)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(domain.IOnodeIface, *domain.HandlerRequest) error); ok {
+		r0 = rf(node, req)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // SetEnabled provides a mock function with given fields: val
 func (_m *HandlerIface) SetEnabled(val bool) {
 	_m.Called(val)
@@ -216,6 +314,20 @@ func (_m *HandlerIface) SetService(hs domain.HandlerServiceIface) {
 	_m.Called(hs)
 }
 
+// Writable provides a mock function with given fields:
+func (_m *HandlerIface) Writable() bool {
+	ret := _m.Called()
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func() bool); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
 // Write provides a mock function with given fields: node, req
 func (_m *HandlerIface) Write(node domain.IOnodeIface, req *domain.HandlerRequest) (int, error) {
 	ret := _m.Called(node, req)
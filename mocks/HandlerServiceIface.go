@@ -5,6 +5,10 @@ package mocks
 import (
 	domain "github.com/nestybox/sysbox-fs/domain"
 	mock "github.com/stretchr/testify/mock"
+
+	syscall "syscall"
+
+	time "time"
 )
 
 // HandlerServiceIface is an autogenerated mock type for the HandlerServiceIface type
@@ -12,6 +16,25 @@ type HandlerServiceIface struct {
 	mock.Mock
 }
 
+// Authorize provides a mock function with given fields: cntr, pid, path, op
+func (_m *HandlerServiceIface) Authorize(cntr domain.ContainerIface, pid uint32, path string, op domain.HandlerOp) syscall.Errno {
+	ret := _m.Called(cntr, pid, path, op)
+
+	var r0 syscall.Errno
+	if rf, ok := ret.Get(0).(func(domain.ContainerIface, uint32, string, domain.HandlerOp) syscall.Errno); ok {
+		r0 = rf(cntr, pid, path, op)
+	} else {
+		r0 = ret.Get(0).(syscall.Errno)
+	}
+
+	return r0
+}
+
+// Audit provides a mock function with given fields: rec
+func (_m *HandlerServiceIface) Audit(rec domain.HandlerAuditRecord) {
+	_m.Called(rec)
+}
+
 // DirHandlerEntries provides a mock function with given fields: s
 func (_m *HandlerServiceIface) DirHandlerEntries(s string) []string {
 	ret := _m.Called(s)
@@ -116,6 +139,36 @@ func (_m *HandlerServiceIface) HandlerDB() map[string]domain.HandlerIface {
 	return r0
 }
 
+// HandlersMetadata provides a mock function with given fields:
+func (_m *HandlerServiceIface) HandlersMetadata() []domain.HandlerMetadata {
+	ret := _m.Called()
+
+	var r0 []domain.HandlerMetadata
+	if rf, ok := ret.Get(0).(func() []domain.HandlerMetadata); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.HandlerMetadata)
+		}
+	}
+
+	return r0
+}
+
+// HealthCheck provides a mock function with given fields:
+func (_m *HandlerServiceIface) HealthCheck() error {
+	ret := _m.Called()
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func() error); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // HostUserNsInode provides a mock function with given fields:
 func (_m *HandlerServiceIface) HostUserNsInode() uint64 {
 	ret := _m.Called()
@@ -130,6 +183,20 @@ func (_m *HandlerServiceIface) HostUserNsInode() uint64 {
 	return r0
 }
 
+// HostUptime provides a mock function with given fields:
+func (_m *HandlerServiceIface) HostUptime() bool {
+	ret := _m.Called()
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func() bool); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
 // IOService provides a mock function with given fields:
 func (_m *HandlerServiceIface) IOService() domain.IOServiceIface {
 	ret := _m.Called()
@@ -215,6 +282,62 @@ func (_m *HandlerServiceIface) ProcessService() domain.ProcessServiceIface {
 	return r0
 }
 
+// CheckPathLimits provides a mock function with given fields: path
+func (_m *HandlerServiceIface) CheckPathLimits(path string) syscall.Errno {
+	ret := _m.Called(path)
+
+	var r0 syscall.Errno
+	if rf, ok := ret.Get(0).(func(string) syscall.Errno); ok {
+		r0 = rf(path)
+	} else {
+		r0 = ret.Get(0).(syscall.Errno)
+	}
+
+	return r0
+}
+
+// PathLimits provides a mock function with given fields:
+func (_m *HandlerServiceIface) PathLimits() (int, int) {
+	ret := _m.Called()
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func() int); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 int
+	if rf, ok := ret.Get(1).(func() int); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	return r0, r1
+}
+
+// PushRetries provides a mock function with given fields:
+func (_m *HandlerServiceIface) PushRetries() (int, time.Duration) {
+	ret := _m.Called()
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func() int); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 time.Duration
+	if rf, ok := ret.Get(1).(func() time.Duration); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Get(1).(time.Duration)
+	}
+
+	return r0, r1
+}
+
 // RegisterHandler provides a mock function with given fields: h
 func (_m *HandlerServiceIface) RegisterHandler(h domain.HandlerIface) error {
 	ret := _m.Called(h)
@@ -229,6 +352,65 @@ func (_m *HandlerServiceIface) RegisterHandler(h domain.HandlerIface) error {
 	return r0
 }
 
+// Reload provides a mock function with given fields: config
+func (_m *HandlerServiceIface) Reload(config map[string]domain.HandlerConfig) error {
+	ret := _m.Called(config)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(map[string]domain.HandlerConfig) error); ok {
+		r0 = rf(config)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetAuditor provides a mock function with given fields: a
+func (_m *HandlerServiceIface) SetAuditor(a domain.HandlerAuditor) {
+	_m.Called(a)
+}
+
+// SetAuthorizer provides a mock function with given fields: a
+func (_m *HandlerServiceIface) SetAuthorizer(a domain.HandlerAuthorizer) {
+	_m.Called(a)
+}
+
+// SetCacheWarmup provides a mock function with given fields: paths
+func (_m *HandlerServiceIface) SetCacheWarmup(paths []string) {
+	_m.Called(paths)
+}
+
+// SetHostUptime provides a mock function with given fields: val
+func (_m *HandlerServiceIface) SetHostUptime(val bool) {
+	_m.Called(val)
+}
+
+// SetLookupCacheSize provides a mock function with given fields: size
+func (_m *HandlerServiceIface) SetLookupCacheSize(size int) {
+	_m.Called(size)
+}
+
+// SetPathLimits provides a mock function with given fields: maxLen, maxDepth
+func (_m *HandlerServiceIface) SetPathLimits(maxLen int, maxDepth int) {
+	_m.Called(maxLen, maxDepth)
+}
+
+// SetPushRetries provides a mock function with given fields: retries, backoff
+func (_m *HandlerServiceIface) SetPushRetries(retries int, backoff time.Duration) {
+	_m.Called(retries, backoff)
+}
+
+// SetSingleInstance provides a mock function with given fields: val
+func (_m *HandlerServiceIface) SetSingleInstance(val bool) {
+	_m.Called(val)
+}
+
+// SetWriteCoalesceWindow provides a mock function with given fields: d
+func (_m *HandlerServiceIface) SetWriteCoalesceWindow(d time.Duration) {
+	_m.Called(d)
+}
+
 // SetStateService provides a mock function with given fields: css
 func (_m *HandlerServiceIface) SetStateService(css domain.ContainerStateServiceIface) {
 	_m.Called(css)
@@ -239,6 +421,20 @@ func (_m *HandlerServiceIface) Setup(hdlrs []domain.HandlerIface, ignoreErrors b
 	_m.Called(hdlrs, ignoreErrors, css, nss, prs, ios)
 }
 
+// SingleInstance provides a mock function with given fields:
+func (_m *HandlerServiceIface) SingleInstance() bool {
+	ret := _m.Called()
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func() bool); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
 // StateService provides a mock function with given fields:
 func (_m *HandlerServiceIface) StateService() domain.ContainerStateServiceIface {
 	ret := _m.Called()
@@ -255,6 +451,20 @@ func (_m *HandlerServiceIface) StateService() domain.ContainerStateServiceIface
 	return r0
 }
 
+// SysctlWrite provides a mock function with given fields: pid, sysctl, value
+func (_m *HandlerServiceIface) SysctlWrite(pid uint32, sysctl string, value []byte) syscall.Errno {
+	ret := _m.Called(pid, sysctl, value)
+
+	var r0 syscall.Errno
+	if rf, ok := ret.Get(0).(func(uint32, string, []byte) syscall.Errno); ok {
+		r0 = rf(pid, sysctl, value)
+	} else {
+		r0 = ret.Get(0).(syscall.Errno)
+	}
+
+	return r0
+}
+
 // UnregisterHandler provides a mock function with given fields: h
 func (_m *HandlerServiceIface) UnregisterHandler(h domain.HandlerIface) error {
 	ret := _m.Called(h)
@@ -268,3 +478,55 @@ func (_m *HandlerServiceIface) UnregisterHandler(h domain.HandlerIface) error {
 
 	return r0
 }
+
+// WarmupCache provides a mock function with given fields: cntr
+func (_m *HandlerServiceIface) WarmupCache(cntr domain.ContainerIface) {
+	_m.Called(cntr)
+}
+
+// WritePending provides a mock function with given fields: cntr, path, name
+func (_m *HandlerServiceIface) WritePending(cntr domain.ContainerIface, path string, name string) bool {
+	ret := _m.Called(cntr, path, name)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(domain.ContainerIface, string, string) bool); ok {
+		r0 = rf(cntr, path, name)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
+// CoalesceWrite provides a mock function with given fields: cntr, path, name, val, push
+func (_m *HandlerServiceIface) CoalesceWrite(cntr domain.ContainerIface, path string, name string, val string, push func(string) error) bool {
+	ret := _m.Called(cntr, path, name, val, push)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(domain.ContainerIface, string, string, string, func(string) error) bool); ok {
+		r0 = rf(cntr, path, name, val, push)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
+// CancelWrites provides a mock function with given fields: cntr
+func (_m *HandlerServiceIface) CancelWrites(cntr domain.ContainerIface) {
+	_m.Called(cntr)
+}
+
+// WriteCoalesceWindow provides a mock function with given fields:
+func (_m *HandlerServiceIface) WriteCoalesceWindow() time.Duration {
+	ret := _m.Called()
+
+	var r0 time.Duration
+	if rf, ok := ret.Get(0).(func() time.Duration); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(time.Duration)
+	}
+
+	return r0
+}
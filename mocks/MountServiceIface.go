@@ -12,6 +12,20 @@ type MountServiceIface struct {
 	mock.Mock
 }
 
+// IsIDMappedMount provides a mock function with given fields: c, path
+func (_m *MountServiceIface) IsIDMappedMount(c domain.ContainerIface, path string) bool {
+	ret := _m.Called(c, path)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(domain.ContainerIface, string) bool); ok {
+		r0 = rf(c, path)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
 // MountHelper provides a mock function with given fields:
 func (_m *MountServiceIface) MountHelper() domain.MountHelperIface {
 	ret := _m.Called()
@@ -26,6 +26,20 @@ func (_m *NSenterServiceIface) GetEventProcessID(e domain.NSenterEventIface) uin
 	return r0
 }
 
+// InflightEvents provides a mock function with given fields:
+func (_m *NSenterServiceIface) InflightEvents() int {
+	ret := _m.Called()
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func() int); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	return r0
+}
+
 // NewEvent provides a mock function with given fields: pid, ns, req, res, async
 func (_m *NSenterServiceIface) NewEvent(pid uint32, ns *[]string, req *domain.NSenterMessage, res *domain.NSenterMessage, async bool) domain.NSenterEventIface {
 	ret := _m.Called(pid, ns, req, res, async)
@@ -72,9 +86,44 @@ func (_m *NSenterServiceIface) SendRequestEvent(e domain.NSenterEventIface) erro
 	return r0
 }
 
-// Setup provides a mock function with given fields: prs, mts
-func (_m *NSenterServiceIface) Setup(prs domain.ProcessServiceIface, mts domain.MountServiceIface) {
-	_m.Called(prs, mts)
+// SetReadDirMaxEntries provides a mock function with given fields: n
+func (_m *NSenterServiceIface) SetReadDirMaxEntries(n int) {
+	_m.Called(n)
+}
+
+// Setup provides a mock function with given fields: prs, mts, agentPath
+func (_m *NSenterServiceIface) Setup(prs domain.ProcessServiceIface, mts domain.MountServiceIface, agentPath string) error {
+	ret := _m.Called(prs, mts, agentPath)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(domain.ProcessServiceIface, domain.MountServiceIface, string) error); ok {
+		r0 = rf(prs, mts, agentPath)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Shutdown provides a mock function with given fields:
+func (_m *NSenterServiceIface) Shutdown() {
+	_m.Called()
+}
+
+// Stats provides a mock function with given fields:
+func (_m *NSenterServiceIface) Stats() map[string]domain.NSenterStats {
+	ret := _m.Called()
+
+	var r0 map[string]domain.NSenterStats
+	if rf, ok := ret.Get(0).(func() map[string]domain.NSenterStats); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]domain.NSenterStats)
+		}
+	}
+
+	return r0
 }
 
 // TerminateRequestEvent provides a mock function with given fields: e
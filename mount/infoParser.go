@@ -716,6 +716,20 @@ func (mi *mountInfoParser) IsRoMount(info *domain.MountInfo) bool {
 	return perMountFlags&unix.MS_RDONLY == unix.MS_RDONLY
 }
 
+// IsIDMappedMount checks if the passed mountpoint is currently present and tagged
+// as id-mapped (i.e. the ownership of its files is subject to the mount's own
+// id-mapping).
+func (mi *mountInfoParser) IsIDMappedMount(info *domain.MountInfo) bool {
+
+	if info == nil {
+		return false
+	}
+
+	_, ok := info.Options["idmapped"]
+
+	return ok
+}
+
 // IsRecursiveBindMount verifies if the passed mountinfo entry is a recursive
 // bind-mount.
 //
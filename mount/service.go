@@ -17,6 +17,8 @@
 package mount
 
 import (
+	"path/filepath"
+
 	"github.com/nestybox/sysbox-fs/domain"
 	"github.com/sirupsen/logrus"
 )
@@ -91,3 +93,29 @@ func (mts *MountService) NewMountHelper() domain.MountHelperIface {
 func (mts *MountService) MountHelper() domain.MountHelperIface {
 	return mts.mh
 }
+
+// IsIDMappedMount reports whether the given path, as seen by the container's
+// init process, is backed by an id-mapped mount.
+func (mts *MountService) IsIDMappedMount(cntr domain.ContainerIface, path string) bool {
+
+	process := cntr.InitProc()
+	if process == nil {
+		return false
+	}
+
+	mip, err := mts.NewMountInfoParser(cntr, process, true, true, false)
+	if err != nil {
+		logrus.Debugf("Could not parse mountinfo of container %v: %v", cntr.ID(), err)
+		return false
+	}
+
+	// The path is backed by the closest mountpoint amongst its ancestors.
+	for p := filepath.Clean(path); ; p = filepath.Dir(p) {
+		if info := mip.LookupByMountpoint(p); info != nil {
+			return mip.IsIDMappedMount(info)
+		}
+		if p == "/" || p == "." {
+			return false
+		}
+	}
+}
@@ -58,6 +58,42 @@ type pid struct {
 	PidFirstChild int `json:"pid_first"`
 }
 
+//
+// Error reported when the agent fails to enter the namespaces of the target
+// process (nsexec stage). As this happens before the request is handed over to
+// the agent, the request has not been acted upon and can be safely re-sent.
+// The failure is deemed transient (e.g. setns() racing with the container's
+// startup) as long as the target namespaces remain reachable; otherwise (e.g.
+// the process is gone or access is denied) retrying is pointless.
+//
+type nsEntryError struct {
+	pid       uint32
+	transient bool
+}
+
+func (e *nsEntryError) Error() string {
+	return fmt.Sprintf("nsenter agent failed to enter the namespaces of pid %d", e.pid)
+}
+
+//
+// Auxiliary function to build the nsEntryError of the event, checking whether
+// the namespaces to enter are still reachable.
+//
+func (e *NSenterEvent) nsEntryError() error {
+
+	var transient = true
+
+	for _, nstype := range *(e.Namespace) {
+		path := filepath.Join("/proc", strconv.Itoa(int(e.Pid)), "ns", nstype)
+		if _, err := os.Stat(path); err != nil {
+			transient = false
+			break
+		}
+	}
+
+	return &nsEntryError{pid: e.Pid, transient: transient}
+}
+
 //
 // NSenterEvent struct serves as a transport abstraction (envelope) to carry
 // all the potential messages that can be exchanged between sysbox-fs master
@@ -96,6 +132,9 @@ type NSenterEvent struct {
 	// Zombie Reaper (for left-over nsenter child processes)
 	reaper *zombieReaper
 
+	// Binary to re-exec as the nsenter agent
+	agentPath string
+
 	// Backpointer to Nsenter service
 	service *nsenterService
 }
@@ -151,10 +190,27 @@ func (e *NSenterEvent) processResponse(pipe io.Reader) error {
 	// remote-end. This second step is executed as part of a subsequent
 	// unmarshal instruction (see further below).
 	if err := json.NewDecoder(pipe).Decode(&nsenterMsg); err != nil {
+
+		// An error response is left in place in either case, so that the
+		// consumers of the event never come across a nil one. An empty
+		// response means that the agent went away without responding (e.g.
+		// killed or crashed).
+		if err == io.EOF {
+			logrus.Warnf("Empty nsenterMsg response received")
+			e.ResMsg = errorResponseMsg(syscall.ENODATA)
+			return nil
+		}
+
 		logrus.Warnf("Error decoding received nsenterMsg response: %s", err)
+		e.ResMsg = errorResponseMsg(syscall.EIO)
 		return fmt.Errorf("Error decoding received nsenterMsg response: %s", err)
 	}
 
+	if err := checkMsgVersion(&nsenterMsg); err != nil {
+		logrus.Warnf("Error processing received nsenterMsg response: %s", err)
+		return err
+	}
+
 	switch nsenterMsg.Type {
 
 	case domain.LookupResponse:
@@ -179,7 +235,28 @@ func (e *NSenterEvent) processResponse(pipe io.Reader) error {
 	case domain.OpenFileResponse:
 		logrus.Debug("Received nsenterEvent OpenResponse message.")
 
-		var p int
+		// File handle; agents not reporting it (null payload) produce a zero
+		// handle.
+		var p domain.FileHandle
+
+		if payload != nil {
+			err := json.Unmarshal(payload, &p)
+			if err != nil {
+				logrus.Error(err)
+				return err
+			}
+		}
+
+		e.ResMsg = &domain.NSenterMessage{
+			Type:    nsenterMsg.Type,
+			Payload: p,
+		}
+		break
+
+	case domain.FstatResponse:
+		logrus.Debug("Received nsenterEvent fstatResponse message.")
+
+		var p domain.FileInfo
 
 		if payload != nil {
 			err := json.Unmarshal(payload, &p)
@@ -214,16 +291,47 @@ func (e *NSenterEvent) processResponse(pipe io.Reader) error {
 		}
 		break
 
+	case domain.ReadFilesResponse:
+		logrus.Debug("Received nsenterEvent readFilesResponse message.")
+
+		var p map[string]string
+
+		if payload != nil {
+			err := json.Unmarshal(payload, &p)
+			if err != nil {
+				logrus.Error(err)
+				return err
+			}
+		}
+
+		e.ResMsg = &domain.NSenterMessage{
+			Type:    nsenterMsg.Type,
+			Payload: p,
+		}
+		break
+
 	case domain.WriteFileResponse:
 		logrus.Debug("Received nsenterEvent writeResponse message.")
 
+		// Number of bytes written; agents not reporting it (null payload) are
+		// assumed to have written the whole content.
+		var p int = -1
+
+		if payload != nil {
+			err := json.Unmarshal(payload, &p)
+			if err != nil {
+				logrus.Error(err)
+				return err
+			}
+		}
+
 		e.ResMsg = &domain.NSenterMessage{
 			Type:    nsenterMsg.Type,
-			Payload: "",
+			Payload: p,
 		}
 		break
 
-	case domain.ReadDirResponse:
+	case domain.ReadDirResponse, domain.ReadDirPartResponse:
 		logrus.Debug("Received nsenterEvent readDirAllResponse message.")
 
 		var p []domain.FileInfo
@@ -316,6 +424,24 @@ func (e *NSenterEvent) processResponse(pipe io.Reader) error {
 		}
 		break
 
+	case domain.AccessResponse:
+		logrus.Debug("Received nsenterEvent accessResponse message.")
+
+		e.ResMsg = &domain.NSenterMessage{
+			Type:    nsenterMsg.Type,
+			Payload: "",
+		}
+		break
+
+	case domain.RmdirResponse:
+		logrus.Debug("Received nsenterEvent rmdirResponse message.")
+
+		e.ResMsg = &domain.NSenterMessage{
+			Type:    nsenterMsg.Type,
+			Payload: "",
+		}
+		break
+
 	case domain.ErrorResponse:
 		logrus.Debug("Received nsenterEvent errorResponse message.")
 
@@ -329,6 +455,14 @@ func (e *NSenterEvent) processResponse(pipe io.Reader) error {
 			}
 		}
 
+		// Errors carrying no errno would be mistaken for successful operations
+		// by the consumers relying on the errno alone (e.g. seccomp ones).
+		if p.Code == 0 {
+			logrus.Warnf("Empty nsenterMsg error response received")
+			e.ResMsg = errorResponseMsg(syscall.EIO)
+			break
+		}
+
 		e.ResMsg = &domain.NSenterMessage{
 			Type:    nsenterMsg.Type,
 			Payload: p,
@@ -342,6 +476,20 @@ func (e *NSenterEvent) processResponse(pipe io.Reader) error {
 	return nil
 }
 
+//
+// Auxiliary function to verify that the received message has been encoded
+// with a protocol version supported by this binary. Messages with no version
+// are generated by older binaries speaking the initial protocol version.
+//
+func checkMsgVersion(m *domain.NSenterMessage) error {
+
+	if m.Version > domain.NSenterMsgVersion {
+		return &domain.NSenterVersionMismatch{Version: m.Version}
+	}
+
+	return nil
+}
+
 //
 // Auxiliary function to obtain the FS path associated to any given namespace.
 // Theese FS paths are utilized by sysbox-runc's nsexec logic to enter the
@@ -415,9 +563,16 @@ func (e *NSenterEvent) SendRequest() error {
 		Value: []byte(strings.Join(namespaces, ",")),
 	})
 
+	// Events not generated through the nsenter service fall back to the
+	// default agent.
+	agentPath := e.agentPath
+	if agentPath == "" {
+		agentPath = defaultAgentPath
+	}
+
 	// Prepare exec.cmd in charge of running: "sysbox-fs nsenter".
 	cmd := &exec.Cmd{
-		Path:        "/proc/self/exe",
+		Path:        agentPath,
 		Args:        []string{os.Args[0], "nsenter"},
 		ExtraFiles:  []*os.File{childPipe},
 		Env:         []string{"_LIBCONTAINER_INITPIPE=3", fmt.Sprintf("GOMAXPROCS=%s", os.Getenv("GOMAXPROCS"))},
@@ -431,7 +586,8 @@ func (e *NSenterEvent) SendRequest() error {
 	err = cmd.Start()
 	childPipe.Close()
 	if err != nil {
-		logrus.Errorf("Error launching sysbox-fs first child process: %s", err)
+		logrus.Errorf("Error launching sysbox-fs first child process (agent %v): %s",
+			agentPath, err)
 		return errors.New("Error launching sysbox-fs first child process")
 	}
 
@@ -452,7 +608,7 @@ func (e *NSenterEvent) SendRequest() error {
 	if !status.Success() {
 		logrus.Warnf("Sysbox-fs first child process error status: pid = %d", cmd.Process.Pid)
 		e.reaper.nsenterReapReq()
-		return errors.New("Error waiting for sysbox-fs first child process")
+		return e.nsEntryError()
 	}
 
 	// Receive sysbox-fs' first-child pid.
@@ -507,6 +663,7 @@ func (e *NSenterEvent) SendRequest() error {
 	}
 
 	// Transfer the rest of the payload
+	e.ReqMsg.Version = domain.NSenterMsgVersion
 	data, err := json.Marshal(*(e.ReqMsg))
 	if err != nil {
 		logrus.Warnf("Error while encoding nsenter payload (%v).", err)
@@ -545,9 +702,25 @@ func (e *NSenterEvent) SendRequest() error {
 
 func (e *NSenterEvent) ReceiveResponse() *domain.NSenterMessage {
 
+	// No response obtained (e.g. asynchronous or failed requests).
+	if e.ResMsg == nil {
+		logrus.Warnf("No nsenter response available for pid %d", e.Pid)
+		return errorResponseMsg(syscall.EIO)
+	}
+
 	return e.ResMsg
 }
 
+// errorResponseMsg builds the error response handed over to the consumers of
+// the events for which no (valid) response could be obtained from the agent.
+func errorResponseMsg(code syscall.Errno) *domain.NSenterMessage {
+
+	return &domain.NSenterMessage{
+		Type:    domain.ErrorResponse,
+		Payload: fuse.IOerror{Code: code, Message: code.Error()},
+	}
+}
+
 // TerminateRequest serves to unwind the nsenter-event FSM after the generation
 // of an asynchronous event. This method is not required for regular nsenter
 // events, as in those cases the SendRequest() method itself takes care of
@@ -613,7 +786,7 @@ func (e *NSenterEvent) processLookupRequest() error {
 		Fmode:    info.Mode(),
 		FmodTime: info.ModTime(),
 		FisDir:   info.IsDir(),
-		Fsys:     info.Sys().(*syscall.Stat_t),
+		Fsys:     statOf(info),
 	}
 
 	// Create a response message.
@@ -625,6 +798,22 @@ func (e *NSenterEvent) processLookupRequest() error {
 	return nil
 }
 
+//
+// Returns the stat struct underlying the passed file info, or nil if the
+// file-system backend doesn't provide one (consumers are expected to fall back
+// to the generic file info attributes in that case).
+//
+func statOf(info os.FileInfo) *syscall.Stat_t {
+
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		logrus.Warnf("Unexpected stat info type for %v: %T", info.Name(), info.Sys())
+		return nil
+	}
+
+	return st
+}
+
 //
 // Once a file has been opened with open(), no permission checking is performed
 // by subsequent system calls that work with the returned file descriptor (such
@@ -643,6 +832,15 @@ func (e *NSenterEvent) processOpenFileRequest() error {
 		}
 		return nil
 	}
+	// Reject any attempt to create new nodes (see comment below).
+	if domain.IsCreateOpenFlags(openFlags) {
+		e.ResMsg = &domain.NSenterMessage{
+			Type:    domain.ErrorResponse,
+			Payload: &fuse.IOerror{RcvError: syscall.EPERM},
+		}
+		return nil
+	}
+
 	// Extract openMode from the incoming payload.
 	mode, err := strconv.Atoi(payload.Mode)
 	if err != nil {
@@ -653,10 +851,10 @@ func (e *NSenterEvent) processOpenFileRequest() error {
 		return nil
 	}
 
-	// Open the file in question. Notice that we are hardcoding the 'mode'
-	// argument (third one) as this one is not relevant in a procfs; that
-	// is, user cannot create files -- openflags 'O_CREAT' and 'O_TMPFILE'
-	// are not expected (refer to "man open(2)" for details).
+	// Open the file in question. Notice that the 'mode' argument (third one)
+	// is not relevant in a procfs; that is, user cannot create files --
+	// openflags 'O_CREAT', 'O_EXCL' and 'O_TMPFILE' have been rejected above
+	// (refer to "man open(2)" for details).
 	fd, err := os.OpenFile(payload.File, openFlags, os.FileMode(mode))
 	if err != nil {
 		e.ResMsg = &domain.NSenterMessage{
@@ -665,12 +863,98 @@ func (e *NSenterEvent) processOpenFileRequest() error {
 		}
 		return nil
 	}
-	fd.Close()
+	defer fd.Close()
+
+	info, err := fd.Stat()
+	if err != nil {
+		e.ResMsg = &domain.NSenterMessage{
+			Type:    domain.ErrorResponse,
+			Payload: &fuse.IOerror{RcvError: err},
+		}
+		return nil
+	}
+	// The file identity can't be recorded (nor verified) without a stat struct.
+	st := statOf(info)
+	if st == nil {
+		e.ResMsg = &domain.NSenterMessage{
+			Type:    domain.ErrorResponse,
+			Payload: &fuse.IOerror{RcvError: syscall.EIO},
+		}
+		return nil
+	}
 
 	// Create a response message.
 	e.ResMsg = &domain.NSenterMessage{
-		Type:    domain.OpenFileResponse,
-		Payload: nil,
+		Type: domain.OpenFileResponse,
+		Payload: domain.FileHandle{
+			File: payload.File,
+			Dev:  uint64(st.Dev),
+			Ino:  uint64(st.Ino),
+		},
+	}
+
+	return nil
+}
+
+//
+// Refreshes the attributes of a file previously opened through an
+// OpenFileRequest. The file is re-opened with O_PATH (no permission over the
+// file content is required to fstat() it) and its identity is matched against
+// the one recorded in the handle, so that attributes of a different file are
+// never reported.
+//
+func (e *NSenterEvent) processFstatRequest() error {
+
+	payload := e.ReqMsg.Payload.(domain.FstatPayload)
+	handle := payload.Handle
+
+	fd, err := os.OpenFile(handle.File, unix.O_PATH, 0)
+	if err != nil {
+		e.ResMsg = &domain.NSenterMessage{
+			Type:    domain.ErrorResponse,
+			Payload: &fuse.IOerror{RcvError: err},
+		}
+		return nil
+	}
+	defer fd.Close()
+
+	info, err := fd.Stat()
+	if err != nil {
+		e.ResMsg = &domain.NSenterMessage{
+			Type:    domain.ErrorResponse,
+			Payload: &fuse.IOerror{RcvError: err},
+		}
+		return nil
+	}
+	// The file identity can't be recorded (nor verified) without a stat struct.
+	st := statOf(info)
+	if st == nil {
+		e.ResMsg = &domain.NSenterMessage{
+			Type:    domain.ErrorResponse,
+			Payload: &fuse.IOerror{RcvError: syscall.EIO},
+		}
+		return nil
+	}
+
+	if uint64(st.Dev) != handle.Dev || uint64(st.Ino) != handle.Ino {
+		e.ResMsg = &domain.NSenterMessage{
+			Type:    domain.ErrorResponse,
+			Payload: &fuse.IOerror{RcvError: syscall.ESTALE},
+		}
+		return nil
+	}
+
+	// Create a response message.
+	e.ResMsg = &domain.NSenterMessage{
+		Type: domain.FstatResponse,
+		Payload: domain.FileInfo{
+			Fname:    info.Name(),
+			Fsize:    info.Size(),
+			Fmode:    info.Mode(),
+			FmodTime: info.ModTime(),
+			FisDir:   info.IsDir(),
+			Fsys:     st,
+		},
 	}
 
 	return nil
@@ -681,7 +965,7 @@ func (e *NSenterEvent) processFileReadRequest() error {
 	payload := e.ReqMsg.Payload.(domain.ReadFilePayload)
 
 	// Perform read operation and return error msg should this one fail.
-	fileContent, err := ioutil.ReadFile(payload.File)
+	fileContent, err := readFile(payload.File)
 	if err != nil {
 		e.ResMsg = &domain.NSenterMessage{
 			Type:    domain.ErrorResponse,
@@ -690,21 +974,116 @@ func (e *NSenterEvent) processFileReadRequest() error {
 		return nil
 	}
 
+	content := string(fileContent)
+	if !payload.Raw {
+		content = strings.TrimSpace(content)
+	}
+
 	// Create a response message.
 	e.ResMsg = &domain.NSenterMessage{
 		Type:    domain.ReadFileResponse,
-		Payload: strings.TrimSpace(string(fileContent)),
+		Payload: content,
 	}
 
 	return nil
 }
 
+func (e *NSenterEvent) processFilesReadRequest() error {
+
+	payload := e.ReqMsg.Payload.(domain.ReadFilesPayload)
+
+	// Files that can't be read are simply skipped; it's up to the requester to
+	// figure out what to do with the missing ones.
+	contents := make(map[string]string, len(payload.Files))
+	for _, f := range payload.Files {
+		fileContent, err := readFile(f.File)
+		if err != nil {
+			logrus.Debugf("Skipping file %v: %v", f.File, err)
+			continue
+		}
+
+		content := string(fileContent)
+		if !f.Raw {
+			content = strings.TrimSpace(content)
+		}
+		contents[f.File] = content
+	}
+
+	// Create a response message.
+	e.ResMsg = &domain.NSenterMessage{
+		Type:    domain.ReadFilesResponse,
+		Payload: contents,
+	}
+
+	return nil
+}
+
+// Reads the whole content of the given file. Unlike ioutil.ReadFile(), the
+// buffer is not sized based on the file's reported size, which is meaningless
+// (or stale) for most /proc pseudo-files (see readAll()).
+func readFile(path string) ([]byte, error) {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := readAll(f)
+	if err != nil {
+		return nil, &os.PathError{Op: "read", Path: path, Err: err}
+	}
+
+	return data, nil
+}
+
+// Maximum number of consecutive reads returning no data (either interrupted or
+// empty ones) before giving up on a reader.
+const readAllMaxEmptyReads = 100
+
+// Reads from the given reader until EOF. Short reads and resources growing (or
+// shrinking) as they are being read are expected for volatile /proc files, so
+// interrupted reads are simply retried and content is accumulated for as long
+// as the reader keeps providing it.
+func readAll(r io.Reader) ([]byte, error) {
+
+	var (
+		data  []byte
+		buf   = make([]byte, 4096)
+		empty int
+	)
+
+	for {
+		n, err := r.Read(buf)
+		data = append(data, buf[:n]...)
+
+		if err == io.EOF {
+			return data, nil
+		}
+		if err != nil &&
+			!errors.Is(err, syscall.EINTR) && !errors.Is(err, syscall.EAGAIN) {
+			return nil, err
+		}
+
+		if n > 0 {
+			empty = 0
+			continue
+		}
+		if empty++; empty >= readAllMaxEmptyReads {
+			return nil, io.ErrNoProgress
+		}
+	}
+}
+
 func (e *NSenterEvent) processFileWriteRequest() error {
 
 	payload := e.ReqMsg.Payload.(domain.WriteFilePayload)
 
-	// Perform write operation and return error msg should this one fail.
-	err := ioutil.WriteFile(payload.File, []byte(payload.Content), 0644)
+	// Perform write operation and return error msg should this one fail. A
+	// single write() is issued, as procfs / sysfs files may legitimately
+	// consume just part of the content (short write), which is reported back
+	// as such.
+	n, err := writeFile(payload.File, []byte(payload.Content))
 	if err != nil {
 		e.ResMsg = &domain.NSenterMessage{
 			Type:    domain.ErrorResponse,
@@ -713,15 +1092,37 @@ func (e *NSenterEvent) processFileWriteRequest() error {
 		return nil
 	}
 
-	// Create a response message.
+	// Create a response message carrying the number of bytes written.
 	e.ResMsg = &domain.NSenterMessage{
 		Type:    domain.WriteFileResponse,
-		Payload: nil,
+		Payload: n,
 	}
 
 	return nil
 }
 
+// writeFile writes the passed data into the given file through a single write()
+// syscall, and returns the number of bytes written.
+func writeFile(path string, data []byte) (int, error) {
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := syscall.Write(int(f.Fd()), data)
+	if err != nil {
+		f.Close()
+		return 0, &os.PathError{Op: "write", Path: path, Err: err}
+	}
+
+	if err := f.Close(); err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
 func (e *NSenterEvent) processDirReadRequest() error {
 
 	payload := e.ReqMsg.Payload.(domain.ReadDirPayload)
@@ -736,6 +1137,20 @@ func (e *NSenterEvent) processDirReadRequest() error {
 		return nil
 	}
 
+	// Serve the requested page only; entries are sorted by name, so offsets
+	// remain meaningful across requests.
+	respType := domain.ReadDirResponse
+
+	if payload.Offset >= len(dirContent) {
+		dirContent = nil
+	} else if payload.Offset > 0 {
+		dirContent = dirContent[payload.Offset:]
+	}
+	if payload.MaxEntries > 0 && len(dirContent) > payload.MaxEntries {
+		dirContent = dirContent[:payload.MaxEntries]
+		respType = domain.ReadDirPartResponse
+	}
+
 	// Create a FileInfo slice to return to sysbox-fs' main instance.
 	var dirContentList []domain.FileInfo
 
@@ -746,20 +1161,44 @@ func (e *NSenterEvent) processDirReadRequest() error {
 			Fmode:    entry.Mode(),
 			FmodTime: entry.ModTime(),
 			FisDir:   entry.IsDir(),
-			Fsys:     entry.Sys().(*syscall.Stat_t),
+			Fsys:     statOf(entry),
 		}
 		dirContentList = append(dirContentList, elem)
 	}
 
 	// Create a response message.
 	e.ResMsg = &domain.NSenterMessage{
-		Type:    domain.ReadDirResponse,
+		Type:    respType,
 		Payload: dirContentList,
 	}
 
 	return nil
 }
 
+func (e *NSenterEvent) processRmdirRequest() error {
+
+	payload := e.ReqMsg.Payload.(domain.RmdirPayload)
+
+	// Rely on rmdir() rather than os.Remove(), as the latter would happily
+	// unlink a regular file found at the given path.
+	err := syscall.Rmdir(payload.Dir)
+	if err != nil {
+		e.ResMsg = &domain.NSenterMessage{
+			Type:    domain.ErrorResponse,
+			Payload: &fuse.IOerror{RcvError: err},
+		}
+		return nil
+	}
+
+	// Create a response message.
+	e.ResMsg = &domain.NSenterMessage{
+		Type:    domain.RmdirResponse,
+		Payload: nil,
+	}
+
+	return nil
+}
+
 func (e *NSenterEvent) processMountSyscallRequest() error {
 
 	var (
@@ -1056,6 +1495,91 @@ func (e *NSenterEvent) processSleepRequest() error {
 	return nil
 }
 
+func (e *NSenterEvent) processAccessRequest() error {
+
+	payload := e.ReqMsg.Payload.(domain.AccessReqPayload)
+	header := payload.Header
+
+	// Create a dummy 'process' struct to represent the 'sysbox-fs nsenter'
+	// process executing this logic.
+	this := e.service.prs.ProcessCreate(0, 0, 0)
+
+	// Adjust 'nsenter' process personality to match the end-user's original
+	// process, so that the kernel can check the access on its behalf.
+	if err := this.AdjustPersonality(
+		header.Uid,
+		header.Gid,
+		header.Root,
+		header.Cwd,
+		header.Capabilities); err != nil {
+
+		e.ResMsg = &domain.NSenterMessage{
+			Type:    domain.ErrorResponse,
+			Payload: &fuse.IOerror{RcvError: err},
+		}
+		return nil
+	}
+
+	process := e.service.prs.ProcessCreate(e.Pid, 0, 0)
+
+	path, err := process.ResolveProcSelf(payload.Path)
+	if err != nil {
+		e.ResMsg = &domain.NSenterMessage{
+			Type:    domain.ErrorResponse,
+			Payload: &fuse.IOerror{RcvError: syscall.EINVAL},
+		}
+		return nil
+	}
+
+	if err := accessCheck(path, payload.Mode); err != nil {
+		e.ResMsg = &domain.NSenterMessage{
+			Type:    domain.ErrorResponse,
+			Payload: &fuse.IOerror{RcvError: err},
+		}
+		return nil
+	}
+
+	e.ResMsg = &domain.NSenterMessage{
+		Type:    domain.AccessResponse,
+		Payload: "",
+	}
+
+	return nil
+}
+
+// accessCheck obtains the kernel's verdict on whether the running process can
+// access the given path with the given mode. Notice that the effective ids are
+// the ones being checked (AT_EACCESS), as those are the ones being adjusted to
+// match the original process' personality.
+func accessCheck(path string, mode domain.AccessMode) error {
+	return unix.Faccessat(unix.AT_FDCWD, path, uint32(mode), unix.AT_EACCESS)
+}
+
+// setCreds adjusts the supplementary groups and the effective gid / uid of the
+// running agent to the passed ones. The uid goes last, as the agent may lose
+// the privileges required for the other changes along with it. Notice that
+// these syscalls act on the calling thread only, which is fine as the agent
+// runs locked to its main thread (see init()).
+func setCreds(c *domain.NSenterCreds) error {
+
+	groups := make([]int, len(c.Groups))
+	for i, g := range c.Groups {
+		groups[i] = int(g)
+	}
+
+	if err := unix.Setgroups(groups); err != nil {
+		return err
+	}
+	if err := unix.Setresgid(-1, int(c.Gid), -1); err != nil {
+		return err
+	}
+	if err := unix.Setresuid(-1, int(c.Uid), -1); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // Method in charge of processing all requests generated by sysbox-fs' master
 // instance.
 func (e *NSenterEvent) processRequest(pipe *os.File) error {
@@ -1065,6 +1589,13 @@ func (e *NSenterEvent) processRequest(pipe *os.File) error {
 		return err
 	}
 
+	return e.processRequestMsg(pipe)
+}
+
+// Decodes the request message read from the passed reader and dispatches it
+// to the corresponding request handler.
+func (e *NSenterEvent) processRequestMsg(pipe io.Reader) error {
+
 	// Raw message payload to aid in decoding generic messages (see below
 	// explanation).
 	var payload json.RawMessage
@@ -1083,6 +1614,26 @@ func (e *NSenterEvent) processRequest(pipe *os.File) error {
 		return errors.New("Error decoding received event request.")
 	}
 
+	// Refrain from decoding payloads of unknown protocol versions; the message
+	// layout may have changed in ways that we can't possibly anticipate.
+	if err := checkMsgVersion(&nsenterMsg); err != nil {
+		logrus.Warnf("Error processing received nsenterMsg request (%v).", err)
+		return err
+	}
+
+	// Impersonate the original caller if so requested. Failing to do so is
+	// reported back as the outcome of the request, as carrying it out with
+	// the agent's own credentials could bypass the caller's permissions.
+	if nsenterMsg.Creds != nil {
+		if err := setCreds(nsenterMsg.Creds); err != nil {
+			e.ResMsg = &domain.NSenterMessage{
+				Type:    domain.ErrorResponse,
+				Payload: &fuse.IOerror{RcvError: err},
+			}
+			return nil
+		}
+	}
+
 	switch nsenterMsg.Type {
 
 	case domain.LookupRequest:
@@ -1117,6 +1668,22 @@ func (e *NSenterEvent) processRequest(pipe *os.File) error {
 		}
 		return e.processOpenFileRequest()
 
+	case domain.FstatRequest:
+		var p domain.FstatPayload
+		if payload != nil {
+			err := json.Unmarshal(payload, &p)
+			if err != nil {
+				logrus.Error(err)
+				return err
+			}
+		}
+
+		e.ReqMsg = &domain.NSenterMessage{
+			Type:    nsenterMsg.Type,
+			Payload: p,
+		}
+		return e.processFstatRequest()
+
 	case domain.ReadFileRequest:
 		var p domain.ReadFilePayload
 		if payload != nil {
@@ -1133,6 +1700,22 @@ func (e *NSenterEvent) processRequest(pipe *os.File) error {
 		}
 		return e.processFileReadRequest()
 
+	case domain.ReadFilesRequest:
+		var p domain.ReadFilesPayload
+		if payload != nil {
+			err := json.Unmarshal(payload, &p)
+			if err != nil {
+				logrus.Error(err)
+				return err
+			}
+		}
+
+		e.ReqMsg = &domain.NSenterMessage{
+			Type:    nsenterMsg.Type,
+			Payload: p,
+		}
+		return e.processFilesReadRequest()
+
 	case domain.WriteFileRequest:
 		var p domain.WriteFilePayload
 		if payload != nil {
@@ -1165,6 +1748,22 @@ func (e *NSenterEvent) processRequest(pipe *os.File) error {
 		}
 		return e.processDirReadRequest()
 
+	case domain.RmdirRequest:
+		var p domain.RmdirPayload
+		if payload != nil {
+			err := json.Unmarshal(payload, &p)
+			if err != nil {
+				logrus.Error(err)
+				return err
+			}
+		}
+
+		e.ReqMsg = &domain.NSenterMessage{
+			Type:    nsenterMsg.Type,
+			Payload: p,
+		}
+		return e.processRmdirRequest()
+
 	// case domain.SetAttrRequest:
 	// 	var p domain.SetAttrPayload
 	// 	if payload != nil {
@@ -1273,6 +1872,23 @@ func (e *NSenterEvent) processRequest(pipe *os.File) error {
 
 		return e.processSleepRequest()
 
+	case domain.AccessRequest:
+		var p domain.AccessReqPayload
+		if payload != nil {
+			err := json.Unmarshal(payload, &p)
+			if err != nil {
+				logrus.Error(err)
+				return err
+			}
+		}
+
+		e.ReqMsg = &domain.NSenterMessage{
+			Type:    nsenterMsg.Type,
+			Payload: p,
+		}
+
+		return e.processAccessRequest()
+
 	default:
 		e.ResMsg = &domain.NSenterMessage{
 			Type:    domain.ErrorResponse,
@@ -1294,6 +1910,12 @@ func Init() (err error) {
 		envInitPipe = os.Getenv("_LIBCONTAINER_INITPIPE")
 	)
 
+	// Agent validation probe (see agentCheck()): just identify ourselves.
+	if os.Getenv(agentProbeEnv) != "" {
+		_, err = fmt.Fprintln(os.Stdout, agentProbeReply())
+		return err
+	}
+
 	// Get the INITPIPE.
 	pipefd, err = strconv.Atoi(envInitPipe)
 	if err != nil {
@@ -1312,25 +1934,52 @@ func Init() (err error) {
 	var nsenterSvc = NewNSenterService()
 	var processSvc = process.NewProcessService()
 	var mountSvc = mount.NewMountService()
-	nsenterSvc.Setup(processSvc, mountSvc)
+	// The agent never launches agents of its own, so the agent-path validation
+	// carried out by Setup() is skipped.
+	nsenterSvc.(*nsenterService).setup(processSvc, mountSvc)
 	mountSvc.Setup(nil, nil, processSvc, nsenterSvc)
 
 	var event = NSenterEvent{service: nsenterSvc.(*nsenterService)}
 
-	// Process incoming request.
-	err = event.processRequest(pipe)
+	// Process incoming request and push the response back to sysbox-main.
+	return event.sendResponse(pipe, event.processRequest(pipe))
+}
+
+//
+// Encodes and pushes the response of the processed request back to the
+// remote-end. Processing errors (e.g. requests that couldn't be decoded) are
+// reported through an ErrorResponse message, and so are the ones hit while
+// encoding the response itself, so that the remote-end always gets a
+// well-formed message instead of an empty pipe.
+//
+func (e *NSenterEvent) sendResponse(pipe io.Writer, err error) error {
+
+	if err == nil && e.ResMsg == nil {
+		err = errors.New("Empty nsenterMsg response.")
+	}
 	if err != nil {
-		event.ResMsg = &domain.NSenterMessage{
+		e.ResMsg = &domain.NSenterMessage{
 			Type:    domain.ErrorResponse,
 			Payload: &fuse.IOerror{RcvError: err},
 		}
 	}
 
-	// Encode / push response back to sysbox-main.
-	data, err := json.Marshal(*(event.ResMsg))
+	e.ResMsg.Version = domain.NSenterMsgVersion
+	data, err := json.Marshal(*(e.ResMsg))
 	if err != nil {
-		return err
+		logrus.Warnf("Error encoding nsenterMsg response (%v).", err)
+
+		e.ResMsg = &domain.NSenterMessage{
+			Version: domain.NSenterMsgVersion,
+			Type:    domain.ErrorResponse,
+			Payload: &fuse.IOerror{RcvError: err},
+		}
+		data, err = json.Marshal(*(e.ResMsg))
+		if err != nil {
+			return err
+		}
 	}
+
 	_, err = pipe.Write(data)
 	if err != nil {
 		return err
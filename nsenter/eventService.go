@@ -17,22 +17,91 @@
 package nsenter
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
 	"github.com/nestybox/sysbox-fs/domain"
 )
 
+// Binary re-exec'ed by default to launch the nsenter agent (i.e. sysbox-fs
+// itself).
+var defaultAgentPath = "/proc/self/exe"
+
+// Environment variable asking an agent launched as "<path> nsenter" to merely
+// identify itself (see agentCheck()), and maximum time it's given to do so.
+const agentProbeEnv = "_SYSBOXFS_NSENTER_PROBE"
+
+var agentProbeTimeout = 5 * time.Second
+
+// Retry settings for requests failing due to transient namespace-entry errors
+// (e.g. setns() racing with the container's startup). Failures reported by the
+// request's operation itself are never retried, as these may not be
+// idempotent. The delay is doubled after every attempt.
+var (
+	transientRetries    = 3
+	transientRetryDelay = 5 * time.Millisecond
+)
+
+// Default maximum number of entries carried by each ReadDir response; larger
+// directories are served over multiple (paginated) requests.
+var defaultReadDirMaxEntries = 4096
+
+// Maximum amount of time to wait for ongoing (synchronous) requests to complete
+// during the service shutdown.
+var shutdownTimeout = 5 * time.Second
+
 type nsenterService struct {
-	prs    domain.ProcessServiceIface // for process class interactions (capabilities)
-	mts    domain.MountServiceIface   // for mount class interactions (mountInfoParser)
-	reaper *zombieReaper
+	prs       domain.ProcessServiceIface // for process class interactions (capabilities)
+	mts       domain.MountServiceIface   // for mount class interactions (mountInfoParser)
+	reaper    *zombieReaper
+	stats     *eventStats // round-trip accounting per request type
+	agentPath string      // binary to re-exec as "sysbox-fs nsenter"
+
+	// Cap on the entries per ReadDir response (zero means no limit).
+	readDirMaxEntries int
+
+	// Requests dispatched and not completed yet. Asynchronous ones remain here
+	// till explicitly terminated.
+	mu           sync.Mutex
+	inflight     map[domain.NSenterEventIface]struct{}
+	shuttingDown bool
 }
 
 func NewNSenterService() domain.NSenterServiceIface {
 	return &nsenterService{
-		reaper: newZombieReaper(),
+		reaper:            newZombieReaper(),
+		stats:             newEventStats(),
+		inflight:          make(map[domain.NSenterEventIface]struct{}),
+		readDirMaxEntries: defaultReadDirMaxEntries,
 	}
 }
 
 func (s *nsenterService) Setup(
+	prs domain.ProcessServiceIface,
+	mts domain.MountServiceIface,
+	agentPath string) error {
+
+	s.setup(prs, mts)
+
+	path, err := agentLookup(agentPath)
+	if err != nil {
+		return err
+	}
+	s.agentPath = path
+
+	return nil
+}
+
+// setup wires the service's dependencies, leaving the agent path untouched.
+func (s *nsenterService) setup(
 	prs domain.ProcessServiceIface,
 	mts domain.MountServiceIface) {
 
@@ -47,6 +116,14 @@ func (s *nsenterService) NewEvent(
 	res *domain.NSenterMessage,
 	async bool) domain.NSenterEventIface {
 
+	// Bound the size of the ReadDir responses, unless the caller has explicitly
+	// requested a different page size.
+	if req != nil && req.Type == domain.ReadDirRequest {
+		if p, ok := req.Payload.(*domain.ReadDirPayload); ok && p.MaxEntries == 0 {
+			p.MaxEntries = s.readDirMaxEntries
+		}
+	}
+
 	event := &NSenterEvent{
 		Pid:       pid,
 		Namespace: ns,
@@ -54,6 +131,7 @@ func (s *nsenterService) NewEvent(
 		ResMsg:    res,
 		Async:     async,
 		reaper:    s.reaper,
+		agentPath: s.agentPath,
 	}
 
 	return event
@@ -61,10 +139,42 @@ func (s *nsenterService) NewEvent(
 
 func (s *nsenterService) SendRequestEvent(
 	e domain.NSenterEventIface) error {
-	return e.SendRequest()
+
+	if err := s.trackEvent(e); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	err := e.SendRequest()
+
+	delay := transientRetryDelay
+	for i := 0; i < transientRetries && isTransient(err); i++ {
+		logrus.Debugf("Transient nsenter failure (%v), retrying in %v", err, delay)
+		time.Sleep(delay)
+		delay *= 2
+
+		e.SetResponseMsg(nil)
+		err = e.SendRequest()
+	}
+
+	// Synchronous requests carry the response by now (if any). Async ones are
+	// accounted for based on the dispatching outcome only.
+	var reqType domain.NSenterMsgType
+	if req := e.GetRequestMsg(); req != nil {
+		reqType = req.Type
+	}
+	s.stats.record(reqType, time.Since(start), e.GetResponseMsg(), err)
+
+	if err != nil || !isAsync(e) {
+		s.untrackEvent(e)
+	}
+
+	return err
 }
 
 func (s *nsenterService) TerminateRequestEvent(e domain.NSenterEventIface) error {
+	defer s.untrackEvent(e)
+
 	return e.TerminateRequest()
 }
 
@@ -103,3 +213,166 @@ func (s *nsenterService) GetResponseEventPayload(
 func (s *nsenterService) GetEventProcessID(e domain.NSenterEventIface) uint32 {
 	return e.GetProcessID()
 }
+
+// SetReadDirMaxEntries sets the maximum number of entries to carry in each
+// ReadDir response. A zero value disables the limit.
+func (s *nsenterService) SetReadDirMaxEntries(n int) {
+	if n < 0 {
+		n = 0
+	}
+	s.readDirMaxEntries = n
+}
+
+// Stats returns the accounting of the nsenter round-trips dispatched so far,
+// indexed by request type.
+func (s *nsenterService) Stats() map[domain.NSenterMsgType]domain.NSenterStats {
+	return s.stats.snapshot()
+}
+
+// InflightEvents returns the number of requests dispatched and not completed
+// yet.
+func (s *nsenterService) InflightEvents() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.inflight)
+}
+
+// Shutdown drains the service: new requests are rejected from now on, the
+// asynchronous ones still running are terminated, and the synchronous ones are
+// given some time (shutdownTimeout) to complete.
+func (s *nsenterService) Shutdown() {
+
+	s.mu.Lock()
+	s.shuttingDown = true
+	var async []domain.NSenterEventIface
+	for e := range s.inflight {
+		if isAsync(e) {
+			async = append(async, e)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, e := range async {
+		if err := s.TerminateRequestEvent(e); err != nil {
+			logrus.Warnf("Error terminating nsenter request during shutdown: %v", err)
+		}
+	}
+
+	deadline := time.Now().Add(shutdownTimeout)
+	for s.InflightEvents() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if n := s.InflightEvents(); n > 0 {
+		logrus.Warnf("nsenter service shutdown with %d requests in-flight", n)
+	}
+}
+
+func (s *nsenterService) trackEvent(e domain.NSenterEventIface) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.shuttingDown {
+		return errors.New("nsenter service shutting down")
+	}
+	s.inflight[e] = struct{}{}
+
+	return nil
+}
+
+func (s *nsenterService) untrackEvent(e domain.NSenterEventIface) {
+	s.mu.Lock()
+	delete(s.inflight, e)
+	s.mu.Unlock()
+}
+
+// isAsync reports whether the passed event has been launched asynchronously,
+// that is, it keeps running after being dispatched (see TerminateRequest()).
+func isAsync(e domain.NSenterEventIface) bool {
+	ev, ok := e.(*NSenterEvent)
+
+	return ok && ev.Async
+}
+
+// agentLookup determines the binary to re-exec as the nsenter agent: the
+// explicitly configured one (if any), or else the default path (sysbox-fs' own
+// binary). Failing to validate the agent is reported right away, as no nsenter
+// request could succeed otherwise.
+func agentLookup(configPath string) (string, error) {
+
+	path := defaultAgentPath
+	if configPath != "" {
+		path = configPath
+	}
+
+	if err := agentCheck(path); err != nil {
+		if configPath == "" {
+			return "", fmt.Errorf("Unable to find a runnable nsenter agent: %v "+
+				"(an alternative agent can be configured)", err)
+		}
+		return "", fmt.Errorf("Unable to find a runnable nsenter agent: %v", err)
+	}
+
+	if path != defaultAgentPath {
+		logrus.Infof("Utilizing %v as nsenter agent", path)
+	}
+
+	return path, nil
+}
+
+// agentProbeReply returns the identification expected from the agent during
+// its validation, which also accounts for the protocol version it speaks.
+func agentProbeReply() string {
+	return fmt.Sprintf("sysbox-fs nsenter agent (protocol version %d)",
+		domain.NSenterMsgVersion)
+}
+
+// agentCheck verifies that the passed path refers to an executable regular file
+// that can actually be launched as "<path> nsenter", by having it identify
+// itself as an nsenter agent speaking our protocol version.
+func agentCheck(path string) error {
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("agent %v not accessible: %v", path, err)
+	}
+
+	if !fi.Mode().IsRegular() {
+		return fmt.Errorf("agent %v is not a regular file", path)
+	}
+
+	if fi.Mode().Perm()&0111 == 0 {
+		return fmt.Errorf("agent %v is not executable", path)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), agentProbeTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, path, "nsenter")
+	cmd.Args[0] = os.Args[0]
+	cmd.Env = []string{agentProbeEnv + "=1"}
+
+	out, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("agent %v can't be launched as nsenter: %v", path, err)
+	}
+
+	if reply := strings.TrimSpace(string(out)); reply != agentProbeReply() {
+		return fmt.Errorf("agent %v is not a compatible nsenter agent (replied %q, want %q)",
+			path, reply, agentProbeReply())
+	}
+
+	return nil
+}
+
+// isTransient reports whether a request failed due to a transient
+// namespace-entry condition that is worth retrying (see nsEntryError). Any other
+// failure, including the ones returned by the agent while serving the request,
+// is considered permanent.
+func isTransient(err error) bool {
+
+	var nsErr *nsEntryError
+
+	return errors.As(err, &nsErr) && nsErr.transient
+}
@@ -0,0 +1,359 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package nsenter
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+)
+
+func TestAgentLookup(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "sysbox-fs-agent")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Fake agents answering the validation probe (or not).
+	script := func(name, reply string, perm os.FileMode) string {
+		path := filepath.Join(dir, name)
+		content := fmt.Sprintf("#!/bin/sh\n"+
+			"[ \"$1\" = nsenter ] && [ -n \"$%s\" ] && echo \"%s\"\n",
+			agentProbeEnv, reply)
+		if err := ioutil.WriteFile(path, []byte(content), perm); err != nil {
+			t.Fatalf("Could not create temp file: %v", err)
+		}
+		return path
+	}
+
+	agent := script("sysbox-fs", agentProbeReply(), 0755)
+	altAgent := script("sysbox-fs-alt", agentProbeReply(), 0755)
+	noExec := script("noexec", agentProbeReply(), 0644)
+	notAgent := script("notagent", "", 0755)
+	oldAgent := script("oldagent", "sysbox-fs nsenter agent (protocol version 0)", 0755)
+
+	savedPath := defaultAgentPath
+	defer func() { defaultAgentPath = savedPath }()
+
+	tests := []struct {
+		name        string
+		defaultPath string
+		configPath  string
+		want        string
+		wantErr     []string
+	}{
+		{
+			//
+			// Test-case 1: Valid default agent, none configured. No errors
+			// expected.
+			//
+			name:        "1",
+			defaultPath: agent,
+			want:        agent,
+		},
+		{
+			//
+			// Test-case 2: Configured agent takes precedence over a valid
+			// default one.
+			//
+			name:        "2",
+			defaultPath: agent,
+			configPath:  altAgent,
+			want:        altAgent,
+		},
+		{
+			//
+			// Test-case 3: Unusable default agent, none configured. Error must
+			// point out the reason why the default path is not usable.
+			//
+			name:        "3",
+			defaultPath: filepath.Join(dir, "missing"),
+			wantErr:     []string{filepath.Join(dir, "missing"), "not accessible", "can be configured"},
+		},
+		{
+			//
+			// Test-case 4: Non-executable configured agent. No fallback to the
+			// default one is expected.
+			//
+			name:        "4",
+			defaultPath: agent,
+			configPath:  noExec,
+			wantErr:     []string{noExec, "not executable"},
+		},
+		{
+			//
+			// Test-case 5: Configured agent is a directory.
+			//
+			name:        "5",
+			defaultPath: agent,
+			configPath:  dir,
+			wantErr:     []string{dir, "not a regular file"},
+		},
+		{
+			//
+			// Test-case 6: Configured agent is executable but doesn't answer
+			// the probe.
+			//
+			name:        "6",
+			defaultPath: agent,
+			configPath:  notAgent,
+			wantErr:     []string{notAgent, "not a compatible nsenter agent"},
+		},
+		{
+			//
+			// Test-case 7: Configured agent speaks a different protocol
+			// version.
+			//
+			name:        "7",
+			defaultPath: agent,
+			configPath:  oldAgent,
+			wantErr:     []string{oldAgent, "not a compatible nsenter agent", "version 0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defaultAgentPath = tt.defaultPath
+			s := &nsenterService{}
+
+			err := s.Setup(nil, nil, tt.configPath)
+			if (err != nil) != (tt.wantErr != nil) {
+				t.Fatalf("Setup() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				for _, w := range tt.wantErr {
+					if !strings.Contains(err.Error(), w) {
+						t.Errorf("Setup() error = %q, missing %q", err, w)
+					}
+				}
+				return
+			}
+			if s.agentPath != tt.want {
+				t.Errorf("Setup() agent path = %v, want %v", s.agentPath, tt.want)
+			}
+		})
+	}
+}
+
+// Event stub producing a different canned result (and error) on every attempt.
+type flakyEvent struct {
+	stubEvent
+	results  []*domain.NSenterMessage
+	errs     []error
+	attempts int
+}
+
+func (e *flakyEvent) SendRequest() error {
+	e.res = e.results[e.attempts]
+	err := e.errs[e.attempts]
+	e.attempts++
+	return err
+}
+
+func TestNSenterService_TransientRetry(t *testing.T) {
+
+	savedDelay := transientRetryDelay
+	transientRetryDelay = time.Microsecond
+	defer func() { transientRetryDelay = savedDelay }()
+
+	errResponse := func(code syscall.Errno) *domain.NSenterMessage {
+		return &domain.NSenterMessage{
+			Type:    domain.ErrorResponse,
+			Payload: fuse.IOerror{Code: code},
+		}
+	}
+	okResponse := &domain.NSenterMessage{Type: domain.WriteFileResponse}
+	transientErr := &nsEntryError{pid: 1001, transient: true}
+	permanentErr := &nsEntryError{pid: 1001, transient: false}
+
+	tests := []struct {
+		name         string
+		results      []*domain.NSenterMessage
+		errs         []error
+		want         *domain.NSenterMessage
+		wantErr      error
+		wantAttempts int
+	}{
+		{
+			//
+			// Test-case 1: Transient namespace-entry failure followed by
+			// success. Request must be retried.
+			//
+			name:         "1",
+			results:      []*domain.NSenterMessage{nil, okResponse},
+			errs:         []error{transientErr, nil},
+			want:         okResponse,
+			wantErr:      nil,
+			wantAttempts: 2,
+		},
+		{
+			//
+			// Test-case 2: Namespace-entry failure with unreachable target
+			// namespaces. No retries expected.
+			//
+			name:         "2",
+			results:      []*domain.NSenterMessage{nil, okResponse},
+			errs:         []error{permanentErr, nil},
+			want:         nil,
+			wantErr:      permanentErr,
+			wantAttempts: 1,
+		},
+		{
+			//
+			// Test-case 3: EAGAIN returned by the requested operation itself.
+			// Request may not be idempotent, so no retries expected.
+			//
+			name:         "3",
+			results:      []*domain.NSenterMessage{errResponse(syscall.EAGAIN), okResponse},
+			errs:         []error{nil, nil},
+			want:         errResponse(syscall.EAGAIN),
+			wantErr:      nil,
+			wantAttempts: 1,
+		},
+		{
+			//
+			// Test-case 4: Permission error returned by the requested
+			// operation. No retries expected.
+			//
+			name:         "4",
+			results:      []*domain.NSenterMessage{errResponse(syscall.EPERM), okResponse},
+			errs:         []error{nil, nil},
+			want:         errResponse(syscall.EPERM),
+			wantErr:      nil,
+			wantAttempts: 1,
+		},
+		{
+			//
+			// Test-case 5: Persistent transient namespace-entry failures.
+			// Retries must be bounded.
+			//
+			name:         "5",
+			results:      []*domain.NSenterMessage{nil, nil, nil, nil, okResponse},
+			errs:         []error{transientErr, transientErr, transientErr, transientErr, nil},
+			want:         nil,
+			wantErr:      transientErr,
+			wantAttempts: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nss := NewNSenterService()
+
+			e := &flakyEvent{results: tt.results, errs: tt.errs}
+			e.req = &domain.NSenterMessage{Type: domain.WriteFileRequest}
+
+			if err := nss.SendRequestEvent(e); err != tt.wantErr {
+				t.Fatalf("SendRequestEvent() error = %v, want %v", err, tt.wantErr)
+			}
+			if e.attempts != tt.wantAttempts {
+				t.Errorf("SendRequestEvent() attempts = %v, want %v",
+					e.attempts, tt.wantAttempts)
+			}
+			if got := nss.ReceiveResponseEvent(e); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReceiveResponseEvent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Event stub blocking till released.
+type blockingEvent struct {
+	stubEvent
+	started chan struct{}
+	release chan struct{}
+}
+
+func (e *blockingEvent) SendRequest() error {
+	close(e.started)
+	<-e.release
+	return nil
+}
+
+func TestNSenterService_Shutdown(t *testing.T) {
+
+	nss := NewNSenterService()
+	s := nss.(*nsenterService)
+
+	// Synchronous request in progress.
+	blocked := &blockingEvent{
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	blocked.req = &domain.NSenterMessage{Type: domain.ReadFileRequest}
+
+	go nss.SendRequestEvent(blocked)
+	<-blocked.started
+
+	// Asynchronous request still running (never launched, so nothing to kill).
+	zr := newZombieReaper()
+	zr.nsenterStarted()
+	async := &NSenterEvent{
+		ReqMsg: &domain.NSenterMessage{Type: domain.MountSyscallRequest},
+		Async:  true,
+		reaper: zr,
+	}
+	if err := s.trackEvent(async); err != nil {
+		t.Fatalf("trackEvent() error = %v", err)
+	}
+
+	if got := nss.InflightEvents(); got != 2 {
+		t.Fatalf("InflightEvents() = %v, want %v", got, 2)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		nss.Shutdown()
+		close(done)
+	}()
+
+	// Shutdown must wait for the synchronous request to complete.
+	select {
+	case <-done:
+		t.Fatalf("Shutdown() returned with requests in-flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(blocked.release)
+
+	select {
+	case <-done:
+	case <-time.After(shutdownTimeout):
+		t.Fatalf("Shutdown() did not complete")
+	}
+
+	if got := nss.InflightEvents(); got != 0 {
+		t.Errorf("InflightEvents() = %v, want %v", got, 0)
+	}
+
+	// New requests are rejected once the service is shut down.
+	e := &stubEvent{req: &domain.NSenterMessage{Type: domain.ReadFileRequest}}
+	if err := nss.SendRequestEvent(e); err == nil {
+		t.Errorf("SendRequestEvent() after Shutdown() succeeded, want error")
+	}
+}
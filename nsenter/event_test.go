@@ -0,0 +1,808 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package nsenter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+)
+
+func TestAccessCheck(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "sysbox-fs-access")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Regular (non-executable) file. Notice that not even root is allowed to
+	// execute a file lacking all execute bits, so the expected verdicts hold
+	// regardless of the credentials of the process running the test.
+	file := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(file, []byte("0"), 0644); err != nil {
+		t.Fatalf("Could not create temp file: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		mode    domain.AccessMode
+		wantErr error
+	}{
+		{
+			//
+			// Test-case 1: Readable file. No errors expected.
+			//
+			name:    "1",
+			path:    file,
+			mode:    domain.R_OK,
+			wantErr: nil,
+		},
+		{
+			//
+			// Test-case 2: Existence check on a missing file (ENOENT).
+			//
+			name:    "2",
+			path:    filepath.Join(dir, "missing"),
+			mode:    0,
+			wantErr: syscall.ENOENT,
+		},
+		{
+			//
+			// Test-case 3: Non-final path component is not a directory (ENOTDIR).
+			//
+			name:    "3",
+			path:    filepath.Join(file, "child"),
+			mode:    0,
+			wantErr: syscall.ENOTDIR,
+		},
+		{
+			//
+			// Test-case 4: Execute access on a non-executable file (EACCES).
+			//
+			name:    "4",
+			path:    file,
+			mode:    domain.X_OK,
+			wantErr: syscall.EACCES,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := accessCheck(tt.path, tt.mode)
+			if err != tt.wantErr {
+				t.Errorf("accessCheck() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProcessResponse_Version(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		msg     string
+		wantErr error
+	}{
+		{
+			//
+			// Test-case 1: Message generated by an older binary (no version
+			// field). No errors expected.
+			//
+			name:    "1",
+			msg:     `{"message":"sleepResponse","payload":""}`,
+			wantErr: nil,
+		},
+		{
+			//
+			// Test-case 2: Message with the current protocol version. No errors
+			// expected.
+			//
+			name:    "2",
+			msg:     `{"version":1,"message":"sleepResponse","payload":""}`,
+			wantErr: nil,
+		},
+		{
+			//
+			// Test-case 3: Message with a newer protocol version and an unknown
+			// payload layout (VersionMismatch).
+			//
+			name:    "3",
+			msg:     `{"version":2,"message":"sleepResponse","payload":{"foo":[1,2]}}`,
+			wantErr: &domain.NSenterVersionMismatch{Version: 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &NSenterEvent{}
+
+			err := e.processResponse(strings.NewReader(tt.msg))
+			if !reflect.DeepEqual(err, tt.wantErr) {
+				t.Errorf("processResponse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (e.ResMsg == nil || e.ResMsg.Type != domain.SleepResponse) {
+				t.Errorf("processResponse() response = %v, want %v", e.ResMsg,
+					domain.SleepResponse)
+			}
+		})
+	}
+}
+
+func TestProcessResponse_Empty(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		msg      string
+		wantErr  bool
+		wantCode syscall.Errno
+	}{
+		{
+			//
+			// Test-case 1: Agent gone without responding (ENODATA).
+			//
+			name:     "1",
+			msg:      "",
+			wantErr:  false,
+			wantCode: syscall.ENODATA,
+		},
+		{
+			//
+			// Test-case 2: Error response carrying no errno (EIO).
+			//
+			name:     "2",
+			msg:      `{"version":1,"message":"errorResponse","payload":null}`,
+			wantErr:  false,
+			wantCode: syscall.EIO,
+		},
+		{
+			//
+			// Test-case 3: Truncated response (EIO).
+			//
+			name:     "3",
+			msg:      `{"version":1,"message":"readFileResponse","payl`,
+			wantErr:  true,
+			wantCode: syscall.EIO,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &NSenterEvent{}
+
+			err := e.processResponse(strings.NewReader(tt.msg))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("processResponse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			// Consumers must always obtain a well-formed error response.
+			resp := e.ReceiveResponse()
+			if resp == nil || resp.Type != domain.ErrorResponse {
+				t.Fatalf("ReceiveResponse() = %v, want %v", resp, domain.ErrorResponse)
+			}
+			ioErr, ok := resp.Payload.(fuse.IOerror)
+			if !ok || ioErr.Code != tt.wantCode {
+				t.Errorf("ReceiveResponse() payload = %v, want code %v", resp.Payload, tt.wantCode)
+			}
+		})
+	}
+
+	// Events lacking a response altogether (e.g. asynchronous ones).
+	resp := (&NSenterEvent{}).ReceiveResponse()
+	if resp == nil || resp.Type != domain.ErrorResponse {
+		t.Fatalf("ReceiveResponse() = %v, want %v", resp, domain.ErrorResponse)
+	}
+	if ioErr := resp.Payload.(fuse.IOerror); ioErr.Code != syscall.EIO {
+		t.Errorf("ReceiveResponse() error code = %v, want %v", ioErr.Code, syscall.EIO)
+	}
+}
+
+func TestProcessRequestMsg_Malformed(t *testing.T) {
+
+	tests := []struct {
+		name string
+		msg  string
+	}{
+		{
+			//
+			// Test-case 1: Truncated message.
+			//
+			name: "1",
+			msg:  `{"version":1,"message":"readFileRequest","payload":{"file":`,
+		},
+		{
+			//
+			// Test-case 2: Non-JSON garbage.
+			//
+			name: "2",
+			msg:  "foo",
+		},
+		{
+			//
+			// Test-case 3: Empty pipe.
+			//
+			name: "3",
+			msg:  "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent := &NSenterEvent{}
+
+			err := agent.processRequestMsg(strings.NewReader(tt.msg))
+			if err == nil {
+				t.Fatalf("processRequestMsg() error = nil, want decoding error")
+			}
+
+			var buf bytes.Buffer
+			if err := agent.sendResponse(&buf, err); err != nil {
+				t.Fatalf("sendResponse() error = %v", err)
+			}
+
+			// The master must receive a well-formed error response.
+			master := &NSenterEvent{}
+			if err := master.processResponse(&buf); err != nil {
+				t.Fatalf("processResponse() error = %v", err)
+			}
+			if master.ResMsg == nil || master.ResMsg.Type != domain.ErrorResponse {
+				t.Fatalf("processResponse() response = %v, want %v", master.ResMsg,
+					domain.ErrorResponse)
+			}
+			if ioErr := master.ResMsg.Payload.(fuse.IOerror); ioErr.Code != syscall.EIO {
+				t.Errorf("processResponse() error code = %v, want %v", ioErr.Code, syscall.EIO)
+			}
+		})
+	}
+}
+
+func TestProcessFileReadRequest_Raw(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "sysbox-fs-read")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	const content = "CD-ROM information, Id: cdrom.c 3.20 2003/12/17\n\ndrive name:\n"
+
+	file := filepath.Join(dir, "info")
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatalf("Could not create temp file: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		raw  bool
+		want string
+	}{
+		{
+			//
+			// Test-case 1: Default behavior. Surrounding whitespaces trimmed.
+			//
+			name: "1",
+			raw:  false,
+			want: strings.TrimSpace(content),
+		},
+		{
+			//
+			// Test-case 2: Raw content. Trailing newline preserved.
+			//
+			name: "2",
+			raw:  true,
+			want: content,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &NSenterEvent{
+				ReqMsg: &domain.NSenterMessage{
+					Type:    domain.ReadFileRequest,
+					Payload: domain.ReadFilePayload{File: file, Raw: tt.raw},
+				},
+			}
+
+			if err := e.processFileReadRequest(); err != nil {
+				t.Fatalf("processFileReadRequest() error = %v", err)
+			}
+			if e.ResMsg == nil || e.ResMsg.Type != domain.ReadFileResponse {
+				t.Fatalf("processFileReadRequest() response = %v, want %v",
+					e.ResMsg, domain.ReadFileResponse)
+			}
+			if got := e.ResMsg.Payload.(string); got != tt.want {
+				t.Errorf("processFileReadRequest() content = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessFilesReadRequest(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "sysbox-fs-read")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	file1 := filepath.Join(dir, "file1")
+	if err := ioutil.WriteFile(file1, []byte("1\n"), 0644); err != nil {
+		t.Fatalf("Could not create temp file: %v", err)
+	}
+	file2 := filepath.Join(dir, "file2")
+	if err := ioutil.WriteFile(file2, []byte("a b\n"), 0644); err != nil {
+		t.Fatalf("Could not create temp file: %v", err)
+	}
+	missing := filepath.Join(dir, "missing")
+
+	e := &NSenterEvent{
+		ReqMsg: &domain.NSenterMessage{
+			Type: domain.ReadFilesRequest,
+			Payload: domain.ReadFilesPayload{
+				Files: []domain.ReadFilePayload{
+					{File: file1},
+					{File: file2, Raw: true},
+					{File: missing},
+				},
+			},
+		},
+	}
+
+	if err := e.processFilesReadRequest(); err != nil {
+		t.Fatalf("processFilesReadRequest() error = %v", err)
+	}
+	if e.ResMsg == nil || e.ResMsg.Type != domain.ReadFilesResponse {
+		t.Fatalf("processFilesReadRequest() response = %v, want %v",
+			e.ResMsg, domain.ReadFilesResponse)
+	}
+
+	// Missing files are left out; the rest are trimmed as requested.
+	want := map[string]string{file1: "1", file2: "a b\n"}
+	if got := e.ResMsg.Payload.(map[string]string); !reflect.DeepEqual(got, want) {
+		t.Errorf("processFilesReadRequest() content = %q, want %q", got, want)
+	}
+}
+
+func TestProcessRmdirRequest(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "sysbox-fs-rmdir")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	empty := filepath.Join(dir, "empty")
+	if err := os.Mkdir(empty, 0755); err != nil {
+		t.Fatalf("Could not create temp dir: %v", err)
+	}
+
+	file := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(file, []byte("0"), 0644); err != nil {
+		t.Fatalf("Could not create temp file: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		want    domain.NSenterMsgType
+		wantErr syscall.Errno
+	}{
+		{
+			//
+			// Test-case 1: Empty directory. No errors expected.
+			//
+			name: "1",
+			path: empty,
+			want: domain.RmdirResponse,
+		},
+		{
+			//
+			// Test-case 2: Regular file must be left in place (ENOTDIR).
+			//
+			name:    "2",
+			path:    file,
+			want:    domain.ErrorResponse,
+			wantErr: syscall.ENOTDIR,
+		},
+		{
+			//
+			// Test-case 3: Non-empty directory (ENOTEMPTY).
+			//
+			name:    "3",
+			path:    dir,
+			want:    domain.ErrorResponse,
+			wantErr: syscall.ENOTEMPTY,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &NSenterEvent{
+				ReqMsg: &domain.NSenterMessage{
+					Type:    domain.RmdirRequest,
+					Payload: domain.RmdirPayload{Dir: tt.path},
+				},
+			}
+
+			if err := e.processRmdirRequest(); err != nil {
+				t.Fatalf("processRmdirRequest() error = %v", err)
+			}
+			if e.ResMsg == nil || e.ResMsg.Type != tt.want {
+				t.Fatalf("processRmdirRequest() response = %v, want %v", e.ResMsg, tt.want)
+			}
+			if tt.want == domain.ErrorResponse {
+				ioErr := e.ResMsg.Payload.(*fuse.IOerror)
+				if ioErr.RcvError != tt.wantErr {
+					t.Errorf("processRmdirRequest() error = %v, want %v",
+						ioErr.RcvError, tt.wantErr)
+				}
+			}
+		})
+	}
+
+	if _, err := os.Stat(empty); !os.IsNotExist(err) {
+		t.Errorf("processRmdirRequest() did not remove %v", empty)
+	}
+	if _, err := os.Stat(file); err != nil {
+		t.Errorf("processRmdirRequest() removed regular file %v", file)
+	}
+}
+
+func TestProcessDirReadRequest_MaxEntries(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "sysbox-fs-readdir")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Synthetic large directory.
+	const numEntries = 250
+	for i := 0; i < numEntries; i++ {
+		file := filepath.Join(dir, fmt.Sprintf("%04d", i))
+		if err := ioutil.WriteFile(file, nil, 0644); err != nil {
+			t.Fatalf("Could not create temp file: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		offset     int
+		maxEntries int
+		want       domain.NSenterMsgType
+		wantLen    int
+		wantFirst  string
+	}{
+		{
+			//
+			// Test-case 1: No limit. Whole directory returned.
+			//
+			name:       "1",
+			offset:     0,
+			maxEntries: 0,
+			want:       domain.ReadDirResponse,
+			wantLen:    numEntries,
+			wantFirst:  "0000",
+		},
+		{
+			//
+			// Test-case 2: First page of a directory exceeding the limit.
+			// Partial response expected.
+			//
+			name:       "2",
+			offset:     0,
+			maxEntries: 100,
+			want:       domain.ReadDirPartResponse,
+			wantLen:    100,
+			wantFirst:  "0000",
+		},
+		{
+			//
+			// Test-case 3: Intermediate page.
+			//
+			name:       "3",
+			offset:     100,
+			maxEntries: 100,
+			want:       domain.ReadDirPartResponse,
+			wantLen:    100,
+			wantFirst:  "0100",
+		},
+		{
+			//
+			// Test-case 4: Last page. Complete response expected.
+			//
+			name:       "4",
+			offset:     200,
+			maxEntries: 100,
+			want:       domain.ReadDirResponse,
+			wantLen:    50,
+			wantFirst:  "0200",
+		},
+		{
+			//
+			// Test-case 5: Offset beyond the end of the directory.
+			//
+			name:       "5",
+			offset:     numEntries,
+			maxEntries: 100,
+			want:       domain.ReadDirResponse,
+			wantLen:    0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &NSenterEvent{
+				ReqMsg: &domain.NSenterMessage{
+					Type: domain.ReadDirRequest,
+					Payload: domain.ReadDirPayload{
+						Dir:        dir,
+						Offset:     tt.offset,
+						MaxEntries: tt.maxEntries,
+					},
+				},
+			}
+
+			if err := e.processDirReadRequest(); err != nil {
+				t.Fatalf("processDirReadRequest() error = %v", err)
+			}
+			if e.ResMsg == nil || e.ResMsg.Type != tt.want {
+				t.Fatalf("processDirReadRequest() response = %v, want %v", e.ResMsg, tt.want)
+			}
+
+			entries := e.ResMsg.Payload.([]domain.FileInfo)
+			if len(entries) != tt.wantLen {
+				t.Fatalf("processDirReadRequest() entries = %v, want %v", len(entries), tt.wantLen)
+			}
+			if tt.wantLen > 0 && entries[0].Name() != tt.wantFirst {
+				t.Errorf("processDirReadRequest() first entry = %v, want %v",
+					entries[0].Name(), tt.wantFirst)
+			}
+		})
+	}
+}
+
+func TestProcessFstatRequest(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "sysbox-fs-fstat")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(file, []byte("1"), 0644); err != nil {
+		t.Fatalf("Could not create temp file: %v", err)
+	}
+
+	e := &NSenterEvent{
+		ReqMsg: &domain.NSenterMessage{
+			Type: domain.OpenFileRequest,
+			Payload: domain.OpenFilePayload{
+				File:  file,
+				Flags: strconv.Itoa(os.O_RDONLY),
+				Mode:  "0",
+			},
+		},
+	}
+	if err := e.processOpenFileRequest(); err != nil {
+		t.Fatalf("processOpenFileRequest() error = %v", err)
+	}
+	if e.ResMsg == nil || e.ResMsg.Type != domain.OpenFileResponse {
+		t.Fatalf("processOpenFileRequest() response = %v, want %v",
+			e.ResMsg, domain.OpenFileResponse)
+	}
+	handle := e.ResMsg.Payload.(domain.FileHandle)
+
+	// File size changes after the open.
+	if err := ioutil.WriteFile(file, []byte("12345"), 0644); err != nil {
+		t.Fatalf("Could not update temp file: %v", err)
+	}
+
+	e = &NSenterEvent{
+		ReqMsg: &domain.NSenterMessage{
+			Type:    domain.FstatRequest,
+			Payload: domain.FstatPayload{Handle: handle},
+		},
+	}
+	if err := e.processFstatRequest(); err != nil {
+		t.Fatalf("processFstatRequest() error = %v", err)
+	}
+	if e.ResMsg == nil || e.ResMsg.Type != domain.FstatResponse {
+		t.Fatalf("processFstatRequest() response = %v, want %v",
+			e.ResMsg, domain.FstatResponse)
+	}
+	if got := e.ResMsg.Payload.(domain.FileInfo).Size(); got != 5 {
+		t.Errorf("processFstatRequest() size = %v, want %v", got, 5)
+	}
+
+	// File replaced by a different one (ESTALE). The original inode is kept
+	// alive through a hard-link to prevent its reuse.
+	if err := os.Link(file, file+".orig"); err != nil {
+		t.Fatalf("Could not link temp file: %v", err)
+	}
+	if err := os.Remove(file); err != nil {
+		t.Fatalf("Could not remove temp file: %v", err)
+	}
+	if err := ioutil.WriteFile(file, []byte("1"), 0644); err != nil {
+		t.Fatalf("Could not re-create temp file: %v", err)
+	}
+
+	e = &NSenterEvent{
+		ReqMsg: &domain.NSenterMessage{
+			Type:    domain.FstatRequest,
+			Payload: domain.FstatPayload{Handle: handle},
+		},
+	}
+	if err := e.processFstatRequest(); err != nil {
+		t.Fatalf("processFstatRequest() error = %v", err)
+	}
+	if e.ResMsg == nil || e.ResMsg.Type != domain.ErrorResponse {
+		t.Fatalf("processFstatRequest() response = %v, want %v",
+			e.ResMsg, domain.ErrorResponse)
+	}
+	if got := e.ResMsg.Payload.(*fuse.IOerror).RcvError; got != syscall.ESTALE {
+		t.Errorf("processFstatRequest() error = %v, want %v", got, syscall.ESTALE)
+	}
+}
+
+// Reader emulating a volatile /proc file: content grows between reads, which
+// return short (and occasionally interrupted) results.
+type growingReader struct {
+	content []byte
+	off     int
+	reads   int
+}
+
+func (r *growingReader) Read(p []byte) (int, error) {
+	r.reads++
+
+	// Interrupted read.
+	if r.reads%3 == 0 {
+		return 0, syscall.EINTR
+	}
+
+	// Resource grows after the first read.
+	if r.reads == 2 {
+		r.content = append(r.content, []byte("line 2\nline 3\n")...)
+	}
+
+	if r.off >= len(r.content) {
+		return 0, io.EOF
+	}
+
+	// Short read.
+	n := copy(p[:4], r.content[r.off:])
+	r.off += n
+
+	return n, nil
+}
+
+func TestProcessRequestMsg_Creds(t *testing.T) {
+
+	if os.Geteuid() != 0 {
+		t.Skip("Test requires root privileges")
+	}
+
+	dir, err := ioutil.TempDir("", "sysbox-fs-creds")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Chmod(dir, 0755); err != nil {
+		t.Fatalf("Could not chmod temp dir: %v", err)
+	}
+
+	// File only readable by its owner (root) and by its group members.
+	file := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(file, []byte("1"), 0640); err != nil {
+		t.Fatalf("Could not create temp file: %v", err)
+	}
+	if err := os.Chown(file, 0, 2000); err != nil {
+		t.Fatalf("Could not chown temp file: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		creds    *domain.NSenterCreds
+		wantType domain.NSenterMsgType
+	}{
+		{
+			//
+			// Test-case 1: Caller belonging to the file's group through its
+			// supplementary groups. No errors expected.
+			//
+			name:     "1",
+			creds:    &domain.NSenterCreds{Uid: 1000, Gid: 1000, Groups: []uint32{3000, 2000}},
+			wantType: domain.ReadFileResponse,
+		},
+		{
+			//
+			// Test-case 2: Caller not belonging to the file's group (EACCES).
+			//
+			name:     "2",
+			creds:    &domain.NSenterCreds{Uid: 1000, Gid: 1000, Groups: []uint32{3000}},
+			wantType: domain.ErrorResponse,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := json.Marshal(domain.NSenterMessage{
+				Version: domain.NSenterMsgVersion,
+				Type:    domain.ReadFileRequest,
+				Payload: domain.ReadFilePayload{File: file},
+				Creds:   tt.creds,
+			})
+			if err != nil {
+				t.Fatalf("Could not encode request: %v", err)
+			}
+
+			// Credentials are changed for the calling thread only, so the
+			// request is processed within a dedicated (locked) thread that
+			// is discarded upon completion.
+			e := &NSenterEvent{}
+			errCh := make(chan error)
+			go func() {
+				runtime.LockOSThread()
+				errCh <- e.processRequestMsg(bytes.NewReader(msg))
+			}()
+
+			if err := <-errCh; err != nil {
+				t.Fatalf("processRequestMsg() error = %v", err)
+			}
+			if e.ResMsg == nil || e.ResMsg.Type != tt.wantType {
+				t.Fatalf("processRequestMsg() response = %+v, want %v", e.ResMsg, tt.wantType)
+			}
+			if tt.wantType == domain.ErrorResponse {
+				ioerr := e.ResMsg.Payload.(*fuse.IOerror)
+				if !os.IsPermission(ioerr.RcvError) {
+					t.Errorf("processRequestMsg() error = %v, want %v", ioerr.RcvError, syscall.EACCES)
+				}
+			}
+		})
+	}
+}
+
+func TestReadAll_Growing(t *testing.T) {
+
+	r := &growingReader{content: []byte("line 1\n")}
+
+	got, err := readAll(r)
+	if err != nil {
+		t.Fatalf("readAll() error = %v", err)
+	}
+
+	want := "line 1\nline 2\nline 3\n"
+	if string(got) != want {
+		t.Errorf("readAll() = %q, want %q", got, want)
+	}
+}
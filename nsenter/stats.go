@@ -0,0 +1,96 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package nsenter
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nestybox/sysbox-fs/domain"
+)
+
+// Accounting of nsenter round-trips, indexed by request type.
+type eventStats struct {
+	sync.Mutex
+	stats map[domain.NSenterMsgType]*domain.NSenterStats
+}
+
+func newEventStats() *eventStats {
+	return &eventStats{
+		stats: make(map[domain.NSenterMsgType]*domain.NSenterStats),
+	}
+}
+
+func (es *eventStats) record(
+	reqType domain.NSenterMsgType,
+	duration time.Duration,
+	res *domain.NSenterMessage,
+	err error) {
+
+	// Buckets' search is done prior to acquiring the lock to keep the critical
+	// section as short as possible.
+	bucket := len(domain.NSenterLatencyBuckets)
+	for i, bound := range domain.NSenterLatencyBuckets {
+		if duration <= bound {
+			bucket = i
+			break
+		}
+	}
+
+	es.Lock()
+	defer es.Unlock()
+
+	s, ok := es.stats[reqType]
+	if !ok {
+		s = &domain.NSenterStats{
+			Histogram: make([]uint64, len(domain.NSenterLatencyBuckets)+1),
+		}
+		es.stats[reqType] = s
+	}
+
+	switch {
+	case isTimeout(err):
+		s.Timeout++
+	case err != nil, res != nil && res.Type == domain.ErrorResponse:
+		s.Error++
+	default:
+		s.Success++
+	}
+
+	s.Latency += duration
+	s.Histogram[bucket]++
+}
+
+// Returns a snapshot of the collected stats.
+func (es *eventStats) snapshot() map[domain.NSenterMsgType]domain.NSenterStats {
+	es.Lock()
+	defer es.Unlock()
+
+	snapshot := make(map[domain.NSenterMsgType]domain.NSenterStats, len(es.stats))
+	for k, v := range es.stats {
+		s := *v
+		s.Histogram = append([]uint64(nil), v.Histogram...)
+		snapshot[k] = s
+	}
+
+	return snapshot
+}
+
+func isTimeout(err error) bool {
+	t, ok := err.(interface{ Timeout() bool })
+	return ok && t.Timeout()
+}
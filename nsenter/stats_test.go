@@ -0,0 +1,109 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package nsenter
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nestybox/sysbox-fs/domain"
+)
+
+// Event stub producing canned results.
+type stubEvent struct {
+	req *domain.NSenterMessage
+	res *domain.NSenterMessage
+	err error
+}
+
+func (e *stubEvent) SendRequest() error                      { return e.err }
+func (e *stubEvent) TerminateRequest() error                 { return nil }
+func (e *stubEvent) ReceiveResponse() *domain.NSenterMessage { return e.res }
+func (e *stubEvent) SetRequestMsg(m *domain.NSenterMessage)  { e.req = m }
+func (e *stubEvent) GetRequestMsg() *domain.NSenterMessage   { return e.req }
+func (e *stubEvent) SetResponseMsg(m *domain.NSenterMessage) { e.res = m }
+func (e *stubEvent) GetResponseMsg() *domain.NSenterMessage  { return e.res }
+func (e *stubEvent) GetProcessID() uint32                    { return 0 }
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string { return "i/o timeout" }
+func (timeoutError) Timeout() bool { return true }
+
+func TestNSenterService_Stats(t *testing.T) {
+
+	nss := NewNSenterService()
+
+	events := []*stubEvent{
+		{
+			req: &domain.NSenterMessage{Type: domain.ReadFileRequest},
+			res: &domain.NSenterMessage{Type: domain.ReadFileResponse},
+		},
+		{
+			req: &domain.NSenterMessage{Type: domain.ReadFileRequest},
+			res: &domain.NSenterMessage{Type: domain.ReadFileResponse},
+		},
+		{
+			req: &domain.NSenterMessage{Type: domain.ReadFileRequest},
+			res: &domain.NSenterMessage{Type: domain.ErrorResponse},
+		},
+		{
+			req: &domain.NSenterMessage{Type: domain.WriteFileRequest},
+			err: errors.New("Error creating sysbox-fs nsenter pipe"),
+		},
+		{
+			req: &domain.NSenterMessage{Type: domain.WriteFileRequest},
+			err: timeoutError{},
+		},
+	}
+
+	for _, e := range events {
+		nss.SendRequestEvent(e)
+	}
+
+	want := map[domain.NSenterMsgType]domain.NSenterStats{
+		domain.ReadFileRequest:  {Success: 2, Error: 1},
+		domain.WriteFileRequest: {Error: 1, Timeout: 1},
+	}
+
+	stats := nss.Stats()
+	if len(stats) != len(want) {
+		t.Errorf("Stats() = %v, want %d request types", stats, len(want))
+	}
+
+	for reqType, w := range want {
+		got, ok := stats[reqType]
+		if !ok {
+			t.Errorf("Stats() missing entry for %v", reqType)
+			continue
+		}
+
+		if got.Success != w.Success || got.Error != w.Error || got.Timeout != w.Timeout {
+			t.Errorf("Stats()[%v] = %+v, want %+v", reqType, got, w)
+		}
+
+		// Every round-trip must be accounted for in the latency histogram.
+		var total uint64
+		for _, c := range got.Histogram {
+			total += c
+		}
+		if total != w.Success+w.Error+w.Timeout {
+			t.Errorf("Stats()[%v] histogram accounts for %d events, want %d",
+				reqType, total, w.Success+w.Error+w.Timeout)
+		}
+	}
+}
@@ -348,6 +348,55 @@ func (p *process) UserNsInodeParent() (domain.Inode, error) {
 	return stat.Ino, nil
 }
 
+// Returns the inodes of all the user-namespaces the process' user-ns descends
+// from, starting with its immediate parent. The walk stops at the user-ns of
+// sysbox-fs (init user-ns), as its ancestors (if any) lay beyond our reach.
+func (p *process) UserNsInodeAncestors() ([]domain.Inode, error) {
+
+	// ioctl to retrieve the parent namespace.
+	const NS_GET_PARENT = 0xb702
+
+	usernsPath := filepath.Join(
+		"/proc",
+		strconv.FormatUint(uint64(p.pid), 10),
+		"ns",
+		"user",
+	)
+
+	nsFd, err := unix.Open(usernsPath, unix.O_RDONLY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var ancestors []domain.Inode
+
+	for {
+		ret, _, errno := unix.Syscall(
+			unix.SYS_IOCTL,
+			uintptr(nsFd),
+			uintptr(NS_GET_PARENT),
+			0)
+		unix.Close(nsFd)
+
+		if errno != 0 {
+			// EPERM is returned once the parent ns falls out of our scope.
+			if errno == unix.EPERM {
+				return ancestors, nil
+			}
+			return nil, errno
+		}
+		nsFd = int(ret)
+
+		var stat unix.Stat_t
+		if err := unix.Fstat(nsFd, &stat); err != nil {
+			unix.Close(nsFd)
+			return nil, err
+		}
+
+		ancestors = append(ancestors, stat.Ino)
+	}
+}
+
 // Collects the namespace inodes of the given process
 func (p *process) GetNsInodes() (map[string]domain.Inode, error) {
 
@@ -442,33 +491,19 @@ func (p *process) init() error {
 		return err
 	}
 
-	// effective uid
-	str := space.ReplaceAllString(p.status["Uid"], " ")
-	str = strings.TrimSpace(str)
-	uids := strings.Split(str, " ")
-	if len(uids) != 4 {
-		return fmt.Errorf("invalid uid status: %+v", uids)
-	}
-	euid, err := strconv.Atoi(uids[1])
+	// effective uid & gid
+	euid, err := p.statusEffId("Uid")
 	if err != nil {
 		return err
 	}
-
-	// effective gid
-	str = space.ReplaceAllString(p.status["Gid"], " ")
-	str = strings.TrimSpace(str)
-	gids := strings.Split(str, " ")
-	if len(gids) != 4 {
-		return fmt.Errorf("invalid gid status: %+v", gids)
-	}
-	egid, err := strconv.Atoi(gids[1])
+	egid, err := p.statusEffId("Gid")
 	if err != nil {
 		return err
 	}
 
 	// supplementary groups
 	sgid := []uint32{}
-	str = space.ReplaceAllString(p.status["Groups"], " ")
+	str := space.ReplaceAllString(p.status["Groups"], " ")
 	str = strings.TrimSpace(str)
 	groups := strings.Split(str, " ")
 	for _, g := range groups {
@@ -498,8 +533,8 @@ func (p *process) init() error {
 	p.cwd, _ = os.Readlink(cwd)
 	p.procroot = root
 	p.proccwd = cwd
-	p.uid = uint32(euid)
-	p.gid = uint32(egid)
+	p.uid = euid
+	p.gid = egid
 	p.sgid = sgid
 
 	// Mark process as fully initialized.
@@ -508,6 +543,50 @@ func (p *process) init() error {
 	return nil
 }
 
+// initCreds() retrieves the process' effective uid & gid. Unlike init(), no
+// other process attribute is collected.
+func (p *process) initCreds() error {
+
+	if err := p.getStatus([]string{"Uid", "Gid"}); err != nil {
+		return err
+	}
+
+	euid, err := p.statusEffId("Uid")
+	if err != nil {
+		return err
+	}
+	egid, err := p.statusEffId("Gid")
+	if err != nil {
+		return err
+	}
+
+	p.uid = euid
+	p.gid = egid
+
+	return nil
+}
+
+// statusEffId() parses the effective id out of a previously collected "Uid"
+// or "Gid" status field (real, effective, saved-set and filesystem ids).
+func (p *process) statusEffId(field string) (uint32, error) {
+
+	space := regexp.MustCompile(`\s+`)
+
+	str := space.ReplaceAllString(p.status[field], " ")
+	str = strings.TrimSpace(str)
+	ids := strings.Split(str, " ")
+	if len(ids) != 4 {
+		return 0, fmt.Errorf("invalid %s status: %+v", strings.ToLower(field), ids)
+	}
+
+	id, err := strconv.Atoi(ids[1])
+	if err != nil {
+		return 0, err
+	}
+
+	return uint32(id), nil
+}
+
 // getStatus retrieves process status info obtained from the
 // /proc/[pid]/status file.
 func (p *process) getStatus(fields []string) error {
@@ -733,6 +812,16 @@ func (p *process) pathAccess(path string, mode domain.AccessMode) error {
 // error occurred during the check.
 func (p *process) checkPerm(path string, aMode domain.AccessMode) (bool, error) {
 
+	// Some callers (e.g. FUSE requests lacking credentials) don't supply the
+	// process' uid/gid. Rather than making permission decisions on behalf of
+	// root, obtain the process' effective ids from its status file (unless
+	// init() already did).
+	if !p.initialized && p.pid != 0 && p.uid == 0 && p.gid == 0 {
+		if err := p.initCreds(); err != nil {
+			return false, err
+		}
+	}
+
 	fi, err := os.Stat(path)
 	if err != nil {
 		return false, err
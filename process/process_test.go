@@ -19,6 +19,7 @@ package process
 import (
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"syscall"
 	"testing"
@@ -906,3 +907,71 @@ func TestPathAccessSymlink(t *testing.T) {
 // TODO:
 // * test symlink resolution limit
 // * test long path
+
+func TestProcessCreateUnsetCreds(t *testing.T) {
+
+	if os.Geteuid() != 0 {
+		t.Skip("test requires root privileges")
+	}
+
+	const testUid, testGid = 1000, 1000
+
+	tmpDir, err := ioutil.TempDir("/tmp", "TestPathres")
+	if err != nil {
+		t.Fatalf("failed to create test dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// File owned by root and only accessible to its owner.
+	rootFile := filepath.Join(tmpDir, "rootFile")
+	if err := ioutil.WriteFile(rootFile, []byte("0"), 0600); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+
+	// File owned by the test uid/gid and only accessible to its owner.
+	userFile := filepath.Join(tmpDir, "userFile")
+	if err := ioutil.WriteFile(userFile, []byte("0"), 0600); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+	if err := os.Chown(userFile, testUid, testGid); err != nil {
+		t.Fatalf("failed to chown test file: %v", err)
+	}
+
+	// Unprivileged process whose credentials won't be passed to ProcessCreate().
+	cmd := exec.Command("sleep", "30")
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Credential: &syscall.Credential{Uid: testUid, Gid: testGid},
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("failed to start test process: %v", err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	ps := &processService{}
+	p := ps.ProcessCreate(uint32(cmd.Process.Pid), 0, 0).(*process)
+
+	// Creds must only be resolved when needed for a permission decision.
+	if p.status != nil {
+		t.Fatalf("ProcessCreate() collected process status: %v", p.status)
+	}
+
+	mode := domain.R_OK | domain.W_OK
+
+	ok, err := p.checkPerm(rootFile, mode)
+	if err != nil || ok {
+		t.Fatalf("checkPerm() failed: ok = %v, err = %v", ok, err)
+	}
+
+	ok, err = p.checkPerm(userFile, mode)
+	if err != nil || !ok {
+		t.Fatalf("checkPerm() failed: ok = %v, err = %v", ok, err)
+	}
+
+	if p.uid != testUid || p.gid != testGid {
+		t.Fatalf("checkPerm() creds = %v:%v, want %v:%v",
+			p.uid, p.gid, testUid, testGid)
+	}
+}
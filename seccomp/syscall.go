@@ -17,7 +17,10 @@
 package seccomp
 
 import (
+	"syscall"
+
 	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
 )
 
 // Syscall generic information / state.
@@ -33,3 +36,57 @@ type syscallCtx struct {
 	cntr        domain.ContainerIface // Container hosting the process generating the syscall
 	tracer      *syscallTracer        // Backpointer to the seccomp-tracer owning the syscall
 }
+
+// pathAccessNSenter checks if the process generating the syscall can access the
+// given path with the given mode. As opposed to process.PathAccess(), which
+// emulates the kernel's path-resolution logic, the verdict here is obtained
+// from the kernel itself by means of an nsenter agent executing faccessat()
+// with the process' credentials. Hence, this method should be preferred
+// whenever correctness matters more than the cost of the nsenter round-trip.
+func (s *syscallCtx) pathAccessNSenter(path string, mode domain.AccessMode) error {
+
+	process := s.processInfo
+	if process == nil {
+		process = s.tracer.service.prs.ProcessCreate(s.pid, 0, 0)
+	}
+
+	payload := &domain.AccessReqPayload{
+		Header: domain.NSenterMsgHeader{
+			Pid:          process.Pid(),
+			Uid:          process.Uid(),
+			Gid:          process.Gid(),
+			Root:         process.Root(),
+			Cwd:          process.Cwd(),
+			Capabilities: process.GetEffCaps(),
+		},
+		Path: path,
+		Mode: mode,
+	}
+
+	nss := s.tracer.service.nss
+	event := nss.NewEvent(
+		s.pid,
+		&domain.AllNSs,
+		&domain.NSenterMessage{
+			Type:    domain.AccessRequest,
+			Payload: payload,
+		},
+		nil,
+		false,
+	)
+
+	err := nss.SendRequestEvent(event)
+	if err != nil {
+		return err
+	}
+
+	responseMsg := nss.ReceiveResponseEvent(event)
+	if responseMsg.Type == domain.ErrorResponse {
+		if ioErr, ok := responseMsg.Payload.(fuse.IOerror); ok {
+			return ioErr.Code
+		}
+		return syscall.EACCES
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package seccomp
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+	"github.com/nestybox/sysbox-fs/mocks"
+	"github.com/nestybox/sysbox-fs/process"
+	"github.com/nestybox/sysbox-fs/sysio"
+)
+
+func Test_syscallCtx_pathAccessNSenter(t *testing.T) {
+
+	ios := sysio.NewIOService(domain.IOMemFileService)
+	prs := process.NewProcessService()
+	prs.Setup(ios)
+
+	pid := uint32(os.Getpid())
+
+	tests := []struct {
+		name    string
+		sendErr error
+		resp    *domain.NSenterMessage
+		wantErr error
+	}{
+		{
+			//
+			// Test-case 1: Access granted by the kernel.
+			//
+			name:    "1",
+			sendErr: nil,
+			resp:    &domain.NSenterMessage{Type: domain.AccessResponse},
+			wantErr: nil,
+		},
+		{
+			//
+			// Test-case 2: Access denied by the kernel. Its errno must be
+			// honored.
+			//
+			name:    "2",
+			sendErr: nil,
+			resp: &domain.NSenterMessage{
+				Type:    domain.ErrorResponse,
+				Payload: fuse.IOerror{Code: syscall.EACCES},
+			},
+			wantErr: syscall.EACCES,
+		},
+		{
+			//
+			// Test-case 3: Path not found.
+			//
+			name:    "3",
+			sendErr: nil,
+			resp: &domain.NSenterMessage{
+				Type:    domain.ErrorResponse,
+				Payload: fuse.IOerror{Code: syscall.ENOENT},
+			},
+			wantErr: syscall.ENOENT,
+		},
+		{
+			//
+			// Test-case 4: Agent could not be reached.
+			//
+			name:    "4",
+			sendErr: errors.New("nsenter failure"),
+			resp:    nil,
+			wantErr: errors.New("nsenter failure"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nss := &mocks.NSenterServiceIface{}
+			event := &mocks.NSenterEventIface{}
+
+			s := &syscallCtx{
+				pid:         pid,
+				processInfo: prs.ProcessCreate(pid, 1000, 1000),
+				tracer: &syscallTracer{
+					service: &SyscallMonitorService{nss: nss, prs: prs},
+				},
+			}
+
+			// The request must carry the process' credentials and be executed
+			// within all of its namespaces.
+			isAccessReq := func(m *domain.NSenterMessage) bool {
+				p, ok := m.Payload.(*domain.AccessReqPayload)
+				return ok && m.Type == domain.AccessRequest &&
+					p.Path == "/mnt/target" && p.Mode == domain.W_OK &&
+					p.Header.Pid == pid && p.Header.Uid == 1000 && p.Header.Gid == 1000
+			}
+			nss.On("NewEvent", pid, &domain.AllNSs, mock.MatchedBy(isAccessReq),
+				(*domain.NSenterMessage)(nil), false).Return(event)
+			nss.On("SendRequestEvent", event).Return(tt.sendErr)
+			if tt.resp != nil {
+				nss.On("ReceiveResponseEvent", event).Return(tt.resp)
+			}
+
+			err := s.pathAccessNSenter("/mnt/target", domain.W_OK)
+			if (err == nil) != (tt.wantErr == nil) ||
+				(err != nil && err.Error() != tt.wantErr.Error()) {
+				t.Errorf("pathAccessNSenter() error = %v, want %v", err, tt.wantErr)
+			}
+
+			nss.AssertExpectations(t)
+		})
+	}
+}
@@ -364,6 +364,7 @@ func (t *syscallTracer) process(
 			cntrID)
 		return t.createErrorResponse(req.Id, syscall.Errno(syscall.EPERM))
 	}
+	cntr.Validate()
 
 	syscallId := req.Data.Syscall
 	syscallStr := t.syscalls[syscallId]
@@ -465,8 +466,11 @@ func (t *syscallTracer) processMount(
 	}
 
 	// Resolve mount target and verify that process has the proper rights to
-	// access each of the components of the path.
-	err = process.PathAccess(mount.Target, 0)
+	// access each of the components of the path. The kernel's verdict is
+	// obtained, as a mistaken one could let the process operate on otherwise
+	// unreachable mountpoints.
+	mount.processInfo = process
+	err = mount.pathAccessNSenter(mount.Target, 0)
 	if err != nil {
 		return t.createErrorResponse(req.Id, err), nil
 	}
@@ -476,7 +480,6 @@ func (t *syscallTracer) processMount(
 	mount.gid = process.Gid()
 	mount.cwd = process.Cwd()
 	mount.root = process.Root()
-	mount.processInfo = process
 
 	logrus.Debug(mount)
 
@@ -529,8 +532,11 @@ func (t *syscallTracer) processUmount(
 	}
 
 	// Resolve umount target and verify that process has the proper rights to
-	// access each of the components of the path.
-	err = process.PathAccess(umount.Target, 0)
+	// access each of the components of the path. The kernel's verdict is
+	// obtained, as a mistaken one could let the process operate on otherwise
+	// unreachable mountpoints.
+	umount.processInfo = process
+	err = umount.pathAccessNSenter(umount.Target, 0)
 	if err != nil {
 		return t.createErrorResponse(req.Id, err), nil
 	}
@@ -540,7 +546,6 @@ func (t *syscallTracer) processUmount(
 	umount.gid = process.Gid()
 	umount.cwd = process.Cwd()
 	umount.root = process.Root()
-	umount.processInfo = process
 
 	logrus.Debug(umount)
 
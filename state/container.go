@@ -19,9 +19,11 @@ package state
 import (
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/sirupsen/logrus"
 	"golang.org/x/sys/unix"
 )
 
@@ -29,6 +31,8 @@ import (
 // Container type to represent all the container-state relevant to sysbox-fs.
 //
 type container struct {
+	cacheHits   uint64 // reads served from dataStore (atomic; 64-bit aligned)
+	cacheMisses uint64 // reads fetched from host FS (atomic; 64-bit aligned)
 	sync.RWMutex
 	id              string                      // container-id value generated by runC
 	initPid         uint32                      // initPid within container
@@ -40,6 +44,8 @@ type container struct {
 	gidSize         uint32                      // Gid range size
 	procRoPaths     []string                    // OCI spec read-only proc paths
 	procMaskPaths   []string                    // OCI spec masked proc paths
+	sysctlRo        bool                        // /proc/sys presented as read-only
+	initPidReuse    bool                        // init pid found held by an unrelated process
 	mountInfoParser domain.MountInfoParserIface // Per container mountinfo DB & parser
 	dataStore       domain.StateDataMap         // Handler's container-specific storage blob
 	initProc        domain.ProcessIface         // container's init process
@@ -58,6 +64,7 @@ func newContainer(
 	gidSize uint32,
 	procRoPaths []string,
 	procMaskPaths []string,
+	sysctlRo bool,
 	css *containerStateService,
 ) domain.ContainerIface {
 
@@ -71,6 +78,7 @@ func newContainer(
 		gidSize:       gidSize,
 		procRoPaths:   procRoPaths,
 		procMaskPaths: procMaskPaths,
+		sysctlRo:      sysctlRo,
 		service:       css,
 	}
 
@@ -116,6 +124,27 @@ func (c *container) GID() uint32 {
 	return c.gidFirst
 }
 
+func (c *container) UIDSize() uint32 {
+	c.intLock.RLock()
+	defer c.intLock.RUnlock()
+
+	return c.uidSize
+}
+
+func (c *container) GIDSize() uint32 {
+	c.intLock.RLock()
+	defer c.intLock.RUnlock()
+
+	return c.gidSize
+}
+
+func (c *container) CacheStats() domain.CacheStats {
+	return domain.CacheStats{
+		Hits:   atomic.LoadUint64(&c.cacheHits),
+		Misses: atomic.LoadUint64(&c.cacheMisses),
+	}
+}
+
 func (c *container) ProcRoPaths() []string {
 	c.intLock.RLock()
 	defer c.intLock.RUnlock()
@@ -123,6 +152,20 @@ func (c *container) ProcRoPaths() []string {
 	return c.procRoPaths
 }
 
+func (c *container) SysctlReadOnly() bool {
+	c.intLock.RLock()
+	defer c.intLock.RUnlock()
+
+	return c.sysctlRo
+}
+
+func (c *container) SetSysctlReadOnly(val bool) {
+	c.intLock.Lock()
+	defer c.intLock.Unlock()
+
+	c.sysctlRo = val
+}
+
 func (c *container) ProcMaskPaths() []string {
 	c.intLock.RLock()
 	defer c.intLock.RUnlock()
@@ -145,6 +188,55 @@ func (c *container) Data(path string, name string) (string, bool) {
 	return c.dataStore[path][name], true
 }
 
+// initPidReused returns true if the container's init pid is now held by an
+// unrelated process (e.g. after a pid wraparound following the container's exit
+// without its unregistration), as per the user-ns of the process currently
+// holding the pid, which differs from the one recorded for the init process. A
+// missing process is not deemed a reuse. Only the current process' user-ns is
+// looked up, so the check is cheap enough to be carried out on every request.
+func (c *container) initPidReused() bool {
+	c.intLock.RLock()
+	initPid := c.initPid
+	initProc := c.initProc
+	service := c.service
+	c.intLock.RUnlock()
+
+	if initProc == nil || service == nil || service.ios == nil {
+		return false
+	}
+
+	recorded, err := initProc.UserNsInode()
+	if err != nil {
+		return false
+	}
+
+	path := fmt.Sprintf("/proc/%d/ns/user", initPid)
+	current, err := service.ios.NewIOnode("", path, 0).GetNsInode()
+	if err != nil {
+		return false
+	}
+
+	return current != recorded
+}
+
+func (c *container) Validate() bool {
+	if !c.initPidReused() {
+		return true
+	}
+
+	c.intLock.Lock()
+	defer c.intLock.Unlock()
+
+	if !c.initPidReuse {
+		logrus.Warnf("Container %s init pid %d reused by an unrelated process; resetting its state",
+			c.id, c.initPid)
+		c.initPidReuse = true
+	}
+	c.dataStore = nil
+
+	return false
+}
+
 func (c *container) InitProc() domain.ProcessIface {
 	c.intLock.RLock()
 	defer c.intLock.RUnlock()
@@ -261,6 +353,7 @@ func (c *container) update(src *container) error {
 		)
 		c.initPid = src.initPid
 		c.rootInode = c.initProc.RootInode()
+		c.initPidReuse = false
 	}
 
 	if c.ctime != src.ctime {
@@ -325,6 +418,15 @@ func (c *container) SetCtime(t time.Time) {
 }
 
 func (c *container) SetData(path string, name string, data string) {
+	c.setData(path, name, data)
+
+	// Persist the state of the resources flagged as such.
+	if c.service != nil && c.service.isPersistentPath(path) {
+		c.service.persistData(c)
+	}
+}
+
+func (c *container) setData(path string, name string, data string) {
 	c.intLock.Lock()
 	defer c.intLock.Unlock()
 
@@ -339,6 +441,60 @@ func (c *container) SetData(path string, name string, data string) {
 	c.dataStore[path][name] = data
 }
 
+// Returns a copy of the handlers' state associated to this container.
+func (c *container) dataSnapshot() domain.StateDataMap {
+	c.intLock.RLock()
+	defer c.intLock.RUnlock()
+
+	data := make(domain.StateDataMap, len(c.dataStore))
+	for path, entries := range c.dataStore {
+		data[path] = make(domain.StateData, len(entries))
+		for name, val := range entries {
+			data[path][name] = val
+		}
+	}
+
+	return data
+}
+
+// Returns a copy of the container's attributes for debugging purposes.
+func (c *container) snapshot() domain.ContainerSnapshot {
+	c.intLock.RLock()
+	defer c.intLock.RUnlock()
+
+	var entries int
+	for _, data := range c.dataStore {
+		entries += len(data)
+	}
+
+	return domain.ContainerSnapshot{
+		ID:           c.id,
+		InitPid:      c.initPid,
+		Ctime:        c.ctime,
+		UidFirst:     c.uidFirst,
+		UidSize:      c.uidSize,
+		GidFirst:     c.gidFirst,
+		GidSize:      c.gidSize,
+		CacheEntries: entries,
+	}
+}
+
+// Releases the handlers' state associated to this container.
+func (c *container) freeData() {
+	c.intLock.Lock()
+	defer c.intLock.Unlock()
+
+	c.dataStore = nil
+}
+
+func (c *container) CacheHit() {
+	atomic.AddUint64(&c.cacheHits, 1)
+}
+
+func (c *container) CacheMiss() {
+	atomic.AddUint64(&c.cacheMisses, 1)
+}
+
 func (c *container) Lock() {
 	c.extLock.Lock()
 }
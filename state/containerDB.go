@@ -17,6 +17,7 @@
 package state
 
 import (
+	"sort"
 	"sync"
 	"time"
 
@@ -49,13 +50,29 @@ type containerStateService struct {
 
 	// Pointer to the service providing mount helper/parser capabilities.
 	mts domain.MountServiceIface
+
+	// On-disk store of the container state to preserve across sysbox-fs
+	// restarts (nil if persistence is disabled), and paths of the resources
+	// whose state is to be persisted.
+	store           *persistStore
+	persistentPaths map[string]bool
+
+	// Optional warmer of the cache of newly registered containers.
+	warmer domain.CacheWarmer
+
+	// Optional canceller of the writes left pending by unregistered containers.
+	canceller domain.WriteCanceller
+
+	// Present /proc/sys as read-only to newly registered containers.
+	sysctlRo bool
 }
 
 func NewContainerStateService() domain.ContainerStateServiceIface {
 
 	newCss := &containerStateService{
-		idTable:     make(map[string]*container),
-		usernsTable: make(map[domain.Inode]*container),
+		idTable:         make(map[string]*container),
+		usernsTable:     make(map[domain.Inode]*container),
+		persistentPaths: make(map[string]bool),
 	}
 
 	return newCss
@@ -83,6 +100,7 @@ func (css *containerStateService) ContainerCreate(
 	gidSize uint32,
 	procRoPaths []string,
 	procMaskPaths []string,
+	sysctlRo bool,
 	service domain.ContainerStateServiceIface,
 ) domain.ContainerIface {
 
@@ -96,6 +114,7 @@ func (css *containerStateService) ContainerCreate(
 		gidSize,
 		procRoPaths,
 		procMaskPaths,
+		sysctlRo,
 		css,
 	)
 }
@@ -202,8 +221,39 @@ func (css *containerStateService) ContainerRegister(c domain.ContainerIface) err
 	}
 
 	css.usernsTable[usernsInode] = currCntr
+
+	// Sysctls are read-only if requested at registration time, or by default.
+	currCntr.SetSysctlReadOnly(cntr.SysctlReadOnly() || css.sysctlRo)
+
+	// Containers registered with the same init pid are gone for good, as their
+	// init pid has been reused by the one of the new container.
+	var stale []*container
+	if pid := currCntr.InitPid(); pid != 0 {
+		for id, c := range css.idTable {
+			if id != currCntr.id && c.InitPid() == pid {
+				stale = append(stale, c)
+			}
+		}
+	}
 	css.Unlock()
 
+	for _, c := range stale {
+		css.ContainerMarkDead(c)
+	}
+
+	// Restore the state persisted during a previous sysbox-fs incarnation (if
+	// any).
+	css.restoreData(currCntr)
+
+	// Warm the container's cache up in the background, so as not to delay the
+	// registration.
+	css.RLock()
+	warmer := css.warmer
+	css.RUnlock()
+	if warmer != nil {
+		go warmer.WarmupCache(currCntr)
+	}
+
 	// No need to allocate cntr's locks as we're printing the temporary one.
 	logrus.Infof("Container registration completed: %v", cntr.string())
 
@@ -312,13 +362,37 @@ func (css *containerStateService) ContainerUnregister(c domain.ContainerIface) e
 
 	delete(css.idTable, cntr.id)
 	delete(css.usernsTable, usernsInode)
+	canceller := css.canceller
 	css.Unlock()
 
+	// Drop the host FS updates yet to be pushed on behalf of this container, as
+	// these could otherwise land after its departure.
+	if canceller != nil {
+		canceller.CancelWrites(currCntrIdTable)
+	}
+
+	// Release the state cached by handlers on behalf of this container, as
+	// well as the persisted one.
+	currCntrIdTable.freeData()
+	css.removeData(currCntrIdTable)
+
 	logrus.Infof("Container unregistration completed: id = %s", cntr.id)
 
 	return nil
 }
 
+// ContainerMarkDead is invoked upon detection of a container that has gone
+// away without its corresponding unregistration message (e.g. sysbox-runc has
+// been killed), to release all the resources associated to it.
+func (css *containerStateService) ContainerMarkDead(c domain.ContainerIface) error {
+
+	cntr := c.(*container)
+
+	logrus.Warnf("Container %s found dead: proceeding to unregister it", cntr.id)
+
+	return css.ContainerUnregister(cntr)
+}
+
 func (css *containerStateService) ContainerLookupById(id string) domain.ContainerIface {
 	css.RLock()
 	defer css.RUnlock()
@@ -372,26 +446,28 @@ func (css *containerStateService) ContainerLookupByProcess(
 	if cntr == nil {
 		// If no container is found then determine if we are dealing with a nested
 		// container scenario. If that's the case, it's natural to expect sysbox-fs
-		// to be totally unaware of L2 containers launching this request, so we
-		// would be tempted to discard it. To avoid that we obtain the parent user
-		// namespace (and its associated inode), and we search through containerDB
-		// once again. If there's a match then we serve this request making use of
-		// the parent (L1) system container state.
-		parentUsernsInode, err := p.UserNsInodeParent()
+		// to be totally unaware of L2 (or deeper) containers launching this
+		// request, so we would be tempted to discard it. To avoid that we climb
+		// up the user-ns hierarchy, and we search through containerDB for each
+		// ancestor user-ns. The first match (i.e. the closest registered sys
+		// container) is the one whose state will be utilized to serve this
+		// request.
+		ancestors, err := p.UserNsInodeAncestors()
 		if err != nil {
-			logrus.Errorf("Could not identify a parent user-namespace for pid %d",
+			logrus.Errorf("Could not identify the parent user-namespaces for pid %d",
 				p.Pid())
 			return nil
 		}
 
-		parentCntr := css.ContainerLookupByInode(parentUsernsInode)
-		if parentCntr == nil {
-			logrus.Infof("Could not find the container originating this request (userNsInode %d)",
-				usernsInode)
-			return nil
+		for _, ancestorInode := range ancestors {
+			if cntr := css.ContainerLookupByInode(ancestorInode); cntr != nil {
+				return cntr
+			}
 		}
 
-		return parentCntr
+		logrus.Infof("Could not find the container originating this request (userNsInode %d)",
+			usernsInode)
+		return nil
 	}
 
 	return cntr
@@ -415,3 +491,176 @@ func (css *containerStateService) ContainerDBSize() int {
 
 	return len(css.idTable)
 }
+
+// ContainerDBSnapshot returns a copy of the state of all the registered (and
+// pre-registered) containers, sorted by container id. Meant to be utilized for
+// debugging purposes (e.g. state dumps).
+func (css *containerStateService) ContainerDBSnapshot() []domain.ContainerSnapshot {
+	css.RLock()
+	defer css.RUnlock()
+
+	snapshot := make([]domain.ContainerSnapshot, 0, len(css.idTable))
+	for _, cntr := range css.idTable {
+		snapshot = append(snapshot, cntr.snapshot())
+	}
+
+	sort.Slice(snapshot, func(i, j int) bool {
+		return snapshot[i].ID < snapshot[j].ID
+	})
+
+	return snapshot
+}
+
+// Shutdown unregisters all the containers and releases the state cached on
+// their behalf. Meant to be invoked during sysbox-fs termination: fuse-servers
+// are left untouched here, as they're torn down (all at once) by the fuse
+// service right after.
+func (css *containerStateService) Shutdown() {
+
+	css.Lock()
+	cntrs := css.idTable
+	css.idTable = make(map[string]*container)
+	css.usernsTable = make(map[domain.Inode]*container)
+	css.Unlock()
+
+	for _, cntr := range cntrs {
+		cntr.freeData()
+	}
+
+	logrus.Infof("Container state service shutdown completed: %d containers unregistered",
+		len(cntrs))
+}
+
+// SetCacheWarmer sets the warmer to run upon the registration of containers (nil
+// disables warming).
+func (css *containerStateService) SetCacheWarmer(w domain.CacheWarmer) {
+	css.Lock()
+	defer css.Unlock()
+
+	css.warmer = w
+}
+
+// SetWriteCanceller sets the canceller of the pending writes of the containers
+// being unregistered (nil disables cancellation).
+func (css *containerStateService) SetWriteCanceller(w domain.WriteCanceller) {
+	css.Lock()
+	defer css.Unlock()
+
+	css.canceller = w
+}
+
+// SetSysctlReadOnly sets whether /proc/sys is to be presented as read-only to all
+// the containers registered from now on: sysctl writes are then rejected (EROFS)
+// regardless of the handler serving them or of the requester's capabilities.
+// Otherwise, it's up to each container to request it at registration time (see
+// ContainerCreate()).
+func (css *containerStateService) SetSysctlReadOnly(val bool) {
+	css.Lock()
+	defer css.Unlock()
+
+	css.sysctlRo = val
+}
+
+// SetPersistDir enables the persistence of the state of the resources flagged
+// as persistent (see RegisterPersistentPath()) into the given dir.
+func (css *containerStateService) SetPersistDir(dir string) error {
+
+	store, err := newPersistStore(dir)
+	if err != nil {
+		return err
+	}
+
+	css.Lock()
+	css.store = store
+	css.Unlock()
+
+	return nil
+}
+
+// RegisterPersistentPath flags the given resource as one whose state must be
+// persisted across sysbox-fs restarts.
+func (css *containerStateService) RegisterPersistentPath(path string) {
+	css.Lock()
+	defer css.Unlock()
+
+	if css.persistentPaths == nil {
+		css.persistentPaths = make(map[string]bool)
+	}
+	css.persistentPaths[path] = true
+}
+
+func (css *containerStateService) isPersistentPath(path string) bool {
+	css.RLock()
+	defer css.RUnlock()
+
+	return css.store != nil && css.persistentPaths[path]
+}
+
+// persistData stores the persistent subset of the container's state.
+func (css *containerStateService) persistData(c *container) {
+
+	css.RLock()
+	store := css.store
+	paths := css.persistentPaths
+	css.RUnlock()
+
+	if store == nil {
+		return
+	}
+
+	data := make(domain.StateDataMap)
+	for path, entries := range c.dataSnapshot() {
+		if paths[path] {
+			data[path] = entries
+		}
+	}
+
+	if err := store.save(c.ID(), data); err != nil {
+		logrus.Warnf("Could not persist state of container %s: %v", c.ID(), err)
+	}
+}
+
+// restoreData loads the state persisted on behalf of the container into its
+// data store.
+func (css *containerStateService) restoreData(c *container) {
+
+	css.RLock()
+	store := css.store
+	css.RUnlock()
+
+	if store == nil {
+		return
+	}
+
+	data, err := store.load(c.ID())
+	if err != nil {
+		logrus.Warnf("Could not restore state of container %s: %v", c.ID(), err)
+		return
+	}
+
+	for path, entries := range data {
+		for name, val := range entries {
+			c.setData(path, name, val)
+		}
+	}
+
+	if len(data) > 0 {
+		logrus.Infof("Restored persisted state of container %s", c.ID())
+	}
+}
+
+func (css *containerStateService) removeData(c *container) {
+
+	css.RLock()
+	store := css.store
+	css.RUnlock()
+
+	if store == nil {
+		return
+	}
+
+	if err := store.remove(c.ID()); err != nil {
+		logrus.Warnf("Could not remove persisted state of container %s: %v",
+			c.ID(), err)
+	}
+}
@@ -18,6 +18,7 @@ package state
 
 import (
 	"io/ioutil"
+	"os"
 	"reflect"
 	"sync"
 	"testing"
@@ -28,6 +29,7 @@ import (
 	"github.com/nestybox/sysbox-fs/process"
 	"github.com/nestybox/sysbox-fs/sysio"
 	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/mock"
 )
 
 // Sysbox-fs global services for all state's pkg unit-tests.
@@ -214,6 +216,7 @@ func Test_containerStateService_ContainerCreate(t *testing.T) {
 				tt.args.gidSize,
 				tt.args.procRoPaths,
 				tt.args.procMaskPaths,
+				false,
 				css); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("containerStateService.ContainerCreate() = %v, want %v",
 					got, tt.want)
@@ -604,6 +607,59 @@ func Test_containerStateService_ContainerUpdate(t *testing.T) {
 	}
 }
 
+func Test_container_ValidateInitPidReuse(t *testing.T) {
+
+	fss := &mocks.FuseServerServiceIface{}
+	css := &containerStateService{
+		idTable:     make(map[string]*container),
+		usernsTable: make(map[domain.Inode]*container),
+		fss:         fss,
+		prs:         prs,
+		ios:         ios,
+	}
+
+	// Registered container (user-ns inode recorded for its init process).
+	c1 := &container{
+		id:       "c1",
+		initPid:  7001,
+		initProc: prs.ProcessCreate(7001, 0, 0),
+		service:  css,
+	}
+	c1.InitProc().CreateNsInodes(71001)
+	inode, _ := c1.InitProc().UserNsInode()
+	css.idTable[c1.id] = c1
+	css.usernsTable[inode] = c1
+	initProc := c1.InitProc()
+
+	// Same init process: cached data must be preserved.
+	c1.SetData("/proc/uptime", "uptime", "100")
+	if !c1.Validate() {
+		t.Fatalf("container.Validate() = false, want true")
+	}
+	if got, ok := c1.Data("/proc/uptime", "uptime"); !ok || got != "100" {
+		t.Fatalf("container.Data() = %v, %v, want 100, true", got, ok)
+	}
+
+	// Init pid reused by a process living in a different user-ns: the
+	// container's cached data must be dropped, yet the container must neither
+	// adopt the new process nor be unregistered.
+	prs.ProcessCreate(7001, 0, 0).CreateNsInodes(72001)
+
+	if c1.Validate() {
+		t.Errorf("container.Validate() = true, want false")
+	}
+	if got, ok := c1.Data("/proc/uptime", "uptime"); ok {
+		t.Errorf("container.Data() = %v, %v, want empty, false", got, ok)
+	}
+	if c1.InitProc() != initProc {
+		t.Errorf("container.InitProc() replaced after init pid reuse")
+	}
+	if got := css.ContainerLookupById("c1"); got != c1 {
+		t.Errorf("containerStateService.ContainerLookupById() = %v, want %v", got, c1)
+	}
+	fss.AssertNotCalled(t, "DestroyFuseServer", c1.id)
+}
+
 func Test_containerStateService_ContainerUnregister(t *testing.T) {
 	type fields struct {
 		RWMutex     sync.RWMutex
@@ -765,6 +821,297 @@ func Test_containerStateService_ContainerUnregister(t *testing.T) {
 	}
 }
 
+func Test_containerStateService_ContainerMarkDead(t *testing.T) {
+
+	css := &containerStateService{
+		idTable:     make(map[string]*container),
+		usernsTable: make(map[domain.Inode]*container),
+		fss:         &mocks.FuseServerServiceIface{},
+		prs:         prs,
+		ios:         ios,
+	}
+
+	c1 := &container{
+		id:       "c1",
+		initProc: prs.ProcessCreate(1001, 0, 0),
+		service:  css,
+	}
+	c1.InitProc().CreateNsInodes(123456)
+	inode, _ := c1.InitProc().UserNsInode()
+
+	css.idTable[c1.id] = c1
+	css.usernsTable[inode] = c1
+	css.fss.(*mocks.FuseServerServiceIface).On("DestroyFuseServer", c1.id).Return(nil)
+
+	// Populate the container's handler-state cache.
+	c1.SetData("/proc/sys/net/netfilter/nf_conntrack_max", "nf_conntrack_max", "131072")
+
+	if err := css.ContainerMarkDead(c1); err != nil {
+		t.Fatalf("containerStateService.ContainerMarkDead() error = %v", err)
+	}
+
+	// Container's cached entries must be gone.
+	if _, ok := c1.Data("/proc/sys/net/netfilter/nf_conntrack_max", "nf_conntrack_max"); ok {
+		t.Errorf("containerStateService.ContainerMarkDead() did not release container's data")
+	}
+
+	// And so should the container itself.
+	if cntr := css.ContainerLookupById(c1.id); cntr != nil {
+		t.Errorf("ContainerLookupById() = %v, want nil", cntr)
+	}
+	if cntr := css.ContainerLookupByInode(inode); cntr != nil {
+		t.Errorf("ContainerLookupByInode() = %v, want nil", cntr)
+	}
+
+	css.fss.(*mocks.FuseServerServiceIface).AssertExpectations(t)
+}
+
+func Test_containerStateService_Shutdown(t *testing.T) {
+
+	// No fuse-server expectations set: these are torn down by the fuse service.
+	css := &containerStateService{
+		idTable:     make(map[string]*container),
+		usernsTable: make(map[domain.Inode]*container),
+		fss:         &mocks.FuseServerServiceIface{},
+		prs:         prs,
+		ios:         ios,
+	}
+
+	// Registered container.
+	c1 := &container{
+		id:       "c1",
+		initProc: prs.ProcessCreate(1001, 0, 0),
+		service:  css,
+	}
+	c1.InitProc().CreateNsInodes(123456)
+	inode, _ := c1.InitProc().UserNsInode()
+
+	css.idTable[c1.id] = c1
+	css.usernsTable[inode] = c1
+	c1.SetData("/proc/sys/net/netfilter/nf_conntrack_max", "nf_conntrack_max", "131072")
+
+	// Pre-registered container (no init process yet).
+	c2 := &container{
+		id:      "c2",
+		service: css,
+	}
+	css.idTable[c2.id] = c2
+	c2.SetData("/proc/sys/fs/file-max", "file-max", "1048576")
+
+	css.Shutdown()
+
+	if size := css.ContainerDBSize(); size != 0 {
+		t.Errorf("containerStateService.ContainerDBSize() = %v, want 0", size)
+	}
+	if cntr := css.ContainerLookupByInode(inode); cntr != nil {
+		t.Errorf("ContainerLookupByInode() = %v, want nil", cntr)
+	}
+	if _, ok := c1.Data("/proc/sys/net/netfilter/nf_conntrack_max", "nf_conntrack_max"); ok {
+		t.Errorf("containerStateService.Shutdown() did not release c1's data")
+	}
+	if _, ok := c2.Data("/proc/sys/fs/file-max", "file-max"); ok {
+		t.Errorf("containerStateService.Shutdown() did not release c2's data")
+	}
+
+	css.fss.(*mocks.FuseServerServiceIface).AssertExpectations(t)
+}
+
+func Test_containerStateService_ContainerDBSnapshot(t *testing.T) {
+
+	css := &containerStateService{
+		idTable:     make(map[string]*container),
+		usernsTable: make(map[domain.Inode]*container),
+		fss:         fss,
+		prs:         prs,
+		ios:         ios,
+	}
+
+	if got := css.ContainerDBSnapshot(); len(got) != 0 {
+		t.Errorf("containerStateService.ContainerDBSnapshot() = %v, want empty", got)
+	}
+
+	ctime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Registered container with two cached entries.
+	c1 := newContainer("c1", 1001, ctime, 231072, 65536, 231072, 65536, nil, nil, false, css).(*container)
+	c1.SetData("/proc/sys/net/netfilter/nf_conntrack_max", "nf_conntrack_max", "131072")
+	c1.SetData("/proc/sys/fs/file-max", "file-max", "1048576")
+	css.idTable[c1.id] = c1
+
+	// Pre-registered container (no cached entries).
+	c2 := newContainer("c2", 0, time.Time{}, 0, 0, 0, 0, nil, nil, false, css).(*container)
+	css.idTable[c2.id] = c2
+
+	want := []domain.ContainerSnapshot{
+		{
+			ID:           "c1",
+			InitPid:      1001,
+			Ctime:        ctime,
+			UidFirst:     231072,
+			UidSize:      65536,
+			GidFirst:     231072,
+			GidSize:      65536,
+			CacheEntries: 2,
+		},
+		{
+			ID: "c2",
+		},
+	}
+
+	got := css.ContainerDBSnapshot()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("containerStateService.ContainerDBSnapshot() = %v, want %v", got, want)
+	}
+
+	// Snapshot must not be affected by subsequent changes to the containers.
+	c2.SetData("/proc/sys/fs/file-max", "file-max", "1048576")
+	delete(css.idTable, c1.id)
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("containerStateService.ContainerDBSnapshot() = %v, want %v", got, want)
+	}
+	if got := css.ContainerDBSnapshot(); len(got) != 1 || got[0].CacheEntries != 1 {
+		t.Errorf("containerStateService.ContainerDBSnapshot() = %v, want c2 with 1 entry", got)
+	}
+}
+
+func Test_containerStateService_PersistData(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "sysbox-fs-persist")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	const (
+		persistPath = "/proc/sys/net/netfilter/nf_conntrack_max"
+		volatilPath = "/proc/sys/fs/file-max"
+	)
+
+	newCss := func() *containerStateService {
+		css := &containerStateService{
+			idTable:         make(map[string]*container),
+			usernsTable:     make(map[domain.Inode]*container),
+			persistentPaths: make(map[string]bool),
+			fss:             &mocks.FuseServerServiceIface{},
+			prs:             prs,
+			ios:             ios,
+			mts:             &mocks.MountServiceIface{},
+		}
+		if err := css.SetPersistDir(dir); err != nil {
+			t.Fatalf("SetPersistDir() error = %v", err)
+		}
+		css.RegisterPersistentPath(persistPath)
+
+		return css
+	}
+
+	// Initialize memory-based mock FS.
+	ios.RemoveAllIOnodes()
+	prs.ProcessCreate(1001, 0, 0).CreateNsInodes(123456)
+
+	// First sysbox-fs incarnation: container values are emulated.
+	css1 := newCss()
+	c1 := &container{
+		id:       "c1",
+		initPid:  1001,
+		initProc: prs.ProcessCreate(1001, 0, 0),
+		service:  css1,
+	}
+	css1.idTable[c1.id] = c1
+	c1.SetData(persistPath, "nf_conntrack_max", "131072")
+	c1.SetData(volatilPath, "file-max", "1048576")
+
+	// Simulated restart: container is pre-registered and registered again in a
+	// new sysbox-fs incarnation.
+	css2 := newCss()
+	css2.mts.(*mocks.MountServiceIface).On(
+		"NewMountInfoParser", mock.Anything, mock.Anything, true, true, true).Return(nil, nil)
+
+	css2.idTable["c1"] = &container{id: "c1", service: css2}
+	c2 := &container{id: "c1", initPid: 1001, service: css2}
+	if err := css2.ContainerRegister(c2); err != nil {
+		t.Fatalf("containerStateService.ContainerRegister() error = %v", err)
+	}
+
+	cntr := css2.ContainerLookupById("c1").(*container)
+	if val, ok := cntr.Data(persistPath, "nf_conntrack_max"); !ok || val != "131072" {
+		t.Errorf("persistent value = %v (%v), want 131072", val, ok)
+	}
+	if val, ok := cntr.Data(volatilPath, "file-max"); ok {
+		t.Errorf("non-persistent value = %v, want none", val)
+	}
+
+	// Persisted state is eliminated along with the container.
+	css2.fss.(*mocks.FuseServerServiceIface).On("DestroyFuseServer", "c1").Return(nil)
+	if err := css2.ContainerUnregister(cntr); err != nil {
+		t.Fatalf("containerStateService.ContainerUnregister() error = %v", err)
+	}
+	if data, err := css2.store.load("c1"); err != nil || data != nil {
+		t.Errorf("persistStore.load() = %v, %v, want no data", data, err)
+	}
+}
+
+func Test_containerStateService_ContainerRegisterSysctlReadOnly(t *testing.T) {
+
+	type args struct {
+		daemonRo bool
+		cntrRo   bool
+	}
+	tests := []struct {
+		name string
+		args args
+		want bool
+	}{
+		//
+		// Test-case 1: Neither the container nor the daemon request read-only
+		// sysctls.
+		//
+		{"1", args{false, false}, false},
+
+		//
+		// Test-case 2: Read-only sysctls requested in the registration payload.
+		//
+		{"2", args{false, true}, true},
+
+		//
+		// Test-case 3: Read-only sysctls enforced daemon-wide.
+		//
+		{"3", args{true, false}, true},
+	}
+
+	// Initialize memory-based mock FS.
+	ios.RemoveAllIOnodes()
+	prs.ProcessCreate(1001, 0, 0).CreateNsInodes(123456)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			css := &containerStateService{
+				idTable:     make(map[string]*container),
+				usernsTable: make(map[domain.Inode]*container),
+				fss:         &mocks.FuseServerServiceIface{},
+				prs:         prs,
+				ios:         ios,
+				mts:         &mocks.MountServiceIface{},
+			}
+			css.SetSysctlReadOnly(tt.args.daemonRo)
+			css.mts.(*mocks.MountServiceIface).On(
+				"NewMountInfoParser", mock.Anything, mock.Anything, true, true, true).Return(nil, nil)
+
+			css.idTable["c1"] = &container{id: "c1", service: css}
+			c := &container{id: "c1", initPid: 1001, sysctlRo: tt.args.cntrRo, service: css}
+			if err := css.ContainerRegister(c); err != nil {
+				t.Fatalf("containerStateService.ContainerRegister() error = %v", err)
+			}
+
+			cntr := css.ContainerLookupById("c1")
+			if got := cntr.SysctlReadOnly(); got != tt.want {
+				t.Errorf("container.SysctlReadOnly() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func Test_containerStateService_ContainerLookupById(t *testing.T) {
 	type fields struct {
 		RWMutex     sync.RWMutex
@@ -1051,3 +1398,92 @@ func Test_containerStateService_ContainerLookupByProcess(t *testing.T) {
 		})
 	}
 }
+
+// Process stub with a fixed user-ns hierarchy.
+type nestedProcess struct {
+	domain.ProcessIface
+	pid         uint32
+	usernsInode domain.Inode
+	ancestors   []domain.Inode
+}
+
+func (p *nestedProcess) Pid() uint32 {
+	return p.pid
+}
+
+func (p *nestedProcess) UserNsInode() (domain.Inode, error) {
+	return p.usernsInode, nil
+}
+
+func (p *nestedProcess) UserNsInodeAncestors() ([]domain.Inode, error) {
+	return p.ancestors, nil
+}
+
+func Test_containerStateService_ContainerLookupByProcess_Nested(t *testing.T) {
+
+	css := &containerStateService{
+		idTable:     make(map[string]*container),
+		usernsTable: make(map[domain.Inode]*container),
+		fss:         fss,
+		prs:         prs,
+		ios:         ios,
+	}
+
+	// Registered (L1) sys container with user-ns inode 1000.
+	c1 := &container{id: "c1"}
+	css.idTable[c1.id] = c1
+	css.usernsTable[1000] = c1
+
+	tests := []struct {
+		name string
+		p    domain.ProcessIface
+		want domain.ContainerIface
+	}{
+		{
+			//
+			// Test-case 1: Process within the L1 container itself.
+			//
+			name: "1",
+			p:    &nestedProcess{pid: 1001, usernsInode: 1000, ancestors: []domain.Inode{1}},
+			want: c1,
+		},
+		{
+			//
+			// Test-case 2: Process within an L2 container (one level down).
+			//
+			name: "2",
+			p:    &nestedProcess{pid: 2001, usernsInode: 2000, ancestors: []domain.Inode{1000, 1}},
+			want: c1,
+		},
+		{
+			//
+			// Test-case 3: Process within an L3 container (two levels down).
+			//
+			name: "3",
+			p: &nestedProcess{pid: 3001, usernsInode: 3000,
+				ancestors: []domain.Inode{2000, 1000, 1}},
+			want: c1,
+		},
+		{
+			//
+			// Test-case 4: Process with no registered container among its
+			// ancestors.
+			//
+			name: "4",
+			p:    &nestedProcess{pid: 4001, usernsInode: 4000, ancestors: []domain.Inode{1}},
+			want: nil,
+		},
+	}
+
+	//
+	// Testcase executions.
+	//
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := css.ContainerLookupByProcess(tt.p); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("containerStateService.ContainerLookupByProcess() = %v, want %v",
+					got, tt.want)
+			}
+		})
+	}
+}
@@ -18,6 +18,7 @@ package state
 
 import (
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 
@@ -168,6 +169,34 @@ func Test_container_Data(t *testing.T) {
 	}
 }
 
+func Test_container_CacheStats(t *testing.T) {
+
+	var cs1 = &container{id: "cs1"}
+
+	const workers, iters = 8, 1000
+
+	// Counters must remain accurate in the presence of concurrent updates.
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < iters; j++ {
+				cs1.CacheHit()
+				if j%2 == 0 {
+					cs1.CacheMiss()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	want := domain.CacheStats{Hits: workers * iters, Misses: workers * iters / 2}
+	if got := cs1.CacheStats(); got != want {
+		t.Errorf("container.CacheStats() = %+v, want %+v", got, want)
+	}
+}
+
 func Test_container_SetCtime(t *testing.T) {
 
 	var cs1 = &container{
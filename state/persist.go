@@ -0,0 +1,117 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/nestybox/sysbox-fs/domain"
+)
+
+//
+// On-disk store of the container state emulated by 'persistent' handlers, so
+// that it survives sysbox-fs restarts while the containers keep running. The
+// state of each container is kept as a JSON file named after the container id.
+//
+type persistStore struct {
+	dir string
+}
+
+func newPersistStore(dir string) (*persistStore, error) {
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	return &persistStore{dir: dir}, nil
+}
+
+func (s *persistStore) file(id string) (string, error) {
+
+	// Container ids are used as file names; reject anything that could escape
+	// the store's dir.
+	if id == "" || id != filepath.Base(id) || id == "." || id == ".." {
+		return "", fmt.Errorf("invalid container id %q", id)
+	}
+
+	return filepath.Join(s.dir, id+".json"), nil
+}
+
+// load returns the state persisted on behalf of the given container (if any).
+func (s *persistStore) load(id string) (domain.StateDataMap, error) {
+
+	file, err := s.file(id)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var data domain.StateDataMap
+	if err := json.Unmarshal(content, &data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// save replaces the state persisted on behalf of the given container. The file
+// is atomically replaced to avoid leaving partial content behind on crashes.
+func (s *persistStore) save(id string, data domain.StateDataMap) error {
+
+	file, err := s.file(id)
+	if err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	tmp := file + ".tmp"
+	if err := ioutil.WriteFile(tmp, content, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, file)
+}
+
+// remove eliminates the state persisted on behalf of the given container.
+func (s *persistStore) remove(id string) error {
+
+	file, err := s.file(id)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
@@ -23,6 +23,7 @@ import (
 	"io/ioutil"
 	"os"
 	"strconv"
+	"sync"
 	"syscall"
 
 	"github.com/nestybox/sysbox-fs/domain"
@@ -93,57 +94,107 @@ type IOnodeFile struct {
 	path  string
 	flags int
 	mode  os.FileMode
-	file  afero.File
 	fss   *ioFileService
+
+	// Opened files, indexed by the open flags they were obtained with.
+	// Overlapping opens with identical flags share the file (and hence its
+	// offset, as dup'ed fds do), which is only closed once the last of them is
+	// released; opens with differing flags get a file of their own. All other
+	// operations act on the file matching the current open flags.
+	mu    sync.Mutex
+	files map[int]*ionodeFileRef
+}
+
+// Opened file and number of outstanding Open() calls over it.
+type ionodeFileRef struct {
+	file afero.File
+	refs int
 }
 
 func (i *IOnodeFile) Open() error {
 
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if ref, ok := i.files[i.flags]; ok {
+		ref.refs++
+		return nil
+	}
+
 	file, err := i.fss.appFs.OpenFile(i.path, i.flags, i.mode)
 	if err != nil {
 		return err
 	}
 
-	i.file = file
+	if i.files == nil {
+		i.files = make(map[int]*ionodeFileRef)
+	}
+	i.files[i.flags] = &ionodeFileRef{file: file, refs: 1}
+
+	return nil
+}
+
+// openedFile returns the file currently opened with the current open flags (if
+// any).
+func (i *IOnodeFile) openedFile() afero.File {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if ref, ok := i.files[i.flags]; ok {
+		return ref.file
+	}
 
 	return nil
 }
 
 func (i *IOnodeFile) Read(p []byte) (n int, err error) {
 
-	if i.file == nil {
+	file := i.openedFile()
+	if file == nil {
 		return 0, fmt.Errorf("File not currently opened.")
 	}
 
-	return i.file.Read(p)
+	return file.Read(p)
 
 }
 
 func (i *IOnodeFile) Write(p []byte) (n int, err error) {
 
-	if i.file == nil {
+	file := i.openedFile()
+	if file == nil {
 		return 0, fmt.Errorf("File not currently opened.")
 	}
 
-	return i.file.Write(p)
+	return file.Write(p)
 }
 
 func (i *IOnodeFile) Close() error {
 
-	if i.file == nil {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	ref, ok := i.files[i.flags]
+	if !ok {
 		return fmt.Errorf("File not currently opened.")
 	}
 
-	return i.file.Close()
+	if ref.refs--; ref.refs > 0 {
+		return nil
+	}
+
+	delete(i.files, i.flags)
+
+	return ref.file.Close()
 }
 
 func (i *IOnodeFile) ReadAt(p []byte, off int64) (n int, err error) {
 
-	if i.file == nil {
+	file := i.openedFile()
+	if file == nil {
 		return 0, fmt.Errorf("File not currently opened.")
 	}
 
-	return i.file.ReadAt(p, off)
+	return file.ReadAt(p, off)
 }
 
 func (i *IOnodeFile) ReadDirAll() ([]os.FileInfo, error) {
@@ -240,7 +291,10 @@ func (i *IOnodeFile) GetNsInode() (domain.Inode, error) {
 		return 0, err
 	}
 
-	stat := info.Sys().(*syscall.Stat_t)
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || stat == nil {
+		return 0, fmt.Errorf("No stat info available for namespace file %s", i.path)
+	}
 
 	return stat.Ino, nil
 }
@@ -251,11 +305,12 @@ func (i *IOnodeFile) Stat() (os.FileInfo, error) {
 
 func (i *IOnodeFile) SeekReset() (int64, error) {
 
-	if i.file == nil {
+	file := i.openedFile()
+	if file == nil {
 		return 0, fmt.Errorf("File not currently opened.")
 	}
 
-	return i.file.Seek(io.SeekStart, 0)
+	return file.Seek(io.SeekStart, 0)
 }
 
 // Eliminate a node from a previously created file-system. Utilized exclusively
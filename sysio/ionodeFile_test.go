@@ -20,6 +20,7 @@ import (
 	"io/ioutil"
 	"os"
 	"reflect"
+	"sync"
 	"testing"
 
 	"github.com/nestybox/sysbox-fs/domain"
@@ -365,6 +366,140 @@ func TestIOnodeFile_Close(t *testing.T) {
 	}
 }
 
+func TestIOnodeFile_OpenCloseRefs(t *testing.T) {
+
+	i := ios.NewIOnode("node_1", "/proc/sys/net/node_1", 0600)
+
+	// Initialize memory-based fs.
+	ios.RemoveAllIOnodes()
+	i.WriteFile([]byte("file content 0123456789"))
+
+	read := func() error {
+		if _, err := i.SeekReset(); err != nil {
+			return err
+		}
+		_, err := i.Read(make([]byte, 4))
+		return err
+	}
+
+	// Overlapping open / close sequences: the file must remain usable till
+	// the last handle is released.
+	if err := i.Open(); err != nil {
+		t.Fatalf("IOnodeFile.Open() error = %v", err)
+	}
+	if err := i.Open(); err != nil {
+		t.Fatalf("IOnodeFile.Open() error = %v", err)
+	}
+	if err := i.Close(); err != nil {
+		t.Fatalf("IOnodeFile.Close() error = %v", err)
+	}
+	if err := read(); err != nil {
+		t.Errorf("IOnodeFile.Read() after partial close: error = %v", err)
+	}
+
+	// Concurrent handles.
+	var wg sync.WaitGroup
+	for j := 0; j < 16; j++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := i.Open(); err != nil {
+				t.Errorf("IOnodeFile.Open() error = %v", err)
+				return
+			}
+			if err := i.Close(); err != nil {
+				t.Errorf("IOnodeFile.Close() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := read(); err != nil {
+		t.Errorf("IOnodeFile.Read() after concurrent handles: error = %v", err)
+	}
+
+	// Last close releases the file.
+	if err := i.Close(); err != nil {
+		t.Fatalf("IOnodeFile.Close() error = %v", err)
+	}
+	if err := read(); err == nil {
+		t.Errorf("IOnodeFile.Read() after last close: no error, want error")
+	}
+	if err := i.Close(); err == nil {
+		t.Errorf("IOnodeFile.Close() of a closed file: no error, want error")
+	}
+
+	// File can be re-opened afterwards.
+	if err := i.Open(); err != nil {
+		t.Fatalf("IOnodeFile.Open() error = %v", err)
+	}
+	if err := read(); err != nil {
+		t.Errorf("IOnodeFile.Read() after re-open: error = %v", err)
+	}
+	i.Close()
+}
+
+func TestIOnodeFile_OpenCloseRefsFlags(t *testing.T) {
+
+	i := ios.NewIOnode("node_1", "/proc/sys/net/node_1", 0600)
+
+	// Initialize memory-based fs.
+	ios.RemoveAllIOnodes()
+	i.WriteFile([]byte("file content 0123456789"))
+
+	rd := func(want string) {
+		t.Helper()
+		i.SetOpenFlags(int(os.O_RDONLY))
+		p := make([]byte, len(want))
+		if _, err := i.Read(p); err != nil || string(p) != want {
+			t.Errorf("IOnodeFile.Read() = %q, %v, want %q", p, err, want)
+		}
+	}
+	wr := func(val string) {
+		t.Helper()
+		i.SetOpenFlags(int(os.O_WRONLY))
+		if _, err := i.Write([]byte(val)); err != nil {
+			t.Errorf("IOnodeFile.Write() error = %v", err)
+		}
+	}
+
+	// Overlapping read-only and write-only handles: each one gets a file of
+	// its own (i.e. opened with its own flags and offset).
+	i.SetOpenFlags(int(os.O_RDONLY))
+	if err := i.Open(); err != nil {
+		t.Fatalf("IOnodeFile.Open() error = %v", err)
+	}
+	rd("file")
+
+	i.SetOpenFlags(int(os.O_WRONLY))
+	if err := i.Open(); err != nil {
+		t.Fatalf("IOnodeFile.Open() error = %v", err)
+	}
+	wr("FILE")
+	rd(" con")
+
+	// Releasing the read-only handle leaves the write-only one untouched.
+	i.SetOpenFlags(int(os.O_RDONLY))
+	if err := i.Close(); err != nil {
+		t.Fatalf("IOnodeFile.Close() error = %v", err)
+	}
+	if err := i.Close(); err == nil {
+		t.Errorf("IOnodeFile.Close() of a closed file: no error, want error")
+	}
+	wr("FILE")
+
+	i.SetOpenFlags(int(os.O_WRONLY))
+	if err := i.Close(); err != nil {
+		t.Fatalf("IOnodeFile.Close() error = %v", err)
+	}
+
+	content, err := i.ReadFile()
+	if err != nil || string(content) != "FILEFILEtent 0123456789" {
+		t.Errorf("IOnodeFile.ReadFile() = %q, %v, want %q",
+			content, err, "FILEFILEtent 0123456789")
+	}
+}
+
 func TestIOnodeFile_ReadAt(t *testing.T) {
 	type fields struct {
 		name string
@@ -0,0 +1,25 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package integration holds sysbox-fs' end-to-end tests. These ones mount a
+// real sysbox-fs instance and exercise it through actual syscalls, so they
+// require root privileges. The test binary itself acts as nsenter agent, unless
+// a sysbox-fs binary is pointed to by SYSBOXFS_AGENT. They are only built with
+// the 'integration' build tag:
+//
+//	sudo go test -tags integration ./tests/integration/...
+//
+package integration
@@ -0,0 +1,228 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+	"github.com/nestybox/sysbox-fs/handler"
+	"github.com/nestybox/sysbox-fs/mount"
+	"github.com/nestybox/sysbox-fs/nsenter"
+	"github.com/nestybox/sysbox-fs/process"
+	"github.com/nestybox/sysbox-fs/state"
+	"github.com/nestybox/sysbox-fs/sysio"
+)
+
+// Environment variable optionally holding the path of a sysbox-fs binary to
+// re-exec as nsenter agent. By default the test binary itself acts as agent
+// (see TestMain()).
+const agentEnvVar = "SYSBOXFS_AGENT"
+
+// TestMain lets the test binary double as nsenter agent: sysbox-fs re-execs
+// /proc/self/exe as "<binary> nsenter", which must be served just like
+// sysbox-fs' own 'nsenter' command does, rather than running the tests.
+func TestMain(m *testing.M) {
+
+	if len(os.Args) > 1 && os.Args[1] == "nsenter" {
+		// nsenter errors are passed back to sysbox-fs via a pipe
+		nsenter.Init()
+		os.Exit(0)
+	}
+
+	os.Exit(m.Run())
+}
+
+// testEnv represents a live sysbox-fs instance serving a fake sys container.
+// The container is backed by the namespaces of the process running the tests,
+// so the emulated resources can be compared against the host ones.
+type testEnv struct {
+	t          *testing.T
+	baseDir    string
+	mountPoint string
+	css        domain.ContainerStateServiceIface
+	hds        domain.HandlerServiceIface
+	cntr       domain.ContainerIface
+}
+
+// setupEnv brings up the sysbox-fs services (wired as in sysbox-fs' main),
+// registers the fake container and waits for its FUSE mount to be ready. Tests
+// are skipped if the required privileges are not available.
+func setupEnv(t *testing.T) *testEnv {
+
+	if os.Geteuid() != 0 {
+		t.Skip("Integration tests require root privileges")
+	}
+	if _, err := os.Stat("/dev/fuse"); err != nil {
+		t.Skip("Integration tests require /dev/fuse")
+	}
+	agent := os.Getenv(agentEnvVar)
+
+	if testing.Verbose() {
+		logrus.SetLevel(logrus.DebugLevel)
+	} else {
+		logrus.SetOutput(ioutil.Discard)
+	}
+
+	baseDir, err := ioutil.TempDir("", "sysbox-fs-integration")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %v", err)
+	}
+
+	var nsenterService = nsenter.NewNSenterService()
+	var ioService = sysio.NewIOService(domain.IOOsFileService)
+	var processService = process.NewProcessService()
+	var handlerService = handler.NewHandlerService()
+	var fuseServerService = fuse.NewFuseServerService()
+	var containerStateService = state.NewContainerStateService()
+	var mountService = mount.NewMountService()
+
+	processService.Setup(ioService)
+
+	if err := nsenterService.Setup(processService, nil, agent); err != nil {
+		os.RemoveAll(baseDir)
+		t.Fatalf("nsenter service setup failed: %v", err)
+	}
+
+	handlerService.Setup(
+		handler.DefaultHandlers,
+		false,
+		containerStateService,
+		nsenterService,
+		processService,
+		ioService,
+	)
+
+	fuseServerService.Setup(
+		baseDir,
+		containerStateService,
+		ioService,
+		handlerService,
+	)
+
+	containerStateService.Setup(
+		fuseServerService,
+		processService,
+		ioService,
+		mountService,
+	)
+
+	mountService.Setup(
+		containerStateService,
+		handlerService,
+		processService,
+		nsenterService,
+	)
+
+	env := &testEnv{
+		t:       t,
+		baseDir: baseDir,
+		css:     containerStateService,
+		hds:     handlerService,
+	}
+
+	// Pre-registration creates (and mounts) the container's fuse-server.
+	id := fmt.Sprintf("integration-%d", time.Now().UnixNano())
+	if err := env.css.ContainerPreRegister(id); err != nil {
+		os.RemoveAll(baseDir)
+		t.Fatalf("Container pre-registration failed: %v", err)
+	}
+	env.mountPoint = filepath.Join(baseDir, id)
+
+	// Fake container whose init process is the one running the tests. Its
+	// root user is mapped to the host's one.
+	cntr := env.css.ContainerCreate(
+		id,
+		uint32(os.Getpid()),
+		time.Now(),
+		0,
+		65536,
+		0,
+		65536,
+		nil,
+		nil,
+		false,
+		env.css,
+	)
+	if err := env.css.ContainerRegister(cntr); err != nil {
+		env.teardown()
+		t.Fatalf("Container registration failed: %v", err)
+	}
+	env.cntr = env.css.ContainerLookupById(id)
+
+	return env
+}
+
+// teardown unregisters the fake container (which unmounts its fuse-server) and
+// removes the mountpoints left behind.
+func (env *testEnv) teardown() {
+
+	if cntr := env.css.ContainerLookupById(filepath.Base(env.mountPoint)); cntr != nil {
+		if err := env.css.ContainerUnregister(cntr); err != nil {
+			env.t.Errorf("Container unregistration failed: %v", err)
+		}
+	}
+
+	if err := os.RemoveAll(env.baseDir); err != nil {
+		env.t.Errorf("Could not remove temp dir %v: %v", env.baseDir, err)
+	}
+}
+
+// path returns the location of the passed (container) path within the
+// sysbox-fs mount.
+func (env *testEnv) path(p string) string {
+	return filepath.Join(env.mountPoint, p)
+}
+
+// readLine returns the first line of the passed file, as displayed through the
+// sysbox-fs mount.
+func (env *testEnv) readLine(p string) string {
+
+	data, err := ioutil.ReadFile(env.path(p))
+	if err != nil {
+		env.t.Fatalf("Could not read %v: %v", p, err)
+	}
+
+	return firstLine(data)
+}
+
+// hostReadLine returns the first line of the passed host file.
+func hostReadLine(t *testing.T, p string) string {
+
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		t.Fatalf("Could not read host file %v: %v", p, err)
+	}
+
+	return firstLine(data)
+}
+
+func firstLine(data []byte) string {
+	return strings.SplitN(string(data), "\n", 2)[0]
+}
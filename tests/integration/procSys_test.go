@@ -0,0 +1,112 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestProcSys_Read(t *testing.T) {
+
+	env := setupEnv(t)
+	defer env.teardown()
+
+	const path = "/proc/sys/kernel/cap_last_cap"
+
+	if got, want := env.readLine(path), hostReadLine(t, path); got != want {
+		t.Errorf("Read of %v = %v, want %v", path, got, want)
+	}
+}
+
+func TestProcSys_Write(t *testing.T) {
+
+	env := setupEnv(t)
+	defer env.teardown()
+
+	// Host-global resource with 'max' semantics. A value lower than the host
+	// one is written, so the host is left untouched regardless of the outcome.
+	const path = "/proc/sys/fs/file-max"
+
+	hostVal := hostReadLine(t, path)
+	val, err := strconv.ParseUint(hostVal, 10, 64)
+	if err != nil || val < 2 {
+		t.Skipf("Unexpected host value %q for %v", hostVal, path)
+	}
+	newVal := strconv.FormatUint(val-1, 10)
+
+	if err := ioutil.WriteFile(env.path(path), []byte(newVal+"\n"), 0644); err != nil {
+		t.Fatalf("Write of %v failed: %v", path, err)
+	}
+
+	// Container must display the value it wrote, while the host keeps the max.
+	if got := env.readLine(path); got != newVal {
+		t.Errorf("Read of %v = %v, want %v", path, got, newVal)
+	}
+	if got := hostReadLine(t, path); got != hostVal {
+		t.Errorf("Host value of %v = %v, want %v", path, got, hostVal)
+	}
+
+	// Non-numeric values must be rejected.
+	if err := ioutil.WriteFile(env.path(path), []byte("foo\n"), 0644); err == nil {
+		t.Errorf("Write of non-numeric value to %v succeeded, want error", path)
+	}
+}
+
+func TestProcSys_ReadDir(t *testing.T) {
+
+	env := setupEnv(t)
+	defer env.teardown()
+
+	const path = "/proc/sys/kernel"
+
+	entries, err := ioutil.ReadDir(env.path(path))
+	if err != nil {
+		t.Fatalf("ReadDir of %v failed: %v", path, err)
+	}
+
+	got := make(map[string]os.FileInfo, len(entries))
+	for _, e := range entries {
+		got[e.Name()] = e
+	}
+
+	// Every host entry must be listed, emulated ones (e.g. cap_last_cap)
+	// included.
+	hostEntries, err := ioutil.ReadDir(path)
+	if err != nil {
+		t.Fatalf("ReadDir of host dir %v failed: %v", path, err)
+	}
+	for _, e := range hostEntries {
+		info, ok := got[e.Name()]
+		if !ok {
+			t.Errorf("ReadDir of %v is missing entry %v", path, e.Name())
+			continue
+		}
+		if info.IsDir() != e.IsDir() {
+			t.Errorf("ReadDir of %v: entry %v IsDir() = %v, want %v",
+				path, e.Name(), info.IsDir(), e.IsDir())
+		}
+	}
+	if _, ok := got["cap_last_cap"]; !ok {
+		t.Errorf("ReadDir of %v is missing emulated entry cap_last_cap", path)
+	}
+}